@@ -0,0 +1,101 @@
+package main
+
+import (
+	"sync"
+
+	"go.lsp.dev/protocol"
+)
+
+// responseCache memoizes OmniSharp results per document position. Every /updatebuffer bumps
+// the document's generation, which is part of each key, so nothing computed against an
+// older buffer is served even before the client's version bump arrives. Documents are keyed by
+// documentKey, as in the document store
+type responseCache struct {
+	mu          sync.Mutex
+	generations map[protocol.DocumentURI]uint64
+	entries     map[cacheKey]interface{}
+}
+
+type cacheKey struct {
+	kind       string
+	uri        protocol.DocumentURI
+	generation uint64
+	pos        protocol.Position
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{
+		generations: make(map[protocol.DocumentURI]uint64),
+		entries:     make(map[cacheKey]interface{}),
+	}
+}
+
+// generation returns the buffer generation of uri, to capture before querying OmniSharp
+func (c *responseCache) generation(uri protocol.DocumentURI) uint64 {
+	uri = documentKey(uri)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generations[uri]
+}
+
+// bump records that OmniSharp's copy of uri changed, invalidating its entries
+func (c *responseCache) bump(uri protocol.DocumentURI) {
+	uri = documentKey(uri)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generations[uri]++
+	c.dropEntries(uri)
+}
+
+func (c *responseCache) get(kind string, uri protocol.DocumentURI, pos protocol.Position) (interface{}, bool) {
+	uri = documentKey(uri)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	value, ok := c.entries[cacheKey{kind, uri, c.generations[uri], pos}]
+	return value, ok
+}
+
+// put stores a result computed at generation, unless the buffer has changed since
+func (c *responseCache) put(kind string, uri protocol.DocumentURI, generation uint64, pos protocol.Position, value interface{}) {
+	uri = documentKey(uri)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generations[uri] != generation {
+		return
+	}
+	c.entries[cacheKey{kind, uri, generation, pos}] = value
+}
+
+// forget drops the entries of a closed or evicted document. Its generation is kept so results
+// still in flight from before can't be stored once it is reopened
+func (c *responseCache) forget(uri protocol.DocumentURI) {
+	uri = documentKey(uri)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.dropEntries(uri)
+}
+
+// clear drops every entry, bumping the generations so results still in flight aren't stored
+func (c *responseCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for uri := range c.generations {
+		c.generations[uri]++
+	}
+	c.entries = make(map[cacheKey]interface{})
+}
+
+// dropEntries removes the entries of uri. The caller must hold c.mu
+func (c *responseCache) dropEntries(uri protocol.DocumentURI) {
+	for key := range c.entries {
+		if key.uri == uri {
+			delete(c.entries, key)
+		}
+	}
+}
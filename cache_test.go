@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestResponseCacheGenerations(t *testing.T) {
+	const uri = protocol.DocumentURI("file:///project/Assets/Player.cs")
+	pos := protocol.Position{Line: 3, Character: 8}
+	tests := []struct {
+		name string
+		// steps run between capturing the generation and storing a result computed at it
+		steps func(cache *responseCache)
+		// after runs once the result is stored
+		after  func(cache *responseCache)
+		wantOK bool
+	}{
+		{
+			name:   "served at the generation it was computed for",
+			wantOK: true,
+		},
+		{
+			name:  "a bump while in flight keeps it from being stored",
+			steps: func(cache *responseCache) { cache.bump(uri) },
+		},
+		{
+			name:  "a bump drops it",
+			after: func(cache *responseCache) { cache.bump(uri) },
+		},
+		{
+			name:   "a bump of another document keeps it",
+			after:  func(cache *responseCache) { cache.bump("file:///project/Assets/Enemy.cs") },
+			wantOK: true,
+		},
+		{
+			name:  "forgetting the document drops it",
+			after: func(cache *responseCache) { cache.forget(uri) },
+		},
+		{
+			name:  "a reopen after forgetting doesn't store what was in flight",
+			steps: func(cache *responseCache) { cache.forget(uri); cache.bump(uri) },
+		},
+		{
+			name:  "clear while in flight",
+			steps: func(cache *responseCache) { cache.clear() },
+		},
+		{
+			name:  "clear drops it",
+			after: func(cache *responseCache) { cache.clear() },
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cache := newResponseCache()
+			// The document has been synced once, as on open
+			cache.bump(uri)
+			generation := cache.generation(uri)
+			if test.steps != nil {
+				test.steps(cache)
+			}
+			cache.put("hover", uri, generation, pos, "result")
+			if test.after != nil {
+				test.after(cache)
+			}
+			value, ok := cache.get("hover", uri, pos)
+			if ok != test.wantOK {
+				t.Fatalf("get = %v, %v, want found %v", value, ok, test.wantOK)
+			}
+			if ok && value != "result" {
+				t.Errorf("get = %v", value)
+			}
+		})
+	}
+}
+
+func TestResponseCacheKeys(t *testing.T) {
+	cache := newResponseCache()
+	uri := protocol.DocumentURI("file:///project/Assets/Player.cs")
+	pos := protocol.Position{Line: 3, Character: 8}
+	cache.put("hover", uri, cache.generation(uri), pos, "hover")
+
+	if _, ok := cache.get("completion", uri, pos); ok {
+		t.Errorf("a hover served as a completion")
+	}
+	if _, ok := cache.get("hover", uri, protocol.Position{Line: 3, Character: 9}); ok {
+		t.Errorf("a hover served at another position")
+	}
+	if value, ok := cache.get("hover", "file:///project/Assets/%50layer.cs", pos); !ok || value != "hover" {
+		t.Errorf("a hover not served under another spelling of its document")
+	}
+}
+
+// TestHoverCachedUntilChange checks the cache is bumped by the buffer updates of each change
+func TestHoverCachedUntilChange(t *testing.T) {
+	fake := newFakeOmniSharp(t, map[string]interface{}{
+		"/typelookup": TypeLookupResponse{Type: "float Player.speed"},
+	})
+	s, _ := newTestServer(t, fake)
+	uri := testURI(s, "Player.cs")
+	openTestDocument(s, uri, "class Player { float speed; }\n")
+	hover := func() {
+		t.Helper()
+		if _, err := s.handleHover(context.Background(), &protocol.HoverParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Position:     protocol.Position{Line: 0, Character: 23},
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hover()
+	hover()
+	if calls := fake.callCount("/typelookup"); calls != 1 {
+		t.Errorf("/typelookup called %d times for one position, want 1", calls)
+	}
+	typeAt(s, uri, 2, protocol.Position{Line: 1}, "//")
+	hover()
+	if calls := fake.callCount("/typelookup"); calls != 2 {
+		t.Errorf("/typelookup called %d times in all after a change, want 2", calls)
+	}
+}
+
+// TestBufferPushesInvalidateCache checks the buffers pushed besides those of changes, to a
+// replica taking over completion or to retry an empty completion, bump the cache
+func TestBufferPushesInvalidateCache(t *testing.T) {
+	const text = "class Player { float speed; void Start() { transform. } }"
+	tests := []struct {
+		name string
+		push func(s *Server, uri protocol.DocumentURI, replica *fakeOmniSharp)
+	}{
+		{"replica handoff", func(s *Server, uri protocol.DocumentURI, replica *fakeOmniSharp) {
+			s.handOffCompletion(context.Background(), NewOmniSharpClient(replica.URL, 5*time.Second, false))
+		}},
+		{"completion retry", func(s *Server, uri protocol.DocumentURI, replica *fakeOmniSharp) {
+			completeAt(t, s, uri, protocol.Position{Character: uint32(strings.Index(text, ".") + 1)}, protocol.CompletionTriggerKindInvoked)
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{
+				"/typelookup":   TypeLookupResponse{Type: "float Player.speed"},
+				"/autocomplete": []AutoCompleteResponse{},
+			})
+			replica := newFakeOmniSharp(t, map[string]interface{}{})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, text)
+			hover := func() {
+				t.Helper()
+				if _, err := s.handleHover(context.Background(), &protocol.HoverParams{
+					TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+						TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+						Position:     protocol.Position{Character: 23},
+					},
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			hover()
+			test.push(s, uri, replica)
+			hover()
+			if calls := fake.callCount("/typelookup"); calls != 2 {
+				t.Errorf("/typelookup called %d times around the push, want 2", calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+)
+
+// diagnosticsRequests are the methods computing the diagnostics of the document they name, whose
+// cancellation also cancels the diagnostics pass of the document
+var diagnosticsRequests = map[string]bool{methodCheckFile: true}
+
+// inFlightRequests are the requests not answered yet, which $/cancelRequest can cancel
+type inFlightRequests struct {
+	mu       sync.Mutex
+	requests map[jsonrpc2.ID]inFlightRequest
+}
+
+// inFlightRequest is a request not answered yet. uri is only set for diagnosticsRequests
+type inFlightRequest struct {
+	cancel context.CancelFunc
+	method string
+	uri    protocol.DocumentURI
+}
+
+func newInFlightRequests() *inFlightRequests {
+	return &inFlightRequests{requests: make(map[jsonrpc2.ID]inFlightRequest)}
+}
+
+// track derives the context of call, cancelled by $/cancelRequest until reply is called.
+// Handlers then stop at their next OmniSharp call, and withErrorCodes answers RequestCancelled
+func (r *inFlightRequests) track(ctx context.Context, call *jsonrpc2.Call, reply jsonrpc2.Replier) (context.Context, jsonrpc2.Replier) {
+	ctx, cancel := context.WithCancel(ctx)
+	id := call.ID()
+	request := inFlightRequest{cancel: cancel, method: call.Method()}
+	if diagnosticsRequests[request.method] {
+		var params struct {
+			URI protocol.DocumentURI `json:"uri"`
+		}
+		if json.Unmarshal(call.Params(), &params) == nil {
+			request.uri = params.URI
+		}
+	}
+	r.mu.Lock()
+	r.requests[id] = request
+	r.mu.Unlock()
+
+	return ctx, func(ctx context.Context, result interface{}, err error) error {
+		r.mu.Lock()
+		delete(r.requests, id)
+		r.mu.Unlock()
+		defer cancel()
+		return reply(ctx, result, err)
+	}
+}
+
+// cancel cancels the request with the id of a $/cancelRequest, which arrives as a JSON number
+// or string, returning it if it was in flight. Requests already answered are ignored
+func (r *inFlightRequests) cancel(params *protocol.CancelParams) (inFlightRequest, bool) {
+	var id jsonrpc2.ID
+	switch value := params.ID.(type) {
+	case float64:
+		id = jsonrpc2.NewNumberID(int32(value))
+	case string:
+		id = jsonrpc2.NewStringID(value)
+	default:
+		return inFlightRequest{}, false
+	}
+
+	r.mu.Lock()
+	request, ok := r.requests[id]
+	r.mu.Unlock()
+	if ok {
+		request.cancel()
+	}
+	return request, ok
+}
+
+// handleCancelRequest cancels a request in flight. Cancelling one of the diagnosticsRequests
+// gives up on the document's diagnostics, so its diagnostics pass is cancelled as well, keeping
+// what was published. Other requests leave the pass going: editors cancel hovers and code
+// actions whenever the caret moves
+func (s *Server) handleCancelRequest(params *protocol.CancelParams) {
+	if request, ok := s.inFlight.cancel(params); ok && request.uri != "" {
+		s.diagnostics.cancel(request.uri)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+)
+
+func TestInFlightRequestsCancel(t *testing.T) {
+	const uri = protocol.DocumentURI("file:///project/Assets/Player.cs")
+	tests := []struct {
+		name string
+		id   jsonrpc2.ID
+		// cancelID is the id as $/cancelRequest decodes it
+		cancelID   interface{}
+		replyFirst bool
+		wantCancel bool
+	}{
+		{name: "number id", id: jsonrpc2.NewNumberID(7), cancelID: float64(7), wantCancel: true},
+		{name: "string id", id: jsonrpc2.NewStringID("7"), cancelID: "7", wantCancel: true},
+		{name: "number and string ids differ", id: jsonrpc2.NewNumberID(7), cancelID: "7"},
+		{name: "another request", id: jsonrpc2.NewNumberID(7), cancelID: float64(8)},
+		{name: "already answered", id: jsonrpc2.NewNumberID(7), cancelID: float64(7), replyFirst: true},
+		{name: "malformed id", id: jsonrpc2.NewNumberID(7), cancelID: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests := newInFlightRequests()
+			call, err := jsonrpc2.NewCall(test.id, protocol.MethodTextDocumentHover, protocol.HoverParams{
+				TextDocumentPositionParams: protocol.TextDocumentPositionParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, reply := requests.track(context.Background(), call, func(context.Context, interface{}, error) error { return nil })
+			if test.replyFirst {
+				reply(ctx, nil, nil)
+			}
+
+			if _, ok := requests.cancel(&protocol.CancelParams{ID: test.cancelID}); ok != test.wantCancel {
+				t.Errorf("cancel = %v, want %v", ok, test.wantCancel)
+			}
+			if test.wantCancel && ctx.Err() == nil {
+				t.Errorf("the request's context is live after $/cancelRequest")
+			}
+			if !test.wantCancel && !test.replyFirst && ctx.Err() != nil {
+				t.Errorf("the request's context was cancelled")
+			}
+		})
+	}
+}
+
+func TestReplyingReleasesRequest(t *testing.T) {
+	requests := newInFlightRequests()
+	call, err := jsonrpc2.NewCall(jsonrpc2.NewNumberID(1), protocol.MethodShutdown, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, reply := requests.track(context.Background(), call, func(context.Context, interface{}, error) error { return nil })
+	reply(ctx, nil, nil)
+	if ctx.Err() == nil {
+		t.Errorf("the request's context is live once answered")
+	}
+	if len(requests.requests) != 0 {
+		t.Errorf("%d requests in flight once answered", len(requests.requests))
+	}
+}
+
+// TestCancelRequestAndDiagnosticsPass cancels a request about a document mid-pass. Only giving
+// up on a check of the document cancels its pass too
+func TestCancelRequestAndDiagnosticsPass(t *testing.T) {
+	const delay = 200 * time.Millisecond
+	tests := []struct {
+		name   string
+		method string
+		params func(uri protocol.DocumentURI) interface{}
+		// wantCounts are the sizes of the diagnostics sets published
+		wantCounts []int
+	}{
+		// The caret moves on, and the editor cancels its hover
+		{"hover", protocol.MethodTextDocumentHover, func(uri protocol.DocumentURI) interface{} {
+			return protocol.HoverParams{TextDocumentPositionParams: protocol.TextDocumentPositionParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}}}
+		}, []int{1}},
+		{"check", methodCheckFile, func(uri protocol.DocumentURI) interface{} { return CheckFileParams{URI: uri} }, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/codecheck": map[string]interface{}{"QuickFixes": []QuickFix{
+				{Id: "CS0103", LogLevel: "Error", Line: 0, Column: 30, EndLine: 0, EndColumn: 34, Text: "The name 'Move' does not exist"},
+			}}})
+			fake.setDelay(delay)
+			s, client := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, "class Player { void Update() { Move(); } }")
+			waitFor(t, "the diagnostics pass", func() bool { return fake.callCount("/codecheck") == 1 })
+
+			call, err := jsonrpc2.NewCall(jsonrpc2.NewNumberID(3), test.method, test.params(uri))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, _ := s.inFlight.track(context.Background(), call, func(context.Context, interface{}, error) error { return nil })
+			s.handleCancelRequest(&protocol.CancelParams{ID: float64(3)})
+			if ctx.Err() == nil {
+				t.Errorf("the request is live after $/cancelRequest")
+			}
+
+			// Long enough for the pass to have published had it gone on
+			time.Sleep(2 * delay)
+			if got := publishedCounts(t, client); !reflect.DeepEqual(got, test.wantCounts) {
+				t.Errorf("published %v diagnostics, want %v", got, test.wantCounts)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.lsp.dev/protocol"
+)
+
+// methodCheckFile runs a fresh codecheck of one file and returns its diagnostics, without
+// publishing them, for scripts and for editors forcing a refresh of a file's problems
+const methodCheckFile = "unity-lsp/checkFile"
+
+// CheckFileParams are the params of unity-lsp/checkFile
+type CheckFileParams struct {
+	URI protocol.DocumentURI `json:"uri"`
+}
+
+// CheckFileResult holds the diagnostics of a file and the version of the document they were
+// computed for, which is null for files the client doesn't have open
+type CheckFileResult struct {
+	URI         protocol.DocumentURI  `json:"uri"`
+	Version     *int32                `json:"version"`
+	Diagnostics []protocol.Diagnostic `json:"diagnostics"`
+}
+
+// handleCheckFile checks the buffer of an open document, or else the file as saved
+func (s *Server) handleCheckFile(ctx context.Context, params *CheckFileParams) (*CheckFileResult, error) {
+	omnisharp := s.backend()
+	if omnisharp == nil {
+		return nil, errors.New("OmniSharp is not ready")
+	}
+
+	doc, ok := s.documents.Get(params.URI)
+	if !ok {
+		text, err := os.ReadFile(params.URI.Filename())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", s.displayPath(params.URI), err)
+		}
+		doc = Document{URI: params.URI, Text: string(text)}
+	}
+
+	result := &CheckFileResult{URI: params.URI, Diagnostics: []protocol.Diagnostic{}}
+	if doc.Open {
+		version := doc.Version
+		result.Version = &version
+	}
+	if isDisabledText(doc.Text) {
+		return result, nil
+	}
+
+	diagnostics, err := s.codeCheck(ctx, omnisharp, doc)
+	if err != nil {
+		return nil, err
+	}
+	result.Diagnostics = diagnostics
+	return result, nil
+}
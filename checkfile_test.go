@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+// TestCheckFile checks unity-lsp/checkFile returns the diagnostics of a fresh codecheck of the
+// file asked for, with the version of the document they were computed for
+func TestCheckFile(t *testing.T) {
+	tests := []struct {
+		name string
+		// open opens the file with text, rather than writing it to disk
+		open        bool
+		text        string
+		edits       int
+		wantVersion int32
+		wantChecked bool
+		wantErr     string
+	}{
+		{name: "open document", open: true, text: "class Player { }", wantVersion: 1, wantChecked: true},
+		{name: "edited document", open: true, text: "class Player { }", edits: 2, wantVersion: 3, wantChecked: true},
+		{name: "file on disk", text: "class Player { }", wantChecked: true},
+		{name: "disabled document", open: true, text: "// unity-lsp: disable\nclass Player { }", wantVersion: 1},
+		{name: "missing file", wantErr: "failed to read Player.cs"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/codecheck": map[string]interface{}{
+				"QuickFixes": []QuickFix{{Id: "CS0103", LogLevel: "Error", Line: 0, Column: 6, EndLine: 0, EndColumn: 12, Text: "The name 'x' does not exist"}},
+			}})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			switch {
+			case test.open:
+				openTestDocument(s, uri, test.text)
+			case test.text != "":
+				if err := os.WriteFile(uri.Filename(), []byte(test.text), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i := 0; i < test.edits; i++ {
+				typeAt(s, uri, int32(i+2), protocol.Position{Character: 15}, " ")
+			}
+			checks := fake.callCount("/codecheck")
+
+			result, err := call(t, s, 1, methodCheckFile, CheckFileParams{URI: uri})
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("checkFile = %+v, %v; want an error containing %q", result, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if checked := fake.callCount("/codecheck") > checks; checked != test.wantChecked {
+				t.Errorf("ran a codecheck: %v, want %v", checked, test.wantChecked)
+			}
+
+			// Decoded as the client sees it
+			encoded, _ := json.Marshal(result)
+			var got struct {
+				URI         protocol.DocumentURI  `json:"uri"`
+				Version     *int32                `json:"version"`
+				Diagnostics []protocol.Diagnostic `json:"diagnostics"`
+			}
+			if err := json.Unmarshal(encoded, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.URI != uri || !strings.Contains(string(encoded), `"version":`) || (got.Version == nil) != !test.open || got.Version != nil && *got.Version != test.wantVersion {
+				t.Errorf("checkFile = %s, want %s at version %d", encoded, uri, test.wantVersion)
+			}
+			if !test.wantChecked {
+				if got.Diagnostics == nil || len(got.Diagnostics) != 0 {
+					t.Errorf("diagnostics = %s, want none", encoded)
+				}
+				return
+			}
+			if len(got.Diagnostics) != 1 || got.Diagnostics[0].Severity != protocol.DiagnosticSeverityError || got.Diagnostics[0].Range.Start.Character != 6 {
+				t.Errorf("diagnostics = %+v, want the codecheck's error", got.Diagnostics)
+			}
+		})
+	}
+}
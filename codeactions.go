@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// commandFixAll runs one of OmniSharp's fix-all providers, see fixAllArguments
+const commandFixAll = "unity-lsp.fixAll"
+
+// commands are the commands workspace/executeCommand runs
+var commands = []string{commandFixAll, commandOrganizeImports, commandRenameClass}
+
+// codeActionKinds are the kinds of code actions offered
+var codeActionKinds = []protocol.CodeActionKind{protocol.QuickFix, protocol.SourceOrganizeImports}
+
+// fixAllScope is where a fix-all provider applies its fix
+type fixAllScope string
+
+const (
+	fixAllDocument fixAllScope = "Document"
+	fixAllProject  fixAllScope = "Project"
+	fixAllSolution fixAllScope = "Solution"
+)
+
+var fixAllScopes = []fixAllScope{fixAllDocument, fixAllProject, fixAllSolution}
+
+// FixAllItem identifies a fix-all provider, as returned by /getfixall
+type FixAllItem struct {
+	Id      string `json:"Id"`
+	Message string `json:"Message"`
+}
+
+// LinePositionSpanTextChange is an edit in OmniSharp's format
+type LinePositionSpanTextChange struct {
+	NewText     string `json:"NewText"`
+	StartLine   uint32 `json:"StartLine"`
+	StartColumn uint32 `json:"StartColumn"`
+	EndLine     uint32 `json:"EndLine"`
+	EndColumn   uint32 `json:"EndColumn"`
+}
+
+// ModifiedFileResponse lists the edits OmniSharp made to one file
+type ModifiedFileResponse struct {
+	FileName string                       `json:"FileName"`
+	Changes  []LinePositionSpanTextChange `json:"Changes"`
+}
+
+// fixAllArguments is the single argument of commandFixAll
+type fixAllArguments struct {
+	FileName string      `json:"fileName"`
+	Scope    fixAllScope `json:"scope"`
+	Id       string      `json:"id"`
+	Message  string      `json:"message"`
+}
+
+// handleCodeAction offers to organize the document's usings, to implement missing members, to
+// add override or new to hiding members, to rename a MonoBehaviour after its file, and fix-all
+// actions, in each scope, for the diagnostics in the request
+func (s *Server) handleCodeAction(ctx context.Context, params *protocol.CodeActionParams) ([]protocol.CodeAction, error) {
+	omnisharp := s.backend()
+	if omnisharp == nil {
+		return nil, nil
+	}
+
+	actions := []protocol.CodeAction{}
+	if wantsCodeAction(params.Context.Only, protocol.SourceOrganizeImports) {
+		actions = append(actions, organizeImportsAction(params.TextDocument.URI))
+	}
+	if len(params.Context.Diagnostics) == 0 || !wantsCodeAction(params.Context.Only, protocol.QuickFix) {
+		return actions, nil
+	}
+	actions = append(actions, s.implementMemberActions(ctx, omnisharp, params)...)
+	actions = append(actions, s.hidingMemberActions(params)...)
+	actions = append(actions, classNameActions(params)...)
+
+	// The file OmniSharp reported the diagnostics in, which it knows by that name
+	fileName := params.TextDocument.URI.Filename()
+	for _, diagnostic := range params.Context.Diagnostics {
+		if data, ok := decodeDiagnosticData(diagnostic.Data); ok {
+			fileName = data.FileName
+			break
+		}
+	}
+	items, err := s.fixAllItems(ctx, omnisharp, params.TextDocument.URI, fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		diagnostics := diagnosticsWithCode(params.Context.Diagnostics, item.Id)
+		if len(diagnostics) == 0 {
+			continue
+		}
+		for _, scope := range fixAllScopes {
+			title := fmt.Sprintf("Fix all: %s (%s)", item.Message, scope)
+			actions = append(actions, protocol.CodeAction{
+				Title:       title,
+				Kind:        protocol.QuickFix,
+				Diagnostics: diagnostics,
+				Command: &protocol.Command{
+					Title:   title,
+					Command: commandFixAll,
+					Arguments: []interface{}{fixAllArguments{
+						FileName: fileName,
+						Scope:    scope,
+						Id:       item.Id,
+						Message:  item.Message,
+					}},
+				},
+			})
+		}
+	}
+	return actions, nil
+}
+
+// wantsCodeAction reports whether only, the kinds a client asked for, includes kind. Kinds are
+// hierarchical, so asking for source includes source.organizeImports
+func wantsCodeAction(only []protocol.CodeActionKind, kind protocol.CodeActionKind) bool {
+	if len(only) == 0 {
+		return true
+	}
+	for _, wanted := range only {
+		if kind == wanted || strings.HasPrefix(string(kind), string(wanted)+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// fixAllItems lists the fix-all providers of the document, cached until it changes since
+// clients ask for code actions whenever the caret moves
+func (s *Server) fixAllItems(ctx context.Context, omnisharp *OmniSharpClient, uri protocol.DocumentURI, fileName string) ([]FixAllItem, error) {
+	if cached, ok := s.cache.get("getfixall", uri, protocol.Position{}); ok {
+		markCacheHit(ctx)
+		return cached.([]FixAllItem), nil
+	}
+
+	generation := s.cache.generation(uri)
+	response, err := omnisharp.SendRequest(ctx, "/getfixall", map[string]interface{}{
+		"FileName": fileName,
+		"Scope":    fixAllDocument,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var omnisharpResponse struct {
+		Items []FixAllItem `json:"Items"`
+	}
+	if err := json.Unmarshal(response, &omnisharpResponse); err != nil {
+		return nil, err
+	}
+	s.cache.put("getfixall", uri, generation, protocol.Position{}, omnisharpResponse.Items)
+	return omnisharpResponse.Items, nil
+}
+
+func diagnosticsWithCode(diagnostics []protocol.Diagnostic, code string) []protocol.Diagnostic {
+	var matching []protocol.Diagnostic
+	for _, diagnostic := range diagnostics {
+		if diagnosticCode(diagnostic) == code {
+			matching = append(matching, diagnostic)
+		}
+	}
+	return matching
+}
+
+// handleExecuteCommand runs a command and applies its edit. It may prompt the user, so it
+// must not run on the connection's read loop
+func (s *Server) handleExecuteCommand(ctx context.Context, params *protocol.ExecuteCommandParams) (interface{}, error) {
+	switch params.Command {
+	case commandFixAll:
+		var args fixAllArguments
+		if err := commandArgument(params, &args); err != nil {
+			return nil, err
+		}
+		return nil, s.runFixAllCommand(ctx, args)
+	case commandOrganizeImports:
+		var args organizeImportsArguments
+		if err := commandArgument(params, &args); err != nil {
+			return nil, err
+		}
+		return s.runOrganizeImports(ctx, args)
+	case commandRenameClass:
+		var args renameClassArguments
+		if err := commandArgument(params, &args); err != nil {
+			return nil, err
+		}
+		return s.runRenameClass(ctx, args)
+	}
+	return nil, fmt.Errorf("unknown command %q", params.Command)
+}
+
+// commandArgument decodes the single argument of a command into args
+func commandArgument(params *protocol.ExecuteCommandParams, args interface{}) error {
+	if len(params.Arguments) != 1 {
+		return fmt.Errorf("%s takes one argument, got %d", params.Command, len(params.Arguments))
+	}
+	encoded, err := json.Marshal(params.Arguments[0])
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(encoded, args); err != nil {
+		return fmt.Errorf("invalid %s arguments: %w", params.Command, err)
+	}
+	return nil
+}
+
+// runFixAllCommand applies a fix-all, confirming solution-wide ones first
+func (s *Server) runFixAllCommand(ctx context.Context, args fixAllArguments) error {
+	omnisharp := s.backend()
+	if omnisharp == nil {
+		return nil
+	}
+
+	// A solution-wide fix can touch every file in the project, so make sure it was meant
+	if args.Scope == fixAllSolution && !s.confirm(ctx, fmt.Sprintf(s.localize("Apply \"%s\" to the entire solution? This may change many files."), args.Message), "Apply") {
+		return nil
+	}
+
+	edit, err := runFixAll(ctx, omnisharp, args)
+	if err != nil {
+		return err
+	}
+	if len(edit.Changes) == 0 {
+		return nil
+	}
+
+	return s.applyEdit(ctx, args.Message, edit)
+}
+
+// applyEdit asks the client to apply edit. protocol.Client.ApplyEdit decodes the response as
+// a bool instead of an ApplyWorkspaceEditResponse, so the call is made directly
+func (s *Server) applyEdit(ctx context.Context, label string, edit *protocol.WorkspaceEdit) error {
+	var response protocol.ApplyWorkspaceEditResponse
+	if _, err := s.conn.Call(ctx, protocol.MethodWorkspaceApplyEdit, &protocol.ApplyWorkspaceEditParams{
+		Label: label,
+		Edit:  *edit,
+	}, &response); err != nil {
+		return err
+	}
+	if !response.Applied {
+		return fmt.Errorf("client did not apply the edit: %s", response.FailureReason)
+	}
+	return nil
+}
+
+// confirm asks the user to confirm an action, reporting whether they chose action. The action
+// is localized here, message by the caller
+func (s *Server) confirm(ctx context.Context, message, action string) bool {
+	action = s.localize(action)
+	choice, err := s.client.ShowMessageRequest(ctx, &protocol.ShowMessageRequestParams{
+		Type:    protocol.MessageTypeWarning,
+		Message: message,
+		Actions: []protocol.MessageActionItem{{Title: action}, {Title: s.localize("Cancel")}},
+	})
+	return err == nil && choice != nil && choice.Title == action
+}
+
+// runFixAll asks OmniSharp for the edits of a fix-all without applying them to its buffers
+func runFixAll(ctx context.Context, omnisharp *OmniSharpClient, args fixAllArguments) (*protocol.WorkspaceEdit, error) {
+	response, err := omnisharp.SendRequest(ctx, "/runfixall", map[string]interface{}{
+		"FileName":                     args.FileName,
+		"Scope":                        args.Scope,
+		"FixAllFilter":                 []FixAllItem{{Id: args.Id, Message: args.Message}},
+		"WantsTextChanges":             true,
+		"WantsAllCodeActionOperations": false,
+		"ApplyChanges":                 false,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var omnisharpResponse struct {
+		Changes []ModifiedFileResponse `json:"Changes"`
+	}
+	if err := json.Unmarshal(response, &omnisharpResponse); err != nil {
+		return nil, err
+	}
+	return workspaceEdit(omnisharpResponse.Changes), nil
+}
+
+// workspaceEdit converts OmniSharp's per-file changes into a WorkspaceEdit
+func workspaceEdit(files []ModifiedFileResponse) *protocol.WorkspaceEdit {
+	changes := make(map[protocol.DocumentURI][]protocol.TextEdit)
+	for _, file := range files {
+		uri := pathToURI(file.FileName)
+		for _, change := range file.Changes {
+			changes[uri] = append(changes[uri], protocol.TextEdit{
+				Range: protocol.Range{
+					Start: protocol.Position{Line: change.StartLine, Character: change.StartColumn},
+					End:   protocol.Position{Line: change.EndLine, Character: change.EndColumn},
+				},
+				NewText: change.NewText,
+			})
+		}
+	}
+	return &protocol.WorkspaceEdit{Changes: changes}
+}
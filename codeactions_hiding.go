@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+const (
+	// diagnosticHidesOverridable is a member hiding a virtual or abstract one it could override
+	diagnosticHidesOverridable = "CS0114"
+	// diagnosticHidesMember is a member hiding one it can't override, so only new applies
+	diagnosticHidesMember = "CS0108"
+)
+
+// accessModifiers come first in a declaration, before the modifier added to a hiding member
+var accessModifiers = map[string]bool{"public": true, "protected": true, "internal": true, "private": true}
+
+// hidingMemberActions offers to add override to a member hiding an overridable one, preferred
+// as it is usually what was meant, and new to any hiding member to say the hiding is intended
+func (s *Server) hidingMemberActions(params *protocol.CodeActionParams) []protocol.CodeAction {
+	uri := params.TextDocument.URI
+	doc, ok := s.documents.Get(uri)
+	if !ok {
+		return nil
+	}
+
+	var actions []protocol.CodeAction
+	for _, diagnostic := range params.Context.Diagnostics {
+		code := fmt.Sprint(diagnostic.Code)
+		if code != diagnosticHidesOverridable && code != diagnosticHidesMember {
+			continue
+		}
+		if code == diagnosticHidesOverridable {
+			if edit, ok := hidingModifierEdit(doc.Text, diagnostic.Range.Start, "override"); ok {
+				actions = append(actions, s.hidingMemberAction("Add override", diagnostic, uri, doc.Version, edit, true))
+			}
+		}
+		if edit, ok := hidingModifierEdit(doc.Text, diagnostic.Range.Start, "new"); ok {
+			actions = append(actions, s.hidingMemberAction("Add new", diagnostic, uri, doc.Version, edit, code == diagnosticHidesMember))
+		}
+	}
+	return actions
+}
+
+func (s *Server) hidingMemberAction(title string, diagnostic protocol.Diagnostic, uri protocol.DocumentURI, version int32, edit protocol.TextEdit, preferred bool) protocol.CodeAction {
+	workspace := &protocol.WorkspaceEdit{Changes: map[protocol.DocumentURI][]protocol.TextEdit{uri: {edit}}}
+	if s.supportsDocumentChanges() {
+		workspace = versionedEdit(workspace, map[protocol.DocumentURI]int32{uri: version})
+	}
+	return protocol.CodeAction{
+		Title:       title,
+		Kind:        protocol.QuickFix,
+		Diagnostics: []protocol.Diagnostic{diagnostic},
+		IsPreferred: preferred,
+		Edit:        workspace,
+	}
+}
+
+// hidingModifierEdit adds modifier to the declaration of the member named at name, after its
+// access modifiers. A virtual member overriding instead has virtual replaced, since the two
+// don't combine
+func hidingModifierEdit(text string, name protocol.Position, modifier string) (protocol.TextEdit, bool) {
+	line := lineAt(text, name.Line)
+	declaration := line[:utf16ToByteOffset(line, name.Character)]
+	// Attributes on the same line come before the modifiers
+	start := len(declaration) - len(strings.TrimLeft(declaration, " \t"))
+	for strings.HasPrefix(declaration[start:], "[") {
+		end := strings.IndexByte(declaration[start:], ']')
+		if end < 0 {
+			return protocol.TextEdit{}, false
+		}
+		start += end + 1
+		start += len(declaration[start:]) - len(strings.TrimLeft(declaration[start:], " \t"))
+	}
+
+	at := start
+	for offset := start; offset < len(declaration); {
+		word := declaration[offset:]
+		word = word[:len(word)-len(strings.TrimLeft(word, "abcdefghijklmnopqrstuvwxyz"))]
+		if word == "" {
+			break
+		}
+		end := offset + len(word)
+		if word == modifier {
+			return protocol.TextEdit{}, false
+		}
+		if word == "virtual" && modifier == "override" {
+			return protocol.TextEdit{
+				Range:   protocol.Range{Start: linePosition(line, name.Line, offset), End: linePosition(line, name.Line, end)},
+				NewText: modifier,
+			}, true
+		}
+		if accessModifiers[word] {
+			at = end + len(declaration[end:]) - len(strings.TrimLeft(declaration[end:], " \t"))
+		}
+		offset = end + len(declaration[end:]) - len(strings.TrimLeft(declaration[end:], " \t"))
+	}
+
+	position := linePosition(line, name.Line, at)
+	return protocol.TextEdit{Range: protocol.Range{Start: position, End: position}, NewText: modifier + " "}, true
+}
+
+// linePosition is the position of the byte at offset in line, the text of line number lineNumber
+func linePosition(line string, lineNumber uint32, offset int) protocol.Position {
+	return protocol.Position{Line: lineNumber, Character: byteToUTF16Offset(line, offset)}
+}
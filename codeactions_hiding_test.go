@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestHidingModifierEdit(t *testing.T) {
+	tests := []struct {
+		name string
+		// line has | before the member's name
+		line     string
+		modifier string
+		// want is the line edited, empty when there is nothing to add
+		want string
+	}{
+		{"after the access modifier", "    public void |Update() { }", "override", "    public override void Update() { }"},
+		{"new", "    public void |Update() { }", "new", "    public new void Update() { }"},
+		{"no access modifier", "    void |Update() { }", "override", "    override void Update() { }"},
+		{"after every access modifier", "    protected internal void |Reset() { }", "new", "    protected internal new void Reset() { }"},
+		{"access modifier after another", "    static public int |Count;", "new", "    static public new int Count;"},
+		{"virtual replaced", "    protected virtual void |Awake() { }", "override", "    protected override void Awake() { }"},
+		{"virtual kept for new", "    protected virtual void |Awake() { }", "new", "    protected new virtual void Awake() { }"},
+		{"after attributes", "    [ContextMenu(\"Reset\")] [Obsolete] public void |Reset() { }", "new", "    [ContextMenu(\"Reset\")] [Obsolete] public new void Reset() { }"},
+		{"generic return type", "    public List<int> |Items() => null;", "new", "    public new List<int> Items() => null;"},
+		{"already there", "    public new void |Update() { }", "new", ""},
+		{"attribute not closed", "    [Obsolete(\"Use |Start", "new", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text, name := caretIn(test.line)
+			edit, ok := hidingModifierEdit(text, name, test.modifier)
+			if !ok {
+				if test.want != "" {
+					t.Errorf("hidingModifierEdit found no edit, want %q", test.want)
+				}
+				return
+			}
+			got := applyContentChange(text, TextDocumentContentChangeEvent{Range: &edit.Range, Text: edit.NewText})
+			if got != test.want {
+				t.Errorf("hidingModifierEdit makes %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestHidingMemberActions checks a member hiding an overridable one is offered override,
+// preferred, and new, and a member hiding any other only new
+func TestHidingMemberActions(t *testing.T) {
+	const text = "class Boss : Enemy\n{\n    public void Attack() { }\n}\n"
+	name := protocol.Range{Start: protocol.Position{Line: 2, Character: 16}, End: protocol.Position{Line: 2, Character: 22}}
+	tests := []struct {
+		name string
+		code string
+		// want are the lines each action declares the member on, the preferred one first
+		want []string
+	}{
+		{"overridable", "CS0114", []string{"    public override void Attack() { }", "    public new void Attack() { }"}},
+		{"not overridable", "CS0108", []string{"    public new void Attack() { }"}},
+		{"other diagnostic", "CS0103", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/getfixall": map[string]interface{}{"Items": []FixAllItem{}}})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Boss.cs")
+			openTestDocument(s, uri, text)
+			diagnostic := protocol.Diagnostic{Code: test.code, Range: name}
+
+			actions, err := s.handleCodeAction(context.Background(), &protocol.CodeActionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Range:        name,
+				Context:      protocol.CodeActionContext{Diagnostics: []protocol.Diagnostic{diagnostic}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, action := range actions {
+				if action.Kind != protocol.QuickFix || action.Edit == nil {
+					continue
+				}
+				if action.IsPreferred != (len(got) == 0) {
+					t.Errorf("%q preferred %v", action.Title, action.IsPreferred)
+				}
+				if len(action.Diagnostics) != 1 || action.Diagnostics[0].Code != test.code {
+					t.Errorf("%q fixes %+v, want the %s diagnostic", action.Title, action.Diagnostics, test.code)
+				}
+				edited := text
+				for _, edit := range action.Edit.Changes[uri] {
+					at := edit.Range
+					edited = applyContentChange(edited, TextDocumentContentChangeEvent{Range: &at, Text: edit.NewText})
+				}
+				got = append(got, lineAt(edited, 2))
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("actions declare %q, want %q", got, test.want)
+			}
+		})
+	}
+}
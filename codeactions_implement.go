@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// implementDiagnostics are the errors of a type missing members of an interface it declares,
+// or of an abstract base class
+var implementDiagnostics = map[string]bool{
+	"CS0534": true,
+	"CS0535": true,
+	"CS0737": true,
+	"CS0738": true,
+}
+
+// implementActions are the titles Roslyn's refactorings generating the missing members start with
+var implementActions = []string{
+	"Implement interface",
+	"Implement all members explicitly",
+	"Implement remaining members explicitly",
+	"Implement abstract class",
+}
+
+// OmniSharpCodeAction is a refactoring or fix offered by /v2/getcodeactions
+type OmniSharpCodeAction struct {
+	Identifier string `json:"Identifier"`
+	Name       string `json:"Name"`
+}
+
+// omnisharpSelection is a range in the format of the Selection of OmniSharp's v2 requests
+func omnisharpSelection(r protocol.Range) map[string]interface{} {
+	return map[string]interface{}{
+		"Start": map[string]interface{}{"Line": r.Start.Line, "Column": r.Start.Character},
+		"End":   map[string]interface{}{"Line": r.End.Line, "Column": r.End.Character},
+	}
+}
+
+// implementMemberActions offers Roslyn's refactorings generating the members a type is
+// missing, as quick fixes of the diagnostics reporting them, with the edit of each computed
+// up front. Stubs are indented as formatting configures OmniSharp and throw
+// NotImplementedException, or are auto-properties with omnisharp.implementAutoProperties
+func (s *Server) implementMemberActions(ctx context.Context, omnisharp *OmniSharpClient, params *protocol.CodeActionParams) []protocol.CodeAction {
+	uri := params.TextDocument.URI
+	doc, ok := s.documents.Get(uri)
+	if !ok {
+		return nil
+	}
+
+	var actions []protocol.CodeAction
+	seen := make(map[string]bool)
+	for _, diagnostic := range params.Context.Diagnostics {
+		if !implementDiagnostics[fmt.Sprint(diagnostic.Code)] {
+			continue
+		}
+		found, err := getCodeActions(ctx, omnisharp, uri, diagnostic.Range)
+		if err != nil {
+			log.Printf("failed to get the code actions of %s: %v", s.displayPath(uri), err)
+			return actions
+		}
+
+		for _, action := range found {
+			if !isImplementAction(action.Name) || seen[action.Name] {
+				continue
+			}
+			edit, err := runCodeAction(ctx, omnisharp, uri, diagnostic.Range, action.Identifier)
+			if err != nil {
+				log.Printf("failed to run %q in %s: %v", action.Name, s.displayPath(uri), err)
+				continue
+			}
+			if len(edit.Changes) == 0 {
+				continue
+			}
+			if s.supportsDocumentChanges() {
+				edit = versionedEdit(edit, map[protocol.DocumentURI]int32{uri: doc.Version})
+			}
+			seen[action.Name] = true
+			actions = append(actions, protocol.CodeAction{
+				Title:       action.Name,
+				Kind:        protocol.QuickFix,
+				Diagnostics: []protocol.Diagnostic{diagnostic},
+				IsPreferred: len(actions) == 0,
+				Edit:        edit,
+			})
+		}
+	}
+	return actions
+}
+
+func isImplementAction(name string) bool {
+	for _, prefix := range implementActions {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// getCodeActions lists the code actions OmniSharp offers for selection
+func getCodeActions(ctx context.Context, omnisharp *OmniSharpClient, uri protocol.DocumentURI, selection protocol.Range) ([]OmniSharpCodeAction, error) {
+	request := omnisharpPosition(uri, selection.Start)
+	request["Selection"] = omnisharpSelection(selection)
+	response, err := omnisharp.SendRequest(ctx, "/v2/getcodeactions", request)
+	if err != nil {
+		return nil, err
+	}
+
+	var omnisharpResponse struct {
+		CodeActions []OmniSharpCodeAction `json:"CodeActions"`
+	}
+	if err := json.Unmarshal(response, &omnisharpResponse); err != nil {
+		return nil, err
+	}
+	return omnisharpResponse.CodeActions, nil
+}
+
+// runCodeAction asks OmniSharp for the edits of a code action without applying them to its buffers
+func runCodeAction(ctx context.Context, omnisharp *OmniSharpClient, uri protocol.DocumentURI, selection protocol.Range, identifier string) (*protocol.WorkspaceEdit, error) {
+	request := omnisharpPosition(uri, selection.Start)
+	request["Selection"] = omnisharpSelection(selection)
+	request["Identifier"] = identifier
+	request["WantsTextChanges"] = true
+	request["ApplyTextChanges"] = false
+	request["WantsAllCodeActionOperations"] = false
+	response, err := omnisharp.SendRequest(ctx, "/v2/runcodeaction", request)
+	if err != nil {
+		return nil, err
+	}
+
+	var omnisharpResponse struct {
+		Changes []ModifiedFileResponse `json:"Changes"`
+	}
+	if err := json.Unmarshal(response, &omnisharpResponse); err != nil {
+		return nil, err
+	}
+	return workspaceEdit(omnisharpResponse.Changes), nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+// TestImplementMemberActions checks the diagnostics of missing interface and abstract members
+// get Roslyn's implementing refactorings as quick fixes, each editing in the stubs OmniSharp
+// generated, and that other diagnostics and refactorings are left out
+func TestImplementMemberActions(t *testing.T) {
+	const text = "class Player : MonoBehaviour, IDamageable\n{\n}\n"
+	const stubs = "    public int Health { get; set; }\n\n" +
+		"    public void TakeDamage(int amount)\n    {\n        throw new System.NotImplementedException();\n    }\n"
+	missing := protocol.Diagnostic{
+		Code:  "CS0535",
+		Range: protocol.Range{Start: protocol.Position{Character: 30}, End: protocol.Position{Character: 41}},
+	}
+	tests := []struct {
+		name            string
+		diagnostic      protocol.Diagnostic
+		documentChanges bool
+		wantTitles      []string
+	}{
+		{"missing interface members", missing, false, []string{"Implement interface", "Implement all members explicitly"}},
+		{"versioned", missing, true, []string{"Implement interface", "Implement all members explicitly"}},
+		{"missing abstract members", protocol.Diagnostic{Code: "CS0534", Range: missing.Range}, false, []string{"Implement interface", "Implement all members explicitly"}},
+		{"other diagnostic", protocol.Diagnostic{Code: "CS0103", Range: missing.Range}, false, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{
+				"/getfixall": map[string]interface{}{"Items": []FixAllItem{}},
+				"/v2/getcodeactions": map[string]interface{}{"CodeActions": []OmniSharpCodeAction{
+					{Identifier: "Implement interface", Name: "Implement interface"},
+					{Identifier: "Implement all members explicitly", Name: "Implement all members explicitly"},
+					{Identifier: "Generate constructor", Name: "Generate constructor 'Player()'"},
+				}},
+			})
+			s, _ := newTestServer(t, fake)
+			if test.documentChanges {
+				s.capabilities.Workspace = &protocol.WorkspaceClientCapabilities{
+					WorkspaceEdit: &protocol.WorkspaceClientCapabilitiesWorkspaceEdit{DocumentChanges: true},
+				}
+			}
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, text)
+			fake.setResponse("/v2/runcodeaction", map[string]interface{}{"Changes": []ModifiedFileResponse{
+				{FileName: uri.Filename(), Changes: []LinePositionSpanTextChange{{NewText: stubs, StartLine: 2, EndLine: 2}}},
+			}})
+
+			actions, err := s.handleCodeAction(context.Background(), &protocol.CodeActionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Range:        test.diagnostic.Range,
+				Context:      protocol.CodeActionContext{Diagnostics: []protocol.Diagnostic{test.diagnostic}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var titles []string
+			for _, action := range actions {
+				if action.Kind != protocol.QuickFix || action.Edit == nil {
+					continue
+				}
+				titles = append(titles, action.Title)
+				if action.IsPreferred != (len(titles) == 1) {
+					t.Errorf("%q preferred %v", action.Title, action.IsPreferred)
+				}
+
+				var edits []protocol.TextEdit
+				if test.documentChanges {
+					if len(action.Edit.DocumentChanges) != 1 || action.Edit.DocumentChanges[0].TextDocument.Version == nil || *action.Edit.DocumentChanges[0].TextDocument.Version != 1 {
+						t.Fatalf("%q edits %+v, want the document at version 1", action.Title, action.Edit)
+					}
+					edits = action.Edit.DocumentChanges[0].Edits
+				} else {
+					edits = action.Edit.Changes[uri]
+				}
+				got := text
+				for _, edit := range edits {
+					at := edit.Range
+					got = applyContentChange(got, TextDocumentContentChangeEvent{Range: &at, Text: edit.NewText})
+				}
+				if want := "class Player : MonoBehaviour, IDamageable\n{\n" + stubs + "}\n"; got != want {
+					t.Errorf("%q makes %q, want %q", action.Title, got, want)
+				}
+			}
+			if !reflect.DeepEqual(titles, test.wantTitles) {
+				t.Errorf("implement actions %q, want %q", titles, test.wantTitles)
+			}
+
+			// Each action is run without OmniSharp applying it to its buffer
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			var ran []string
+			for _, body := range fake.bodies["/v2/runcodeaction"] {
+				var request struct {
+					Identifier       string
+					ApplyTextChanges bool
+				}
+				if err := json.Unmarshal(body, &request); err != nil || request.ApplyTextChanges {
+					t.Errorf("ran %s", body)
+				}
+				ran = append(ran, request.Identifier)
+			}
+			if !reflect.DeepEqual(ran, test.wantTitles) {
+				t.Errorf("ran %q, want %q", ran, test.wantTitles)
+			}
+		})
+	}
+}
+
+func TestImplementPropertyArgs(t *testing.T) {
+	tests := []struct {
+		name           string
+		autoProperties bool
+		want           string
+	}{
+		{"throwing properties", false, "ImplementTypeOptions:PropertyGenerationBehavior=PreferThrowingProperties"},
+		{"auto-properties", true, "ImplementTypeOptions:PropertyGenerationBehavior=PreferAutoProperties"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig().OmniSharp
+			config.ImplementAutoProperties = test.autoProperties
+			args := config.omnisharpArgs()
+			found := false
+			for _, arg := range args {
+				found = found || arg == test.want
+			}
+			if !found {
+				t.Errorf("omnisharpArgs = %q, want %q among them", args, test.want)
+			}
+		})
+	}
+}
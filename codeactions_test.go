@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+var unusedUsings = FixAllItem{Id: "IDE0005", Message: "Remove unnecessary usings"}
+
+func TestFixAllCodeActions(t *testing.T) {
+	tests := []struct {
+		name        string
+		diagnostics []protocol.Diagnostic
+		wantTitles  []string
+	}{
+		{
+			name:        "a fixable diagnostic",
+			diagnostics: []protocol.Diagnostic{{Code: "IDE0005", Message: "Using directive is unnecessary."}},
+			wantTitles: []string{
+				"Fix all: Remove unnecessary usings (Document)",
+				"Fix all: Remove unnecessary usings (Project)",
+				"Fix all: Remove unnecessary usings (Solution)",
+			},
+		},
+		{
+			name:        "a fixable diagnostic known by its data",
+			diagnostics: []protocol.Diagnostic{{Code: "CS8019", Data: diagnosticData{Id: "IDE0005", FileName: "/project/Assets/Player.cs"}}},
+			wantTitles: []string{
+				"Fix all: Remove unnecessary usings (Document)",
+				"Fix all: Remove unnecessary usings (Project)",
+				"Fix all: Remove unnecessary usings (Solution)",
+			},
+		},
+		{name: "no fix-all provider", diagnostics: []protocol.Diagnostic{{Code: "CS0103"}}},
+		{name: "no diagnostics"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{
+				"/getfixall": map[string]interface{}{"Items": []FixAllItem{unusedUsings}},
+			})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, "using System;\nclass Player { }\n")
+
+			actions, err := s.handleCodeAction(context.Background(), &protocol.CodeActionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Context:      protocol.CodeActionContext{Diagnostics: test.diagnostics},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var titles []string
+			for _, action := range actions {
+				if !strings.HasPrefix(action.Title, "Fix all:") {
+					continue
+				}
+				titles = append(titles, action.Title)
+				if action.Command == nil || action.Command.Command != commandFixAll || len(action.Diagnostics) != len(test.diagnostics) {
+					t.Errorf("%q runs %+v for %v", action.Title, action.Command, action.Diagnostics)
+				}
+			}
+			if !reflect.DeepEqual(titles, test.wantTitles) {
+				t.Errorf("fix-all actions %q, want %q", titles, test.wantTitles)
+			}
+		})
+	}
+}
+
+func TestRunFixAll(t *testing.T) {
+	fixed := map[string]interface{}{"Changes": []ModifiedFileResponse{
+		{FileName: "/project/Assets/Player.cs", Changes: []LinePositionSpanTextChange{
+			{StartLine: 0, StartColumn: 0, EndLine: 1, EndColumn: 0},
+			{StartLine: 1, StartColumn: 0, EndLine: 2, EndColumn: 0},
+		}},
+		{FileName: "/project/Assets/Enemy.cs", Changes: []LinePositionSpanTextChange{
+			{StartLine: 2, StartColumn: 0, EndLine: 3, EndColumn: 0},
+		}},
+	}}
+	tests := []struct {
+		name  string
+		scope fixAllScope
+		// choice is the user's answer to the confirmation, if asked
+		choice      interface{}
+		wantConfirm bool
+		wantApplied bool
+	}{
+		{name: "document", scope: fixAllDocument, wantApplied: true},
+		{name: "project", scope: fixAllProject, wantApplied: true},
+		{name: "solution confirmed", scope: fixAllSolution, choice: protocol.MessageActionItem{Title: "Apply"}, wantConfirm: true, wantApplied: true},
+		{name: "solution cancelled", scope: fixAllSolution, choice: protocol.MessageActionItem{Title: "Cancel"}, wantConfirm: true},
+		{name: "solution dismissed", scope: fixAllSolution, wantConfirm: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/runfixall": fixed})
+			s, client := newTestServer(t, fake)
+			client.answer(protocol.MethodWindowShowMessageRequest, test.choice)
+			client.answer(protocol.MethodWorkspaceApplyEdit, protocol.ApplyWorkspaceEditResponse{Applied: true})
+
+			_, err := s.handleExecuteCommand(context.Background(), &protocol.ExecuteCommandParams{
+				Command: commandFixAll,
+				Arguments: []interface{}{fixAllArguments{
+					FileName: "/project/Assets/Player.cs",
+					Scope:    test.scope,
+					Id:       unusedUsings.Id,
+					Message:  unusedUsings.Message,
+				}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if asked := len(client.received(protocol.MethodWindowShowMessageRequest)) > 0; asked != test.wantConfirm {
+				t.Errorf("asked to confirm: %v, want %v", asked, test.wantConfirm)
+			}
+			edits := client.received(protocol.MethodWorkspaceApplyEdit)
+			if !test.wantApplied {
+				if len(edits) != 0 || fake.callCount("/runfixall") != 0 {
+					t.Errorf("ran the fix-all unconfirmed")
+				}
+				return
+			}
+			if len(edits) != 1 {
+				t.Fatalf("applied %d edits, want 1", len(edits))
+			}
+			var applied protocol.ApplyWorkspaceEditParams
+			if err := json.Unmarshal(edits[0], &applied); err != nil {
+				t.Fatal(err)
+			}
+			if applied.Label != unusedUsings.Message || len(applied.Edit.Changes["file:///project/Assets/Player.cs"]) != 2 || len(applied.Edit.Changes["file:///project/Assets/Enemy.cs"]) != 1 {
+				t.Errorf("applied %+v", applied)
+			}
+
+			var request struct {
+				Scope        fixAllScope
+				FixAllFilter []FixAllItem
+				ApplyChanges bool
+			}
+			if err := json.Unmarshal(fake.bodies["/runfixall"][0], &request); err != nil {
+				t.Fatal(err)
+			}
+			if request.Scope != test.scope || !reflect.DeepEqual(request.FixAllFilter, []FixAllItem{unusedUsings}) || request.ApplyChanges {
+				t.Errorf("asked OmniSharp for %+v", request)
+			}
+		})
+	}
+}
@@ -0,0 +1,823 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"go.lsp.dev/protocol"
+)
+
+// CompletionList mirrors protocol.CompletionList with the LSP 3.17 itemDefaults field,
+// which go.lsp.dev/protocol v0.12.0 predates
+type CompletionList struct {
+	IsIncomplete bool                    `json:"isIncomplete"`
+	ItemDefaults *CompletionItemDefaults `json:"itemDefaults,omitempty"`
+	Items        []CompletionItem        `json:"items"`
+}
+
+// CompletionItemDefaults holds values shared by every item in a CompletionList
+type CompletionItemDefaults struct {
+	EditRange        interface{} `json:"editRange,omitempty"` // *protocol.Range | *InsertReplaceRange
+	CommitCharacters []string    `json:"commitCharacters,omitempty"`
+}
+
+// InsertReplaceRange is the editRange form used when the client supports insert/replace edits
+type InsertReplaceRange struct {
+	Insert  protocol.Range `json:"insert"`
+	Replace protocol.Range `json:"replace"`
+}
+
+// CompletionItem wraps protocol.CompletionItem so an item can carry an InsertReplaceEdit
+// and the 3.17 textEditText used together with the list's default editRange
+type CompletionItem struct {
+	protocol.CompletionItem
+	TextEdit     interface{} `json:"textEdit,omitempty"` // *protocol.TextEdit | *protocol.InsertReplaceEdit
+	TextEditText string      `json:"textEditText,omitempty"`
+
+	// returnType is what a member returns, as OmniSharp reported it; not sent to the client
+	returnType string
+	// requiredImport is the namespace a type not in scope yet is imported from
+	requiredImport string
+	// methodHeader is the signature of a method item, such as "GetComponent<T>()"
+	methodHeader string
+}
+
+func (s *Server) handleCompletion(ctx context.Context, params *protocol.CompletionParams) (*CompletionList, error) {
+	items := []CompletionItem{}
+	if !s.shouldTriggerCompletion(params) {
+		return &CompletionList{Items: items}, nil
+	}
+	// OmniSharp has nothing to offer in the format clause of an interpolation hole, nor in code
+	// the preprocessor leaves out. Documentation comments get their tags, and the strings naming
+	// tags, layers, inputs, scenes and resources those of the project
+	if doc, ok := s.documents.Get(params.TextDocument.URI); ok {
+		if s.inDisabledCode(doc, params.Position) {
+			return &CompletionList{Items: items}, nil
+		}
+		if clause, ok := inFormatClause(doc.Text, offsetAt(doc.Text, params.Position)); ok {
+			items = formatSpecifierCompletions(doc.Text, clause, params.Position)
+			sortCompletionItems(items, nil, s.currentConfig().Completion.kindRanks())
+			return &CompletionList{Items: items}, nil
+		}
+		if items, ok := tagLayerCompletions(doc, params.Position, s.rootPath); ok {
+			sortCompletionItems(items, nil, s.currentConfig().Completion.kindRanks())
+			return &CompletionList{Items: items}, nil
+		}
+		if items, ok := inputNameCompletions(doc, params.Position, s.rootPath); ok {
+			sortCompletionItems(items, nil, s.currentConfig().Completion.kindRanks())
+			return &CompletionList{Items: items}, nil
+		}
+		if items, ok := sceneNameCompletions(doc, params.Position, s.rootPath); ok {
+			sortCompletionItems(items, nil, s.currentConfig().Completion.kindRanks())
+			return &CompletionList{Items: items}, nil
+		}
+		if items, ok := s.resourcePathCompletions(doc, params.Position); ok {
+			sortCompletionItems(items, nil, s.currentConfig().Completion.kindRanks())
+			return &CompletionList{Items: items}, nil
+		}
+		if line := lineAt(doc.Text, params.Position.Line); inDocComment(line[:utf16ToByteOffset(line, params.Position.Character)]) {
+			items = s.plainTextCompletions(s.docCommentCompletions(ctx, doc, params.Position))
+			sortCompletionItems(items, nil, s.currentConfig().Completion.kindRanks())
+			return &CompletionList{Items: items}, nil
+		}
+	}
+
+	// Typing a.b.c. quickly triggers at every dot, so wait for the caret to settle. A newer
+	// completion cancels ctx, see completionQuery
+	if debounce := time.Duration(s.currentConfig().Completion.Debounce); debounce > 0 && params.Context != nil && params.Context.TriggerKind == protocol.CompletionTriggerKindTriggerCharacter {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(debounce):
+		}
+	}
+
+	reused, isIncomplete, fallback := false, false, false
+	if omnisharp := s.completionBackend(); omnisharp != nil {
+		omnisharpItems, ok, truncated, err := s.sessionCompletions(ctx, omnisharp, params)
+		if err != nil {
+			return nil, err
+		}
+		// A truncated list is requeried as the user types, each time narrowed further
+		items, reused, isIncomplete = omnisharpItems, ok, truncated
+		fallback = len(items) == 0
+	}
+
+	if doc, ok := s.documents.Get(params.TextDocument.URI); ok {
+		offset := offsetAt(doc.Text, params.Position)
+		// OmniSharp may have nothing to offer mid-edit, while the code around the caret is broken
+		if fallback && tokenClassAt(doc.Text, offset-len(identifierBefore(doc.Text[:offset]))) == tokenCode && likelyBroken(doc.Text, offset) {
+			items = fallbackCompletions(doc.Text, offset)
+		}
+		constraint := constraintContextAt(doc.Text, offset)
+		items = filterConstraintCompletions(items, constraint)
+		items = appendLocalCompletions(items, constraintCompletions(constraint))
+		items = appendLocalCompletions(items, unityAttributeCompletions(doc, params.Position, s.rootPath))
+		items = appendLocalCompletions(items, s.partialMethodCompletions(ctx, doc, params.Position))
+		items = appendLocalCompletions(items, s.namespaceCompletions(doc, params.Position))
+		items = appendLocalCompletions(items, s.nullCoalescingCompletions(ctx, doc, params.Position))
+		if !afterMemberAccess(doc.Text, offset) {
+			items = rankEnclosingTypeCompletions(items, s.enclosingTypes(ctx, s.completionBackend(), doc, params.Position))
+		}
+		items = rankExpectedTypeCompletions(items, expectedTypeAt(doc.Text, offset))
+		subscription, _ := s.eventSubscriptionAt(ctx, doc, params.Position)
+		items = rankHandlerCompletions(items, subscription)
+		items = appendLocalCompletions(items, s.eventHandlerCompletions(doc, params.Position, subscription))
+		items = filterPatternCompletions(items, patternContextAt(doc.Text, offset))
+		if method, ok := typeArgumentListAt(doc.Text, offset); ok {
+			items = filterTypeArgumentCompletions(items, method)
+		}
+		items = filterReceiverCompletions(items, receiverContextAt(doc.Text, offset), enclosingTypeName(doc.Text, offset))
+		if s.currentConfig().Completion.Scope == completionScopeProjectOnly {
+			items = filterFrameworkCompletions(items, afterMemberAccess(doc.Text, offset))
+		}
+		if !afterMemberAccess(doc.Text, offset) {
+			qualifyAmbiguousTypes(items)
+		}
+		nameof := inNameof(doc.Text, offset)
+		items = filterNameofCompletions(items, nameof)
+		if s.currentConfig().Completion.TypeArgumentSnippets && s.supportsSnippets() && !nameof {
+			items = typeArgumentSnippets(items, doc.Text, offset)
+		}
+		await := afterAwait(doc.Text, offset)
+		items = rankAwaitableCompletions(items, await)
+		line := lineAt(doc.Text, params.Position.Line)
+		if s.currentConfig().Completion.InsertAwait && !await && !nameof && inAsyncBody(doc.Text, offset) {
+			items = insertAwait(items, line, params.Position)
+		}
+		// An incomplete list is requeried as the user types, so the client must see it all, but a
+		// list reused for such a requery is narrowed to what has been typed since
+		if !isIncomplete || reused {
+			items = filterCompletionItems(items, identifierBefore(line[:utf16ToByteOffset(line, params.Position.Character)]))
+		}
+		s.indentMultilineItems(items, line)
+	}
+	if s.currentConfig().Completion.CollapseOverloads {
+		items = collapseOverloads(items)
+	}
+	items = s.plainTextCompletions(items)
+	var recent map[string]float64
+	if s.currentConfig().Completion.RecentlyUsed {
+		recent = s.recentCompletions.scores()
+	}
+	sortCompletionItems(items, recent, s.currentConfig().Completion.kindRanks())
+	if doc, ok := s.documents.Get(params.TextDocument.URI); ok && s.currentConfig().Completion.RecentlyUsed {
+		start, _ := wordRanges(doc.Text, params.Position)
+		s.recentCompletions.offer(params.TextDocument.URI, start.Start, items)
+	}
+
+	defaults := s.completionItemDefaults(params)
+	if doc, ok := s.documents.Get(params.TextDocument.URI); ok && defaults == nil {
+		pinMemberEditRanges(items, doc, params.Position)
+	}
+	compressCompletionData(items, s.completionOrigins)
+	return &CompletionList{
+		IsIncomplete: isIncomplete,
+		ItemDefaults: s.factorItemDefaults(items, defaults),
+		Items:        items,
+	}, nil
+}
+
+// AutoCompleteResponse is one item of OmniSharp's /autocomplete response
+type AutoCompleteResponse struct {
+	CompletionText string `json:"CompletionText"`
+	DisplayText    string `json:"DisplayText"`
+	Documentation  string `json:"Documentation"`
+	Kind           string `json:"Kind"`
+	// Description is Roslyn's display of the symbol, starting with its accessibility and modifiers
+	Description string `json:"Description"`
+	ReturnType  string `json:"ReturnType"`
+	// MethodHeader is the signature of method items, such as "Translate(Vector3 translation)"
+	MethodHeader string `json:"MethodHeader"`
+	// Preselect marks the item Roslyn recommends, such as the expected type after new
+	Preselect bool `json:"Preselect"`
+	// RequiredNamespaceImport is the namespace to import for types that aren't in scope yet
+	RequiredNamespaceImport string `json:"RequiredNamespaceImport"`
+}
+
+// completionSession remembers the OmniSharp items of the last completion, so a client
+// requerying an incomplete list as the user extends the identifier is answered without asking
+// OmniSharp again. Any other edit, to the document or OmniSharp's copy of it, ends the session
+type completionSession struct {
+	mu  sync.Mutex
+	uri protocol.DocumentURI
+	// generation is the buffer generation the items were computed at
+	generation uint64
+	// before and after are the document text around the identifier being completed
+	before, after string
+	// prefix is the part of the identifier typed when OmniSharp was asked
+	prefix    string
+	items     []CompletionItem
+	truncated bool
+}
+
+func (c *completionSession) store(uri protocol.DocumentURI, generation uint64, text string, offset int, items []CompletionItem, truncated bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prefix = identifierBefore(text[:offset])
+	c.uri, c.generation, c.truncated = documentKey(uri), generation, truncated
+	c.before, c.after = text[:offset-len(c.prefix)], text[offset:]
+	c.items = append([]CompletionItem(nil), items...)
+}
+
+func (c *completionSession) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.uri, c.before, c.after, c.items = "", "", "", nil
+}
+
+// advance follows OmniSharp's copy of uri moving on to generation with text. Extending the
+// identifier keeps the session, while any other change ends it
+func (c *completionSession) advance(uri protocol.DocumentURI, generation uint64, text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.items == nil || c.uri != documentKey(uri) {
+		return
+	}
+	if c.extends(text, len(text)-len(c.after)) {
+		c.generation = generation
+		return
+	}
+	c.uri, c.before, c.after, c.items = "", "", "", nil
+}
+
+// extends reports whether text, with the caret at offset, differs from the session's only by
+// more of the identifier typed. The caller must hold c.mu
+func (c *completionSession) extends(text string, offset int) bool {
+	if offset < len(c.before) || offset > len(text) || text[offset:] != c.after {
+		return false
+	}
+	typed := identifierBefore(text[:offset])
+	return strings.HasPrefix(typed, c.prefix) && text[:offset-len(typed)] == c.before
+}
+
+// lookup returns a copy of the remembered items, and whether they were truncated, if OmniSharp
+// has seen no edit since but the identifier being extended
+func (c *completionSession) lookup(uri protocol.DocumentURI, generation uint64, text string, offset int) ([]CompletionItem, bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.items == nil || c.uri != documentKey(uri) || c.generation != generation || !c.extends(text, offset) {
+		return nil, false, false
+	}
+	return append([]CompletionItem(nil), c.items...), c.truncated, true
+}
+
+// sessionCompletions answers a requery of an incomplete list from the items of the request
+// that started it, narrowed by the caller to what has been typed since, reporting whether it
+// did. Other requests go to OmniSharp and start a new session. A truncated session only holds
+// the first items OmniSharp offered, so what it serves stays incomplete
+func (s *Server) sessionCompletions(ctx context.Context, omnisharp *OmniSharpClient, params *protocol.CompletionParams) (items []CompletionItem, reused, truncated bool, err error) {
+	uri := params.TextDocument.URI
+	doc, ok := s.documents.Get(uri)
+	if !ok {
+		items, truncated, err := s.cachedOmniSharpCompletions(ctx, omnisharp, params)
+		if errors.Is(err, errCompletionDeadline) {
+			return items, false, true, nil
+		}
+		return items, false, truncated, err
+	}
+
+	offset := offsetAt(doc.Text, params.Position)
+	if params.Context != nil && params.Context.TriggerKind == protocol.CompletionTriggerKindTriggerForIncompleteCompletions {
+		if items, truncated, ok := s.completionSession.lookup(uri, s.cache.generation(uri), doc.Text, offset); ok {
+			markCacheHit(ctx)
+			return items, true, truncated, nil
+		}
+	}
+
+	generation := s.cache.generation(uri)
+	items, truncated, err = s.cachedOmniSharpCompletions(ctx, omnisharp, params)
+	if errors.Is(err, errCompletionDeadline) {
+		// Partial items would hide the rest from every requery
+		return items, false, true, nil
+	}
+	if err != nil {
+		return nil, false, false, err
+	}
+	items = narrowTriggeredCompletions(params, items)
+	items = appendNamedArguments(items, s.namedArgumentCompletions(ctx, omnisharp, doc, params.Position))
+	s.completionSession.store(uri, generation, doc.Text, offset, items, truncated)
+	return items, false, truncated, nil
+}
+
+// cachedCompletions is a cached OmniSharp completion result
+type cachedCompletions struct {
+	items     []CompletionItem
+	truncated bool
+}
+
+// cachedOmniSharpCompletions serves repeated requests at the same position of an unchanged
+// buffer from the cache. Callers get their own copy of the items to sort and filter. Items cut
+// short by completion.timeout come with errCompletionDeadline
+func (s *Server) cachedOmniSharpCompletions(ctx context.Context, omnisharp *OmniSharpClient, params *protocol.CompletionParams) ([]CompletionItem, bool, error) {
+	uri := params.TextDocument.URI
+	if cached, ok := s.cache.get("completion", uri, params.Position); ok {
+		markCacheHit(ctx)
+		result := cached.(cachedCompletions)
+		return append([]CompletionItem(nil), result.items...), result.truncated, nil
+	}
+
+	generation := s.cache.generation(uri)
+	items, offered, truncated, err := s.omnisharpCompletions(ctx, omnisharp, params)
+	if errors.Is(err, errCompletionDeadline) {
+		// Partial items are incomplete, so they're neither cached nor requeried on their own
+		return items, true, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	// No members at all after a receiver's dot usually means OmniSharp hadn't seen the buffer
+	// yet, as on the first completion after opening a file, so sync it and ask once more. Members
+	// none of which match what is typed are an answer
+	if doc, ok := s.documents.Get(uri); ok && offered == 0 && isMemberAccess(doc.Text, offsetAt(doc.Text, params.Position)) {
+		s.bufferSync.Lock()
+		s.syncBuffer(ctx, uri)
+		s.bufferSync.Unlock()
+		generation = s.cache.generation(uri)
+		if items, _, truncated, err = s.omnisharpCompletions(ctx, omnisharp, params); errors.Is(err, errCompletionDeadline) {
+			return items, true, err
+		} else if err != nil {
+			return nil, false, err
+		}
+	}
+	s.cache.put("completion", uri, generation, params.Position, cachedCompletions{
+		items:     append([]CompletionItem(nil), items...),
+		truncated: truncated,
+	})
+	return items, truncated, nil
+}
+
+// isMemberAccess reports whether offset follows the dot of a member access on an expression,
+// such as transform. or GetComponent<Rigidbody>()., possibly with part of the member typed.
+// Numeric literals such as 1. are not receivers
+func isMemberAccess(text string, offset int) bool {
+	before := text[:offset]
+	before = strings.TrimSuffix(before, identifierBefore(before))
+	if !strings.HasSuffix(before, ".") {
+		return false
+	}
+	before = strings.TrimSuffix(before, ".")
+	if strings.HasSuffix(before, ")") || strings.HasSuffix(before, "]") || strings.HasSuffix(before, ">") {
+		return true
+	}
+	receiver := identifierBefore(before)
+	return receiver != "" && !unicode.IsDigit(rune(receiver[0]))
+}
+
+// errCompletionDeadline is returned along with the items decoded before completion.timeout
+var errCompletionDeadline = errors.New("completion timed out")
+
+// omnisharpCompletions asks OmniSharp for completions, keeping at most completion.maxItems of
+// them and reporting whether there were more. The response is decoded an item at a time, so
+// huge lists, such as the members of a namespace like UnityEngine, are never held whole, and
+// items not matching what has been typed don't count toward the cap. Past completion.timeout
+// it gives up with errCompletionDeadline and the items decoded so far. offered counts the items
+// OmniSharp returned, matching or not
+func (s *Server) omnisharpCompletions(ctx context.Context, omnisharp *OmniSharpClient, params *protocol.CompletionParams) (items []CompletionItem, offered int, truncated bool, err error) {
+	queryCtx := ctx
+	timeout := time.Duration(s.currentConfig().Completion.Timeout)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		queryCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Convert LSP completion params to OmniSharp format
+	omnisharpRequest := map[string]interface{}{
+		"Line":     params.Position.Line,
+		"Column":   params.Position.Character,
+		"FileName": params.TextDocument.URI.Filename(),
+		// Needed to tell methods that take parameters from those that don't
+		"WantMethodHeader": true,
+		"WantReturnType":   true,
+		// Needed to narrow completions in patterns
+		"WantKind": true,
+	}
+	doc, tracked := s.documents.Get(params.TextDocument.URI)
+	if tracked {
+		offset := offsetAt(doc.Text, params.Position)
+		_, typeArgument := typeArgumentListAt(doc.Text, offset)
+		if typeArgument || patternContextAt(doc.Text, offset) == patternType {
+			// A type pattern or argument may name a type whose namespace isn't imported yet
+			omnisharpRequest["WantImportableTypes"] = true
+		}
+	}
+
+	prefix := ""
+	if tracked {
+		line := lineAt(doc.Text, params.Position.Line)
+		prefix = identifierBefore(line[:utf16ToByteOffset(line, params.Position.Character)])
+	}
+
+	items = []CompletionItem{}
+	maxItems := s.currentConfig().Completion.MaxItems
+	err = omnisharp.SendRequestStream(queryCtx, "/autocomplete", omnisharpRequest, func(r io.Reader) error {
+		decoder := json.NewDecoder(r)
+		if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+			return err
+		}
+		for decoder.More() {
+			if maxItems > 0 && len(items) == maxItems {
+				truncated = true
+				return nil
+			}
+			var item AutoCompleteResponse
+			if err := decoder.Decode(&item); err != nil {
+				return err
+			}
+			offered++
+			if converted := s.omnisharpCompletionItem(params, doc, tracked, item); matchesPrefix(converted, prefix) {
+				items = append(items, converted)
+			}
+		}
+		return nil
+	})
+	if err != nil && ctx.Err() == nil && queryCtx.Err() == context.DeadlineExceeded {
+		// A popup with some items, or none and marked incomplete, beats one that never opens
+		log.Printf("completion timed out after %v, returning the %d items decoded so far", timeout, len(items))
+		err = errCompletionDeadline
+	} else if err != nil {
+		return nil, 0, false, err
+	}
+
+	// Editors highlight a single item, so only honor the first recommendation
+	preselected := false
+	for i := range items {
+		if items[i].Preselect && preselected {
+			items[i].Preselect = false
+		}
+		preselected = preselected || items[i].Preselect
+	}
+	return items, offered, truncated, err
+}
+
+// omnisharpCompletionItem converts an /autocomplete item. doc is the document completed in,
+// if tracked
+func (s *Server) omnisharpCompletionItem(params *protocol.CompletionParams, doc Document, tracked bool, item AutoCompleteResponse) CompletionItem {
+	converted := CompletionItem{
+		CompletionItem: protocol.CompletionItem{
+			Label:      item.DisplayText,
+			Detail:     completionDetail(item),
+			Kind:       convertKind(item.Kind),
+			InsertText: item.CompletionText,
+			Preselect:  item.Preselect,
+			Data: &completionData{
+				Source:         completionSourceOmniSharp,
+				FileName:       params.TextDocument.URI.Filename(),
+				Line:           params.Position.Line,
+				Column:         params.Position.Character,
+				CompletionText: item.CompletionText,
+				DisplayText:    item.DisplayText,
+				Symbol:         item.Description,
+			},
+		},
+		TextEditText:   item.CompletionText,
+		returnType:     item.ReturnType,
+		requiredImport: item.RequiredNamespaceImport,
+		methodHeader:   item.MethodHeader,
+	}
+	if item.RequiredNamespaceImport != "" && tracked {
+		if edit, ok := usingEdit(doc.Text, item.RequiredNamespaceImport, s.currentConfig().Usings); ok {
+			converted.AdditionalTextEdits = []protocol.TextEdit{edit}
+		}
+	}
+	if s.supportsCommitCharacters() {
+		converted.CommitCharacters = s.currentConfig().Completion.CommitCharacters
+	}
+	if s.currentConfig().Completion.SignatureHelpOnAccept && hasParameters(item) {
+		converted.Command = triggerParameterHints
+	}
+	return converted
+}
+
+// memberModifiers are the accessibility and modifier keywords shown in completion details
+var memberModifiers = map[string]bool{
+	"public": true, "protected": true, "internal": true, "private": true,
+	"static": true, "readonly": true, "const": true, "override": true, "abstract": true,
+	"virtual": true, "sealed": true, "extern": true, "async": true,
+}
+
+// completionDetail formats the item's modifiers, type and name, such as "public static Vector3
+// zero", so static and instance members can be told apart, and marks extension methods.
+// Items OmniSharp describes no further keep their documentation as detail
+func completionDetail(item AutoCompleteResponse) string {
+	// Roslyn starts extension method descriptions with "(extension)"
+	description, extension := strings.CutPrefix(item.Description, "(extension)")
+	extension = extension || item.Kind == "ExtensionMethod"
+
+	var parts []string
+	if extension {
+		parts = append(parts, "(extension)")
+	}
+	for _, word := range strings.Fields(description) {
+		if !memberModifiers[word] {
+			break
+		}
+		parts = append(parts, word)
+	}
+	if !extension && len(parts) == 0 && item.ReturnType == "" {
+		return item.Documentation
+	}
+
+	if item.ReturnType != "" {
+		parts = append(parts, item.ReturnType)
+	}
+	if item.MethodHeader != "" {
+		parts = append(parts, item.MethodHeader)
+	} else {
+		parts = append(parts, item.DisplayText)
+	}
+	return strings.Join(parts, " ")
+}
+
+// triggerParameterHints opens signature help once a method item has been inserted
+var triggerParameterHints = &protocol.Command{
+	Title:   "Trigger parameter hints",
+	Command: "editor.action.triggerParameterHints",
+}
+
+// hasParameters reports whether item is a method or constructor whose signature lists any parameters
+func hasParameters(item AutoCompleteResponse) bool {
+	if kind := convertKind(item.Kind); kind != protocol.CompletionItemKindMethod && kind != protocol.CompletionItemKindConstructor {
+		return false
+	}
+	start, end := strings.Index(item.MethodHeader, "("), strings.LastIndex(item.MethodHeader, ")")
+	return start >= 0 && end > start && strings.TrimSpace(item.MethodHeader[start+1:end]) != ""
+}
+
+// filterCompletionItems keeps the items matching the identifier prefix already typed, as the
+// client would, so large unfiltered OmniSharp results aren't sent only to be discarded
+func filterCompletionItems(items []CompletionItem, prefix string) []CompletionItem {
+	if prefix == "" {
+		return items
+	}
+
+	filtered := items[:0]
+	for _, item := range items {
+		if matchesPrefix(item, prefix) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
+func matchesPrefix(item CompletionItem, prefix string) bool {
+	text := item.FilterText
+	if text == "" {
+		text = item.Label
+	}
+	return isSubsequence(strings.ToLower(prefix), strings.ToLower(text))
+}
+
+// isSubsequence reports whether the runes of sub appear in s in order, e.g. "gcp" in
+// "getcomponent"
+func isSubsequence(sub, s string) bool {
+	for _, r := range sub {
+		i := strings.IndexRune(s, r)
+		if i < 0 {
+			return false
+		}
+		s = s[i+utf8.RuneLen(r):]
+	}
+	return true
+}
+
+// appendLocalCompletions adds items we synthesize ourselves, skipping any OmniSharp already offered
+func appendLocalCompletions(items, local []CompletionItem) []CompletionItem {
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		seen[item.InsertText] = true
+	}
+	for _, item := range local {
+		if !seen[item.InsertText] {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// completionKinds are the kinds completion.kindPriority can rank, by their LSP name
+var completionKinds = func() map[string]protocol.CompletionItemKind {
+	kinds := make(map[string]protocol.CompletionItemKind)
+	for kind := protocol.CompletionItemKindText; kind <= protocol.CompletionItemKindTypeParameter; kind++ {
+		kinds[strings.ToLower(kind.String())] = kind
+	}
+	return kinds
+}()
+
+// kindRanks orders items of otherwise equal priority by their kind, lowest rank first. Kinds
+// missing from the table come last
+type kindRanks map[protocol.CompletionItemKind]int
+
+func (r kindRanks) rank(kind protocol.CompletionItemKind) int {
+	if rank, ok := r[kind]; ok {
+		return rank
+	}
+	return math.MaxInt
+}
+
+// sortCompletionItems puts the preselected item first, then orders items by their existing
+// SortText, how recently they were used according to recent, the rank of their kind and label, and
+// rewrites SortText to the resulting position. OmniSharp doesn't order items of equal
+// priority consistently, so without this the editor's top suggestion changes between requests
+func sortCompletionItems(items []CompletionItem, recent map[string]float64, ranks kindRanks) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if a.Preselect != b.Preselect {
+			return a.Preselect
+		}
+		if a.SortText != b.SortText {
+			return a.SortText < b.SortText
+		}
+		if len(recent) > 0 {
+			if ra, rb := recent[recentKey(a)], recent[recentKey(b)]; ra != rb {
+				return ra > rb
+			}
+		}
+		if pa, pb := ranks.rank(a.Kind), ranks.rank(b.Kind); pa != pb {
+			return pa < pb
+		}
+		if la, lb := strings.ToLower(a.Label), strings.ToLower(b.Label); la != lb {
+			return la < lb
+		}
+		if a.Label != b.Label {
+			return a.Label < b.Label
+		}
+		// Overloads share a label, so fall back to what distinguishes them
+		if a.InsertText != b.InsertText {
+			return a.InsertText < b.InsertText
+		}
+		return a.Detail < b.Detail
+	})
+
+	width := len(fmt.Sprint(len(items)))
+	for i := range items {
+		items[i].SortText = fmt.Sprintf("%0*d", width, i)
+	}
+}
+
+// completionItemDefaults computes the default edit range from the identifier around the caret.
+// With insertReplaceSupport the client gets both ranges and decides whether accepting an item
+// overwrites the rest of the identifier after the caret; others supporting a default range get
+// the identifier up to the caret
+func (s *Server) completionItemDefaults(params *protocol.CompletionParams) *CompletionItemDefaults {
+	if !s.supportsInsertReplace() && !s.completionListDefaults.EditRange {
+		return nil
+	}
+
+	doc, ok := s.documents.Get(params.TextDocument.URI)
+	if !ok {
+		return nil
+	}
+
+	insert, replace := wordRanges(doc.Text, params.Position)
+	if !s.supportsInsertReplace() {
+		return &CompletionItemDefaults{EditRange: &insert}
+	}
+	return &CompletionItemDefaults{
+		EditRange: &InsertReplaceRange{Insert: insert, Replace: replace},
+	}
+}
+
+// pinMemberEditRanges gives the items of a member access without an edit of their own one
+// replacing the member typed after the dot, for clients without a default edit range. Left to
+// pick the word themselves, some take the dot with it, and accepting position after transform.
+// would give transformposition
+func pinMemberEditRanges(items []CompletionItem, doc Document, pos protocol.Position) {
+	if !afterMemberAccess(doc.Text, offsetAt(doc.Text, pos)) {
+		return
+	}
+	insert, _ := wordRanges(doc.Text, pos)
+	for i := range items {
+		if items[i].TextEdit != nil {
+			continue
+		}
+		newText := items[i].InsertText
+		if newText == "" {
+			newText = items[i].Label
+		}
+		items[i].TextEdit = &protocol.TextEdit{Range: insert, NewText: newText}
+	}
+}
+
+func (s *Server) supportsInsertReplace() bool {
+	textDocument := s.capabilities.TextDocument
+	if textDocument == nil || textDocument.Completion == nil || textDocument.Completion.CompletionItem == nil {
+		return false
+	}
+	return textDocument.Completion.CompletionItem.InsertReplaceSupport
+}
+
+func (s *Server) supportsSnippets() bool {
+	textDocument := s.capabilities.TextDocument
+	if textDocument == nil || textDocument.Completion == nil || textDocument.Completion.CompletionItem == nil {
+		return false
+	}
+	return textDocument.Completion.CompletionItem.SnippetSupport
+}
+
+// supportsAdjustIndentation reports whether the client can re-indent multi-line insertions
+func (s *Server) supportsAdjustIndentation() bool {
+	textDocument := s.capabilities.TextDocument
+	if textDocument == nil || textDocument.Completion == nil || textDocument.Completion.CompletionItem == nil {
+		return false
+	}
+	support := textDocument.Completion.CompletionItem.InsertTextModeSupport
+	if support == nil {
+		return false
+	}
+	for _, mode := range support.ValueSet {
+		if mode == protocol.InsertTextModeAdjustIndentation {
+			return true
+		}
+	}
+	return false
+}
+
+// indentMultilineItems makes multi-line insertions, such as override stubs, follow the
+// indentation of the caret's line. Clients that can adjust indentation do it themselves,
+// which also accounts for their tab settings; for others the lines are indented here
+func (s *Server) indentMultilineItems(items []CompletionItem, line string) {
+	adjust := s.supportsAdjustIndentation()
+	indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+
+	for i := range items {
+		item := &items[i]
+		edit, _ := item.TextEdit.(*protocol.TextEdit)
+		if !strings.Contains(item.InsertText, "\n") && !strings.Contains(item.TextEditText, "\n") && (edit == nil || !strings.Contains(edit.NewText, "\n")) {
+			continue
+		}
+		if adjust {
+			item.InsertTextMode = protocol.InsertTextModeAdjustIndentation
+			continue
+		}
+		if indent != "" {
+			item.InsertText = strings.ReplaceAll(item.InsertText, "\n", "\n"+indent)
+			item.TextEditText = strings.ReplaceAll(item.TextEditText, "\n", "\n"+indent)
+			if edit != nil {
+				edit.NewText = strings.ReplaceAll(edit.NewText, "\n", "\n"+indent)
+			}
+		}
+	}
+}
+
+// convertKind maps the Roslyn symbol kind OmniSharp reports to the closest completion kind.
+// LSP has no kinds for destructors, indexers, delegates or labels, so they borrow from the
+// construct they behave like
+func convertKind(omnisharpKind string) protocol.CompletionItemKind {
+	switch omnisharpKind {
+	case "Method", "ExtensionMethod", "Destructor", "LocalFunction":
+		return protocol.CompletionItemKindMethod
+	case "Constructor":
+		return protocol.CompletionItemKindConstructor
+	case "Operator", "UserDefinedOperator", "Conversion":
+		return protocol.CompletionItemKindOperator
+	case "Property", "Indexer":
+		return protocol.CompletionItemKindProperty
+	case "Field":
+		return protocol.CompletionItemKindField
+	case "Event":
+		return protocol.CompletionItemKindEvent
+	case "Local", "Parameter", "RangeVariable", "Discard":
+		return protocol.CompletionItemKindVariable
+	case "Constant":
+		return protocol.CompletionItemKindConstant
+	case "EnumMember":
+		return protocol.CompletionItemKindEnumMember
+	case "Class", "Delegate", "Record":
+		return protocol.CompletionItemKindClass
+	case "Struct", "RecordStruct":
+		return protocol.CompletionItemKindStruct
+	case "Interface":
+		return protocol.CompletionItemKindInterface
+	case "Enum":
+		return protocol.CompletionItemKindEnum
+	case "TypeParameter":
+		return protocol.CompletionItemKindTypeParameter
+	case "Namespace":
+		return protocol.CompletionItemKindModule
+	case "Keyword":
+		return protocol.CompletionItemKindKeyword
+	case "Snippet":
+		return protocol.CompletionItemKindSnippet
+	case "Label":
+		return protocol.CompletionItemKindReference
+	default:
+		return protocol.CompletionItemKindText
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+)
+
+// qualifyAmbiguousTypes has the types whose simple name another type of the list shares insert
+// their qualified name where the bare name wouldn't compile: a type in scope alongside another
+// of that name in scope, as with UnityEngine.Random and System.Random both imported, or a type
+// whose using would bring in a second one. The qualified name is shown as detail to tell them
+// apart. Types imported along with no other type of their name keep their using
+func qualifyAmbiguousTypes(items []CompletionItem) {
+	qualified := make([]string, len(items))
+	// inScope holds the qualified names of the types in scope, by simple name
+	inScope := make(map[string]map[string]bool)
+	for i := range items {
+		name, ok := qualifiedTypeName(items[i])
+		if !ok {
+			continue
+		}
+		qualified[i] = name
+		if items[i].requiredImport == "" {
+			simple := items[i].TextEditText
+			if inScope[simple] == nil {
+				inScope[simple] = make(map[string]bool)
+			}
+			inScope[simple][name] = true
+		}
+	}
+
+	for i := range items {
+		if qualified[i] == "" {
+			continue
+		}
+		ambiguous := false
+		for name := range inScope[items[i].TextEditText] {
+			ambiguous = ambiguous || name != qualified[i]
+		}
+		if !ambiguous {
+			continue
+		}
+		items[i].InsertText, items[i].TextEditText = qualified[i], qualified[i]
+		items[i].Detail = qualified[i]
+		// The qualified name needs no using, which would make the bare name ambiguous
+		items[i].AdditionalTextEdits = nil
+	}
+}
+
+// qualifiedTypeName finds the namespace-qualified name of a type item in the symbol OmniSharp
+// describes it with, such as "class UnityEngine.Random"
+func qualifiedTypeName(item CompletionItem) (string, bool) {
+	data, ok := item.Data.(*completionData)
+	if !ok || data.Source != completionSourceOmniSharp || !typeKinds[item.Kind] || item.TextEditText == "" {
+		return "", false
+	}
+	for _, word := range strings.Fields(data.Symbol) {
+		if end := strings.IndexAny(word, "<("); end >= 0 {
+			word = word[:end]
+		}
+		if strings.HasSuffix(word, "."+item.TextEditText) {
+			return word, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestQualifiedTypeName(t *testing.T) {
+	tests := []struct {
+		name   string
+		kind   protocol.CompletionItemKind
+		source string
+		// text is the name inserted, symbol what OmniSharp describes the item with
+		text, symbol string
+		want         string
+	}{
+		{"class", protocol.CompletionItemKindClass, completionSourceOmniSharp, "Random", "class UnityEngine.Random", "UnityEngine.Random"},
+		{"generic", protocol.CompletionItemKindClass, completionSourceOmniSharp, "List", "class System.Collections.Generic.List<T>", "System.Collections.Generic.List"},
+		{"struct", protocol.CompletionItemKindStruct, completionSourceOmniSharp, "Vector3", "readonly struct UnityEngine.Vector3", "UnityEngine.Vector3"},
+		{"not a type", protocol.CompletionItemKindMethod, completionSourceOmniSharp, "Range", "float UnityEngine.Random.Range(float min, float max)", ""},
+		{"no namespace", protocol.CompletionItemKindClass, completionSourceOmniSharp, "Random", "class Random", ""},
+		{"not OmniSharp's", protocol.CompletionItemKindClass, completionSourceUnity, "Random", "class UnityEngine.Random", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			item := CompletionItem{
+				CompletionItem: protocol.CompletionItem{Kind: test.kind, Data: &completionData{Source: test.source, Symbol: test.symbol}},
+				TextEditText:   test.text,
+			}
+			got, ok := qualifiedTypeName(item)
+			if got != test.want || ok != (test.want != "") {
+				t.Errorf("qualifiedTypeName = %q, %v, want %q", got, ok, test.want)
+			}
+		})
+	}
+}
+
+// TestAmbiguousTypeCompletions checks a type sharing its simple name with another in scope, or
+// whose using would bring that ambiguity, inserts its qualified name without a using
+func TestAmbiguousTypeCompletions(t *testing.T) {
+	unityRandom := AutoCompleteResponse{CompletionText: "Random", DisplayText: "Random", Kind: "Class", Description: "class UnityEngine.Random"}
+	systemRandom := AutoCompleteResponse{CompletionText: "Random", DisplayText: "Random", Kind: "Class", Description: "class System.Random"}
+	imported := func(item AutoCompleteResponse, namespace string) AutoCompleteResponse {
+		item.RequiredNamespaceImport = namespace
+		return item
+	}
+	tests := []struct {
+		name  string
+		items []AutoCompleteResponse
+		// line is completed at its end
+		line string
+		// want are the names inserted, and wantUsings the namespaces of the usings added
+		want       []string
+		wantUsings []string
+	}{
+		{"both in scope", []AutoCompleteResponse{unityRandom, systemRandom}, "    var r = ", []string{"System.Random", "UnityEngine.Random"}, []string{"", ""}},
+		{"using would make it ambiguous", []AutoCompleteResponse{unityRandom, imported(systemRandom, "System")}, "    var r = ", []string{"Random", "System.Random"}, []string{"", ""}},
+		{"neither in scope", []AutoCompleteResponse{imported(unityRandom, "UnityEngine"), imported(systemRandom, "System")}, "    var r = ", []string{"Random", "Random"}, []string{"UnityEngine", "System"}},
+		{"single type", []AutoCompleteResponse{unityRandom}, "    var r = ", []string{"Random"}, []string{""}},
+		{"after member access", []AutoCompleteResponse{unityRandom, systemRandom}, "    var r = Game.", []string{"Random", "Random"}, []string{"", ""}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": test.items})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, "class Player\n{\n"+test.line+"\n}\n")
+
+			list := completeAt(t, s, uri, protocol.Position{Line: 2, Character: uint32(len(test.line))}, protocol.CompletionTriggerKindInvoked)
+			var inserted, usings []string
+			for _, item := range list.Items {
+				inserted = append(inserted, item.TextEditText)
+				if strings.Contains(item.TextEditText, ".") && item.Detail != item.TextEditText {
+					t.Errorf("%s detailed %q, want its qualified name", item.TextEditText, item.Detail)
+				}
+				using := ""
+				for _, edit := range item.AdditionalTextEdits {
+					using = edit.NewText
+				}
+				usings = append(usings, using)
+			}
+			if !reflect.DeepEqual(inserted, test.want) {
+				t.Errorf("inserted %q, want %q", inserted, test.want)
+			}
+			for i, namespace := range test.wantUsings {
+				if i >= len(usings) {
+					break
+				}
+				if want := "using " + namespace + ";"; namespace != "" && !strings.HasPrefix(usings[i], want) {
+					t.Errorf("%s added %q, want %s", inserted[i], usings[i], want)
+				} else if namespace == "" && usings[i] != "" {
+					t.Errorf("%s added %q, want no using", inserted[i], usings[i])
+				}
+			}
+		})
+	}
+}
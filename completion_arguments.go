@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// namedArgumentCompletions offers name: for each parameter of the overloads of the call around
+// the caret, when an argument is about to be typed. Parameters already named in the call are
+// left out
+func (s *Server) namedArgumentCompletions(ctx context.Context, omnisharp *OmniSharpClient, doc Document, pos protocol.Position) []CompletionItem {
+	offset := offsetAt(doc.Text, pos)
+	before := doc.Text[:offset]
+	before = strings.TrimRight(strings.TrimSuffix(before, identifierBefore(before)), " \t\r\n")
+	if !strings.HasSuffix(before, "(") && !strings.HasSuffix(before, ",") {
+		return nil
+	}
+	open, _, ok := enclosingCall(doc.Text, offset)
+	if !ok {
+		return nil
+	}
+
+	response, err := omnisharp.SendRequest(ctx, "/signaturehelp", omnisharpPosition(doc.URI, pos))
+	if err != nil {
+		log.Printf("failed to get parameters for named arguments: %v", err)
+		return nil
+	}
+	var help SignatureHelpResponse
+	if err := json.Unmarshal(response, &help); err != nil {
+		log.Printf("failed to get parameters for named arguments: %v", err)
+		return nil
+	}
+
+	arguments := doc.Text[open:offset]
+	seen := make(map[string]bool)
+	var items []CompletionItem
+	for _, signature := range help.Signatures {
+		for _, parameter := range signature.Parameters {
+			if parameter.Name == "" || seen[parameter.Name] || isNamedArgument(arguments, parameter.Name) {
+				continue
+			}
+			seen[parameter.Name] = true
+			items = append(items, s.namedArgumentItem(parameter.Name, parameter.Label))
+		}
+	}
+	return items
+}
+
+// isNamedArgument reports whether arguments, the text from the open parenthesis to the caret,
+// already passes name: as a named argument
+func isNamedArgument(arguments, name string) bool {
+	for _, separator := range []string{"(", ","} {
+		for _, part := range strings.Split(arguments, separator)[1:] {
+			if strings.HasPrefix(strings.TrimSpace(part), name+":") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// namedArgumentItem inserts name: and, for clients with snippets, leaves the caret after it
+func (s *Server) namedArgumentItem(name, label string) CompletionItem {
+	insert := name + ": "
+	format := protocol.InsertTextFormatPlainText
+	if s.supportsSnippets() {
+		insert, format = name+": $0", protocol.InsertTextFormatSnippet
+	}
+	return CompletionItem{
+		CompletionItem: protocol.CompletionItem{
+			Label:            name + ":",
+			Kind:             protocol.CompletionItemKindVariable,
+			Detail:           "(named argument) " + label,
+			InsertText:       insert,
+			InsertTextFormat: format,
+		},
+		TextEditText: insert,
+	}
+}
+
+// appendNamedArguments adds named argument items unless OmniSharp offered the same label
+func appendNamedArguments(items, named []CompletionItem) []CompletionItem {
+	labels := make(map[string]bool, len(items))
+	for _, item := range items {
+		labels[item.Label] = true
+	}
+	for _, item := range named {
+		if !labels[item.Label] {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestNamedArgumentCompletions(t *testing.T) {
+	signatures := map[string]interface{}{"Signatures": []interface{}{
+		map[string]interface{}{"Label": "void Player.Move(float x, float y)", "Parameters": []interface{}{
+			map[string]interface{}{"Name": "x", "Label": "float x"},
+			map[string]interface{}{"Name": "y", "Label": "float y"},
+		}},
+		map[string]interface{}{"Label": "void Player.Move(float x, float y, Space space)", "Parameters": []interface{}{
+			map[string]interface{}{"Name": "x", "Label": "float x"},
+			map[string]interface{}{"Name": "y", "Label": "float y"},
+			map[string]interface{}{"Name": "space", "Label": "Space space"},
+		}},
+	}}
+	tests := []struct {
+		name string
+		call string
+		want []string
+	}{
+		{"first argument", "Move(", []string{"space:", "x:", "y:"}},
+		{"typing the first argument", "Move(sp", []string{"space:"}},
+		{"next argument", "Move(1f, ", []string{"space:", "x:", "y:"}},
+		{"after a named argument", "Move(x: 1f, ", []string{"space:", "y:"}},
+		{"after named arguments", "Move(y: 2f, x: 1f, ", []string{"space:"}},
+		{"inside an argument", "Move(1f +", nil},
+		{"not in a call", "var speed = ", nil},
+		{"in an indexer", "values[", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{
+				"/signaturehelp": signatures,
+				"/autocomplete":  autoCompleteItems("speed"),
+			})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			text := "class Player { void Start() { " + test.call
+			openTestDocument(s, uri, text+" } }\n")
+
+			list := completeAt(t, s, uri, protocol.Position{Character: uint32(len(text))}, protocol.CompletionTriggerKindInvoked)
+			var named []string
+			for _, item := range list.Items {
+				if strings.HasPrefix(item.Detail, "(named argument)") {
+					named = append(named, item.Label)
+				}
+			}
+			sort.Strings(named)
+			if !reflect.DeepEqual(named, test.want) {
+				t.Errorf("named arguments %v, want %v", named, test.want)
+			}
+		})
+	}
+}
+
+func TestNamedArgumentItem(t *testing.T) {
+	tests := []struct {
+		name       string
+		snippets   bool
+		wantInsert string
+		wantFormat protocol.InsertTextFormat
+	}{
+		{"snippets", true, "space: $0", protocol.InsertTextFormatSnippet},
+		{"plain text", false, "space: ", protocol.InsertTextFormatPlainText},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newServer(DefaultConfig())
+			s.capabilities.TextDocument = &protocol.TextDocumentClientCapabilities{Completion: &protocol.CompletionTextDocumentClientCapabilities{
+				CompletionItem: &protocol.CompletionTextDocumentClientCapabilitiesItem{SnippetSupport: test.snippets},
+			}}
+			item := s.namedArgumentItem("space", "Space space")
+			if item.Label != "space:" || item.Detail != "(named argument) Space space" {
+				t.Errorf("item = %+v", item)
+			}
+			if item.InsertText != test.wantInsert || item.TextEditText != test.wantInsert || item.InsertTextFormat != test.wantFormat {
+				t.Errorf("inserts %q, %q as %v, want %q as %v", item.InsertText, item.TextEditText, item.InsertTextFormat, test.wantInsert, test.wantFormat)
+			}
+		})
+	}
+}
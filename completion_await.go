@@ -0,0 +1,146 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// awaitableTypes are the awaitable types members commonly return, by simple name: the BCL
+// tasks, UniTask, and Unity's own Awaitable
+var awaitableTypes = map[string]bool{
+	"Task":      true,
+	"ValueTask": true,
+	"UniTask":   true,
+	"Awaitable": true,
+}
+
+// isAwaitableType reports whether a return type, such as "System.Threading.Tasks.Task<int>"
+// or "UniTask", is one of awaitableTypes
+func isAwaitableType(returnType string) bool {
+	name, _, _ := strings.Cut(strings.TrimSpace(returnType), "<")
+	name = strings.TrimSuffix(name, "?")
+	if dot := strings.LastIndexByte(name, '.'); dot >= 0 {
+		name = name[dot+1:]
+	}
+	return awaitableTypes[name]
+}
+
+// awaitOperand matches text ending where the operand of await goes, including the qualifiers
+// of a member as in await manager.
+var awaitOperand = regexp.MustCompile(`\bawait\s+(?:@?[A-Za-z_]\w*\s*\.\s*)*$`)
+
+// memberChain matches the qualifiers of a member access ending text, such as "manager.", and
+// what precedes them
+var memberChain = regexp.MustCompile(`(?:@?[A-Za-z_]\w*\s*\.\s*)*$`)
+
+// afterAwait reports whether offset is in the operand of await, ignoring the identifier being typed
+func afterAwait(text string, offset int) bool {
+	before := text[:offset]
+	return awaitOperand.MatchString(strings.TrimSuffix(before, identifierBefore(before)))
+}
+
+// blockKeywords start statements whose block belongs to the enclosing method
+var blockKeywords = regexp.MustCompile(`^(?:if|else|for|foreach|while|do|try|catch|finally|using|lock|switch|checked|unchecked|unsafe|fixed|case|default)\b`)
+
+// asyncModifier matches the async modifier of a method or lambda header
+var asyncModifier = regexp.MustCompile(`\basync\b`)
+
+// inAsyncBody reports whether offset is in the body of an async method, local function or
+// lambda, where members returning a task can be awaited. The innermost enclosing function
+// decides; blocks of statements such as if and using are looked through
+func inAsyncBody(text string, offset int) bool {
+	before := text[:offset]
+
+	// An expression bodied lambda or member, as in async () => Load(
+	if statement := before[strings.LastIndexAny(before, ";{}")+1:]; strings.Contains(statement, "=>") {
+		return asyncModifier.MatchString(statement)
+	}
+
+	end := len(before)
+	for {
+		open := openingBrace(before[:end])
+		if open < 0 {
+			return false
+		}
+		header := strings.TrimSpace(before[strings.LastIndexAny(before[:open], ";{}")+1 : open])
+		switch {
+		case strings.HasSuffix(header, "=>") || strings.HasPrefix(header, "delegate") || strings.Contains(header, " delegate"):
+			return asyncModifier.MatchString(header)
+		case header == "" || blockKeywords.MatchString(header) || strings.HasSuffix(header, ":"):
+			end = open
+		case strings.HasSuffix(header, ")") || strings.Contains(header, ")") && strings.Contains(header, " where "):
+			return asyncModifier.MatchString(header)
+		default:
+			// A type, namespace, property or initializer
+			return false
+		}
+	}
+}
+
+// openingBrace returns the offset of the brace left open at the end of text, or -1
+func openingBrace(text string) int {
+	depth := 0
+	for i := len(text) - 1; i >= 0; i-- {
+		switch text[i] {
+		case '}':
+			depth++
+		case '{':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// rankAwaitableCompletions puts the items returning an awaitable type first after await,
+// keeping the order within both groups
+func rankAwaitableCompletions(items []CompletionItem, await bool) []CompletionItem {
+	if !await {
+		return items
+	}
+	for i := range items {
+		if isAwaitableType(items[i].returnType) {
+			items[i].SortText = "0" + items[i].SortText
+		} else {
+			items[i].SortText = "1" + items[i].SortText
+		}
+	}
+	return items
+}
+
+// insertAwait makes accepting a method returning an awaitable type in an async body also
+// insert await before it, or before the qualifiers of the member being accessed. line is the
+// line of pos, the caret
+func insertAwait(items []CompletionItem, line string, pos protocol.Position) []CompletionItem {
+	before := line[:utf16ToByteOffset(line, pos.Character)]
+	lead := strings.TrimSuffix(before, identifierBefore(before))
+	chain := memberChain.FindString(lead)
+	start := len(lead) - len(chain)
+	// Member accesses on calls or indexers, as in GetComponent<T>().Load, aren't handled
+	if prefix := strings.TrimRight(lead[:start], " \t"); strings.HasSuffix(prefix, ")") || strings.HasSuffix(prefix, "]") ||
+		strings.HasSuffix(prefix, ">") || strings.HasSuffix(prefix, "?") || strings.HasSuffix(prefix, ".") {
+		return items
+	}
+
+	for i, item := range items {
+		if item.Kind != protocol.CompletionItemKindMethod || !isAwaitableType(item.returnType) {
+			continue
+		}
+		if chain == "" {
+			items[i].InsertText = "await " + item.InsertText
+			items[i].TextEditText = "await " + item.TextEditText
+			continue
+		}
+		at := protocol.Position{Line: pos.Line, Character: byteToUTF16Offset(line, start)}
+		edits := append([]protocol.TextEdit(nil), item.AdditionalTextEdits...)
+		items[i].AdditionalTextEdits = append(edits, protocol.TextEdit{
+			Range:   protocol.Range{Start: at, End: at},
+			NewText: "await ",
+		})
+	}
+	return items
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestIsAwaitableType(t *testing.T) {
+	tests := []struct {
+		returnType string
+		want       bool
+	}{
+		{"Task", true},
+		{"System.Threading.Tasks.Task<int>", true},
+		{"ValueTask<bool>", true},
+		{"Cysharp.Threading.Tasks.UniTask", true},
+		{"UniTask<GameObject>", true},
+		{"UnityEngine.Awaitable", true},
+		{"Task?", true},
+		{"void", false},
+		{"TaskManager", false},
+		{"IEnumerator", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if got := isAwaitableType(test.returnType); got != test.want {
+			t.Errorf("isAwaitableType(%q) = %v, want %v", test.returnType, got, test.want)
+		}
+	}
+}
+
+func TestAfterAwait(t *testing.T) {
+	tests := []struct {
+		name string
+		// text has | at the caret
+		text string
+		want bool
+	}{
+		{"operand", "await |", true},
+		{"operand begun", "await Lo|", true},
+		{"member", "await manager.|", true},
+		{"member begun", "await manager.scenes.Lo|", true},
+		{"awaited already", "await Load(); |", false},
+		{"argument", "await Load(|", false},
+		{"await itself", "awa|", false},
+		{"identifier ending in await", "preawait |", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			offset := strings.Index(test.text, "|")
+			text := strings.Replace(test.text, "|", "", 1)
+			if got := afterAwait(text, offset); got != test.want {
+				t.Errorf("afterAwait = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestInAsyncBody(t *testing.T) {
+	tests := []struct {
+		name string
+		// text has | at the caret
+		text string
+		want bool
+	}{
+		{"async method", "class Player { async Task Start() { |", true},
+		{"method", "class Player { void Start() { |", false},
+		{"in an if", "class Player { async void Start() { if (ready) { |", true},
+		{"in a using and a try", "class Player { async Task Start() { using (var scope = Open()) { try { |", true},
+		{"async lambda in a method", "class Player { void Start() { button.onClick += async () => { |", true},
+		{"lambda in an async method", "class Player { async Task Start() { items.ForEach(item => { |", false},
+		{"expression bodied async lambda", "class Player { void Start() { Run(async () => |", true},
+		{"async local function", "class Player { void Start() { async Task Load() { |", true},
+		{"generic constrained method", "class Pool { async Task<T> Get<T>() where T : Component { |", true},
+		{"class body", "class Player { |", false},
+		{"after the async method", "class Player { async Task Start() { } void Update() { |", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			offset := strings.Index(test.text, "|")
+			text := strings.Replace(test.text, "|", "", 1)
+			if got := inAsyncBody(text, offset); got != test.want {
+				t.Errorf("inAsyncBody = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+var awaitItems = []AutoCompleteResponse{
+	{CompletionText: "Save", DisplayText: "Save", Kind: "Method", ReturnType: "void"},
+	{CompletionText: "speed", DisplayText: "speed", Kind: "Field", ReturnType: "float"},
+	{CompletionText: "Load", DisplayText: "Load", Kind: "Method", ReturnType: "Task"},
+	{CompletionText: "LoadScene", DisplayText: "LoadScene", Kind: "Method", ReturnType: "UniTask<Scene>"},
+}
+
+// TestAwaitableCompletionsRankFirst checks members returning an awaitable type come first
+// after await, and keep their place elsewhere
+func TestAwaitableCompletionsRankFirst(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		want   []string
+	}{
+		{"after await", "class Player { async Task Start() { await ", []string{"Load", "LoadScene", "speed", "Save"}},
+		{"member after await", "class Player { async Task Start() { await manager.", []string{"Load", "LoadScene", "speed", "Save"}},
+		{"elsewhere", "class Player { async Task Start() { var x = ", []string{"speed", "Load", "LoadScene", "Save"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": awaitItems})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, test.before+" } }\n")
+
+			list := completeAt(t, s, uri, protocol.Position{Character: uint32(len(test.before))}, protocol.CompletionTriggerKindInvoked)
+			if got := omnisharpLabels(list.Items); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("completed %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// omnisharpLabels returns the labels of the awaitItems completed, in order
+func omnisharpLabels(items []CompletionItem) []string {
+	var names []string
+	for _, item := range items {
+		for _, known := range awaitItems {
+			if item.Label == known.DisplayText {
+				names = append(names, item.Label)
+			}
+		}
+	}
+	return names
+}
+
+// TestInsertAwait checks accepting a method returning an awaitable type in an async body also
+// inserts await, before the member access it completes
+func TestInsertAwait(t *testing.T) {
+	tests := []struct {
+		name     string
+		before   string
+		disabled bool
+		// wantInline inserts await with the item's text
+		wantInline bool
+		// wantAt is where await is inserted before the member access completed, if not zero
+		wantAt uint32
+	}{
+		{name: "async method", before: "class Player { async Task Start() { ", wantInline: true},
+		{name: "member", before: "class Player { async Task Start() { manager.", wantAt: 36},
+		{name: "nested member", before: "class Player { async Task Start() { game.manager.", wantAt: 36},
+		{name: "member of a call", before: "class Player { async Task Start() { GetComponent<Manager>()."},
+		{name: "not async", before: "class Player { void Start() { "},
+		{name: "after await", before: "class Player { async Task Start() { await "},
+		{name: "disabled", before: "class Player { async Task Start() { ", disabled: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": awaitItems})
+			s, _ := newTestServer(t, fake)
+			configure(s, func(config *Config) { config.Completion.InsertAwait = !test.disabled })
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, test.before+" } }\n")
+
+			list := completeAt(t, s, uri, protocol.Position{Character: uint32(len(test.before))}, protocol.CompletionTriggerKindInvoked)
+			for _, item := range list.Items {
+				awaitable := item.Label == "Load" || item.Label == "LoadScene"
+				inline := strings.HasPrefix(item.TextEditText, "await ")
+				var edit *protocol.TextEdit
+				for i, additional := range item.AdditionalTextEdits {
+					if additional.NewText == "await " {
+						edit = &item.AdditionalTextEdits[i]
+					}
+				}
+				switch {
+				case !awaitable || !test.wantInline && test.wantAt == 0:
+					if inline || edit != nil {
+						t.Errorf("%s inserts await: %q, %+v", item.Label, item.TextEditText, edit)
+					}
+				case test.wantInline:
+					if !inline || !strings.HasPrefix(item.InsertText, "await ") || edit != nil {
+						t.Errorf("%s inserts %q (%q), %+v; want await with it", item.Label, item.TextEditText, item.InsertText, edit)
+					}
+				default:
+					at := protocol.Position{Character: test.wantAt}
+					if inline || edit == nil || edit.Range != (protocol.Range{Start: at, End: at}) {
+						t.Errorf("%s inserts %q, %+v; want await inserted at %d", item.Label, item.TextEditText, edit, test.wantAt)
+					}
+				}
+			}
+		})
+	}
+}
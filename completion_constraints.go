@@ -0,0 +1,100 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// constraintContext is the position within a type parameter's constraint list at the caret
+type constraintContext int
+
+const (
+	constraintNone constraintContext = iota
+	// constraintFirst is the first constraint of the list, e.g. after where T :
+	constraintFirst
+	// constraintLater is a constraint after the first, e.g. after where T : Component,
+	constraintLater
+)
+
+// constraintList matches a where clause up to its last constraint, capturing the constraints
+// before it
+var constraintList = regexp.MustCompile(`\bwhere\s+[A-Za-z_]\w*\s*:((?:[^:;{}()=]|\(\))*)$`)
+
+// constraintKeywords are the constraints that aren't types. class, struct, unmanaged and
+// notnull must come first; new() must come last
+var constraintKeywords = []struct {
+	label string
+	first bool
+}{
+	{"class", true},
+	{"struct", true},
+	{"unmanaged", true},
+	{"notnull", true},
+	{"new()", false},
+}
+
+// constraintKinds are the completion kinds a constraint can name besides the keywords
+var constraintKinds = map[protocol.CompletionItemKind]bool{
+	protocol.CompletionItemKindClass:         true,
+	protocol.CompletionItemKindInterface:     true,
+	protocol.CompletionItemKindTypeParameter: true,
+	protocol.CompletionItemKindModule:        true,
+	protocol.CompletionItemKindText:          true,
+}
+
+// constraintContextAt finds the constraint started at offset, ignoring the identifier being typed
+func constraintContextAt(text string, offset int) constraintContext {
+	before := text[:offset]
+	before = strings.TrimSuffix(before, identifierBefore(before))
+	match := constraintList.FindStringSubmatch(before)
+	if match == nil {
+		return constraintNone
+	}
+	if strings.TrimSpace(match[1]) == "" {
+		return constraintFirst
+	}
+	if strings.HasSuffix(strings.TrimSpace(match[1]), ",") {
+		return constraintLater
+	}
+	// The caret follows a complete constraint, such as where T : class
+	return constraintNone
+}
+
+// constraintCompletions offers the constraint keywords valid at context
+func constraintCompletions(context constraintContext) []CompletionItem {
+	var items []CompletionItem
+	for _, keyword := range constraintKeywords {
+		if context == constraintNone || (keyword.first && context != constraintFirst) {
+			continue
+		}
+		items = append(items, CompletionItem{
+			CompletionItem: protocol.CompletionItem{
+				Label:      keyword.label,
+				Kind:       protocol.CompletionItemKindKeyword,
+				Detail:     "(constraint)",
+				FilterText: strings.TrimSuffix(keyword.label, "()"),
+				InsertText: keyword.label,
+			},
+			TextEditText: keyword.label,
+		})
+	}
+	return items
+}
+
+// filterConstraintCompletions drops the items that can't be a constraint, such as structs,
+// which can't be derived from, or members in scope
+func filterConstraintCompletions(items []CompletionItem, context constraintContext) []CompletionItem {
+	if context == constraintNone {
+		return items
+	}
+
+	filtered := items[:0]
+	for _, item := range items {
+		if constraintKinds[item.Kind] {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
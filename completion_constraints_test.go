@@ -0,0 +1,77 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestConstraintContextAt(t *testing.T) {
+	tests := []struct {
+		name string
+		// text has | at the caret
+		text string
+		want constraintContext
+	}{
+		{"first constraint", "class Pool<T> where T : |", constraintFirst},
+		{"first constraint begun", "class Pool<T> where T : Comp|", constraintFirst},
+		{"method constraint", "T Spawn<T>() where T : |", constraintFirst},
+		{"after a comma", "class Pool<T> where T : Component, |", constraintLater},
+		{"after new()", "class Pool<T> where T : new(), |", constraintLater},
+		{"after a complete constraint", "class Pool<T> where T : class |", constraintNone},
+		{"second where clause", "class Map<K, V> where K : notnull where V : |", constraintFirst},
+		{"inside the body", "class Pool<T> where T : Component { void M() { |", constraintNone},
+		{"base list", "class Pool : |", constraintNone},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			offset := strings.Index(test.text, "|")
+			text := strings.Replace(test.text, "|", "", 1)
+			if got := constraintContextAt(text, offset); got != test.want {
+				t.Errorf("constraintContextAt = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+// TestConstraintCompletions checks the constraint keywords valid at the caret are offered with
+// the types a constraint can name
+func TestConstraintCompletions(t *testing.T) {
+	items := []AutoCompleteResponse{
+		{CompletionText: "Component", DisplayText: "Component", Kind: "Class"},
+		{CompletionText: "IPoolable", DisplayText: "IPoolable", Kind: "Interface"},
+		{CompletionText: "UnityEngine", DisplayText: "UnityEngine", Kind: "Namespace"},
+		{CompletionText: "Vector3", DisplayText: "Vector3", Kind: "Struct"},
+		{CompletionText: "capacity", DisplayText: "capacity", Kind: "Field"},
+	}
+	types := []string{"Component", "IPoolable", "UnityEngine"}
+	tests := []struct {
+		name   string
+		before string
+		want   []string
+	}{
+		{"first constraint", "class Pool<T> where T : ", append([]string{"class", "new()", "notnull", "struct", "unmanaged"}, types...)},
+		{"later constraint", "class Pool<T> where T : Component, ", append([]string{"new()"}, types...)},
+		{"no constraint", "class Pool<T> { int Count() { return ", []string{"Component", "IPoolable", "UnityEngine", "Vector3", "capacity"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": items})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Pool.cs")
+			openTestDocument(s, uri, test.before+"\n")
+
+			list := completeAt(t, s, uri, protocol.Position{Character: uint32(len(test.before))}, protocol.CompletionTriggerKindInvoked)
+			got := labels(list.Items)
+			sort.Strings(got)
+			want := append([]string(nil), test.want...)
+			sort.Strings(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("completions = %v, want %v", got, want)
+			}
+		})
+	}
+}
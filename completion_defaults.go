@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+)
+
+// completionListDefaults are the itemDefaults of a completion list the client accepts, from its
+// textDocument.completion.completionList capability, which protocol.ClientCapabilities lacks
+type completionListDefaults struct {
+	EditRange        bool
+	CommitCharacters bool
+}
+
+// parseCompletionListDefaults reads the completionList capability from the raw initialize params
+func parseCompletionListDefaults(raw json.RawMessage) completionListDefaults {
+	var params struct {
+		Capabilities struct {
+			TextDocument struct {
+				Completion struct {
+					CompletionList struct {
+						ItemDefaults []string `json:"itemDefaults"`
+					} `json:"completionList"`
+				} `json:"completion"`
+			} `json:"textDocument"`
+		} `json:"capabilities"`
+	}
+	if json.Unmarshal(raw, &params) != nil {
+		return completionListDefaults{}
+	}
+	defaults := params.Capabilities.TextDocument.Completion.CompletionList.ItemDefaults
+	return completionListDefaults{
+		EditRange:        containsString(defaults, "editRange"),
+		CommitCharacters: containsString(defaults, "commitCharacters"),
+	}
+}
+
+// supportsCommitCharacters reports whether the client accepts commit characters on items
+func (s *Server) supportsCommitCharacters() bool {
+	textDocument := s.capabilities.TextDocument
+	if textDocument == nil || textDocument.Completion == nil || textDocument.Completion.CompletionItem == nil {
+		return false
+	}
+	return textDocument.Completion.CompletionItem.CommitCharactersSupport
+}
+
+// factorItemDefaults moves the commit characters most items share into defaults, leaving them
+// on the items that differ. A default applies to every item without a value of its own, so
+// nothing is factored while some item has none. Data is never factored: every item has its
+// own, which the client would keep over a default, see compressCompletionData instead
+func (s *Server) factorItemDefaults(items []CompletionItem, defaults *CompletionItemDefaults) *CompletionItemDefaults {
+	if defaults == nil {
+		defaults = &CompletionItemDefaults{}
+	}
+	if s.completionListDefaults.CommitCharacters {
+		commitCharacters := factorDefault(items,
+			func(item *CompletionItem) interface{} { return item.CommitCharacters },
+			func(item *CompletionItem) { item.CommitCharacters = nil })
+		if commitCharacters != nil {
+			defaults.CommitCharacters = commitCharacters.([]string)
+		}
+	}
+	if defaults.EditRange == nil && defaults.CommitCharacters == nil {
+		return nil
+	}
+	return defaults
+}
+
+// compressCompletionData replaces the file and position OmniSharp items were requested at, which
+// their data all repeat, with a token origins keeps them under, by which resolve adds them
+// back. A large list so spares the path in every item. Items are left as they are unless two
+// share an origin
+func compressCompletionData(items []CompletionItem, origins *completionOrigins) {
+	var origin *completionData
+	shared := 0
+	for i := range items {
+		data, ok := items[i].Data.(*completionData)
+		if !ok || data.Source != completionSourceOmniSharp || data.FileName == "" {
+			continue
+		}
+		if origin == nil {
+			origin = &completionData{Source: data.Source, FileName: data.FileName, Line: data.Line, Column: data.Column}
+		} else if data.FileName != origin.FileName || data.Line != origin.Line || data.Column != origin.Column {
+			return
+		}
+		shared++
+	}
+	if shared < 2 {
+		return
+	}
+
+	token := origins.add(origin)
+	for i := range items {
+		if data, ok := items[i].Data.(*completionData); ok && data.Source == completionSourceOmniSharp {
+			// A copy, since the items share their data with the session and cache
+			own := *data
+			own.FileName, own.Line, own.Column = "", 0, 0
+			own.Origin = token
+			items[i].Data = &own
+		}
+	}
+}
+
+// completionOriginsKept is how many lists back items can be resolved from once their origin
+// is compressed out. Clients only resolve items of the list they show, so a few suffice
+const completionOriginsKept = 16
+
+// completionOrigins keeps where recent completion lists were requested, by the token their
+// items carry in its place, so an item resolves against its own list's origin even after lists
+// for other positions or documents arrived
+type completionOrigins struct {
+	mu      sync.Mutex
+	last    uint64
+	origins map[uint64]*completionData
+}
+
+func newCompletionOrigins() *completionOrigins {
+	return &completionOrigins{origins: make(map[uint64]*completionData)}
+}
+
+// add keeps origin, forgetting the oldest beyond completionOriginsKept, and returns its token
+func (o *completionOrigins) add(origin *completionData) uint64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.last++
+	o.origins[o.last] = origin
+	delete(o.origins, o.last-completionOriginsKept)
+	return o.last
+}
+
+// get returns the origin kept under token, if it is still kept
+func (o *completionOrigins) get(token uint64) (*completionData, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	origin, ok := o.origins[token]
+	return origin, ok
+}
+
+// factorDefault clears the value of field most items have from them and returns it. It
+// returns nil when some item has no value, or no value is shared by two items, where a default
+// would save nothing
+func factorDefault(items []CompletionItem, field func(item *CompletionItem) interface{}, clear func(item *CompletionItem)) interface{} {
+	keys := make([]string, len(items))
+	counts := make(map[string]int)
+	common := -1
+	for i := range items {
+		value := field(&items[i])
+		if isNilValue(value) {
+			return nil
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil
+		}
+		keys[i] = string(encoded)
+		counts[keys[i]]++
+		if counts[keys[i]] > 1 && (common < 0 || counts[keys[i]] > counts[keys[common]]) {
+			common = i
+		}
+	}
+	if common < 0 {
+		return nil
+	}
+
+	value := field(&items[common])
+	for i := range items {
+		if keys[i] == keys[common] {
+			clear(&items[i])
+		}
+	}
+	return value
+}
+
+func isNilValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func omnisharpData(completionText string, line, column uint32) *completionData {
+	return &completionData{
+		Source:         completionSourceOmniSharp,
+		FileName:       "/project/Assets/Player.cs",
+		Line:           line,
+		Column:         column,
+		CompletionText: completionText,
+		DisplayText:    completionText,
+		Symbol:         "int Player." + completionText,
+	}
+}
+
+func TestFactorItemDefaults(t *testing.T) {
+	withCommitCharacters := func(label string, characters ...string) CompletionItem {
+		item := CompletionItem{CompletionItem: protocol.CompletionItem{Label: label}}
+		item.CommitCharacters = characters
+		return item
+	}
+	tests := []struct {
+		name                 string
+		items                []CompletionItem
+		wantCommitCharacters []string
+		// wantItemCharacters are the commit characters left on each item
+		wantItemCharacters [][]string
+	}{
+		{
+			name:                 "uniform commit characters",
+			items:                []CompletionItem{withCommitCharacters("a", ".", ";"), withCommitCharacters("b", ".", ";")},
+			wantCommitCharacters: []string{".", ";"},
+			wantItemCharacters:   [][]string{nil, nil},
+		},
+		{
+			name: "commit characters varying",
+			items: []CompletionItem{
+				withCommitCharacters("a", "."), withCommitCharacters("b", "(", "."), withCommitCharacters("c", "."),
+			},
+			wantCommitCharacters: []string{"."},
+			wantItemCharacters:   [][]string{nil, {"(", "."}, nil},
+		},
+		{
+			name:               "commit characters all different",
+			items:              []CompletionItem{withCommitCharacters("a", "."), withCommitCharacters("b", "(")},
+			wantItemCharacters: [][]string{{"."}, {"("}},
+		},
+		{
+			// The default would apply to the item without any
+			name:               "an item without commit characters",
+			items:              []CompletionItem{withCommitCharacters("a", "."), withCommitCharacters("b", "."), withCommitCharacters("c")},
+			wantItemCharacters: [][]string{{"."}, {"."}, nil},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newServer(DefaultConfig())
+			s.completionListDefaults = completionListDefaults{CommitCharacters: true}
+
+			defaults := s.factorItemDefaults(test.items, nil)
+			var commitCharacters []string
+			if defaults != nil {
+				commitCharacters = defaults.CommitCharacters
+			}
+			if !reflect.DeepEqual(commitCharacters, test.wantCommitCharacters) {
+				t.Errorf("default commit characters = %v, want %v", commitCharacters, test.wantCommitCharacters)
+			}
+			for i, want := range test.wantItemCharacters {
+				if got := test.items[i].CommitCharacters; !reflect.DeepEqual(got, want) {
+					t.Errorf("item %d commit characters = %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompressCompletionData(t *testing.T) {
+	withData := func(data interface{}) CompletionItem {
+		return CompletionItem{CompletionItem: protocol.CompletionItem{Label: "item", Data: data}}
+	}
+	tests := []struct {
+		name           string
+		items          []CompletionItem
+		wantCompressed bool
+	}{
+		{
+			name:           "OmniSharp items from one request",
+			items:          []CompletionItem{withData(omnisharpData("speed", 4, 8)), withData(omnisharpData("health", 4, 8)), withData(nil)},
+			wantCompressed: true,
+		},
+		{
+			name: "Unity items keep their data",
+			items: []CompletionItem{
+				withData(omnisharpData("speed", 4, 8)), withData(omnisharpData("health", 4, 8)),
+				withData(&completionData{Source: completionSourceUnity, Name: "SerializeField"}),
+			},
+			wantCompressed: true,
+		},
+		{
+			name:  "OmniSharp items from different positions",
+			items: []CompletionItem{withData(omnisharpData("speed", 4, 8)), withData(omnisharpData("health", 5, 8))},
+		},
+		{
+			name:  "a single OmniSharp item",
+			items: []CompletionItem{withData(omnisharpData("speed", 4, 8))},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			origins := newCompletionOrigins()
+			before := make([]interface{}, len(test.items))
+			for i, item := range test.items {
+				if data, ok := item.Data.(*completionData); ok {
+					copied := *data
+					before[i] = &copied
+				}
+			}
+
+			compressCompletionData(test.items, origins)
+			for i, item := range test.items {
+				if before[i] == nil {
+					continue
+				}
+				data := item.Data.(*completionData)
+				if compressed := data.Origin != 0; data.Source == completionSourceOmniSharp && compressed != test.wantCompressed {
+					t.Errorf("item %d data = %+v, want compressed %v", i, data, test.wantCompressed)
+				}
+				// Each item keeps what identifies it, and gets the rest back when resolved
+				decoded, ok := decodeCompletionData(item.Data, origins)
+				if !ok || !reflect.DeepEqual(decoded, before[i]) {
+					t.Errorf("item %d data decodes to %+v, want %+v", i, decoded, before[i])
+				}
+			}
+		})
+	}
+}
+
+// TestCompressedCompletionDataKeepsListOrigin checks items resolve against the origin of their
+// own list after lists for other positions and documents arrived, and not once it is forgotten
+func TestCompressedCompletionDataKeepsListOrigin(t *testing.T) {
+	s := newServer(DefaultConfig())
+	list := func(fileName string, line uint32) []CompletionItem {
+		items := make([]CompletionItem, 2)
+		for i, text := range []string{"speed", "health"} {
+			data := omnisharpData(text, line, 8)
+			data.FileName = fileName
+			items[i] = CompletionItem{CompletionItem: protocol.CompletionItem{Label: text, Data: data}}
+		}
+		compressCompletionData(items, s.completionOrigins)
+		return items
+	}
+
+	first := list("/project/Assets/Player.cs", 4)
+	list("/project/Assets/Player.cs", 9)
+	list("/project/Assets/Enemy.cs", 2)
+	decoded, ok := decodeCompletionData(first[0].Data, s.completionOrigins)
+	if !ok || decoded.FileName != "/project/Assets/Player.cs" || decoded.Line != 4 || decoded.Column != 8 {
+		t.Errorf("item of the first list decodes to %+v, %v; want Player.cs:4:8", decoded, ok)
+	}
+
+	for i := 0; i < completionOriginsKept; i++ {
+		list("/project/Assets/Enemy.cs", 2)
+	}
+	if decoded, ok := decodeCompletionData(first[0].Data, s.completionOrigins); ok {
+		t.Errorf("item of a forgotten list decodes to %+v", decoded)
+	}
+}
+
+// TestCompressedCompletionDataResolves sends an OmniSharp list through the wire and back, as the
+// client resolves one of its items
+func TestCompressedCompletionDataResolves(t *testing.T) {
+	items := []AutoCompleteResponse{
+		{CompletionText: "speed", DisplayText: "speed", Kind: "Field", Description: "float Player.speed"},
+		{CompletionText: "health", DisplayText: "health", Kind: "Field", Description: "int Player.health"},
+		{CompletionText: "Jump", DisplayText: "Jump()", Kind: "Method", Description: "void Player.Jump()"},
+	}
+	fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": items})
+	s, _ := newTestServer(t, fake)
+	uri := testURI(s, "Player.cs")
+	openTestDocument(s, uri, "class Player { void Update() { } }\n")
+
+	list := completeAt(t, s, uri, protocol.Position{Line: 0, Character: 31}, protocol.CompletionTriggerKindInvoked)
+	encoded, err := json.Marshal(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(encoded), uri.Filename()) {
+		t.Errorf("the file name is sent: %s", encoded)
+	}
+
+	var sent struct {
+		Items []CompletionItem `json:"items"`
+	}
+	if err := json.Unmarshal(encoded, &sent); err != nil {
+		t.Fatal(err)
+	}
+	var jump *CompletionItem
+	for i := range sent.Items {
+		if sent.Items[i].Label == "Jump()" {
+			jump = &sent.Items[i]
+		}
+	}
+	if jump == nil {
+		t.Fatalf("no Jump() among %v", labels(sent.Items))
+	}
+	fake.setResponse("/autocomplete", []AutoCompleteResponse{
+		{CompletionText: "Jump", DisplayText: "Jump()", Documentation: "Jumps once"},
+	})
+	resolved, err := s.handleCompletionResolve(context.Background(), jump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if documentation, _ := json.Marshal(resolved.Documentation); !strings.Contains(string(documentation), "Jumps once") {
+		t.Errorf("documentation = %s", documentation)
+	}
+
+	fake.mu.Lock()
+	bodies := fake.bodies["/autocomplete"]
+	fake.mu.Unlock()
+	var request struct {
+		FileName     string
+		Line, Column uint32
+	}
+	if err := json.Unmarshal(bodies[len(bodies)-1], &request); err != nil {
+		t.Fatal(err)
+	}
+	if request.FileName != uri.Filename() || request.Line != 0 || request.Column != 31 {
+		t.Errorf("resolved at %+v, want %s:0:31", request, uri.Filename())
+	}
+}
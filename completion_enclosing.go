@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// codeStructureTypeKinds are the /v2/codestructure kinds of type declarations
+var codeStructureTypeKinds = map[string]bool{
+	"class": true, "struct": true, "interface": true, "record": true, "recordStruct": true, "enum": true,
+}
+
+// enclosingTypes returns the names of the types declared around pos, innermost first, as
+// OmniSharp's code structure of the document has them, or as the buffer has the innermost
+// one while OmniSharp has no answer
+func (s *Server) enclosingTypes(ctx context.Context, omnisharp *OmniSharpClient, doc Document, pos protocol.Position) []string {
+	if omnisharp != nil {
+		if elements, err := s.codeStructure(ctx, omnisharp, doc.URI); err == nil {
+			var names []string
+			for containing := elements; ; {
+				found := false
+				for _, element := range containing {
+					full := element.Ranges.Full.toProtocol()
+					if positionBefore(pos, full.Start) || !positionBefore(pos, full.End) {
+						continue
+					}
+					if codeStructureTypeKinds[element.Kind] {
+						names = append([]string{element.Name}, names...)
+					}
+					containing, found = element.Children, true
+					break
+				}
+				if !found {
+					break
+				}
+			}
+			return names
+		} else if ctx.Err() == nil {
+			log.Printf("failed to read the code structure for ranking completions: %v", err)
+		}
+	}
+	if name := enclosingTypeName(doc.Text, offsetAt(doc.Text, pos)); name != "" {
+		return []string{name}
+	}
+	return nil
+}
+
+// codeStructure returns the /v2/codestructure elements of uri, cached until its buffer changes
+func (s *Server) codeStructure(ctx context.Context, omnisharp *OmniSharpClient, uri protocol.DocumentURI) ([]CodeElement, error) {
+	if cached, ok := s.cache.get("codestructure", uri, protocol.Position{}); ok {
+		return cached.([]CodeElement), nil
+	}
+	generation := s.cache.generation(uri)
+	response, err := omnisharp.SendRequest(ctx, "/v2/codestructure", map[string]interface{}{
+		"FileName": uri.Filename(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var structure struct {
+		Elements []CodeElement `json:"Elements"`
+	}
+	if err := json.Unmarshal(response, &structure); err != nil {
+		return nil, err
+	}
+	s.cache.put("codestructure", uri, generation, protocol.Position{}, structure.Elements)
+	return structure.Elements, nil
+}
+
+// rankEnclosingTypeCompletions ranks the OmniSharp items close to the caret above the others of
+// equal relevance: locals and parameters, and the members of the types being edited, which
+// the caret's code most likely uses over inherited and framework members
+func rankEnclosingTypeCompletions(items []CompletionItem, enclosing []string) []CompletionItem {
+	if len(enclosing) == 0 {
+		return items
+	}
+	for i, item := range items {
+		data, ok := item.Data.(*completionData)
+		if !ok || data.Source != completionSourceOmniSharp {
+			continue
+		}
+		if strings.HasPrefix(data.Symbol, "(local") || strings.HasPrefix(data.Symbol, "(parameter)") ||
+			containsString(enclosing, declaringType(data.Symbol, data.CompletionText)) {
+			items[i].SortText = "0" + item.SortText
+		} else {
+			items[i].SortText = "1" + item.SortText
+		}
+	}
+	return items
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+// enclosingText nests Inventory in Player in the Game namespace, as enclosingStructure has them
+const enclosingText = "namespace Game\n" +
+	"{\n" +
+	"    class Player\n" +
+	"    {\n" +
+	"        int health;\n" +
+	"        class Inventory\n" +
+	"        {\n" +
+	"            void Add()\n" +
+	"            {\n" +
+	"                \n" +
+	"            }\n" +
+	"        }\n" +
+	"        void Update()\n" +
+	"        {\n" +
+	"            \n" +
+	"        }\n" +
+	"    }\n" +
+	"}\n"
+
+const enclosingStructure = `{"Elements": [{
+	"Kind": "namespace", "Name": "Game", "Ranges": {"full": {"Start": {"Line": 0, "Column": 0}, "End": {"Line": 17, "Column": 1}}},
+	"Children": [{
+		"Kind": "class", "Name": "Player", "Ranges": {"full": {"Start": {"Line": 2, "Column": 4}, "End": {"Line": 16, "Column": 5}}},
+		"Children": [
+			{"Kind": "field", "Name": "health", "Ranges": {"full": {"Start": {"Line": 4, "Column": 8}, "End": {"Line": 4, "Column": 19}}}},
+			{"Kind": "class", "Name": "Inventory", "Ranges": {"full": {"Start": {"Line": 5, "Column": 8}, "End": {"Line": 11, "Column": 9}}},
+				"Children": [{"Kind": "method", "Name": "Add", "Ranges": {"full": {"Start": {"Line": 7, "Column": 12}, "End": {"Line": 10, "Column": 13}}}}]},
+			{"Kind": "method", "Name": "Update", "Ranges": {"full": {"Start": {"Line": 12, "Column": 8}, "End": {"Line": 15, "Column": 9}}}}
+		]
+	}]
+}]}`
+
+func TestEnclosingTypes(t *testing.T) {
+	tests := []struct {
+		name      string
+		omnisharp bool
+		pos       protocol.Position
+		want      []string
+	}{
+		{"nested", true, protocol.Position{Line: 9, Character: 16}, []string{"Inventory", "Player"}},
+		{"in a method", true, protocol.Position{Line: 14, Character: 12}, []string{"Player"}},
+		{"in the namespace", true, protocol.Position{Line: 1, Character: 0}, nil},
+		{"without OmniSharp", false, protocol.Position{Line: 9, Character: 16}, []string{"Inventory"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var structure interface{}
+			if err := json.Unmarshal([]byte(enclosingStructure), &structure); err != nil {
+				t.Fatal(err)
+			}
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/v2/codestructure": structure})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, enclosingText)
+			doc, _ := s.documents.Get(uri)
+			omnisharp := s.completionBackend()
+			if !test.omnisharp {
+				omnisharp = nil
+			}
+
+			if got := s.enclosingTypes(context.Background(), omnisharp, doc, test.pos); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("enclosingTypes = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestEnclosingTypeRanking checks identifier completion ranks locals and the members of the
+// types around the caret above inherited and framework members, and member access doesn't
+func TestEnclosingTypeRanking(t *testing.T) {
+	items := []AutoCompleteResponse{
+		{CompletionText: "enabled", DisplayText: "enabled", Kind: "Property", Description: "bool UnityEngine.Behaviour.enabled { get; set; }"},
+		{CompletionText: "health", DisplayText: "health", Kind: "Property", Description: "int Game.Player.health"},
+		{CompletionText: "Add", DisplayText: "Add", Kind: "Property", Description: "void Game.Player.Inventory.Add()"},
+		{CompletionText: "amount", DisplayText: "amount", Kind: "Property", Description: "(local variable) int amount"},
+	}
+	tests := []struct {
+		name string
+		pos  protocol.Position
+		// typed is inserted at pos before completing
+		typed string
+		want  []string
+	}{
+		{"in Player", protocol.Position{Line: 14, Character: 12}, "", []string{"amount", "health", "Add", "enabled"}},
+		{"in Inventory", protocol.Position{Line: 9, Character: 16}, "", []string{"Add", "amount", "health", "enabled"}},
+		{"member access", protocol.Position{Line: 14, Character: 12}, "other.", []string{"Add", "amount", "enabled", "health"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var structure interface{}
+			if err := json.Unmarshal([]byte(enclosingStructure), &structure); err != nil {
+				t.Fatal(err)
+			}
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/v2/codestructure": structure, "/autocomplete": items})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			offset := offsetAt(enclosingText, test.pos)
+			openTestDocument(s, uri, enclosingText[:offset]+test.typed+enclosingText[offset:])
+
+			caret := test.pos
+			caret.Character += uint32(len(test.typed))
+			list := completeAt(t, s, uri, caret, protocol.CompletionTriggerKindInvoked)
+			if got := labels(list.Items); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("completions = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
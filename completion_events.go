@@ -0,0 +1,432 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go.lsp.dev/protocol"
+)
+
+var (
+	// eventSubscription matches text ending in the += of a subscription, capturing the member
+	// subscribed to and its qualifiers
+	eventSubscription = regexp.MustCompile(`(?:^|[^\w.])((?:@?[A-Za-z_]\w*\s*\.\s*)*)(@?[A-Za-z_]\w*)\s*\+=\s*$`)
+	// qualifierName strips the namespace and enclosing types from the names of a type
+	qualifierName = regexp.MustCompile(`\b(?:[A-Za-z_]\w*\.)+`)
+	// methodParameters captures the parameter list of a method's description
+	methodParameters = regexp.MustCompile(`\(([^()]*)\)`)
+)
+
+// delegateSignature is what a handler of an event takes and returns
+type delegateSignature struct {
+	// delegate is the type of the event, as /typelookup shows it
+	delegate   string
+	returnType string
+	// parameters are the parameter types and names, and any ref, out or in modifiers
+	parameters []delegateParameter
+}
+
+type delegateParameter struct {
+	modifier, typeName, name string
+}
+
+// subscriptionContext is an event subscription being completed
+type subscriptionContext struct {
+	signature delegateSignature
+	// event is the member subscribed to, and receiver the qualifier naming what it is on
+	event, receiver string
+}
+
+// delegateParameters names the parameters of the built-in delegates the way Visual Studio's
+// generated handlers do
+func delegateParameters(types []string) []delegateParameter {
+	parameters := make([]delegateParameter, len(types))
+	for i, typeName := range types {
+		name := "obj"
+		if len(types) > 1 {
+			name = "arg" + strconv.Itoa(i+1)
+		}
+		parameters[i] = delegateParameter{typeName: strings.TrimSpace(typeName), name: name}
+	}
+	return parameters
+}
+
+// eventSubscriptionAt returns the event subscribed to with += before offset, ignoring the
+// identifier being typed, with the signature of its delegate. The delegate is the type
+// /typelookup reports for the member, and custom delegates are read from their declaration
+func (s *Server) eventSubscriptionAt(ctx context.Context, doc Document, pos protocol.Position) (*subscriptionContext, bool) {
+	omnisharp := s.completionBackend()
+	if omnisharp == nil {
+		return nil, false
+	}
+	offset := offsetAt(doc.Text, pos)
+	before := doc.Text[:offset]
+	before = before[:len(before)-len(identifierBefore(before))]
+	match := eventSubscription.FindStringSubmatchIndex(before)
+	if match == nil || tokenClassAt(doc.Text, match[4]) != tokenCode {
+		return nil, false
+	}
+
+	request := omnisharpPosition(doc.URI, positionAt(doc.Text, match[4]))
+	response, err := omnisharp.SendRequest(ctx, "/typelookup", request)
+	if err != nil {
+		log.Printf("failed to look up the event subscribed to: %v", err)
+		return nil, false
+	}
+	var lookup TypeLookupResponse
+	if err := json.Unmarshal(response, &lookup); err != nil || lookup.Type == "" {
+		return nil, false
+	}
+
+	signature, ok := s.delegateSignature(ctx, omnisharp, memberType(lookup.Type))
+	if !ok {
+		return nil, false
+	}
+	qualifiers := strings.Split(strings.Join(strings.Fields(doc.Text[match[2]:match[3]]), ""), ".")
+	receiver := ""
+	if len(qualifiers) > 1 && qualifiers[len(qualifiers)-2] != "this" {
+		receiver = strings.TrimPrefix(qualifiers[len(qualifiers)-2], "@")
+	}
+	return &subscriptionContext{
+		signature: signature,
+		event:     strings.TrimPrefix(doc.Text[match[4]:match[5]], "@"),
+		receiver:  receiver,
+	}, true
+}
+
+// memberType returns the type in the /typelookup description of an event, field or property,
+// such as Action<int> in "event Action<int> Player.Damaged"
+func memberType(description string) string {
+	description = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(description), "event "))
+	depth := 0
+	for i := len(description) - 1; i >= 0; i-- {
+		switch description[i] {
+		case '>', ')', ']':
+			depth++
+		case '<', '(', '[':
+			depth--
+		case ' ':
+			if depth == 0 {
+				return strings.TrimSpace(description[:i])
+			}
+		}
+	}
+	return ""
+}
+
+// delegateSignature returns the signature of the delegate type typeName: Action, Func,
+// EventHandler and UnityAction are known, other delegates are looked up in the workspace
+func (s *Server) delegateSignature(ctx context.Context, omnisharp *OmniSharpClient, typeName string) (delegateSignature, bool) {
+	typeName = strings.TrimSuffix(typeName, "?")
+	name, arguments, generic := strings.Cut(qualifierName.ReplaceAllString(typeName, ""), "<")
+	var types []string
+	if generic {
+		types = splitParameters(strings.TrimSuffix(arguments, ">"))
+	}
+
+	signature := delegateSignature{delegate: typeName, returnType: "void"}
+	switch name {
+	case "Action", "UnityAction":
+		signature.parameters = delegateParameters(types)
+	case "Func":
+		if len(types) == 0 {
+			return delegateSignature{}, false
+		}
+		signature.returnType = strings.TrimSpace(types[len(types)-1])
+		signature.parameters = delegateParameters(types[:len(types)-1])
+	case "EventHandler":
+		argsType := "EventArgs"
+		if len(types) == 1 {
+			argsType = strings.TrimSpace(types[0])
+		}
+		signature.parameters = []delegateParameter{{typeName: "object", name: "sender"}, {typeName: argsType, name: "e"}}
+	default:
+		// Generic custom delegates would need their type arguments substituted
+		if generic || csharpBuiltinTypes[name] {
+			return delegateSignature{}, false
+		}
+		declared, ok := s.declaredDelegate(ctx, omnisharp, name)
+		if !ok {
+			return delegateSignature{}, false
+		}
+		declared.delegate = typeName
+		return declared, true
+	}
+	return signature, true
+}
+
+// delegateDeclaration captures the return type, name and parameters of a delegate declaration
+var delegateDeclaration = regexp.MustCompile(`\bdelegate\s+([\w.<>\[\]?, ]+?)\s+(@?[A-Za-z_]\w*)\s*\(([^)]*)\)`)
+
+// declaredDelegate reads the signature of the delegate named name from its declaration in the
+// workspace
+func (s *Server) declaredDelegate(ctx context.Context, omnisharp *OmniSharpClient, name string) (delegateSignature, bool) {
+	found, err := findSymbols(ctx, omnisharp, name)
+	if err != nil {
+		log.Printf("failed to find the delegate %s: %v", name, err)
+		return delegateSignature{}, false
+	}
+	for _, symbol := range found {
+		if symbol.Kind != "Delegate" || symbolName(symbol.Text) != name || symbol.FileName == "" {
+			continue
+		}
+		uri := pathToURI(s.resolveOmniSharpPath(symbol.FileName))
+		var text string
+		if doc, ok := s.documents.Get(uri); ok {
+			text = doc.Text
+		} else if data, err := os.ReadFile(uri.Filename()); err == nil {
+			text = string(data)
+		} else {
+			continue
+		}
+		for _, match := range delegateDeclaration.FindAllStringSubmatch(text, -1) {
+			if strings.TrimPrefix(match[2], "@") != name {
+				continue
+			}
+			signature := delegateSignature{returnType: strings.TrimSpace(match[1])}
+			for _, parameter := range splitParameters(match[3]) {
+				fields := strings.Fields(parameter)
+				if len(fields) < 2 {
+					return delegateSignature{}, false
+				}
+				declared := delegateParameter{name: fields[len(fields)-1]}
+				if modifier := fields[0]; modifier == "ref" || modifier == "out" || modifier == "in" || modifier == "params" {
+					declared.modifier, fields = modifier, fields[1:]
+				}
+				declared.typeName = strings.Join(fields[:len(fields)-1], " ")
+				signature.parameters = append(signature.parameters, declared)
+			}
+			return signature, true
+		}
+	}
+	return delegateSignature{}, false
+}
+
+// lambdaParameters formats the parameters of a lambda handling the event, typed only where ref,
+// out or in require it
+func (d delegateSignature) lambdaParameters(snippet bool) string {
+	typed := false
+	for _, parameter := range d.parameters {
+		typed = typed || (parameter.modifier != "" && parameter.modifier != "params")
+	}
+	parts := make([]string, len(d.parameters))
+	for i, parameter := range d.parameters {
+		name := parameter.name
+		if snippet {
+			name = "${" + strconv.Itoa(i+1) + ":" + name + "}"
+		}
+		if typed {
+			name = strings.TrimSpace(parameter.modifier+" "+parameter.typeName) + " " + name
+		}
+		parts[i] = name
+	}
+	if len(parts) == 1 && !typed {
+		return parts[0]
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// declaredParameters formats the parameter list of a method handling the event
+func (d delegateSignature) declaredParameters() string {
+	parts := make([]string, len(d.parameters))
+	for i, parameter := range d.parameters {
+		parts[i] = strings.TrimSpace(parameter.modifier+" "+parameter.typeName) + " " + parameter.name
+	}
+	return strings.Join(parts, ", ")
+}
+
+// compatible reports whether a method with the description of an /autocomplete item, such as
+// "void Player.OnHit(int amount)", and returnType can handle the event. Type names are compared
+// without their qualifiers
+func (d delegateSignature) compatible(description, returnType string) bool {
+	if normalizeTypeName(returnType) != normalizeTypeName(d.returnType) {
+		return false
+	}
+	match := methodParameters.FindStringSubmatch(description)
+	if match == nil {
+		return false
+	}
+	parameters := splitParameters(match[1])
+	if len(parameters) != len(d.parameters) {
+		return false
+	}
+	for i, parameter := range parameters {
+		fields := strings.Fields(parameter)
+		if len(fields) < 2 {
+			return false
+		}
+		expected := strings.TrimSpace(d.parameters[i].modifier + " " + d.parameters[i].typeName)
+		if normalizeTypeName(strings.Join(fields[:len(fields)-1], " ")) != normalizeTypeName(expected) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeTypeName(name string) string {
+	return strings.Join(strings.Fields(qualifierName.ReplaceAllString(name, "")), "")
+}
+
+// rankHandlerCompletions puts the methods that can handle the event subscribed to first,
+// keeping the order within both groups
+func rankHandlerCompletions(items []CompletionItem, subscription *subscriptionContext) []CompletionItem {
+	if subscription == nil {
+		return items
+	}
+	for i, item := range items {
+		data, _ := item.Data.(*completionData)
+		if item.Kind == protocol.CompletionItemKindMethod && data != nil && subscription.signature.compatible(data.Symbol, item.returnType) {
+			items[i].SortText = "0" + item.SortText
+		} else {
+			items[i].SortText = "1" + item.SortText
+		}
+	}
+	return items
+}
+
+// eventHandlerCompletions offers a lambda matching the delegate of the event subscribed to,
+// and a new method handling it, added at the end of the enclosing type
+func (s *Server) eventHandlerCompletions(doc Document, pos protocol.Position, subscription *subscriptionContext) []CompletionItem {
+	if subscription == nil {
+		return nil
+	}
+	signature := subscription.signature
+	body := "\n{\n" + s.indentUnit() + "$0\n}"
+	items := []CompletionItem{{
+		CompletionItem: protocol.CompletionItem{
+			Label:            signature.lambdaParameters(false) + " => { }",
+			Kind:             protocol.CompletionItemKindSnippet,
+			Detail:           "lambda handling " + signature.delegate,
+			InsertText:       signature.lambdaParameters(true) + " =>" + body,
+			InsertTextFormat: protocol.InsertTextFormatSnippet,
+		},
+		TextEditText: signature.lambdaParameters(true) + " =>" + body,
+	}}
+
+	if handler, ok := s.handlerMethodItem(doc, pos, subscription); ok {
+		items = append(items, handler)
+	}
+	return items
+}
+
+// handlerMethodItem subscribes a new method named after the event, such as OnPlayerDied for
+// player.Died, declaring it before the closing brace of the enclosing type. The method is static
+// if the member subscribing is
+func (s *Server) handlerMethodItem(doc Document, pos protocol.Position, subscription *subscriptionContext) (CompletionItem, bool) {
+	offset := offsetAt(doc.Text, pos)
+	open, close, ok := enclosingTypeBraces(doc.Text, offset)
+	if !ok {
+		return CompletionItem{}, false
+	}
+	closeLine := positionAt(doc.Text, close)
+	lineStart := offsetAt(doc.Text, protocol.Position{Line: closeLine.Line})
+	if strings.TrimSpace(doc.Text[lineStart:close]) != "" {
+		return CompletionItem{}, false
+	}
+	indent := doc.Text[lineStart:close] + s.indentUnit()
+
+	event := strings.TrimPrefix(subscription.event, "On")
+	if r, _ := utf8.DecodeRuneInString(event); event == "" || !unicode.IsUpper(r) {
+		event = subscription.event
+	}
+	base := "On" + capitalize(subscription.receiver) + capitalize(event)
+	name := base
+	for i := 2; declaresMethod(doc.Text, name); i++ {
+		name = base + strconv.Itoa(i)
+	}
+
+	signature := subscription.signature
+	declaration := "private "
+	if memberHeaderIsStatic(doc.Text, open, offset) {
+		declaration += "static "
+	}
+	declaration += signature.returnType + " " + name + "(" + signature.declaredParameters() + ")"
+	body := ""
+	if signature.returnType != "void" {
+		body = indent + s.indentUnit() + "return default;\n"
+	}
+	return CompletionItem{
+		CompletionItem: protocol.CompletionItem{
+			Label:      name,
+			Kind:       protocol.CompletionItemKindMethod,
+			Detail:     "new handler " + declaration,
+			InsertText: name,
+			AdditionalTextEdits: []protocol.TextEdit{{
+				Range:   protocol.Range{Start: positionAt(doc.Text, lineStart), End: positionAt(doc.Text, lineStart)},
+				NewText: "\n" + indent + declaration + "\n" + indent + "{\n" + body + indent + "}\n",
+			}},
+		},
+		TextEditText: name,
+	}, true
+}
+
+// declaresMethod reports whether text already has a method, or a call, named name
+func declaresMethod(text, name string) bool {
+	return regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\s*[(<]`).MatchString(text)
+}
+
+func capitalize(name string) string {
+	if name == "" {
+		return ""
+	}
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[size:]
+}
+
+// enclosingTypeBraces returns the offsets of the braces of the innermost type body around
+// offset
+func enclosingTypeBraces(text string, offset int) (int, int, bool) {
+	before := text[:offset]
+	open := -1
+	for _, match := range typeDeclaration.FindAllStringSubmatchIndex(before, -1) {
+		brace := strings.IndexByte(before[match[1]:], '{')
+		if brace >= 0 && unclosed(before[match[1]+brace:]) {
+			open = match[1] + brace
+		}
+	}
+	if open < 0 {
+		return 0, 0, false
+	}
+	depth := braceDepth(text[open:offset])
+	for i := offset; i < len(text); i++ {
+		switch text[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return open, i, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// memberHeaderIsStatic reports whether the member of the type body opened at open that holds
+// offset is declared static
+func memberHeaderIsStatic(text string, open, offset int) bool {
+	brace := offset
+	for {
+		outer := openingBrace(text[:brace])
+		if outer <= open {
+			break
+		}
+		brace = outer
+	}
+	header := text[open+1 : brace]
+	if end := strings.LastIndexAny(header, ";}"); end >= 0 {
+		header = header[end+1:]
+	}
+	for _, word := range strings.Fields(header) {
+		if word == "static" {
+			return true
+		}
+	}
+	return false
+}
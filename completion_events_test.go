@@ -0,0 +1,201 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestMemberType(t *testing.T) {
+	tests := []struct {
+		description string
+		want        string
+	}{
+		{"event Action<int> Player.Damaged", "Action<int>"},
+		{"event EventHandler<DamageEventArgs> Game.Player.Hit", "EventHandler<DamageEventArgs>"},
+		{"UnityEvent<int, string> Player.onScore", "UnityEvent<int, string>"},
+		{"event Func<Dictionary<string, int>, bool> Player.Filter", "Func<Dictionary<string, int>, bool>"},
+		{"Action Player.onDeath", "Action"},
+		{"Player", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			if got := memberType(test.description); got != test.want {
+				t.Errorf("memberType = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDelegateSignature(t *testing.T) {
+	tests := []struct {
+		delegate string
+		// lambda, handler and returnType are the lambda's parameters, the new method's
+		// parameters and what it returns; an empty lambda for a delegate that isn't known
+		lambda, handler, returnType string
+	}{
+		{"Action", "()", "", "void"},
+		{"Action<int>", "obj", "int obj", "void"},
+		{"System.Action<int, string>", "(arg1, arg2)", "int arg1, string arg2", "void"},
+		{"UnityAction<float>", "obj", "float obj", "void"},
+		{"Func<int, bool>", "obj", "int obj", "bool"},
+		{"EventHandler", "(sender, e)", "object sender, EventArgs e", "void"},
+		{"EventHandler<DamageEventArgs>", "(sender, e)", "object sender, DamageEventArgs e", "void"},
+		{"Action<int>?", "obj", "int obj", "void"},
+		{"Func", "", "", ""},
+		{"int", "", "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.delegate, func(t *testing.T) {
+			s, _ := newTestServer(t, nil)
+			signature, ok := s.delegateSignature(nil, nil, test.delegate)
+			if !ok {
+				if test.lambda != "" {
+					t.Errorf("delegateSignature found none, want %s => and %s", test.lambda, test.handler)
+				}
+				return
+			}
+			if test.lambda == "" {
+				t.Fatalf("delegateSignature = %+v, want none", signature)
+			}
+			if got := signature.lambdaParameters(false); got != test.lambda {
+				t.Errorf("lambda parameters = %q, want %q", got, test.lambda)
+			}
+			if got := signature.declaredParameters(); got != test.handler || signature.returnType != test.returnType {
+				t.Errorf("handler = %s (%s), want %s (%s)", signature.returnType, got, test.returnType, test.handler)
+			}
+		})
+	}
+}
+
+func TestLambdaParametersTypedForRef(t *testing.T) {
+	signature := delegateSignature{returnType: "void", parameters: []delegateParameter{
+		{modifier: "ref", typeName: "int", name: "health"},
+		{typeName: "string", name: "source"},
+	}}
+	if got, want := signature.lambdaParameters(true), "(ref int ${1:health}, string ${2:source})"; got != want {
+		t.Errorf("lambdaParameters = %q, want %q", got, want)
+	}
+	if got, want := signature.declaredParameters(), "ref int health, string source"; got != want {
+		t.Errorf("declaredParameters = %q, want %q", got, want)
+	}
+}
+
+func TestDelegateCompatible(t *testing.T) {
+	signature := delegateSignature{returnType: "void", parameters: []delegateParameter{{typeName: "int", name: "obj"}}}
+	tests := []struct {
+		name        string
+		description string
+		returnType  string
+		want        bool
+	}{
+		{"matching", "private void Player.Heal(int amount)", "void", true},
+		{"qualified types", "private void Player.Heal(System.Int32 amount)", "System.Void", false},
+		{"other parameter type", "private void Player.Rename(string name)", "void", false},
+		{"other parameter count", "private void Player.Jump()", "void", false},
+		{"other return type", "private bool Player.Heal(int amount)", "bool", false},
+		{"not a method", "private int Player.health", "int", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := signature.compatible(test.description, test.returnType); got != test.want {
+				t.Errorf("compatible = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestEventSubscriptionCompletions checks a subscription with += is offered a lambda and a new
+// handler method matching the event's delegate, known or declared in the workspace, with the
+// existing methods that can handle it first
+func TestEventSubscriptionCompletions(t *testing.T) {
+	const declarations = "delegate bool DamageFilter(ref int amount, Enemy source);\n"
+	tests := []struct {
+		name   string
+		line   string
+		lookup string
+		// wantLambda, wantHandler and wantDeclaration are the lambda and the new method offered,
+		// none without a subscription or once a name is typed
+		wantLambda, wantHandler, wantDeclaration string
+		// wantFirst is the label of the first OmniSharp item, a method handling the event if any
+		wantFirst string
+	}{
+		{
+			name: "Action", line: "        player.Died += ", lookup: "event Action<int> Player.Died",
+			wantLambda: "obj =>\n        {\n            \n        }", wantHandler: "OnPlayerDied",
+			wantDeclaration: "private void OnPlayerDied(int obj)", wantFirst: "Heal",
+		},
+		{
+			name: "EventHandler on this", line: "        this.OnHit += ", lookup: "event EventHandler Spawner.OnHit",
+			wantLambda: "(sender, e) =>\n        {\n            \n        }", wantHandler: "OnHit",
+			wantDeclaration: "private void OnHit(object sender, EventArgs e)", wantFirst: "health",
+		},
+		{
+			name: "declared delegate", line: "        Filter += ", lookup: "event DamageFilter Spawner.Filter",
+			wantLambda: "(ref int amount, Enemy source) =>\n        {\n            \n        }", wantHandler: "OnFilter",
+			wantDeclaration: "private bool OnFilter(ref int amount, Enemy source)", wantFirst: "health",
+		},
+		// Typing a method's name leaves the compatible methods, first
+		{name: "handler begun", line: "        player.Died += H", lookup: "event Action<int> Player.Died", wantFirst: "Heal"},
+		{name: "not a subscription", line: "        var total = 1 + ", lookup: "int Spawner.total", wantFirst: "health"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{
+				"/typelookup": TypeLookupResponse{Type: test.lookup},
+				"/autocomplete": []AutoCompleteResponse{
+					{CompletionText: "health", DisplayText: "health", Kind: "Field", ReturnType: "int", Description: "private int Spawner.health"},
+					{CompletionText: "Jump", DisplayText: "Jump", Kind: "Method", ReturnType: "void", Description: "private void Spawner.Jump()"},
+					{CompletionText: "Heal", DisplayText: "Heal", Kind: "Method", ReturnType: "void", Description: "private void Spawner.Heal(int amount)"},
+				},
+			})
+			s, _ := newTestServer(t, fake)
+			uri, other := testURI(s, "Spawner.cs"), testURI(s, "DamageFilter.cs")
+			writeFiles(t, s.rootPath, map[string]string{"DamageFilter.cs": declarations})
+			fake.setResponse("/findsymbols", map[string]interface{}{"QuickFixes": []SymbolLocation{
+				{QuickFix: QuickFix{FileName: other.Filename(), Text: "DamageFilter"}, Kind: "Delegate"},
+			}})
+			text := "class Spawner\n{\n    void Start()\n    {\n" + test.line + "\n    }\n}\n"
+			openTestDocument(s, uri, text)
+
+			caret := protocol.Position{Line: 4, Character: uint32(len(test.line))}
+			list := completeAt(t, s, uri, caret, protocol.CompletionTriggerKindInvoked)
+			var lambda, handler *CompletionItem
+			var first string
+			for i, item := range list.Items {
+				switch {
+				case strings.HasPrefix(item.Detail, "lambda handling "):
+					lambda = &list.Items[i]
+				case strings.HasPrefix(item.Detail, "new handler "):
+					handler = &list.Items[i]
+				case first == "":
+					first = item.Label
+				}
+			}
+			if first != test.wantFirst {
+				t.Errorf("first OmniSharp item %s, want %s", first, test.wantFirst)
+			}
+			if test.wantLambda == "" {
+				if lambda != nil || handler != nil {
+					t.Errorf("offered %+v and %+v, want no handlers", lambda, handler)
+				}
+				return
+			}
+
+			if lambda == nil || lambda.InsertText != test.wantLambda {
+				t.Errorf("lambda = %+v, want %q", lambda, test.wantLambda)
+			}
+			if handler == nil {
+				t.Fatal("no handler method offered")
+			}
+			if handler.Label != test.wantHandler || len(handler.AdditionalTextEdits) != 1 {
+				t.Fatalf("handler = %+v, want %s declared", handler, test.wantHandler)
+			}
+			declared := applyContentChange(text, TextDocumentContentChangeEvent{Range: &handler.AdditionalTextEdits[0].Range, Text: handler.AdditionalTextEdits[0].NewText})
+			if !strings.Contains(declared, "    }\n\n    "+test.wantDeclaration+"\n    {\n") || !strings.HasSuffix(declared, "    }\n}\n") {
+				t.Errorf("declared the handler as %q, want %q before the closing brace", declared, test.wantDeclaration)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+)
+
+// implicitNumericConversions lists, for each built-in numeric type, the types it converts to
+// implicitly
+var implicitNumericConversions = map[string][]string{
+	"sbyte":  {"short", "int", "long", "float", "double", "decimal", "nint"},
+	"byte":   {"short", "ushort", "int", "uint", "long", "ulong", "float", "double", "decimal", "nint", "nuint"},
+	"short":  {"int", "long", "float", "double", "decimal", "nint"},
+	"ushort": {"int", "uint", "long", "ulong", "float", "double", "decimal", "nint", "nuint"},
+	"int":    {"long", "float", "double", "decimal", "nint"},
+	"uint":   {"long", "ulong", "float", "double", "decimal", "nuint"},
+	"long":   {"float", "double", "decimal"},
+	"ulong":  {"float", "double", "decimal"},
+	"char":   {"ushort", "int", "uint", "long", "ulong", "float", "double", "decimal", "nint", "nuint"},
+	"float":  {"double"},
+	"nint":   {"long", "float", "double", "decimal"},
+	"nuint":  {"ulong", "float", "double", "decimal"},
+}
+
+// expectedTypeAt returns the declared type of the variable the expression at offset
+// initializes, as for int x = |, or "" when there is none or it is inferred with var
+func expectedTypeAt(text string, offset int) string {
+	before := text[:offset]
+	match := declaredVariable.FindStringSubmatch(strings.TrimSuffix(before, identifierBefore(before)))
+	if match == nil || match[1] == "var" {
+		return ""
+	}
+	return match[1]
+}
+
+// typeCompatibility tells whether a value of type actual can initialize a variable of type
+// expected: 1 if it can, -1 if it clearly can't, being void or a built-in type without a
+// conversion to it, and 0 if it takes more than the names to tell
+func typeCompatibility(actual, expected string) int {
+	actual, expected = normalizeTypeName(actual), normalizeTypeName(expected)
+	// A nullable variable also takes the underlying type
+	underlying := strings.TrimSuffix(expected, "?")
+	switch {
+	case actual == "":
+		return 0
+	case actual == "void":
+		return -1
+	case actual == expected || actual == underlying:
+		return 1
+	case containsString(implicitNumericConversions[actual], underlying):
+		return 1
+	case expected == "object" || expected == "dynamic" || actual == "object" || actual == "dynamic":
+		return 0
+	case csharpBuiltinTypes[strings.TrimSuffix(actual, "?")] && csharpBuiltinTypes[underlying]:
+		return -1
+	}
+	return 0
+}
+
+// rankExpectedTypeCompletions ranks the members whose type fits the variable being initialized
+// first, and those that can't, such as void methods after int x =, last, keeping the order
+// within each group. Items OmniSharp reports no type for, such as types and keywords, stay
+// in between
+func rankExpectedTypeCompletions(items []CompletionItem, expected string) []CompletionItem {
+	if expected == "" {
+		return items
+	}
+	for i, item := range items {
+		switch typeCompatibility(item.returnType, expected) {
+		case 1:
+			items[i].SortText = "0" + item.SortText
+		case -1:
+			items[i].SortText = "2" + item.SortText
+		default:
+			items[i].SortText = "1" + item.SortText
+		}
+	}
+	return items
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"sort"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+// TestExpectedTypeRanksCompletions checks members returning the type of the variable being
+// initialized, or one converting to it, outrank void methods
+func TestExpectedTypeRanksCompletions(t *testing.T) {
+	items := []AutoCompleteResponse{
+		{CompletionText: "Jump", DisplayText: "Jump", Kind: "Method", ReturnType: "void"},
+		{CompletionText: "Awake", DisplayText: "Awake", Kind: "Method", ReturnType: "void"},
+		{CompletionText: "Rigidbody", DisplayText: "Rigidbody", Kind: "Class"},
+		{CompletionText: "health", DisplayText: "health", Kind: "Field", ReturnType: "int"},
+		{CompletionText: "CountEnemies", DisplayText: "CountEnemies", Kind: "Method", ReturnType: "int"},
+	}
+	tests := []struct {
+		name string
+		// code is the method body up to the caret
+		code string
+	}{
+		{"int", "int x = "},
+		{"int, partly typed", "int x = h"},
+		{"converted to float", "float x = "},
+		{"nullable", "int? x = "},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": items})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			text := "class Player { void Start() { " + test.code
+			openTestDocument(s, uri, text+" } }\n")
+
+			list := completeAt(t, s, uri, protocol.Position{Character: uint32(len(text))}, protocol.CompletionTriggerKindInvoked)
+			// Clients order by sortText
+			sort.SliceStable(list.Items, func(i, j int) bool { return list.Items[i].SortText < list.Items[j].SortText })
+			rank := make(map[string]int)
+			for i, item := range list.Items {
+				rank[item.Label] = i
+			}
+			for _, fits := range []string{"health", "CountEnemies"} {
+				if _, ok := rank[fits]; !ok {
+					continue
+				}
+				for _, void := range []string{"Jump", "Awake"} {
+					if _, ok := rank[void]; ok && rank[fits] > rank[void] {
+						t.Errorf("%s ranks below %s in %v", fits, void, labels(list.Items))
+					}
+				}
+			}
+			if _, ok := rank["health"]; !ok {
+				t.Errorf("completed %v, want health", labels(list.Items))
+			}
+		})
+	}
+}
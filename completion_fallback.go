@@ -0,0 +1,93 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// fallbackDetail marks the items guessed from the text of the file
+const fallbackDetail = "(text completion)"
+
+// csharpKeywords are the keywords offered when completing from the text of the file
+var csharpKeywords = []string{
+	"abstract", "as", "async", "await", "base", "bool", "break", "byte", "case", "catch", "char",
+	"class", "const", "continue", "default", "delegate", "do", "double", "else", "enum", "event",
+	"false", "finally", "float", "for", "foreach", "if", "in", "int", "interface", "internal",
+	"is", "long", "namespace", "new", "null", "out", "override", "private", "protected", "public",
+	"readonly", "ref", "return", "sealed", "static", "string", "struct", "switch", "this", "throw",
+	"true", "try", "typeof", "using", "var", "virtual", "void", "while", "yield",
+}
+
+var identifierPattern = regexp.MustCompile(`@?[A-Za-z_]\w*`)
+
+// likelyBroken reports whether the code around offset probably doesn't parse, as while typing:
+// the file's braces don't balance, or the statement at offset has unclosed parentheses or
+// brackets or an unterminated string
+func likelyBroken(text string, offset int) bool {
+	if braceDepth(text) != 0 {
+		return true
+	}
+	start := strings.LastIndexAny(text[:offset], ";{}") + 1
+	end := len(text)
+	if next := strings.IndexAny(text[offset:], ";{}"); next >= 0 {
+		end = offset + next
+	}
+	statement := text[start:end]
+	if strings.Count(statement, "(") != strings.Count(statement, ")") || strings.Count(statement, "[") != strings.Count(statement, "]") {
+		return true
+	}
+	return strings.Count(lineAt(text, uint32(strings.Count(text[:offset], "\n"))), `"`)%2 != 0
+}
+
+// fallbackCompletions offers the identifiers found elsewhere in text, for when OmniSharp has
+// nothing to offer in a buffer that doesn't parse. After a dot only names that follow a dot
+// somewhere are offered, since a member is expected; elsewhere keywords are offered too
+func fallbackCompletions(text string, offset int) []CompletionItem {
+	typed := identifierBefore(text[:offset])
+	start := offset - len(typed)
+	member := isMemberAccess(text, offset)
+
+	keywords := make(map[string]bool, len(csharpKeywords))
+	for _, keyword := range csharpKeywords {
+		keywords[keyword] = true
+	}
+
+	items := []CompletionItem{}
+	seen := make(map[string]bool)
+	for _, match := range identifierPattern.FindAllStringIndex(text, -1) {
+		name := text[match[0]:match[1]]
+		if match[0] == start || keywords[name] || seen[name] || len(name) < 2 {
+			continue
+		}
+		if match[0] > 0 && isIdentifierRune(rune(text[match[0]-1])) {
+			continue
+		}
+		if member && !strings.HasSuffix(strings.TrimRight(text[:match[0]], " \t"), ".") {
+			continue
+		}
+		seen[name] = true
+		items = append(items, fallbackItem(name, protocol.CompletionItemKindText, "0"))
+	}
+	if !member {
+		for _, keyword := range csharpKeywords {
+			items = append(items, fallbackItem(keyword, protocol.CompletionItemKindKeyword, "1"))
+		}
+	}
+	return items
+}
+
+// fallbackItem builds an item of fallbackCompletions. Names from the file sort before keywords
+func fallbackItem(name string, kind protocol.CompletionItemKind, sortText string) CompletionItem {
+	return CompletionItem{
+		CompletionItem: protocol.CompletionItem{
+			Label:      name,
+			Kind:       kind,
+			Detail:     fallbackDetail,
+			SortText:   sortText,
+			InsertText: name,
+		},
+		TextEditText: name,
+	}
+}
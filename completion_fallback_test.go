@@ -0,0 +1,118 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestLikelyBroken(t *testing.T) {
+	tests := []struct {
+		name string
+		// text has | at the caret
+		text string
+		want bool
+	}{
+		{"balanced", "class Player { void Update() { speed = | } }", false},
+		{"unclosed brace", "class Player { void Update() { speed = |", true},
+		{"unclosed parenthesis", "class Player { void Update() { Move(spe| } }", true},
+		{"unclosed bracket", "class Player { void Update() { var e = enemies[|; } }", true},
+		{"unterminated string", "class Player { void Update() { Debug.Log(\"hit |\n } }", true},
+		{"closed elsewhere", "class Player { void Update() { Move(1); Jump(|); } }", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			offset := strings.Index(test.text, "|")
+			text := strings.Replace(test.text, "|", "", 1)
+			if got := likelyBroken(text, offset); got != test.want {
+				t.Errorf("likelyBroken = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestFallbackCompletions checks a broken buffer OmniSharp completes nothing in is completed
+// from the identifiers of the file, marked as such
+func TestFallbackCompletions(t *testing.T) {
+	const declarations = "class Player { float speed; Enemy target; void Hit(Enemy other) { other.health -= 1; }\n"
+	tests := []struct {
+		name      string
+		before    string
+		after     string
+		omnisharp []AutoCompleteResponse
+		// want are labels completed, and absent labels that mustn't be
+		want, absent []string
+	}{
+		{
+			name:   "broken statement",
+			before: "void Update() { Move(sp",
+			after:  " } }",
+			want:   []string{"speed"},
+		},
+		{
+			name:   "keyword",
+			before: "void Update() { Move(ret",
+			after:  " } }",
+			want:   []string{"return"},
+		},
+		{
+			name:   "member",
+			before: "void Update() { Move(target.",
+			after:  " } }",
+			want:   []string{"health"},
+			absent: []string{"speed", "return"},
+		},
+		{
+			name:   "parsing",
+			before: "void Update() { sp",
+			after:  " } }",
+			absent: []string{"speed"},
+		},
+		{
+			name:      "OmniSharp completes",
+			before:    "void Update() { Move(sp",
+			after:     " } }",
+			omnisharp: autoCompleteItems("speed"),
+			want:      []string{"speed"},
+		},
+		{
+			name:   "comment",
+			before: "void Update() { Move( // sp",
+			after:  "\n } }",
+			absent: []string{"speed"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			omnisharp := test.omnisharp
+			if omnisharp == nil {
+				omnisharp = []AutoCompleteResponse{}
+			}
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": omnisharp})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, declarations+test.before+test.after+"\n")
+
+			list := completeAt(t, s, uri, protocol.Position{Line: 1, Character: uint32(len(test.before))}, protocol.CompletionTriggerKindInvoked)
+			completed := make(map[string]CompletionItem)
+			for _, item := range list.Items {
+				completed[item.Label] = item
+			}
+			for _, label := range test.want {
+				item, ok := completed[label]
+				if !ok {
+					t.Errorf("completed %v, want %s", labels(list.Items), label)
+				}
+				if fromText := item.Detail == fallbackDetail; ok && fromText != (test.omnisharp == nil) {
+					t.Errorf("%s detail = %q, want it marked as text completion: %v", label, item.Detail, test.omnisharp == nil)
+				}
+			}
+			for _, label := range test.absent {
+				if _, ok := completed[label]; ok {
+					t.Errorf("completed %s", label)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// formatSpecifier is a format string offered in the format clause of an interpolation hole
+type formatSpecifier struct {
+	text    string
+	detail  string
+	example string
+}
+
+// formatSpecifiers are the common numeric and date and time format strings
+var formatSpecifiers = []formatSpecifier{
+	{"F2", "Fixed-point with 2 decimals", "3.14159 → 3.14"},
+	{"F0", "Fixed-point without decimals", "3.6 → 4"},
+	{"N0", "Number with group separators, no decimals", "1234.5 → 1,235"},
+	{"N2", "Number with group separators and 2 decimals", "1234.5 → 1,234.50"},
+	{"P", "Percent", "0.25 → 25.00 %"},
+	{"P0", "Percent without decimals", "0.25 → 25 %"},
+	{"C", "Currency", "9.5 → $9.50"},
+	{"D", "Decimal integer", "42 → 42"},
+	{"D3", "Decimal integer padded to 3 digits", "7 → 007"},
+	{"X", "Hexadecimal", "255 → FF"},
+	{"X8", "Hexadecimal padded to 8 digits", "255 → 000000FF"},
+	{"E", "Exponential", "1234.5 → 1.234500E+003"},
+	{"G", "General, the shortest of fixed-point and exponential", "1234.5 → 1234.5"},
+	{"R", "Round-trip", "0.1f → 0.1"},
+	{"0.00", "Custom: at least one integer digit and 2 decimals", "0.5 → 0.50"},
+	{"#,##0", "Custom: group separators, no decimals", "1234.5 → 1,235"},
+	{"d", "Short date", "2024-06-01 → 6/1/2024"},
+	{"D", "Long date", "2024-06-01 → Saturday, June 1, 2024"},
+	{"t", "Short time", "14:05 → 2:05 PM"},
+	{"T", "Long time", "14:05:09 → 2:05:09 PM"},
+	{"g", "Short date and time", "6/1/2024 2:05 PM"},
+	{"o", "Round-trip date and time", "2024-06-01T14:05:09.0000000"},
+	{"yyyy-MM-dd", "Custom: ISO date", "2024-06-01"},
+	{"HH:mm:ss", "Custom: 24-hour time", "14:05:09"},
+}
+
+// inFormatClause reports whether offset is in the format clause of an interpolation hole, after
+// the colon of $"{value:F2}", reporting where the clause starts
+func inFormatClause(text string, offset int) (int, bool) {
+	if offset == 0 {
+		return 0, false
+	}
+	// The caret sits before the closing brace, which belongs to the string again
+	hole, ok := interpolationHoleAt(text, offset-1)
+	if !ok {
+		return 0, false
+	}
+
+	depth := 0
+	for i := hole; i < offset; i++ {
+		switch text[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ':':
+			// A colon outside parentheses starts the format; ?: must be parenthesized in holes
+			if depth == 0 {
+				return i + 1, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// formatSpecifierCompletions offers the formatSpecifiers starting with what has been typed
+// of the clause, replacing it: specifiers such as yyyy-MM-dd aren't identifiers, so the
+// client's word range won't do. Numeric and date formats sharing a letter, such as D, are
+// both offered, since the type of the value isn't known here
+func formatSpecifierCompletions(text string, clause int, pos protocol.Position) []CompletionItem {
+	typed := text[clause:offsetAt(text, pos)]
+	if strings.Contains(typed, "\n") {
+		return nil
+	}
+	start := pos
+	start.Character -= byteToUTF16Offset(typed, len(typed))
+
+	items := []CompletionItem{}
+	for i, specifier := range formatSpecifiers {
+		if !strings.HasPrefix(strings.ToLower(specifier.text), strings.ToLower(typed)) {
+			continue
+		}
+		items = append(items, CompletionItem{
+			CompletionItem: protocol.CompletionItem{
+				Label:         specifier.text,
+				Kind:          protocol.CompletionItemKindValue,
+				Detail:        specifier.detail,
+				Documentation: specifier.example,
+				// Keeps the order of formatSpecifiers, most common first
+				SortText:   fmt.Sprintf("%02d", i),
+				InsertText: specifier.text,
+			},
+			TextEdit: &protocol.TextEdit{Range: protocol.Range{Start: start, End: pos}, NewText: specifier.text},
+		})
+	}
+	return items
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestInFormatClause(t *testing.T) {
+	tests := []struct {
+		name string
+		// text has | at the caret
+		text string
+		// clause is the text from the start of the clause to the caret
+		clause string
+		want   bool
+	}{
+		{"after the colon", `var s = $"{value:|}";`, "", true},
+		{"format begun", `var s = $"{value:F|}";`, "F", true},
+		{"custom format", `var s = $"{time:yyyy-MM-|}";`, "yyyy-MM-", true},
+		{"second hole", `var s = $"{a} and {b:N|}";`, "N", true},
+		{"verbatim", `var s = $@"{value:P|}";`, "P", true},
+		{"parenthesized conditional", `var s = $"{(ok ? a : b):F|}";`, "F", true},
+		{"before the colon", `var s = $"{val|ue:F2}";`, "", false},
+		{"hole without format", `var s = $"{value|}";`, "", false},
+		{"plain string", `var s = "{value:|}";`, "", false},
+		{"interpolated text", `var s = $"time: |{value}";`, "", false},
+		{"code", `var s = ok ? a :|`, "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			offset := strings.Index(test.text, "|")
+			text := strings.Replace(test.text, "|", "", 1)
+			clause, ok := inFormatClause(text, offset)
+			if ok != test.want {
+				t.Fatalf("inFormatClause = %v, want %v", ok, test.want)
+			}
+			if ok && text[clause:offset] != test.clause {
+				t.Errorf("clause = %q, want %q", text[clause:offset], test.clause)
+			}
+		})
+	}
+}
+
+// TestFormatSpecifierCompletions checks the format specifiers are offered after the colon of an
+// interpolation hole, replacing what has been typed of the format, without asking OmniSharp
+func TestFormatSpecifierCompletions(t *testing.T) {
+	tests := []struct {
+		name string
+		// before is the line up to the caret, which is followed by }";
+		before string
+		want   []string
+	}{
+		{"after the colon", `    string Label() => $"{speed:`, []string{"F2", "F0", "N0", "N2", "P", "P0", "C", "D", "D3", "X", "X8", "E", "G", "R", "0.00", "#,##0", "d", "D", "t", "T", "g", "o", "yyyy-MM-dd", "HH:mm:ss"}},
+		{"format begun", `    string Label() => $"{speed:f`, []string{"F2", "F0"}},
+		{"custom format begun", `    string Label() => $"{time:yyyy-`, []string{"yyyy-MM-dd"}},
+		{"no match", `    string Label() => $"{speed:Q`, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": autoCompleteItems("speed")})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, "class Player {\n"+test.before+"}\";\n}\n")
+
+			caret := protocol.Position{Line: 1, Character: uint32(len(test.before))}
+			list := completeAt(t, s, uri, caret, protocol.CompletionTriggerKindInvoked)
+			if got := labels(list.Items); strings.Join(got, " ") != strings.Join(test.want, " ") {
+				t.Errorf("completions = %v, want %v", got, test.want)
+			}
+			if calls := fake.callCount("/autocomplete"); calls != 0 {
+				t.Errorf("asked OmniSharp %d times, want none", calls)
+			}
+
+			typed := uint32(len(test.before) - strings.LastIndex(test.before, ":") - 1)
+			for _, item := range list.Items {
+				want := protocol.Range{Start: protocol.Position{Line: 1, Character: caret.Character - typed}, End: caret}
+				if edit, ok := item.TextEdit.(*protocol.TextEdit); !ok || edit.Range != want || edit.NewText != item.Label {
+					t.Errorf("%s edits %+v, want %s over %+v", item.Label, item.TextEdit, item.Label, want)
+				}
+			}
+		})
+	}
+}
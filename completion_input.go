@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+var (
+	// inputAxisArgument matches a caret in the string argument of the Input Manager's methods
+	// taking an axis or button name
+	inputAxisArgument = regexp.MustCompile(`\bInput\s*\.\s*(?:GetAxis|GetAxisRaw|GetButton|GetButtonDown|GetButtonUp)\s*\(\s*"([^"\\]*)$`)
+	// inputActionArgument matches a caret in a string naming an Input System action, passed to
+	// FindAction or indexing actions
+	inputActionArgument = regexp.MustCompile(`(?:\bFindAction\s*\(\s*|\bactions\s*\[\s*)"([^"\\]*)$`)
+	// inputActionMapArgument matches a caret in the string argument of FindActionMap
+	inputActionMapArgument = regexp.MustCompile(`\bFindActionMap\s*\(\s*"([^"\\]*)$`)
+)
+
+// inputNameCompletions offers the axes of ProjectSettings/InputManager.asset in the string
+// argument of Input.GetAxis and the like, and the actions and action maps of the project's
+// .inputactions assets where the Input System looks them up by name. It reports false when the
+// caret isn't in such a string, or the file isn't in a Unity project
+func inputNameCompletions(doc Document, pos protocol.Position, rootPath string) ([]CompletionItem, bool) {
+	line := lineAt(doc.Text, pos.Line)
+	before := line[:utf16ToByteOffset(line, pos.Character)]
+
+	axis, action, actionMap := inputAxisArgument.MatchString(before), inputActionArgument.MatchString(before), inputActionMapArgument.MatchString(before)
+	if !axis && !action && !actionMap {
+		return nil, false
+	}
+	root, ok := unityProjectRoot(doc.URI.Filename(), rootPath)
+	if !ok {
+		return nil, false
+	}
+
+	if axis {
+		data, err := os.ReadFile(filepath.Join(root, "ProjectSettings", "InputManager.asset"))
+		if err != nil {
+			return nil, false
+		}
+		return stringContentItems(line, pos, parseInputManager(string(data)), "Input axis"), true
+	}
+
+	maps := readInputActions(filepath.Join(root, "Assets"))
+	if len(maps) == 0 {
+		return nil, false
+	}
+	var names []string
+	detail := "Input action"
+	for _, actions := range maps {
+		if actionMap {
+			names, detail = append(names, actions.Name), "Input action map"
+			continue
+		}
+		for _, action := range actions.Actions {
+			names = append(names, action.Name)
+		}
+		// FindAction also takes the action qualified with its map
+		for _, action := range actions.Actions {
+			names = append(names, actions.Name+"/"+action.Name)
+		}
+	}
+	return stringContentItems(line, pos, names, detail), true
+}
+
+// parseInputManager reads the axis names of an InputManager.asset, each the m_Name of an entry
+// of m_Axes. Axes defined for several devices share their name
+func parseInputManager(text string) []string {
+	var names []string
+	inAxes := false
+	for _, line := range splitLines(text) {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasSuffix(trimmed, ":") && !strings.HasPrefix(line, "    ") {
+			inAxes = trimmed == "m_Axes:"
+			continue
+		}
+		if !inAxes {
+			continue
+		}
+		trimmed = strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))
+		if name, ok := strings.CutPrefix(trimmed, "m_Name:"); ok {
+			if name = strings.Trim(strings.TrimSpace(name), `'"`); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// inputActionMap is an action map of an .inputactions asset, which is JSON
+type inputActionMap struct {
+	Name    string `json:"name"`
+	Actions []struct {
+		Name string `json:"name"`
+	} `json:"actions"`
+}
+
+// readInputActions reads the action maps of every .inputactions asset under assets
+func readInputActions(assets string) []inputActionMap {
+	var maps []inputActionMap
+	filepath.WalkDir(assets, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.EqualFold(filepath.Ext(path), ".inputactions") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		var asset struct {
+			Maps []inputActionMap `json:"maps"`
+		}
+		if json.Unmarshal(data, &asset) == nil {
+			maps = append(maps, asset.Maps...)
+		}
+		return nil
+	})
+	return maps
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+// inputManager is a ProjectSettings/InputManager.asset defining Horizontal for the keyboard
+// and a joystick, and Jump
+const inputManager = "%YAML 1.1\n" +
+	"--- !u!13 &1\n" +
+	"InputManager:\n" +
+	"  m_ObjectHideFlags: 0\n" +
+	"  serializedVersion: 2\n" +
+	"  m_Axes:\n" +
+	"  - serializedVersion: 3\n" +
+	"    m_Name: Horizontal\n" +
+	"    descriptiveName: \n" +
+	"    positiveButton: right\n" +
+	"  - serializedVersion: 3\n" +
+	"    m_Name: Jump\n" +
+	"    positiveButton: space\n" +
+	"  - serializedVersion: 3\n" +
+	"    m_Name: Horizontal\n" +
+	"    type: 2\n" +
+	"  m_UsePhysicalKeys: 1\n"
+
+// inputActions is an .inputactions asset with a Player and a UI action map
+const inputActions = `{
+	"name": "Controls",
+	"maps": [
+		{"name": "Player", "actions": [{"name": "Move"}, {"name": "Fire"}]},
+		{"name": "UI", "actions": [{"name": "Submit"}]}
+	]
+}`
+
+func TestParseInputManager(t *testing.T) {
+	if got, want := parseInputManager(inputManager), []string{"Horizontal", "Jump", "Horizontal"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseInputManager = %q, want %q", got, want)
+	}
+}
+
+// TestInputNameCompletions checks the strings naming an input axis are offered those of the
+// Input Manager, and those naming an Input System action or map the project's, without
+// asking OmniSharp, while projects lacking the asset are completed as usual
+func TestInputNameCompletions(t *testing.T) {
+	project := map[string]string{
+		"ProjectSettings/InputManager.asset": inputManager,
+		"Assets/Input/Controls.inputactions": inputActions,
+	}
+	tests := []struct {
+		name  string
+		files map[string]string
+		// line has | at the caret
+		line string
+		// want are the names offered, none when OmniSharp completes the string
+		want []string
+	}{
+		{"GetAxis", project, `        float x = Input.GetAxis("|");`, []string{"Horizontal", "Jump"}},
+		{"GetButtonDown begun", project, `        if (Input.GetButtonDown("Ju|")) { }`, []string{"Horizontal", "Jump"}},
+		{"FindAction", project, `        var move = input.FindAction("|");`, []string{"Move", "Fire", "Submit", "Player/Move", "Player/Fire", "UI/Submit"}},
+		{"actions indexed", project, `        var fire = input.actions["|"];`, []string{"Move", "Fire", "Submit", "Player/Move", "Player/Fire", "UI/Submit"}},
+		{"FindActionMap", project, `        var map = input.FindActionMap("|");`, []string{"Player", "UI"}},
+		{"no Input Manager", map[string]string{"ProjectSettings/ProjectVersion.txt": ""}, `        float x = Input.GetAxis("|");`, nil},
+		{"no input actions", map[string]string{"ProjectSettings/InputManager.asset": inputManager}, `        var move = input.FindAction("|");`, nil},
+		{"not a Unity project", map[string]string{"Assets/Input/Controls.inputactions": inputActions}, `        var move = input.FindAction("|");`, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": []AutoCompleteResponse{}})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Assets/Scripts/Player.cs")
+			line, caret := caretIn(test.line)
+			text := "class Player\n{\n    void Update()\n    {\n" + line + "\n    }\n}\n"
+			writeFiles(t, s.rootPath, test.files)
+			writeFiles(t, s.rootPath, map[string]string{"Assets/Scripts/Player.cs": text})
+			openTestDocument(s, uri, text)
+
+			list := completeAt(t, s, uri, protocol.Position{Line: 4, Character: caret.Character}, protocol.CompletionTriggerKindInvoked)
+			if test.want == nil {
+				if fake.callCount("/autocomplete") == 0 {
+					t.Error("OmniSharp not asked without the asset")
+				}
+				return
+			}
+			if fake.callCount("/autocomplete") != 0 {
+				t.Error("asked OmniSharp to complete an input name")
+			}
+			got := labels(list.Items)
+			want := append([]string{}, test.want...)
+			sort.Strings(got)
+			sort.Strings(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("completions = %q, want %q", got, want)
+			}
+		})
+	}
+}
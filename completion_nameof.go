@@ -0,0 +1,71 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// nameofArgument matches text ending in the argument of nameof, up to the identifier being
+// typed, including the qualifiers of a member as in nameof(transform.
+var nameofArgument = regexp.MustCompile(`\bnameof\s*\(\s*(?:@?[A-Za-z_]\w*\s*\.\s*)*$`)
+
+// nameofKinds are the completion kinds nameof accepts. Keywords, even type keywords such as
+// int, aren't names
+var nameofKinds = map[protocol.CompletionItemKind]bool{
+	protocol.CompletionItemKindMethod:        true,
+	protocol.CompletionItemKindProperty:      true,
+	protocol.CompletionItemKindField:         true,
+	protocol.CompletionItemKindEvent:         true,
+	protocol.CompletionItemKindVariable:      true,
+	protocol.CompletionItemKindConstant:      true,
+	protocol.CompletionItemKindEnumMember:    true,
+	protocol.CompletionItemKindClass:         true,
+	protocol.CompletionItemKindStruct:        true,
+	protocol.CompletionItemKindInterface:     true,
+	protocol.CompletionItemKindEnum:          true,
+	protocol.CompletionItemKindTypeParameter: true,
+	protocol.CompletionItemKindModule:        true,
+	protocol.CompletionItemKindText:          true,
+}
+
+// inNameof reports whether offset is in the argument of nameof, ignoring the identifier being typed
+func inNameof(text string, offset int) bool {
+	before := text[:offset]
+	return nameofArgument.MatchString(strings.TrimSuffix(before, identifierBefore(before)))
+}
+
+// filterNameofCompletions keeps the items naming something, inserting only their name: no
+// argument list for methods, no type arguments for generic types
+func filterNameofCompletions(items []CompletionItem, nameof bool) []CompletionItem {
+	if !nameof {
+		return items
+	}
+
+	filtered := items[:0]
+	for _, item := range items {
+		if !nameofKinds[item.Kind] {
+			continue
+		}
+		text := item.TextEditText
+		if text == "" {
+			text = item.InsertText
+		}
+		if text == "" {
+			text = item.Label
+		}
+		name := identifierAfter(strings.TrimPrefix(text, "@"))
+		if name == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "@") {
+			name = "@" + name
+		}
+		item.InsertText, item.TextEditText = name, name
+		item.InsertTextFormat = protocol.InsertTextFormatPlainText
+		item.Command = nil
+		filtered = append(filtered, item)
+	}
+	return filtered
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestInNameof(t *testing.T) {
+	tests := []struct {
+		name string
+		// text has | at the caret
+		text string
+		want bool
+	}{
+		{"empty argument", "Debug.Log(nameof(|", true},
+		{"argument begun", "Debug.Log(nameof(spe|", true},
+		{"spaced", "nameof ( |", true},
+		{"member", "nameof(transform.|", true},
+		{"member begun", "nameof(transform.posi|", true},
+		{"verbatim qualifier", "nameof(@class.|", true},
+		{"after the argument", "nameof(speed) + |", false},
+		{"call argument", "Log(|", false},
+		{"nested call", "nameof(Get(|", false},
+		{"identifier ending in nameof", "mynameof(|", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			offset := strings.Index(test.text, "|")
+			text := strings.Replace(test.text, "|", "", 1)
+			if got := inNameof(text, offset); got != test.want {
+				t.Errorf("inNameof = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestNameofCompletions checks the argument of nameof is completed with the names in scope,
+// inserting the bare name
+func TestNameofCompletions(t *testing.T) {
+	items := []AutoCompleteResponse{
+		{CompletionText: "speed", DisplayText: "speed", Kind: "Field"},
+		{CompletionText: "Jump", DisplayText: "Jump", Kind: "Method", MethodHeader: "Jump(float height)"},
+		{CompletionText: "GetComponent<>", DisplayText: "GetComponent<>", Kind: "Method"},
+		{CompletionText: "Player", DisplayText: "Player", Kind: "Class"},
+		{CompletionText: "height", DisplayText: "height", Kind: "Parameter"},
+		{CompletionText: "@event", DisplayText: "event", Kind: "Local"},
+		{CompletionText: "int", DisplayText: "int", Kind: "Keyword"},
+		{CompletionText: "this", DisplayText: "this", Kind: "Keyword"},
+	}
+	tests := []struct {
+		name   string
+		before string
+		// want maps the labels completed to what they insert, if checked
+		want map[string]string
+		// namesOnly is set if nothing else should be completed, such as keywords and snippets
+		namesOnly bool
+	}{
+		{
+			name:      "nameof",
+			namesOnly: true,
+			before:    "class Player { void Jump(float height) { Debug.Log(nameof(",
+			want: map[string]string{
+				"speed": "speed", "Jump": "Jump", "GetComponent<>": "GetComponent", "Player": "Player",
+				"height": "height", "event": "@event",
+			},
+		},
+		{
+			name:   "other call",
+			before: "class Player { void Jump(float height) { Debug.Log(",
+			want: map[string]string{
+				"speed": "", "Jump": "", "GetComponent<>": "", "Player": "", "height": "", "event": "", "int": "", "this": "",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": items})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, test.before+")); } }\n")
+
+			list := completeAt(t, s, uri, protocol.Position{Character: uint32(len(test.before))}, protocol.CompletionTriggerKindInvoked)
+			var got, want []string
+			for _, item := range list.Items {
+				if _, ok := test.want[item.Label]; !ok {
+					continue
+				}
+				got = append(got, item.Label)
+				if inserted := test.want[item.Label]; inserted != "" {
+					if item.InsertText != inserted || item.TextEditText != inserted || item.InsertTextFormat != protocol.InsertTextFormatPlainText || item.Command != nil {
+						t.Errorf("%s inserts %q (%q, format %v, command %+v), want %q", item.Label, item.InsertText, item.TextEditText, item.InsertTextFormat, item.Command, inserted)
+					}
+				}
+			}
+			for label := range test.want {
+				want = append(want, label)
+			}
+			sort.Strings(got)
+			sort.Strings(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("completed %v, want %v", got, want)
+			}
+			if test.namesOnly && len(list.Items) != len(test.want) {
+				t.Errorf("completed %v besides the names", labels(list.Items))
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+var (
+	// namespaceStart matches a line ending in the name of a namespace declaration being typed
+	namespaceStart = regexp.MustCompile(`^(\s*)namespace\s+[\w.]*$`)
+	// langVersion and targetFramework capture the properties of a project deciding its C# version
+	langVersion     = regexp.MustCompile(`<LangVersion>\s*([^<\s]+)\s*</LangVersion>`)
+	targetFramework = regexp.MustCompile(`<TargetFrameworks?>\s*net(\d+)\.`)
+)
+
+// namespaceCompletions offers a namespace declaration for the file, named after its folder, in
+// the block-scoped form and, if the project's C# version has it, the file-scoped one. The form
+// most files of the folder use is preselected
+func (s *Server) namespaceCompletions(doc Document, pos protocol.Position) []CompletionItem {
+	line := lineAt(doc.Text, pos.Line)
+	caret := utf16ToByteOffset(line, pos.Character)
+	match := namespaceStart.FindStringSubmatchIndex(line[:caret])
+	if match == nil {
+		return nil
+	}
+	// Only the first namespace of a file can be file-scoped, and it must come before any type
+	before := doc.Text[:offsetAt(doc.Text, protocol.Position{Line: pos.Line})]
+	if braceDepth(before) != 0 || typeDeclaration.MatchString(before) || fileScopedNamespace.MatchString(doc.Text) {
+		return nil
+	}
+
+	// A name already typed is kept rather than replaced by the folder's
+	path := doc.URI.Filename()
+	name := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line[match[3]:caret]), "namespace"))
+	if name == "" {
+		name = namespaceForPath(path, s.rootPath)
+	}
+	placeholder := name
+	if placeholder == "" {
+		placeholder = "Name"
+	}
+	fileScoped := supportsFileScopedNamespaces(s.projectFor(path))
+	preferFileScoped := fileScoped && prefersFileScoped(doc, filepath.Dir(path))
+
+	edit := protocol.Range{
+		Start: protocol.Position{Line: pos.Line, Character: byteToUTF16Offset(line, match[3])},
+		End:   pos,
+	}
+	item := func(label, newText string, preselect bool) CompletionItem {
+		return CompletionItem{
+			CompletionItem: protocol.CompletionItem{
+				Label:            label,
+				Kind:             protocol.CompletionItemKindSnippet,
+				Detail:           "namespace declaration",
+				FilterText:       "namespace " + name,
+				Preselect:        preselect,
+				InsertText:       newText,
+				InsertTextFormat: protocol.InsertTextFormatSnippet,
+			},
+			TextEdit: &protocol.TextEdit{Range: edit, NewText: newText},
+		}
+	}
+
+	items := []CompletionItem{
+		item("namespace "+placeholder+" { }", "namespace ${1:"+placeholder+"}\n{\n"+s.indentUnit()+"$0\n}", !preferFileScoped),
+	}
+	if fileScoped {
+		items = append(items, item("namespace "+placeholder+";", "namespace ${1:"+placeholder+"};\n\n$0", preferFileScoped))
+	}
+	return items
+}
+
+// fileScopedNamespace matches a file-scoped namespace declaration
+var fileScopedNamespace = regexp.MustCompile(`(?m)^\s*namespace\s+[\w.]+\s*;`)
+
+// namespaceForPath names the namespace of a script after its folders below the nearest
+// assembly definition, prefixed with that assembly's rootNamespace. Scripts outside any
+// assembly definition are named after their folders below Assets, leaving out Scripts
+func namespaceForPath(path, rootPath string) string {
+	dir := filepath.Dir(path)
+	base, root := filepath.Join(rootPath, "Assets"), ""
+	for search := dir; ; search = filepath.Dir(search) {
+		if asmdef, ok := findAsmdef(search); ok {
+			base, root = search, asmdefRootNamespace(asmdef)
+			break
+		}
+		if parent := filepath.Dir(search); parent == search || search == rootPath {
+			break
+		}
+	}
+
+	parts := []string{}
+	if root != "" {
+		parts = append(parts, root)
+	}
+	rel, err := filepath.Rel(base, dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return root
+	}
+	for i, folder := range strings.Split(filepath.ToSlash(rel), "/") {
+		if folder == "." || (i == 0 && root == "" && folder == "Scripts") {
+			continue
+		}
+		folder = strings.Map(func(r rune) rune {
+			if isIdentifierRune(r) {
+				return r
+			}
+			return '_'
+		}, folder)
+		parts = append(parts, folder)
+	}
+	return strings.Join(parts, ".")
+}
+
+func asmdefRootNamespace(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	var asmdef struct {
+		RootNamespace string `json:"rootNamespace"`
+	}
+	if err := json.Unmarshal(data, &asmdef); err != nil {
+		return ""
+	}
+	return asmdef.RootNamespace
+}
+
+// supportsFileScopedNamespaces reports whether project targets C# 10 or later, from its
+// LangVersion. Without a LangVersion the version is the default of the target framework, C# 10
+// from .NET 6
+func supportsFileScopedNamespaces(project string) bool {
+	if match := langVersion.FindStringSubmatch(project); match != nil {
+		switch version := strings.ToLower(match[1]); version {
+		case "latest", "latestmajor", "preview":
+			return true
+		case "default":
+		default:
+			major, err := strconv.ParseFloat(version, 64)
+			return err == nil && major >= 10
+		}
+	}
+	if match := targetFramework.FindStringSubmatch(project); match != nil {
+		major, err := strconv.Atoi(match[1])
+		return err == nil && major >= 6
+	}
+	return false
+}
+
+// prefersFileScoped reports whether most scripts of dir other than doc declare file-scoped
+// namespaces; with none either way, the modern form wins
+func prefersFileScoped(doc Document, dir string) bool {
+	scripts, _ := filepath.Glob(filepath.Join(dir, "*.cs"))
+	fileScoped, blockScoped := 0, 0
+	for _, script := range scripts {
+		if pathToURI(script) == doc.URI {
+			continue
+		}
+		data, err := os.ReadFile(script)
+		if err != nil {
+			continue
+		}
+		switch {
+		case fileScopedNamespace.Match(data):
+			fileScoped++
+		case namespaceDeclaration.Match(data):
+			blockScoped++
+		}
+	}
+	return fileScoped >= blockScoped
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+// writeFiles writes files, keyed by their path below root
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, text := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNamespaceForPath(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"Assets/Studio/Studio.asmdef":   `{"name": "Studio", "rootNamespace": "Studio.Game"}`,
+		"Assets/Plugins/Plugins.asmdef": `{"name": "Plugins"}`,
+	})
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"below Scripts", "Assets/Scripts/Player/Player.cs", "Player"},
+		{"folders below Assets", "Assets/Game/UI/Menu.cs", "Game.UI"},
+		{"folder not an identifier", "Assets/Game/Main Menu/Menu.cs", "Game.Main_Menu"},
+		{"directly in Assets", "Assets/Player.cs", ""},
+		{"assembly root namespace", "Assets/Studio/UI/Menu.cs", "Studio.Game.UI"},
+		{"beside the assembly definition", "Assets/Studio/Game.cs", "Studio.Game"},
+		{"assembly without a root namespace", "Assets/Plugins/Audio/Mixer.cs", "Audio"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := namespaceForPath(filepath.Join(root, test.path), root); got != test.want {
+				t.Errorf("namespaceForPath = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSupportsFileScopedNamespaces(t *testing.T) {
+	project := func(properties string) string {
+		return "<Project>\n  <PropertyGroup>\n    " + properties + "\n  </PropertyGroup>\n</Project>\n"
+	}
+	tests := []struct {
+		name    string
+		project string
+		want    bool
+	}{
+		{"C# 9", project("<LangVersion>9.0</LangVersion>"), false},
+		{"C# 10", project("<LangVersion>10.0</LangVersion>"), true},
+		{"latest", project("<LangVersion>latest</LangVersion>"), true},
+		{"default of .NET 6", project("<TargetFramework>net6.0</TargetFramework>"), true},
+		{"default of .NET Standard", project("<TargetFramework>netstandard2.1</TargetFramework>"), false},
+		{"default spelled out", project("<LangVersion>default</LangVersion><TargetFramework>net8.0</TargetFramework>"), true},
+		{"several frameworks", project("<TargetFrameworks>net7.0;net48</TargetFrameworks>"), true},
+		{"no project", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := supportsFileScopedNamespaces(test.project); got != test.want {
+				t.Errorf("supportsFileScopedNamespaces = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestNamespaceCompletions checks a namespace declaration is offered block-scoped, and also
+// file-scoped when the project's C# version has it, preselecting the form of the folder's
+// other scripts
+func TestNamespaceCompletions(t *testing.T) {
+	const compile = `<ItemGroup><Compile Include="Assets\Game\Player.cs" /></ItemGroup>`
+	const csharp9, csharp10 = "<Project><PropertyGroup><LangVersion>9.0</LangVersion></PropertyGroup>" + compile + "</Project>", "<Project><PropertyGroup><LangVersion>10.0</LangVersion></PropertyGroup>" + compile + "</Project>"
+	block := "namespace Game\n{\n    \n}"
+	fileScoped := "namespace Game;\n\n"
+	tests := []struct {
+		name  string
+		files map[string]string
+		// text is the document up to the caret
+		text string
+		// want are the insertions offered, the preselected one first
+		want []string
+	}{
+		{"C# 9", map[string]string{"Game.csproj": csharp9}, "namespace ", []string{block}},
+		{"C# 10", map[string]string{"Game.csproj": csharp10}, "namespace ", []string{fileScoped, block}},
+		{
+			"C# 10, block-scoped folder",
+			map[string]string{"Game.csproj": csharp10, "Assets/Game/Enemy.cs": "namespace Game\n{\n}\n", "Assets/Game/Boss.cs": "namespace Game\n{\n}\n"},
+			"namespace ", []string{block, fileScoped},
+		},
+		{
+			"C# 10, folder split evenly",
+			map[string]string{"Game.csproj": csharp10, "Assets/Game/Enemy.cs": "namespace Game;\n", "Assets/Game/Boss.cs": "namespace Game\n{\n}\n"},
+			"namespace ", []string{fileScoped, block},
+		},
+		{"name typed", map[string]string{"Game.csproj": csharp9}, "namespace Game", []string{block}},
+		{"after a type", map[string]string{"Game.csproj": csharp10}, "class Player { }\nnamespace ", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": []AutoCompleteResponse{}})
+			s, _ := newTestServer(t, fake)
+			writeFiles(t, s.rootPath, test.files)
+			uri := testURI(s, "Assets/Game/Player.cs")
+			writeFiles(t, s.rootPath, map[string]string{"Assets/Game/Player.cs": test.text})
+			openTestDocument(s, uri, test.text)
+
+			list := completeAt(t, s, uri, positionAt(test.text, len(test.text)), protocol.CompletionTriggerKindInvoked)
+			var got []string
+			for _, item := range list.Items {
+				if item.Detail == "namespace declaration" {
+					got = append(got, item.TextEdit.(*protocol.TextEdit).NewText)
+				}
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("namespace declarations %q, want %q", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("namespace declarations %q, want %q", got, test.want)
+				}
+			}
+			if len(got) > 0 && !list.Items[0].Preselect {
+				t.Errorf("%q not preselected", list.Items[0].Label)
+			}
+		})
+	}
+}
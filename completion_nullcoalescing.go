@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"regexp"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// nullableOperand matches text ending in a variable or member followed by a space, possibly
+// with the start of ?? or ??= typed, capturing the text before it, its qualifiers, its name and
+// the operator typed
+var nullableOperand = regexp.MustCompile(`(^|[^\w.?])((?:@?[A-Za-z_]\w*\s*\.\s*)*)(@?[A-Za-z_]\w*)\s+(\?(?:\?=?)?)?$`)
+
+// expressionLead matches the end of text after which an expression starts, so a throw
+// expression may follow the operand
+var expressionLead = regexp.MustCompile(`(?:[=(,:]|=>|\breturn)\s*$`)
+
+// nullCoalescingCompletions offers ??= new after a nullable variable or member starting a
+// statement, and ?? throw after one in an expression, with completion.nullCoalescing set.
+// Whether it is nullable comes from the type OmniSharp shows for it, such as Player?, so
+// references are only offered these where nullable annotations are enabled
+func (s *Server) nullCoalescingCompletions(ctx context.Context, doc Document, pos protocol.Position) []CompletionItem {
+	omnisharp := s.completionBackend()
+	if omnisharp == nil || !s.currentConfig().Completion.NullCoalescing {
+		return nil
+	}
+	offset := offsetAt(doc.Text, pos)
+	before := doc.Text[:offset]
+	match := nullableOperand.FindStringSubmatchIndex(before)
+	if match == nil || tokenClassAt(doc.Text, match[6]) != tokenCode {
+		return nil
+	}
+	lead := strings.TrimRight(before[:match[4]], " \t")
+	statement := lead == "" || strings.HasSuffix(lead, "\n") || strings.HasSuffix(lead, ";") || strings.HasSuffix(lead, "{") || strings.HasSuffix(lead, "}")
+	if !statement && !expressionLead.MatchString(lead) {
+		return nil
+	}
+	if containsString(csharpKeywords, doc.Text[match[6]:match[7]]) {
+		return nil
+	}
+
+	response, err := omnisharp.SendRequest(ctx, "/typelookup", omnisharpPosition(doc.URI, positionAt(doc.Text, match[6])))
+	if err != nil {
+		log.Printf("failed to look up the type of the operand: %v", err)
+		return nil
+	}
+	var lookup TypeLookupResponse
+	if err := json.Unmarshal(response, &lookup); err != nil {
+		return nil
+	}
+	typeName, nullable := strings.CutSuffix(memberType(lookup.Type), "?")
+	if !nullable || typeName == "" {
+		return nil
+	}
+
+	edit := protocol.Range{Start: pos, End: pos}
+	if match[8] >= 0 {
+		edit.Start = positionAt(doc.Text, match[8])
+	}
+	item := func(label, detail, snippet string) CompletionItem {
+		return CompletionItem{
+			CompletionItem: protocol.CompletionItem{
+				Label:            label,
+				Kind:             protocol.CompletionItemKindSnippet,
+				Detail:           detail,
+				FilterText:       label,
+				InsertText:       snippet,
+				InsertTextFormat: protocol.InsertTextFormatSnippet,
+			},
+			TextEdit: &protocol.TextEdit{Range: edit, NewText: snippet},
+		}
+	}
+
+	if statement {
+		value := "new ${1:" + snippetEscape(typeName) + "}()"
+		if csharpBuiltinTypes[typeName] {
+			value = "${1:value}"
+		}
+		terminator := ";"
+		if strings.HasPrefix(strings.TrimLeft(doc.Text[offset:], " \t"), ";") {
+			terminator = ""
+		}
+		return []CompletionItem{item("??= "+snippetToPlainText(value), "assign if null", "??= "+value+terminator+"$0")}
+	}
+	operand := strings.TrimPrefix(strings.Join(strings.Fields(doc.Text[match[4]:match[7]]), ""), "this.")
+	return []CompletionItem{
+		item("?? throw", "throw if null", "?? throw new ${1:ArgumentNullException}(nameof("+snippetEscape(operand)+"))$0"),
+		item("??", "value if null", "?? ${1:fallback}$0"),
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+// TestNullCoalescingCompletions checks ??= and ?? throw are offered after a nullable operand
+// only, and only with completion.nullCoalescing set
+func TestNullCoalescingCompletions(t *testing.T) {
+	tests := []struct {
+		name string
+		// code is the method body, with | at the caret
+		code string
+		// typeLookup is OmniSharp's description of the operand
+		typeLookup string
+		disabled   bool
+		want       []string
+	}{
+		{name: "nullable statement", code: "target |", typeLookup: "Enemy? Player.target", want: []string{"??= new Enemy()"}},
+		{name: "nullable value type statement", code: "count |", typeLookup: "int? Player.count", want: []string{"??= value"}},
+		{name: "operator partly typed", code: "target ??|", typeLookup: "Enemy? Player.target", want: []string{"??= new Enemy()"}},
+		{name: "nullable expression", code: "var enemy = target |", typeLookup: "Enemy? Player.target", want: []string{"??", "?? throw"}},
+		{name: "nullable argument", code: "Attack(this.target |", typeLookup: "Enemy? Player.target", want: []string{"??", "?? throw"}},
+		{name: "non-nullable statement", code: "target |", typeLookup: "Enemy Player.target"},
+		{name: "non-nullable expression", code: "var enemy = target |", typeLookup: "Enemy Player.target"},
+		{name: "non-nullable value type", code: "count |", typeLookup: "int Player.count"},
+		{name: "disabled", code: "target |", typeLookup: "Enemy? Player.target", disabled: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{
+				"/autocomplete": autoCompleteItems("speed"),
+				"/typelookup":   TypeLookupResponse{Type: test.typeLookup},
+			})
+			s, _ := newTestServer(t, fake)
+			configure(s, func(config *Config) { config.Completion.NullCoalescing = !test.disabled })
+			uri := testURI(s, "Player.cs")
+			offset := strings.Index(test.code, "|")
+			text := "class Player { void Start() { " + strings.Replace(test.code, "|", "", 1) + " } }\n"
+			openTestDocument(s, uri, text)
+
+			list := completeAt(t, s, uri, protocol.Position{Character: uint32(len("class Player { void Start() { ") + offset)}, protocol.CompletionTriggerKindInvoked)
+			var got []string
+			for _, item := range list.Items {
+				if strings.HasPrefix(item.Label, "??") {
+					got = append(got, item.Label)
+				}
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("offered %q, want %q", got, test.want)
+			}
+			// The gate also spares OmniSharp the lookup
+			if test.disabled && fake.callCount("/typelookup") != 0 {
+				t.Errorf("looked up the operand's type with nullCoalescing off")
+			}
+		})
+	}
+}
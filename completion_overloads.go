@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// collapseOverloads merges the items OmniSharp returns for each overload of a method into the
+// first of them, noting how many more there are in its detail and listing every signature in
+// its documentation. Accepting it opens signature help to pick one
+func collapseOverloads(items []CompletionItem) []CompletionItem {
+	overloads := make(map[string][]string)
+	for _, item := range items {
+		if key, ok := overloadKey(item); ok {
+			overloads[key] = append(overloads[key], overloadSignature(item))
+		}
+	}
+
+	collapsed := items[:0]
+	seen := make(map[string]bool)
+	for _, item := range items {
+		key, ok := overloadKey(item)
+		if ok && seen[key] {
+			continue
+		}
+		if signatures := overloads[key]; ok && len(signatures) > 1 {
+			seen[key] = true
+			// The data is shared with cached copies of the list
+			data := *item.Data.(*completionData)
+			data.Overloads = signatures
+			item.Data = &data
+			item.Detail = strings.TrimSpace(item.Detail + overloadCount(len(signatures)-1))
+			item.Command = triggerParameterHints
+		}
+		collapsed = append(collapsed, item)
+	}
+	return collapsed
+}
+
+// overloadKey groups the OmniSharp method and constructor items inserting the same name
+func overloadKey(item CompletionItem) (string, bool) {
+	if item.Kind != protocol.CompletionItemKindMethod && item.Kind != protocol.CompletionItemKindConstructor {
+		return "", false
+	}
+	if data, ok := item.Data.(*completionData); !ok || data.Source != completionSourceOmniSharp {
+		return "", false
+	}
+	return fmt.Sprintf("%d:%s", uint32(item.Kind), item.TextEditText), true
+}
+
+// overloadSignature describes an overload by its symbol, e.g. "void Rigidbody.AddForce(Vector3
+// force)", or else by its label
+func overloadSignature(item CompletionItem) string {
+	if data := item.Data.(*completionData); data.Symbol != "" {
+		return data.Symbol
+	}
+	return item.Label
+}
+
+func overloadCount(more int) string {
+	if more == 1 {
+		return " (+1 overload)"
+	}
+	return fmt.Sprintf(" (+%d overloads)", more)
+}
+
+// withOverloads prefixes documentation with the signatures of a collapsed item
+func withOverloads(documentation string, overloads []string) string {
+	if len(overloads) == 0 {
+		return documentation
+	}
+	signatures := "```csharp\n" + strings.Join(overloads, "\n") + "\n```"
+	if documentation == "" {
+		return signatures
+	}
+	return signatures + "\n\n" + documentation
+}
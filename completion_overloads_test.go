@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+// TestCollapseOverloads checks the overloads of a method complete as one item counting the
+// others, whose documentation lists every signature
+func TestCollapseOverloads(t *testing.T) {
+	overload := func(parameters string) AutoCompleteResponse {
+		return AutoCompleteResponse{
+			CompletionText: "AddForce",
+			DisplayText:    "AddForce(" + parameters + ")",
+			Kind:           "Method",
+			ReturnType:     "void",
+			Description:    "public void Rigidbody.AddForce(" + parameters + ")",
+			MethodHeader:   "AddForce(" + parameters + ")",
+		}
+	}
+	signatures := []string{"Vector3 force", "Vector3 force, ForceMode mode", "float x, float y, float z"}
+	others := []AutoCompleteResponse{
+		{CompletionText: "AddTorque", DisplayText: "AddTorque(Vector3 torque)", Kind: "Method", Description: "public void Rigidbody.AddTorque(Vector3 torque)"},
+		{CompletionText: "mass", DisplayText: "mass", Kind: "Property", ReturnType: "float"},
+	}
+	tests := []struct {
+		name      string
+		overloads int
+		collapse  bool
+		// wantDetail is how the AddForce item's detail ends, if collapsed
+		wantDetail string
+	}{
+		{"three overloads", 3, true, "(+2 overloads)"},
+		{"two overloads", 2, true, "(+1 overload)"},
+		{"one method", 1, true, ""},
+		{"disabled", 3, false, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var items []AutoCompleteResponse
+			for _, parameters := range signatures[:test.overloads] {
+				items = append(items, overload(parameters))
+			}
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": append(items, others...)})
+			s, _ := newTestServer(t, fake)
+			configure(s, func(config *Config) { config.Completion.CollapseOverloads = test.collapse })
+			uri := testURI(s, "Player.cs")
+			const before = "class Player { Rigidbody body; void Jump() { body."
+			openTestDocument(s, uri, before+" } }\n")
+
+			list := completeAt(t, s, uri, protocol.Position{Character: uint32(len(before))}, protocol.CompletionTriggerKindTriggerCharacter)
+			var addForce []CompletionItem
+			for _, item := range list.Items {
+				if strings.HasPrefix(item.Label, "AddForce") {
+					addForce = append(addForce, item)
+				}
+			}
+			if len(list.Items) != len(addForce)+len(others) {
+				t.Errorf("completed %v, want AddTorque and mass kept apart", labels(list.Items))
+			}
+			if test.wantDetail == "" {
+				if len(addForce) != test.overloads {
+					t.Fatalf("completed %d AddForce items, want %d", len(addForce), test.overloads)
+				}
+				for _, item := range addForce {
+					if strings.Contains(item.Detail, "overload") {
+						t.Errorf("detail = %q, want no overload count", item.Detail)
+					}
+				}
+				return
+			}
+
+			if len(addForce) != 1 {
+				t.Fatalf("completed %d AddForce items, want one", len(addForce))
+			}
+			item := addForce[0]
+			if !strings.HasSuffix(item.Detail, test.wantDetail) || item.Command == nil || item.Command.Command != triggerParameterHints.Command {
+				t.Errorf("detail = %q, command %+v; want it ending %q, triggering parameter hints", item.Detail, item.Command, test.wantDetail)
+			}
+
+			// The item travels to the client and back before it is resolved
+			encoded, err := json.Marshal(item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sent CompletionItem
+			if err := json.Unmarshal(encoded, &sent); err != nil {
+				t.Fatal(err)
+			}
+			resolved, err := s.handleCompletionResolve(context.Background(), &sent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			documentation, _ := json.Marshal(resolved.Documentation)
+			for _, parameters := range signatures[:test.overloads] {
+				if !strings.Contains(string(documentation), "AddForce("+parameters+")") {
+					t.Errorf("documentation = %s, want the AddForce(%s) overload listed", documentation, parameters)
+				}
+			}
+		})
+	}
+}
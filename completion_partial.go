@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+var (
+	// partialMemberStart matches a line ending in a partial member being typed, after any
+	// modifiers, capturing the return type if it has been typed
+	partialMemberStart = regexp.MustCompile(`^\s*((?:[a-z]+\s+)*?)partial\s+(?:([A-Za-z_][\w<>\[\],.?]*)\s+)?@?\w*$`)
+	// partialMethod matches a partial method declaration or implementation, capturing its
+	// modifiers, return type, name, parameters and whether a body follows
+	partialMethod = regexp.MustCompile(`(?m)^[ \t]*((?:[a-z]+[ \t]+)*?)partial[ \t]+([A-Za-z_][\w<>\[\],.? ]*?)[ \t]+([A-Za-z_]\w*)[ \t]*\(([^)]*)\)[ \t\r\n]*(;|\{|=>)`)
+)
+
+// typeKeywords start the nested partial types partialMethod would otherwise take for methods
+var typeKeywords = map[string]bool{"class": true, "struct": true, "record": true, "interface": true}
+
+// partialMethodDeclaration is a partial method as declared, without an implementation
+type partialMethodDeclaration struct {
+	modifiers  string
+	returnType string
+	name       string
+	parameters string
+}
+
+// signature is the part of the declaration the implementation repeats
+func (d partialMethodDeclaration) signature() string {
+	return d.modifiers + "partial " + d.returnType + " " + d.name + "(" + d.parameters + ")"
+}
+
+// partialMethodCompletions offers the partial methods of the enclosing type left to implement
+// where a partial member is being typed, inserting their signature and an empty body. The
+// other parts of a partial type, such as those written by Unity's code generators, are found
+// through OmniSharp
+func (s *Server) partialMethodCompletions(ctx context.Context, doc Document, pos protocol.Position) []CompletionItem {
+	line := lineAt(doc.Text, pos.Line)
+	caret := utf16ToByteOffset(line, pos.Character)
+	match := partialMemberStart.FindStringSubmatchIndex(line[:caret])
+	if match == nil {
+		return nil
+	}
+	typed := ""
+	if match[4] >= 0 {
+		typed = line[match[4]:match[5]]
+	}
+	typeName := enclosingTypeName(doc.Text, offsetAt(doc.Text, pos))
+	if typeName == "" {
+		return nil
+	}
+
+	var declared []partialMethodDeclaration
+	implemented := make(map[string]bool)
+	for _, text := range s.partialTypeTexts(ctx, doc, typeName) {
+		for _, body := range typeBodies(text, typeName) {
+			for _, method := range partialMethod.FindAllStringSubmatchIndex(body, -1) {
+				// Skip the members of nested types
+				if braceDepth(body[:method[0]]) != 0 {
+					continue
+				}
+				declaration := partialMethodDeclaration{
+					modifiers:  strings.Join(strings.Fields(body[method[2]:method[3]]), " "),
+					returnType: strings.Join(strings.Fields(body[method[4]:method[5]]), " "),
+					name:       body[method[6]:method[7]],
+					parameters: strings.Join(strings.Fields(body[method[8]:method[9]]), " "),
+				}
+				if declaration.modifiers != "" {
+					declaration.modifiers += " "
+				}
+				if typeKeywords[declaration.returnType] {
+					continue
+				}
+				if body[method[10]:method[11]] == ";" {
+					declared = append(declared, declaration)
+				} else {
+					implemented[partialMethodKey(declaration)] = true
+				}
+			}
+		}
+	}
+
+	// The implementation repeats the modifiers typed before partial, so they are replaced too
+	start := protocol.Position{Line: pos.Line, Character: byteToUTF16Offset(line, match[2])}
+	_, word := wordRanges(doc.Text, pos)
+	items := []CompletionItem{}
+	seen := make(map[string]bool)
+	for _, declaration := range declared {
+		key := partialMethodKey(declaration)
+		if implemented[key] || seen[key] || (typed != "" && typed != declaration.returnType) {
+			continue
+		}
+		seen[key] = true
+		signature := declaration.signature()
+		newText := signature + "\n{\n" + s.indentUnit() + "$0\n}"
+		items = append(items, CompletionItem{
+			CompletionItem: protocol.CompletionItem{
+				Label:            declaration.name + "(" + declaration.parameters + ")",
+				Kind:             protocol.CompletionItemKindMethod,
+				Detail:           signature,
+				FilterText:       signature,
+				InsertText:       newText,
+				InsertTextFormat: protocol.InsertTextFormatSnippet,
+			},
+			TextEdit: &protocol.TextEdit{Range: protocol.Range{Start: start, End: word.End}, NewText: newText},
+		})
+	}
+	return items
+}
+
+// partialTypeTexts returns the text of doc and of the other files declaring typeName, open
+// documents as edited
+func (s *Server) partialTypeTexts(ctx context.Context, doc Document, typeName string) []string {
+	texts := []string{doc.Text}
+	omnisharp := s.backend()
+	if omnisharp == nil {
+		return texts
+	}
+
+	found, err := findSymbols(ctx, omnisharp, typeName)
+	if err != nil {
+		log.Printf("failed to find the parts of %s: %v", typeName, err)
+		return texts
+	}
+	seen := map[protocol.DocumentURI]bool{doc.URI: true}
+	for _, symbol := range found {
+		if symbol.FileName == "" || symbolName(symbol.Text) != typeName || convertSymbolKind(symbol.Kind) != protocol.SymbolKindClass && convertSymbolKind(symbol.Kind) != protocol.SymbolKindStruct {
+			continue
+		}
+		uri := s.quickFixLocation(symbol.QuickFix).URI
+		if seen[uri] {
+			continue
+		}
+		seen[uri] = true
+		if other, ok := s.documents.Get(uri); ok {
+			texts = append(texts, other.Text)
+		} else if data, err := os.ReadFile(uri.Filename()); err == nil {
+			texts = append(texts, string(data))
+		}
+	}
+	return texts
+}
+
+// typeBodies returns the inside of the braces of each declaration of typeName in text
+func typeBodies(text, typeName string) []string {
+	var bodies []string
+	for _, match := range typeDeclaration.FindAllStringSubmatchIndex(text, -1) {
+		if text[match[2]:match[3]] != typeName {
+			continue
+		}
+		open := strings.IndexByte(text[match[1]:], '{')
+		if open < 0 {
+			continue
+		}
+		rest := text[match[1]+open:]
+		if end := closingBracket(rest, '{', '}'); end >= 0 {
+			bodies = append(bodies, rest[1:end])
+		} else {
+			// The part being edited may not be closed yet
+			bodies = append(bodies, rest[1:])
+		}
+	}
+	return bodies
+}
+
+// partialMethodKey identifies a partial method by its name and parameter types, which its
+// declaration and implementation share even if their parameter names differ
+func partialMethodKey(d partialMethodDeclaration) string {
+	var types []string
+	for _, parameter := range splitParameters(d.parameters) {
+		if value := strings.IndexByte(parameter, '='); value >= 0 {
+			parameter = parameter[:value]
+		}
+		fields := strings.Fields(parameter)
+		if len(fields) > 1 {
+			fields = fields[:len(fields)-1]
+		}
+		types = append(types, strings.Join(fields, " "))
+	}
+	return d.name + "(" + strings.Join(types, ",") + ")"
+}
+
+// splitParameters splits a parameter list at the commas outside type arguments
+func splitParameters(parameters string) []string {
+	var split []string
+	depth, start := 0, 0
+	for i := 0; i < len(parameters); i++ {
+		switch parameters[i] {
+		case '<', '[', '(':
+			depth++
+		case '>', ']', ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				split = append(split, parameters[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if strings.TrimSpace(parameters[start:]) != "" {
+		split = append(split, parameters[start:])
+	}
+	return split
+}
+
+// indentUnit is one level of indentation as formatting.useTabs and indentationSize set it
+func (s *Server) indentUnit() string {
+	if s.currentConfig().Formatting.UseTabs {
+		return "\t"
+	}
+	return strings.Repeat(" ", s.currentConfig().Formatting.IndentationSize)
+}
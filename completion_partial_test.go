@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestPartialMethodKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		declaration partialMethodDeclaration
+		want        string
+	}{
+		{"no parameters", partialMethodDeclaration{name: "OnDeath"}, "OnDeath()"},
+		{"parameter names dropped", partialMethodDeclaration{name: "OnHit", parameters: "float damage, int times"}, "OnHit(float,int)"},
+		{"modifiers kept", partialMethodDeclaration{name: "Load", parameters: "ref int count, out string name"}, "Load(ref int,out string)"},
+		{"default values dropped", partialMethodDeclaration{name: "Spawn", parameters: "int wave = 1"}, "Spawn(int)"},
+		{"generic parameter", partialMethodDeclaration{name: "Fill", parameters: "Dictionary<string, int> counts"}, "Fill(Dictionary<string, int>)"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := partialMethodKey(test.declaration); got != test.want {
+				t.Errorf("partialMethodKey = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestPartialMethodCompletions checks the partial methods of the enclosing type declared in
+// any of its parts and not yet implemented are offered where a partial member is typed
+func TestPartialMethodCompletions(t *testing.T) {
+	const declarations = "partial class Player : MonoBehaviour\n{\n" +
+		"    partial void OnSpawn(int wave);\n" +
+		"    partial void OnHit(float damage);\n" +
+		"    partial void OnHit(float amount) { }\n" +
+		"    partial class Stats { partial void OnReset(); }\n"
+	// generated is another part of Player, as Unity's code generators write
+	const generated = "partial class Player\n{\n    public partial bool CanSpawn(int wave);\n    partial void OnDeath();\n}\n"
+	tests := []struct {
+		name string
+		line string
+		want []string
+	}{
+		{"after partial", "    partial ", []string{"CanSpawn(int wave)", "OnDeath()", "OnSpawn(int wave)"}},
+		{"name begun", "    partial void On", []string{"OnDeath()", "OnSpawn(int wave)"}},
+		{"return type typed", "    public partial bool ", []string{"CanSpawn(int wave)"}},
+		{"not a partial member", "    void ", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": []AutoCompleteResponse{}})
+			s, _ := newTestServer(t, fake)
+			uri, other := testURI(s, "Player.cs"), testURI(s, "Player.Generated.cs")
+			if err := os.WriteFile(other.Filename(), []byte(generated), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			fake.setResponse("/findsymbols", map[string]interface{}{"QuickFixes": []SymbolLocation{
+				{QuickFix: QuickFix{FileName: uri.Filename(), Text: "Player"}, Kind: "Class"},
+				{QuickFix: QuickFix{FileName: other.Filename(), Text: "Player"}, Kind: "Class"},
+			}})
+			openTestDocument(s, uri, declarations+test.line+"\n}\n")
+
+			caret := protocol.Position{Line: uint32(strings.Count(declarations, "\n")), Character: uint32(len(test.line))}
+			list := completeAt(t, s, uri, caret, protocol.CompletionTriggerKindInvoked)
+			var got []string
+			for _, item := range list.Items {
+				if item.Kind == protocol.CompletionItemKindMethod {
+					got = append(got, item.Label)
+				}
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("partial methods = %v, want %v", got, test.want)
+			}
+
+			// The implementation replaces the line from the first modifier
+			start := protocol.Position{Line: caret.Line, Character: 4}
+			for _, item := range list.Items {
+				if item.Label != "OnDeath()" {
+					continue
+				}
+				want := "partial void OnDeath()\n    {\n        \n    }"
+				if edit, ok := item.TextEdit.(*protocol.TextEdit); !ok || edit.Range.Start != start || edit.NewText != want {
+					t.Errorf("OnDeath() edits %+v, want %q from %+v", item.TextEdit, want, start)
+				}
+			}
+		})
+	}
+}
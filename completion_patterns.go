@@ -0,0 +1,133 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"go.lsp.dev/protocol"
+)
+
+// patternContext is the kind of pattern the caret starts, which narrows what completes there
+type patternContext int
+
+const (
+	patternNone patternContext = iota
+	// patternType is where a type or constant pattern goes, e.g. after is, case or a switch arm
+	patternType
+	// patternProperty is where a property pattern names a member, e.g. after is {
+	patternProperty
+)
+
+// patternKeywords are followed by a pattern
+var patternKeywords = map[string]bool{"is": true, "case": true, "not": true, "and": true, "or": true}
+
+// patternKinds are the completion kinds that can start each kind of pattern. Items of unknown
+// kind are kept
+var patternKinds = map[patternContext]map[protocol.CompletionItemKind]bool{
+	patternType: {
+		protocol.CompletionItemKindClass:         true,
+		protocol.CompletionItemKindStruct:        true,
+		protocol.CompletionItemKindInterface:     true,
+		protocol.CompletionItemKindEnum:          true,
+		protocol.CompletionItemKindTypeParameter: true,
+		protocol.CompletionItemKindModule:        true,
+		protocol.CompletionItemKindConstant:      true,
+		protocol.CompletionItemKindKeyword:       true,
+		protocol.CompletionItemKindText:          true,
+	},
+	patternProperty: {
+		protocol.CompletionItemKindProperty: true,
+		protocol.CompletionItemKindField:    true,
+		protocol.CompletionItemKindText:     true,
+	},
+}
+
+// patternContextAt finds the pattern started at offset, ignoring the identifier being typed
+func patternContextAt(text string, offset int) patternContext {
+	before := text[:offset]
+	return patternContextBefore(strings.TrimRightFunc(strings.TrimSuffix(before, identifierBefore(before)), unicode.IsSpace))
+}
+
+// patternContextBefore is patternContextAt for text that ends where the pattern would start
+func patternContextBefore(before string) patternContext {
+	if patternKeywords[identifierBefore(before)] {
+		return patternType
+	}
+
+	trailing := before[len(before)-min(len(before), 1):]
+	if trailing != "{" && trailing != "," && trailing != ":" {
+		return patternNone
+	}
+	open := unmatchedBrace(before)
+	if open < 0 {
+		return patternNone
+	}
+	head := strings.TrimRightFunc(before[:open], unicode.IsSpace)
+
+	if identifierBefore(head) == "switch" {
+		// Each arm of a switch expression starts with a pattern
+		if trailing == ":" {
+			return patternNone
+		}
+		return patternType
+	}
+	if !isPropertyPatternBrace(head) {
+		return patternNone
+	}
+	// { Name: is followed by the pattern the member must match
+	if trailing == ":" {
+		return patternType
+	}
+	return patternProperty
+}
+
+// isPropertyPatternBrace reports whether a { following head opens a property pattern, as in
+// is { or case Vector3 {
+func isPropertyPatternBrace(head string) bool {
+	if word := identifierBefore(head); word != "" && !patternKeywords[word] {
+		head = strings.TrimRightFunc(strings.TrimSuffix(head, word), unicode.IsSpace)
+	}
+	return patternContextBefore(head) == patternType
+}
+
+// unmatchedBrace returns the offset of the { enclosing the end of text within its statement,
+// or -1 if the innermost open bracket is another kind or there is none
+func unmatchedBrace(text string) int {
+	depth := 0
+	for i := len(text) - 1; i >= 0; i-- {
+		switch text[i] {
+		case ')', ']', '}':
+			depth++
+		case '(', '[', '{':
+			if depth == 0 {
+				if text[i] == '{' {
+					return i
+				}
+				return -1
+			}
+			depth--
+		case ';':
+			if depth == 0 {
+				return -1
+			}
+		}
+	}
+	return -1
+}
+
+// filterPatternCompletions drops the items that can't start the pattern at the caret, such as
+// methods after is
+func filterPatternCompletions(items []CompletionItem, context patternContext) []CompletionItem {
+	kinds, ok := patternKinds[context]
+	if !ok {
+		return items
+	}
+
+	filtered := items[:0]
+	for _, item := range items {
+		if kinds[item.Kind] {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestPatternContextAt(t *testing.T) {
+	tests := []struct {
+		name string
+		// text marks the caret with |
+		text string
+		want patternContext
+	}{
+		{"after is", "if (other is |", patternType},
+		{"typing after is", "if (other is Rigid|", patternType},
+		{"after case", "case |", patternType},
+		{"after not", "if (other is not |", patternType},
+		{"after or", "if (other is Enemy or |", patternType},
+		{"switch arm", "var speed = state switch { |", patternType},
+		{"next switch arm", "var speed = state switch { State.Idle => 0, |", patternType},
+		{"property pattern", "if (other is { |", patternProperty},
+		{"typed property pattern", "if (other is Enemy { |", patternProperty},
+		{"next property", "if (other is { health: 0, |", patternProperty},
+		{"property's pattern", "if (other is { health: |", patternType},
+		{"nested property pattern", "if (other is { transform: { |", patternProperty},
+		{"case property pattern", "case Vector3 { |", patternProperty},
+		{"block", "void Start() { |", patternNone},
+		{"object initializer", "var enemy = new Enemy { |", patternNone},
+		{"expression", "var x = |", patternNone},
+		{"after the statement", "if (other is Enemy) { }\n|", patternNone},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			offset := strings.Index(test.text, "|")
+			text := strings.Replace(test.text, "|", "", 1)
+			if got := patternContextAt(text, offset); got != test.want {
+				t.Errorf("patternContextAt = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+// TestPatternCompletions completes x is with types, and x is { with property names
+func TestPatternCompletions(t *testing.T) {
+	items := []AutoCompleteResponse{
+		{CompletionText: "Enemy", DisplayText: "Enemy", Kind: "Class"},
+		{CompletionText: "Vector3", DisplayText: "Vector3", Kind: "Struct"},
+		{CompletionText: "health", DisplayText: "health", Kind: "Field"},
+		{CompletionText: "name", DisplayText: "name", Kind: "Property"},
+		{CompletionText: "GetComponent", DisplayText: "GetComponent", Kind: "Method"},
+		{CompletionText: "other", DisplayText: "other", Kind: "Local"},
+	}
+	tests := []struct {
+		name           string
+		pattern        string
+		want           []string
+		wantImportable bool
+	}{
+		{"type pattern", "other is ", []string{"Enemy", "Vector3"}, true},
+		{"property pattern", "other is { ", []string{"health", "name"}, false},
+		{"no pattern", "other == ", []string{"Enemy", "GetComponent", "health", "name", "other", "Vector3"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": items})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			text := "class Player { void Hit(object other) { if (" + test.pattern
+			openTestDocument(s, uri, text+") { } } }\n")
+
+			list := completeAt(t, s, uri, protocol.Position{Character: uint32(len(text))}, protocol.CompletionTriggerKindInvoked)
+			var got []string
+			for _, label := range labels(list.Items) {
+				for _, item := range items {
+					if item.DisplayText == label {
+						got = append(got, label)
+					}
+				}
+			}
+			sort.Strings(got)
+			sort.Strings(test.want)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("completed %v, want %v", got, test.want)
+			}
+
+			var request struct{ WantImportableTypes bool }
+			if err := json.Unmarshal(fake.bodies["/autocomplete"][0], &request); err != nil {
+				t.Fatal(err)
+			}
+			if request.WantImportableTypes != test.wantImportable {
+				t.Errorf("asked for importable types: %v, want %v", request.WantImportableTypes, test.wantImportable)
+			}
+		})
+	}
+}
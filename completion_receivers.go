@@ -0,0 +1,150 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// receiverContext is the keyword receiver of a member access at the caret, if any
+type receiverContext int
+
+const (
+	receiverNone receiverContext = iota
+	// receiverThis is this., which only reaches instance members
+	receiverThis
+	// receiverBase is base., which only reaches members inherited from the base class
+	receiverBase
+)
+
+// receiverContextAt finds whether offset follows this. or base., ignoring the member being typed
+func receiverContextAt(text string, offset int) receiverContext {
+	before := text[:offset]
+	before = strings.TrimSuffix(before, identifierBefore(before))
+	before, ok := strings.CutSuffix(before, ".")
+	if !ok {
+		return receiverNone
+	}
+	switch identifierBefore(before) {
+	case "this":
+		return receiverThis
+	case "base":
+		return receiverBase
+	default:
+		return receiverNone
+	}
+}
+
+// typeDeclaration matches the start of a type declaration, capturing its name
+var typeDeclaration = regexp.MustCompile(`\b(?:class|struct|record|interface)\s+([A-Za-z_]\w*)`)
+
+// enclosingTypeName returns the name of the innermost type declared around offset, or "" when
+// the caret isn't inside a type body
+func enclosingTypeName(text string, offset int) string {
+	before := text[:offset]
+	name := ""
+	for _, match := range typeDeclaration.FindAllStringSubmatchIndex(before, -1) {
+		open := strings.IndexByte(before[match[1]:], '{')
+		if open < 0 {
+			continue
+		}
+		if unclosed(before[match[1]+open:]) {
+			name = before[match[2]:match[3]]
+		}
+	}
+	return name
+}
+
+// braceDepth counts the braces of text left open at its end
+func braceDepth(text string) int {
+	return strings.Count(text, "{") - strings.Count(text, "}")
+}
+
+// unclosed reports whether the brace text starts with is still open at its end. Unlike a
+// positive braceDepth, a body closed before another brace opens doesn't count
+func unclosed(text string) bool {
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return false
+			}
+		}
+	}
+	return depth > 0
+}
+
+// typeKinds are the completion kinds of nested types, which aren't members of an instance
+var typeKinds = map[protocol.CompletionItemKind]bool{
+	protocol.CompletionItemKindClass:     true,
+	protocol.CompletionItemKindStruct:    true,
+	protocol.CompletionItemKindInterface: true,
+	protocol.CompletionItemKindEnum:      true,
+}
+
+// filterReceiverCompletions drops the items a keyword receiver can't reach: static members and
+// nested types after this., and after base. also the members declared by enclosing, the class
+// whose base is meant. Items OmniSharp didn't describe are kept
+func filterReceiverCompletions(items []CompletionItem, receiver receiverContext, enclosing string) []CompletionItem {
+	if receiver == receiverNone {
+		return items
+	}
+
+	filtered := items[:0]
+	for _, item := range items {
+		data, ok := item.Data.(*completionData)
+		if !ok || data.Source != completionSourceOmniSharp || data.Symbol == "" {
+			filtered = append(filtered, item)
+			continue
+		}
+		if typeKinds[item.Kind] || isStaticSymbol(data.Symbol) {
+			continue
+		}
+		if receiver == receiverBase && enclosing != "" && declaringType(data.Symbol, data.CompletionText) == enclosing {
+			continue
+		}
+		filtered = append(filtered, item)
+	}
+	return filtered
+}
+
+// isStaticSymbol reports whether a Roslyn symbol description declares a static member. Constants
+// are implicitly static
+func isStaticSymbol(description string) bool {
+	for _, word := range strings.Fields(description) {
+		if !memberModifiers[word] {
+			return false
+		}
+		if word == "static" || word == "const" {
+			return true
+		}
+	}
+	return false
+}
+
+// declaringType finds the type a Roslyn symbol description qualifies member with, such as Enemy
+// in "public void Game.Enemy.TakeDamage(int amount)", or "" if it names none
+func declaringType(description, member string) string {
+	for _, word := range strings.Fields(description) {
+		if i := strings.IndexByte(word, '('); i >= 0 {
+			word = word[:i]
+		}
+		// The type parameters of a generic method, as in Spawn<T>
+		if i := strings.LastIndexByte(word, '<'); i > strings.LastIndexByte(word, '.') {
+			word = word[:i]
+		}
+		qualifier, ok := strings.CutSuffix(word, "."+member)
+		if !ok {
+			continue
+		}
+		if i := strings.IndexByte(qualifier, '<'); i >= 0 {
+			qualifier = qualifier[:i]
+		}
+		return qualifier[strings.LastIndexByte(qualifier, '.')+1:]
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestReceiverContextAt(t *testing.T) {
+	tests := []struct {
+		name string
+		// text has | at the caret
+		text string
+		want receiverContext
+	}{
+		{"this.", "void M() { this.|", receiverThis},
+		{"this. with a member begun", "void M() { this.spe|", receiverThis},
+		{"base.", "override void M() { base.|", receiverBase},
+		{"another receiver", "void M() { player.|", receiverNone},
+		{"identifier ending in this", "void M() { ofthis.|", receiverNone},
+		{"no member access", "void M() { this|", receiverNone},
+		{"nested access", "void M() { this.target.|", receiverNone},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			offset := strings.Index(test.text, "|")
+			text := strings.Replace(test.text, "|", "", 1)
+			if got := receiverContextAt(text, offset); got != test.want {
+				t.Errorf("receiverContextAt = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEnclosingTypeName(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"class", "class Player : Actor { void M() { |", "Player"},
+		{"nested type", "class Outer { struct Inner { void M() { |", "Inner"},
+		{"after a nested type", "class Outer { struct Inner { } void M() { |", "Outer"},
+		{"record", "record Score(int Value) { void M() { |", "Score"},
+		{"outside any type", "class Player { } |", ""},
+		{"before the body", "class Player : |", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			offset := strings.Index(test.text, "|")
+			text := strings.Replace(test.text, "|", "", 1)
+			if got := enclosingTypeName(text, offset); got != test.want {
+				t.Errorf("enclosingTypeName = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDeclaringType(t *testing.T) {
+	tests := []struct {
+		description string
+		member      string
+		want        string
+	}{
+		{"public void Game.Enemy.TakeDamage(int amount)", "TakeDamage", "Enemy"},
+		{"int Player.health", "health", "Player"},
+		{"T Game.Spawner.Spawn<T>(T prefab)", "Spawn", "Spawner"},
+		{"void Game.Pool<T>.Release(T item)", "Release", "Pool"},
+		{"(local variable) int health", "health", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			if got := declaringType(test.description, test.member); got != test.want {
+				t.Errorf("declaringType = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestReceiverCompletions checks this. leaves out static members and nested types, and base.
+// also the derived class's own members
+func TestReceiverCompletions(t *testing.T) {
+	items := []AutoCompleteResponse{
+		{CompletionText: "health", DisplayText: "health", Kind: "Field", Description: "protected int Actor.health"},
+		{CompletionText: "Die", DisplayText: "Die", Kind: "Method", Description: "public virtual void Actor.Die()"},
+		{CompletionText: "count", DisplayText: "count", Kind: "Field", Description: "public static int Actor.count"},
+		{CompletionText: "MaxSpeed", DisplayText: "MaxSpeed", Kind: "Field", Description: "public const float Player.MaxSpeed"},
+		{CompletionText: "speed", DisplayText: "speed", Kind: "Field", Description: "private float Player.speed"},
+		{CompletionText: "Jump", DisplayText: "Jump", Kind: "Method", Description: "public void Player.Jump(float height)"},
+		{CompletionText: "State", DisplayText: "State", Kind: "Enum", Description: "enum Player.State"},
+		{CompletionText: "GetType", DisplayText: "GetType", Kind: "Method"},
+	}
+	tests := []struct {
+		name     string
+		receiver string
+		want     []string
+	}{
+		{"this.", "this", []string{"Die", "GetType", "Jump", "health", "speed"}},
+		{"base.", "base", []string{"Die", "GetType", "health"}},
+		{"another receiver", "other", []string{"Die", "GetType", "Jump", "MaxSpeed", "State", "count", "health", "speed"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": items})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			line := "class Player : Actor { Player other; override void Die() { " + test.receiver + "."
+			openTestDocument(s, uri, line+" } }\n")
+
+			list := completeAt(t, s, uri, protocol.Position{Line: 0, Character: uint32(len(line))}, protocol.CompletionTriggerKindTriggerCharacter)
+			got := labels(list.Items)
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("completions = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
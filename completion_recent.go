@@ -0,0 +1,170 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.lsp.dev/protocol"
+)
+
+const (
+	// maxRecentCompletions bounds the remembered items, forgetting the least used first
+	maxRecentCompletions = 200
+	// recentHalfLife is how long it takes a use to count half as much
+	recentHalfLife = 30 * time.Minute
+)
+
+// recentCompletions ranks recently accepted items higher, as IDEs do. LSP doesn't tell the
+// server which item was accepted, so it is inferred from the edit following a completion:
+// one inserting an identifier that an item of the list would have inserted
+type recentCompletions struct {
+	mu      sync.Mutex
+	entries map[string]recentEntry
+	pending *pendingCompletion
+}
+
+// recentEntry scores a remembered item, decaying from updated
+type recentEntry struct {
+	score   float64
+	updated time.Time
+}
+
+// pendingCompletion is the last completion list returned, waiting for the edit accepting one
+// of its items
+type pendingCompletion struct {
+	uri       protocol.DocumentURI
+	line      uint32
+	character uint32
+	// candidates maps the identifier each item inserts to the keys of the items inserting it
+	candidates map[string][]string
+}
+
+func newRecentCompletions() *recentCompletions {
+	return &recentCompletions{entries: make(map[string]recentEntry)}
+}
+
+// recentKey identifies an item across completions: by its symbol, so overloads and members of
+// other types sharing a name count apart, or else by its label
+func recentKey(item CompletionItem) string {
+	if data, ok := item.Data.(*completionData); ok && data.Symbol != "" {
+		return data.Symbol
+	}
+	return item.Label
+}
+
+// offer remembers items, completed for the identifier starting at start, until the next edit
+func (r *recentCompletions) offer(uri protocol.DocumentURI, start protocol.Position, items []CompletionItem) {
+	candidates := make(map[string][]string)
+	for _, item := range items {
+		text := item.TextEditText
+		if text == "" {
+			text = item.InsertText
+		}
+		if text == "" {
+			text = item.Label
+		}
+		// Items may insert await before the member
+		if identifier := identifierAfter(strings.TrimPrefix(text, "await ")); identifier != "" {
+			candidates[identifier] = append(candidates[identifier], recentKey(item))
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending = &pendingCompletion{uri: uri, line: start.Line, character: start.Character, candidates: candidates}
+}
+
+// observe looks for the edit accepting an item of the pending completion. text is the document
+// after changes were applied to it
+func (r *recentCompletions) observe(uri protocol.DocumentURI, text string, changes []TextDocumentContentChangeEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pending := r.pending
+	if pending == nil || pending.uri != uri {
+		return
+	}
+
+	// Accepting inserts the rest of the identifier at once, and maybe a using above it
+	accepted := false
+	for _, change := range changes {
+		if change.Range == nil {
+			r.pending = nil
+			return
+		}
+		if change.Range.End.Line < pending.line {
+			removed := change.Range.End.Line - change.Range.Start.Line
+			pending.line = pending.line + uint32(strings.Count(change.Text, "\n")) - removed
+		} else if change.Range.Start.Line != pending.line || change.Range.End.Line != pending.line {
+			r.pending = nil
+			return
+		}
+		accepted = accepted || utf8.RuneCountInString(change.Text) > 1
+	}
+	if !accepted {
+		return
+	}
+
+	line := lineAt(text, pending.line)
+	identifier := identifierAfter(line[utf16ToByteOffset(line, pending.character):])
+	keys, ok := pending.candidates[identifier]
+	if !ok {
+		return
+	}
+	r.pending = nil
+	now := time.Now()
+	for _, key := range keys {
+		entry := r.entries[key]
+		r.entries[key] = recentEntry{score: entry.decayed(now) + 1, updated: now}
+	}
+	r.prune(now)
+}
+
+// scores returns the current score of every remembered item
+func (r *recentCompletions) scores() map[string]float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	scores := make(map[string]float64, len(r.entries))
+	for key, entry := range r.entries {
+		scores[key] = entry.decayed(now)
+	}
+	return scores
+}
+
+// prune forgets the lowest scoring entries beyond maxRecentCompletions. The caller must hold r.mu
+func (r *recentCompletions) prune(now time.Time) {
+	for len(r.entries) > maxRecentCompletions {
+		lowest, lowestScore := "", math.Inf(1)
+		for key, entry := range r.entries {
+			if score := entry.decayed(now); score < lowestScore {
+				lowest, lowestScore = key, score
+			}
+		}
+		delete(r.entries, lowest)
+	}
+}
+
+func (e recentEntry) decayed(now time.Time) float64 {
+	if e.score == 0 {
+		return 0
+	}
+	return e.score * math.Pow(0.5, float64(now.Sub(e.updated))/float64(recentHalfLife))
+}
+
+// identifierAfter returns the identifier text starts with
+func identifierAfter(text string) string {
+	end := 0
+	for end < len(text) {
+		r, size := utf8.DecodeRuneInString(text[end:])
+		if !isIdentifierRune(r) {
+			break
+		}
+		end += size
+	}
+	return text[:end]
+}
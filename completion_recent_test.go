@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+// TestRecentlyUsedRanking checks an item accepted from a completion ranks first in the next,
+// and that edits not accepting an item leave the order as it was
+func TestRecentlyUsedRanking(t *testing.T) {
+	const text = "class Player : MonoBehaviour {\n    void M() {\n        transform.\n        transform.\n    }\n}\n"
+	unranked := []string{"position", "rotation", "scale"}
+	tests := []struct {
+		name     string
+		disabled bool
+		// changes are the didChanges following the first completion, at line 2 character 18
+		changes [][]TextDocumentContentChangeEvent
+		// next is where the second completion is asked
+		next protocol.Position
+		want []string
+	}{
+		{
+			name:    "accepted",
+			changes: [][]TextDocumentContentChangeEvent{{changeAt(2, 18, 2, 18, "scale")}},
+			next:    protocol.Position{Line: 3, Character: 18},
+			want:    []string{"scale", "position", "rotation"},
+		},
+		{
+			name:    "accepted with a using above",
+			changes: [][]TextDocumentContentChangeEvent{{changeAt(0, 0, 0, 0, "using UnityEngine;\n"), changeAt(3, 18, 3, 18, "scale")}},
+			next:    protocol.Position{Line: 4, Character: 18},
+			want:    []string{"scale", "position", "rotation"},
+		},
+		{
+			name:    "typed",
+			changes: [][]TextDocumentContentChangeEvent{{changeAt(2, 18, 2, 18, "s")}, {changeAt(2, 19, 2, 19, "c")}},
+			next:    protocol.Position{Line: 3, Character: 18},
+			want:    unranked,
+		},
+		{
+			name:    "edited elsewhere first",
+			changes: [][]TextDocumentContentChangeEvent{{changeAt(4, 5, 4, 5, "\n")}, {changeAt(2, 18, 2, 18, "scale")}},
+			next:    protocol.Position{Line: 3, Character: 18},
+			want:    unranked,
+		},
+		{
+			name:     "disabled",
+			disabled: true,
+			changes:  [][]TextDocumentContentChangeEvent{{changeAt(2, 18, 2, 18, "scale")}},
+			next:     protocol.Position{Line: 3, Character: 18},
+			want:     unranked,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": autoCompleteItems("rotation", "scale", "position")})
+			s, _ := newTestServer(t, fake)
+			configure(s, func(config *Config) { config.Completion.RecentlyUsed = !test.disabled })
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, text)
+
+			if got := labels(completeAt(t, s, uri, protocol.Position{Line: 2, Character: 18}, protocol.CompletionTriggerKindTriggerCharacter).Items); !reflect.DeepEqual(got, unranked) {
+				t.Fatalf("first completion = %v, want %v", got, unranked)
+			}
+			for i, changes := range test.changes {
+				s.handleDidChange(context.Background(), &DidChangeTextDocumentParams{
+					TextDocument:   protocol.VersionedTextDocumentIdentifier{TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri}, Version: int32(i + 2)},
+					ContentChanges: changes,
+				})
+			}
+			s.cache.forget(uri)
+
+			if got := labels(completeAt(t, s, uri, test.next, protocol.CompletionTriggerKindTriggerCharacter).Items); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("next completion = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRecentEntryDecayed(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name  string
+		entry recentEntry
+		want  float64
+	}{
+		{"just used", recentEntry{score: 2, updated: now}, 2},
+		{"a half-life ago", recentEntry{score: 2, updated: now.Add(-recentHalfLife)}, 1},
+		{"two half-lives ago", recentEntry{score: 2, updated: now.Add(-2 * recentHalfLife)}, 0.5},
+		{"never used", recentEntry{}, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.entry.decayed(now); math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("decayed = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestRecentCompletionsBounded checks the lowest scoring items are forgotten beyond
+// maxRecentCompletions
+func TestRecentCompletionsBounded(t *testing.T) {
+	r := newRecentCompletions()
+	now := time.Now()
+	for i := 0; i <= maxRecentCompletions; i++ {
+		// Each older than the next, so the first decayed most
+		r.entries[fmt.Sprintf("item%d", i)] = recentEntry{score: 1, updated: now.Add(-time.Duration(maxRecentCompletions-i) * time.Minute)}
+	}
+	r.entries["recent"] = recentEntry{score: 1, updated: now}
+	r.prune(now)
+
+	scores := r.scores()
+	if len(scores) != maxRecentCompletions {
+		t.Errorf("remembered %d items, want %d", len(scores), maxRecentCompletions)
+	}
+	if _, ok := scores["item0"]; ok {
+		t.Error("kept the least recently used item")
+	}
+	if _, ok := scores["recent"]; !ok {
+		t.Error("forgot the most recently used item")
+	}
+}
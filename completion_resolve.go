@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"go.lsp.dev/protocol"
+)
+
+const (
+	completionSourceOmniSharp = "omnisharp"
+	completionSourceUnity     = "unity"
+)
+
+// completionData is stashed in CompletionItem.Data so resolve can find the item again
+type completionData struct {
+	Source string `json:"source"`
+
+	// OmniSharp items: where completion was requested and which item this was
+	FileName       string `json:"fileName,omitempty"`
+	Line           uint32 `json:"line,omitempty"`
+	Column         uint32 `json:"column,omitempty"`
+	CompletionText string `json:"completionText,omitempty"`
+	DisplayText    string `json:"displayText,omitempty"`
+	// Symbol identifies the symbol across positions and buffer versions, e.g.
+	// "public static Vector3 Vector3.zero { get; }"; empty when OmniSharp didn't describe it
+	Symbol string `json:"symbol,omitempty"`
+	// Overloads are the signatures of every overload of a collapsed method item
+	Overloads []string `json:"overloads,omitempty"`
+	// Origin is the token of the file and position above once compressed out of the item, see
+	// compressCompletionData
+	Origin uint64 `json:"origin,omitempty"`
+
+	// Unity items: the attribute name
+	Name string `json:"name,omitempty"`
+}
+
+// decodeCompletionData recovers our data from an item sent back by the client, which has
+// turned it into generic JSON: a map, raw bytes, or for some clients a string holding the
+// object. OmniSharp data without a file name had it compressed into origins, under the
+// token it carries. Items without data, or with data we didn't produce, yield false
+func decodeCompletionData(raw interface{}, origins *completionOrigins) (*completionData, bool) {
+	var data completionData
+	if !unmarshalData(raw, &data) {
+		return nil, false
+	}
+	if data.Source == completionSourceOmniSharp && data.FileName == "" && data.Origin != 0 && origins != nil {
+		if origin, ok := origins.get(data.Origin); ok {
+			data.FileName, data.Line, data.Column = origin.FileName, origin.Line, origin.Column
+			data.Origin = 0
+		}
+	}
+
+	switch data.Source {
+	case completionSourceOmniSharp:
+		return &data, data.FileName != "" && data.CompletionText != ""
+	case completionSourceUnity:
+		return &data, data.Name != ""
+	default:
+		return nil, false
+	}
+}
+
+// unmarshalData decodes data the client sent back, in whichever generic form, into v
+func unmarshalData(raw interface{}, v interface{}) bool {
+	if raw == nil {
+		return false
+	}
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return false
+	}
+	var nested string
+	if json.Unmarshal(encoded, &nested) == nil {
+		encoded = []byte(nested)
+	}
+	return json.Unmarshal(encoded, v) == nil
+}
+
+// handleCompletionResolve fills in documentation for an item. Items we can't resolve are
+// returned unchanged rather than failing
+func (s *Server) handleCompletionResolve(ctx context.Context, item *CompletionItem) (*CompletionItem, error) {
+	data, ok := decodeCompletionData(item.Data, s.completionOrigins)
+	if !ok {
+		return item, nil
+	}
+
+	switch data.Source {
+	case completionSourceUnity:
+		if documentation, ok := unityAttributeDocumentation(data.Name); ok {
+			item.Documentation = s.localize(documentation)
+		}
+
+	case completionSourceOmniSharp:
+		documentation, ok := s.documentation.get(data.Symbol)
+		if !ok {
+			documentation = s.resolveDocumentation(ctx, item.Label, data)
+		}
+		if documentation = withOverloads(documentation, data.Overloads); documentation != "" {
+			item.Documentation = s.completionDocumentation(documentation)
+		}
+	}
+
+	return item, nil
+}
+
+// resolveDocumentation asks OmniSharp for the documentation of an item, remembering it by symbol
+func (s *Server) resolveDocumentation(ctx context.Context, label string, data *completionData) string {
+	omnisharp := s.completionBackend()
+	if omnisharp == nil {
+		return ""
+	}
+	resolved, err := resolveOmniSharpItem(ctx, omnisharp, data)
+	if err != nil {
+		// Missing documentation isn't worth an error popup
+		log.Printf("failed to resolve completion %q: %v", label, err)
+		return ""
+	}
+	if resolved == nil {
+		return ""
+	}
+	// Keeps the <returns> text, so users see what a method gives back before accepting it
+	documentation := xmlDocToMarkdown(resolved.Documentation)
+	s.documentation.put(data.Symbol, documentation)
+	return documentation
+}
+
+// completionDocumentation sends markdown documentation as such to clients that render it
+func (s *Server) completionDocumentation(markdown string) interface{} {
+	textDocument := s.capabilities.TextDocument
+	if textDocument == nil || textDocument.Completion == nil || textDocument.Completion.CompletionItem == nil {
+		return markdown
+	}
+	for _, format := range textDocument.Completion.CompletionItem.DocumentationFormat {
+		if format == protocol.Markdown {
+			return protocol.MarkupContent{Kind: protocol.Markdown, Value: markdown}
+		}
+	}
+	return markdown
+}
+
+// documentationCache remembers resolved documentation by symbol, since scrolling through the
+// completion popup resolves the same members over and over and their docs rarely change. It
+// holds at most capacity symbols, forgetting the oldest first
+type documentationCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]string
+	order    []string
+}
+
+func newDocumentationCache(capacity int) *documentationCache {
+	return &documentationCache{
+		capacity: capacity,
+		entries:  make(map[string]string),
+	}
+}
+
+func (c *documentationCache) get(symbol string) (string, bool) {
+	if symbol == "" {
+		return "", false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	documentation, ok := c.entries[symbol]
+	return documentation, ok
+}
+
+func (c *documentationCache) put(symbol, documentation string) {
+	if symbol == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[symbol]; !ok {
+		if len(c.order) >= c.capacity {
+			delete(c.entries, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, symbol)
+	}
+	c.entries[symbol] = documentation
+}
+
+// clear forgets everything, e.g. when settings affecting documentation change
+func (c *documentationCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]string)
+	c.order = nil
+}
+
+// resolveOmniSharpItem asks for documentation at the original completion position, narrowed
+// to the item's text, since /autocomplete only computes documentation when asked to
+func resolveOmniSharpItem(ctx context.Context, omnisharp *OmniSharpClient, data *completionData) (*AutoCompleteResponse, error) {
+	response, err := omnisharp.SendRequest(ctx, "/autocomplete", map[string]interface{}{
+		"FileName":       data.FileName,
+		"Line":           data.Line,
+		"Column":         data.Column,
+		"WordToComplete": data.CompletionText,
+		"WantDocumentationForEveryCompletionResult": true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var omnisharpResponse []AutoCompleteResponse
+	if err := json.Unmarshal(response, &omnisharpResponse); err != nil {
+		return nil, err
+	}
+
+	for i, candidate := range omnisharpResponse {
+		if candidate.CompletionText == data.CompletionText && candidate.DisplayText == data.DisplayText {
+			return &omnisharpResponse[i], nil
+		}
+	}
+	return nil, nil
+}
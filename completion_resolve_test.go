@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestCompletionResolveWithoutOmniSharpData(t *testing.T) {
+	serializeField := unityAttributes[0]
+	tests := []struct {
+		name string
+		data interface{}
+		// wantDocumentation is found in the resolved item's documentation, or else none is
+		wantDocumentation string
+	}{
+		{name: "no data"},
+		{name: "data of someone else", data: map[string]interface{}{"id": 4}},
+		{name: "OmniSharp data missing the item", data: map[string]interface{}{"source": completionSourceOmniSharp, "fileName": "/project/A.cs"}},
+		{name: "unknown source", data: map[string]interface{}{"source": "elsewhere", "name": serializeField.Name}},
+		{
+			name:              "Unity attribute",
+			data:              map[string]interface{}{"source": completionSourceUnity, "name": serializeField.Name},
+			wantDocumentation: serializeField.Documentation,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{})
+			s, _ := newTestServer(t, fake)
+			item := &CompletionItem{CompletionItem: protocol.CompletionItem{Label: "Thing", Kind: protocol.CompletionItemKindClass, Data: test.data}}
+			before := *item
+
+			resolved, err := s.handleCompletionResolve(context.Background(), item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if calls := fake.callCount("/autocomplete"); calls != 0 {
+				t.Errorf("asked OmniSharp to resolve it")
+			}
+			documentation, _ := json.Marshal(resolved.Documentation)
+			if test.wantDocumentation == "" {
+				if !reflect.DeepEqual(*resolved, before) {
+					t.Errorf("resolved to %+v, want it unchanged", resolved)
+				}
+				return
+			}
+			if !strings.Contains(string(documentation), test.wantDocumentation) {
+				t.Errorf("documentation = %s, want %q", documentation, test.wantDocumentation)
+			}
+		})
+	}
+}
+
+func TestDecodeCompletionData(t *testing.T) {
+	want := &completionData{
+		Source:         completionSourceOmniSharp,
+		FileName:       "/project/Assets/Player.cs",
+		Line:           4,
+		Column:         8,
+		CompletionText: "speed",
+		DisplayText:    "speed",
+	}
+	encoded, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var generic map[string]interface{}
+	json.Unmarshal(encoded, &generic)
+
+	tests := []struct {
+		name   string
+		raw    interface{}
+		wantOK bool
+	}{
+		{"as we sent it", want, true},
+		{"a map", generic, true},
+		{"raw JSON", json.RawMessage(encoded), true},
+		{"a string holding the object", string(encoded), true},
+		{"nothing", nil, false},
+		{"a string of something else", "speed", false},
+		{"a number", 4, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, ok := decodeCompletionData(test.raw, nil)
+			if ok != test.wantOK {
+				t.Fatalf("decoded %+v, %v, want ok %v", data, ok, test.wantOK)
+			}
+			if ok && !reflect.DeepEqual(data, want) {
+				t.Errorf("decoded %+v, want %+v", data, want)
+			}
+		})
+	}
+}
+
+func TestDocumentationCache(t *testing.T) {
+	type put struct{ symbol, documentation string }
+	tests := []struct {
+		name string
+		puts []put
+		want map[string]string
+	}{
+		{"within capacity", []put{{"a", "A"}, {"b", "B"}}, map[string]string{"a": "A", "b": "B"}},
+		{"oldest evicted", []put{{"a", "A"}, {"b", "B"}, {"c", "C"}}, map[string]string{"b": "B", "c": "C"}},
+		{"updated in place", []put{{"a", "A"}, {"b", "B"}, {"a", "A2"}}, map[string]string{"a": "A2", "b": "B"}},
+		{"without a symbol", []put{{"", "A"}}, map[string]string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cache := newDocumentationCache(2)
+			for _, put := range test.puts {
+				cache.put(put.symbol, put.documentation)
+			}
+			for _, symbol := range []string{"", "a", "b", "c"} {
+				documentation, ok := cache.get(symbol)
+				if want, wantOK := test.want[symbol]; ok != wantOK || documentation != want {
+					t.Errorf("get(%q) = %q, %v, want %q, %v", symbol, documentation, ok, want, wantOK)
+				}
+			}
+			cache.clear()
+			if _, ok := cache.get("b"); ok {
+				t.Error("clear kept documentation")
+			}
+		})
+	}
+}
+
+// TestResolvedDocumentationCached resolves a member twice, from lists of different positions
+// and versions, and once more after the settings change
+func TestResolvedDocumentationCached(t *testing.T) {
+	fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": []AutoCompleteResponse{
+		{CompletionText: "speed", DisplayText: "speed", Documentation: "How fast it moves"},
+	}})
+	s, _ := newTestServer(t, fake)
+	resolve := func(line uint32, symbol string) {
+		t.Helper()
+		data := omnisharpData("speed", line, 8)
+		data.Symbol = symbol
+		resolved, err := s.handleCompletionResolve(context.Background(), &CompletionItem{CompletionItem: protocol.CompletionItem{Label: "speed", Data: data}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if documentation, _ := json.Marshal(resolved.Documentation); !strings.Contains(string(documentation), "How fast it moves") {
+			t.Errorf("documentation = %s", documentation)
+		}
+	}
+
+	steps := []struct {
+		name   string
+		line   uint32
+		symbol string
+		// settings sends a configuration change first
+		settings  bool
+		wantCalls int
+	}{
+		{"first resolve", 4, "float Player.speed", false, 1},
+		{"same symbol elsewhere", 9, "float Player.speed", false, 1},
+		{"another symbol", 4, "float Enemy.speed", false, 2},
+		{"after a configuration change", 4, "float Player.speed", true, 3},
+	}
+	for _, step := range steps {
+		if step.settings {
+			s.handleDidChangeConfiguration(context.Background(), &protocol.DidChangeConfigurationParams{Settings: map[string]interface{}{}})
+		}
+		resolve(step.line, step.symbol)
+		if calls := fake.callCount("/autocomplete"); calls != step.wantCalls {
+			t.Errorf("%s: asked OmniSharp %d times in all, want %d", step.name, calls, step.wantCalls)
+		}
+	}
+}
+
+func TestResolvedDocumentationReturns(t *testing.T) {
+	fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": []AutoCompleteResponse{{
+		CompletionText: "GetComponent",
+		DisplayText:    "GetComponent",
+		Documentation:  `<summary>Gets a component of the game object.</summary><param name="type">The type to look for.</param><returns>The component, or null if there is none.</returns>`,
+	}}})
+	s, _ := newTestServer(t, fake)
+
+	resolved, err := s.handleCompletionResolve(context.Background(), &CompletionItem{CompletionItem: protocol.CompletionItem{
+		Label: "GetComponent",
+		Data:  omnisharpData("GetComponent", 4, 8),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Gets a component of the game object.\n\nReturns: The component, or null if there is none."
+	if resolved.Documentation != want {
+		t.Errorf("documentation = %#v, want %q", resolved.Documentation, want)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+	"log"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+// resourceArgument matches a caret in the path argument of Resources.Load and the like,
+// capturing the method
+var resourceArgument = regexp.MustCompile(`\bResources\s*\.\s*(Load|LoadAll|LoadAsync)\s*(?:<[^>]*>)?\s*\(\s*"([^"\\]*)$`)
+
+// resourceListingTTL is how long a listing is trusted when the client can't report file
+// changes
+const resourceListingTTL = 10 * time.Second
+
+// resource is a file or folder under a Resources folder, by the path Unity loads it with
+type resource struct {
+	path  string
+	asset string
+	dir   bool
+}
+
+// resourceListing is the resources of a Unity project as read at listed
+type resourceListing struct {
+	resources []resource
+	listed    time.Time
+}
+
+// resourceIndex caches the resources of each Unity project, dropped when the client reports
+// a change under a Resources folder
+type resourceIndex struct {
+	mu       sync.Mutex
+	listings map[string]resourceListing
+	// watched is set once the client reports changes, so listings don't expire
+	watched bool
+}
+
+func newResourceIndex() *resourceIndex {
+	return &resourceIndex{listings: make(map[string]resourceListing)}
+}
+
+// list returns the resources of the Unity project at root, reading them when not cached
+func (r *resourceIndex) list(root string) []resource {
+	r.mu.Lock()
+	listing, ok := r.listings[root]
+	fresh := ok && (r.watched || time.Since(listing.listed) < resourceListingTTL)
+	r.mu.Unlock()
+	if fresh {
+		return listing.resources
+	}
+
+	listing = resourceListing{resources: readResources(filepath.Join(root, "Assets")), listed: time.Now()}
+	r.mu.Lock()
+	r.listings[root] = listing
+	r.mu.Unlock()
+	return listing.resources
+}
+
+func (r *resourceIndex) invalidate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listings = make(map[string]resourceListing)
+}
+
+func (r *resourceIndex) setWatched() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.watched = true
+}
+
+// readResources lists the files and folders of every Resources folder under assets, by their
+// path relative to it without extension, as Resources.Load expects. Folders nested in a
+// Resources folder give their contents a path of their own
+func readResources(assets string) []resource {
+	var resources []resource
+	filepath.WalkDir(assets, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || file == assets {
+			return nil
+		}
+		rel, err := filepath.Rel(assets, file)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		at := strings.LastIndex("/"+rel, "/Resources/")
+		if at < 0 || (!entry.IsDir() && strings.HasSuffix(rel, ".meta")) {
+			return nil
+		}
+		name := rel[at+len("Resources/"):]
+		if !entry.IsDir() {
+			name = strings.TrimSuffix(name, path.Ext(name))
+		}
+		resources = append(resources, resource{path: name, asset: "Assets/" + rel, dir: entry.IsDir()})
+		return nil
+	})
+	sort.SliceStable(resources, func(i, j int) bool { return resources[i].path < resources[j].path })
+	return resources
+}
+
+// resourcePathCompletions offers the paths of the project's resources in the string argument
+// of Resources.Load and the like, with the asset as detail. LoadAll also takes folders. It
+// reports false when the caret isn't in such a string, or the file isn't in a Unity project
+func (s *Server) resourcePathCompletions(doc Document, pos protocol.Position) ([]CompletionItem, bool) {
+	line := lineAt(doc.Text, pos.Line)
+	match := resourceArgument.FindStringSubmatch(line[:utf16ToByteOffset(line, pos.Character)])
+	if match == nil {
+		return nil, false
+	}
+	root, ok := unityProjectRoot(doc.URI.Filename(), s.rootPath)
+	if !ok {
+		return nil, false
+	}
+
+	var names []string
+	resources := make(map[string]resource)
+	for _, resource := range s.resources.list(root) {
+		if resource.dir && match[1] != "LoadAll" {
+			continue
+		}
+		if _, ok := resources[resource.path]; !ok {
+			names = append(names, resource.path)
+			resources[resource.path] = resource
+		}
+	}
+	items := stringContentItems(line, pos, names, "")
+	for i := range items {
+		resource := resources[items[i].Label]
+		items[i].Detail = resource.asset
+		items[i].Kind = protocol.CompletionItemKindFile
+		if resource.dir {
+			items[i].Kind = protocol.CompletionItemKindFolder
+		}
+	}
+	return items, true
+}
+
+// isResourceChange reports whether changes touch a Resources folder
+func isResourceChange(changes []*protocol.FileEvent) bool {
+	for _, change := range changes {
+		if strings.Contains(filepath.ToSlash(change.URI.Filename())+"/", "/Resources/") {
+			return true
+		}
+	}
+	return false
+}
+
+// watchResources asks the client for the changes under Resources folders, which drop the
+// cached listings
+func (s *Server) watchResources(ctx context.Context) {
+	err := s.client.RegisterCapability(ctx, &protocol.RegistrationParams{
+		Registrations: []protocol.Registration{{
+			ID:     "unity-lsp.resources",
+			Method: protocol.MethodWorkspaceDidChangeWatchedFiles,
+			RegisterOptions: protocol.DidChangeWatchedFilesRegistrationOptions{
+				Watchers: []protocol.FileSystemWatcher{
+					{GlobPattern: "**/Resources/**"},
+				},
+			},
+		}},
+	})
+	if err != nil {
+		log.Printf("failed to watch resources: %v", err)
+		return
+	}
+	s.resources.setWatched()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+// resourceFiles are a Unity project with two Resources folders, one with a nested folder
+var resourceFiles = map[string]string{
+	"ProjectSettings/ProjectVersion.txt":          "",
+	"Assets/Resources/Enemy.prefab":               "",
+	"Assets/Resources/Enemy.prefab.meta":          "",
+	"Assets/Resources/Audio/Hit.wav":              "",
+	"Assets/Plugins/Fonts/Resources/Title.ttf":    "",
+	"Assets/Textures/Grass.png":                   "",
+	"Assets/Scripts/Spawner.cs":                   "",
+	"Assets/Resources/Audio/Music/Theme.ogg.meta": "",
+}
+
+func TestReadResources(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, resourceFiles)
+	want := []resource{
+		{path: "Audio", asset: "Assets/Resources/Audio", dir: true},
+		{path: "Audio/Hit", asset: "Assets/Resources/Audio/Hit.wav"},
+		{path: "Audio/Music", asset: "Assets/Resources/Audio/Music", dir: true},
+		{path: "Enemy", asset: "Assets/Resources/Enemy.prefab"},
+		{path: "Title", asset: "Assets/Plugins/Fonts/Resources/Title.ttf"},
+	}
+	if got := readResources(root + "/Assets"); !reflect.DeepEqual(got, want) {
+		t.Errorf("readResources = %+v, want %+v", got, want)
+	}
+}
+
+// TestResourcePathCompletions checks the path argument of Resources.Load is offered the
+// project's resources without their extension, and LoadAll their folders too
+func TestResourcePathCompletions(t *testing.T) {
+	files := []string{"Audio/Hit", "Enemy", "Title"}
+	tests := []struct {
+		name string
+		// line has | at the caret
+		line string
+		// want are the paths offered, none when OmniSharp completes the string
+		want []string
+	}{
+		{"Load", `        var prefab = Resources.Load("|");`, files},
+		{"generic Load", `        var prefab = Resources.Load<GameObject>("En|");`, files},
+		{"LoadAsync", `        var request = Resources.LoadAsync("|");`, files},
+		{"LoadAll", `        var clips = Resources.LoadAll<AudioClip>("|");`, []string{"Audio", "Audio/Hit", "Audio/Music", "Enemy", "Title"}},
+		{"other string", `        Debug.Log("|");`, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": []AutoCompleteResponse{}})
+			s, _ := newTestServer(t, fake)
+			writeFiles(t, s.rootPath, resourceFiles)
+			uri := testURI(s, "Assets/Scripts/Spawner.cs")
+			line, caret := caretIn(test.line)
+			openTestDocument(s, uri, "class Spawner\n{\n    void Start()\n    {\n"+line+"\n    }\n}\n")
+
+			list := completeAt(t, s, uri, protocol.Position{Line: 4, Character: caret.Character}, protocol.CompletionTriggerKindInvoked)
+			if test.want == nil {
+				if fake.callCount("/autocomplete") == 0 {
+					t.Error("OmniSharp not asked outside a resource path")
+				}
+				return
+			}
+			if got := labels(list.Items); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("completions = %q, want %q", got, test.want)
+			}
+			kinds := map[string]protocol.CompletionItemKind{"Audio": protocol.CompletionItemKindFolder, "Enemy": protocol.CompletionItemKindFile}
+			for _, item := range list.Items {
+				if kind, ok := kinds[item.Label]; ok && item.Kind != kind {
+					t.Errorf("%s is a %v, want a %v", item.Label, item.Kind, kind)
+				}
+				if item.Label == "Enemy" && item.Detail != "Assets/Resources/Enemy.prefab" {
+					t.Errorf("Enemy detailed %q, want its asset", item.Detail)
+				}
+			}
+		})
+	}
+}
+
+// TestResourceListingRefresh checks the cached listing is dropped when the client reports a
+// change under a Resources folder, and kept for other changes
+func TestResourceListingRefresh(t *testing.T) {
+	tests := []struct {
+		name    string
+		changed string
+		want    []string
+	}{
+		{"resource added", "Assets/Resources/Boss.prefab", []string{"Boss", "Enemy"}},
+		{"other file changed", "Assets/Textures/Grass.png", []string{"Enemy"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, _ := newTestServer(t, newFakeOmniSharp(t, nil))
+			s.resources.setWatched()
+			root := s.rootPath
+			writeFiles(t, root, map[string]string{"ProjectSettings/ProjectVersion.txt": "", "Assets/Resources/Enemy.prefab": ""})
+			paths := func() []string {
+				var paths []string
+				for _, resource := range s.resources.list(root) {
+					paths = append(paths, resource.path)
+				}
+				return paths
+			}
+			if got := paths(); !reflect.DeepEqual(got, []string{"Enemy"}) {
+				t.Fatalf("listed %q, want Enemy", got)
+			}
+
+			writeFiles(t, root, map[string]string{"Assets/Resources/Boss.prefab": ""})
+			s.handleDidChangeWatchedFiles(context.Background(), &protocol.DidChangeWatchedFilesParams{
+				Changes: []*protocol.FileEvent{{URI: testURI(s, test.changed), Type: protocol.FileChangeTypeCreated}},
+			})
+			if got := paths(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("listed %q, want %q", got, test.want)
+			}
+		})
+	}
+}
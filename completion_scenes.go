@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// sceneArgument matches a caret in the string argument of the SceneManager methods taking a
+// scene name
+var sceneArgument = regexp.MustCompile(`\bSceneManager\s*\.\s*(?:LoadScene|LoadSceneAsync|UnloadSceneAsync|GetSceneByName)\s*\(\s*"([^"\\]*)$`)
+
+// sceneNameCompletions offers the scenes of the build settings in the string argument of
+// SceneManager.LoadScene and the like, by name with their path as detail. Scenes disabled in
+// the build are left out, as they can't be loaded. It reports false when the caret isn't in
+// such a string, or the file isn't in a Unity project
+func sceneNameCompletions(doc Document, pos protocol.Position, rootPath string) ([]CompletionItem, bool) {
+	line := lineAt(doc.Text, pos.Line)
+	if !sceneArgument.MatchString(line[:utf16ToByteOffset(line, pos.Character)]) {
+		return nil, false
+	}
+	root, ok := unityProjectRoot(doc.URI.Filename(), rootPath)
+	if !ok {
+		return nil, false
+	}
+	data, err := os.ReadFile(filepath.Join(root, "ProjectSettings", "EditorBuildSettings.asset"))
+	if err != nil {
+		return nil, false
+	}
+
+	scenes := parseEditorBuildSettings(string(data))
+	names := make([]string, len(scenes))
+	paths := make(map[string]string, len(scenes))
+	for i, scene := range scenes {
+		names[i] = strings.TrimSuffix(path.Base(scene), path.Ext(scene))
+		if _, ok := paths[names[i]]; !ok {
+			paths[names[i]] = scene
+		}
+	}
+	items := stringContentItems(line, pos, names, "")
+	for i := range items {
+		items[i].Detail = paths[items[i].Label]
+	}
+	return items, true
+}
+
+// parseEditorBuildSettings reads the paths of the scenes enabled in the m_Scenes list of an
+// EditorBuildSettings.asset, in build order
+func parseEditorBuildSettings(text string) []string {
+	var scenes []string
+	inScenes, enabled := false, true
+	for _, line := range splitLines(text) {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasSuffix(trimmed, ":") && !strings.HasPrefix(line, "    ") {
+			inScenes = trimmed == "m_Scenes:"
+			continue
+		}
+		if !inScenes {
+			continue
+		}
+		if entry, ok := strings.CutPrefix(trimmed, "-"); ok {
+			trimmed, enabled = strings.TrimSpace(entry), true
+		}
+		if value, ok := strings.CutPrefix(trimmed, "enabled:"); ok {
+			enabled = strings.TrimSpace(value) != "0"
+		} else if value, ok := strings.CutPrefix(trimmed, "path:"); ok && enabled {
+			if value = strings.TrimSpace(value); value != "" {
+				scenes = append(scenes, value)
+			}
+		}
+	}
+	return scenes
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+// editorBuildSettings is a ProjectSettings/EditorBuildSettings.asset with a scene disabled in
+// the build
+const editorBuildSettings = "%YAML 1.1\n" +
+	"--- !u!1045 &1\n" +
+	"EditorBuildSettings:\n" +
+	"  m_ObjectHideFlags: 0\n" +
+	"  serializedVersion: 2\n" +
+	"  m_Scenes:\n" +
+	"  - enabled: 1\n" +
+	"    path: Assets/Scenes/Menu.unity\n" +
+	"    guid: 2cda990e2423bbf4892e6590ba056729\n" +
+	"  - enabled: 0\n" +
+	"    path: Assets/Scenes/Sandbox.unity\n" +
+	"    guid: 9fc0d4010bbf28b4594072e72b8655ab\n" +
+	"  - enabled: 1\n" +
+	"    path: Assets/Scenes/Levels/Level 1.unity\n" +
+	"    guid: 1e6a5bc1dba4d4c45a2ea0c56f0b9e66\n" +
+	"  m_configObjects: {}\n"
+
+func TestParseEditorBuildSettings(t *testing.T) {
+	want := []string{"Assets/Scenes/Menu.unity", "Assets/Scenes/Levels/Level 1.unity"}
+	if got := parseEditorBuildSettings(editorBuildSettings); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseEditorBuildSettings = %q, want %q", got, want)
+	}
+}
+
+// TestSceneNameCompletions checks the strings naming a scene are offered the scenes enabled in
+// the build settings, by name with their path, and completed by OmniSharp elsewhere
+func TestSceneNameCompletions(t *testing.T) {
+	tests := []struct {
+		name string
+		// line has | at the caret
+		line     string
+		settings bool
+		// want are the names offered, none when OmniSharp completes the string
+		want []string
+	}{
+		{"LoadScene", `        SceneManager.LoadScene("|");`, true, []string{"Level 1", "Menu"}},
+		{"LoadSceneAsync begun", `        SceneManager.LoadSceneAsync("Me|", LoadSceneMode.Additive);`, true, []string{"Level 1", "Menu"}},
+		{"GetSceneByName", `        var scene = SceneManager.GetSceneByName("|");`, true, []string{"Level 1", "Menu"}},
+		{"other string", `        Debug.Log("|");`, true, nil},
+		{"no build settings", `        SceneManager.LoadScene("|");`, false, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": []AutoCompleteResponse{}})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Assets/Scripts/Menu.cs")
+			line, caret := caretIn(test.line)
+			text := "class Menu\n{\n    void Play()\n    {\n" + line + "\n    }\n}\n"
+			files := map[string]string{"Assets/Scripts/Menu.cs": text, "ProjectSettings/ProjectVersion.txt": ""}
+			if test.settings {
+				files["ProjectSettings/EditorBuildSettings.asset"] = editorBuildSettings
+			}
+			writeFiles(t, s.rootPath, files)
+			openTestDocument(s, uri, text)
+
+			list := completeAt(t, s, uri, protocol.Position{Line: 4, Character: caret.Character}, protocol.CompletionTriggerKindInvoked)
+			if test.want == nil {
+				if fake.callCount("/autocomplete") == 0 {
+					t.Error("OmniSharp not asked outside a scene name")
+				}
+				return
+			}
+			if got := labels(list.Items); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("completions = %q, want %q", got, test.want)
+			}
+			paths := map[string]string{"Menu": "Assets/Scenes/Menu.unity", "Level 1": "Assets/Scenes/Levels/Level 1.unity"}
+			for _, item := range list.Items {
+				if item.Detail != paths[item.Label] {
+					t.Errorf("%s detailed %q, want %q", item.Label, item.Detail, paths[item.Label])
+				}
+			}
+		})
+	}
+}
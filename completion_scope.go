@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// frameworkNamespaces are the root namespaces of the .NET and Unity assemblies, whose symbols
+// completion.scope projectOnly leaves out
+var frameworkNamespaces = map[string]bool{
+	"System": true, "Microsoft": true, "Mono": true,
+	"UnityEngine": true, "UnityEditor": true, "Unity": true, "TMPro": true, "NUnit": true,
+}
+
+// filterFrameworkCompletions drops the OmniSharp items declared by framework assemblies, as
+// told by the namespace their symbol is qualified with. After a dot on a framework type, where
+// every member is the framework's, the list is kept whole. Keywords, items OmniSharp didn't
+// describe and our own items are kept
+func filterFrameworkCompletions(items []CompletionItem, memberAccess bool) []CompletionItem {
+	if memberAccess && !hasProjectMember(items) {
+		return items
+	}
+
+	filtered := items[:0]
+	for _, item := range items {
+		data, ok := item.Data.(*completionData)
+		if ok && data.Source == completionSourceOmniSharp && item.Kind != protocol.CompletionItemKindKeyword && frameworkSymbol(data.Symbol, data.CompletionText) {
+			continue
+		}
+		filtered = append(filtered, item)
+	}
+	return filtered
+}
+
+// hasProjectMember reports whether any OmniSharp item is a member declared in the project,
+// which makes the receiver of a member access one of its types. Extension methods don't tell,
+// since the project may extend framework types
+func hasProjectMember(items []CompletionItem) bool {
+	for _, item := range items {
+		data, ok := item.Data.(*completionData)
+		if !ok || data.Source != completionSourceOmniSharp || data.Symbol == "" || strings.HasPrefix(data.Symbol, "(extension)") {
+			continue
+		}
+		if !frameworkSymbol(data.Symbol, data.CompletionText) {
+			return true
+		}
+	}
+	return false
+}
+
+// frameworkSymbol reports whether a Roslyn symbol description qualifies name with a framework
+// namespace, as in "public Transform UnityEngine.Component.transform { get; }". Unqualified
+// symbols, such as locals and types of the global namespace, are the project's
+func frameworkSymbol(description, name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, word := range strings.Fields(description) {
+		i := strings.Index(word, "."+name)
+		if i < 0 {
+			continue
+		}
+		if rest := word[i+1+len(name):]; rest != "" && rest[0] != '<' && rest[0] != '(' {
+			continue
+		}
+		root, _, _ := strings.Cut(word[:i], ".")
+		return frameworkNamespaces[root]
+	}
+	return false
+}
+
+// afterMemberAccess reports whether offset follows a dot, ignoring the member being typed
+func afterMemberAccess(text string, offset int) bool {
+	before := text[:offset]
+	return strings.HasSuffix(strings.TrimSuffix(before, identifierBefore(before)), ".")
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestFrameworkSymbol(t *testing.T) {
+	tests := []struct {
+		description string
+		name        string
+		want        bool
+	}{
+		{"public Transform UnityEngine.Component.transform { get; }", "transform", true},
+		{"void UnityEngine.Debug.Log(object message)", "Log", true},
+		{"class System.Collections.Generic.List<T>", "List", true},
+		{"TMPro.TextMeshProUGUI", "TextMeshProUGUI", true},
+		{"private float Game.Player.speed", "speed", false},
+		{"void Player.Jump()", "Jump", false},
+		// A parameter of a framework type doesn't make the method the framework's
+		{"void Game.Player.Follow(UnityEngine.Transform target)", "Follow", false},
+		{"(local variable) int health", "health", false},
+		{"", "speed", false},
+		{"void UnityEngine.Debug.Log(object message)", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			if got := frameworkSymbol(test.description, test.name); got != test.want {
+				t.Errorf("frameworkSymbol(%q) = %v, want %v", test.name, got, test.want)
+			}
+		})
+	}
+}
+
+func TestAfterMemberAccess(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"transform.", true},
+		{"transform.pos", true},
+		{"transform", false},
+		{"var x = ", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			if got := afterMemberAccess(test.text, len(test.text)); got != test.want {
+				t.Errorf("afterMemberAccess = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestCompletionScope checks completion.scope projectOnly leaves the framework's symbols out
+// of identifier completion, but keeps every member of a framework type after a dot
+func TestCompletionScope(t *testing.T) {
+	identifiers := []AutoCompleteResponse{
+		{CompletionText: "speed", DisplayText: "speed", Kind: "Field", ReturnType: "float", Description: "private float Player.speed"},
+		{CompletionText: "Debug", DisplayText: "Debug", Kind: "Class", Description: "class UnityEngine.Debug"},
+		{CompletionText: "Math", DisplayText: "Math", Kind: "Class", Description: "class System.Math"},
+		{CompletionText: "return", DisplayText: "return", Kind: "Keyword"},
+	}
+	frameworkMembers := []AutoCompleteResponse{
+		{CompletionText: "position", DisplayText: "position", Kind: "Property", ReturnType: "Vector3", Description: "Vector3 UnityEngine.Transform.position { get; set; }"},
+		{CompletionText: "Rotate", DisplayText: "Rotate", Kind: "Method", ReturnType: "void", Description: "void UnityEngine.Transform.Rotate(Vector3 eulers)"},
+		{CompletionText: "Reset", DisplayText: "Reset", Kind: "Method", ReturnType: "void", Description: "(extension) void TransformExtensions.Reset(this Transform transform)"},
+	}
+	projectMembers := []AutoCompleteResponse{
+		{CompletionText: "speed", DisplayText: "speed", Kind: "Field", ReturnType: "float", Description: "private float Player.speed"},
+		{CompletionText: "GetComponent", DisplayText: "GetComponent", Kind: "Method", ReturnType: "T", Description: "T UnityEngine.Component.GetComponent<T>()"},
+	}
+	tests := []struct {
+		name  string
+		scope string
+		line  string
+		items []AutoCompleteResponse
+		want  []string
+	}{
+		{"all", completionScopeAll, "        ", identifiers, []string{"speed", "Debug", "Math", "return"}},
+		{"identifiers", completionScopeProjectOnly, "        ", identifiers, []string{"speed", "return"}},
+		{"members of a framework type", completionScopeProjectOnly, "        transform.", frameworkMembers, []string{"position", "Rotate", "Reset"}},
+		{"members of a project type", completionScopeProjectOnly, "        player.", projectMembers, []string{"speed"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": test.items})
+			s, _ := newTestServer(t, fake)
+			configure(s, func(config *Config) { config.Completion.Scope = test.scope })
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, "class Player\n{\n    void Update()\n    {\n"+test.line+"\n    }\n}\n")
+
+			list := completeAt(t, s, uri, protocol.Position{Line: 4, Character: uint32(len(test.line))}, protocol.CompletionTriggerKindInvoked)
+			got := make(map[string]bool)
+			for _, label := range labels(list.Items) {
+				got[label] = true
+			}
+			want := make(map[string]bool)
+			for _, label := range test.want {
+				want[label] = true
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("completions = %v, want %v", labels(list.Items), test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// snippetElement matches the tab stops, placeholders and choices of snippet syntax, capturing
+// the default text of placeholders and the first option of choices. Escapes are matched too,
+// so an escaped $ doesn't start a tab stop
+var snippetElement = regexp.MustCompile(`\\.|\$(?:\d+|\{\d+\}|\{\d+:((?:[^{}\\]|\\.)*)\}|\{\d+\|([^,|]*)[^}]*\})`)
+
+// snippetToPlainText inserts what a snippet would before the user edits it: placeholders
+// become their default text, tab stops disappear and escapes are undone. Nested
+// placeholders are expanded from the inside out
+func snippetToPlainText(snippet string) string {
+	for {
+		expanded := snippetElement.ReplaceAllStringFunc(snippet, func(element string) string {
+			if element[0] == '\\' {
+				return element
+			}
+			match := snippetElement.FindStringSubmatch(element)
+			return match[1] + match[2]
+		})
+		if expanded == snippet {
+			break
+		}
+		snippet = expanded
+	}
+	return strings.NewReplacer(`\$`, "$", `\}`, "}", `\\`, `\`).Replace(snippet)
+}
+
+// plainTextCompletions turns the snippet items of a list into plain text ones for clients
+// without snippet support, which would otherwise insert the snippet syntax literally
+func (s *Server) plainTextCompletions(items []CompletionItem) []CompletionItem {
+	if s.supportsSnippets() {
+		return items
+	}
+	for i, item := range items {
+		if item.InsertTextFormat != protocol.InsertTextFormatSnippet {
+			continue
+		}
+		items[i].InsertText = snippetToPlainText(item.InsertText)
+		items[i].TextEditText = snippetToPlainText(item.TextEditText)
+		if edit, ok := item.TextEdit.(*protocol.TextEdit); ok {
+			edit.NewText = snippetToPlainText(edit.NewText)
+		}
+		items[i].InsertTextFormat = protocol.InsertTextFormatPlainText
+	}
+	return items
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestSnippetToPlainText(t *testing.T) {
+	tests := []struct {
+		snippet string
+		want    string
+	}{
+		{"Jump()", "Jump()"},
+		{"space: $0", "space: "},
+		{"namespace ${1:Game}\n{\n    $0\n}", "namespace Game\n{\n    \n}"},
+		{"GetComponent<${1:T}>()$0", "GetComponent<T>()"},
+		{"${1:outer ${2:inner}}", "outer inner"},
+		{"${1|Start,Update|}()", "Start()"},
+		{"${1}x$2", "x"},
+		{`cost \$5 \} \\`, `cost $5 } \`},
+	}
+	for _, test := range tests {
+		if got := snippetToPlainText(test.snippet); got != test.want {
+			t.Errorf("snippetToPlainText(%q) = %q, want %q", test.snippet, got, test.want)
+		}
+	}
+}
+
+// TestCompletionWithoutSnippetSupport checks no completion inserts snippet syntax for clients
+// that would insert it literally, while clients supporting snippets still get them
+func TestCompletionWithoutSnippetSupport(t *testing.T) {
+	contexts := []struct {
+		name string
+		// text has | at the caret
+		text string
+		// typeLookup is OmniSharp's description of the symbol before the caret
+		typeLookup string
+	}{
+		{name: "namespace declaration", text: "namespace |"},
+		{name: "event handler", text: "class Player { event Action<int> OnDeath; void Start() { OnDeath += | } }", typeLookup: "event Action<int> Player.OnDeath"},
+		{name: "fallback value", text: "class Player { Enemy? target; void Start() { target | } }", typeLookup: "Enemy? Player.target"},
+		{name: "documentation tag", text: "class Player {\n    /// <|\n    void Jump(float height) { }\n}"},
+	}
+	for _, context := range contexts {
+		for _, snippets := range []bool{true, false} {
+			name := context.name
+			if !snippets {
+				name += ", no snippet support"
+			}
+			t.Run(name, func(t *testing.T) {
+				fake := newFakeOmniSharp(t, map[string]interface{}{
+					"/autocomplete": []AutoCompleteResponse{{CompletionText: "Jump", DisplayText: "Jump", Kind: "Method", MethodHeader: "Jump(float height)"}},
+					"/typelookup":   TypeLookupResponse{Type: context.typeLookup},
+				})
+				s, _ := newTestServer(t, fake)
+				configure(s, func(config *Config) { config.Completion.NullCoalescing = true })
+				s.capabilities.TextDocument = &protocol.TextDocumentClientCapabilities{Completion: &protocol.CompletionTextDocumentClientCapabilities{
+					CompletionItem: &protocol.CompletionTextDocumentClientCapabilitiesItem{SnippetSupport: snippets},
+				}}
+				uri := testURI(s, "Player.cs")
+				offset := strings.Index(context.text, "|")
+				text := strings.Replace(context.text, "|", "", 1)
+				openTestDocument(s, uri, text+"\n")
+
+				list := completeAt(t, s, uri, positionAt(text, offset), protocol.CompletionTriggerKindInvoked)
+				found := false
+				for _, item := range list.Items {
+					inserted := []string{item.InsertText, item.TextEditText}
+					if edit, ok := item.TextEdit.(*protocol.TextEdit); ok {
+						inserted = append(inserted, edit.NewText)
+					}
+					syntax := false
+					for _, text := range inserted {
+						syntax = syntax || strings.Contains(text, "${") || strings.Contains(text, "$0")
+					}
+					snippet := item.InsertTextFormat == protocol.InsertTextFormatSnippet
+					found = found || snippet && syntax
+					if !snippets && (snippet || syntax) {
+						t.Errorf("%s inserts %q as %v", item.Label, inserted, item.InsertTextFormat)
+					}
+				}
+				if snippets && !found {
+					t.Errorf("completed %v, want a snippet", labels(list.Items))
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+var (
+	// tagArgument matches a caret in the string argument of a call taking a tag, or a string
+	// compared with or assigned to a tag
+	tagArgument = regexp.MustCompile(`(?:\b(?:CompareTag|FindWithTag|FindGameObjectWithTag|FindGameObjectsWithTag)\s*\(\s*|\btag\s*(?:==|!=|=)\s*)"([^"\\]*)$`)
+	// layerArgument matches a caret in a string argument of a call taking layer names
+	layerArgument = regexp.MustCompile(`\bLayerMask\s*\.\s*(?:GetMask\s*\(\s*(?:"[^"]*"\s*,\s*)*|NameToLayer\s*\(\s*)"([^"\\]*)$`)
+)
+
+// builtinTags are the tags every Unity project has, which TagManager.asset doesn't list
+var builtinTags = []string{"Untagged", "Respawn", "Finish", "EditorOnly", "MainCamera", "Player", "GameController"}
+
+// tagLayerCompletions offers the tags or layers of the Unity project in the string argument of
+// CompareTag, LayerMask.GetMask and the like, replacing the string's contents. It reports
+// false when the caret isn't in such a string
+func tagLayerCompletions(doc Document, pos protocol.Position, rootPath string) ([]CompletionItem, bool) {
+	line := lineAt(doc.Text, pos.Line)
+	before := line[:utf16ToByteOffset(line, pos.Character)]
+
+	var names []string
+	detail := ""
+	if tagArgument.MatchString(before) {
+		tags, _ := readTagManager(doc.URI.Filename(), rootPath)
+		names, detail = append(append([]string{}, builtinTags...), tags...), "Tag"
+	} else if layerArgument.MatchString(before) {
+		_, layers := readTagManager(doc.URI.Filename(), rootPath)
+		names, detail = layers, "Layer"
+	} else {
+		return nil, false
+	}
+
+	return stringContentItems(line, pos, names, detail), true
+}
+
+// stringContentItems offers names replacing the contents of the string literal the caret, at
+// pos on line, is in
+func stringContentItems(line string, pos protocol.Position, names []string, detail string) []CompletionItem {
+	caret := utf16ToByteOffset(line, pos.Character)
+	before := line[:caret]
+	typed := before[strings.LastIndexByte(before, '"')+1:]
+	start := pos
+	start.Character -= byteToUTF16Offset(typed, len(typed))
+	end := pos
+	if closing := strings.IndexByte(line[caret:], '"'); closing >= 0 {
+		end.Character = byteToUTF16Offset(line, caret+closing)
+	}
+
+	items := []CompletionItem{}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		items = append(items, CompletionItem{
+			CompletionItem: protocol.CompletionItem{
+				Label:      name,
+				Kind:       protocol.CompletionItemKindConstant,
+				Detail:     detail,
+				InsertText: name,
+			},
+			TextEdit: &protocol.TextEdit{Range: protocol.Range{Start: start, End: end}, NewText: name},
+		})
+	}
+	return items
+}
+
+// readTagManager reads the custom tags and the named layers of the Unity project path belongs
+// to, from its ProjectSettings/TagManager.asset
+func readTagManager(path, rootPath string) (tags, layers []string) {
+	root, ok := unityProjectRoot(path, rootPath)
+	if !ok {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Join(root, "ProjectSettings", "TagManager.asset"))
+	if err != nil {
+		return nil, nil
+	}
+	return parseTagManager(string(data))
+}
+
+// unityProjectRoot finds the Unity project path belongs to: the nearest folder up to rootPath
+// holding both Assets and ProjectSettings
+func unityProjectRoot(path, rootPath string) (string, bool) {
+	for dir := filepath.Dir(path); ; {
+		if isDir(filepath.Join(dir, "Assets")) && isDir(filepath.Join(dir, "ProjectSettings")) {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir || dir == rootPath {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// parseTagManager reads the tags and layers lists of a TagManager.asset, whose YAML lists
+// them one per line. Unnamed layer slots are left out
+func parseTagManager(text string) (tags, layers []string) {
+	var list *[]string
+	for _, line := range splitLines(text) {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "tags:":
+			list = &tags
+		case trimmed == "layers:":
+			list = &layers
+		case list != nil && strings.HasPrefix(trimmed, "-"):
+			name := strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))
+			name = strings.Trim(name, `'"`)
+			if name != "" {
+				*list = append(*list, name)
+			}
+		default:
+			list = nil
+		}
+	}
+	return tags, layers
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+// tagManager is a ProjectSettings/TagManager.asset with two custom tags and two named layers
+// among the unnamed slots
+const tagManager = "%YAML 1.1\n" +
+	"%TAG !u! tag:unity3d.com,2011:\n" +
+	"--- !u!78 &1\n" +
+	"TagManager:\n" +
+	"  serializedVersion: 2\n" +
+	"  tags:\n" +
+	"  - Enemy\n" +
+	"  - 'Pick Up'\n" +
+	"  layers:\n" +
+	"  - Default\n" +
+	"  - \n" +
+	"  - UI\n" +
+	"  - Ground\n" +
+	"  m_SortingLayers:\n" +
+	"  - name: Default\n"
+
+func TestParseTagManager(t *testing.T) {
+	tags, layers := parseTagManager(tagManager)
+	if want := []string{"Enemy", "Pick Up"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags = %q, want %q", tags, want)
+	}
+	if want := []string{"Default", "UI", "Ground"}; !reflect.DeepEqual(layers, want) {
+		t.Errorf("layers = %q, want %q", layers, want)
+	}
+}
+
+// TestTagLayerCompletions checks the strings naming a tag or a layer are offered the project's,
+// replacing what the string holds, without asking OmniSharp
+func TestTagLayerCompletions(t *testing.T) {
+	tags := append(append([]string{}, builtinTags...), "Enemy", "Pick Up")
+	tests := []struct {
+		name string
+		// line has | at the caret
+		line string
+		want []string
+		// wantStart and wantEnd are the characters the items replace
+		wantStart, wantEnd uint32
+	}{
+		{"CompareTag", `        if (other.CompareTag("|")) { }`, tags, 30, 30},
+		{"CompareTag begun", `        if (other.CompareTag("En|")) { }`, tags, 30, 32},
+		{"tag compared", `        if (other.tag == "|Pl") { }`, tags, 26, 28},
+		{"FindWithTag", `        GameObject.FindWithTag("|`, tags, 32, 32},
+		{"GetMask", `        int mask = LayerMask.GetMask("|");`, []string{"Default", "UI", "Ground"}, 38, 38},
+		{"second GetMask argument", `        int mask = LayerMask.GetMask("Ground", "|");`, []string{"Default", "UI", "Ground"}, 48, 48},
+		{"NameToLayer", `        int layer = LayerMask.NameToLayer("G|");`, []string{"Default", "UI", "Ground"}, 43, 44},
+		{"other string", `        Debug.Log("|");`, nil, 0, 0},
+		{"after the string", `        if (other.CompareTag("Enemy") && |) { }`, nil, 0, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": []AutoCompleteResponse{}})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Assets/Scripts/Player.cs")
+			line, caret := caretIn(test.line)
+			text := "class Player\n{\n    void OnTriggerEnter(Collider other)\n    {\n" + line + "\n    }\n}\n"
+			writeFiles(t, s.rootPath, map[string]string{"ProjectSettings/TagManager.asset": tagManager, "Assets/Scripts/Player.cs": text})
+			openTestDocument(s, uri, text)
+
+			list := completeAt(t, s, uri, protocol.Position{Line: 4, Character: caret.Character}, protocol.CompletionTriggerKindInvoked)
+			if test.want == nil {
+				if fake.callCount("/autocomplete") == 0 {
+					t.Error("OmniSharp not asked outside a tag or layer string")
+				}
+				return
+			}
+			got := make(map[string]bool)
+			for _, item := range list.Items {
+				got[item.Label] = true
+				edit := item.TextEdit.(*protocol.TextEdit)
+				if edit.Range.Start.Character != test.wantStart || edit.Range.End.Character != test.wantEnd || edit.NewText != item.Label {
+					t.Errorf("%s edits %+v, want characters %d to %d", item.Label, edit, test.wantStart, test.wantEnd)
+				}
+			}
+			want := make(map[string]bool)
+			for _, name := range test.want {
+				want[name] = true
+			}
+			if !reflect.DeepEqual(got, want) || len(list.Items) != len(test.want) {
+				t.Errorf("completions = %q, want %q", labels(list.Items), test.want)
+			}
+			if fake.callCount("/autocomplete") != 0 {
+				t.Error("asked OmniSharp to complete a tag or layer")
+			}
+		})
+	}
+}
+
+func TestReadTagManagerWithoutProject(t *testing.T) {
+	root := t.TempDir()
+	if tags, layers := readTagManager(root+"/Assets/Player.cs", root); tags != nil || layers != nil {
+		t.Errorf("read %q and %q, want nothing", tags, layers)
+	}
+}
@@ -0,0 +1,982 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+)
+
+func autoCompleteItems(names ...string) []AutoCompleteResponse {
+	items := make([]AutoCompleteResponse, len(names))
+	for i, name := range names {
+		items[i] = AutoCompleteResponse{CompletionText: name, DisplayText: name, Kind: "Property", ReturnType: "int"}
+	}
+	return items
+}
+
+// typeAt inserts text at at, as the didChange of a keystroke
+func typeAt(s *Server, uri protocol.DocumentURI, version int32, at protocol.Position, text string) {
+	s.handleDidChange(context.Background(), &DidChangeTextDocumentParams{
+		TextDocument:   protocol.VersionedTextDocumentIdentifier{TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri}, Version: version},
+		ContentChanges: []TextDocumentContentChangeEvent{{Range: &protocol.Range{Start: at, End: at}, Text: text}},
+	})
+}
+
+func completeAt(t *testing.T, s *Server, uri protocol.DocumentURI, pos protocol.Position, kind protocol.CompletionTriggerKind) *CompletionList {
+	t.Helper()
+	list, err := s.handleCompletion(context.Background(), &protocol.CompletionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}, Position: pos},
+		Context:                    &protocol.CompletionContext{TriggerKind: kind},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func labels(items []CompletionItem) []string {
+	var names []string
+	for _, item := range items {
+		names = append(names, item.Label)
+	}
+	return names
+}
+
+func TestIncompleteCompletionRequeryReusesSession(t *testing.T) {
+	fake := newFakeOmniSharp(t, map[string]interface{}{
+		"/autocomplete": autoCompleteItems("GameObject", "GameTime", "Gizmos", "Gravity"),
+	})
+	s, _ := newTestServer(t, fake)
+	// The list is capped, so it is incomplete and the editor requeries it as the user types
+	configure(s, func(config *Config) { config.Completion.MaxItems = 3 })
+	uri := testURI(s, "A.cs")
+	openTestDocument(s, uri, "class A { void M() { G } }")
+
+	first := completeAt(t, s, uri, protocol.Position{Line: 0, Character: 22}, protocol.CompletionTriggerKindInvoked)
+	if !first.IsIncomplete {
+		t.Fatalf("capped list IsIncomplete = false")
+	}
+
+	typeAt(s, uri, 2, protocol.Position{Line: 0, Character: 22}, "a")
+	second := completeAt(t, s, uri, protocol.Position{Line: 0, Character: 23}, protocol.CompletionTriggerKindTriggerForIncompleteCompletions)
+	typeAt(s, uri, 3, protocol.Position{Line: 0, Character: 23}, "m")
+	third := completeAt(t, s, uri, protocol.Position{Line: 0, Character: 24}, protocol.CompletionTriggerKindTriggerForIncompleteCompletions)
+
+	if calls := fake.callCount("/autocomplete"); calls != 1 {
+		t.Errorf("/autocomplete called %d times for two narrowing keystrokes, want 1", calls)
+	}
+	if got := strings.Join(labels(second.Items), ","); got != "GameObject,GameTime" {
+		t.Errorf("after Ga got %s", got)
+	}
+	if got := strings.Join(labels(third.Items), ","); got != "GameObject,GameTime" {
+		t.Errorf("after Gam got %s", got)
+	}
+	if !third.IsIncomplete {
+		t.Errorf("list narrowed from a truncated session IsIncomplete = false")
+	}
+
+	// An edit elsewhere changes what OmniSharp would offer
+	typeAt(s, uri, 4, protocol.Position{Line: 0, Character: 0}, "using System; ")
+	completeAt(t, s, uri, protocol.Position{Line: 0, Character: 38}, protocol.CompletionTriggerKindTriggerForIncompleteCompletions)
+	if calls := fake.callCount("/autocomplete"); calls != 2 {
+		t.Errorf("/autocomplete called %d times after an unrelated edit, want 2", calls)
+	}
+}
+
+func TestCompletionSessionLookup(t *testing.T) {
+	const uri = protocol.DocumentURI("file:///project/A.cs")
+	const text = "x = Ga;"
+	tests := []struct {
+		name string
+		// edit is the text OmniSharp is sent after the session was stored, if any
+		edit   string
+		text   string
+		offset int
+		want   bool
+	}{
+		{"same caret", "", text, 6, true},
+		{"identifier extended", "x = Gam;", "x = Gam;", 7, true},
+		{"identifier shortened", "x = G;", "x = G;", 5, false},
+		{"text before changed", "y = Gam;", "y = Gam;", 7, false},
+		{"text after changed", "x = Gam; ", "x = Gam; ", 7, false},
+		{"other identifier", "x = Ho;", "x = Ho;", 6, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &completionSession{}
+			session.store(uri, 1, text, 6, []CompletionItem{{CompletionItem: protocol.CompletionItem{Label: "GameObject"}}}, true)
+			generation := uint64(1)
+			if tt.edit != "" {
+				generation++
+				session.advance(uri, generation, tt.edit)
+			}
+			items, truncated, ok := session.lookup(uri, generation, tt.text, tt.offset)
+			if ok != tt.want {
+				t.Fatalf("lookup ok = %v, want %v", ok, tt.want)
+			}
+			if ok && (len(items) != 1 || !truncated) {
+				t.Errorf("lookup = %v, %v", items, truncated)
+			}
+		})
+	}
+
+	t.Run("generation moved on without the session", func(t *testing.T) {
+		session := &completionSession{}
+		session.store(uri, 1, text, 6, []CompletionItem{{}}, false)
+		if _, _, ok := session.lookup(uri, 2, text, 6); ok {
+			t.Error("lookup served items from before a buffer push it didn't follow")
+		}
+	})
+	t.Run("reset", func(t *testing.T) {
+		session := &completionSession{}
+		session.store(uri, 1, text, 6, []CompletionItem{{}}, false)
+		session.reset()
+		if _, _, ok := session.lookup(uri, 1, text, 6); ok {
+			t.Error("lookup served items after reset")
+		}
+	})
+}
+
+func TestSortCompletionItems(t *testing.T) {
+	item := func(label string, kind protocol.CompletionItemKind) CompletionItem {
+		return CompletionItem{CompletionItem: protocol.CompletionItem{Label: label, Kind: kind, InsertText: label}}
+	}
+	preselected := item("zebra", protocol.CompletionItemKindField)
+	preselected.Preselect = true
+	prioritized := item("yak", protocol.CompletionItemKindField)
+	prioritized.SortText = "0"
+	unprioritized := item("alpha", protocol.CompletionItemKindField)
+	unprioritized.SortText = "1"
+	overload := func(detail string) CompletionItem {
+		overload := item("Move", protocol.CompletionItemKindMethod)
+		overload.Detail = detail
+		return overload
+	}
+
+	tests := []struct {
+		name   string
+		items  []CompletionItem
+		recent map[string]float64
+		ranks  kindRanks
+		want   []string
+	}{
+		{
+			name:  "by label, ignoring case",
+			items: []CompletionItem{item("beta", 0), item("Alpha", 0), item("alpha", 0), item("Gamma", 0)},
+			want:  []string{"Alpha", "alpha", "beta", "Gamma"},
+		},
+		{
+			name:  "preselected first, then OmniSharp's priority",
+			items: []CompletionItem{unprioritized, prioritized, preselected},
+			want:  []string{"zebra", "yak", "alpha"},
+		},
+		{
+			name: "by kind rank, unranked kinds last",
+			items: []CompletionItem{
+				item("a", protocol.CompletionItemKindKeyword), item("b", protocol.CompletionItemKindMethod), item("c", protocol.CompletionItemKindField),
+			},
+			ranks: kindRanks{protocol.CompletionItemKindField: 0, protocol.CompletionItemKindMethod: 1},
+			want:  []string{"c", "b", "a"},
+		},
+		{
+			name:   "recently used before kind",
+			items:  []CompletionItem{item("a", protocol.CompletionItemKindField), item("b", protocol.CompletionItemKindMethod)},
+			recent: map[string]float64{recentKey(item("b", protocol.CompletionItemKindMethod)): 1},
+			ranks:  kindRanks{protocol.CompletionItemKindField: 0, protocol.CompletionItemKindMethod: 1},
+			want:   []string{"b", "a"},
+		},
+		{
+			name:  "overloads by detail",
+			items: []CompletionItem{overload("void Move(float)"), overload("void Move(int)"), overload("void Move()")},
+			want:  []string{"void Move()", "void Move(float)", "void Move(int)"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Equal items come out the same whichever order OmniSharp sent them in
+			for _, reversed := range []bool{false, true} {
+				items := append([]CompletionItem(nil), test.items...)
+				if reversed {
+					for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+						items[i], items[j] = items[j], items[i]
+					}
+				}
+				sortCompletionItems(items, test.recent, test.ranks)
+
+				var got []string
+				for i, item := range items {
+					// Overloads are told apart by their detail
+					if item.Detail != "" {
+						got = append(got, item.Detail)
+					} else {
+						got = append(got, item.Label)
+					}
+					if want := strconv.Itoa(i); len(items) < 10 && item.SortText != want {
+						t.Errorf("SortText of %s = %q, want %q", item.Label, item.SortText, want)
+					}
+				}
+				if strings.Join(got, ",") != strings.Join(test.want, ",") {
+					t.Errorf("sorted reversed %v: %v, want %v", reversed, got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSortTextKeepsOrderPastNineItems(t *testing.T) {
+	var items []CompletionItem
+	for _, label := range []string{"k", "j", "i", "h", "g", "f", "e", "d", "c", "b", "a"} {
+		items = append(items, CompletionItem{CompletionItem: protocol.CompletionItem{Label: label}})
+	}
+	sortCompletionItems(items, nil, nil)
+	for i := 1; i < len(items); i++ {
+		if items[i-1].SortText >= items[i].SortText {
+			t.Errorf("SortText %q of %s doesn't sort before %q of %s", items[i-1].SortText, items[i-1].Label, items[i].SortText, items[i].Label)
+		}
+	}
+}
+
+// completionCapabilities are the capabilities of a client with the completion item support item
+func completionCapabilities(item protocol.CompletionTextDocumentClientCapabilitiesItem) protocol.ClientCapabilities {
+	return protocol.ClientCapabilities{TextDocument: &protocol.TextDocumentClientCapabilities{
+		Completion: &protocol.CompletionTextDocumentClientCapabilities{CompletionItem: &item},
+	}}
+}
+
+func TestCompletionItemDefaults(t *testing.T) {
+	tests := []struct {
+		name          string
+		insertReplace bool
+		editRange     bool
+		character     uint32
+		want          interface{}
+	}{
+		{
+			name: "insert and replace differ mid-identifier", insertReplace: true, character: 40,
+			want: &InsertReplaceRange{
+				Insert:  protocol.Range{Start: protocol.Position{Character: 36}, End: protocol.Position{Character: 40}},
+				Replace: protocol.Range{Start: protocol.Position{Character: 36}, End: protocol.Position{Character: 44}},
+			},
+		},
+		{
+			name: "insert and replace agree at the end", insertReplace: true, character: 44,
+			want: &InsertReplaceRange{
+				Insert:  protocol.Range{Start: protocol.Position{Character: 36}, End: protocol.Position{Character: 44}},
+				Replace: protocol.Range{Start: protocol.Position{Character: 36}, End: protocol.Position{Character: 44}},
+			},
+		},
+		{
+			name: "a plain range up to the caret", editRange: true, character: 40,
+			want: &protocol.Range{Start: protocol.Position{Character: 36}, End: protocol.Position{Character: 40}},
+		},
+		{name: "no default range", character: 40},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, _ := newTestServer(t, nil)
+			s.capabilities = completionCapabilities(protocol.CompletionTextDocumentClientCapabilitiesItem{InsertReplaceSupport: test.insertReplace})
+			s.completionListDefaults.EditRange = test.editRange
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, "class Player { void M() { transform.position } }")
+
+			defaults := s.completionItemDefaults(&protocol.CompletionParams{
+				TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+					TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+					Position:     protocol.Position{Character: test.character},
+				},
+			})
+			var got interface{}
+			if defaults != nil {
+				got = defaults.EditRange
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("editRange = %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPreselectRecommendedItem(t *testing.T) {
+	recommended := func(items []AutoCompleteResponse, indexes ...int) []AutoCompleteResponse {
+		for _, i := range indexes {
+			items[i].Preselect = true
+		}
+		return items
+	}
+	tests := []struct {
+		name  string
+		items []AutoCompleteResponse
+		want  string
+	}{
+		{"none recommended", autoCompleteItems("Rigidbody", "Collider", "Transform"), ""},
+		{"one recommended", recommended(autoCompleteItems("Rigidbody", "Collider", "Transform"), 2), "Transform"},
+		{"several recommended", recommended(autoCompleteItems("Rigidbody", "Collider", "Transform"), 1, 2), "Collider"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": test.items})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, "class Player { void Start() { var body = new  } }\n")
+
+			list := completeAt(t, s, uri, protocol.Position{Character: 45}, protocol.CompletionTriggerKindInvoked)
+			var preselected []string
+			for _, item := range list.Items {
+				if item.Preselect {
+					preselected = append(preselected, item.Label)
+				}
+			}
+			if test.want == "" {
+				if len(preselected) != 0 {
+					t.Errorf("preselected %v, want none", preselected)
+				}
+				return
+			}
+			if len(preselected) != 1 || preselected[0] != test.want {
+				t.Fatalf("preselected %v, want only %s", preselected, test.want)
+			}
+			if first := list.Items[0]; first.Label != test.want {
+				t.Errorf("%s sorts first, want %s", first.Label, test.want)
+			}
+			for _, item := range list.Items[1:] {
+				if item.SortText <= list.Items[0].SortText {
+					t.Errorf("%s sort text %q isn't after %q", item.Label, item.SortText, list.Items[0].SortText)
+				}
+			}
+		})
+	}
+}
+
+func TestSignatureHelpOnAccept(t *testing.T) {
+	tests := []struct {
+		name    string
+		item    AutoCompleteResponse
+		enabled bool
+		want    bool
+	}{
+		{"method with parameters", AutoCompleteResponse{Kind: "Method", MethodHeader: "Translate(Vector3 translation)"}, true, true},
+		{"parameterless method", AutoCompleteResponse{Kind: "Method", MethodHeader: "Jump()"}, true, false},
+		{"method without a header", AutoCompleteResponse{Kind: "Method"}, true, false},
+		{"constructor with parameters", AutoCompleteResponse{Kind: "Constructor", MethodHeader: "Vector3(float x, float y)"}, true, true},
+		{"property", AutoCompleteResponse{Kind: "Property"}, true, false},
+		{"disabled", AutoCompleteResponse{Kind: "Method", MethodHeader: "Translate(Vector3 translation)"}, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.item.CompletionText, test.item.DisplayText = "Translate", "Translate"
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": []AutoCompleteResponse{test.item}})
+			s, _ := newTestServer(t, fake)
+			configure(s, func(config *Config) { config.Completion.SignatureHelpOnAccept = test.enabled })
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, "class Player { void Update() { transform. } }\n")
+
+			list := completeAt(t, s, uri, protocol.Position{Character: 41}, protocol.CompletionTriggerKindInvoked)
+			if len(list.Items) != 1 {
+				t.Fatalf("completed %v", labels(list.Items))
+			}
+			command := list.Items[0].Command
+			if got := command != nil && command.Command == "editor.action.triggerParameterHints"; got != test.want {
+				t.Errorf("command = %+v, want one triggering parameter hints: %v", command, test.want)
+			}
+		})
+	}
+}
+
+func TestCompletionDetail(t *testing.T) {
+	tests := []struct {
+		name string
+		item AutoCompleteResponse
+		want string
+	}{
+		{
+			name: "static field",
+			item: AutoCompleteResponse{DisplayText: "zero", Kind: "Field", ReturnType: "Vector3", Description: "public static readonly Vector3 Vector3.zero"},
+			want: "public static readonly Vector3 zero",
+		},
+		{
+			name: "instance method",
+			item: AutoCompleteResponse{DisplayText: "Translate", Kind: "Method", ReturnType: "void", MethodHeader: "Translate(Vector3 translation)", Description: "public void Transform.Translate(Vector3 translation)"},
+			want: "public void Translate(Vector3 translation)",
+		},
+		{
+			name: "override",
+			item: AutoCompleteResponse{DisplayText: "ToString", Kind: "Method", ReturnType: "string", MethodHeader: "ToString()", Description: "public override string Object.ToString()"},
+			want: "public override string ToString()",
+		},
+		{
+			name: "extension method",
+			item: AutoCompleteResponse{DisplayText: "Select", Kind: "Method", ReturnType: "IEnumerable<T>", MethodHeader: "Select(Func<T> selector)", Description: "(extension) IEnumerable<T> IEnumerable.Select(Func<T> selector)"},
+			want: "(extension) IEnumerable<T> Select(Func<T> selector)",
+		},
+		{
+			name: "without a description",
+			item: AutoCompleteResponse{DisplayText: "speed", Kind: "Field", ReturnType: "float"},
+			want: "float speed",
+		},
+		{
+			name: "keyword",
+			item: AutoCompleteResponse{DisplayText: "while", Kind: "Keyword", Documentation: "while keyword"},
+			want: "while keyword",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := completionDetail(test.item); got != test.want {
+				t.Errorf("detail = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFilterCompletionItems(t *testing.T) {
+	item := func(label, filterText string) CompletionItem {
+		return CompletionItem{CompletionItem: protocol.CompletionItem{Label: label, FilterText: filterText}}
+	}
+	items := []CompletionItem{
+		item("GetComponent", ""), item("GetComponents", ""), item("gameObject", ""), item("Translate", ""),
+		item("OnGUI()", "OnGUI"),
+	}
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{"GetComponent", "GetComponents", "gameObject", "Translate", "OnGUI()"}},
+		{"GetC", []string{"GetComponent", "GetComponents"}},
+		{"getc", []string{"GetComponent", "GetComponents"}},
+		{"gcs", []string{"GetComponents"}},
+		{"go", []string{"GetComponent", "GetComponents", "gameObject"}},
+		{"gui", []string{"OnGUI()"}},
+		{"()", nil},
+		{"xyz", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.prefix, func(t *testing.T) {
+			got := labels(filterCompletionItems(append([]CompletionItem{}, items...), test.prefix))
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("filtered to %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestServerFiltersCompletions compares the items completed with and without a prefix typed
+func TestServerFiltersCompletions(t *testing.T) {
+	names := []string{"GetComponent", "GetComponentInChildren", "gameObject", "transform", "Translate", "enabled"}
+	tests := []struct {
+		name           string
+		typed          string
+		maxItems       int
+		want           int
+		wantIncomplete bool
+	}{
+		{"nothing typed", "", 0, 6, false},
+		{"prefix typed", "GetC", 0, 2, false},
+		{"nothing typed, capped", "", 3, 3, true},
+		// The cap counts matching items only
+		{"prefix typed, capped", "GetC", 3, 2, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": autoCompleteItems(names...)})
+			s, _ := newTestServer(t, fake)
+			configure(s, func(config *Config) { config.Completion.MaxItems = test.maxItems })
+			uri := testURI(s, "Player.cs")
+			text := "class Player { void Start() { " + test.typed
+			openTestDocument(s, uri, text+" } }\n")
+
+			list := completeAt(t, s, uri, protocol.Position{Character: uint32(len(text))}, protocol.CompletionTriggerKindInvoked)
+			if len(list.Items) != test.want || list.IsIncomplete != test.wantIncomplete {
+				t.Errorf("completed %v, incomplete %v; want %d items, incomplete %v", labels(list.Items), list.IsIncomplete, test.want, test.wantIncomplete)
+			}
+		})
+	}
+}
+
+// TestLargeCompletionCapped completes a namespace of 10k members, checking the list stops at
+// the cap and decoding it allocates a fraction of what decoding it all does
+func TestLargeCompletionCapped(t *testing.T) {
+	names := make([]string, 10000)
+	for i := range names {
+		names[i] = fmt.Sprintf("Member%05d", i)
+	}
+	// Encoded once, so the fake doesn't add to what is measured
+	encoded, err := json.Marshal(autoCompleteItems(names...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name           string
+		maxItems       int
+		want           int
+		wantIncomplete bool
+	}{
+		{"uncapped", 0, len(names), false},
+		{"capped", 100, 100, true},
+	}
+	allocs := make(map[string]float64)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": json.RawMessage(encoded)})
+			s, _ := newTestServer(t, fake)
+			configure(s, func(config *Config) { config.Completion.MaxItems = test.maxItems })
+			uri := testURI(s, "Player.cs")
+			text := "class Player { void Start() { UnityEngine."
+			openTestDocument(s, uri, text+" } }\n")
+			at := protocol.Position{Character: uint32(len(text))}
+
+			list := completeAt(t, s, uri, at, protocol.CompletionTriggerKindInvoked)
+			if len(list.Items) != test.want || list.IsIncomplete != test.wantIncomplete {
+				t.Errorf("completed %d items, incomplete %v; want %d, incomplete %v", len(list.Items), list.IsIncomplete, test.want, test.wantIncomplete)
+			}
+			allocs[test.name] = testing.AllocsPerRun(3, func() {
+				// Each run asks OmniSharp again rather than reusing the last list
+				s.cache.forget(uri)
+				completeAt(t, s, uri, at, protocol.CompletionTriggerKindInvoked)
+			})
+			if calls := fake.callCount("/autocomplete"); calls < 4 {
+				t.Fatalf("asked OmniSharp %d times, completions were reused", calls)
+			}
+		})
+	}
+	if allocs["capped"] > allocs["uncapped"]/10 {
+		t.Errorf("a capped completion allocates %.0f times, uncapped %.0f", allocs["capped"], allocs["uncapped"])
+	}
+}
+
+func TestConvertKind(t *testing.T) {
+	tests := []struct {
+		omnisharpKind string
+		want          protocol.CompletionItemKind
+	}{
+		{"Constructor", protocol.CompletionItemKindConstructor},
+		{"Destructor", protocol.CompletionItemKindMethod},
+		{"ExtensionMethod", protocol.CompletionItemKindMethod},
+		{"LocalFunction", protocol.CompletionItemKindMethod},
+		{"UserDefinedOperator", protocol.CompletionItemKindOperator},
+		{"Conversion", protocol.CompletionItemKindOperator},
+		{"Indexer", protocol.CompletionItemKindProperty},
+		{"Event", protocol.CompletionItemKindEvent},
+		{"Delegate", protocol.CompletionItemKindClass},
+		{"RecordStruct", protocol.CompletionItemKindStruct},
+		{"TypeParameter", protocol.CompletionItemKindTypeParameter},
+		{"RangeVariable", protocol.CompletionItemKindVariable},
+		{"Label", protocol.CompletionItemKindReference},
+		{"Namespace", protocol.CompletionItemKindModule},
+		{"Unknown", protocol.CompletionItemKindText},
+	}
+	for _, test := range tests {
+		t.Run(test.omnisharpKind, func(t *testing.T) {
+			if got := convertKind(test.omnisharpKind); got != test.want {
+				t.Errorf("convertKind = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestCompletionKinds completes a constructor, an event, an indexer and an extension method
+func TestCompletionKinds(t *testing.T) {
+	fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": []AutoCompleteResponse{
+		{CompletionText: "Player", DisplayText: "Player", Kind: "Constructor", MethodHeader: "Player(int health)"},
+		{CompletionText: "OnDeath", DisplayText: "OnDeath", Kind: "Event", ReturnType: "Action", Description: "public event Action Player.OnDeath"},
+		{CompletionText: "this", DisplayText: "this[]", Kind: "Indexer", ReturnType: "int"},
+		{CompletionText: "Shuffle", DisplayText: "Shuffle", Kind: "ExtensionMethod", ReturnType: "void", MethodHeader: "Shuffle()"},
+		{CompletionText: "Log", DisplayText: "Log", Kind: "Method", ReturnType: "void", MethodHeader: "Log()", Description: "(extension) void Player.Log()"},
+	}})
+	s, _ := newTestServer(t, fake)
+	uri := testURI(s, "Player.cs")
+	openTestDocument(s, uri, "class Player { void Start() { } }\n")
+
+	want := map[string]struct {
+		kind      protocol.CompletionItemKind
+		extension bool
+	}{
+		"Player":  {protocol.CompletionItemKindConstructor, false},
+		"OnDeath": {protocol.CompletionItemKindEvent, false},
+		"this[]":  {protocol.CompletionItemKindProperty, false},
+		"Shuffle": {protocol.CompletionItemKindMethod, true},
+		"Log":     {protocol.CompletionItemKindMethod, true},
+	}
+	list := completeAt(t, s, uri, protocol.Position{Character: 30}, protocol.CompletionTriggerKindInvoked)
+	for _, item := range list.Items {
+		expected, ok := want[item.Label]
+		if !ok {
+			continue
+		}
+		delete(want, item.Label)
+		if item.Kind != expected.kind || strings.HasPrefix(item.Detail, "(extension)") != expected.extension {
+			t.Errorf("%s is a %v detailed %q, want a %v, marked extension: %v", item.Label, item.Kind, item.Detail, expected.kind, expected.extension)
+		}
+	}
+	for label := range want {
+		t.Errorf("%s wasn't completed", label)
+	}
+}
+
+func TestIndentMultilineItems(t *testing.T) {
+	const stub = "public override void Awake()\n{\n    base.Awake();\n}"
+	tests := []struct {
+		name     string
+		modes    []protocol.InsertTextMode
+		line     string
+		text     string
+		wantText string
+		wantMode protocol.InsertTextMode
+	}{
+		{"client adjusts", []protocol.InsertTextMode{protocol.InsertTextModeAsIs, protocol.InsertTextModeAdjustIndentation}, "    over", stub, stub, protocol.InsertTextModeAdjustIndentation},
+		{"client inserts as is", []protocol.InsertTextMode{protocol.InsertTextModeAsIs}, "    over", stub, "public override void Awake()\n    {\n        base.Awake();\n    }", 0},
+		{"no support", nil, "\tover", stub, "public override void Awake()\n\t{\n\t    base.Awake();\n\t}", 0},
+		{"unindented line", nil, "over", stub, stub, 0},
+		{"single line", []protocol.InsertTextMode{protocol.InsertTextModeAdjustIndentation}, "    tra", "transform", "transform", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newServer(DefaultConfig())
+			if test.modes != nil {
+				s.capabilities.TextDocument = &protocol.TextDocumentClientCapabilities{Completion: &protocol.CompletionTextDocumentClientCapabilities{
+					CompletionItem: &protocol.CompletionTextDocumentClientCapabilitiesItem{
+						InsertTextModeSupport: &protocol.CompletionTextDocumentClientCapabilitiesItemInsertTextModeSupport{ValueSet: test.modes},
+					},
+				}}
+			}
+			edit := &protocol.TextEdit{NewText: test.text}
+			items := []CompletionItem{{
+				CompletionItem: protocol.CompletionItem{Label: "item", InsertText: test.text},
+				TextEdit:       edit,
+				TextEditText:   test.text,
+			}}
+
+			s.indentMultilineItems(items, test.line)
+			item := items[0]
+			if item.InsertText != test.wantText || item.TextEditText != test.wantText || edit.NewText != test.wantText {
+				t.Errorf("inserts %q, %q and %q, want %q", item.InsertText, item.TextEditText, edit.NewText, test.wantText)
+			}
+			if item.InsertTextMode != test.wantMode {
+				t.Errorf("insert text mode = %v, want %v", item.InsertTextMode, test.wantMode)
+			}
+		})
+	}
+}
+
+// TestEmptyMemberCompletionRetries has OmniSharp complete nothing until it gets the buffer
+// again, as right after opening a file
+func TestEmptyMemberCompletionRetries(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		// synced has OmniSharp complete once the buffer is pushed again; else never
+		synced    bool
+		wantCalls int
+		want      int
+		// stale has OmniSharp complete members before the buffer is pushed again too
+		stale bool
+	}{
+		{"member access", "transform.", true, 2, 1, false},
+		{"member partly typed", "transform.pos", true, 2, 1, false},
+		{"member access on a call", "GetComponent<Rigidbody>().", true, 2, 1, false},
+		{"still nothing", "transform.", false, 2, 0, false},
+		{"number", "var x = 1.", true, 1, 0, false},
+		{"not member access", "var x = ", true, 1, 0, false},
+		{"no member matching", "transform.qqq", true, 1, 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			text := "class Player { void Start() { " + test.text
+			openTestDocument(s, uri, text+" } }\n")
+			opened := fake.callCount("/updatebuffer")
+			fake.setHandler("/autocomplete", func() interface{} {
+				if test.stale || test.synced && fake.callCount("/updatebuffer") > opened {
+					return autoCompleteItems("position")
+				}
+				return []AutoCompleteResponse{}
+			})
+
+			list := completeAt(t, s, uri, protocol.Position{Character: uint32(len(text))}, protocol.CompletionTriggerKindInvoked)
+			if calls := fake.callCount("/autocomplete"); calls != test.wantCalls {
+				t.Errorf("asked OmniSharp %d times, want %d", calls, test.wantCalls)
+			}
+			if got := len(list.Items); got != test.want {
+				t.Errorf("completed %v, want %d items", labels(list.Items), test.want)
+			}
+		})
+	}
+}
+
+// TestTriggeredCompletionDebounced checks a completion triggered by a character waits for the
+// caret to settle, so of two quick triggers only the second reaches OmniSharp
+func TestTriggeredCompletionDebounced(t *testing.T) {
+	const text = "class Player { void Update() { a.b. } }"
+	first, second := protocol.Position{Character: uint32(strings.Index(text, "a.") + 2)}, protocol.Position{Character: uint32(strings.Index(text, "b.") + 2)}
+	tests := []struct {
+		name     string
+		debounce time.Duration
+		// pause, if set, separates the first answer from the second trigger
+		pause         time.Duration
+		wantCancelled bool
+		wantCalls     int
+	}{
+		{"quick triggers", 200 * time.Millisecond, 0, true, 1},
+		{"settled between triggers", 50 * time.Millisecond, 300 * time.Millisecond, false, 2},
+		{"no debounce", 0, 300 * time.Millisecond, false, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": autoCompleteItems("position")})
+			s, _ := newTestServer(t, fake)
+			configure(s, func(config *Config) { config.Completion.Debounce = Duration(test.debounce) })
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, text)
+
+			replies := make([]chan error, 2)
+			trigger := func(id int32, pos protocol.Position) {
+				t.Helper()
+				replies[id] = make(chan error, 1)
+				request, err := jsonrpc2.NewCall(jsonrpc2.NewNumberID(id), protocol.MethodTextDocumentCompletion, protocol.CompletionParams{
+					TextDocumentPositionParams: protocol.TextDocumentPositionParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}, Position: pos},
+					Context:                    &protocol.CompletionContext{TriggerKind: protocol.CompletionTriggerKindTriggerCharacter, TriggerCharacter: "."},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				s.handle(context.Background(), func(ctx context.Context, result interface{}, err error) error {
+					replies[id] <- err
+					return nil
+				}, request)
+			}
+
+			trigger(0, first)
+			var err error
+			if test.pause > 0 {
+				err = <-replies[0]
+				time.Sleep(test.pause)
+			}
+			trigger(1, second)
+			if test.pause == 0 {
+				err = <-replies[0]
+			}
+			var wireErr *jsonrpc2.Error
+			if cancelled := errors.As(err, &wireErr) && wireErr.Code == protocol.CodeRequestCancelled; cancelled != test.wantCancelled || !cancelled && err != nil {
+				t.Errorf("first completion answered %v, want cancelled %v", err, test.wantCancelled)
+			}
+			if err := <-replies[1]; err != nil {
+				t.Fatalf("second completion answered %v", err)
+			}
+
+			if calls := fake.callCount("/autocomplete"); calls != test.wantCalls {
+				t.Errorf("asked OmniSharp %d times, want %d", calls, test.wantCalls)
+			}
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			var last struct{ Column uint32 }
+			bodies := fake.bodies["/autocomplete"]
+			if err := json.Unmarshal(bodies[len(bodies)-1], &last); err != nil || last.Column != second.Character {
+				t.Errorf("last asked at column %d, want %d", last.Column, second.Character)
+			}
+		})
+	}
+}
+
+// TestKindPriorityReordersCompletions checks items of equal relevance follow the configured
+// ranks of their kinds
+func TestKindPriorityReordersCompletions(t *testing.T) {
+	items := []AutoCompleteResponse{
+		{CompletionText: "Move", DisplayText: "Move", Kind: "Method"},
+		{CompletionText: "speed", DisplayText: "speed", Kind: "Property"},
+		{CompletionText: "Player", DisplayText: "Player", Kind: "Class"},
+		{CompletionText: "health", DisplayText: "health", Kind: "Field"},
+	}
+	tests := []struct {
+		name     string
+		priority map[string]int
+		want     []string
+	}{
+		{"defaults", nil, []string{"health", "speed", "Move", "Player"}},
+		{"methods first", map[string]int{"method": 0}, []string{"Move", "health", "speed", "Player"}},
+		{"properties before fields", map[string]int{"property": 0, "field": 2}, []string{"speed", "health", "Move", "Player"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": items})
+			s, _ := newTestServer(t, fake)
+			configure(s, func(config *Config) {
+				for kind, rank := range test.priority {
+					config.Completion.KindPriority[kind] = rank
+				}
+			})
+			uri := testURI(s, "Player.cs")
+			const text = "class Player { void Update() { x } }"
+			openTestDocument(s, uri, text)
+
+			list := completeAt(t, s, uri, protocol.Position{Character: uint32(strings.Index(text, "x"))}, protocol.CompletionTriggerKindInvoked)
+			if got := labels(list.Items); strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("completions = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestCompletionDeadline checks completion past completion.timeout answers at once with the
+// items OmniSharp streamed so far, marked incomplete and asked for again the next time
+func TestCompletionDeadline(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		// streamed are the items sent before the response stalls, for stall, or ends
+		streamed []string
+		stall    time.Duration
+		want     []string
+		// wantIncomplete is the list marked incomplete, and asked for again
+		wantIncomplete bool
+	}{
+		{"partial", 50 * time.Millisecond, []string{"health", "speed"}, time.Minute, []string{"health", "speed"}, true},
+		{"nothing yet", 50 * time.Millisecond, nil, time.Minute, nil, true},
+		{"in time", time.Second, []string{"health", "speed"}, 0, []string{"health", "speed"}, false},
+		{"no deadline", 0, []string{"health", "speed"}, 100 * time.Millisecond, []string{"health", "speed"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			calls := 0
+			omnisharp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/autocomplete" {
+					io.WriteString(w, "{}")
+					return
+				}
+				mu.Lock()
+				calls++
+				mu.Unlock()
+				io.WriteString(w, "[")
+				for i, item := range autoCompleteItems(test.streamed...) {
+					if i > 0 {
+						io.WriteString(w, ",")
+					}
+					json.NewEncoder(w).Encode(item)
+				}
+				w.(http.Flusher).Flush()
+				select {
+				case <-time.After(test.stall):
+				case <-r.Context().Done():
+					return
+				}
+				io.WriteString(w, "]")
+			}))
+			defer omnisharp.Close()
+			s, _ := newTestServer(t, newFakeOmniSharp(t, nil))
+			s.omnisharp = NewOmniSharpClient(omnisharp.URL, 5*time.Second, false)
+			configure(s, func(config *Config) { config.Completion.Timeout = Duration(test.timeout) })
+			uri := testURI(s, "Player.cs")
+			const line = "class Player { void Update() { "
+			openTestDocument(s, uri, line+" } }\n")
+
+			for query := 1; query <= 2; query++ {
+				started := time.Now()
+				list := completeAt(t, s, uri, protocol.Position{Character: uint32(len(line))}, protocol.CompletionTriggerKindInvoked)
+				if elapsed := time.Since(started); test.stall == time.Minute && elapsed > time.Second {
+					t.Errorf("answered after %v, want within the deadline", elapsed)
+				}
+				if got := labels(list.Items); !reflect.DeepEqual(got, test.want) {
+					t.Errorf("completions = %q, want %q", got, test.want)
+				}
+				if list.IsIncomplete != test.wantIncomplete {
+					t.Errorf("incomplete = %v, want %v", list.IsIncomplete, test.wantIncomplete)
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			wantCalls := 1
+			if test.wantIncomplete {
+				wantCalls = 2
+			}
+			if calls != wantCalls {
+				t.Errorf("asked OmniSharp %d times, want %d", calls, wantCalls)
+			}
+		})
+	}
+}
+
+// accept applies item to text as a client would, with the list's default edit range for an
+// item without an edit of its own
+func accept(t *testing.T, text string, list *CompletionList, item CompletionItem) string {
+	t.Helper()
+	var edit protocol.TextEdit
+	switch e := item.TextEdit.(type) {
+	case *protocol.TextEdit:
+		edit = *e
+	case *protocol.InsertReplaceEdit:
+		edit = protocol.TextEdit{Range: e.Insert, NewText: e.NewText}
+	case nil:
+		if list.ItemDefaults == nil {
+			t.Fatalf("%s has no edit and the list no default range", item.Label)
+		}
+		edit.NewText = item.TextEditText
+		if edit.NewText == "" {
+			edit.NewText = item.Label
+		}
+		switch r := list.ItemDefaults.EditRange.(type) {
+		case *protocol.Range:
+			edit.Range = *r
+		case *InsertReplaceRange:
+			edit.Range = r.Insert
+		default:
+			t.Fatalf("default edit range %#v", r)
+		}
+	default:
+		t.Fatalf("edit %#v", e)
+	}
+	return applyTextEdits(text, []protocol.TextEdit{edit})
+}
+
+// TestAcceptingMemberKeepsOneDot completes transform. and accepts position, which must leave
+// the dot alone whatever edit ranges the client supports
+func TestAcceptingMemberKeepsOneDot(t *testing.T) {
+	tests := []struct {
+		name          string
+		typed         string
+		insertReplace bool
+		editRange     bool
+	}{
+		{name: "no default range", typed: "transform."},
+		{name: "no default range, member partly typed", typed: "transform.pos"},
+		{name: "default range", typed: "transform.", editRange: true},
+		{name: "insert and replace", typed: "transform.pos", insertReplace: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": autoCompleteItems("position", "rotation")})
+			s, _ := newTestServer(t, fake)
+			s.capabilities = completionCapabilities(protocol.CompletionTextDocumentClientCapabilitiesItem{InsertReplaceSupport: test.insertReplace})
+			s.completionListDefaults.EditRange = test.editRange
+			uri := testURI(s, "Player.cs")
+			before := "class Player { void Start() { " + test.typed
+			text := before + "; } }\n"
+			openTestDocument(s, uri, text)
+
+			list := completeAt(t, s, uri, protocol.Position{Character: uint32(len(before))}, protocol.CompletionTriggerKindInvoked)
+			for _, item := range list.Items {
+				if item.Label != "position" {
+					continue
+				}
+				if got, want := accept(t, text, list, item), "class Player { void Start() { transform.position; } }\n"; got != want {
+					t.Errorf("accepting position gave %q, want %q", got, want)
+				}
+				return
+			}
+			t.Fatalf("completed %v, want position", labels(list.Items))
+		})
+	}
+}
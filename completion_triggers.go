@@ -0,0 +1,164 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go.lsp.dev/protocol"
+)
+
+// contextTriggerChecks decide whether a contextual trigger character was typed somewhere
+// completion is useful, keyed by the character. line is the text before the caret, ending
+// with the trigger character
+var contextTriggerChecks = map[string]func(line string) bool{
+	"<": isAngleBracketTrigger,
+	"[": isAttributeOrIndexerTrigger,
+	"@": isVerbatimIdentifierTrigger,
+	`"`: isUnityNameTrigger,
+}
+
+// advertisedTriggerCharacters combines the global and contextual trigger characters
+func (c CompletionConfig) advertisedTriggerCharacters() []string {
+	triggers := append([]string{}, c.TriggerCharacters...)
+	for _, char := range c.ContextTriggers {
+		if _, ok := contextTriggerChecks[char]; ok && !containsString(triggers, char) {
+			triggers = append(triggers, char)
+		}
+	}
+	return triggers
+}
+
+// shouldTriggerCompletion filters completions triggered by a contextual trigger character in
+// a context where it doesn't start anything completable, e.g. the < of a comparison. A space
+// only triggers after one of completion.spaceTriggerKeywords, not after return or =
+func (s *Server) shouldTriggerCompletion(params *protocol.CompletionParams) bool {
+	if params.Context == nil || params.Context.TriggerKind != protocol.CompletionTriggerKindTriggerCharacter {
+		return true
+	}
+
+	char := params.Context.TriggerCharacter
+	check, ok := contextTriggerChecks[char]
+	if char == " " {
+		check, ok = s.isSpaceTrigger, true
+	} else if !ok || containsString(s.currentConfig().Completion.TriggerCharacters, char) {
+		return true
+	}
+
+	doc, ok := s.documents.Get(params.TextDocument.URI)
+	if !ok {
+		return true
+	}
+	line := lineAt(doc.Text, params.Position.Line)
+	return check(line[:utf16ToByteOffset(line, params.Position.Character)])
+}
+
+// isSpaceTrigger accepts a space following a keyword after which completion is useful, such
+// as new or override, or the += subscribing to an event. Events are capitalized in C#, which
+// leaves out count += and the like
+func (s *Server) isSpaceTrigger(line string) bool {
+	trimmed := strings.TrimRight(line, " \t")
+	if operand, ok := strings.CutSuffix(trimmed, "+="); ok {
+		r, _ := utf8.DecodeRuneInString(identifierBefore(strings.TrimRight(operand, " \t")))
+		return unicode.IsUpper(r)
+	}
+	word := identifierBefore(trimmed)
+	return word != "" && containsString(s.currentConfig().Completion.SpaceTriggerKeywords, word)
+}
+
+// narrowTriggeredCompletions drops keywords and snippets from completions triggered by typing
+// a trigger character, which starts a member, type or argument where they are noise. Type
+// keywords such as int are kept. Completion invoked explicitly offers everything
+func narrowTriggeredCompletions(params *protocol.CompletionParams, items []CompletionItem) []CompletionItem {
+	if params.Context == nil || params.Context.TriggerKind != protocol.CompletionTriggerKindTriggerCharacter {
+		return items
+	}
+
+	narrowed := items[:0]
+	for _, item := range items {
+		switch {
+		case item.Kind == protocol.CompletionItemKindSnippet:
+		case item.Kind == protocol.CompletionItemKindKeyword && !csharpBuiltinTypes[item.Label]:
+		default:
+			narrowed = append(narrowed, item)
+		}
+	}
+	return narrowed
+}
+
+// isUnityNameTrigger accepts the quote opening a string that names a tag, layer, input, scene
+// or resource
+func isUnityNameTrigger(line string) bool {
+	for _, argument := range []*regexp.Regexp{tagArgument, layerArgument, inputAxisArgument, inputActionArgument, inputActionMapArgument, sceneArgument, resourceArgument} {
+		if argument.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAngleBracketTrigger accepts the < starting a tag in a documentation comment, or generic
+// arguments
+func isAngleBracketTrigger(line string) bool {
+	return inDocComment(line) || isGenericArgumentTrigger(line)
+}
+
+// isGenericArgumentTrigger accepts List< or GetComponent< but not a < b or count<5: the <
+// must directly follow an identifier, and type and method names are capitalized in C#
+func isGenericArgumentTrigger(line string) bool {
+	word := identifierBefore(strings.TrimSuffix(line, "<"))
+	if word == "" {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(word)
+	return unicode.IsUpper(r)
+}
+
+// isAttributeOrIndexerTrigger accepts the [ opening an attribute list or indexing an
+// expression, but not array types such as int[
+func isAttributeOrIndexerTrigger(line string) bool {
+	before := strings.TrimSuffix(line, "[")
+	// An attribute list at the start of a line, possibly following another one, or an
+	// indexer on a call or element
+	if trimmed := strings.TrimSpace(before); trimmed == "" || strings.HasSuffix(trimmed, "]") || strings.HasSuffix(before, ")") {
+		return true
+	}
+	word := identifierBefore(before)
+	return word != "" && !csharpBuiltinTypes[word]
+}
+
+// isVerbatimIdentifierTrigger accepts @ starting an identifier such as @class, but not the
+// $@ of an interpolated verbatim string
+func isVerbatimIdentifierTrigger(line string) bool {
+	before := strings.TrimSuffix(line, "@")
+	return !strings.HasSuffix(before, "$") && identifierBefore(before) == ""
+}
+
+// identifierBefore returns the identifier ending exactly at the end of text
+func identifierBefore(text string) string {
+	start := len(text)
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(text[:start])
+		if !isIdentifierRune(r) {
+			break
+		}
+		start -= size
+	}
+	return text[start:]
+}
+
+var csharpBuiltinTypes = map[string]bool{
+	"bool": true, "byte": true, "sbyte": true, "char": true, "decimal": true, "double": true,
+	"float": true, "int": true, "uint": true, "long": true, "ulong": true, "short": true,
+	"ushort": true, "object": true, "string": true, "nint": true, "nuint": true, "dynamic": true,
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
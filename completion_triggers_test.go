@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestAdvertisedTriggerCharacters(t *testing.T) {
+	tests := []struct {
+		name            string
+		global, context []string
+		want            []string
+	}{
+		{"defaults", []string{".", " "}, []string{"<", "[", `"`}, []string{".", " ", "<", "[", `"`}},
+		{"no contextual triggers", []string{"."}, nil, []string{"."}},
+		{"verbatim identifiers", []string{"."}, []string{"@"}, []string{".", "@"}},
+		{"global and contextual", []string{".", "<"}, []string{"<"}, []string{".", "<"}},
+		{"unknown contextual trigger", []string{"."}, []string{"#"}, []string{"."}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := CompletionConfig{TriggerCharacters: test.global, ContextTriggers: test.context}
+			if got := config.advertisedTriggerCharacters(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("advertised %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestContextualTriggers(t *testing.T) {
+	tests := []struct {
+		name string
+		// line is the text of the method body, ending with the trigger character typed
+		line string
+		want bool
+	}{
+		{"generic type", "var enemies = new List<", true},
+		{"generic method", "GetComponent<", true},
+		{"comparison", "if (a <", false},
+		{"comparison without spaces", "if (count<", false},
+		{"documentation tag", "/// <", true},
+		{"attribute", "[", true},
+		{"indexer", "var first = enemies[", true},
+		{"indexer on a call", "var first = Find()[", true},
+		{"array type", "int[", false},
+		{"verbatim identifier", "var x = @", true},
+		{"interpolated verbatim string", "var x = $@", false},
+		{"tag name", `if (other.CompareTag("`, true},
+		{"input axis", `var x = Input.GetAxis("`, true},
+		{"input action", `var fire = input.actions["`, true},
+		{"other string", `Debug.Log("`, false},
+		{"space after new", "var enemies = new ", true},
+		{"space after return", "return ", false},
+		{"space after an event's +=", "OnDeath += ", true},
+		{"space after a counter's +=", "count += ", false},
+		{"global trigger", "transform.", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": autoCompleteItems("Enemy")})
+			s, _ := newTestServer(t, fake)
+			configure(s, func(config *Config) {
+				config.Completion.Debounce = 0
+				config.Completion.ContextTriggers = append(config.Completion.ContextTriggers, "@")
+			})
+			uri := testURI(s, "Player.cs")
+			prefix := "class Player { void Update() {\n"
+			openTestDocument(s, uri, prefix+test.line+"\n} }\n")
+
+			params := &protocol.CompletionParams{
+				TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+					TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+					Position:     protocol.Position{Line: 1, Character: uint32(len(test.line))},
+				},
+				Context: &protocol.CompletionContext{
+					TriggerKind:      protocol.CompletionTriggerKindTriggerCharacter,
+					TriggerCharacter: test.line[len(test.line)-1:],
+				},
+			}
+			if got := s.shouldTriggerCompletion(params); got != test.want {
+				t.Errorf("%q triggers completion: %v, want %v", test.line, got, test.want)
+			}
+			if test.want {
+				return
+			}
+			list, err := s.handleCompletion(context.Background(), params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list.Items) != 0 || fake.callCount("/autocomplete") != 0 {
+				t.Errorf("%q completed %s", test.line, strings.Join(labels(list.Items), ", "))
+			}
+		})
+	}
+}
+
+// TestSpaceTriggerKeywords checks a typed space triggers completion only after the configured
+// keywords
+func TestSpaceTriggerKeywords(t *testing.T) {
+	tests := []struct {
+		name string
+		// line is the text of the method body, ending with the space typed
+		line     string
+		keywords []string
+		want     bool
+	}{
+		{"new", "var enemy = new ", nil, true},
+		{"case", "case ", nil, true},
+		{"override", "public override ", nil, true},
+		{"is", "if (other is ", nil, true},
+		{"as", "var enemy = other as ", nil, true},
+		{"using", "using ", nil, true},
+		{"return", "return ", nil, false},
+		{"assignment", "speed = ", nil, false},
+		{"identifier", "var speed ", nil, false},
+		{"identifier ending in a keyword", "var renew ", nil, false},
+		{"configured keyword", "return ", []string{"return"}, true},
+		{"keyword no longer configured", "var enemy = new ", []string{"return"}, false},
+		{"none configured", "var enemy = new ", []string{}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, _ := newTestServer(t, nil)
+			if test.keywords != nil {
+				configure(s, func(config *Config) { config.Completion.SpaceTriggerKeywords = test.keywords })
+			}
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, "class Player { void Update() {\n"+test.line+"\n} }\n")
+
+			params := &protocol.CompletionParams{
+				TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+					TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+					Position:     protocol.Position{Line: 1, Character: uint32(len(test.line))},
+				},
+				Context: &protocol.CompletionContext{TriggerKind: protocol.CompletionTriggerKindTriggerCharacter, TriggerCharacter: " "},
+			}
+			if got := s.shouldTriggerCompletion(params); got != test.want {
+				t.Errorf("%q triggers completion: %v, want %v", test.line, got, test.want)
+			}
+		})
+	}
+}
+
+// TestInvokedCompletionBroader checks completion invoked at a position offers the keywords and
+// snippets typing a trigger character there leaves out, type keywords aside
+func TestInvokedCompletionBroader(t *testing.T) {
+	items := []AutoCompleteResponse{
+		{CompletionText: "position", DisplayText: "position", Kind: "Property"},
+		{CompletionText: "Translate", DisplayText: "Translate", Kind: "Method"},
+		{CompletionText: "int", DisplayText: "int", Kind: "Keyword"},
+		{CompletionText: "return", DisplayText: "return", Kind: "Keyword"},
+		{CompletionText: "for", DisplayText: "for", Kind: "Snippet"},
+	}
+	tests := []struct {
+		name    string
+		context *protocol.CompletionContext
+		want    []string
+	}{
+		{"invoked", &protocol.CompletionContext{TriggerKind: protocol.CompletionTriggerKindInvoked}, []string{"Translate", "for", "int", "position", "return"}},
+		{"no context", nil, []string{"Translate", "for", "int", "position", "return"}},
+		{"incomplete requery", &protocol.CompletionContext{TriggerKind: protocol.CompletionTriggerKindTriggerForIncompleteCompletions}, []string{"Translate", "for", "int", "position", "return"}},
+		{"typed .", &protocol.CompletionContext{TriggerKind: protocol.CompletionTriggerKindTriggerCharacter, TriggerCharacter: "."}, []string{"Translate", "int", "position"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": items})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			line := "class Player { void Update() { transform."
+			openTestDocument(s, uri, line+" } }\n")
+
+			list, err := s.handleCompletion(context.Background(), &protocol.CompletionParams{
+				TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+					TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+					Position:     protocol.Position{Character: uint32(len(line))},
+				},
+				Context: test.context,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := labels(list.Items)
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("completions = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"go.lsp.dev/protocol"
+)
+
+// typeArgumentKinds are the completion kinds that can be a type argument besides the built-in
+// types. Items of unknown kind are kept
+var typeArgumentKinds = map[protocol.CompletionItemKind]bool{
+	protocol.CompletionItemKindClass:         true,
+	protocol.CompletionItemKindStruct:        true,
+	protocol.CompletionItemKindInterface:     true,
+	protocol.CompletionItemKindEnum:          true,
+	protocol.CompletionItemKindTypeParameter: true,
+	protocol.CompletionItemKindModule:        true,
+	protocol.CompletionItemKindText:          true,
+}
+
+// objectTypeArgumentMethods are the Unity methods whose type argument is a component or
+// another UnityEngine.Object, or for the GetComponent family an interface components implement,
+// so never a value type. Those set to true require a Component, which no interface is
+var objectTypeArgumentMethods = map[string]bool{
+	"GetComponent": false, "GetComponents": false, "TryGetComponent": false,
+	"GetComponentInChildren": false, "GetComponentsInChildren": false,
+	"GetComponentInParent": false, "GetComponentsInParent": false,
+	"FindObjectOfType": false, "FindObjectsOfType": false, "FindObjectsByType": false,
+	"FindFirstObjectByType": false, "FindAnyObjectByType": false,
+	"AddComponent": true,
+}
+
+// typeArgumentListAt finds the generic argument list the caret is in, ignoring the identifier
+// being typed, and returns the name of the method or type it belongs to. The < must directly
+// follow a capitalized name, as for the trigger, which tells GetComponent< from a < b
+func typeArgumentListAt(text string, offset int) (string, bool) {
+	before := text[:offset]
+	before = strings.TrimSuffix(before, identifierBefore(before))
+	depth := 0
+	for i := len(before) - 1; i >= 0; i-- {
+		switch c := before[i]; {
+		case c == '>':
+			depth++
+		case c == '<' && depth > 0:
+			depth--
+		case c == '<':
+			if !isGenericArgumentTrigger(before[:i+1]) {
+				return "", false
+			}
+			return identifierBefore(before[:i]), true
+		case strings.IndexByte(",.?[] \t\r\n", c) >= 0 || c >= utf8.RuneSelf || isIdentifierRune(rune(c)):
+			// Type names, possibly qualified, nullable or arrays, or part of a non-ASCII one
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// filterTypeArgumentCompletions drops the items that can't be a type argument of method, such
+// as members in scope, and for Unity's GetComponent and the like the value types, which are
+// never components
+func filterTypeArgumentCompletions(items []CompletionItem, method string) []CompletionItem {
+	componentOnly, objectOnly := objectTypeArgumentMethods[method]
+
+	filtered := items[:0]
+	for _, item := range items {
+		switch {
+		case item.Kind == protocol.CompletionItemKindKeyword:
+			if !csharpBuiltinTypes[item.Label] || (objectOnly && item.Label != "object" && item.Label != "dynamic") {
+				continue
+			}
+		case !typeArgumentKinds[item.Kind]:
+			continue
+		case objectOnly && (item.Kind == protocol.CompletionItemKindStruct || item.Kind == protocol.CompletionItemKindEnum):
+			continue
+		case componentOnly && item.Kind == protocol.CompletionItemKindInterface:
+			continue
+		}
+		filtered = append(filtered, item)
+	}
+	return filtered
+}
+
+// declaredVariable matches the declaration of a variable initialized by the expression at the
+// end of the text, capturing its type, as in Rigidbody body = gameObject.
+var declaredVariable = regexp.MustCompile(`(?:^|[\s;{(,])([A-Za-z_][\w.]*(?:<[^;=()]*>)?\??(?:\[\])?)\s+@?[A-Za-z_]\w*\s*=\s*(?:[\w.]*\.\s*)?$`)
+
+// typeArgumentSnippets has the generic methods of a list, as OmniSharp describes them in their
+// header, insert their type arguments as placeholders to fill in. A method returning its type
+// argument, such as GetComponent<T>, assigned to a declared variable gets the variable's type,
+// so Rigidbody body = GetComponent completes to GetComponent<Rigidbody>. Nothing changes where
+// type arguments follow already
+func typeArgumentSnippets(items []CompletionItem, text string, offset int) []CompletionItem {
+	after := text[offset:]
+	after = strings.TrimPrefix(after, identifierAfter(after))
+	if strings.HasPrefix(after, "<") {
+		return items
+	}
+	declared := expectedTypeAt(text, offset)
+
+	for i, item := range items {
+		parameters := methodTypeParameters(item)
+		if len(parameters) == 0 {
+			continue
+		}
+		placeholders := make([]string, len(parameters))
+		for j, parameter := range parameters {
+			value := parameter
+			switch {
+			case declared == "":
+			case item.returnType == parameter:
+				value = declared
+			case item.returnType == parameter+"[]" && strings.HasSuffix(declared, "[]"):
+				value = strings.TrimSuffix(declared, "[]")
+			}
+			placeholders[j] = fmt.Sprintf("${%d:%s}", j+1, snippetEscape(value))
+		}
+		snippet := item.TextEditText + "<" + strings.Join(placeholders, ", ") + ">"
+		items[i].InsertText, items[i].TextEditText = snippet, snippet
+		items[i].InsertTextFormat = protocol.InsertTextFormatSnippet
+	}
+	return items
+}
+
+// methodTypeParameters returns the type parameters of a generic method item from its header,
+// such as T for GetComponent<T>()
+func methodTypeParameters(item CompletionItem) []string {
+	if item.Kind != protocol.CompletionItemKindMethod || item.TextEditText == "" || item.InsertTextFormat == protocol.InsertTextFormatSnippet {
+		return nil
+	}
+	header, ok := strings.CutPrefix(item.methodHeader, item.TextEditText+"<")
+	if !ok {
+		return nil
+	}
+	end := strings.Index(header, ">(")
+	if end < 0 {
+		return nil
+	}
+	var parameters []string
+	for _, parameter := range strings.Split(header[:end], ",") {
+		parameters = append(parameters, strings.TrimSpace(parameter))
+	}
+	return parameters
+}
+
+// snippetEscape escapes the characters snippet syntax gives a meaning to
+func snippetEscape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "$", `\$`, "}", `\}`).Replace(text)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+// TestTypeArgumentCompletions checks GetComponent< and List< offer the types that can be their
+// argument, while a comparison such as a < b is neither triggered nor narrowed
+func TestTypeArgumentCompletions(t *testing.T) {
+	items := []AutoCompleteResponse{
+		{CompletionText: "Rigidbody", DisplayText: "Rigidbody", Kind: "Class"},
+		{CompletionText: "Vector3", DisplayText: "Vector3", Kind: "Struct"},
+		{CompletionText: "IDamageable", DisplayText: "IDamageable", Kind: "Interface"},
+		{CompletionText: "int", DisplayText: "int", Kind: "Keyword"},
+		{CompletionText: "health", DisplayText: "health", Kind: "Field"},
+		{CompletionText: "Jump", DisplayText: "Jump", Kind: "Method"},
+	}
+	tests := []struct {
+		name string
+		// code is the method body up to the caret
+		code    string
+		trigger bool
+		want    []string
+	}{
+		{"GetComponent<", "var body = GetComponent<", true, []string{"IDamageable", "Rigidbody"}},
+		{"typing in GetComponent<", "var body = GetComponent<Rig", false, []string{"Rigidbody"}},
+		{"List<", "var enemies = new List<", true, []string{"IDamageable", "int", "Rigidbody", "Vector3"}},
+		{"a < b triggered", "if (a <", true, nil},
+		{"a < b", "if (a < ", false, []string{"health", "IDamageable", "int", "Jump", "Rigidbody", "Vector3"}},
+		{"count<5", "if (count<", true, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": items})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			text := "class Player { void Start() { " + test.code
+			openTestDocument(s, uri, text+" } }\n")
+
+			kind := protocol.CompletionTriggerKindInvoked
+			var trigger string
+			if test.trigger {
+				kind, trigger = protocol.CompletionTriggerKindTriggerCharacter, "<"
+			}
+			list, err := s.handleCompletion(context.Background(), &protocol.CompletionParams{
+				TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+					TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+					Position:     protocol.Position{Character: uint32(len(text))},
+				},
+				Context: &protocol.CompletionContext{TriggerKind: kind, TriggerCharacter: trigger},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			if list != nil {
+				for _, label := range labels(list.Items) {
+					for _, item := range items {
+						if label == item.DisplayText {
+							got = append(got, label)
+						}
+					}
+				}
+			}
+			sort.Slice(got, func(i, j int) bool { return strings.ToLower(got[i]) < strings.ToLower(got[j]) })
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("completions %v, want %v", got, test.want)
+			}
+		})
+	}
+}
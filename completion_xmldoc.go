@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// docTag is an XML documentation tag offered in a /// comment
+type docTag struct {
+	label   string
+	snippet string
+	detail  string
+}
+
+// docTags are the tags offered anywhere in a documentation comment, the most common first.
+// param, typeparam and paramref are offered for the documented member's own parameters
+var docTags = []docTag{
+	{"summary", "<summary>$0</summary>", "Describes the member"},
+	{"returns", "<returns>$0</returns>", "Describes the return value"},
+	{"exception", `<exception cref="$1">$0</exception>`, "Describes an exception the member throws"},
+	{"see", `<see cref="$0"/>`, "Links to a symbol"},
+	{"seealso", `<seealso cref="$0"/>`, "Lists a symbol under See also"},
+	{"remarks", "<remarks>$0</remarks>", "Adds details to the summary"},
+	{"value", "<value>$0</value>", "Describes the value of a property"},
+	{"example", "<example>$0</example>", "Shows how to use the member"},
+	{"inheritdoc", "<inheritdoc/>", "Inherits the documentation of the base member"},
+	{"para", "<para>$0</para>", "Starts a paragraph"},
+	{"c", "<c>$0</c>", "Formats inline code"},
+	{"code", "<code>$0</code>", "Formats a block of code"},
+}
+
+// documentedParam matches the param and typeparam tags already written, capturing the name
+var documentedParam = regexp.MustCompile(`<(param|typeparam)\s+name="([^"]*)"`)
+
+// documentedMember is the declaration a documentation comment precedes, as far as its tags go
+type documentedMember struct {
+	parameters     []string
+	typeParameters []string
+	// returnsValue is set for methods not returning void, the members returns applies to
+	returnsValue bool
+}
+
+// inDocComment reports whether the caret, after before on its line, is in a /// comment and
+// outside the quotes of an attribute, where tags can be typed
+func inDocComment(before string) bool {
+	comment, ok := strings.CutPrefix(strings.TrimLeft(before, " \t"), "///")
+	if !ok {
+		return false
+	}
+	if open := strings.LastIndexByte(comment, '<'); open > strings.LastIndexByte(comment, '>') {
+		return strings.Count(comment[open:], `"`)%2 == 0
+	}
+	return true
+}
+
+// docCommentCompletions offers the XML documentation tags, with a param tag ready for each
+// parameter of the member documented not described yet. The parameters are those OmniSharp
+// reports for the member, or those declared in the buffer while it has no answer
+func (s *Server) docCommentCompletions(ctx context.Context, doc Document, pos protocol.Position) []CompletionItem {
+	line := lineAt(doc.Text, pos.Line)
+	before := line[:utf16ToByteOffset(line, pos.Character)]
+	// Typing < starts the tag, which the items then replace along with the name typed after it
+	typed := identifierBefore(before)
+	start := pos
+	start.Character -= byteToUTF16Offset(typed, len(typed))
+	if strings.HasSuffix(before[:len(before)-len(typed)], "<") {
+		start.Character--
+	}
+	_, word := wordRanges(doc.Text, pos)
+	replace := protocol.Range{Start: start, End: word.End}
+
+	lines := splitLines(doc.Text)
+	documented := make(map[string]bool)
+	for i := docCommentStart(lines, int(pos.Line)); i < len(lines) && isDocCommentLine(lines[i]); i++ {
+		for _, match := range documentedParam.FindAllStringSubmatch(lines[i], -1) {
+			documented[match[1]+" "+match[2]] = true
+		}
+	}
+	member, hasMember := s.documentedMember(ctx, doc, lines, int(pos.Line))
+
+	items := []CompletionItem{}
+	add := func(label, snippet, detail string) {
+		items = append(items, CompletionItem{
+			CompletionItem: protocol.CompletionItem{
+				Label:            label,
+				Kind:             protocol.CompletionItemKindProperty,
+				Detail:           detail,
+				FilterText:       "<" + label,
+				SortText:         fmt.Sprintf("%02d", len(items)),
+				InsertText:       snippet,
+				InsertTextFormat: protocol.InsertTextFormatSnippet,
+			},
+			TextEdit: &protocol.TextEdit{Range: replace, NewText: snippet},
+		})
+	}
+
+	add(docTags[0].label, docTags[0].snippet, docTags[0].detail)
+	for _, name := range member.parameters {
+		if !documented["param "+name] {
+			add(`param name="`+name+`"`, `<param name="`+name+`">$0</param>`, "Describes the parameter "+name)
+		}
+	}
+	for _, name := range member.typeParameters {
+		if !documented["typeparam "+name] {
+			add(`typeparam name="`+name+`"`, `<typeparam name="`+name+`">$0</typeparam>`, "Describes the type parameter "+name)
+		}
+	}
+	if !hasMember {
+		add(`param name=""`, `<param name="$1">$0</param>`, "Describes a parameter")
+	}
+	for _, tag := range docTags[1:] {
+		if tag.label == "returns" && hasMember && !member.returnsValue {
+			continue
+		}
+		add(tag.label, tag.snippet, tag.detail)
+	}
+	for _, name := range member.parameters {
+		add(`paramref name="`+name+`"`, `<paramref name="`+name+`"/>`, "Refers to the parameter "+name)
+	}
+	return items
+}
+
+func isDocCommentLine(line string) bool {
+	return strings.HasPrefix(strings.TrimLeft(line, " \t"), "///")
+}
+
+// docCommentStart is the first line of the documentation comment holding line
+func docCommentStart(lines []string, line int) int {
+	for line > 0 && isDocCommentLine(lines[line-1]) {
+		line--
+	}
+	return line
+}
+
+// documentedMember reads the parameters of the method the documentation comment at line
+// precedes. It reports false when the comment precedes no method, or none could be read
+func (s *Server) documentedMember(ctx context.Context, doc Document, lines []string, line int) (documentedMember, bool) {
+	declaration, at, ok := declarationAfter(lines, line)
+	if !ok {
+		return documentedMember{}, false
+	}
+	name, open, ok := methodName(declaration)
+	if !ok {
+		return documentedMember{}, false
+	}
+
+	signature := declaration
+	if omnisharp := s.completionBackend(); omnisharp != nil {
+		offset := offsetAt(doc.Text, protocol.Position{Line: uint32(at)}) + open - len(name)
+		request := omnisharpPosition(doc.URI, positionAt(doc.Text, offset))
+		response, err := omnisharp.SendRequest(ctx, "/typelookup", request)
+		var lookup TypeLookupResponse
+		if err != nil {
+			log.Printf("failed to look up the documented member: %v", err)
+		} else if json.Unmarshal(response, &lookup) == nil && (strings.Contains(lookup.Type, name+"(") || strings.Contains(lookup.Type, name+"<")) {
+			signature = lookup.Type
+		}
+	}
+	return parseDocumentedMember(signature, name)
+}
+
+// declarationAfter joins the lines of the declaration following the documentation comment at
+// line, past its attributes, up to its body, returning the line it starts at
+func declarationAfter(lines []string, line int) (string, int, bool) {
+	i := line
+	for i < len(lines) && isDocCommentLine(lines[i]) {
+		i++
+	}
+	for i < len(lines) && (strings.TrimSpace(lines[i]) == "" || strings.HasPrefix(strings.TrimSpace(lines[i]), "[")) {
+		i++
+	}
+	// Parameter lists are rarely split over more lines
+	var declaration strings.Builder
+	for j := i; j < len(lines) && j < i+8; j++ {
+		text := lines[j]
+		if end := strings.IndexAny(text, "{;"); end >= 0 {
+			declaration.WriteString(text[:end])
+			return declaration.String(), i, true
+		}
+		declaration.WriteString(text + "\n")
+	}
+	if i >= len(lines) {
+		return "", 0, false
+	}
+	return declaration.String(), i, true
+}
+
+// methodName finds the method name in a declaration, returning the offset of what follows it,
+// the parameter list or its type parameters
+func methodName(declaration string) (string, int, bool) {
+	open := strings.IndexByte(declaration, '(')
+	if open < 0 {
+		return "", 0, false
+	}
+	end := len(strings.TrimRight(declaration[:open], " \t\n"))
+	if strings.HasSuffix(declaration[:end], ">") {
+		end = strings.LastIndexByte(declaration[:end], '<')
+		if end < 0 {
+			return "", 0, false
+		}
+	}
+	name := identifierBefore(declaration[:end])
+	if name == "" || containsString(csharpKeywords, name) {
+		return "", 0, false
+	}
+	return name, end, true
+}
+
+// parseDocumentedMember reads the parameters, type parameters and return type of method name
+// in signature, either as declared or as /typelookup describes it, such as "void
+// Player.TakeDamage(int amount, string source)"
+func parseDocumentedMember(signature, name string) (documentedMember, bool) {
+	at := strings.Index(signature, name+"(")
+	if generic := strings.Index(signature, name+"<"); at < 0 || (generic >= 0 && generic < at) {
+		at = generic
+	}
+	if at < 0 {
+		return documentedMember{}, false
+	}
+
+	var member documentedMember
+	rest := signature[at+len(name):]
+	if strings.HasPrefix(rest, "<") {
+		end := strings.IndexByte(rest, '>')
+		if end < 0 {
+			return documentedMember{}, false
+		}
+		for _, parameter := range strings.Split(rest[1:end], ",") {
+			if parameter = strings.TrimSpace(parameter); parameter != "" {
+				member.typeParameters = append(member.typeParameters, parameter)
+			}
+		}
+		rest = rest[end+1:]
+	}
+	open := strings.IndexByte(rest, '(')
+	end := strings.LastIndexByte(rest, ')')
+	if open < 0 || end < open {
+		return documentedMember{}, false
+	}
+	for _, parameter := range splitParameters(rest[open+1 : end]) {
+		parameter, _, _ = strings.Cut(parameter, "=")
+		if fields := strings.Fields(parameter); len(fields) > 1 {
+			member.parameters = append(member.parameters, strings.TrimPrefix(fields[len(fields)-1], "@"))
+		}
+	}
+
+	// The return type is the word before the qualified name; constructors have none
+	qualified := strings.LastIndexAny(signature[:at], " \t\n") + 1
+	if fields := strings.Fields(signature[:qualified]); len(fields) > 0 {
+		returnType := fields[len(fields)-1]
+		member.returnsValue = returnType != "void" && !memberModifiers[returnType]
+	}
+	return member, true
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestInDocComment(t *testing.T) {
+	tests := []struct {
+		before string
+		want   bool
+	}{
+		{"    /// ", true},
+		{"    /// <", true},
+		{"    /// <summary>Deals damage ", true},
+		{`    /// <param name="`, false},
+		{`    /// <param name="amount" `, true},
+		{"    // ", false},
+		{"    var x = 1; /// ", false},
+	}
+	for _, test := range tests {
+		t.Run(test.before, func(t *testing.T) {
+			if got := inDocComment(test.before); got != test.want {
+				t.Errorf("inDocComment = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseDocumentedMember(t *testing.T) {
+	tests := []struct {
+		name      string
+		signature string
+		method    string
+		want      documentedMember
+		wantOk    bool
+	}{
+		{
+			"declared", "    public bool TakeDamage(int amount, string source = \"\") ", "TakeDamage",
+			documentedMember{parameters: []string{"amount", "source"}, returnsValue: true}, true,
+		},
+		{
+			"looked up", "void Player.TakeDamage(int amount, string source)", "TakeDamage",
+			documentedMember{parameters: []string{"amount", "source"}}, true,
+		},
+		{
+			"generic", "    T Find<T, TKey>(TKey key) where T : Component", "Find",
+			documentedMember{parameters: []string{"key"}, typeParameters: []string{"T", "TKey"}, returnsValue: true}, true,
+		},
+		{
+			"modifiers and verbatim names", "    static void Swap(ref int @from, out int to)", "Swap",
+			documentedMember{parameters: []string{"from", "to"}}, true,
+		},
+		{"constructor", "    public Player(float speed)", "Player", documentedMember{parameters: []string{"speed"}}, true},
+		{"no parameters", "    protected virtual int Count()", "Count", documentedMember{returnsValue: true}, true},
+		{"other method", "    void Jump()", "Heal", documentedMember{}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := parseDocumentedMember(test.signature, test.method)
+			if ok != test.wantOk || !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseDocumentedMember = %+v, %v; want %+v, %v", got, ok, test.want, test.wantOk)
+			}
+		})
+	}
+}
+
+func TestMethodName(t *testing.T) {
+	tests := []struct {
+		declaration string
+		want        string
+	}{
+		{"public void TakeDamage(int amount)", "TakeDamage"},
+		{"T Find<T>(string name)", "Find"},
+		{"public Player (float speed)", "Player"},
+		{"public int Health", ""},
+		{"if (alive)", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.declaration, func(t *testing.T) {
+			if got, _, _ := methodName(test.declaration); got != test.want {
+				t.Errorf("methodName = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestDocCommentCompletions checks a /// comment is offered the documentation tags, with a
+// param tag for each parameter of the method it documents not described yet, named as
+// OmniSharp reports them or else as declared
+func TestDocCommentCompletions(t *testing.T) {
+	const text = "class Player\n{\n" +
+		"    /// <summary>Deals damage</summary>\n" +
+		"    /// <param name=\"amount\">How much</param>\n" +
+		"    /// <su\n" +
+		"    [ContextMenu(\"Damage\")]\n" +
+		"    public bool TakeDamage<T>(int amount, string source = \"\") { return true; }\n" +
+		"}\n"
+	tags := []string{"exception", "see", "seealso", "remarks", "value", "example", "inheritdoc", "para", "c", "code"}
+	tests := []struct {
+		name string
+		// lookup is the member /typelookup describes, none if empty
+		lookup string
+		want   []string
+	}{
+		{
+			"declared parameters", "",
+			append(append([]string{"summary", `param name="source"`, `typeparam name="T"`, "returns"}, tags...), `paramref name="amount"`, `paramref name="source"`),
+		},
+		{
+			"parameters OmniSharp reports", "bool Player.TakeDamage<T>(int amount, string origin)",
+			append(append([]string{"summary", `param name="origin"`, `typeparam name="T"`, "returns"}, tags...), `paramref name="amount"`, `paramref name="origin"`),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/typelookup": TypeLookupResponse{Type: test.lookup}})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, text)
+
+			list := completeAt(t, s, uri, protocol.Position{Line: 4, Character: 11}, protocol.CompletionTriggerKindInvoked)
+			if got := labels(list.Items); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("completions = %q, want %q", got, test.want)
+			}
+			if fake.callCount("/autocomplete") != 0 {
+				t.Error("asked OmniSharp to complete a documentation comment")
+			}
+			// The tag replaces the < and the name typed
+			edit := list.Items[0].TextEdit.(*protocol.TextEdit)
+			if want := (protocol.Range{Start: protocol.Position{Line: 4, Character: 8}, End: protocol.Position{Line: 4, Character: 11}}); edit.Range != want || edit.NewText != "<summary></summary>" {
+				t.Errorf("summary edits %+v, want <summary></summary> over %+v", edit, want)
+			}
+		})
+	}
+}
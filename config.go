@@ -0,0 +1,364 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Config holds user settings, sent by the client as initializationOptions
+type Config struct {
+	OmniSharp      OmniSharpConfig      `json:"omnisharp"`
+	Documents      DocumentsConfig      `json:"documents"`
+	Completion     CompletionConfig     `json:"completion"`
+	Diagnostics    DiagnosticsConfig    `json:"diagnostics"`
+	Generated      GeneratedConfig      `json:"generated"`
+	Formatting     FormattingConfig     `json:"formatting"`
+	Telemetry      TelemetryConfig      `json:"telemetry"`
+	Usings         UsingsConfig         `json:"usings"`
+	Navigation     NavigationConfig     `json:"navigation"`
+	SemanticTokens SemanticTokensConfig `json:"semanticTokens"`
+}
+
+type OmniSharpConfig struct {
+	// Path is the OmniSharp executable, or OmniSharp.dll, to launch
+	Path string `json:"path"`
+	// LaunchMode is "executable" to run Path directly, "dotnet" to run it as OmniSharp.dll under
+	// dotnet, or "auto" to choose from its extension
+	LaunchMode string `json:"launchMode"`
+	// Address connects to an OmniSharp already running there rather than launching Path: a
+	// host:port, or the path of a Unix socket on macOS and Linux. Reloads don't restart it
+	Address string `json:"address"`
+	// StartupTimeout bounds how long we wait for OmniSharp to load the solution
+	StartupTimeout Duration `json:"startupTimeout"`
+	// RequestTimeout bounds each request to OmniSharp once it is running
+	RequestTimeout Duration `json:"requestTimeout"`
+	// Compression asks OmniSharp for gzipped responses, and gzips large request bodies such as
+	// buffer syncs once OmniSharp advertises it accepts them. Off, everything is sent as is
+	Compression bool `json:"compression"`
+	// DedicatedCompletionInstance runs a second OmniSharp that only serves completion, which
+	// keeps completion fast on large solutions at the cost of twice the memory
+	DedicatedCompletionInstance bool `json:"dedicatedCompletionInstance"`
+	// EnableRoslynAnalyzers runs the analyzers of the projects and their packages, for richer
+	// diagnostics and fixes at the cost of slower diagnostics and more memory
+	EnableRoslynAnalyzers bool `json:"enableRoslynAnalyzers"`
+	// EnableEditorConfigSupport applies the .editorconfig files of the project to formatting
+	// and code style
+	EnableEditorConfigSupport bool `json:"enableEditorConfigSupport"`
+	// ImplementAutoProperties generates auto-properties rather than properties throwing
+	// NotImplementedException when implementing interface and abstract members
+	ImplementAutoProperties bool `json:"implementAutoProperties"`
+	// ReloadOnProjectChanges restarts OmniSharp when a .csproj or .asmdef file changes, for
+	// project changes OmniSharp doesn't pick up itself, such as a new assembly definition. The
+	// files are only watched if it is set at startup
+	ReloadOnProjectChanges bool `json:"reloadOnProjectChanges"`
+	// CustomEndpoints are the endpoints unity-lsp/omnisharp forwards requests to, such as those
+	// of OmniSharp plugins. Empty forwards none
+	CustomEndpoints []string `json:"customEndpoints"`
+	// DuringReload is "placeholder" to answer completion, hover and definition at once while
+	// projects reload, with an incomplete empty list the editor asks again for and nulls, or
+	// "wait" to hold them until the open buffers are synced to the new process
+	DuringReload string `json:"duringReload"`
+}
+
+type DocumentsConfig struct {
+	// MaxTracked caps open plus cached closed documents; least recently used closed ones are
+	// evicted beyond it. Zero disables the cap
+	MaxTracked int `json:"maxTracked"`
+}
+
+type CompletionConfig struct {
+	// TriggerCharacters always trigger completion
+	TriggerCharacters []string `json:"triggerCharacters"`
+	// ContextTriggers trigger completion only where they start something completable: "<"
+	// for generic arguments, "[" for attributes and indexers, "@" for verbatim identifiers,
+	// and a quote for the tag, layer, input and scene names of CompareTag, Input.GetAxis,
+	// SceneManager.LoadScene and the like
+	ContextTriggers []string `json:"contextTriggers"`
+	// SpaceTriggerKeywords are the keywords after which typing a space triggers completion, if
+	// " " is a trigger character. Spaces after anything else, such as return or =, don't
+	SpaceTriggerKeywords []string `json:"spaceTriggerKeywords"`
+	// Debounce delays the OmniSharp query of a completion triggered by a character, dropping it
+	// if another completion arrives meanwhile. Zero queries at once
+	Debounce Duration `json:"debounce"`
+	// Timeout bounds the wait for OmniSharp's items, past which the list holds those received
+	// so far and is marked incomplete. Zero waits as long as the request timeout
+	Timeout Duration `json:"timeout"`
+	// SignatureHelpOnAccept opens signature help after accepting a method that takes parameters
+	SignatureHelpOnAccept bool `json:"signatureHelpOnAccept"`
+	// MaxItems caps the OmniSharp items of a completion list; a capped list is marked incomplete
+	// so the client asks again as more is typed. Zero disables the cap
+	MaxItems int `json:"maxItems"`
+	// InsertAwait also inserts await when accepting a method returning a task in an async method
+	InsertAwait bool `json:"insertAwait"`
+	// TypeArgumentSnippets inserts the type arguments of a generic method as placeholders when
+	// accepting it, filled in with the declared type of the variable it initializes if it returns
+	// its type argument, as for Rigidbody body = GetComponent<Rigidbody>
+	TypeArgumentSnippets bool `json:"typeArgumentSnippets"`
+	// NullCoalescing offers ??= new after a nullable variable or member starting a statement,
+	// and ?? throw after one in an expression, at the cost of looking up its type
+	NullCoalescing bool `json:"nullCoalescing"`
+	// CollapseOverloads shows the overloads of a method as a single item
+	CollapseOverloads bool `json:"collapseOverloads"`
+	// RecentlyUsed ranks items accepted recently above others of equal relevance
+	RecentlyUsed bool `json:"recentlyUsed"`
+	// KindPriority ranks items of equal relevance by kind, lowest first, keyed by the LSP name
+	// of the kind such as "property". Entries override the default ranks of their kind
+	KindPriority map[string]int `json:"kindPriority"`
+	// Scope is "all" to offer every symbol, or "projectOnly" to leave out those of the .NET and
+	// Unity assemblies, except for the members of such a type after a dot
+	Scope string `json:"scope"`
+	// CommitCharacters accept the selected OmniSharp item when typed, such as "." or "(";
+	// other items don't commit. Empty leaves committing to the client
+	CommitCharacters []string `json:"commitCharacters"`
+}
+
+type DiagnosticsConfig struct {
+	// WarmDefinitionTargets computes diagnostics for a file as soon as go-to-definition points
+	// into it, before the client opens it, at the cost of an extra codecheck
+	WarmDefinitionTargets bool `json:"warmDefinitionTargets"`
+	// Scope is "openFiles" to check only documents the client has open, or "fullProject" to
+	// also publish diagnostics for every other file of the solution, which costs a
+	// solution-wide codecheck after edits
+	Scope string `json:"scope"`
+	// MaxConcurrent bounds the documents checked at once; others wait, the one last opened or
+	// edited first. Zero checks them all at once
+	MaxConcurrent int `json:"maxConcurrent"`
+	// QuietPeriod holds the diagnostics computed right after OmniSharp loads the solution,
+	// publishing each document's settled set once it ends. Zero publishes them at once
+	QuietPeriod Duration `json:"quietPeriod"`
+}
+
+type GeneratedConfig struct {
+	// Patterns match generated and build output files by their path relative to the workspace
+	// root; ** stands for any number of directories
+	Patterns []string `json:"patterns"`
+	// Diagnostics reports diagnostics for generated files, and warms them as definition targets
+	Diagnostics bool `json:"diagnostics"`
+	// WorkspaceSymbols includes symbols declared in generated files in workspace symbol search
+	WorkspaceSymbols bool `json:"workspaceSymbols"`
+}
+
+// FormattingConfig is the C# style OmniSharp formats with
+type FormattingConfig struct {
+	// IndentationSize and UseTabs describe the indentation used when the editor doesn't send
+	// its own with the format request
+	IndentationSize int  `json:"indentationSize"`
+	UseTabs         bool `json:"useTabs"`
+	// NewLine is the line break inserted by formatting
+	NewLine string `json:"newLine"`
+	// SpaceAfterKeyword puts a space between control flow keywords such as if and their parenthesis
+	SpaceAfterKeyword bool `json:"spaceAfterKeyword"`
+	// OrganizeImportsOnFormat sorts usings and removes unused ones when formatting
+	OrganizeImportsOnFormat bool `json:"organizeImportsOnFormat"`
+}
+
+type TelemetryConfig struct {
+	// Enabled sends the client anonymous timings of completion, hover and definition requests
+	// as telemetry/event notifications, to help diagnose slowness
+	Enabled bool `json:"enabled"`
+}
+
+type UsingsConfig struct {
+	// Placement is where added usings go: "insideNamespace", "outsideNamespace", or "auto" to
+	// follow the usings already in the file
+	Placement string `json:"placement"`
+	// SortSystemFirst orders System namespaces before the others when inserting into a sorted
+	// block, and when organizing usings
+	SortSystemFirst bool `json:"sortSystemFirst"`
+	// OrganizeOnSave removes unused usings and sorts the rest before each save
+	OrganizeOnSave bool `json:"organizeOnSave"`
+}
+
+type NavigationConfig struct {
+	// SymbolSearchFallback goes to a symbol of the same name, found by workspace symbol
+	// search, when OmniSharp finds no definition, as in a buffer too broken to resolve. The
+	// match is a guess, so the user is told
+	SymbolSearchFallback bool `json:"symbolSearchFallback"`
+	// ReferencesScope is "solution" to find references throughout the solution, or "project"
+	// to keep those in the project of the document searched from. A request's scope overrides it
+	ReferencesScope string `json:"referencesScope"`
+}
+
+type SemanticTokensConfig struct {
+	// MaxLines is the length above which a document only gets tokens for the ranges the client
+	// asks, typically what is visible, leaving the rest to its grammar. Zero highlights every
+	// document whole
+	MaxLines int `json:"maxLines"`
+}
+
+const (
+	launchAuto       = "auto"
+	launchExecutable = "executable"
+	launchDotnet     = "dotnet"
+)
+
+const (
+	scopeOpenFiles   = "openFiles"
+	scopeFullProject = "fullProject"
+)
+
+const (
+	referencesScopeSolution = "solution"
+	referencesScopeProject  = "project"
+)
+
+const (
+	duringReloadPlaceholder = "placeholder"
+	duringReloadWait        = "wait"
+)
+
+const (
+	completionScopeAll         = "all"
+	completionScopeProjectOnly = "projectOnly"
+)
+
+// redacted returns a copy of c fit to show or log, without secrets
+func (c Config) redacted() Config {
+	c.OmniSharp.Address = redactedAddress(c.OmniSharp.Address)
+	return c
+}
+
+// redactedAddress masks the credentials an OmniSharp address URL may carry
+func redactedAddress(address string) string {
+	parsed, err := url.Parse(address)
+	if err != nil || parsed.User == nil {
+		return address
+	}
+	parsed.User = url.User("xxxxx")
+	return parsed.String()
+}
+
+func DefaultConfig() Config {
+	return Config{
+		OmniSharp: OmniSharpConfig{
+			Path:                      "OmniSharp",
+			LaunchMode:                launchAuto,
+			StartupTimeout:            Duration(90 * time.Second),
+			RequestTimeout:            Duration(30 * time.Second),
+			EnableEditorConfigSupport: true,
+			DuringReload:              duringReloadPlaceholder,
+		},
+		Documents: DocumentsConfig{
+			MaxTracked: 200,
+		},
+		Completion: CompletionConfig{
+			TriggerCharacters:     []string{".", " "},
+			ContextTriggers:       []string{"<", "[", `"`},
+			SpaceTriggerKeywords:  []string{"new", "case", "override", "partial", "is", "as", "using", "namespace"},
+			Debounce:              Duration(50 * time.Millisecond),
+			Timeout:               Duration(2 * time.Second),
+			SignatureHelpOnAccept: true,
+			MaxItems:              1000,
+			RecentlyUsed:          true,
+			KindPriority: map[string]int{
+				"variable":   0,
+				"field":      1,
+				"property":   2,
+				"method":     3,
+				"event":      4,
+				"enummember": 5,
+				"constant":   6,
+				"class":      7,
+				"struct":     8,
+				"interface":  9,
+				"enum":       10,
+				"module":     11,
+				"keyword":    12,
+				"snippet":    13,
+			},
+			Scope: completionScopeAll,
+		},
+		Diagnostics: DiagnosticsConfig{
+			WarmDefinitionTargets: true,
+			Scope:                 scopeOpenFiles,
+			MaxConcurrent:         2,
+			QuietPeriod:           Duration(3 * time.Second),
+		},
+		Navigation: NavigationConfig{
+			ReferencesScope: referencesScopeSolution,
+		},
+		SemanticTokens: SemanticTokensConfig{
+			MaxLines: 5000,
+		},
+		Generated: GeneratedConfig{
+			Patterns: []string{
+				"**/obj/**", "**/bin/**", "Temp/**", "Library/**",
+				"**/*.g.cs", "**/*.designer.cs",
+			},
+			WorkspaceSymbols: true,
+		},
+		Formatting: FormattingConfig{
+			IndentationSize:   4,
+			NewLine:           "\n",
+			SpaceAfterKeyword: true,
+		},
+		Usings: UsingsConfig{
+			Placement:       placementAuto,
+			SortSystemFirst: true,
+		},
+	}
+}
+
+// normalizeKindPriority lowercases the kinds of KindPriority, so that "enumMember" overrides
+// the default rank of "enummember", and rejects kinds LSP doesn't define and negative ranks
+func (c *CompletionConfig) normalizeKindPriority() error {
+	for name, rank := range c.KindPriority {
+		if _, ok := completionKinds[strings.ToLower(name)]; !ok {
+			return fmt.Errorf("unknown completion item kind %q", name)
+		}
+		if rank < 0 {
+			return fmt.Errorf("negative rank %d for %s", rank, name)
+		}
+		if lower := strings.ToLower(name); lower != name {
+			delete(c.KindPriority, name)
+			c.KindPriority[lower] = rank
+		}
+	}
+	return nil
+}
+
+// kindRanks converts KindPriority, once normalized, into ranks by CompletionItemKind
+func (c CompletionConfig) kindRanks() kindRanks {
+	ranks := make(kindRanks, len(c.KindPriority))
+	for name, rank := range c.KindPriority {
+		ranks[completionKinds[name]] = rank
+	}
+	return ranks
+}
+
+// apply overlays the settings found in options onto the config, keeping defaults for missing keys
+func (c *Config) apply(options interface{}) error {
+	if options == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, c)
+}
+
+// Duration is a time.Duration written in config as a string such as "90s" or "500ms"
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"90s\": %w", err)
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+var (
+	// usingTarget captures the name a using directive imports, after any static or alias
+	usingTarget = regexp.MustCompile(`^\s*(?:global\s+)?using\s+(?:static\s+)?(?:@?\w+\s*=\s*)?([A-Za-z_][\w.]*)\s*;`)
+	// namespaceDeclaration captures the name of block and file-scoped namespace declarations
+	namespaceDeclaration = regexp.MustCompile(`(?m)^[ \t]*namespace[ \t]+([A-Za-z_][\w.]*)`)
+	// stringMethodCall matches text ending in the call of a Unity API naming a method in a string,
+	// up to the opening quote of the name
+	stringMethodCall = regexp.MustCompile(`\b(?:Invoke|InvokeRepeating|CancelInvoke|IsInvoking|StartCoroutine|StopCoroutine|SendMessage|SendMessageUpwards|BroadcastMessage)\s*\(\s*"$`)
+)
+
+// onPreprocessorLine reports whether pos is on a directive such as #if or #region, whose
+// symbols have no declaration to go to
+func onPreprocessorLine(text string, pos protocol.Position) bool {
+	line := lineAt(text, pos.Line)
+	trimmed := strings.TrimLeft(line, " \t")
+	if !strings.HasPrefix(trimmed, "#") {
+		return false
+	}
+	hash := offsetAt(text, protocol.Position{Line: pos.Line}) + len(line) - len(trimmed)
+	return tokenClassAt(text, hash) == tokenCode
+}
+
+// usingNamespaceAt returns the namespace named by a using directive up to the segment at pos,
+// so that Game in using Game.Enemies; is Game itself
+func usingNamespaceAt(text string, pos protocol.Position) (string, bool) {
+	line := lineAt(text, pos.Line)
+	match := usingTarget.FindStringSubmatchIndex(line)
+	if match == nil {
+		return "", false
+	}
+	caret := utf16ToByteOffset(line, pos.Character)
+	if caret < match[2] || caret > match[3] {
+		return "", false
+	}
+	end := caret
+	for end < match[3] && line[end] != '.' {
+		end++
+	}
+	return strings.TrimSuffix(line[match[2]:end], "."), true
+}
+
+// stringMethodAt returns the method named by the string literal at pos when it is the first
+// argument of Invoke, StartCoroutine, SendMessage and the like
+func stringMethodAt(text string, pos protocol.Position) (string, bool) {
+	_, word := wordRanges(text, pos)
+	start, end := offsetAt(text, word.Start), offsetAt(text, word.End)
+	if start == end || tokenClassAt(text, start) != tokenString {
+		return "", false
+	}
+	if !strings.HasPrefix(text[end:], `"`) || !stringMethodCall.MatchString(text[:start]) {
+		return "", false
+	}
+	return text[start:end], true
+}
+
+// methodDefinitions finds the methods named name, preferring those declared in uri since
+// Unity looks the name up on the component it is called on
+func (s *Server) methodDefinitions(ctx context.Context, omnisharp *OmniSharpClient, uri protocol.DocumentURI, name string) ([]protocol.Location, error) {
+	found, err := findSymbols(ctx, omnisharp, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var local, elsewhere []protocol.Location
+	for _, symbol := range found {
+		if symbol.Kind != "Method" || symbolName(symbol.Text) != name || symbol.FileName == "" {
+			continue
+		}
+		location := s.quickFixLocation(symbol.QuickFix)
+		if location.URI == uri {
+			local = append(local, location)
+		} else {
+			elsewhere = append(elsewhere, location)
+		}
+	}
+	if len(local) > 0 {
+		return local, nil
+	}
+	return elsewhere, nil
+}
+
+// symbolName strips the parameters and type parameters /findsymbols shows after a method name
+func symbolName(text string) string {
+	if end := strings.IndexAny(text, "(<"); end >= 0 {
+		return strings.TrimSpace(text[:end])
+	}
+	return text
+}
+
+// namespaceDefinition finds a declaration of namespace in the workspace. Namespaces are spread
+// over many files, so only the primary one is returned: a declaration of the namespace itself
+// over one of a namespace nested in it, then the file closest to the workspace root. Open
+// documents are read as edited
+func (s *Server) namespaceDefinition(ctx context.Context, namespace string) []protocol.Location {
+	var best protocol.Location
+	bestRank, bestPath := -1, ""
+	filepath.WalkDir(s.rootPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fs.SkipAll
+		}
+		if entry.IsDir() {
+			name := entry.Name()
+			if path != s.rootPath && (solutionSkipDirs[name] || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".cs" {
+			return nil
+		}
+
+		uri := pathToURI(path)
+		var text string
+		if doc, ok := s.documents.Get(uri); ok {
+			text = doc.Text
+		} else if data, err := os.ReadFile(path); err == nil {
+			text = string(data)
+		} else {
+			return nil
+		}
+
+		for _, match := range namespaceDeclaration.FindAllStringSubmatchIndex(text, -1) {
+			declared := text[match[2]:match[3]]
+			rank := 1
+			if declared == namespace {
+				rank = 0
+			} else if !strings.HasPrefix(declared, namespace+".") {
+				continue
+			}
+			rank = rank*1000 + strings.Count(path, string(filepath.Separator))
+			if bestRank >= 0 && (rank > bestRank || rank == bestRank && path >= bestPath) {
+				continue
+			}
+			start := positionAt(text, match[2])
+			best = protocol.Location{
+				URI:   uri,
+				Range: protocol.Range{Start: start, End: positionAt(text, match[2]+len(namespace))},
+			}
+			bestRank, bestPath = rank, path
+		}
+		return nil
+	})
+
+	if bestRank < 0 {
+		return nil
+	}
+	return []protocol.Location{best}
+}
+
+// symbolSearchDefinition guesses the definition of the identifier at pos by searching the
+// workspace for symbols of that name, preferring those declared in doc, then in its folder.
+// The user is told the result is a guess, as a symbol of the same name may not be the one meant
+func (s *Server) symbolSearchDefinition(ctx context.Context, omnisharp *OmniSharpClient, doc Document, pos protocol.Position) []protocol.Location {
+	_, word := wordRanges(doc.Text, pos)
+	name := doc.Text[offsetAt(doc.Text, word.Start):offsetAt(doc.Text, word.End)]
+	if name == "" {
+		return nil
+	}
+
+	found, err := findSymbols(ctx, omnisharp, name)
+	if err != nil {
+		log.Printf("failed to search symbols named %s: %v", name, err)
+		return nil
+	}
+
+	var best protocol.Location
+	bestRank := -1
+	dir := filepath.Dir(doc.URI.Filename())
+	for _, symbol := range found {
+		if symbol.FileName == "" || symbolName(symbol.Text) != name {
+			continue
+		}
+		location := s.quickFixLocation(symbol.QuickFix)
+		rank := 2
+		if location.URI == doc.URI {
+			rank = 0
+		} else if filepath.Dir(location.URI.Filename()) == dir {
+			rank = 1
+		}
+		if bestRank < 0 || rank < bestRank {
+			best, bestRank = location, rank
+		}
+	}
+	if bestRank < 0 {
+		return nil
+	}
+
+	s.client.ShowMessage(ctx, &protocol.ShowMessageParams{
+		Type:    protocol.MessageTypeInfo,
+		Message: fmt.Sprintf(s.localize("Unity LSP: OmniSharp found no definition of %s, so this is a symbol of the same name in %s, which may not be the one meant."), name, s.displayPath(best.URI)),
+	})
+	return []protocol.Location{best}
+}
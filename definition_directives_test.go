@@ -0,0 +1,213 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+// caretIn splits text with | at the caret into the text and the caret's position
+func caretIn(text string) (string, protocol.Position) {
+	offset := strings.Index(text, "|")
+	text = strings.Replace(text, "|", "", 1)
+	return text, positionAt(text, offset)
+}
+
+func TestUsingNamespaceAt(t *testing.T) {
+	tests := []struct {
+		name string
+		// text has | at the caret
+		text   string
+		want   string
+		wantOk bool
+	}{
+		{"last segment", "using Game.Ene|mies;", "Game.Enemies", true},
+		{"first segment", "using Ga|me.Enemies;", "Game", true},
+		{"static", "using static Game.Ma|th;", "Game.Math", true},
+		{"alias", "using R = Unity|Engine.Random;", "UnityEngine", true},
+		{"global", "global using Sys|tem;", "System", true},
+		{"on the keyword", "us|ing Game;", "", false},
+		{"using statement", "using (var r = Op|en()) { }", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text, pos := caretIn(test.text)
+			got, ok := usingNamespaceAt(text, pos)
+			if got != test.want || ok != test.wantOk {
+				t.Errorf("usingNamespaceAt = %q, %v; want %q, %v", got, ok, test.want, test.wantOk)
+			}
+		})
+	}
+}
+
+func TestStringMethodAt(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		want   string
+		wantOk bool
+	}{
+		{"Invoke", `void M() { Invoke("Resp|awn", 2f); }`, "Respawn", true},
+		{"StartCoroutine", `void M() { StartCoroutine( "Fade|Out"); }`, "FadeOut", true},
+		{"SendMessage", `void M() { target.SendMessage("Take|Damage", 5); }`, "TakeDamage", true},
+		{"other call", `void M() { Debug.Log("Resp|awn"); }`, "", false},
+		{"more than a name", `void M() { Invoke("Resp|awn later", 2f); }`, "", false},
+		{"not a string", `void M() { Invoke(nameof(Resp|awn), 2f); }`, "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text, pos := caretIn(test.text)
+			got, ok := stringMethodAt(text, pos)
+			if got != test.want || ok != test.wantOk {
+				t.Errorf("stringMethodAt = %q, %v; want %q, %v", got, ok, test.want, test.wantOk)
+			}
+		})
+	}
+}
+
+func TestOnPreprocessorLine(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want bool
+	}{
+		{"if", "#if UNITY_ED|ITOR\n#endif\n", true},
+		{"indented region", "class Player {\n    #region Move|ment\n}\n", true},
+		{"code", "class Player { float sp|eed; }\n", false},
+		{"hash in a string", "string s = \"#if DE|BUG\";\n", false},
+		{"hash in a comment", "// #if DE|BUG\n", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text, pos := caretIn(test.text)
+			if got := onPreprocessorLine(text, pos); got != test.want {
+				t.Errorf("onPreprocessorLine = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestDirectiveDefinitions checks a using directive goes to the primary declaration of its
+// namespace, a preprocessor symbol nowhere, and a string naming a method to that method
+func TestDirectiveDefinitions(t *testing.T) {
+	files := map[string]string{
+		"Assets/Scripts/Enemies/Enemy.cs": "namespace Game.Enemies\n{\n    class Enemy { }\n}\n",
+		"Assets/Scripts/Enemies/Boss.cs":  "namespace Game.Enemies.Bosses;\n\nclass Boss { }\n",
+		"Assets/Player.cs":                "namespace Game.Enemies { }\n",
+		"Assets/Game.cs":                  "namespace Game.Core;\n",
+		"Library/Cache.cs":                "namespace Game;\n",
+	}
+	tests := []struct {
+		name string
+		// text is the open document, with | at the caret
+		text string
+		// want is the file gone to and the line, no file for nowhere
+		want     string
+		wantLine uint32
+	}{
+		{"using the namespace", "using Game.Ene|mies;\n", "Assets/Player.cs", 0},
+		{"using a parent namespace", "using Ga|me.Core;\n", "Assets/Game.cs", 0},
+		{"using an unknown namespace", "using Physics.Ragd|oll;\n", "", 0},
+		{"preprocessor symbol", "#if UNITY_ED|ITOR\n#endif\n", "", 0},
+		{"method named in a string", "class Spawner {\n    void Respawn() { }\n    void Start() { Invoke(\"Resp|awn\", 2f); }\n}\n", "Spawner.cs", 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/v2/gotodefinition": map[string]interface{}{"Definitions": []interface{}{}}})
+			s, _ := newTestServer(t, fake)
+			for name, text := range files {
+				path := filepath.Join(s.rootPath, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			uri := testURI(s, "Spawner.cs")
+			fake.setResponse("/findsymbols", map[string]interface{}{"QuickFixes": []SymbolLocation{
+				{QuickFix: QuickFix{FileName: testURI(s, "Assets/Player.cs").Filename(), Line: 9, Column: 10, Text: "Respawn()"}, Kind: "Method"},
+				{QuickFix: QuickFix{FileName: uri.Filename(), Line: 1, Column: 9, Text: "Respawn()"}, Kind: "Method"},
+				{QuickFix: QuickFix{FileName: uri.Filename(), Line: 1, Column: 6, Text: "Respawner"}, Kind: "Class"},
+			}})
+			text, pos := caretIn(test.text)
+			openTestDocument(s, uri, text)
+
+			locations := definitionAt(t, s, uri, pos)
+			if test.want == "" {
+				if len(locations) != 0 {
+					t.Errorf("definition = %+v, want none", locations)
+				}
+				return
+			}
+			if len(locations) != 1 || locations[0].URI != testURI(s, test.want) || locations[0].Range.Start.Line != test.wantLine {
+				t.Errorf("definition = %+v, want %s line %d", locations, test.want, test.wantLine)
+			}
+		})
+	}
+}
+
+// TestSymbolSearchFallback checks that with navigation.symbolSearchFallback, an identifier
+// OmniSharp finds no definition of goes to a symbol of its name, the nearest one, telling the
+// user it is a guess
+func TestSymbolSearchFallback(t *testing.T) {
+	const text = "class Spawner {\n    void Start() { Spawn(); }\n}\n"
+	tests := []struct {
+		name     string
+		disabled bool
+		// resolved has OmniSharp find the definition itself
+		resolved bool
+		// symbols are the files declaring a method named Spawn, and one named Spawner
+		symbols []string
+		want    string
+	}{
+		{name: "in the document", symbols: []string{"Other/Pool.cs", "Assets/Spawner.cs", "Assets/Wave.cs"}, want: "Assets/Spawner.cs"},
+		{name: "in the folder", symbols: []string{"Other/Pool.cs", "Assets/Wave.cs"}, want: "Assets/Wave.cs"},
+		{name: "elsewhere", symbols: []string{"Other/Pool.cs"}, want: "Other/Pool.cs"},
+		{name: "no symbol of the name"},
+		{name: "resolved by OmniSharp", resolved: true, symbols: []string{"Assets/Wave.cs"}, want: "Assets/Spawner.cs"},
+		{name: "disabled", disabled: true, symbols: []string{"Assets/Wave.cs"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{})
+			s, client := newTestServer(t, fake)
+			configure(s, func(config *Config) { config.Navigation.SymbolSearchFallback = !test.disabled })
+			uri := testURI(s, "Assets/Spawner.cs")
+			openTestDocument(s, uri, text)
+
+			definitions := map[string]interface{}{"Definitions": []interface{}{}}
+			if test.resolved {
+				definitions = definitionResponse(uri.Filename())
+			}
+			fake.setResponse("/v2/gotodefinition", definitions)
+			symbols := []SymbolLocation{{QuickFix: QuickFix{FileName: testURI(s, "Other/Spawner.cs").Filename(), Text: "Spawner"}, Kind: "Class"}}
+			for _, file := range test.symbols {
+				symbols = append(symbols, SymbolLocation{QuickFix: QuickFix{FileName: testURI(s, file).Filename(), Line: 4, Text: "Spawn()"}, Kind: "Method"})
+			}
+			fake.setResponse("/findsymbols", map[string]interface{}{"QuickFixes": symbols})
+
+			locations := definitionAt(t, s, uri, protocol.Position{Line: 1, Character: 21})
+			if test.want == "" {
+				if len(locations) != 0 {
+					t.Errorf("definition = %+v, want none", locations)
+				}
+			} else if len(locations) != 1 || locations[0].URI != testURI(s, test.want) {
+				t.Errorf("definition = %+v, want %s", locations, test.want)
+			}
+
+			searched := fake.callCount("/findsymbols") > 0
+			if wantSearch := !test.disabled && !test.resolved; searched != wantSearch {
+				t.Errorf("searched symbols %v, want %v", searched, wantSearch)
+			}
+			if guessed := test.want != "" && !test.resolved; guessed {
+				waitFor(t, "the user told of the guess", func() bool { return len(client.received(protocol.MethodWindowShowMessage)) > 0 })
+			} else if told := client.received(protocol.MethodWindowShowMessage); len(told) > 0 {
+				t.Errorf("told the user %s", told[0])
+			}
+		})
+	}
+}
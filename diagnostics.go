@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+// QuickFix is a diagnostic as reported by OmniSharp's /codecheck
+type QuickFix struct {
+	Id        string `json:"Id"`
+	LogLevel  string `json:"LogLevel"`
+	FileName  string `json:"FileName"`
+	Line      uint32 `json:"Line"`
+	Column    uint32 `json:"Column"`
+	EndLine   uint32 `json:"EndLine"`
+	EndColumn uint32 `json:"EndColumn"`
+	Text      string `json:"Text"`
+}
+
+// diagnosticsPublisher tracks the diagnostics pass running for each document and serializes
+// publishing, so a pass for a document that was closed or edited meanwhile is dropped
+type diagnosticsPublisher struct {
+	mu        sync.Mutex
+	passes    map[protocol.DocumentURI]context.CancelFunc
+	published map[protocol.DocumentURI][]protocol.Diagnostic
+	// held are the last diagnostics of each document computed during a quiet period
+	held       map[protocol.DocumentURI]heldDiagnostics
+	quietUntil time.Time
+	quietTimer *time.Timer
+}
+
+// heldDiagnostics is a publish postponed to the end of a quiet period
+type heldDiagnostics struct {
+	ctx         context.Context
+	doc         Document
+	diagnostics []protocol.Diagnostic
+}
+
+func newDiagnosticsPublisher() *diagnosticsPublisher {
+	return &diagnosticsPublisher{
+		passes:    make(map[protocol.DocumentURI]context.CancelFunc),
+		published: make(map[protocol.DocumentURI][]protocol.Diagnostic),
+		held:      make(map[protocol.DocumentURI]heldDiagnostics),
+	}
+}
+
+// quiet holds what passes finishing within period compute, then publishes the last set of
+// each document at once. Right after loading, OmniSharp reports errors that go away as it
+// finishes indexing, which would otherwise flash in the editor
+func (p *diagnosticsPublisher) quiet(client protocol.Client, period time.Duration) {
+	if period <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.quietUntil = time.Now().Add(period)
+	if p.quietTimer != nil {
+		p.quietTimer.Stop()
+	}
+	p.quietTimer = time.AfterFunc(period, func() { p.endQuiet(client) })
+}
+
+// quietRemaining is how long the quiet period lasts still, if there is one
+func (p *diagnosticsPublisher) quietRemaining() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return max(time.Until(p.quietUntil), 0)
+}
+
+func (p *diagnosticsPublisher) endQuiet(client protocol.Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.quietUntil, p.quietTimer = time.Time{}, nil
+	held := p.held
+	p.held = make(map[protocol.DocumentURI]heldDiagnostics)
+	if len(held) > 0 {
+		log.Printf("diagnostics quiet period over, publishing %d documents", len(held))
+	}
+	for _, h := range held {
+		if h.ctx.Err() == nil {
+			p.send(h.ctx, client, h.doc, h.diagnostics)
+		}
+	}
+}
+
+// begin starts a pass for uri under the document's context, superseding any pass still running
+func (p *diagnosticsPublisher) begin(doc Document) context.Context {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := documentKey(doc.URI)
+	if cancel, ok := p.passes[key]; ok {
+		cancel()
+	}
+	ctx, cancel := context.WithCancel(doc.Context())
+	p.passes[key] = cancel
+	return ctx
+}
+
+// cancel stops the pass running for uri, if any, keeping the diagnostics already published
+func (p *diagnosticsPublisher) cancel(uri protocol.DocumentURI) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := documentKey(uri)
+	if cancel, ok := p.passes[key]; ok {
+		cancel()
+		delete(p.passes, key)
+	}
+}
+
+// publish sends diagnostics computed by the pass owning ctx unless it has been cancelled, or
+// holds them during a quiet period. They carry the version of doc they were computed against,
+// so clients can drop them once stale
+func (p *diagnosticsPublisher) publish(ctx context.Context, client protocol.Client, doc Document, diagnostics []protocol.Diagnostic) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if ctx.Err() != nil {
+		return
+	}
+	if time.Now().Before(p.quietUntil) {
+		p.held[documentKey(doc.URI)] = heldDiagnostics{ctx: ctx, doc: doc, diagnostics: diagnostics}
+		return
+	}
+	p.send(ctx, client, doc, diagnostics)
+}
+
+// send publishes diagnostics for doc. The caller must hold p.mu
+func (p *diagnosticsPublisher) send(ctx context.Context, client protocol.Client, doc Document, diagnostics []protocol.Diagnostic) {
+	p.published[documentKey(doc.URI)] = diagnostics
+	params := &protocol.PublishDiagnosticsParams{
+		URI:         doc.URI,
+		Diagnostics: diagnostics,
+	}
+	// Warmed documents aren't open, so the client has no version for them
+	if doc.Open {
+		params.Version = uint32(doc.Version)
+	}
+	client.PublishDiagnostics(ctx, params)
+}
+
+// clear drops cached diagnostics for a closed document and publishes an empty set so the
+// editor removes stale squiggles. The document's context must already be cancelled
+func (p *diagnosticsPublisher) clear(ctx context.Context, client protocol.Client, uri protocol.DocumentURI) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.drop(uri)
+	publishEmpty(ctx, client, uri)
+}
+
+// forget drops cached diagnostics for uri, clearing them in the editor if any were published
+func (p *diagnosticsPublisher) forget(ctx context.Context, client protocol.Client, uri protocol.DocumentURI) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.drop(uri) {
+		publishEmpty(ctx, client, uri)
+	}
+}
+
+// drop cancels the pass for uri and its cached diagnostics, reporting whether any were
+// published. The caller must hold p.mu
+func (p *diagnosticsPublisher) drop(uri protocol.DocumentURI) bool {
+	key := documentKey(uri)
+	if cancel, ok := p.passes[key]; ok {
+		cancel()
+		delete(p.passes, key)
+	}
+	delete(p.held, key)
+	published := len(p.published[key]) > 0
+	delete(p.published, key)
+	return published
+}
+
+func publishEmpty(ctx context.Context, client protocol.Client, uri protocol.DocumentURI) {
+	client.PublishDiagnostics(ctx, &protocol.PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: []protocol.Diagnostic{},
+	})
+}
+
+// scheduleDiagnostics runs a codecheck for the document in the background, once fewer than
+// diagnostics.maxConcurrent are running
+func (s *Server) scheduleDiagnostics(uri protocol.DocumentURI) {
+	// A pass for an older version is wasted even when no new one starts
+	s.diagnostics.cancel(uri)
+	omnisharp := s.backend()
+	if omnisharp == nil || (!s.currentConfig().Generated.Diagnostics && s.isGenerated(uri)) {
+		return
+	}
+
+	doc, ok := s.documents.Get(uri)
+	if !ok || isDisabledText(doc.Text) {
+		return
+	}
+
+	s.workspaceDiagnostics.schedule(s)
+
+	ctx := s.diagnostics.begin(doc)
+	s.diagnosticsQueue.submit(ctx, uri, s.currentConfig().Diagnostics.MaxConcurrent, func() {
+		diagnostics, err := s.codeCheck(ctx, omnisharp, doc)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("codecheck failed for %s: %v", uri, err)
+			}
+			return
+		}
+		diagnostics = append(diagnostics, classNameDiagnostics(doc, s.rootPath)...)
+		s.diagnostics.publish(ctx, s.client, doc, diagnostics)
+	})
+}
+
+// codeCheck computes diagnostics for doc, with ranges clamped to the text it was synced with
+func (s *Server) codeCheck(ctx context.Context, omnisharp *OmniSharpClient, doc Document) ([]protocol.Diagnostic, error) {
+	response, err := omnisharp.SendRequest(ctx, "/codecheck", map[string]interface{}{
+		"FileName": doc.URI.Filename(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var omnisharpResponse struct {
+		QuickFixes []QuickFix `json:"QuickFixes"`
+	}
+	if err := json.Unmarshal(response, &omnisharpResponse); err != nil {
+		return nil, err
+	}
+
+	lines := splitLines(doc.Text)
+	diagnostics := []protocol.Diagnostic{}
+	for _, fix := range omnisharpResponse.QuickFixes {
+		if fix.LogLevel == "Hidden" {
+			continue
+		}
+		diagnostics = append(diagnostics, quickFixDiagnostic(fix, lines))
+	}
+	return diagnostics, nil
+}
+
+// quickFixDiagnostic converts a /codecheck result, clamping it to lines
+func quickFixDiagnostic(fix QuickFix, lines []string) protocol.Diagnostic {
+	return protocol.Diagnostic{
+		Range:    quickFixToRange(fix, lines),
+		Severity: convertSeverity(fix.LogLevel),
+		Code:     fix.Id,
+		Source:   "csharp",
+		Message:  fix.Text,
+		Data:     &diagnosticData{Id: fix.Id, FileName: fix.FileName},
+	}
+}
+
+// diagnosticData is stashed in the Data of OmniSharp's diagnostics, so the code actions for a
+// diagnostic the client sends back go to the fix OmniSharp has for it, in the file it named
+type diagnosticData struct {
+	Id       string `json:"id"`
+	FileName string `json:"fileName"`
+}
+
+// decodeDiagnosticData recovers our data from a diagnostic sent back by the client.
+// Diagnostics without it, such as those of other sources, yield false
+func decodeDiagnosticData(raw interface{}) (*diagnosticData, bool) {
+	var data diagnosticData
+	if !unmarshalData(raw, &data) {
+		return nil, false
+	}
+	return &data, data.Id != "" && data.FileName != ""
+}
+
+// diagnosticCode is the OmniSharp id of a diagnostic, from its data if it has ours
+func diagnosticCode(diagnostic protocol.Diagnostic) string {
+	if data, ok := decodeDiagnosticData(diagnostic.Data); ok {
+		return data.Id
+	}
+	return fmt.Sprint(diagnostic.Code)
+}
+
+// quickFixToRange converts a diagnostic span, which may cover several lines, into a range
+// clamped to the buffer. OmniSharp can report spans ending past the last line or beyond a
+// line's length, e.g. for a missing brace at end of file, and editors reject those
+func quickFixToRange(fix QuickFix, lines []string) protocol.Range {
+	start := clampPosition(lines, protocol.Position{Line: fix.Line, Character: fix.Column})
+	end := clampPosition(lines, protocol.Position{Line: fix.EndLine, Character: fix.EndColumn})
+	if end.Line < start.Line || (end.Line == start.Line && end.Character < start.Character) {
+		end = start
+	}
+	return protocol.Range{Start: start, End: end}
+}
+
+func convertSeverity(logLevel string) protocol.DiagnosticSeverity {
+	switch logLevel {
+	case "Error":
+		return protocol.DiagnosticSeverityError
+	case "Warning":
+		return protocol.DiagnosticSeverityWarning
+	case "Info", "Information":
+		return protocol.DiagnosticSeverityInformation
+	default:
+		return protocol.DiagnosticSeverityHint
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"go.lsp.dev/protocol"
+)
+
+// diagnosticsQueue bounds how many diagnostics passes run at once, so opening many files
+// doesn't flood OmniSharp with codechecks. Queued passes start as running ones finish, the
+// focused document's first, and are dropped once superseded by a newer pass
+type diagnosticsQueue struct {
+	mu      sync.Mutex
+	running int
+	waiting []queuedPass
+	// focused is the document last opened or edited, which the user is presumably looking at
+	focused protocol.DocumentURI
+	// caseInsensitive folds the case of paths when matching passes to documents,
+	// caseInsensitivePaths unless a test says otherwise
+	caseInsensitive bool
+}
+
+// queuedPass is a diagnostics pass waiting for a free slot
+type queuedPass struct {
+	uri protocol.DocumentURI
+	ctx context.Context
+	run func()
+}
+
+func newDiagnosticsQueue() *diagnosticsQueue {
+	return &diagnosticsQueue{caseInsensitive: caseInsensitivePaths}
+}
+
+// key is the documentKey of uri under the queue's case sensitivity
+func (q *diagnosticsQueue) key(uri protocol.DocumentURI) protocol.DocumentURI {
+	return foldedDocumentKey(uri, q.caseInsensitive)
+}
+
+// focus makes uri the document whose passes start before the others
+func (q *diagnosticsQueue) focus(uri protocol.DocumentURI) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.focused = uri
+}
+
+// submit runs the pass for uri once fewer than limit passes are running, replacing any pass
+// for uri still queued, however the client spelled it. ctx is the pass's, cancelled when it
+// is superseded. A limit of zero or less runs every pass at once
+func (q *diagnosticsQueue) submit(ctx context.Context, uri protocol.DocumentURI, limit int, run func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	key := q.key(uri)
+	for i, pass := range q.waiting {
+		if q.key(pass.uri) == key {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			break
+		}
+	}
+	if limit > 0 && q.running >= limit {
+		q.waiting = append(q.waiting, queuedPass{uri: uri, ctx: ctx, run: run})
+		return
+	}
+	q.start(queuedPass{uri: uri, ctx: ctx, run: run})
+}
+
+// start runs pass, then the next queued one in its slot. The caller must hold q.mu
+func (q *diagnosticsQueue) start(pass queuedPass) {
+	q.running++
+	go func() {
+		pass.run()
+
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		q.running--
+		if next, ok := q.next(); ok {
+			q.start(next)
+		}
+	}()
+}
+
+// next removes the pass to start from the queue, skipping cancelled ones: the focused
+// document's, or else the one queued first. The caller must hold q.mu
+func (q *diagnosticsQueue) next() (queuedPass, bool) {
+	live := q.waiting[:0]
+	for _, pass := range q.waiting {
+		if pass.ctx.Err() == nil {
+			live = append(live, pass)
+		}
+	}
+	q.waiting = live
+	if len(q.waiting) == 0 {
+		return queuedPass{}, false
+	}
+
+	chosen := 0
+	focused := q.key(q.focused)
+	for i, pass := range q.waiting {
+		if q.key(pass.uri) == focused {
+			chosen = i
+			break
+		}
+	}
+	pass := q.waiting[chosen]
+	q.waiting = append(q.waiting[:chosen], q.waiting[chosen+1:]...)
+	return pass, true
+}
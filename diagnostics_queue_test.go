@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+// TestDiagnosticsQueueMatchesSpellings checks passes queued for one document under different
+// spellings of its URI replace each other, and the focused document goes first however the
+// client spelled it
+func TestDiagnosticsQueueMatchesSpellings(t *testing.T) {
+	tests := []struct {
+		name            string
+		caseInsensitive bool
+		queued, focused protocol.DocumentURI
+	}{
+		{"same spelling", false, "file:///project/Assets/Player.cs", "file:///project/Assets/Player.cs"},
+		{"percent-encoded", false, "file:///project/Assets/%50layer.cs", "file:///project/Assets/Player.cs"},
+		{"case-folded", true, "file:///project/Assets/player.cs", "file:///Project/Assets/Player.cs"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q := newDiagnosticsQueue()
+			q.caseInsensitive = test.caseInsensitive
+			var mu sync.Mutex
+			var ran []string
+			done := make(chan struct{}, 4)
+			pass := func(name string) func() {
+				return func() {
+					mu.Lock()
+					ran = append(ran, name)
+					mu.Unlock()
+					done <- struct{}{}
+				}
+			}
+			// Holds the only slot while the others queue
+			release := make(chan struct{})
+			q.submit(context.Background(), "file:///project/Assets/Busy.cs", 1, func() {
+				<-release
+				done <- struct{}{}
+			})
+			q.submit(context.Background(), "file:///project/Assets/Enemy.cs", 1, pass("Enemy"))
+			q.submit(context.Background(), test.queued, 1, pass("stale"))
+			q.submit(context.Background(), test.focused, 1, pass("Player"))
+			q.focus(test.queued)
+
+			close(release)
+			for i := 0; i < 3; i++ {
+				<-done
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if want := []string{"Player", "Enemy"}; !reflect.DeepEqual(ran, want) {
+				t.Errorf("passes ran %v, want %v", ran, want)
+			}
+		})
+	}
+}
+
+// TestDiagnosticsQueueOrder checks queued passes start one slot at a time, the focused
+// document's first, then in the order queued, skipping superseded and cancelled ones
+func TestDiagnosticsQueueOrder(t *testing.T) {
+	q := newDiagnosticsQueue()
+	started := make(chan string, 10)
+	release := make(chan struct{})
+	pass := func(name string) func() {
+		return func() {
+			started <- name
+			<-release
+		}
+	}
+	uri := func(name string) protocol.DocumentURI { return protocol.DocumentURI("file:///project/" + name + ".cs") }
+
+	q.submit(context.Background(), uri("Player"), 1, pass("Player"))
+	if got := <-started; got != "Player" {
+		t.Fatalf("started %s first", got)
+	}
+	q.submit(context.Background(), uri("Enemy"), 1, pass("Enemy (superseded)"))
+	q.submit(context.Background(), uri("Boss"), 1, pass("Boss"))
+	cancelled, cancel := context.WithCancel(context.Background())
+	q.submit(cancelled, uri("Spawner"), 1, pass("Spawner"))
+	q.submit(context.Background(), uri("Enemy"), 1, pass("Enemy"))
+	q.focus(uri("Enemy"))
+	cancel()
+
+	var got []string
+	for i := 0; i < 2; i++ {
+		release <- struct{}{}
+		got = append(got, <-started)
+	}
+	release <- struct{}{}
+	select {
+	case name := <-started:
+		t.Errorf("started %s after the queue emptied", name)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if want := []string{"Enemy", "Boss"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("started %v after Player, want %v", got, want)
+	}
+}
+
+// TestDiagnosticsMaxConcurrent checks opening several documents at once never has more than
+// diagnostics.maxConcurrent codechecks running, and checks every one of them
+func TestDiagnosticsMaxConcurrent(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int
+		wantMax int
+	}{
+		{"default", 2, 2},
+		{"one at a time", 1, 1},
+		{"unbounded", 0, 5},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{})
+			var mu sync.Mutex
+			running, max := 0, 0
+			fake.setHandler("/codecheck", func() interface{} {
+				mu.Lock()
+				running++
+				if running > max {
+					max = running
+				}
+				mu.Unlock()
+				time.Sleep(100 * time.Millisecond)
+				mu.Lock()
+				running--
+				mu.Unlock()
+				return map[string]interface{}{"QuickFixes": []QuickFix{}}
+			})
+			s, client := newTestServer(t, fake)
+			configure(s, func(config *Config) { config.Diagnostics.MaxConcurrent = test.limit })
+
+			for i := 0; i < 5; i++ {
+				openTestDocument(s, testURI(s, fmt.Sprintf("Script%d.cs", i)), "class Script { }\n")
+			}
+			waitFor(t, "every document checked", func() bool {
+				return len(client.received(protocol.MethodTextDocumentPublishDiagnostics)) >= 5
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			if max != test.wantMax {
+				t.Errorf("%d codechecks ran at once, want %d", max, test.wantMax)
+			}
+			checked := make(map[protocol.DocumentURI]bool)
+			for _, raw := range client.received(protocol.MethodTextDocumentPublishDiagnostics) {
+				var params protocol.PublishDiagnosticsParams
+				if err := json.Unmarshal(raw, &params); err != nil {
+					t.Fatal(err)
+				}
+				checked[params.URI] = true
+			}
+			if len(checked) != 5 {
+				t.Errorf("published diagnostics for %d documents, want 5", len(checked))
+			}
+		})
+	}
+}
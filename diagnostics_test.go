@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+// publishedCounts are how many diagnostics each publishDiagnostics the client got carried
+func publishedCounts(t *testing.T, client *testClient) []int {
+	t.Helper()
+	var counts []int
+	for _, raw := range client.received(protocol.MethodTextDocumentPublishDiagnostics) {
+		var params protocol.PublishDiagnosticsParams
+		if err := json.Unmarshal(raw, &params); err != nil {
+			t.Fatal(err)
+		}
+		counts = append(counts, len(params.Diagnostics))
+	}
+	return counts
+}
+
+func TestCloseDropsDiagnosticsPass(t *testing.T) {
+	const delay = 200 * time.Millisecond
+	quickFixes := map[string]interface{}{"QuickFixes": []QuickFix{
+		{Id: "CS0103", LogLevel: "Error", Line: 0, Column: 30, EndLine: 0, EndColumn: 34, Text: "The name 'Move' does not exist"},
+	}}
+	tests := []struct {
+		name       string
+		midPass    bool
+		wantCounts []int
+	}{
+		// Only the clearing empty set, never the pass's result
+		{"closed mid-pass", true, []int{0}},
+		{"closed after publishing", false, []int{1, 0}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/codecheck": quickFixes})
+			s, client := newTestServer(t, fake)
+			if test.midPass {
+				fake.setDelay(delay)
+			}
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, "class Player { void Update() { Move(); } }")
+			waitFor(t, "the diagnostics pass", func() bool { return fake.callCount("/codecheck") == 1 })
+			if !test.midPass {
+				waitFor(t, "the diagnostics", func() bool { return len(publishedCounts(t, client)) == 1 })
+			}
+
+			s.handleDidClose(context.Background(), &protocol.DidCloseTextDocumentParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			})
+			waitFor(t, "the clearing publish", func() bool { return len(publishedCounts(t, client)) == len(test.wantCounts) })
+			// Long enough for the pass to have published had it gone on
+			time.Sleep(2 * delay)
+			got := publishedCounts(t, client)
+			if len(got) != len(test.wantCounts) {
+				t.Fatalf("published %v diagnostics, want %v", got, test.wantCounts)
+			}
+			for i := range got {
+				if got[i] != test.wantCounts[i] {
+					t.Errorf("published %v diagnostics, want %v", got, test.wantCounts)
+				}
+			}
+		})
+	}
+}
+
+func TestQuickFixToRange(t *testing.T) {
+	// The second line is 20 UTF-16 units long, its é one of them
+	lines := splitLines("using UnityEngine;\nclass Plé : Object {\n}")
+	span := func(line, column, endLine, endColumn uint32) QuickFix {
+		return QuickFix{Line: line, Column: column, EndLine: endLine, EndColumn: endColumn}
+	}
+	position := func(line, character uint32) protocol.Position {
+		return protocol.Position{Line: line, Character: character}
+	}
+	tests := []struct {
+		name string
+		fix  QuickFix
+		want protocol.Range
+	}{
+		{"single line", span(1, 6, 1, 9), protocol.Range{Start: position(1, 6), End: position(1, 9)}},
+		{"multi-line", span(0, 6, 1, 9), protocol.Range{Start: position(0, 6), End: position(1, 9)}},
+		{"ending at line length", span(1, 0, 1, 20), protocol.Range{Start: position(1, 0), End: position(1, 20)}},
+		{"ending past line length", span(1, 12, 1, 40), protocol.Range{Start: position(1, 12), End: position(1, 20)}},
+		{"to end of file", span(1, 12, 2, 1), protocol.Range{Start: position(1, 12), End: position(2, 1)}},
+		{"past end of file", span(2, 0, 5, 0), protocol.Range{Start: position(2, 0), End: position(2, 1)}},
+		{"starting past end of file", span(4, 2, 4, 8), protocol.Range{Start: position(2, 1), End: position(2, 1)}},
+		{"ending before it starts", span(1, 9, 1, 6), protocol.Range{Start: position(1, 9), End: position(1, 9)}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := quickFixToRange(test.fix, lines); got != test.want {
+				t.Errorf("quickFixToRange = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// publishedVersions are the versions of the publishDiagnostics the client got for uri
+func publishedVersions(t *testing.T, client *testClient, uri protocol.DocumentURI) []uint32 {
+	t.Helper()
+	var versions []uint32
+	for _, raw := range client.received(protocol.MethodTextDocumentPublishDiagnostics) {
+		var params protocol.PublishDiagnosticsParams
+		if err := json.Unmarshal(raw, &params); err != nil {
+			t.Fatal(err)
+		}
+		if params.URI == uri {
+			versions = append(versions, params.Version)
+		}
+	}
+	return versions
+}
+
+func TestPublishedDiagnosticsVersion(t *testing.T) {
+	tests := []struct {
+		name  string
+		edits int
+		// warm has the document warmed, as for a definition target, rather than opened
+		warm bool
+		want uint32
+	}{
+		{name: "opened", want: 1},
+		{name: "edited", edits: 3, want: 4},
+		{name: "warmed", warm: true, want: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/codecheck": map[string]interface{}{
+				"QuickFixes": []QuickFix{{Id: "CS0103", LogLevel: "Error", Text: "The name 'x' does not exist"}},
+			}})
+			s, client := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			if test.warm {
+				s.documents.Warm(uri, "class Player { }")
+				s.scheduleDiagnostics(uri)
+			} else {
+				openTestDocument(s, uri, "class Player { }")
+			}
+			for i := 0; i < test.edits; i++ {
+				typeAt(s, uri, int32(i+2), protocol.Position{Character: 15}, " ")
+			}
+
+			waitFor(t, "the diagnostics of the last version", func() bool {
+				versions := publishedVersions(t, client, uri)
+				return len(versions) > 0 && versions[len(versions)-1] == test.want
+			})
+			for _, version := range publishedVersions(t, client, uri) {
+				if version > test.want {
+					t.Errorf("published version %d of a document at %d", version, test.want)
+				}
+			}
+		})
+	}
+}
+
+// TestQuietPeriodMergesPublishes checks diagnostics computed while OmniSharp settles after
+// loading are held, then published once per document, the last set of each, when it ends
+func TestQuietPeriodMergesPublishes(t *testing.T) {
+	const period = 300 * time.Millisecond
+	fake := newFakeOmniSharp(t, nil)
+	// Each pass finds one more problem, so the publish tells which pass it came from
+	fake.setHandler("/codecheck", func() interface{} {
+		quickFixes := make([]QuickFix, fake.callCount("/codecheck"))
+		for i := range quickFixes {
+			quickFixes[i] = QuickFix{Id: "CS0103", LogLevel: "Error", Text: "The name 'x' does not exist"}
+		}
+		return map[string]interface{}{"QuickFixes": quickFixes}
+	})
+	s, client := newTestServer(t, fake)
+	s.diagnostics.quiet(s.client, period)
+	start := time.Now()
+
+	player, enemy := testURI(s, "Player.cs"), testURI(s, "Enemy.cs")
+	openTestDocument(s, player, "class Player { }")
+	waitFor(t, "the first pass", func() bool { return fake.callCount("/codecheck") == 1 })
+	typeAt(s, player, 2, protocol.Position{Character: 15}, " ")
+	waitFor(t, "the second pass", func() bool { return fake.callCount("/codecheck") == 2 })
+	openTestDocument(s, enemy, "class Enemy { }")
+	waitFor(t, "the third pass", func() bool { return fake.callCount("/codecheck") == 3 })
+	if published := client.received(protocol.MethodTextDocumentPublishDiagnostics); len(published) != 0 && time.Since(start) < period {
+		t.Fatalf("published %d times during the quiet period", len(published))
+	}
+
+	waitFor(t, "the held diagnostics", func() bool { return len(publishedCounts(t, client)) == 2 })
+	// Long enough for any other publish to arrive
+	time.Sleep(100 * time.Millisecond)
+	got := make(map[protocol.DocumentURI]int)
+	for _, raw := range client.received(protocol.MethodTextDocumentPublishDiagnostics) {
+		var params protocol.PublishDiagnosticsParams
+		if err := json.Unmarshal(raw, &params); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := got[params.URI]; ok {
+			t.Errorf("published %s more than once", params.URI)
+		}
+		got[params.URI] = len(params.Diagnostics)
+	}
+	if want := map[protocol.DocumentURI]int{player: 2, enemy: 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("published %v diagnostics, want %v", got, want)
+	}
+	if elapsed := time.Since(start); elapsed < period {
+		t.Errorf("published after %v, within the quiet period", elapsed)
+	}
+}
+
+// TestDiagnosticDataRoundTrip sends a published diagnostic back as a client would, decoded from
+// and encoded to JSON, checking its data still leads to OmniSharp's fix, in the file it named
+func TestDiagnosticDataRoundTrip(t *testing.T) {
+	const fileName = "/project/Assets/Scripts/Player.cs"
+	fake := newFakeOmniSharp(t, map[string]interface{}{
+		"/codecheck": map[string]interface{}{"QuickFixes": []QuickFix{
+			{Id: "IDE0005", FileName: fileName, LogLevel: "Warning", Text: "Using directive is unnecessary."},
+		}},
+		"/getfixall": map[string]interface{}{"Items": []FixAllItem{unusedUsings}},
+	})
+	s, client := newTestServer(t, fake)
+	uri := testURI(s, "Player.cs")
+	openTestDocument(s, uri, "using System;\nclass Player { }\n")
+	waitFor(t, "the diagnostics", func() bool { return len(publishedCounts(t, client)) == 1 })
+
+	var published protocol.PublishDiagnosticsParams
+	if err := json.Unmarshal(client.received(protocol.MethodTextDocumentPublishDiagnostics)[0], &published); err != nil {
+		t.Fatal(err)
+	}
+	if len(published.Diagnostics) != 1 {
+		t.Fatalf("published %d diagnostics, want 1", len(published.Diagnostics))
+	}
+	diagnostic := published.Diagnostics[0]
+	if data, ok := decodeDiagnosticData(diagnostic.Data); !ok || *data != (diagnosticData{Id: "IDE0005", FileName: fileName}) {
+		t.Fatalf("data %v decodes to %+v, %v", diagnostic.Data, data, ok)
+	}
+
+	result, err := call(t, s, 1, protocol.MethodTextDocumentCodeAction, protocol.CodeActionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Context:      protocol.CodeActionContext{Diagnostics: published.Diagnostics},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fixes []fixAllArguments
+	for _, action := range result.([]protocol.CodeAction) {
+		if action.Command != nil && action.Command.Command == commandFixAll {
+			fixes = append(fixes, action.Command.Arguments[0].(fixAllArguments))
+		}
+	}
+	if len(fixes) != len(fixAllScopes) {
+		t.Fatalf("offered %d fix-all actions, want %d", len(fixes), len(fixAllScopes))
+	}
+	for _, fix := range fixes {
+		if fix.Id != unusedUsings.Id || fix.FileName != fileName {
+			t.Errorf("fix-all action runs %+v, want %s in %s", fix, unusedUsings.Id, fileName)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+)
+
+// disableMarker is the comment that, as the first line of a file, turns off everything but
+// sync for it, e.g. for generated or huge files OmniSharp chokes on
+var disableMarker = regexp.MustCompile(`^\s*//\s*unity-lsp:\s*disable\s*$`)
+
+// documentFeatures are the requests about a single document that disabled documents answer
+// with nothing
+var documentFeatures = map[string]bool{
+	protocol.MethodTextDocumentCompletion:        true,
+	protocol.MethodTextDocumentHover:             true,
+	protocol.MethodTextDocumentSignatureHelp:     true,
+	protocol.MethodTextDocumentDefinition:        true,
+	protocol.MethodTextDocumentReferences:        true,
+	protocol.MethodTextDocumentImplementation:    true,
+	protocol.MethodTextDocumentCodeAction:        true,
+	protocol.MethodTextDocumentRename:            true,
+	protocol.MethodTextDocumentDocumentSymbol:    true,
+	protocol.MethodTextDocumentFormatting:        true,
+	protocol.MethodTextDocumentWillSaveWaitUntil: true,
+	protocol.MethodSemanticTokensFull:            true,
+	protocol.MethodSemanticTokensRange:           true,
+	methodInlayHint:                              true,
+}
+
+// isDisabledText reports whether text starts with the disable marker
+func isDisabledText(text string) bool {
+	first, _, _ := strings.Cut(text, "\n")
+	return disableMarker.MatchString(strings.TrimSuffix(first, "\r"))
+}
+
+// isDisabled reports whether our copy of uri starts with the disable marker. Files we don't
+// track are never disabled, since the marker is read from the buffer
+func (s *Server) isDisabled(uri protocol.DocumentURI) bool {
+	doc, ok := s.documents.Get(uri)
+	return ok && isDisabledText(doc.Text)
+}
+
+// disabledRequest reports whether req is a feature request about a disabled document
+func (s *Server) disabledRequest(req jsonrpc2.Request) bool {
+	if !documentFeatures[req.Method()] {
+		return false
+	}
+	var params struct {
+		TextDocument protocol.TextDocumentIdentifier `json:"textDocument"`
+	}
+	if err := json.Unmarshal(req.Params(), &params); err != nil {
+		// Malformed params are reported by the request's own handler
+		return false
+	}
+	return s.isDisabled(params.TextDocument.URI)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestIsDisabledText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want bool
+	}{
+		{"marker", "// unity-lsp: disable\nclass Generated { }\n", true},
+		{"marker without spaces", "//unity-lsp:disable\nclass Generated { }\n", true},
+		{"indented marker, CRLF", "  // unity-lsp: disable\r\nclass Generated { }\r\n", true},
+		{"marker alone", "// unity-lsp: disable", true},
+		{"marker on the second line", "using System;\n// unity-lsp: disable\n", false},
+		{"marker with more after it", "// unity-lsp: disable diagnostics\n", false},
+		{"other comment", "// Generated by a tool\n", false},
+		{"empty", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isDisabledText(test.text); got != test.want {
+				t.Errorf("isDisabledText = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestDisabledDocument checks a document starting with the marker gets no completion or
+// hover, and isn't sent to OmniSharp, while other documents are served as usual
+func TestDisabledDocument(t *testing.T) {
+	fake := newFakeOmniSharp(t, map[string]interface{}{
+		"/autocomplete": autoCompleteItems("speed"),
+		"/typelookup":   TypeLookupResponse{Type: "float Player.speed"},
+	})
+	s, _ := newTestServer(t, fake)
+	generated, player := testURI(s, "Generated.cs"), testURI(s, "Player.cs")
+	const generatedCode, playerCode = "class Generated { float speed; void M() { s } }", "class Player { float speed; void M() { s } }"
+	openTestDocument(s, generated, "// unity-lsp: disable\n"+generatedCode+"\n")
+	openTestDocument(s, player, playerCode+"\n")
+
+	tests := []struct {
+		name string
+		uri  protocol.DocumentURI
+		// code is the line completed and hovered in
+		code string
+		line uint32
+		want bool
+	}{
+		{"disabled", generated, generatedCode, 1, false},
+		{"enabled", player, playerCode, 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			completions, hovers := fake.callCount("/autocomplete"), fake.callCount("/typelookup")
+			at := func(character int) protocol.TextDocumentPositionParams {
+				return protocol.TextDocumentPositionParams{
+					TextDocument: protocol.TextDocumentIdentifier{URI: test.uri},
+					Position:     protocol.Position{Line: test.line, Character: uint32(character)},
+				}
+			}
+
+			list, err := call(t, s, 1, protocol.MethodTextDocumentCompletion, protocol.CompletionParams{
+				TextDocumentPositionParams: at(strings.Index(test.code, "{ s ") + 3),
+				Context:                    &protocol.CompletionContext{TriggerKind: protocol.CompletionTriggerKindInvoked},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			asked := fake.callCount("/autocomplete") > completions
+			if completed := list != nil && len(list.(*CompletionList).Items) > 0; completed != test.want || asked != test.want {
+				t.Errorf("completion = %+v, asked OmniSharp %v; want served %v", list, asked, test.want)
+			}
+
+			hover, err := call(t, s, 2, protocol.MethodTextDocumentHover, protocol.HoverParams{
+				TextDocumentPositionParams: at(strings.Index(test.code, "speed") + 1),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			asked = fake.callCount("/typelookup") > hovers
+			if hovered := hover != nil && hover.(*protocol.Hover) != nil; hovered != test.want || asked != test.want {
+				t.Errorf("hover = %+v, asked OmniSharp %v; want served %v", hover, asked, test.want)
+			}
+		})
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	for _, body := range fake.bodies["/updatebuffer"] {
+		if strings.Contains(string(body), "Generated.cs") {
+			t.Errorf("the disabled document was sent to OmniSharp: %s", body)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"go.lsp.dev/protocol"
+)
+
+// Document is a text document opened by the client
+type Document struct {
+	URI     protocol.DocumentURI
+	Version int32
+	Text    string
+	// Open is false for documents the client closed that we still keep cached
+	Open bool
+
+	// ctx lives as long as the document is open or warmed, so background work tied to it stops
+	// on close
+	ctx    context.Context
+	cancel context.CancelFunc
+	// lastUsed orders documents for eviction
+	lastUsed uint64
+}
+
+// Context is cancelled when the document is closed
+func (d Document) Context() context.Context {
+	return d.ctx
+}
+
+// DocumentStore tracks the contents of open documents, and keeps recently closed ones cached
+// up to a cap. Open documents are never evicted, so the cap can be exceeded by open ones alone.
+// Documents are keyed by documentKey, so a URI cased differently finds the same one, and keep
+// the URI they were last opened with for responses
+type DocumentStore struct {
+	mu       sync.Mutex
+	docs     map[protocol.DocumentURI]*Document
+	capacity int
+	clock    uint64
+	// caseInsensitive folds the case of paths in keys, caseInsensitivePaths unless a test says
+	// otherwise
+	caseInsensitive bool
+}
+
+func NewDocumentStore(capacity int) *DocumentStore {
+	return &DocumentStore{
+		docs:            make(map[protocol.DocumentURI]*Document),
+		capacity:        capacity,
+		caseInsensitive: caseInsensitivePaths,
+	}
+}
+
+// key is the documentKey of uri under the store's case sensitivity
+func (d *DocumentStore) key(uri protocol.DocumentURI) protocol.DocumentURI {
+	return foldedDocumentKey(uri, d.caseInsensitive)
+}
+
+// SetCapacity changes the cap on tracked documents and returns the URIs evicted to meet it
+func (d *DocumentStore) SetCapacity(capacity int) []protocol.DocumentURI {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.capacity = capacity
+	return d.evict()
+}
+
+func (d *DocumentStore) Open(uri protocol.DocumentURI, version int32, text string) []protocol.DocumentURI {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := d.key(uri)
+	if doc, ok := d.docs[key]; ok {
+		doc.cancel()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.docs[key] = &Document{URI: uri, Version: version, Text: text, Open: true, ctx: ctx, cancel: cancel}
+	d.touch(d.docs[key])
+	return d.evict()
+}
+
+func (d *DocumentStore) Update(uri protocol.DocumentURI, version int32, text string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	doc, ok := d.docs[d.key(uri)]
+	if !ok {
+		return
+	}
+	doc.Version = version
+	doc.Text = text
+	d.touch(doc)
+}
+
+// Warm caches a document the client is likely to open soon, with a live context so work such as
+// diagnostics can start ahead of time. It reports false for documents already tracked
+func (d *DocumentStore) Warm(uri protocol.DocumentURI, text string) (bool, []protocol.DocumentURI) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := d.key(uri)
+	if _, ok := d.docs[key]; ok {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.docs[key] = &Document{URI: uri, Text: text, ctx: ctx, cancel: cancel}
+	d.touch(d.docs[key])
+	return true, d.evict()
+}
+
+// Close cancels the document's context and keeps its contents cached until evicted
+func (d *DocumentStore) Close(uri protocol.DocumentURI) []protocol.DocumentURI {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if doc, ok := d.docs[d.key(uri)]; ok {
+		doc.cancel()
+		doc.Open = false
+		d.touch(doc)
+	}
+	return d.evict()
+}
+
+// Get returns a snapshot of the document so callers can read it without holding the lock
+func (d *DocumentStore) Get(uri protocol.DocumentURI) (Document, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	doc, ok := d.docs[d.key(uri)]
+	if !ok {
+		return Document{}, false
+	}
+	d.touch(doc)
+	return *doc, true
+}
+
+// OpenDocuments returns snapshots of every document the client has open
+func (d *DocumentStore) OpenDocuments() []Document {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	docs := make([]Document, 0, len(d.docs))
+	for _, doc := range d.docs {
+		if doc.Open {
+			docs = append(docs, *doc)
+		}
+	}
+	return docs
+}
+
+func (d *DocumentStore) touch(doc *Document) {
+	d.clock++
+	doc.lastUsed = d.clock
+}
+
+// evict drops least recently used closed documents until the store is within capacity
+func (d *DocumentStore) evict() []protocol.DocumentURI {
+	var evicted []protocol.DocumentURI
+	for d.capacity > 0 && len(d.docs) > d.capacity {
+		var oldest *Document
+		for _, doc := range d.docs {
+			if !doc.Open && (oldest == nil || doc.lastUsed < oldest.lastUsed) {
+				oldest = doc
+			}
+		}
+		if oldest == nil {
+			break
+		}
+		oldest.cancel()
+		delete(d.docs, d.key(oldest.URI))
+		evicted = append(evicted, oldest.URI)
+	}
+	return evicted
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestDocumentStoreEviction(t *testing.T) {
+	const (
+		a = protocol.DocumentURI("file:///project/Assets/A.cs")
+		b = protocol.DocumentURI("file:///project/Assets/B.cs")
+		c = protocol.DocumentURI("file:///project/Assets/C.cs")
+		d = protocol.DocumentURI("file:///project/Assets/D.cs")
+	)
+	tests := []struct {
+		name     string
+		capacity int
+		steps    func(store *DocumentStore) []protocol.DocumentURI
+		// wantEvicted are the URIs the steps evicted, in order
+		wantEvicted []protocol.DocumentURI
+		wantTracked []protocol.DocumentURI
+	}{
+		{
+			name:     "the least recently used closed document goes first",
+			capacity: 2,
+			steps: func(store *DocumentStore) []protocol.DocumentURI {
+				store.Open(a, 1, "a")
+				store.Open(b, 1, "b")
+				store.Close(a)
+				store.Close(b)
+				return store.Open(c, 1, "c")
+			},
+			wantEvicted: []protocol.DocumentURI{a},
+			wantTracked: []protocol.DocumentURI{b, c},
+		},
+		{
+			name:     "reading a closed document keeps it",
+			capacity: 2,
+			steps: func(store *DocumentStore) []protocol.DocumentURI {
+				store.Open(a, 1, "a")
+				store.Open(b, 1, "b")
+				store.Close(a)
+				store.Close(b)
+				store.Get(a)
+				return store.Open(c, 1, "c")
+			},
+			wantEvicted: []protocol.DocumentURI{b},
+			wantTracked: []protocol.DocumentURI{a, c},
+		},
+		{
+			name:     "open documents are never evicted",
+			capacity: 2,
+			steps: func(store *DocumentStore) []protocol.DocumentURI {
+				store.Open(a, 1, "a")
+				store.Open(b, 1, "b")
+				return store.Open(c, 1, "c")
+			},
+			wantTracked: []protocol.DocumentURI{a, b, c},
+		},
+		{
+			name:     "warmed documents count as closed",
+			capacity: 2,
+			steps: func(store *DocumentStore) []protocol.DocumentURI {
+				store.Warm(a, "a")
+				store.Open(b, 1, "b")
+				_, evicted := store.Warm(c, "c")
+				return evicted
+			},
+			wantEvicted: []protocol.DocumentURI{a},
+			wantTracked: []protocol.DocumentURI{b, c},
+		},
+		{
+			name:     "lowering the capacity evicts",
+			capacity: 4,
+			steps: func(store *DocumentStore) []protocol.DocumentURI {
+				for _, uri := range []protocol.DocumentURI{a, b, c, d} {
+					store.Open(uri, 1, "")
+					store.Close(uri)
+				}
+				return store.SetCapacity(1)
+			},
+			wantEvicted: []protocol.DocumentURI{a, b, c},
+			wantTracked: []protocol.DocumentURI{d},
+		},
+		{
+			name:     "no capacity keeps everything",
+			capacity: 0,
+			steps: func(store *DocumentStore) []protocol.DocumentURI {
+				store.Open(a, 1, "a")
+				store.Close(a)
+				store.Open(b, 1, "b")
+				return store.Close(b)
+			},
+			wantTracked: []protocol.DocumentURI{a, b},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := NewDocumentStore(test.capacity)
+			evicted := test.steps(store)
+			if !reflect.DeepEqual(evicted, test.wantEvicted) {
+				t.Errorf("evicted %v, want %v", evicted, test.wantEvicted)
+			}
+			var tracked []protocol.DocumentURI
+			for _, uri := range []protocol.DocumentURI{a, b, c, d} {
+				if _, ok := store.Get(uri); ok {
+					tracked = append(tracked, uri)
+				}
+			}
+			if !reflect.DeepEqual(tracked, test.wantTracked) {
+				t.Errorf("tracking %v, want %v", tracked, test.wantTracked)
+			}
+		})
+	}
+}
+
+func TestDocumentStoreEvictionCancelsContext(t *testing.T) {
+	store := NewDocumentStore(1)
+	store.Open("file:///project/Assets/A.cs", 1, "a")
+	doc, _ := store.Get("file:///project/Assets/A.cs")
+	store.Close("file:///project/Assets/A.cs")
+	if doc.Context().Err() == nil {
+		t.Errorf("closing left the document's context live")
+	}
+	warmed, _ := store.Warm("file:///project/Assets/B.cs", "b")
+	if !warmed {
+		t.Fatalf("B wasn't warmed")
+	}
+	doc, _ = store.Get("file:///project/Assets/B.cs")
+	store.Open("file:///project/Assets/C.cs", 1, "c")
+	if doc.Context().Err() == nil {
+		t.Errorf("evicting left the warmed document's context live")
+	}
+}
+
+func TestOpenDocuments(t *testing.T) {
+	store := NewDocumentStore(0)
+	store.Open("file:///project/A.cs", 1, "")
+	store.Open("file:///project/B.cs", 1, "")
+	store.Warm("file:///project/C.cs", "")
+	store.Close("file:///project/B.cs")
+
+	var open []string
+	for _, doc := range store.OpenDocuments() {
+		open = append(open, string(doc.URI))
+	}
+	sort.Strings(open)
+	if want := []string{"file:///project/A.cs"}; !reflect.DeepEqual(open, want) {
+		t.Errorf("open documents %v, want %v", open, want)
+	}
+}
+
+func TestDocumentStoreKeysSpellings(t *testing.T) {
+	store := NewDocumentStore(0)
+	store.caseInsensitive = true
+	store.Open("file:///C%3A/Project/Assets/Player.cs", 1, "class Player {}")
+	store.Update("file:///c:/project/assets/player.cs", 2, "class Player { }")
+	doc, ok := store.Get("file:///C:/PROJECT/Assets/Player.cs")
+	if !ok || doc.Version != 2 {
+		t.Fatalf("Get under another spelling = %+v, %v", doc, ok)
+	}
+	// Responses name the document as it was opened
+	if doc.URI != "file:///C%3A/Project/Assets/Player.cs" {
+		t.Errorf("URI = %s", doc.URI)
+	}
+	if open := store.OpenDocuments(); len(open) != 1 {
+		t.Errorf("tracking %d documents, want 1", len(open))
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// CodeElement is a declaration in OmniSharp's /v2/codestructure response
+type CodeElement struct {
+	Kind        string `json:"Kind"`
+	Name        string `json:"Name"`
+	DisplayName string `json:"DisplayName"`
+	Ranges      struct {
+		Full omnisharpRange `json:"full"`
+		Name omnisharpRange `json:"name"`
+	} `json:"Ranges"`
+	Children []CodeElement `json:"Children"`
+}
+
+// symbolBatchSize is about how many symbols each partial result of an outline holds
+const symbolBatchSize = 200
+
+// handleDocumentSymbol returns the outline of a document, as hierarchical DocumentSymbols for
+// clients that render them and flat SymbolInformation for older ones. With a partial result
+// token the outline is streamed in batches instead, and the response left empty
+func (s *Server) handleDocumentSymbol(ctx context.Context, params *protocol.DocumentSymbolParams) (interface{}, error) {
+	omnisharp := s.backend()
+	if omnisharp == nil {
+		return nil, nil
+	}
+
+	response, err := omnisharp.SendRequest(ctx, "/v2/codestructure", map[string]interface{}{
+		"FileName": params.TextDocument.URI.Filename(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var omnisharpResponse struct {
+		Elements []CodeElement `json:"Elements"`
+	}
+	if err := json.Unmarshal(response, &omnisharpResponse); err != nil {
+		return nil, err
+	}
+
+	hierarchical := s.supportsHierarchicalSymbols()
+	if token := params.PartialResultToken; token != nil {
+		var batches []interface{}
+		if hierarchical {
+			batches = documentSymbolBatches(omnisharpResponse.Elements)
+		} else {
+			batches = flatSymbolBatches(params.TextDocument.URI, omnisharpResponse.Elements)
+		}
+		for _, batch := range batches {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			s.client.Progress(ctx, &protocol.ProgressParams{Token: *token, Value: batch})
+		}
+		if hierarchical {
+			return []protocol.DocumentSymbol{}, nil
+		}
+		return []protocol.SymbolInformation{}, nil
+	}
+
+	if hierarchical {
+		return documentSymbols(omnisharpResponse.Elements), nil
+	}
+	return flatSymbols(params.TextDocument.URI, "", omnisharpResponse.Elements, []protocol.SymbolInformation{}), nil
+}
+
+// documentSymbolBatches splits the outline between its top-level symbols. Partial results are
+// appended to the top level, so a symbol comes with all its children
+func documentSymbolBatches(elements []CodeElement) []interface{} {
+	var batches []interface{}
+	start, size := 0, 0
+	for i, element := range elements {
+		size += countElements(element)
+		if size >= symbolBatchSize || i == len(elements)-1 {
+			batches = append(batches, documentSymbols(elements[start:i+1]))
+			start, size = i+1, 0
+		}
+	}
+	return batches
+}
+
+func countElements(element CodeElement) int {
+	count := 1
+	for _, child := range element.Children {
+		count += countElements(child)
+	}
+	return count
+}
+
+// flatSymbolBatches streams a flat outline level by level, the top-level symbols first and
+// then their children, naming their container as flatSymbols does
+func flatSymbolBatches(uri protocol.DocumentURI, elements []CodeElement) []interface{} {
+	type contained struct {
+		element   CodeElement
+		container string
+	}
+	level := make([]contained, len(elements))
+	for i, element := range elements {
+		level[i] = contained{element: element}
+	}
+
+	var batches []interface{}
+	batch := []protocol.SymbolInformation{}
+	for len(level) > 0 {
+		var next []contained
+		for _, current := range level {
+			batch = append(batch, protocol.SymbolInformation{
+				Name:          current.element.displayName(),
+				Kind:          current.element.symbolKind(),
+				Location:      protocol.Location{URI: uri, Range: current.element.Ranges.Full.toProtocol()},
+				ContainerName: current.container,
+			})
+			if len(batch) == symbolBatchSize {
+				batches = append(batches, batch)
+				batch = []protocol.SymbolInformation{}
+			}
+			for _, child := range current.element.Children {
+				next = append(next, contained{element: child, container: current.element.displayName()})
+			}
+		}
+		// Each level starts a batch, so the top level shows before any children
+		if len(batch) > 0 {
+			batches = append(batches, batch)
+			batch = []protocol.SymbolInformation{}
+		}
+		level = next
+	}
+	return batches
+}
+
+func documentSymbols(elements []CodeElement) []protocol.DocumentSymbol {
+	symbols := make([]protocol.DocumentSymbol, len(elements))
+	for i, element := range elements {
+		symbols[i] = protocol.DocumentSymbol{
+			Name:           element.displayName(),
+			Kind:           element.symbolKind(),
+			Range:          element.Ranges.Full.toProtocol(),
+			SelectionRange: element.Ranges.Name.toProtocol(),
+		}
+		if len(element.Children) > 0 {
+			symbols[i].Children = documentSymbols(element.Children)
+		}
+	}
+	return symbols
+}
+
+// flatSymbols appends elements and their descendants to symbols, naming each one's parent as
+// its container
+func flatSymbols(uri protocol.DocumentURI, container string, elements []CodeElement, symbols []protocol.SymbolInformation) []protocol.SymbolInformation {
+	for _, element := range elements {
+		symbols = append(symbols, protocol.SymbolInformation{
+			Name:          element.displayName(),
+			Kind:          element.symbolKind(),
+			Location:      protocol.Location{URI: uri, Range: element.Ranges.Full.toProtocol()},
+			ContainerName: container,
+		})
+		symbols = flatSymbols(uri, element.displayName(), element.Children, symbols)
+	}
+	return symbols
+}
+
+// displayName includes the parameters of methods, telling overloads apart in the outline
+func (e CodeElement) displayName() string {
+	if e.DisplayName != "" {
+		return e.DisplayName
+	}
+	return e.Name
+}
+
+// symbolKind maps the camelCase kinds of /v2/codestructure onto the names /findsymbols uses
+func (e CodeElement) symbolKind() protocol.SymbolKind {
+	if e.Kind == "" {
+		return convertSymbolKind("")
+	}
+	return convertSymbolKind(strings.ToUpper(e.Kind[:1]) + e.Kind[1:])
+}
+
+func (s *Server) supportsHierarchicalSymbols() bool {
+	textDocument := s.capabilities.TextDocument
+	if textDocument == nil || textDocument.DocumentSymbol == nil {
+		return false
+	}
+	return textDocument.DocumentSymbol.HierarchicalDocumentSymbolSupport
+}
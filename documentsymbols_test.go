@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+// codeStructure is a /v2/codestructure response of a class with a field and two overloads
+const codeStructure = `{"Elements": [{
+	"Kind": "class", "Name": "Player", "DisplayName": "Player",
+	"Ranges": {"full": {"Start": {"Line": 0, "Column": 0}, "End": {"Line": 5, "Column": 1}}, "name": {"Start": {"Line": 0, "Column": 6}, "End": {"Line": 0, "Column": 12}}},
+	"Children": [
+		{"Kind": "field", "Name": "speed", "DisplayName": "speed",
+			"Ranges": {"full": {"Start": {"Line": 2, "Column": 4}, "End": {"Line": 2, "Column": 16}}, "name": {"Start": {"Line": 2, "Column": 10}, "End": {"Line": 2, "Column": 15}}}},
+		{"Kind": "method", "Name": "Move", "DisplayName": "Move(float)",
+			"Ranges": {"full": {"Start": {"Line": 3, "Column": 4}, "End": {"Line": 3, "Column": 28}}, "name": {"Start": {"Line": 3, "Column": 9}, "End": {"Line": 3, "Column": 13}}}},
+		{"Kind": "method", "Name": "Move", "DisplayName": "Move(Vector3)",
+			"Ranges": {"full": {"Start": {"Line": 4, "Column": 4}, "End": {"Line": 4, "Column": 30}}, "name": {"Start": {"Line": 4, "Column": 9}, "End": {"Line": 4, "Column": 13}}}}
+	]
+}]}`
+
+func testRange(startLine, startCharacter, endLine, endCharacter uint32) protocol.Range {
+	return protocol.Range{
+		Start: protocol.Position{Line: startLine, Character: startCharacter},
+		End:   protocol.Position{Line: endLine, Character: endCharacter},
+	}
+}
+
+func TestDocumentSymbolForms(t *testing.T) {
+	tests := []struct {
+		name         string
+		capabilities protocol.ClientCapabilities
+		want         interface{}
+	}{
+		{
+			name: "hierarchical",
+			capabilities: protocol.ClientCapabilities{TextDocument: &protocol.TextDocumentClientCapabilities{
+				DocumentSymbol: &protocol.DocumentSymbolClientCapabilities{HierarchicalDocumentSymbolSupport: true},
+			}},
+			want: []protocol.DocumentSymbol{{
+				Name: "Player", Kind: protocol.SymbolKindClass, Range: testRange(0, 0, 5, 1), SelectionRange: testRange(0, 6, 0, 12),
+				Children: []protocol.DocumentSymbol{
+					{Name: "speed", Kind: protocol.SymbolKindField, Range: testRange(2, 4, 2, 16), SelectionRange: testRange(2, 10, 2, 15)},
+					{Name: "Move(float)", Kind: protocol.SymbolKindMethod, Range: testRange(3, 4, 3, 28), SelectionRange: testRange(3, 9, 3, 13)},
+					{Name: "Move(Vector3)", Kind: protocol.SymbolKindMethod, Range: testRange(4, 4, 4, 30), SelectionRange: testRange(4, 9, 4, 13)},
+				},
+			}},
+		},
+		{
+			name: "flat without the capability",
+			capabilities: protocol.ClientCapabilities{TextDocument: &protocol.TextDocumentClientCapabilities{
+				DocumentSymbol: &protocol.DocumentSymbolClientCapabilities{},
+			}},
+			want: "flat",
+		},
+		{name: "flat without document symbol capabilities", want: "flat"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var structure interface{}
+			if err := json.Unmarshal([]byte(codeStructure), &structure); err != nil {
+				t.Fatal(err)
+			}
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/v2/codestructure": structure})
+			s, _ := newTestServer(t, fake)
+			s.capabilities = test.capabilities
+			uri := testURI(s, "Player.cs")
+			want := test.want
+			if want == "flat" {
+				want = []protocol.SymbolInformation{
+					{Name: "Player", Kind: protocol.SymbolKindClass, Location: protocol.Location{URI: uri, Range: testRange(0, 0, 5, 1)}},
+					{Name: "speed", Kind: protocol.SymbolKindField, Location: protocol.Location{URI: uri, Range: testRange(2, 4, 2, 16)}, ContainerName: "Player"},
+					{Name: "Move(float)", Kind: protocol.SymbolKindMethod, Location: protocol.Location{URI: uri, Range: testRange(3, 4, 3, 28)}, ContainerName: "Player"},
+					{Name: "Move(Vector3)", Kind: protocol.SymbolKindMethod, Location: protocol.Location{URI: uri, Range: testRange(4, 4, 4, 30)}, ContainerName: "Player"},
+				}
+			}
+
+			symbols, err := s.handleDocumentSymbol(context.Background(), &protocol.DocumentSymbolParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(symbols, want) {
+				t.Errorf("symbols = %+v, want %+v", symbols, want)
+			}
+		})
+	}
+}
+
+// TestDocumentSymbolPartialResults checks an outline asked for with a partial result token
+// streams in batches, the top-level symbols first, and the response is left empty
+func TestDocumentSymbolPartialResults(t *testing.T) {
+	// Three classes of 150 fields each
+	var elements []CodeElement
+	for _, class := range []string{"Player", "Enemy", "Boss"} {
+		element := CodeElement{Kind: "class", Name: class, DisplayName: class}
+		for i := 0; i < 150; i++ {
+			name := fmt.Sprintf("field%d", i)
+			element.Children = append(element.Children, CodeElement{Kind: "field", Name: name, DisplayName: name})
+		}
+		elements = append(elements, element)
+	}
+	tests := []struct {
+		name         string
+		hierarchical bool
+		// wantBatches are the symbols in each batch, counting children for hierarchical ones
+		wantBatches []int
+		// wantFirst are the names at the top of the first batch
+		wantFirst []string
+	}{
+		{"hierarchical", true, []int{302, 151}, []string{"Player", "Enemy"}},
+		{"flat", false, []int{3, 200, 200, 50}, []string{"Player", "Enemy", "Boss"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/v2/codestructure": map[string]interface{}{"Elements": elements}})
+			s, client := newTestServer(t, fake)
+			s.capabilities = protocol.ClientCapabilities{TextDocument: &protocol.TextDocumentClientCapabilities{
+				DocumentSymbol: &protocol.DocumentSymbolClientCapabilities{HierarchicalDocumentSymbolSupport: test.hierarchical},
+			}}
+
+			symbols, err := s.handleDocumentSymbol(context.Background(), &protocol.DocumentSymbolParams{
+				TextDocument:        protocol.TextDocumentIdentifier{URI: testURI(s, "Player.cs")},
+				PartialResultParams: protocol.PartialResultParams{PartialResultToken: protocol.NewProgressToken("outline")},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reflect.ValueOf(symbols).Len() != 0 {
+				t.Errorf("responded %+v, want it empty", symbols)
+			}
+			waitFor(t, "every batch", func() bool { return len(client.received(protocol.MethodProgress)) >= len(test.wantBatches) })
+
+			var sizes []int
+			var first []string
+			for _, raw := range client.received(protocol.MethodProgress) {
+				var progress struct {
+					Token string          `json:"token"`
+					Value json.RawMessage `json:"value"`
+				}
+				if err := json.Unmarshal(raw, &progress); err != nil {
+					t.Fatal(err)
+				}
+				if progress.Token != "outline" {
+					t.Errorf("progress reported to %q", progress.Token)
+				}
+				// Flat symbols decode as DocumentSymbols without children
+				var batch []protocol.DocumentSymbol
+				if err := json.Unmarshal(progress.Value, &batch); err != nil {
+					t.Fatal(err)
+				}
+				size := 0
+				for _, symbol := range batch {
+					size += 1 + len(symbol.Children)
+					if len(sizes) == 0 {
+						first = append(first, symbol.Name)
+					}
+				}
+				sizes = append(sizes, size)
+			}
+			if !reflect.DeepEqual(sizes, test.wantBatches) {
+				t.Errorf("batches of %v symbols, want %v", sizes, test.wantBatches)
+			}
+			if !reflect.DeepEqual(first, test.wantFirst) {
+				t.Errorf("first batch %v, want %v", first, test.wantFirst)
+			}
+		})
+	}
+}
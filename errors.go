@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+)
+
+// errorClass is how a request to OmniSharp failed, which decides the feedback the user gets
+type errorClass int
+
+const (
+	errorOther errorClass = iota
+	// errorTimeout means OmniSharp didn't answer within omnisharp.requestTimeout, usually because
+	// it is busy or still loading
+	errorTimeout
+	// errorTransport means OmniSharp couldn't be reached at all
+	errorTransport
+	// errorBackend means OmniSharp answered with an error status
+	errorBackend
+)
+
+// OmniSharpError is a failed OmniSharp request
+type OmniSharpError struct {
+	Class    errorClass
+	Endpoint string
+	Err      error
+}
+
+func (e *OmniSharpError) Error() string {
+	return fmt.Sprintf("OmniSharp %s: %v", e.Endpoint, e.Err)
+}
+
+func (e *OmniSharpError) Unwrap() error {
+	return e.Err
+}
+
+// errContentModified means a document changed while a request read it, so its result is stale
+var errContentModified = errors.New("the document changed while the request was served")
+
+// lspError builds an error replied to the client, whose code tells it how to react
+func lspError(code jsonrpc2.Code, message string) error {
+	return jsonrpc2.NewError(code, message)
+}
+
+func invalidParams(err error) error {
+	return lspError(jsonrpc2.InvalidParams, fmt.Sprintf("invalid params: %v", err))
+}
+
+// withErrorCodes gives the errors handlers reply with a JSON-RPC code. Clients silently drop
+// requests failing with RequestCancelled or ContentModified, and show the rest. The reply
+// is written even if ctx was cancelled, since the client waits for it regardless
+func withErrorCodes(reply jsonrpc2.Replier) jsonrpc2.Replier {
+	return func(ctx context.Context, result interface{}, err error) error {
+		var wireErr *jsonrpc2.Error
+		switch {
+		case err == nil, errors.As(err, &wireErr):
+		case errors.Is(err, errContentModified):
+			err = lspError(protocol.CodeContentModified, err.Error())
+		case errors.Is(err, context.Canceled):
+			err = lspError(protocol.CodeRequestCancelled, err.Error())
+		default:
+			err = lspError(jsonrpc2.InternalError, err.Error())
+		}
+		return reply(context.WithoutCancel(ctx), result, err)
+	}
+}
+
+func classifyError(err error) errorClass {
+	var omnisharpErr *OmniSharpError
+	if errors.As(err, &omnisharpErr) {
+		return omnisharpErr.Class
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errorTimeout
+	}
+	return errorOther
+}
+
+// userFacing reports a failed user-initiated action. Timeouts and an unreachable OmniSharp
+// are explained in a message and the request answered empty, since the editor's own error
+// display wouldn't say what to do; other errors are returned to the client as they are
+func (s *Server) userFacing(ctx context.Context, action string, err error) error {
+	if err == nil {
+		return nil
+	}
+	log.Printf("%s failed: %v", action, err)
+
+	var message string
+	switch classifyError(err) {
+	case errorTimeout:
+		message = fmt.Sprintf(s.localize("Unity LSP: %s timed out. OmniSharp may be busy or still loading the solution; try again shortly."), s.localize(action))
+	case errorTransport:
+		message = fmt.Sprintf(s.localize("Unity LSP: %s failed because OmniSharp could not be reached."), s.localize(action))
+	default:
+		return err
+	}
+	s.client.ShowMessage(ctx, &protocol.ShowMessageParams{
+		Type:    protocol.MessageTypeWarning,
+		Message: message,
+	})
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+)
+
+func TestWithErrorCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode jsonrpc2.Code
+	}{
+		{"no error", nil, 0},
+		{"an error with a code keeps it", invalidParams(errors.New("bad")), jsonrpc2.InvalidParams},
+		{"content modified", errContentModified, protocol.CodeContentModified},
+		{"content modified wrapped", fmt.Errorf("hover: %w", errContentModified), protocol.CodeContentModified},
+		{"cancelled", context.Canceled, protocol.CodeRequestCancelled},
+		{"cancelled OmniSharp request", &OmniSharpError{Class: errorOther, Endpoint: "/typelookup", Err: context.Canceled}, protocol.CodeRequestCancelled},
+		{"timed out", &OmniSharpError{Class: errorTimeout, Endpoint: "/typelookup", Err: context.DeadlineExceeded}, jsonrpc2.InternalError},
+		{"anything else", errors.New("boom"), jsonrpc2.InternalError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var replied error
+			var replyCtx context.Context
+			reply := withErrorCodes(func(ctx context.Context, result interface{}, err error) error {
+				replyCtx, replied = ctx, err
+				return nil
+			})
+			// Replies to cancelled requests are written all the same
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			reply(ctx, nil, test.err)
+
+			if replyCtx.Err() != nil {
+				t.Errorf("replied on a cancelled context")
+			}
+			if test.wantCode == 0 {
+				if replied != nil {
+					t.Errorf("replied %v, want no error", replied)
+				}
+				return
+			}
+			var wireErr *jsonrpc2.Error
+			if !errors.As(replied, &wireErr) || wireErr.Code != test.wantCode {
+				t.Errorf("replied %#v, want code %d", replied, test.wantCode)
+			}
+		})
+	}
+}
+
+func TestUserFacing(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantErr     bool
+		wantMessage bool
+	}{
+		{"success", nil, false, false},
+		{"timeout", &OmniSharpError{Class: errorTimeout, Endpoint: "/rename", Err: context.DeadlineExceeded}, false, true},
+		{"deadline of our own", fmt.Errorf("rename: %w", context.DeadlineExceeded), false, true},
+		{"unreachable", &OmniSharpError{Class: errorTransport, Endpoint: "/rename", Err: errors.New("connection refused")}, false, true},
+		{"OmniSharp failing", &OmniSharpError{Class: errorBackend, Endpoint: "/rename", Err: errors.New("500")}, true, false},
+		{"anything else", errors.New("boom"), true, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, client := newTestServer(t, nil)
+			err := s.userFacing(context.Background(), "Rename", test.err)
+			if (err != nil) != test.wantErr {
+				t.Errorf("userFacing = %v, want an error %v", err, test.wantErr)
+			}
+			if test.wantMessage {
+				waitFor(t, "the message explaining it", func() bool { return len(client.received(protocol.MethodWindowShowMessage)) == 1 })
+			} else if messages := client.received(protocol.MethodWindowShowMessage); len(messages) != 0 {
+				t.Errorf("showed %s", messages[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// omnisharpArgs passes the formatting settings to OmniSharp, which only reads them at startup
+// and takes none with format requests
+func (c FormattingConfig) omnisharpArgs() []string {
+	return []string{
+		"FormattingOptions:IndentationSize=" + strconv.Itoa(c.IndentationSize),
+		"FormattingOptions:TabSize=" + strconv.Itoa(c.IndentationSize),
+		"FormattingOptions:UseTabs=" + strconv.FormatBool(c.UseTabs),
+		"FormattingOptions:NewLine=" + c.NewLine,
+		"FormattingOptions:SpaceAfterControlFlowStatementKeyword=" + strconv.FormatBool(c.SpaceAfterKeyword),
+		"FormattingOptions:OrganizeImports=" + strconv.FormatBool(c.OrganizeImportsOnFormat),
+	}
+}
+
+// handleFormatting formats the whole document with OmniSharp's rules, then re-indents the
+// result to the editor's indentation, which wins over formatting.indentationSize and useTabs
+func (s *Server) handleFormatting(ctx context.Context, params *protocol.DocumentFormattingParams) ([]protocol.TextEdit, error) {
+	omnisharp := s.backend()
+	if omnisharp == nil {
+		return nil, nil
+	}
+	doc, ok := s.documents.Get(params.TextDocument.URI)
+	if !ok {
+		return nil, nil
+	}
+
+	response, err := omnisharp.SendRequest(ctx, "/codeformat", map[string]interface{}{
+		"FileName":         doc.URI.Filename(),
+		"WantsTextChanges": false,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var omnisharpResponse struct {
+		Buffer string `json:"Buffer"`
+	}
+	if err := json.Unmarshal(response, &omnisharpResponse); err != nil {
+		return nil, err
+	}
+
+	formatted := omnisharpResponse.Buffer
+	if !params.Options.InsertSpaces {
+		formatted = reindent(formatted, s.currentConfig().Formatting, "\t")
+	} else if params.Options.TabSize > 0 {
+		formatted = reindent(formatted, s.currentConfig().Formatting, strings.Repeat(" ", int(params.Options.TabSize)))
+	}
+	if formatted == doc.Text {
+		return []protocol.TextEdit{}, nil
+	}
+
+	// Editors reduce a whole-document edit to the lines that actually changed
+	lines := splitLines(doc.Text)
+	return []protocol.TextEdit{{
+		Range: protocol.Range{
+			End: clampPosition(lines, protocol.Position{Line: uint32(len(lines))}),
+		},
+		NewText: formatted,
+	}}, nil
+}
+
+// reindent replaces each level of the indentation OmniSharp formatted text with, as set by
+// config, with indent. Lines continuing a verbatim or raw string literal are left alone since
+// their whitespace is part of the string
+func reindent(text string, config FormattingConfig, indent string) string {
+	unit := strings.Repeat(" ", config.IndentationSize)
+	if config.UseTabs {
+		unit = "\t"
+	}
+	if unit == indent || config.IndentationSize <= 0 {
+		return text
+	}
+
+	lines := strings.Split(text, "\n")
+	inString := multilineStringLines(lines)
+	for i, line := range lines {
+		if inString[i] {
+			continue
+		}
+		body := strings.TrimLeft(line, " \t")
+		leading := line[:len(line)-len(body)]
+		columns := 0
+		for _, c := range leading {
+			if c == '\t' {
+				columns += config.IndentationSize
+			} else {
+				columns++
+			}
+		}
+		levels, rest := columns/config.IndentationSize, columns%config.IndentationSize
+		lines[i] = strings.Repeat(indent, levels) + strings.Repeat(" ", rest) + body
+	}
+	return strings.Join(lines, "\n")
+}
+
+// multilineStringLines reports for each line whether it starts inside a verbatim (@"...") or
+// raw ("""...""") string literal begun on an earlier line
+func multilineStringLines(lines []string) []bool {
+	inside := make([]bool, len(lines))
+	verbatim, comment := false, false
+	rawQuotes := 0
+	for i, line := range lines {
+		inside[i] = verbatim || rawQuotes > 0
+		for j := 0; j < len(line); {
+			rest := line[j:]
+			switch {
+			case comment:
+				if strings.HasPrefix(rest, "*/") {
+					comment = false
+					j += 2
+				} else {
+					j++
+				}
+			case verbatim:
+				if strings.HasPrefix(rest, `""`) {
+					j += 2
+					continue
+				}
+				verbatim = rest[0] != '"'
+				j++
+			case rawQuotes > 0:
+				if strings.HasPrefix(rest, strings.Repeat(`"`, rawQuotes)) {
+					j += rawQuotes
+					rawQuotes = 0
+				} else {
+					j++
+				}
+			case strings.HasPrefix(rest, "//"):
+				j = len(line)
+			case strings.HasPrefix(rest, "/*"):
+				comment = true
+				j += 2
+			case strings.HasPrefix(rest, `"""`):
+				rawQuotes = len(rest) - len(strings.TrimLeft(rest, `"`))
+				j += rawQuotes
+			case rest[0] == '@' || rest[0] == '$':
+				prefix := len(rest) - len(strings.TrimLeft(rest, "@$"))
+				if strings.Contains(rest[:prefix], "@") && strings.HasPrefix(rest[prefix:], `"`) {
+					verbatim = true
+					j++
+				}
+				j += prefix
+			case rest[0] == '"' || rest[0] == '\'':
+				// Regular string and character literals end on their line
+				j++
+				for j < len(line) && line[j] != rest[0] {
+					if line[j] == '\\' {
+						j++
+					}
+					j++
+				}
+				j++
+			default:
+				j++
+			}
+		}
+	}
+	return inside
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestFormattingOmniSharpArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		config FormattingConfig
+		want   []string
+	}{
+		{
+			name:   "defaults",
+			config: DefaultConfig().Formatting,
+			want: []string{
+				"FormattingOptions:IndentationSize=4",
+				"FormattingOptions:TabSize=4",
+				"FormattingOptions:UseTabs=false",
+				"FormattingOptions:NewLine=\n",
+				"FormattingOptions:SpaceAfterControlFlowStatementKeyword=true",
+				"FormattingOptions:OrganizeImports=false",
+			},
+		},
+		{
+			name:   "tabs, CRLF, no space, organized",
+			config: FormattingConfig{IndentationSize: 8, UseTabs: true, NewLine: "\r\n", OrganizeImportsOnFormat: true},
+			want: []string{
+				"FormattingOptions:IndentationSize=8",
+				"FormattingOptions:TabSize=8",
+				"FormattingOptions:UseTabs=true",
+				"FormattingOptions:NewLine=\r\n",
+				"FormattingOptions:SpaceAfterControlFlowStatementKeyword=false",
+				"FormattingOptions:OrganizeImports=true",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.config.omnisharpArgs(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("args = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestFormattingEditorIndentWins checks OmniSharp's formatting, done with the configured
+// indentation, is re-indented to the editor's whenever the editor sends one
+func TestFormattingEditorIndentWins(t *testing.T) {
+	const formatted = "class Player\n{\n    void Start()\n    {\n        if (ready) { }\n    }\n}\n"
+	tests := []struct {
+		name    string
+		config  FormattingConfig
+		options protocol.FormattingOptions
+		want    string
+	}{
+		{
+			name:    "editor uses tabs",
+			config:  FormattingConfig{IndentationSize: 4},
+			options: protocol.FormattingOptions{TabSize: 4},
+			want:    "class Player\n{\n\tvoid Start()\n\t{\n\t\tif (ready) { }\n\t}\n}\n",
+		},
+		{
+			name:    "editor uses two spaces",
+			config:  FormattingConfig{IndentationSize: 4},
+			options: protocol.FormattingOptions{TabSize: 2, InsertSpaces: true},
+			want:    "class Player\n{\n  void Start()\n  {\n    if (ready) { }\n  }\n}\n",
+		},
+		{
+			name:    "editor agrees with the config",
+			config:  FormattingConfig{IndentationSize: 4},
+			options: protocol.FormattingOptions{TabSize: 4, InsertSpaces: true},
+			want:    formatted,
+		},
+		{
+			name:    "editor sends no tab size",
+			config:  FormattingConfig{IndentationSize: 4},
+			options: protocol.FormattingOptions{InsertSpaces: true},
+			want:    formatted,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/codeformat": map[string]string{"Buffer": formatted}})
+			s, _ := newTestServer(t, fake)
+			configure(s, func(config *Config) { config.Formatting = test.config })
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, "class Player\n{\nvoid Start()\n{\nif(ready){}\n}\n}\n")
+
+			edits, err := s.handleFormatting(context.Background(), &protocol.DocumentFormattingParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Options:      test.options,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(edits) != 1 || edits[0].NewText != test.want {
+				t.Errorf("edits = %+v, want the document replaced with %q", edits, test.want)
+			}
+		})
+	}
+}
+
+func TestReindent(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		config FormattingConfig
+		indent string
+		want   string
+	}{
+		{"spaces to tabs", "{\n    a;\n        b;\n}", FormattingConfig{IndentationSize: 4}, "\t", "{\n\ta;\n\t\tb;\n}"},
+		{"tabs to spaces", "{\n\ta;\n\t\tb;\n}", FormattingConfig{IndentationSize: 4, UseTabs: true}, "  ", "{\n  a;\n    b;\n}"},
+		{"alignment beyond a level kept", "f(a,\n      b);", FormattingConfig{IndentationSize: 4}, "\t", "f(a,\n\t  b);"},
+		{"same indentation", "{\n    a;\n}", FormattingConfig{IndentationSize: 4}, "    ", "{\n    a;\n}"},
+		{
+			"verbatim string left alone",
+			"{\n    s = @\"one\n    two\";\n    t;\n}", FormattingConfig{IndentationSize: 4}, "\t",
+			"{\n\ts = @\"one\n    two\";\n\tt;\n}",
+		},
+		{
+			"raw string left alone",
+			"{\n    s = \"\"\"\n        raw\n        \"\"\";\n}", FormattingConfig{IndentationSize: 4}, "\t",
+			"{\n\ts = \"\"\"\n        raw\n        \"\"\";\n}",
+		},
+		{
+			"quote in a comment doesn't start a string",
+			"{\n    // say \"hi\n    a;\n}", FormattingConfig{IndentationSize: 4}, "\t",
+			"{\n\t// say \"hi\n\ta;\n}",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := reindent(test.text, test.config, test.indent); got != test.want {
+				t.Errorf("reindent = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// isGenerated reports whether uri matches generated.patterns, relative to the workspace root
+func (s *Server) isGenerated(uri protocol.DocumentURI) bool {
+	name := filepath.ToSlash(uri.Filename())
+	if rel, err := filepath.Rel(s.rootPath, uri.Filename()); err == nil && !strings.HasPrefix(rel, "..") {
+		name = filepath.ToSlash(rel)
+	}
+
+	for _, pattern := range s.currentConfig().Generated.Patterns {
+		if matchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a slash-separated name against pattern, where ** stands for any number of
+// path segments and the rest follows path.Match. Case is ignored, as Unity projects move
+// between case-insensitive file systems
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(strings.ToLower(pattern), "/"), strings.Split(strings.ToLower(strings.TrimPrefix(name, "/")), "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := len(name); i >= 0; i-- {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"**/obj/**", "Assembly-CSharp/obj/Debug/Game.AssemblyInfo.cs", true},
+		{"**/obj/**", "obj/Game.cs", true},
+		{"**/obj/**", "Assets/Objects/Crate.cs", false},
+		{"Temp/**", "Temp/Bee/Generated.cs", true},
+		{"Temp/**", "Assets/Temp/Player.cs", false},
+		{"**/*.g.cs", "Assets/Input/Controls.g.cs", true},
+		{"**/*.g.cs", "Controls.g.cs", true},
+		{"**/*.g.cs", "Assets/Input/Controls.cs", false},
+		{"**/*.designer.cs", "Assets/UI/Menu.Designer.cs", true},
+		{"Library/**", "/Library/PackageCache/Tool.cs", true},
+		{"Assets/*.cs", "Assets/Scripts/Player.cs", false},
+		{"[", "[", false},
+	}
+	for _, test := range tests {
+		t.Run(test.pattern+" "+test.name, func(t *testing.T) {
+			if got := matchGlob(test.pattern, test.name); got != test.want {
+				t.Errorf("matchGlob = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestGeneratedFilesSkipDiagnostics opens a generated file, whose diagnostics pass is
+// short-circuited unless generated.diagnostics is set, then a script, which gets its pass
+func TestGeneratedFilesSkipDiagnostics(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		include   bool
+		wantCheck bool
+	}{
+		{"under obj", "Assembly-CSharp/obj/Debug/Game.AssemblyInfo.cs", false, false},
+		{"generated source", "Assets/Input/Controls.g.cs", false, false},
+		{"included", "Assembly-CSharp/obj/Debug/Game.AssemblyInfo.cs", true, true},
+		{"script", "Assets/Scripts/Enemy.cs", false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{})
+			s, _ := newTestServer(t, fake)
+			configure(s, func(config *Config) { config.Generated.Diagnostics = test.include })
+			uri := testURI(s, test.file)
+			openTestDocument(s, uri, "class Generated { }")
+			script := testURI(s, "Assets/Player.cs")
+			openTestDocument(s, script, "class Player { }")
+
+			waitFor(t, "the script's diagnostics", func() bool { return fake.callCount("/codecheck") >= 1 })
+			time.Sleep(50 * time.Millisecond)
+			checked := false
+			fake.mu.Lock()
+			for _, body := range fake.bodies["/codecheck"] {
+				var request struct{ FileName string }
+				json.Unmarshal(body, &request)
+				checked = checked || request.FileName == uri.Filename()
+			}
+			fake.mu.Unlock()
+			if checked != test.wantCheck {
+				t.Errorf("ran a codecheck for %s: %v, want %v", test.file, checked, test.wantCheck)
+			}
+		})
+	}
+}
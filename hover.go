@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"go.lsp.dev/protocol"
+)
+
+// TypeLookupResponse is OmniSharp's /typelookup response
+type TypeLookupResponse struct {
+	Type          string `json:"Type"`
+	Documentation string `json:"Documentation"`
+}
+
+func (s *Server) handleHover(ctx context.Context, params *protocol.HoverParams) (*protocol.Hover, error) {
+	omnisharp := s.backend()
+	if omnisharp == nil || !s.atIdentifier(params.TextDocument.URI, params.Position) || !s.inCode(params.TextDocument.URI, params.Position) {
+		return nil, nil
+	}
+
+	uri := params.TextDocument.URI
+	if cached, ok := s.cache.get("hover", uri, params.Position); ok {
+		markCacheHit(ctx)
+		return cached.(*protocol.Hover), nil
+	}
+	generation := s.cache.generation(uri)
+
+	request := omnisharpPosition(params.TextDocument.URI, params.Position)
+	request["IncludeDocumentation"] = true
+	response, err := omnisharp.SendRequest(ctx, "/typelookup", request)
+	if err != nil {
+		return nil, err
+	}
+
+	var omnisharpResponse TypeLookupResponse
+	if err := json.Unmarshal(response, &omnisharpResponse); err != nil {
+		return nil, err
+	}
+	if omnisharpResponse.Type == "" {
+		s.cache.put("hover", uri, generation, params.Position, (*protocol.Hover)(nil))
+		return nil, nil
+	}
+
+	// Types show where they sit in the hierarchy, members the type declaring them
+	value := "```csharp\n" + s.withBaseTypes(ctx, omnisharp, uri, params.Position, omnisharpResponse.Type) + "\n```"
+	doc, tracked := s.documents.Get(params.TextDocument.URI)
+	var word protocol.Range
+	if tracked {
+		_, word = wordRanges(doc.Text, params.Position)
+		name := doc.Text[offsetAt(doc.Text, word.Start):offsetAt(doc.Text, word.End)]
+		if declaring := hoverDeclaringType(omnisharpResponse.Type, name); declaring != "" {
+			value += "\n\nDeclared in `" + declaring + "`"
+		}
+	}
+	if omnisharpResponse.Documentation != "" {
+		value += "\n\n" + omnisharpResponse.Documentation
+	}
+	hover := &protocol.Hover{
+		Contents: protocol.MarkupContent{Kind: protocol.Markdown, Value: value},
+	}
+	if tracked {
+		hover.Range = &word
+	}
+	s.cache.put("hover", uri, generation, params.Position, hover)
+	return hover, nil
+}
+
+// atIdentifier reports whether pos touches an identifier or keyword, the only tokens
+// OmniSharp can say anything about. Positions in documents we don't track are assumed to
+// touch one
+func (s *Server) atIdentifier(uri protocol.DocumentURI, pos protocol.Position) bool {
+	doc, ok := s.documents.Get(uri)
+	if !ok {
+		return true
+	}
+	_, word := wordRanges(doc.Text, pos)
+	return word.Start != word.End
+}
+
+// inCode reports whether the word at pos is code rather than part of a comment, a string
+// literal or a number, where OmniSharp describes at best the enclosing type. Words inside the
+// holes of interpolated strings are code
+func (s *Server) inCode(uri protocol.DocumentURI, pos protocol.Position) bool {
+	doc, ok := s.documents.Get(uri)
+	if !ok {
+		return true
+	}
+	word, _ := wordRanges(doc.Text, pos)
+	return tokenClassAt(doc.Text, offsetAt(doc.Text, word.Start)) == tokenCode
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// typeDescription matches the /typelookup description of a type, such as "class Game.Player"
+var typeDescription = regexp.MustCompile(`^(?:[a-z]+\s+)*?(?:class|struct|interface|record|enum)\b`)
+
+// hoverDeclaringType returns the type declaring the member named word that description is of,
+// or "" for types, whose description is qualified by their namespace or enclosing type
+func hoverDeclaringType(description, word string) string {
+	if word == "" || typeDescription.MatchString(description) {
+		return ""
+	}
+	return declaringType(description, word)
+}
+
+// withBaseTypes appends the base type and interfaces of the type at pos to its description,
+// as in "class Player : MonoBehaviour, IDamageable", read from its declaration. Types declared
+// in metadata, or without a base list, keep the description as it is
+func (s *Server) withBaseTypes(ctx context.Context, omnisharp *OmniSharpClient, uri protocol.DocumentURI, pos protocol.Position, description string) string {
+	if !typeDescription.MatchString(description) {
+		return description
+	}
+
+	omnisharpResponse, err := omnisharp.goToDefinition(ctx, uri, pos)
+	if err != nil {
+		log.Printf("failed to find the declaration of %s: %v", description, err)
+		return description
+	}
+	if len(omnisharpResponse.Definitions) == 0 {
+		return description
+	}
+	location := omnisharpResponse.Definitions[0].Location
+	if location.FileName == "" {
+		return description
+	}
+
+	declaration := pathToURI(s.resolveOmniSharpPath(location.FileName))
+	var text string
+	if doc, ok := s.documents.Get(declaration); ok {
+		text = doc.Text
+	} else if data, err := os.ReadFile(declaration.Filename()); err == nil {
+		text = string(data)
+	} else {
+		return description
+	}
+
+	if bases := baseList(text, offsetAt(text, location.Range.toProtocol().Start)); bases != "" {
+		return description + " : " + bases
+	}
+	return description
+}
+
+// baseList returns the base list of the type declared with its name at offset, skipping type
+// parameters and a record's parameters, up to any constraints
+func baseList(text string, offset int) string {
+	rest := text[offset:]
+	rest = strings.TrimLeft(rest[len(identifierAfter(rest)):], " \t\r\n")
+	for _, brackets := range []string{"<>", "()"} {
+		if strings.HasPrefix(rest, brackets[:1]) {
+			end := closingBracket(rest, brackets[0], brackets[1])
+			if end < 0 {
+				return ""
+			}
+			rest = strings.TrimLeft(rest[end+1:], " \t\r\n")
+		}
+	}
+
+	rest, ok := strings.CutPrefix(rest, ":")
+	if !ok {
+		return ""
+	}
+	if end := strings.IndexAny(rest, "{;"); end >= 0 {
+		rest = rest[:end]
+	}
+	if where := constraintClause.FindStringIndex(rest); where != nil {
+		rest = rest[:where[0]]
+	}
+	return strings.Join(strings.Fields(rest), " ")
+}
+
+// constraintClause matches the start of the constraints following a base list
+var constraintClause = regexp.MustCompile(`\bwhere\b`)
+
+// closingBracket returns the offset of the bracket closing the one text starts with, or -1
+func closingBracket(text string, open, close byte) int {
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
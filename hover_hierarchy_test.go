@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestBaseList(t *testing.T) {
+	tests := []struct {
+		name string
+		// text has | before the type's name
+		text string
+		want string
+	}{
+		{"base and interfaces", "class |Player : MonoBehaviour, IDamageable { }", "MonoBehaviour, IDamageable"},
+		{"across lines", "class |Player\n    : MonoBehaviour,\n      IDamageable\n{ }", "MonoBehaviour, IDamageable"},
+		{"generic type", "class |Pool<T> : ObjectPool<T> where T : Component { }", "ObjectPool<T>"},
+		{"generic base", "class |Inventory : List<Dictionary<string, int>> { }", "List<Dictionary<string, int>>"},
+		{"record parameters", "record |Hit(int Damage) : Event;", "Event"},
+		{"no base list", "class |Player { }", ""},
+		{"constraints only", "class |Pool<T> where T : Component { }", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			offset := strings.Index(test.text, "|")
+			text := strings.Replace(test.text, "|", "", 1)
+			if got := baseList(text, offset); got != test.want {
+				t.Errorf("baseList = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestHoverDeclaringType(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		word        string
+		want        string
+	}{
+		{"field", "float Game.Player.speed", "speed", "Player"},
+		{"method", "void Game.Enemy.TakeDamage(int amount)", "TakeDamage", "Enemy"},
+		{"generic type's member", "T Game.Pool<T>.Spawn()", "Spawn", "Pool"},
+		{"type", "class Game.Player", "Player", ""},
+		{"nested type", "struct Game.Player.Stats", "Stats", ""},
+		{"local", "float speed", "speed", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := hoverDeclaringType(test.description, test.word); got != test.want {
+				t.Errorf("hoverDeclaringType = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestHoverHierarchy checks a type's hover shows its base type and interfaces from its
+// declaration, and a member's hover the type declaring it
+func TestHoverHierarchy(t *testing.T) {
+	const text = "class Player : MonoBehaviour, IDamageable {\n    float speed;\n}\n"
+	tests := []struct {
+		name        string
+		description string
+		line        uint32
+		at          string
+		// declaredIn is the file OmniSharp declares the type in, empty for metadata
+		declaredIn string
+		want       string
+	}{
+		{"type", "class Game.Player", 0, "Player", "Player.cs", "```csharp\nclass Game.Player : MonoBehaviour, IDamageable\n```"},
+		{"type in metadata", "class UnityEngine.MonoBehaviour", 0, "MonoBehaviour", "", "```csharp\nclass UnityEngine.MonoBehaviour\n```"},
+		{"member", "float Game.Player.speed", 1, "speed", "", "```csharp\nfloat Game.Player.speed\n```\n\nDeclared in `Player`"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/typelookup": TypeLookupResponse{Type: test.description}})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, text)
+			var file string
+			if test.declaredIn != "" {
+				file = testURI(s, test.declaredIn).Filename()
+			}
+			// Player is declared at columns 6 to 12 of the first line
+			fake.setResponse("/v2/gotodefinition", map[string]interface{}{"Definitions": []interface{}{map[string]interface{}{"Location": map[string]interface{}{
+				"FileName": file,
+				"Range": map[string]interface{}{
+					"Start": map[string]interface{}{"Line": 0, "Column": 6},
+					"End":   map[string]interface{}{"Line": 0, "Column": 12},
+				},
+			}}}})
+			line := strings.Split(text, "\n")[test.line]
+
+			hover, err := s.handleHover(context.Background(), &protocol.HoverParams{TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Position:     protocol.Position{Line: test.line, Character: uint32(strings.Index(line, test.at) + 1)},
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hover == nil {
+				t.Fatal("no hover")
+			}
+			if got := hover.Contents.Value; got != test.want {
+				t.Errorf("hover = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+// TestIdentifierOnlyRequests checks hover, definition and references only ask OmniSharp about
+// identifiers and keywords, and hover only about those in code
+func TestIdentifierOnlyRequests(t *testing.T) {
+	const text = `class Player { float speed = 1f + 2f;  string tag = "Enemy"; }`
+	tests := []struct {
+		name string
+		// at is the text the position is at the start of, plus offset
+		at         string
+		offset     int
+		wantHover  bool
+		wantLookup bool
+	}{
+		{"identifier", "speed", 0, true, true},
+		{"end of an identifier", "speed", 5, true, true},
+		{"keyword", "float", 1, true, true},
+		{"whitespace", "  string", 1, false, false},
+		{"operator", "+ 2f", 0, false, false},
+		{"string literal", "Enemy", 1, false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{
+				"/typelookup": TypeLookupResponse{Type: "float Player.speed"},
+			})
+			s, _ := newTestServer(t, fake)
+			configure(s, func(config *Config) { config.Navigation.SymbolSearchFallback = false })
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, text)
+			position := protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Position:     protocol.Position{Character: uint32(strings.Index(text, test.at) + test.offset)},
+			}
+
+			hover, err := s.handleHover(context.Background(), &protocol.HoverParams{TextDocumentPositionParams: position})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (hover != nil) != test.wantHover || (fake.callCount("/typelookup") > 0) != test.wantHover {
+				t.Errorf("hover = %+v after %d lookups, want one: %v", hover, fake.callCount("/typelookup"), test.wantHover)
+			}
+			if _, err := s.handleDefinition(context.Background(), &protocol.DefinitionParams{TextDocumentPositionParams: position}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.handleReferences(context.Background(), &ReferenceParams{ReferenceParams: protocol.ReferenceParams{TextDocumentPositionParams: position}}); err != nil {
+				t.Fatal(err)
+			}
+			for _, endpoint := range []string{"/v2/gotodefinition", "/findusages"} {
+				if asked := fake.callCount(endpoint) > 0; asked != test.wantLookup {
+					t.Errorf("asked %s: %v, want %v", endpoint, asked, test.wantLookup)
+				}
+			}
+		})
+	}
+}
+
+// TestHoverOutsideCode checks hover is left unanswered in comments, strings and numbers, but
+// served for code in the holes of interpolated strings
+func TestHoverOutsideCode(t *testing.T) {
+	const text = "class Player {\n" +
+		"    // speed is in units\n" +
+		"    float speed = 2.5f;\n" +
+		"    string tag = \"speed\";\n" +
+		"    string Label() => $\"speed {speed} units\";\n" +
+		"}\n"
+	tests := []struct {
+		name string
+		line uint32
+		// at is the text the position is at the start of, plus one
+		at   string
+		want bool
+	}{
+		{"comment", 1, "speed", false},
+		{"field", 2, "speed", true},
+		{"number", 2, "5f", false},
+		{"plain string", 3, "speed", false},
+		{"interpolated text", 4, "speed {", false},
+		{"interpolation hole", 4, "speed}", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{
+				"/typelookup": TypeLookupResponse{Type: "float Player.speed"},
+			})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, text)
+			line := strings.Split(text, "\n")[test.line]
+
+			hover, err := s.handleHover(context.Background(), &protocol.HoverParams{TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Position:     protocol.Position{Line: test.line, Character: uint32(strings.Index(line, test.at) + 1)},
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if asked := fake.callCount("/typelookup") > 0; (hover != nil) != test.want || asked != test.want {
+				t.Errorf("hover = %+v, asked OmniSharp %v; want served %v", hover, asked, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"go.lsp.dev/protocol"
+)
+
+// methodInlayHint and methodInlayHintResolve are from LSP 3.17, which go.lsp.dev/protocol
+// v0.12.0 predates
+const (
+	methodInlayHint        = "textDocument/inlayHint"
+	methodInlayHintResolve = "inlayHint/resolve"
+)
+
+// InlayHintOptions mirrors the 3.17 options of textDocument/inlayHint
+type InlayHintOptions struct {
+	ResolveProvider bool `json:"resolveProvider,omitempty"`
+}
+
+// InlayHintParams asks for the hints of a range of a document
+type InlayHintParams struct {
+	TextDocument protocol.TextDocumentIdentifier `json:"textDocument"`
+	Range        protocol.Range                  `json:"range"`
+}
+
+// InlayHint is a 3.17 inlay hint, whose tooltip and edits are left out until resolved
+type InlayHint struct {
+	Position     protocol.Position       `json:"position"`
+	Label        string                  `json:"label"`
+	Kind         int                     `json:"kind,omitempty"`
+	TextEdits    []protocol.TextEdit     `json:"textEdits,omitempty"`
+	Tooltip      *protocol.MarkupContent `json:"tooltip,omitempty"`
+	PaddingLeft  bool                    `json:"paddingLeft,omitempty"`
+	PaddingRight bool                    `json:"paddingRight,omitempty"`
+	Data         *inlayHintData          `json:"data,omitempty"`
+}
+
+// inlayHintData identifies a hint for resolve: the document version it was computed for, and
+// OmniSharp's own data, which its resolve needs back
+type inlayHintData struct {
+	URI       protocol.DocumentURI `json:"uri"`
+	Version   int32                `json:"version"`
+	OmniSharp json.RawMessage      `json:"omnisharp"`
+}
+
+// OmniSharpInlayHint is a hint of OmniSharp's /inlayHint response, or its /inlayHint/resolve
+// response with the tooltip and edits filled in
+type OmniSharpInlayHint struct {
+	Position     OmniSharpPoint               `json:"Position"`
+	Label        string                       `json:"Label"`
+	Tooltip      string                       `json:"Tooltip,omitempty"`
+	Kind         int                          `json:"Kind,omitempty"`
+	TextEdits    []LinePositionSpanTextChange `json:"TextEdits,omitempty"`
+	PaddingLeft  bool                         `json:"PaddingLeft,omitempty"`
+	PaddingRight bool                         `json:"PaddingRight,omitempty"`
+	Data         json.RawMessage              `json:"Data"`
+}
+
+// OmniSharpPoint is a position in OmniSharp's format
+type OmniSharpPoint struct {
+	Line   uint32 `json:"Line"`
+	Column uint32 `json:"Column"`
+}
+
+// handleInlayHint lists the parameter name and type hints OmniSharp has for a range. Their
+// tooltips and edits, which take OmniSharp longer, are left to inlayHint/resolve
+func (s *Server) handleInlayHint(ctx context.Context, params *InlayHintParams) ([]InlayHint, error) {
+	omnisharp := s.backend()
+	doc, ok := s.documents.Get(params.TextDocument.URI)
+	if omnisharp == nil || !ok {
+		return nil, nil
+	}
+
+	response, err := omnisharp.SendRequest(ctx, "/inlayHint", map[string]interface{}{
+		"Location": map[string]interface{}{
+			"FileName": params.TextDocument.URI.Filename(),
+			"Range": map[string]interface{}{
+				"Start": OmniSharpPoint{Line: params.Range.Start.Line, Column: params.Range.Start.Character},
+				"End":   OmniSharpPoint{Line: params.Range.End.Line, Column: params.Range.End.Character},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var omnisharpResponse struct {
+		InlayHints []OmniSharpInlayHint `json:"InlayHints"`
+	}
+	if err := json.Unmarshal(response, &omnisharpResponse); err != nil {
+		return nil, err
+	}
+
+	hints := make([]InlayHint, len(omnisharpResponse.InlayHints))
+	for i, hint := range omnisharpResponse.InlayHints {
+		hints[i] = InlayHint{
+			Position:     protocol.Position{Line: hint.Position.Line, Character: hint.Position.Column},
+			Label:        hint.Label,
+			Kind:         hint.Kind,
+			PaddingLeft:  hint.PaddingLeft,
+			PaddingRight: hint.PaddingRight,
+			Data:         &inlayHintData{URI: doc.URI, Version: doc.Version, OmniSharp: hint.Data},
+		}
+	}
+	return hints, nil
+}
+
+// handleInlayHintResolve fills in the tooltip and edits of a hint. Hovering over hints resolves
+// them again and again, so resolved hints are kept until their document changes
+func (s *Server) handleInlayHintResolve(ctx context.Context, hint *InlayHint) (*InlayHint, error) {
+	if hint.Data == nil {
+		return hint, nil
+	}
+	key := resolvedHintKey{uri: documentKey(hint.Data.URI), version: hint.Data.Version, position: hint.Position}
+	if resolved, ok := s.resolvedHints.get(key); ok {
+		return &resolved, nil
+	}
+	omnisharp := s.backend()
+	if omnisharp == nil {
+		return hint, nil
+	}
+
+	response, err := omnisharp.SendRequest(ctx, "/inlayHint/resolve", map[string]interface{}{
+		"Hint": OmniSharpInlayHint{
+			Position: OmniSharpPoint{Line: hint.Position.Line, Column: hint.Position.Character},
+			Label:    hint.Label,
+			Kind:     hint.Kind,
+			Data:     hint.Data.OmniSharp,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var omnisharpHint OmniSharpInlayHint
+	if err := json.Unmarshal(response, &omnisharpHint); err != nil {
+		return nil, err
+	}
+
+	resolved := *hint
+	if omnisharpHint.Tooltip != "" {
+		resolved.Tooltip = &protocol.MarkupContent{Kind: protocol.Markdown, Value: omnisharpHint.Tooltip}
+	}
+	for _, change := range omnisharpHint.TextEdits {
+		resolved.TextEdits = append(resolved.TextEdits, protocol.TextEdit{
+			Range: protocol.Range{
+				Start: protocol.Position{Line: change.StartLine, Character: change.StartColumn},
+				End:   protocol.Position{Line: change.EndLine, Character: change.EndColumn},
+			},
+			NewText: change.NewText,
+		})
+	}
+	// A hint of a version since changed is resolved but not kept
+	if doc, ok := s.documents.Get(hint.Data.URI); ok && doc.Version == hint.Data.Version {
+		s.resolvedHints.put(key, resolved)
+	}
+	return &resolved, nil
+}
+
+// resolvedHintKey identifies a hint by where it is in a version of its document
+type resolvedHintKey struct {
+	uri      protocol.DocumentURI
+	version  int32
+	position protocol.Position
+}
+
+// resolvedHints remembers resolved hints until their document changes
+type resolvedHints struct {
+	mu    sync.Mutex
+	hints map[resolvedHintKey]InlayHint
+}
+
+func newResolvedHints() *resolvedHints {
+	return &resolvedHints{hints: make(map[resolvedHintKey]InlayHint)}
+}
+
+func (r *resolvedHints) get(key resolvedHintKey) (InlayHint, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hint, ok := r.hints[key]
+	return hint, ok
+}
+
+func (r *resolvedHints) put(key resolvedHintKey, hint InlayHint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hints[key] = hint
+}
+
+// forget drops the hints resolved for uri, whose positions a change moves
+func (r *resolvedHints) forget(uri protocol.DocumentURI) {
+	uri = documentKey(uri)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key := range r.hints {
+		if key.uri == uri {
+			delete(r.hints, key)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestInlayHintProviderResolves(t *testing.T) {
+	var capabilities ServerCapabilities
+	addSolutionCapabilities(&capabilities)
+	encoded, err := json.Marshal(capabilities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(encoded), `"inlayHintProvider":{"resolveProvider":true}`) {
+		t.Errorf("capabilities don't advertise resolving inlay hints: %s", encoded)
+	}
+}
+
+func TestInlayHintResolve(t *testing.T) {
+	fake := newFakeOmniSharp(t, map[string]interface{}{
+		"/inlayHint": map[string]interface{}{"InlayHints": []OmniSharpInlayHint{
+			{Position: OmniSharpPoint{Line: 0, Column: 38}, Label: "speed: ", Kind: 2, Data: json.RawMessage(`{"Item1":"1","Item2":7}`)},
+		}},
+		"/inlayHint/resolve": OmniSharpInlayHint{
+			Position: OmniSharpPoint{Line: 0, Column: 38},
+			Label:    "speed: ",
+			Tooltip:  "`float speed`",
+			TextEdits: []LinePositionSpanTextChange{
+				{NewText: "speed: ", StartLine: 0, StartColumn: 38, EndLine: 0, EndColumn: 38},
+			},
+		},
+	})
+	s, _ := newTestServer(t, fake)
+	uri := testURI(s, "Player.cs")
+	openTestDocument(s, uri, "class Player { void Start() { Move(1f); } }\n")
+
+	hints, err := s.handleInlayHint(context.Background(), &InlayHintParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Range:        protocol.Range{End: protocol.Position{Line: 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hints) != 1 || hints[0].Tooltip != nil {
+		t.Fatalf("hints = %+v, want one left unresolved", hints)
+	}
+	// What the client sends back is what it was sent
+	resolve := func() *InlayHint {
+		t.Helper()
+		encoded, err := json.Marshal(hints[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		var hint InlayHint
+		if err := json.Unmarshal(encoded, &hint); err != nil {
+			t.Fatal(err)
+		}
+		resolved, err := s.handleInlayHintResolve(context.Background(), &hint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resolved
+	}
+
+	resolved := resolve()
+	if resolved.Tooltip == nil || resolved.Tooltip.Value != "`float speed`" {
+		t.Errorf("tooltip = %+v", resolved.Tooltip)
+	}
+	if len(resolved.TextEdits) != 1 || resolved.TextEdits[0].NewText != "speed: " || resolved.TextEdits[0].Range.Start.Character != 38 {
+		t.Errorf("text edits = %+v", resolved.TextEdits)
+	}
+	var request struct {
+		Hint struct {
+			Data json.RawMessage
+		}
+	}
+	if err := json.Unmarshal(fake.bodies["/inlayHint/resolve"][0], &request); err != nil {
+		t.Fatal(err)
+	}
+	if string(request.Hint.Data) != `{"Item1":"1","Item2":7}` {
+		t.Errorf("OmniSharp got its data back as %s", request.Hint.Data)
+	}
+
+	if again := resolve(); again.Tooltip == nil || fake.callCount("/inlayHint/resolve") != 1 {
+		t.Errorf("resolving again asked OmniSharp %d times in all, want once", fake.callCount("/inlayHint/resolve"))
+	}
+
+	typeAt(s, uri, 2, protocol.Position{Line: 1}, "\n")
+	resolve()
+	if calls := fake.callCount("/inlayHint/resolve"); calls != 2 {
+		t.Errorf("after a change resolving asked OmniSharp %d times in all, want twice", calls)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+// instanceLock is written to .unity-lsp/server.lock by the server owning the workspace's
+// OmniSharp, so a second server started for the same workspace, usually by a misconfigured
+// editor, shares it instead of starting another
+type instanceLock struct {
+	PID int `json:"pid"`
+	// OmniSharp is the URL of the owner's OmniSharp, set once it is ready
+	OmniSharp string `json:"omnisharp,omitempty"`
+}
+
+func lockPath(root string) string {
+	return filepath.Join(root, ".unity-lsp", "server.lock")
+}
+
+func readLock(root string) (instanceLock, bool) {
+	var lock instanceLock
+	data, err := os.ReadFile(lockPath(root))
+	if err != nil || json.Unmarshal(data, &lock) != nil || lock.PID == 0 {
+		return lock, false
+	}
+	return lock, true
+}
+
+// claimLock makes this process the owner of the workspace unless a live process already is,
+// whose lock is returned. Locks left by processes that died are taken over
+func claimLock(root string) (instanceLock, bool, error) {
+	path := lockPath(root)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return instanceLock{}, false, err
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			defer file.Close()
+			data, _ := json.Marshal(instanceLock{PID: os.Getpid()})
+			_, err = file.Write(data)
+			return instanceLock{}, err == nil, err
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return instanceLock{}, false, err
+		}
+
+		lock, ok := readLock(root)
+		if ok && lock.PID == os.Getpid() {
+			return lock, true, nil
+		}
+		if ok && processAlive(lock.PID) {
+			return lock, false, nil
+		}
+		log.Printf("removing the lock of a server that is no longer running: %s", path)
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return instanceLock{}, false, err
+		}
+	}
+	return instanceLock{}, false, fmt.Errorf("another server keeps claiming %s", path)
+}
+
+// publishLock records the URL of our OmniSharp in the lock we own
+func publishLock(root, omnisharp string) error {
+	if lock, ok := readLock(root); !ok || lock.PID != os.Getpid() {
+		return errors.New("the workspace lock is not ours")
+	}
+	data, err := json.Marshal(instanceLock{PID: os.Getpid(), OmniSharp: omnisharp})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(lockPath(root), data, 0o644)
+}
+
+// releaseLock removes the lock if we own it
+func releaseLock(root string) {
+	if lock, ok := readLock(root); ok && lock.PID == os.Getpid() {
+		os.Remove(lockPath(root))
+	}
+}
+
+// removeStaleLock removes the lock of a server whose OmniSharp can't be used, unless another
+// server claimed the workspace in the meantime
+func removeStaleLock(root string, stale instanceLock) error {
+	if lock, ok := readLock(root); !ok || lock != stale {
+		return nil
+	}
+	log.Printf("removing the lock of server %d, whose OmniSharp is unavailable: %s", stale.PID, lockPath(root))
+	if err := os.Remove(lockPath(root)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// claimWorkspace reports whether this server should start OmniSharp. If another server owns
+// the workspace, its OmniSharp is used instead, once ready; a lock that can't be read or
+// written doesn't stop us from starting our own
+func (s *Server) claimWorkspace(ctx context.Context) bool {
+	warned := false
+	for {
+		lock, ours, err := claimLock(s.rootPath)
+		if err != nil {
+			log.Printf("failed to lock the workspace, starting OmniSharp regardless: %v", err)
+			return true
+		}
+		if ours {
+			return true
+		}
+
+		if !warned {
+			warned = true
+			log.Printf("server %d already runs for %s, sharing its OmniSharp", lock.PID, s.rootPath)
+			s.client.ShowMessage(ctx, &protocol.ShowMessageParams{
+				Type:    protocol.MessageTypeWarning,
+				Message: fmt.Sprintf(s.localize("Unity LSP: another server (process %d) is already running for this workspace, so its OmniSharp is shared. Running several usually means the editor starts the server twice."), lock.PID),
+			})
+		}
+		attached, err := s.attachToPrimary(ctx)
+		if err != nil {
+			log.Printf("failed to take over the workspace lock, starting OmniSharp regardless: %v", err)
+			return true
+		}
+		if attached {
+			return false
+		}
+		// The other server went away, or its OmniSharp did, before it could be used, so take over
+	}
+}
+
+// attachToPrimary waits for the OmniSharp of the server owning the workspace and uses it. It
+// reports false if that server went away first, leaving the workspace to claim. So does an
+// owner whose OmniSharp doesn't answer, or never publishes one within omnisharp.startupTimeout,
+// as when its PID now belongs to another process; its lock is removed
+func (s *Server) attachToPrimary(ctx context.Context) (bool, error) {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(time.Duration(s.currentConfig().OmniSharp.StartupTimeout))
+
+	for {
+		lock, ok := readLock(s.rootPath)
+		if !ok || !processAlive(lock.PID) {
+			return false, nil
+		}
+		if lock.OmniSharp != "" {
+			client := NewOmniSharpClient(lock.OmniSharp, time.Duration(s.currentConfig().OmniSharp.RequestTimeout), s.currentConfig().OmniSharp.Compression)
+			ready, err := client.readyStatus(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("the OmniSharp of server %d at %s doesn't answer: %v", lock.PID, lock.OmniSharp, err)
+				return false, removeStaleLock(s.rootPath, lock)
+			}
+			if ready {
+				s.mu.Lock()
+				s.state = backendReady
+				s.omnisharp = client
+				s.mu.Unlock()
+				log.Printf("using the OmniSharp of server %d at %s", lock.PID, lock.OmniSharp)
+
+				s.resyncDocuments(ctx)
+				s.workspaceDiagnostics.schedule(s)
+				s.notifyReady(ctx, client)
+				go s.watchPrimary(ctx, lock, client)
+				return true, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return true, nil
+		case <-timeout:
+			if lock.OmniSharp == "" {
+				log.Printf("server %d published no OmniSharp within %s", lock.PID, time.Duration(s.currentConfig().OmniSharp.StartupTimeout))
+				return false, removeStaleLock(s.rootPath, lock)
+			}
+			s.enterDegraded(ctx, fmt.Sprintf(s.localize("the OmniSharp of server %d did not become ready"), lock.PID))
+			return true, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// primaryCheckInterval is how often a server sharing another's OmniSharp checks it is still
+// there
+const primaryCheckInterval = time.Second
+
+// watchPrimary claims the workspace again once the OmniSharp of the server owning it, which
+// client talks to, goes away: when that server's lock disappears or changes, or its OmniSharp
+// stops answering, as it does when the owner exits and stops it. It returns once ctx is done
+// or OmniSharp is replaced otherwise, as by a reload
+func (s *Server) watchPrimary(ctx context.Context, owner instanceLock, client *OmniSharpClient) {
+	ticker := time.NewTicker(primaryCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		current := s.omnisharp
+		s.mu.Unlock()
+		if current != client {
+			return
+		}
+		lock, ok := readLock(s.rootPath)
+		if ok && lock == owner {
+			if _, err := client.readyStatus(ctx); err == nil || ctx.Err() != nil {
+				continue
+			}
+		}
+
+		s.mu.Lock()
+		if s.omnisharp != client {
+			s.mu.Unlock()
+			return
+		}
+		s.state = backendStarting
+		s.omnisharp = nil
+		s.mu.Unlock()
+		log.Printf("the OmniSharp of server %d went away, claiming the workspace again", owner.PID)
+		s.startOmniSharp(ctx)
+		return
+	}
+}
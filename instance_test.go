@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+// exitedPID returns the PID of a process that has exited
+func exitedPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+func writeLock(t *testing.T, root, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(lockPath(root)), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(lockPath(root), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClaimLock(t *testing.T) {
+	live := os.Getppid()
+	tests := []struct {
+		name string
+		// lock is the lock file's content before claiming, none if empty
+		lock     func(t *testing.T) string
+		wantOurs bool
+		// wantOwner is the PID the lock holds afterwards
+		wantOwner int
+	}{
+		{name: "no lock", wantOurs: true, wantOwner: os.Getpid()},
+		{
+			name:      "stale lock",
+			lock:      func(t *testing.T) string { return `{"pid": ` + strconv.Itoa(exitedPID(t)) + `}` },
+			wantOurs:  true,
+			wantOwner: os.Getpid(),
+		},
+		{name: "unreadable lock", lock: func(t *testing.T) string { return "not json" }, wantOurs: true, wantOwner: os.Getpid()},
+		{name: "our lock", lock: func(t *testing.T) string { return `{"pid": ` + strconv.Itoa(os.Getpid()) + `}` }, wantOurs: true, wantOwner: os.Getpid()},
+		{name: "live lock", lock: func(t *testing.T) string { return `{"pid": ` + strconv.Itoa(live) + `}` }, wantOwner: live},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			if test.lock != nil {
+				writeLock(t, root, test.lock(t))
+			}
+
+			lock, ours, err := claimLock(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ours != test.wantOurs || !ours && lock.PID != live {
+				t.Errorf("claimLock = %+v, %v; want ours %v", lock, ours, test.wantOurs)
+			}
+			if owner, _ := readLock(root); owner.PID != test.wantOwner {
+				t.Errorf("lock owned by %d, want %d", owner.PID, test.wantOwner)
+			}
+		})
+	}
+}
+
+// TestPublishAndReleaseLock checks only the owner records its OmniSharp in the lock and
+// removes it
+func TestPublishAndReleaseLock(t *testing.T) {
+	tests := []struct {
+		name      string
+		owner     int
+		wantOwned bool
+	}{
+		{"ours", os.Getpid(), true},
+		{"another server's", os.Getppid(), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeLock(t, root, `{"pid": `+strconv.Itoa(test.owner)+`}`)
+
+			err := publishLock(root, "http://localhost:2000")
+			if lock, _ := readLock(root); (err == nil) != test.wantOwned || (lock.OmniSharp != "") != test.wantOwned {
+				t.Errorf("publishLock = %v, lock %+v; want published %v", err, lock, test.wantOwned)
+			}
+			releaseLock(root)
+			if _, err := os.Stat(lockPath(root)); os.IsNotExist(err) != test.wantOwned {
+				t.Errorf("lock removed: %v, want %v", os.IsNotExist(err), test.wantOwned)
+			}
+		})
+	}
+}
+
+// TestSecondaryServer checks a server started for a workspace another live server owns warns,
+// and uses that server's OmniSharp rather than starting its own
+func TestSecondaryServer(t *testing.T) {
+	tests := []struct {
+		name      string
+		ready     bool
+		wantState backendState
+	}{
+		{"primary ready", true, backendReady},
+		{"primary never ready", false, backendDegraded},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/checkreadystatus": map[string]bool{"Ready": test.ready}})
+			s, client := newTestServer(t, nil)
+			configure(s, func(config *Config) { config.OmniSharp.StartupTimeout = Duration(100 * time.Millisecond) })
+			if err := os.WriteFile(filepath.Join(s.rootPath, "Game.sln"), nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			primary := os.Getppid()
+			lock := `{"pid": ` + strconv.Itoa(primary) + `, "omnisharp": "` + fake.URL + `"}`
+			writeLock(t, s.rootPath, lock)
+
+			s.startOmniSharp(context.Background())
+			s.mu.Lock()
+			state, omnisharp := s.state, s.omnisharp
+			s.mu.Unlock()
+			if state != test.wantState {
+				t.Fatalf("state = %d, want %d", state, test.wantState)
+			}
+			if test.ready && (omnisharp == nil || omnisharp.baseURL != fake.URL) {
+				t.Errorf("using OmniSharp %+v, want the primary's at %s", omnisharp, fake.URL)
+			}
+			if data, _ := os.ReadFile(lockPath(s.rootPath)); string(data) != lock {
+				t.Errorf("lock = %s, want the primary's left as it was", data)
+			}
+
+			waitFor(t, "the warning", func() bool { return len(client.received(protocol.MethodWindowShowMessage)) > 0 })
+			var message protocol.ShowMessageParams
+			json.Unmarshal(client.received(protocol.MethodWindowShowMessage)[0], &message)
+			if message.Type != protocol.MessageTypeWarning || !strings.Contains(message.Message, strconv.Itoa(primary)) {
+				t.Errorf("showed %+v", message)
+			}
+		})
+	}
+}
+
+// TestSecondaryTakesOver checks a server finding the workspace locked by a live process whose
+// OmniSharp can't be used, as when the PID was reused, takes the lock and runs its own
+func TestSecondaryTakesOver(t *testing.T) {
+	dead := newFakeOmniSharp(t, nil)
+	dead.Close()
+	tests := []struct {
+		name      string
+		omnisharp string
+	}{
+		{"OmniSharp not answering", dead.URL},
+		{"no OmniSharp published", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			own := newFakeOmniSharp(t, map[string]interface{}{"/checkreadystatus": map[string]bool{"Ready": true}})
+			s, _ := newTestServer(t, nil)
+			configure(s, func(config *Config) {
+				config.OmniSharp.Address = own.URL
+				config.OmniSharp.StartupTimeout = Duration(100 * time.Millisecond)
+			})
+			if err := os.WriteFile(filepath.Join(s.rootPath, "Game.sln"), nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			writeLock(t, s.rootPath, `{"pid": `+strconv.Itoa(os.Getppid())+`, "omnisharp": "`+test.omnisharp+`"}`)
+
+			s.startOmniSharp(context.Background())
+			s.mu.Lock()
+			state, omnisharp := s.state, s.omnisharp
+			s.mu.Unlock()
+			if state != backendReady || omnisharp == nil || omnisharp.baseURL != own.URL {
+				t.Errorf("state %d using %+v, want ready with our own OmniSharp at %s", state, omnisharp, own.URL)
+			}
+			if lock, _ := readLock(s.rootPath); lock.PID != os.Getpid() || lock.OmniSharp != own.URL {
+				t.Errorf("lock = %+v, want ours with our OmniSharp", lock)
+			}
+		})
+	}
+}
+
+// TestSecondaryReclaimsWhenPrimaryGoes checks a server sharing another's OmniSharp claims the
+// workspace and starts its own once the other server's OmniSharp goes away
+func TestSecondaryReclaimsWhenPrimaryGoes(t *testing.T) {
+	tests := []struct {
+		name string
+		// leave is how the primary goes away
+		leave func(t *testing.T, primary *fakeOmniSharp, root string)
+	}{
+		{"OmniSharp stops answering", func(t *testing.T, primary *fakeOmniSharp, root string) { primary.Close() }},
+		{"lock removed", func(t *testing.T, primary *fakeOmniSharp, root string) {
+			if err := os.Remove(lockPath(root)); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ready := map[string]interface{}{"/checkreadystatus": map[string]bool{"Ready": true}}
+			primary := newFakeOmniSharp(t, ready)
+			own := newFakeOmniSharp(t, ready)
+			s, _ := newTestServer(t, nil)
+			configure(s, func(config *Config) { config.OmniSharp.Address = own.URL })
+			if err := os.WriteFile(filepath.Join(s.rootPath, "Game.sln"), nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			writeLock(t, s.rootPath, `{"pid": `+strconv.Itoa(os.Getppid())+`, "omnisharp": "`+primary.URL+`"}`)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			s.startOmniSharp(ctx)
+			if omnisharp := s.backend(); omnisharp == nil || omnisharp.baseURL != primary.URL {
+				t.Fatalf("using %+v, want the primary's OmniSharp", omnisharp)
+			}
+
+			test.leave(t, primary, s.rootPath)
+			deadline := time.Now().Add(5 * primaryCheckInterval)
+			for omnisharp := s.backend(); omnisharp == nil || omnisharp.baseURL != own.URL; omnisharp = s.backend() {
+				if time.Now().After(deadline) {
+					t.Fatalf("still using %+v, want our own OmniSharp", omnisharp)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if lock, _ := readLock(s.rootPath); lock.PID != os.Getpid() {
+				t.Errorf("lock = %+v, want ours", lock)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+type backendState int
+
+const (
+	backendStarting backendState = iota
+	backendReady
+	// backendDegraded means OmniSharp is unavailable and requests are answered with empty results
+	backendDegraded
+	// backendNoSolution means the workspace has no solution for OmniSharp to load yet
+	backendNoSolution
+)
+
+// workspaceRoot picks the folder OmniSharp should load from the initialize params
+func workspaceRoot(params *protocol.InitializeParams) string {
+	if params.RootURI != "" {
+		return params.RootURI.Filename()
+	}
+	if len(params.WorkspaceFolders) > 0 {
+		return protocol.DocumentURI(params.WorkspaceFolders[0].URI).Filename()
+	}
+	if params.RootPath != "" {
+		return params.RootPath
+	}
+	wd, _ := os.Getwd()
+	return wd
+}
+
+// startOmniSharp launches OmniSharp and waits for the solution to load. If it fails to start
+// or doesn't become ready within omnisharp.startupTimeout the server keeps running degraded
+func (s *Server) startOmniSharp(ctx context.Context) {
+	s.mu.Lock()
+	noSolution := s.state == backendNoSolution
+	s.mu.Unlock()
+	if noSolution {
+		s.enterNoSolution(ctx)
+		return
+	}
+	if !s.claimWorkspace(ctx) {
+		return
+	}
+
+	solution := s.chooseSolution(ctx)
+	s.projectDiagnostics.reset(ctx, s.client)
+	var process *OmniSharpProcess
+	var client *OmniSharpClient
+	if address := s.currentConfig().OmniSharp.Address; address != "" {
+		// Someone else runs OmniSharp, with the solution they loaded
+		client = NewOmniSharpClient(address, time.Duration(s.currentConfig().OmniSharp.RequestTimeout), s.currentConfig().OmniSharp.Compression)
+		log.Printf("connecting to OmniSharp at %s", redactedAddress(address))
+	} else {
+		version := s.checkOmniSharpVersion(ctx)
+		var err error
+		process, client, err = LaunchOmniSharp(s.currentConfig().OmniSharp, solution, s.currentConfig().Formatting.omnisharpArgs(), s.locale, func(line string) {
+			s.projectDiagnostics.observe(ctx, s.client, line)
+		})
+		if err != nil {
+			s.enterDegraded(ctx, err.Error())
+			return
+		}
+		client.version = version
+	}
+
+	s.mu.Lock()
+	s.process = process
+	s.mu.Unlock()
+
+	timeout := time.Duration(s.currentConfig().OmniSharp.StartupTimeout)
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := process.WaitReady(waitCtx, client); err != nil {
+		reason := err.Error()
+		if waitCtx.Err() == context.DeadlineExceeded {
+			reason = fmt.Sprintf(s.localize("OmniSharp did not finish loading the solution within %s"), timeout)
+		}
+		if process != nil {
+			log.Printf("last OmniSharp output:\n%s", process.output)
+		}
+		s.stopOmniSharp()
+		s.enterDegraded(ctx, reason)
+		return
+	}
+
+	s.mu.Lock()
+	s.state = backendReady
+	s.omnisharp = client
+	s.mu.Unlock()
+	log.Printf("OmniSharp ready for %s", solution)
+	if err := publishLock(s.rootPath, client.address); err != nil {
+		log.Printf("failed to share OmniSharp with other servers for the workspace: %v", err)
+	}
+
+	s.diagnostics.quiet(s.client, time.Duration(s.currentConfig().Diagnostics.QuietPeriod))
+	// Documents opened while OmniSharp was starting haven't been synced yet
+	s.resyncDocuments(ctx)
+	s.workspaceDiagnostics.schedule(s)
+	s.notifyReady(ctx, client)
+
+	if s.currentConfig().OmniSharp.DedicatedCompletionInstance {
+		s.startCompletionReplica(ctx, solution)
+	}
+}
+
+// checkOmniSharpVersion reads the release of the configured OmniSharp, warning when it is
+// outside the range supported. It returns the zero version when OmniSharp can't tell, leaving
+// the launch to report why it won't run
+func (s *Server) checkOmniSharpVersion(ctx context.Context) omnisharpVersion {
+	version, err := readOmniSharpVersion(s.currentConfig().OmniSharp)
+	if err != nil {
+		log.Printf("failed to read the OmniSharp version: %v", err)
+		return omnisharpVersion{}
+	}
+	log.Printf("OmniSharp %s", version)
+	if warning, bound, ok := versionWarning(version); ok {
+		message := fmt.Sprintf(s.localize(warning), version, bound)
+		log.Print(message)
+		s.client.ShowMessage(ctx, &protocol.ShowMessageParams{
+			Type:    protocol.MessageTypeWarning,
+			Message: message,
+		})
+	}
+	return version
+}
+
+// startCompletionReplica launches a second OmniSharp that only serves completion, so typing
+// stays responsive while the primary is busy with diagnostics and navigation. Until it is
+// ready, or if it fails, completion goes to the primary
+func (s *Server) startCompletionReplica(ctx context.Context, solution string) {
+	// The primary already reports problems loading the solution
+	process, client, err := LaunchOmniSharp(s.currentConfig().OmniSharp, solution, s.currentConfig().Formatting.omnisharpArgs(), s.locale, nil)
+	if err != nil {
+		log.Printf("failed to start the completion OmniSharp, using the primary: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	s.replicaProcess = process
+	s.mu.Unlock()
+
+	waitCtx, cancel := context.WithTimeout(ctx, time.Duration(s.currentConfig().OmniSharp.StartupTimeout))
+	defer cancel()
+
+	if err := process.WaitReady(waitCtx, client); err != nil {
+		log.Printf("completion OmniSharp did not become ready, using the primary: %v", err)
+		s.mu.Lock()
+		s.replicaProcess = nil
+		s.mu.Unlock()
+		process.Stop()
+		return
+	}
+
+	s.handOffCompletion(ctx, client)
+	log.Printf("completion OmniSharp ready for %s", solution)
+}
+
+// handOffCompletion has replica take over completion once it has the open buffers. Changes
+// wait meanwhile, then reach it along with the primary
+func (s *Server) handOffCompletion(ctx context.Context, replica *OmniSharpClient) {
+	s.bufferSync.Lock()
+	defer s.bufferSync.Unlock()
+
+	for _, doc := range s.documents.OpenDocuments() {
+		if !isDisabledText(doc.Text) {
+			s.sendBuffer(ctx, []*OmniSharpClient{replica}, doc)
+		}
+	}
+	s.mu.Lock()
+	s.replica = replica
+	s.mu.Unlock()
+}
+
+func (s *Server) enterDegraded(ctx context.Context, reason string) {
+	s.mu.Lock()
+	s.state = backendDegraded
+	s.omnisharp = nil
+	s.mu.Unlock()
+
+	log.Printf("OmniSharp unavailable, continuing without it: %s", reason)
+	s.client.ShowMessage(ctx, &protocol.ShowMessageParams{
+		Type:    protocol.MessageTypeError,
+		Message: fmt.Sprintf(s.localize("Unity LSP: %s. C# features are unavailable until the server is restarted."), reason),
+	})
+}
+
+// completionBackend returns the completion replica when it is running, or else the primary
+func (s *Server) completionBackend() *OmniSharpClient {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state != backendReady {
+		return nil
+	}
+	if s.replica != nil {
+		return s.replica
+	}
+	return s.omnisharp
+}
+
+// bufferBackends returns every running OmniSharp, all of which must see buffer changes
+func (s *Server) bufferBackends() []*OmniSharpClient {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state != backendReady {
+		return nil
+	}
+	backends := []*OmniSharpClient{s.omnisharp}
+	if s.replica != nil {
+		backends = append(backends, s.replica)
+	}
+	return backends
+}
+
+// backend returns the OmniSharp client, or nil while it is starting or when degraded
+func (s *Server) backend() *OmniSharpClient {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state != backendReady {
+		return nil
+	}
+	return s.omnisharp
+}
+
+func (s *Server) stopOmniSharp() {
+	s.mu.Lock()
+	processes := []*OmniSharpProcess{s.process, s.replicaProcess}
+	s.process = nil
+	s.replicaProcess = nil
+	s.replica = nil
+	s.mu.Unlock()
+	s.projects.reset()
+
+	for _, process := range processes {
+		if process != nil {
+			process.Stop()
+		}
+	}
+}
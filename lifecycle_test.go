@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestStartupTimeout(t *testing.T) {
+	tests := []struct {
+		name      string
+		ready     bool
+		wantState backendState
+	}{
+		{"ready in time", true, backendReady},
+		{"never ready", false, backendDegraded},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{
+				"/checkreadystatus": map[string]bool{"Ready": test.ready},
+				"/typelookup":       TypeLookupResponse{Type: "float Player.speed"},
+			})
+			s, client := newTestServer(t, nil)
+			if err := os.WriteFile(filepath.Join(s.rootPath, "Game.sln"), nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			configure(s, func(config *Config) {
+				config.OmniSharp.Address = fake.URL
+				config.OmniSharp.StartupTimeout = Duration(100 * time.Millisecond)
+			})
+
+			start := time.Now()
+			s.startOmniSharp(context.Background())
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("waited %v for OmniSharp", elapsed)
+			}
+			s.mu.Lock()
+			state := s.state
+			s.mu.Unlock()
+			if state != test.wantState {
+				t.Fatalf("state = %d, want %d", state, test.wantState)
+			}
+			if test.ready {
+				return
+			}
+
+			waitFor(t, "the error message", func() bool { return len(client.received(protocol.MethodWindowShowMessage)) > 0 })
+			var message protocol.ShowMessageParams
+			json.Unmarshal(client.received(protocol.MethodWindowShowMessage)[0], &message)
+			if message.Type != protocol.MessageTypeError || !strings.Contains(message.Message, "within 100ms") {
+				t.Errorf("showed %+v", message)
+			}
+			// Degraded, requests are answered empty rather than left waiting
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, "class Player { float speed; }")
+			hover, err := s.handleHover(context.Background(), &protocol.HoverParams{
+				TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+					TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+					Position:     protocol.Position{Character: 23},
+				},
+			})
+			if hover != nil || err != nil {
+				t.Errorf("degraded hover = %+v, %v", hover, err)
+			}
+			if calls := fake.callCount("/typelookup"); calls != 0 {
+				t.Errorf("degraded hover asked OmniSharp")
+			}
+		})
+	}
+}
+
+// TestCompletionReplica checks completion goes to the dedicated completion OmniSharp when one
+// runs, hover to the primary, and buffer changes to both
+func TestCompletionReplica(t *testing.T) {
+	tests := []struct {
+		name    string
+		replica bool
+	}{
+		{"with a replica", true},
+		{"without", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			primary := newFakeOmniSharp(t, map[string]interface{}{
+				"/autocomplete": autoCompleteItems("speed"),
+				"/typelookup":   TypeLookupResponse{Type: "float Player.speed"},
+			})
+			replica := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": autoCompleteItems("speed")})
+			s, _ := newTestServer(t, primary)
+			if test.replica {
+				s.replica = NewOmniSharpClient(replica.URL, 5*time.Second, false)
+			}
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, "class Player { float speed; void Start() { } }")
+			typeAt(s, uri, 2, protocol.Position{Character: 43}, "s")
+
+			completeAt(t, s, uri, protocol.Position{Character: 44}, protocol.CompletionTriggerKindInvoked)
+			if _, err := s.handleHover(context.Background(), &protocol.HoverParams{
+				TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+					TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+					Position:     protocol.Position{Character: 22},
+				},
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			completing, idle := primary, replica
+			if test.replica {
+				completing, idle = replica, primary
+			}
+			if completing.callCount("/autocomplete") != 1 || idle.callCount("/autocomplete") != 0 {
+				t.Errorf("completion asked the primary %d times and the replica %d times", primary.callCount("/autocomplete"), replica.callCount("/autocomplete"))
+			}
+			if primary.callCount("/typelookup") != 1 || replica.callCount("/typelookup") != 0 {
+				t.Errorf("hover asked the primary %d times and the replica %d times", primary.callCount("/typelookup"), replica.callCount("/typelookup"))
+			}
+			if updates := primary.callCount("/updatebuffer"); updates == 0 || test.replica && replica.callCount("/updatebuffer") != updates {
+				t.Errorf("the replica got %d buffer updates, the primary %d", replica.callCount("/updatebuffer"), primary.callCount("/updatebuffer"))
+			}
+		})
+	}
+}
+
+// TestCompletionReplicaHandoff changes a document while the open buffers are on their way to
+// the replica taking over completion, which must end up with the change all the same
+func TestCompletionReplicaHandoff(t *testing.T) {
+	primary := newFakeOmniSharp(t, map[string]interface{}{})
+	replica := newFakeOmniSharp(t, map[string]interface{}{})
+	s, _ := newTestServer(t, primary)
+	uris := []protocol.DocumentURI{testURI(s, "Player.cs"), testURI(s, "Enemy.cs")}
+	for _, uri := range uris {
+		openTestDocument(s, uri, "class C { }")
+	}
+	replica.setDelay(200 * time.Millisecond)
+	type update struct{ FileName, Buffer string }
+	updates := func() []update {
+		replica.mu.Lock()
+		defer replica.mu.Unlock()
+		var updates []update
+		for _, body := range replica.bodies["/updatebuffer"] {
+			var u update
+			json.Unmarshal(body, &u)
+			updates = append(updates, u)
+		}
+		return updates
+	}
+
+	handedOff := make(chan struct{})
+	go func() {
+		s.handOffCompletion(context.Background(), NewOmniSharpClient(replica.URL, 5*time.Second, false))
+		close(handedOff)
+	}()
+	waitFor(t, "the replica to get a buffer", func() bool { return len(updates()) > 0 })
+	// Change the document whose buffer is yet to be sent
+	uri := uris[0]
+	if updates()[0].FileName == uri.Filename() {
+		uri = uris[1]
+	}
+	const changed = "class C { float speed; }"
+	s.handleDidChange(context.Background(), &DidChangeTextDocumentParams{
+		TextDocument:   protocol.VersionedTextDocumentIdentifier{TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri}, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: changed}},
+	})
+	<-handedOff
+
+	var last string
+	for _, u := range updates() {
+		if u.FileName == uri.Filename() {
+			last = u.Buffer
+		}
+	}
+	if last != changed {
+		t.Errorf("the replica was left with %q, want %q", last, changed)
+	}
+}
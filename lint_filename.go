@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// diagnosticClassNameMismatch is a MonoBehaviour in a file named after none of its classes,
+// which Unity can't attach to a GameObject
+const diagnosticClassNameMismatch = "UNITY0001"
+
+// commandRenameClass renames a MonoBehaviour after its file, see renameClassArguments
+const commandRenameClass = "unity-lsp.renameClass"
+
+// renameClassArguments is the single argument of commandRenameClass
+type renameClassArguments struct {
+	URI      protocol.DocumentURI `json:"uri"`
+	Position protocol.Position    `json:"position"`
+	NewName  string               `json:"newName"`
+}
+
+var (
+	// derivedClass matches a class declaration with a base type, capturing its modifiers,
+	// name, type parameters and first base type
+	derivedClass = regexp.MustCompile(`(?m)^[ \t]*((?:\w+[ \t]+)*)class[ \t]+@?(\w+)([ \t]*<[^>{]*>)?[ \t]*:[ \t]*([\w.]+)`)
+	// classDeclaration matches any class declaration, capturing its name
+	classDeclaration = regexp.MustCompile(`\bclass\s+@?(\w+)`)
+)
+
+// scriptBaseTypes are the base types making a class a script Unity attaches by file name
+var scriptBaseTypes = map[string]bool{"MonoBehaviour": true, "NetworkBehaviour": true}
+
+// classNameDiagnostics warns about the first MonoBehaviour of a script in a Unity project when
+// no class of the file is named after it. Unity finds a script's component by the file name and
+// fails silently otherwise. Abstract and generic classes are never attached, so they're left out,
+// as are classes in comments and strings
+func classNameDiagnostics(doc Document, rootPath string) []protocol.Diagnostic {
+	path := doc.URI.Filename()
+	if !strings.EqualFold(filepath.Ext(path), ".cs") {
+		return nil
+	}
+	if _, ok := unityProjectRoot(path, rootPath); !ok {
+		return nil
+	}
+	fileName := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	code := maskCommentsAndStrings(doc.Text)
+	for _, match := range classDeclaration.FindAllStringSubmatch(code, -1) {
+		if match[1] == fileName {
+			return nil
+		}
+	}
+
+	matches := derivedClass.FindAllStringSubmatchIndex(code, -1)
+	// Classes deriving from a MonoBehaviour of the same file are MonoBehaviours too
+	scripts := make(map[string]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, match := range matches {
+			name, base := code[match[4]:match[5]], code[match[8]:match[9]]
+			base = base[strings.LastIndexByte(base, '.')+1:]
+			if !scripts[name] && (scriptBaseTypes[base] || scripts[base]) {
+				scripts[name], changed = true, true
+			}
+		}
+	}
+
+	for _, match := range matches {
+		name := code[match[4]:match[5]]
+		modifiers := strings.Fields(code[match[2]:match[3]])
+		if !scripts[name] || match[6] >= 0 || containsString(modifiers, "abstract") {
+			continue
+		}
+		return []protocol.Diagnostic{{
+			Range: protocol.Range{
+				Start: positionAt(doc.Text, match[4]),
+				End:   positionAt(doc.Text, match[5]),
+			},
+			Severity: protocol.DiagnosticSeverityWarning,
+			Code:     diagnosticClassNameMismatch,
+			Source:   "unity-lsp",
+			Message:  fmt.Sprintf("%s doesn't match the file name %s: Unity can't attach it to a GameObject.", name, filepath.Base(path)),
+		}}
+	}
+	return nil
+}
+
+// classNameActions offers to rename a MonoBehaviour after its file, unless the file name can't
+// name a class. The rename runs across the solution, so its references follow, only once the
+// action is picked: clients ask for code actions whenever the caret moves
+func classNameActions(params *protocol.CodeActionParams) []protocol.CodeAction {
+	uri := params.TextDocument.URI
+	path := uri.Filename()
+	fileName := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if !isIdentifier(fileName) {
+		return nil
+	}
+
+	var actions []protocol.CodeAction
+	for _, diagnostic := range params.Context.Diagnostics {
+		if fmt.Sprint(diagnostic.Code) != diagnosticClassNameMismatch {
+			continue
+		}
+		title := "Rename class to " + fileName
+		actions = append(actions, protocol.CodeAction{
+			Title:       title,
+			Kind:        protocol.QuickFix,
+			Diagnostics: []protocol.Diagnostic{diagnostic},
+			IsPreferred: true,
+			Command: &protocol.Command{
+				Title:     title,
+				Command:   commandRenameClass,
+				Arguments: []interface{}{renameClassArguments{URI: uri, Position: diagnostic.Range.Start, NewName: fileName}},
+			},
+		})
+	}
+	return actions
+}
+
+// runRenameClass renames the class at the position of args across the solution and applies
+// the edit, also returning it
+func (s *Server) runRenameClass(ctx context.Context, args renameClassArguments) (interface{}, error) {
+	edit, err := s.handleRename(ctx, &protocol.RenameParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: args.URI},
+			Position:     args.Position,
+		},
+		NewName: args.NewName,
+	})
+	if err != nil || edit == nil {
+		return nil, err
+	}
+	if err := s.applyEdit(ctx, "Rename class to "+args.NewName, edit); err != nil {
+		return nil, err
+	}
+	return edit, nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestClassNameDiagnostics(t *testing.T) {
+	tests := []struct {
+		name string
+		// file is the script's path below the workspace
+		file string
+		text string
+		// want is the class warned about, if any
+		want string
+	}{
+		{"matching", "Assets/Player.cs", "public class Player : MonoBehaviour { }\n", ""},
+		{"mismatched", "Assets/Player.cs", "public class PlayerController : MonoBehaviour { }\n", "PlayerController"},
+		{"qualified base", "Assets/Player.cs", "class Hero : UnityEngine.MonoBehaviour { }\n", "Hero"},
+		{"derived from a script of the file", "Assets/Player.cs", "class Actor : MonoBehaviour { }\nclass Hero : Actor { }\n", "Actor"},
+		{"abstract", "Assets/Player.cs", "public abstract class Actor : MonoBehaviour { }\n", ""},
+		{"generic", "Assets/Player.cs", "public class Pool<T> : MonoBehaviour { }\n", ""},
+		{"not a script", "Assets/Player.cs", "public class Inventory : ScriptableObject { }\n", ""},
+		{"matching among others", "Assets/Player.cs", "class Helper { }\npublic class Player : MonoBehaviour { }\n", ""},
+		{"outside a Unity project", "Scripts/Player.cs", "public class PlayerController : MonoBehaviour { }\n", ""},
+		{"not C#", "Assets/Player.txt", "public class PlayerController : MonoBehaviour { }\n", ""},
+		{"commented out", "Assets/Player.cs", "// public class PlayerController : MonoBehaviour { }\n", ""},
+		{"in a string", "Assets/Player.cs", "class Help { string text = @\"\nclass Hero : MonoBehaviour { }\"; }\n", ""},
+		{"matching in a comment", "Assets/Player.cs", "/* class Player */\npublic class PlayerController : MonoBehaviour { }\n", "PlayerController"},
+		{"matching in a string", "Assets/Player.cs", "public class PlayerController : MonoBehaviour { string old = \"class Player\"; }\n", "PlayerController"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, map[string]string{test.file: test.text, "ProjectSettings/ProjectVersion.txt": ""})
+			doc := Document{URI: pathToURI(root + "/" + test.file), Text: test.text}
+
+			diagnostics := classNameDiagnostics(doc, root)
+			if test.want == "" {
+				if len(diagnostics) != 0 {
+					t.Errorf("warned %+v, want nothing", diagnostics)
+				}
+				return
+			}
+			if len(diagnostics) != 1 {
+				t.Fatalf("warned %+v, want %s", diagnostics, test.want)
+			}
+			diagnostic := diagnostics[0]
+			start, end := offsetAt(test.text, diagnostic.Range.Start), offsetAt(test.text, diagnostic.Range.End)
+			if test.text[start:end] != test.want || diagnostic.Code != diagnosticClassNameMismatch || diagnostic.Severity != protocol.DiagnosticSeverityWarning {
+				t.Errorf("warned %+v about %q, want a warning about %s", diagnostic, test.text[start:end], test.want)
+			}
+		})
+	}
+}
+
+// TestClassNameMismatch checks a mismatched MonoBehaviour is warned about with OmniSharp's
+// diagnostics, and its quick fix renames the class after the file across the solution once
+// picked
+func TestClassNameMismatch(t *testing.T) {
+	const text = "using UnityEngine;\n\npublic class PlayerController : MonoBehaviour { }\n"
+	fake := newFakeOmniSharp(t, map[string]interface{}{
+		"/codecheck": map[string]interface{}{"QuickFixes": []QuickFix{}},
+		"/getfixall": map[string]interface{}{"Items": []FixAllItem{}},
+	})
+	s, client := newTestServer(t, fake)
+	uri := testURI(s, "Assets/Player.cs")
+	writeFiles(t, s.rootPath, map[string]string{
+		"Assets/Player.cs":                   text,
+		"Assets/Spawner.cs":                  "class Spawner { PlayerController prefab; }\n",
+		"ProjectSettings/ProjectVersion.txt": "",
+	})
+	spawner := testURI(s, "Assets/Spawner.cs")
+	fake.setResponse("/rename", RenameResponse{Changes: []ModifiedFileResponse{
+		{FileName: uri.Filename(), Changes: []LinePositionSpanTextChange{{NewText: "Player", StartLine: 2, StartColumn: 13, EndLine: 2, EndColumn: 29}}},
+		{FileName: spawner.Filename(), Changes: []LinePositionSpanTextChange{{NewText: "Player", StartColumn: 16, EndColumn: 32}}},
+	}})
+	openTestDocument(s, uri, text)
+
+	waitFor(t, "diagnostics", func() bool { return len(client.received(protocol.MethodTextDocumentPublishDiagnostics)) > 0 })
+	var published protocol.PublishDiagnosticsParams
+	if err := json.Unmarshal(client.received(protocol.MethodTextDocumentPublishDiagnostics)[0], &published); err != nil {
+		t.Fatal(err)
+	}
+	if len(published.Diagnostics) != 1 || published.Diagnostics[0].Code != diagnosticClassNameMismatch {
+		t.Fatalf("published %+v, want the mismatch", published.Diagnostics)
+	}
+
+	actions, err := s.handleCodeAction(context.Background(), &protocol.CodeActionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Range:        published.Diagnostics[0].Range,
+		Context:      protocol.CodeActionContext{Diagnostics: published.Diagnostics},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rename *protocol.CodeAction
+	for i, action := range actions {
+		if action.Title == "Rename class to Player" {
+			rename = &actions[i]
+		}
+	}
+	if rename == nil || !rename.IsPreferred || rename.Command == nil {
+		t.Fatalf("actions %+v, want a preferred rename to Player", actions)
+	}
+	if calls := fake.callCount("/rename"); calls != 0 {
+		t.Errorf("renamed %d times before the action was picked", calls)
+	}
+
+	client.answer(protocol.MethodWorkspaceApplyEdit, protocol.ApplyWorkspaceEditResponse{Applied: true})
+	if _, err := s.handleExecuteCommand(context.Background(), &protocol.ExecuteCommandParams{
+		Command:   rename.Command.Command,
+		Arguments: rename.Command.Arguments,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	applied := client.received(protocol.MethodWorkspaceApplyEdit)
+	if len(applied) != 1 {
+		t.Fatalf("applied %d edits, want the rename", len(applied))
+	}
+	var params protocol.ApplyWorkspaceEditParams
+	if err := json.Unmarshal(applied[0], &params); err != nil {
+		t.Fatal(err)
+	}
+	if len(params.Edit.Changes[uri]) != 1 || len(params.Edit.Changes[spawner]) != 1 {
+		t.Errorf("renames %+v, want the class and its reference", params.Edit.Changes)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	var request struct {
+		Line, Column int
+		RenameTo     string
+	}
+	if bodies := fake.bodies["/rename"]; len(bodies) != 1 || json.Unmarshal(bodies[0], &request) != nil || request.RenameTo != "Player" || request.Line != 2 || request.Column != 13 {
+		t.Errorf("renamed %s, want PlayerController at 2:13 to Player", fake.bodies["/rename"])
+	}
+}
+
+func TestClassNameActions(t *testing.T) {
+	tests := []struct {
+		file string
+		want bool
+	}{
+		{"Player.cs", true},
+		{"_Player2.cs", true},
+		{"My Script.cs", false},
+		{"2DPlayer.cs", false},
+		{"Player-Controller.cs", false},
+		{"class.cs", false},
+	}
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			diagnostic := protocol.Diagnostic{Code: diagnosticClassNameMismatch}
+			actions := classNameActions(&protocol.CodeActionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: pathToURI("/project/Assets/" + test.file)},
+				Context:      protocol.CodeActionContext{Diagnostics: []protocol.Diagnostic{diagnostic}},
+			})
+			if offered := len(actions) > 0; offered != test.want {
+				t.Errorf("offered %+v, want a rename %v", actions, test.want)
+			}
+		})
+	}
+}
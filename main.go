@@ -1,27 +1,78 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"io/ioutil"
 	"log"
-	"net/http"
 	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.lsp.dev/jsonrpc2"
 	"go.lsp.dev/protocol"
+	"go.uber.org/zap"
 )
 
-type OmniSharpClient struct {
-	baseURL string
-	client  *http.Client
-}
-
 type Server struct {
-	conn      jsonrpc2.Conn
-	client    protocol.Client
+	conn                 jsonrpc2.Conn
+	client               protocol.Client
+	documents            *DocumentStore
+	diagnostics          *diagnosticsPublisher
+	diagnosticsQueue     *diagnosticsQueue
+	projectDiagnostics   *projectDiagnostics
+	projects             *projectIndex
+	projectFiles         *projectFiles
+	workspaceDiagnostics *workspaceDiagnostics
+	cache                *responseCache
+	documentation        *documentationCache
+	completionSession    *completionSession
+	recentCompletions    *recentCompletions
+	resources            *resourceIndex
+	largeDocuments       *largeDocuments
+	resolvedHints        *resolvedHints
+	symbolQuery          *latestRequest
+	completionQuery      *latestRequest
+	symbolResults        *symbolResults
+	initialized          bool
+	// shuttingDown is set once shutdown arrives, after which only exit is accepted
+	shuttingDown bool
+	requests     *requestTracker
+	inFlight     *inFlightRequests
+	capabilities protocol.ClientCapabilities
+	// lazySymbolProperties are the 3.17 capabilities protocol.ClientCapabilities lacks
+	lazySymbolProperties lazySymbolProperties
+	// completionListDefaults are the completion itemDefaults the client accepts beyond editRange
+	completionListDefaults completionListDefaults
+	// completionOrigins are the data the items of recent completion lists share, kept here in
+	// their place, which resolve adds back to the data the client returns
+	completionOrigins *completionOrigins
+	tracer            *tracer
+	// config is the configuration in effect, which didChangeConfiguration replaces while
+	// requests served off the read loop read it, so it is only ever swapped whole
+	config atomic.Pointer[Config]
+	// initializationOptions are kept to resolve the configuration when settings change
+	initializationOptions interface{}
+	rootPath              string
+	// locale is the language of the client's user interface, which our messages are shown in
+	locale string
+
+	// mu guards the OmniSharp backend, which is replaced when it finishes starting
+	mu        sync.Mutex
+	state     backendState
 	omnisharp *OmniSharpClient
+	process   *OmniSharpProcess
+	// replica is the optional second OmniSharp dedicated to completion
+	replica        *OmniSharpClient
+	replicaProcess *OmniSharpProcess
+	// reloaded is closed once the reload in progress, if any, has synced the open buffers
+	reloaded chan struct{}
+	// projectReload debounces the reloads project file changes trigger
+	projectReload *time.Timer
+
+	// bufferSync is held from reading open documents to sending them to OmniSharp, so a copy
+	// read before a change can't reach a backend after the change did
+	bufferSync sync.Mutex
 }
 
 type StdioStream struct {
@@ -30,12 +81,48 @@ type StdioStream struct {
 }
 
 func main() {
-	server := &Server{}
-	if err := server.Start(); err != nil {
+	// --check validates the OmniSharp install instead of serving, for scripts and bug reports
+	if len(os.Args) > 1 && os.Args[1] == "--check" {
+		os.Exit(runCheck(os.Args[2:]))
+	}
+	if err := newServer(DefaultConfig()).Start(); err != nil {
 		log.Fatal(err)
 	}
 }
 
+// newServer creates a server with config, before initialize resolves the client's settings
+func newServer(config Config) *Server {
+	s := &Server{
+		documents:            NewDocumentStore(config.Documents.MaxTracked),
+		diagnostics:          newDiagnosticsPublisher(),
+		diagnosticsQueue:     newDiagnosticsQueue(),
+		projectDiagnostics:   newProjectDiagnostics(),
+		projects:             newProjectIndex(),
+		projectFiles:         newProjectFiles(),
+		workspaceDiagnostics: newWorkspaceDiagnostics(),
+		cache:                newResponseCache(),
+		documentation:        newDocumentationCache(1000),
+		completionSession:    &completionSession{},
+		recentCompletions:    newRecentCompletions(),
+		completionOrigins:    newCompletionOrigins(),
+		resources:            newResourceIndex(),
+		largeDocuments:       newLargeDocuments(),
+		resolvedHints:        newResolvedHints(),
+		symbolQuery:          &latestRequest{},
+		completionQuery:      &latestRequest{},
+		symbolResults:        &symbolResults{},
+		requests:             newRequestTracker(),
+		inFlight:             newInFlightRequests(),
+	}
+	s.config.Store(&config)
+	return s
+}
+
+// currentConfig returns the configuration in effect, which callers must not modify
+func (s *Server) currentConfig() *Config {
+	return s.config.Load()
+}
+
 func (s *Server) Start() error {
 	// Create a new stream for stdin/stdout communication
 	stream := jsonrpc2.NewStream(NewStdioStream())
@@ -43,90 +130,480 @@ func (s *Server) Start() error {
 	// Create a new connection
 	conn := jsonrpc2.NewConn(stream)
 	s.conn = conn
+	s.client = protocol.ClientDispatcher(conn, zap.NewNop())
+	s.tracer = newTracer(conn)
 
 	// Handle incoming requests
 	conn.Go(context.Background(), s.handle)
 
 	// Wait for connection to close
 	<-conn.Done()
+	s.stopOmniSharp()
+	releaseLock(s.rootPath)
 	return conn.Err()
 }
 
 // handle processes incoming LSP requests
 func (s *Server) handle(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+	reply = withErrorCodes(s.tracer.wrap(ctx, req, reply))
+	if call, ok := req.(*jsonrpc2.Call); ok {
+		ctx, reply = s.inFlight.track(ctx, call, reply)
+	}
+
+	if !s.initialized && req.Method() != protocol.MethodInitialize {
+		return reply(ctx, nil, lspError(jsonrpc2.ServerNotInitialized, "the server has not received initialize yet"))
+	}
+	// Requests still in flight after shutdown can be cancelled
+	if s.shuttingDown && req.Method() != protocol.MethodExit && req.Method() != protocol.MethodCancelRequest {
+		return reply(ctx, nil, lspError(jsonrpc2.InvalidRequest, "the server is shutting down"))
+	}
+	if s.disabledRequest(req) {
+		return reply(ctx, nil, nil)
+	}
+
 	switch req.Method() {
+	case protocol.MethodCancelRequest:
+		var params protocol.CancelParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.handleCancelRequest(&params)
+		return reply(ctx, nil, nil)
+
 	case protocol.MethodInitialize:
 		var params protocol.InitializeParams
-		if err := req.Params().UnmarshalTo(&params); err != nil {
-			return err
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.lazySymbolProperties = parseLazySymbolProperties(req.Params())
+		s.completionListDefaults = parseCompletionListDefaults(req.Params())
+		result, err := s.handleInitialize(&params)
+		return reply(ctx, result, err)
+
+	case protocol.MethodSetTrace:
+		var params protocol.SetTraceParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.tracer.setLevel(params.Value)
+		return reply(ctx, nil, nil)
+
+	case protocol.MethodShutdown:
+		s.shuttingDown = true
+		// Not tracked with goRequest, since it waits for every tracked request
+		go func() {
+			s.handleShutdown()
+			reply(ctx, nil, nil)
+		}()
+		return nil
+
+	case protocol.MethodExit:
+		s.handleExit()
+		return nil
+
+	case protocol.MethodInitialized:
+		go s.startOmniSharp(context.Background())
+		// Registering waits on the client's response, which arrives through this read loop
+		if s.currentConfig().OmniSharp.ReloadOnProjectChanges && s.supportsWatchedFiles() {
+			go s.watchProjectFiles(context.Background())
+		}
+		if s.supportsWatchedFiles() {
+			go s.watchResources(context.Background())
+		}
+		return reply(ctx, nil, nil)
+
+	case protocol.MethodWorkspaceDidChangeConfiguration:
+		var params protocol.DidChangeConfigurationParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.handleDidChangeConfiguration(ctx, &params)
+		return reply(ctx, nil, nil)
+
+	case methodConfig:
+		return reply(ctx, s.handleConfig(), nil)
+
+	case methodStatus:
+		return reply(ctx, s.handleStatus(), nil)
+
+	case protocol.MethodTextDocumentDidOpen:
+		var params protocol.DidOpenTextDocumentParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.handleDidOpen(ctx, &params)
+		return reply(ctx, nil, nil)
+
+	case protocol.MethodTextDocumentDidChange:
+		var params DidChangeTextDocumentParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.handleDidChange(ctx, &params)
+		return reply(ctx, nil, nil)
+
+	case protocol.MethodTextDocumentDidClose:
+		var params protocol.DidCloseTextDocumentParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.handleDidClose(ctx, &params)
+		return reply(ctx, nil, nil)
+
+	case protocol.MethodWorkspaceDidChangeWatchedFiles:
+		var params protocol.DidChangeWatchedFilesParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
 		}
-		return reply(ctx, s.handleInitialize(&params))
+		s.handleDidChangeWatchedFiles(ctx, &params)
+		return reply(ctx, nil, nil)
 
 	case protocol.MethodTextDocumentCompletion:
 		var params protocol.CompletionParams
-		if err := req.Params().UnmarshalTo(&params); err != nil {
-			return err
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		// Begun on the read loop, so completions supersede each other in the order they arrived
+		ctx, end := s.completionQuery.begin(ctx)
+		s.serveRead(ctx, reply, "completion", params.TextDocument.URI, func(ctx context.Context) (interface{}, error) {
+			defer end()
+			return s.handleCompletion(ctx, &params)
+		})
+		return nil
+
+	case protocol.MethodCompletionItemResolve:
+		var params CompletionItem
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.goRequest(ctx, func(ctx context.Context) {
+			s.awaitReload(ctx)
+			result, err := s.handleCompletionResolve(ctx, &params)
+			reply(ctx, result, err)
+		})
+		return nil
+
+	case protocol.MethodTextDocumentHover:
+		var params protocol.HoverParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.serveRead(ctx, reply, "hover", params.TextDocument.URI, func(ctx context.Context) (interface{}, error) {
+			return s.handleHover(ctx, &params)
+		})
+		return nil
+
+	case protocol.MethodTextDocumentSignatureHelp:
+		var params protocol.SignatureHelpParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.serveRead(ctx, reply, "signatureHelp", params.TextDocument.URI, func(ctx context.Context) (interface{}, error) {
+			return s.handleSignatureHelp(ctx, &params)
+		})
+		return nil
+
+	case protocol.MethodTextDocumentDefinition:
+		var params protocol.DefinitionParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.serveRead(ctx, reply, "definition", params.TextDocument.URI, func(ctx context.Context) (interface{}, error) {
+			result, err := s.handleDefinition(ctx, &params)
+			return result, s.userFacing(ctx, "Go to definition", err)
+		})
+		return nil
+
+	case protocol.MethodTextDocumentReferences:
+		var params ReferenceParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.serveRead(ctx, reply, "references", params.TextDocument.URI, func(ctx context.Context) (interface{}, error) {
+			result, err := s.handleReferences(ctx, &params)
+			return result, s.userFacing(ctx, "Find references", err)
+		})
+		return nil
+
+	case protocol.MethodTextDocumentImplementation:
+		var params protocol.ImplementationParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.serveRead(ctx, reply, "implementation", params.TextDocument.URI, func(ctx context.Context) (interface{}, error) {
+			result, err := s.handleImplementation(ctx, &params)
+			return result, s.userFacing(ctx, "Go to implementation", err)
+		})
+		return nil
+
+	case protocol.MethodWorkspaceSymbol:
+		var params protocol.WorkspaceSymbolParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		// Off the read loop, so the next keystroke's query can cancel this one, but begun on it, so
+		// queries supersede each other in the order they arrived
+		ctx, end := s.symbolQuery.begin(ctx)
+		s.goRequest(ctx, func(ctx context.Context) {
+			defer end()
+			result, err := s.handleWorkspaceSymbol(ctx, &params)
+			reply(ctx, result, s.userFacing(ctx, "Workspace symbol search", err))
+		})
+		return nil
+
+	case methodWorkspaceSymbolResolve:
+		var params WorkspaceSymbol
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.goRequest(ctx, func(ctx context.Context) {
+			result, err := s.handleWorkspaceSymbolResolve(ctx, &params)
+			reply(ctx, result, s.userFacing(ctx, "Workspace symbol search", err))
+		})
+		return nil
+
+	case protocol.MethodTextDocumentCodeAction:
+		var params protocol.CodeActionParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		// Asked at every caret move, and slow with /getfixall, so it mustn't hold up the edits
+		s.serveRead(ctx, reply, "codeAction", params.TextDocument.URI, func(ctx context.Context) (interface{}, error) {
+			return s.handleCodeAction(ctx, &params)
+		})
+		return nil
+
+	case protocol.MethodTextDocumentRename:
+		var params protocol.RenameParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		// A rename over many files takes a while, and edits meanwhile are what it checks for
+		s.goRequest(ctx, func(ctx context.Context) {
+			result, err := s.handleRename(ctx, &params)
+			reply(ctx, result, s.userFacing(ctx, "Rename", err))
+		})
+		return nil
+
+	case protocol.MethodTextDocumentDocumentSymbol:
+		var params protocol.DocumentSymbolParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.serveRead(ctx, reply, "documentSymbol", params.TextDocument.URI, func(ctx context.Context) (interface{}, error) {
+			return s.handleDocumentSymbol(ctx, &params)
+		})
+		return nil
+
+	case protocol.MethodSemanticTokensFull:
+		var params protocol.SemanticTokensParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.serveRead(ctx, reply, "semanticTokens", params.TextDocument.URI, func(ctx context.Context) (interface{}, error) {
+			return s.handleSemanticTokensFull(ctx, &params)
+		})
+		return nil
+
+	case protocol.MethodSemanticTokensRange:
+		var params protocol.SemanticTokensRangeParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.serveRead(ctx, reply, "semanticTokensRange", params.TextDocument.URI, func(ctx context.Context) (interface{}, error) {
+			return s.handleSemanticTokensRange(ctx, &params)
+		})
+		return nil
+
+	case methodInlayHint:
+		var params InlayHintParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.serveRead(ctx, reply, "inlayHint", params.TextDocument.URI, func(ctx context.Context) (interface{}, error) {
+			return s.handleInlayHint(ctx, &params)
+		})
+		return nil
+
+	case methodInlayHintResolve:
+		var params InlayHint
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.goRequest(ctx, func(ctx context.Context) {
+			s.awaitReload(ctx)
+			result, err := s.handleInlayHintResolve(ctx, &params)
+			reply(ctx, result, err)
+		})
+		return nil
+
+	case protocol.MethodTextDocumentWillSaveWaitUntil:
+		var params protocol.WillSaveTextDocumentParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.serveRead(ctx, reply, "willSaveWaitUntil", params.TextDocument.URI, func(ctx context.Context) (interface{}, error) {
+			return s.handleWillSaveWaitUntil(ctx, &params), nil
+		})
+		return nil
+
+	case protocol.MethodTextDocumentFormatting:
+		var params protocol.DocumentFormattingParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.serveRead(ctx, reply, "formatting", params.TextDocument.URI, func(ctx context.Context) (interface{}, error) {
+			result, err := s.handleFormatting(ctx, &params)
+			return result, s.userFacing(ctx, "Formatting", err)
+		})
+		return nil
+
+	case protocol.MethodWorkspaceExecuteCommand:
+		var params protocol.ExecuteCommandParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
 		}
-		return reply(ctx, s.handleCompletion(&params))
+		// Commands can wait on the user, whose answer arrives through this read loop
+		s.goRequest(ctx, func(ctx context.Context) {
+			result, err := s.handleExecuteCommand(ctx, &params)
+			reply(ctx, result, s.userFacing(ctx, "Running "+params.Command, err))
+		})
+		return nil
+
+	case methodReloadProjects:
+		// Params are optional for this request
+		var params ReloadProjectsParams
+		if err := json.Unmarshal(req.Params(), &params); len(req.Params()) > 0 && err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		// Reloading waits on OmniSharp, and progress on the client, whose answer arrives through
+		// this read loop
+		s.goRequest(ctx, func(ctx context.Context) {
+			result, err := s.handleReloadProjects(ctx, &params)
+			reply(ctx, result, err)
+		})
+		return nil
+
+	case methodRestart:
+		var params RestartParams
+		if err := json.Unmarshal(req.Params(), &params); len(req.Params()) > 0 && err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.goRequest(ctx, func(ctx context.Context) {
+			result, err := s.handleRestart(ctx, &params)
+			reply(ctx, result, err)
+		})
+		return nil
+
+	case methodOmniSharp:
+		var params OmniSharpParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.goRequest(ctx, func(ctx context.Context) {
+			s.awaitReload(ctx)
+			result, err := s.handleOmniSharp(ctx, &params)
+			reply(ctx, result, err)
+		})
+		return nil
+
+	case methodCheckFile:
+		var params CheckFileParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return reply(ctx, nil, invalidParams(err))
+		}
+		s.serveRead(ctx, reply, "checkFile", params.URI, func(ctx context.Context) (interface{}, error) {
+			return s.handleCheckFile(ctx, &params)
+		})
+		return nil
 	}
 
+	// A request left unanswered would keep the client waiting, while notifications, such as the
+	// $/ ones of other servers, may be ignored
+	if _, ok := req.(*jsonrpc2.Call); ok {
+		return reply(ctx, nil, lspError(jsonrpc2.MethodNotFound, "method not supported: "+req.Method()))
+	}
+	if s.tracer.getLevel() == protocol.TraceVerbose {
+		log.Printf("ignoring notification %s", req.Method())
+	}
 	return nil
 }
 
-func (s *Server) handleInitialize(params *protocol.InitializeParams) (*protocol.InitializeResult, error) {
-	return &protocol.InitializeResult{
-		Capabilities: protocol.ServerCapabilities{
-			CompletionProvider: &protocol.CompletionOptions{
-				TriggerCharacters: []string{".", " "},
-			},
-			TextDocumentSync: &protocol.TextDocumentSyncOptions{
-				Change:    protocol.TextDocumentSyncKindFull,
-				OpenClose: true,
-			},
-		},
-	}, nil
+// serveRead answers a request reading uri off the read loop, so a slow OmniSharp doesn't hold
+// up the edits queued behind it. If one of those lands meanwhile the result describes an older
+// buffer, and the client is told to ask again with ContentModified. Requests arriving while
+// projects reload wait for OmniSharp to have the open buffers again, unless omnisharp.duringReload
+// has them answered with a placeholder. name identifies the request in telemetry
+func (s *Server) serveRead(ctx context.Context, reply jsonrpc2.Replier, name string, uri protocol.DocumentURI, serve func(ctx context.Context) (interface{}, error)) {
+	before, tracked := s.documents.Get(uri)
+	s.goRequest(ctx, func(ctx context.Context) {
+		if placeholder, ok := reloadPlaceholders[name]; ok && s.reloading() && s.currentConfig().OmniSharp.DuringReload != duringReloadWait {
+			reply(ctx, placeholder(), nil)
+			return
+		}
+		s.awaitReload(ctx)
+		start := time.Now()
+		ctx, stats := withRequestStats(ctx)
+		result, err := serve(ctx)
+		if err == nil {
+			s.reportTiming(ctx, name, start, result, stats)
+		}
+		if after, ok := s.documents.Get(uri); err == nil && tracked && ok && after.Version != before.Version {
+			result, err = nil, errContentModified
+		}
+		reply(ctx, result, err)
+	})
 }
 
-func (s *Server) handleCompletion(params *protocol.CompletionParams) (*protocol.CompletionList, error) {
-	// Convert LSP completion params to OmniSharp format
-	omnisharpRequest := map[string]interface{}{
-		"Line":     params.Position.Line,
-		"Column":   params.Position.Character,
-		"FileName": params.TextDocument.URI.SpanURI().Filename(),
-	}
-
-	response, err := s.omnisharp.SendRequest("/autocomplete", omnisharpRequest)
-	if err != nil {
-		return nil, err
-	}
+// ServerCapabilities adds the 3.17 capabilities protocol.ServerCapabilities lacks
+type ServerCapabilities struct {
+	protocol.ServerCapabilities
+	InlayHintProvider *InlayHintOptions `json:"inlayHintProvider,omitempty"`
+}
 
-	// Parse OmniSharp response
-	var omnisharpResponse []struct {
-		CompletionText string `json:"CompletionText"`
-		DisplayText    string `json:"DisplayText"`
-		Documentation  string `json:"Documentation"`
-		Kind           string `json:"Kind"`
-	}
+// InitializeResult is protocol.InitializeResult with our ServerCapabilities
+type InitializeResult struct {
+	Capabilities ServerCapabilities `json:"capabilities"`
+}
 
-	if err := json.Unmarshal(response, &omnisharpResponse); err != nil {
-		return nil, err
+func (s *Server) handleInitialize(params *protocol.InitializeParams) (*InitializeResult, error) {
+	s.initialized = true
+	s.capabilities = params.Capabilities
+	s.tracer.setLevel(params.Trace)
+	s.rootPath = workspaceRoot(params)
+	s.locale = params.Locale
+	s.initializationOptions = params.InitializationOptions
+	config, err := resolveConfig(params.InitializationOptions, nil)
+	if err != nil {
+		log.Printf("invalid initializationOptions, using defaults: %v", err)
+		config, s.initializationOptions = DefaultConfig(), nil
 	}
+	s.config.Store(&config)
+	// Nothing can be evicted yet since no document has been opened
+	s.documents.SetCapacity(s.currentConfig().Documents.MaxTracked)
 
-	// Convert to LSP completion items
-	items := make([]protocol.CompletionItem, len(omnisharpResponse))
-	for i, item := range omnisharpResponse {
-		items[i] = protocol.CompletionItem{
-			Label:      item.DisplayText,
-			Detail:     item.Documentation,
-			Kind:       convertKind(item.Kind),
-			InsertText: item.CompletionText,
-		}
+	capabilities := ServerCapabilities{ServerCapabilities: protocol.ServerCapabilities{
+		CompletionProvider: &protocol.CompletionOptions{
+			TriggerCharacters: s.currentConfig().Completion.advertisedTriggerCharacters(),
+			ResolveProvider:   true,
+		},
+		TextDocumentSync: &protocol.TextDocumentSyncOptions{
+			Change:    protocol.TextDocumentSyncKindIncremental,
+			OpenClose: true,
+			// Organizes usings on save when configured; answered empty otherwise
+			WillSaveWaitUntil: true,
+		},
+	}}
+	// Without a solution only local completions work; the rest is registered once one appears
+	if hasSolution(s.rootPath) {
+		addSolutionCapabilities(&capabilities)
+	} else {
+		s.mu.Lock()
+		s.state = backendNoSolution
+		s.mu.Unlock()
 	}
 
-	return &protocol.CompletionList{
-		IsIncomplete: false,
-		Items:        items,
-	}, nil
+	return &InitializeResult{Capabilities: capabilities}, nil
 }
 
 func NewStdioStream() *StdioStream {
@@ -150,47 +627,3 @@ func (s *StdioStream) Close() error {
 	}
 	return s.out.Close()
 }
-
-func NewOmniSharpClient(baseURL string) *OmniSharpClient {
-	return &OmniSharpClient{
-		baseURL: baseURL,
-		client:  &http.Client{},
-	}
-}
-
-func (o *OmniSharpClient) SendRequest(endpoint string, request interface{}) ([]byte, error) {
-	jsonData, err := json.Marshal(request)
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest("POST", o.baseURL+endpoint, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := o.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	return ioutil.ReadAll(resp.Body)
-}
-
-func convertKind(omnisharpKind string) protocol.CompletionItemKind {
-	switch omnisharpKind {
-	case "Method":
-		return protocol.CompletionItemKindMethod
-	case "Property":
-		return protocol.CompletionItemKindProperty
-	case "Field":
-		return protocol.CompletionItemKindField
-	case "Class":
-		return protocol.CompletionItemKindClass
-	default:
-		return protocol.CompletionItemKindText
-	}
-}
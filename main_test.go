@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+	"go.uber.org/zap"
+)
+
+// fakeOmniSharp serves canned responses by endpoint, counting the requests it gets
+type fakeOmniSharp struct {
+	*httptest.Server
+	mu        sync.Mutex
+	responses map[string]interface{}
+	// handlers compute the responses of their endpoints, in place of responses
+	handlers map[string]func() interface{}
+	// delay holds every response back, as a busy OmniSharp does
+	delay  time.Duration
+	calls  map[string]int
+	bodies map[string][]json.RawMessage
+}
+
+func newFakeOmniSharp(t *testing.T, responses map[string]interface{}) *fakeOmniSharp {
+	t.Helper()
+	fake := &fakeOmniSharp{
+		responses: responses,
+		calls:     make(map[string]int),
+		bodies:    make(map[string][]json.RawMessage),
+	}
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fake.mu.Lock()
+		fake.calls[r.URL.Path]++
+		fake.bodies[r.URL.Path] = append(fake.bodies[r.URL.Path], body)
+		response, ok := fake.responses[r.URL.Path]
+		handler := fake.handlers[r.URL.Path]
+		delay := fake.delay
+		fake.mu.Unlock()
+		if handler != nil {
+			response, ok = handler(), true
+		}
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		if !ok {
+			response = struct{}{}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func (f *fakeOmniSharp) callCount(endpoint string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[endpoint]
+}
+
+func (f *fakeOmniSharp) setDelay(delay time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delay = delay
+}
+
+// setHandler has handler compute each response of endpoint. It runs unlocked, so it may ask
+// how many calls the fake got
+func (f *fakeOmniSharp) setHandler(endpoint string, handler func() interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.handlers == nil {
+		f.handlers = make(map[string]func() interface{})
+	}
+	f.handlers[endpoint] = handler
+}
+
+func (f *fakeOmniSharp) setResponse(endpoint string, response interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[endpoint] = response
+}
+
+// testClient records what the server sends the editor, answering its requests with null
+// unless told otherwise
+type testClient struct {
+	mu       sync.Mutex
+	messages []jsonrpc2.Request
+	answers  map[string]interface{}
+}
+
+// answer makes the client answer requests of method with result
+func (c *testClient) answer(method string, result interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.answers == nil {
+		c.answers = make(map[string]interface{})
+	}
+	c.answers[method] = result
+}
+
+func (c *testClient) received(method string) []json.RawMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var params []json.RawMessage
+	for _, message := range c.messages {
+		if message.Method() == method {
+			params = append(params, message.Params())
+		}
+	}
+	return params
+}
+
+// newTestServer returns an initialized server whose OmniSharp is fake, ready to serve, and
+// whose editor is a testClient
+func newTestServer(t *testing.T, fake *fakeOmniSharp) (*Server, *testClient) {
+	t.Helper()
+	s := newServer(DefaultConfig())
+	s.initialized = true
+	s.rootPath = t.TempDir()
+
+	serverEnd, clientEnd := net.Pipe()
+	serverConn := jsonrpc2.NewConn(jsonrpc2.NewStream(serverEnd))
+	clientConn := jsonrpc2.NewConn(jsonrpc2.NewStream(clientEnd))
+	client := &testClient{}
+	clientConn.Go(context.Background(), func(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+		client.mu.Lock()
+		client.messages = append(client.messages, req)
+		answer := client.answers[req.Method()]
+		client.mu.Unlock()
+		return reply(ctx, answer, nil)
+	})
+	serverConn.Go(context.Background(), func(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+		return reply(ctx, nil, nil)
+	})
+	t.Cleanup(func() {
+		serverConn.Close()
+		clientConn.Close()
+	})
+	s.conn = serverConn
+	s.client = protocol.ClientDispatcher(serverConn, zap.NewNop())
+	s.tracer = newTracer(serverConn)
+
+	if fake != nil {
+		s.omnisharp = NewOmniSharpClient(fake.URL, 5*time.Second, false)
+		s.state = backendReady
+	}
+	return s, client
+}
+
+// call sends the request method with params through handle, returning once it is answered
+func call(t *testing.T, s *Server, id int32, method string, params interface{}) (interface{}, error) {
+	t.Helper()
+	request, err := jsonrpc2.NewCall(jsonrpc2.NewNumberID(id), method, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type response struct {
+		result interface{}
+		err    error
+	}
+	replies := make(chan response, 1)
+	s.handle(context.Background(), func(ctx context.Context, result interface{}, err error) error {
+		replies <- response{result, err}
+		return nil
+	}, request)
+	answer := <-replies
+	return answer.result, answer.err
+}
+
+// configure changes the configuration of s the way settings do, by replacing it
+func configure(s *Server, change func(config *Config)) {
+	config := *s.currentConfig()
+	change(&config)
+	s.config.Store(&config)
+}
+
+// openTestDocument opens text as uri the way didOpen does
+func openTestDocument(s *Server, uri protocol.DocumentURI, text string) {
+	s.handleDidOpen(context.Background(), &protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{URI: uri, LanguageID: "csharp", Version: 1, Text: text},
+	})
+}
+
+// testURI is the URI of a file named name in the server's workspace
+func testURI(s *Server, name string) protocol.DocumentURI {
+	return pathToURI(s.rootPath + "/" + name)
+}
+
+// TestSlowRequestsLeaveTheReadLoop checks handle returns before OmniSharp answers the requests
+// it is slow at, so the edits queued behind them aren't held up, and $/cancelRequest answers them
+func TestSlowRequestsLeaveTheReadLoop(t *testing.T) {
+	const delay = 2 * time.Second
+	fake := newFakeOmniSharp(t, map[string]interface{}{})
+	s, _ := newTestServer(t, fake)
+	configure(s, func(config *Config) { config.Usings.OrganizeOnSave = true })
+	uri := testURI(s, "Player.cs")
+	openTestDocument(s, uri, "class Player { void Update() { Move(1); } }\n")
+	fake.setDelay(delay)
+
+	document := protocol.TextDocumentIdentifier{URI: uri}
+	position := protocol.Position{Line: 0, Character: 36}
+	tests := []struct {
+		method string
+		params interface{}
+	}{
+		{protocol.MethodTextDocumentCodeAction, protocol.CodeActionParams{
+			TextDocument: document,
+			Context: protocol.CodeActionContext{Diagnostics: []protocol.Diagnostic{
+				{Code: "CS0103", Message: "The name 'Move' does not exist in the current context"},
+			}},
+		}},
+		{protocol.MethodTextDocumentSignatureHelp, protocol.SignatureHelpParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{TextDocument: document, Position: position},
+		}},
+		{protocol.MethodTextDocumentFormatting, protocol.DocumentFormattingParams{TextDocument: document}},
+		{protocol.MethodTextDocumentWillSaveWaitUntil, protocol.WillSaveTextDocumentParams{
+			TextDocument: document, Reason: protocol.TextDocumentSaveReasonManual,
+		}},
+	}
+	for i, test := range tests {
+		t.Run(test.method, func(t *testing.T) {
+			id := jsonrpc2.NewNumberID(int32(i + 1))
+			call, err := jsonrpc2.NewCall(id, test.method, test.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			replies := make(chan error, 1)
+			reply := func(ctx context.Context, result interface{}, err error) error {
+				replies <- err
+				return nil
+			}
+
+			start := time.Now()
+			if err := s.handle(context.Background(), reply, call); err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed > delay/4 {
+				t.Fatalf("handle took %v, waiting for OmniSharp", elapsed)
+			}
+
+			cancel, err := jsonrpc2.NewNotification(protocol.MethodCancelRequest, protocol.CancelParams{ID: float64(i + 1)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.handle(context.Background(), func(context.Context, interface{}, error) error { return nil }, cancel)
+			select {
+			case err := <-replies:
+				// Handlers that swallow OmniSharp's errors answer nothing instead
+				var wireErr *jsonrpc2.Error
+				if err != nil && (!errors.As(err, &wireErr) || wireErr.Code != protocol.CodeRequestCancelled) {
+					t.Errorf("reply error = %v, want RequestCancelled", err)
+				}
+			case <-time.After(delay / 2):
+				t.Fatal("the request wasn't answered once cancelled")
+			}
+		})
+	}
+}
+
+// TestReadRequestsContentModified checks reads of a document edited while OmniSharp answers
+// them reply ContentModified rather than describe the buffer as it was
+func TestReadRequestsContentModified(t *testing.T) {
+	const text = "class Player { float speed; void Update() { speed = 1; } }\n"
+	position := protocol.TextDocumentPositionParams{Position: protocol.Position{Line: 0, Character: 46}}
+	tests := []struct {
+		method   string
+		endpoint string
+		response interface{}
+		params   func(uri protocol.DocumentURI) interface{}
+	}{
+		{protocol.MethodTextDocumentHover, "/typelookup", TypeLookupResponse{Type: "float Player.speed"}, func(uri protocol.DocumentURI) interface{} {
+			position.TextDocument.URI = uri
+			return protocol.HoverParams{TextDocumentPositionParams: position}
+		}},
+		{protocol.MethodTextDocumentDefinition, "/v2/gotodefinition", definitionResponse("/project/Assets/Player.cs"), func(uri protocol.DocumentURI) interface{} {
+			position.TextDocument.URI = uri
+			return protocol.DefinitionParams{TextDocumentPositionParams: position}
+		}},
+		{protocol.MethodTextDocumentCompletion, "/autocomplete", autoCompleteItems("speed"), func(uri protocol.DocumentURI) interface{} {
+			position.TextDocument.URI = uri
+			return protocol.CompletionParams{
+				TextDocumentPositionParams: position,
+				Context:                    &protocol.CompletionContext{TriggerKind: protocol.CompletionTriggerKindInvoked},
+			}
+		}},
+	}
+	for _, test := range tests {
+		for _, edited := range []bool{false, true} {
+			name := test.method
+			if edited {
+				name += " edited"
+			}
+			t.Run(name, func(t *testing.T) {
+				fake := newFakeOmniSharp(t, nil)
+				s, _ := newTestServer(t, fake)
+				uri := testURI(s, "Player.cs")
+				openTestDocument(s, uri, text)
+				fake.setHandler(test.endpoint, func() interface{} {
+					if edited {
+						typeAt(s, uri, 2, protocol.Position{Line: 1}, "\n")
+					}
+					return test.response
+				})
+
+				result, err := call(t, s, 1, test.method, test.params(uri))
+				if edited {
+					var wireErr *jsonrpc2.Error
+					if !errors.As(err, &wireErr) || wireErr.Code != protocol.CodeContentModified {
+						t.Errorf("reply = %v, %v; want ContentModified", result, err)
+					}
+					return
+				}
+				if err != nil || result == nil {
+					t.Errorf("reply = %v, %v; want a result", result, err)
+				}
+			})
+		}
+	}
+}
+
+// TestUnknownMethods checks a request the server doesn't know is answered MethodNotFound, so
+// the client isn't left waiting, while such a notification is ignored without a reply
+func TestUnknownMethods(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		notification bool
+		// trace is the trace level set, at verbose of which ignored notifications are logged
+		trace protocol.TraceValue
+	}{
+		{"request", "unity/unknownRequest", false, protocol.TraceOff},
+		{"$/ request", "$/unknownRequest", false, protocol.TraceOff},
+		{"notification", "unity/unknownNotification", true, protocol.TraceOff},
+		{"$/ notification", "$/unknownNotification", true, protocol.TraceOff},
+		{"notification traced", "$/unknownNotification", true, protocol.TraceMessage},
+		{"notification traced verbosely", "$/unknownNotification", true, protocol.TraceVerbose},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, _ := newTestServer(t, newFakeOmniSharp(t, nil))
+			s.tracer.setLevel(test.trace)
+			var logged bytes.Buffer
+			log.SetOutput(&logged)
+			t.Cleanup(func() { log.SetOutput(os.Stderr) })
+			var request jsonrpc2.Request
+			var err error
+			if test.notification {
+				request, err = jsonrpc2.NewNotification(test.method, map[string]interface{}{})
+			} else {
+				request, err = jsonrpc2.NewCall(jsonrpc2.NewNumberID(1), test.method, map[string]interface{}{})
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var replies []error
+			reply := func(ctx context.Context, result interface{}, err error) error {
+				replies = append(replies, err)
+				return nil
+			}
+			if err := s.handle(context.Background(), reply, request); err != nil {
+				t.Fatal(err)
+			}
+
+			if test.notification {
+				if len(replies) != 0 {
+					t.Errorf("replied %v to a notification", replies)
+				}
+				if ignored := strings.Contains(logged.String(), "ignoring notification"); ignored != (test.trace == protocol.TraceVerbose) {
+					t.Errorf("logged %q at trace level %s", logged.String(), test.trace)
+				}
+				return
+			}
+			var wireErr *jsonrpc2.Error
+			if len(replies) != 1 || !errors.As(replies[0], &wireErr) || wireErr.Code != jsonrpc2.MethodNotFound {
+				t.Errorf("replies = %v, want one MethodNotFound", replies)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"strings"
+)
+
+// messageCatalogs translate the messages the server writes itself, keyed by language and then
+// by the English message, formatting verbs included. Unity's menus keep their English names,
+// as the editor shows them in English in these languages
+var messageCatalogs = map[string]map[string]string{
+	"de": {
+		"Unity LSP: %s timed out. OmniSharp may be busy or still loading the solution; try again shortly.": "Unity LSP: Zeitüberschreitung bei %s. OmniSharp ist eventuell ausgelastet oder lädt die Solution noch; bitte gleich noch einmal versuchen.",
+		"Unity LSP: %s failed because OmniSharp could not be reached.":                                     "Unity LSP: %s fehlgeschlagen, da OmniSharp nicht erreichbar ist.",
+		"Go to definition":        "Gehe zu Definition",
+		"Find references":         "Verweise suchen",
+		"Go to implementation":    "Gehe zu Implementierung",
+		"Workspace symbol search": "Symbolsuche im Arbeitsbereich",
+		"Rename":                  "Umbenennen",
+		"Formatting":              "Formatierung",
+		"Unity LSP: %s. C# features are unavailable until the server is restarted.": "Unity LSP: %s. C#-Funktionen sind bis zum Neustart des Servers nicht verfügbar.",
+		"OmniSharp did not finish loading the solution within %s":                   "OmniSharp hat die Solution nicht innerhalb von %s geladen",
+		"the OmniSharp of server %d did not become ready":                           "das OmniSharp von Server %d wurde nicht bereit",
+		"Unity LSP: another server (process %d) is already running for this workspace, so its OmniSharp is shared. Running several usually means the editor starts the server twice.": "Unity LSP: Für diesen Arbeitsbereich läuft bereits ein anderer Server (Prozess %d), dessen OmniSharp mitbenutzt wird. Mehrere Server bedeuten meist, dass der Editor den Server doppelt startet.",
+		"Unity LSP: %s contains several solutions. Which one should OmniSharp load?":                                                                                                  "Unity LSP: %s enthält mehrere Solutions. Welche soll OmniSharp laden?",
+		"Unity LSP: no .sln or .csproj found in %s. Open the folder containing your Unity project, or run Assets > Open C# Project in Unity to generate solution files.":              "Unity LSP: In %s wurde keine .sln- oder .csproj-Datei gefunden. Öffne den Ordner mit deinem Unity-Projekt oder erzeuge die Solution-Dateien in Unity mit Assets > Open C# Project.",
+		"Unity LSP: OmniSharp %s is older than %s, the oldest release supported, so some features will fail. Update OmniSharp, or point omnisharp.path at a newer one.":               "Unity LSP: OmniSharp %s ist älter als %s, die älteste unterstützte Version, daher werden einige Funktionen fehlschlagen. Aktualisieren Sie OmniSharp oder verweisen Sie omnisharp.path auf eine neuere Version.",
+		"Unity LSP: OmniSharp %s is newer than the releases supported, before %s, so some features may fail.":                                                                         "Unity LSP: OmniSharp %s ist neuer als die unterstützten Versionen vor %s, daher können einige Funktionen fehlschlagen.",
+		"Restart the server once they exist.": "Starte den Server neu, sobald sie existieren.",
+		"Unity LSP: OmniSharp restarted.":     "Unity LSP: OmniSharp wurde neu gestartet.",
+		"Unity LSP: the OmniSharp settings changed, which takes restarting OmniSharp. Restart it now?":                                "Unity LSP: Die OmniSharp-Einstellungen haben sich geändert, wofür OmniSharp neu gestartet werden muss. Jetzt neu starten?",
+		"Unity LSP: OmniSharp found no definition of %s, so this is a symbol of the same name in %s, which may not be the one meant.": "Unity LSP: OmniSharp hat keine Definition von %s gefunden. Dies ist ein gleichnamiges Symbol in %s, das eventuell nicht das gemeinte ist.",
+		"Apply \"%s\" to the entire solution? This may change many files.":                                                            "\"%s\" auf die gesamte Solution anwenden? Dabei können sich viele Dateien ändern.",
+		"Restart": "Neu starten",
+		"Apply":   "Anwenden",
+		"Cancel":  "Abbrechen",
+
+		"Force Unity to serialize a private field.":                                              "Lässt Unity ein privates Feld serialisieren.",
+		"Hide a serialized field in the Inspector.":                                              "Blendet ein serialisiertes Feld im Inspector aus.",
+		"Add a header above fields in the Inspector.":                                            "Fügt im Inspector eine Überschrift über Feldern hinzu.",
+		"Show a tooltip for a field in the Inspector.":                                           "Zeigt im Inspector einen Tooltip für ein Feld an.",
+		"Clamp a float or int field to a range in the Inspector.":                                "Beschränkt ein float- oder int-Feld im Inspector auf einen Bereich.",
+		"Add spacing above a field in the Inspector.":                                            "Fügt im Inspector Abstand über einem Feld hinzu.",
+		"Edit a string field with a multi-line text area.":                                       "Bearbeitet ein string-Feld in einem mehrzeiligen Textbereich.",
+		"Automatically add required components as dependencies.":                                 "Fügt benötigte Komponenten automatisch als Abhängigkeiten hinzu.",
+		"Prevent the MonoBehaviour from being added more than once to a GameObject.":             "Verhindert, dass das MonoBehaviour einem GameObject mehrfach hinzugefügt wird.",
+		"Add a ScriptableObject to the Assets/Create menu.":                                      "Fügt ein ScriptableObject dem Menü Assets/Create hinzu.",
+		"Call a static method when the runtime has loaded, at the chosen load stage.":            "Ruft eine statische Methode auf, sobald die Laufzeit geladen ist, in der gewählten Ladephase.",
+		"Run the script's callbacks in Edit Mode and Play Mode.":                                 "Führt die Callbacks des Skripts im Edit Mode und im Play Mode aus.",
+		"Run the script's callbacks in Edit Mode. Prefer ExecuteAlways for prefab mode support.": "Führt die Callbacks des Skripts im Edit Mode aus. ExecuteAlways unterstützt zusätzlich den Prefab Mode.",
+		"Run the class's static constructor when the editor loads or scripts recompile.":         "Führt den statischen Konstruktor der Klasse aus, wenn der Editor lädt oder Skripte neu kompiliert werden.",
+		"Call a static method when the editor loads or scripts recompile.":                       "Ruft eine statische Methode auf, wenn der Editor lädt oder Skripte neu kompiliert werden.",
+		"Add a static method to the editor's main menu, e.g. [MenuItem(\"Tools/My Tool\")].":     "Fügt eine statische Methode dem Hauptmenü des Editors hinzu, z. B. [MenuItem(\"Tools/My Tool\")].",
+	},
+	"fr": {
+		"Unity LSP: %s timed out. OmniSharp may be busy or still loading the solution; try again shortly.": "Unity LSP : %s a expiré. OmniSharp est peut-être occupé ou charge encore la solution ; réessayez dans un instant.",
+		"Unity LSP: %s failed because OmniSharp could not be reached.":                                     "Unity LSP : %s a échoué, car OmniSharp est injoignable.",
+		"Go to definition":        "Atteindre la définition",
+		"Find references":         "Rechercher les références",
+		"Go to implementation":    "Atteindre l'implémentation",
+		"Workspace symbol search": "Recherche de symboles",
+		"Rename":                  "Renommer",
+		"Formatting":              "Mise en forme",
+		"Unity LSP: %s. C# features are unavailable until the server is restarted.": "Unity LSP : %s. Les fonctionnalités C# sont indisponibles jusqu'au redémarrage du serveur.",
+		"OmniSharp did not finish loading the solution within %s":                   "OmniSharp n'a pas fini de charger la solution en %s",
+		"the OmniSharp of server %d did not become ready":                           "l'OmniSharp du serveur %d n'est pas devenu prêt",
+		"Unity LSP: another server (process %d) is already running for this workspace, so its OmniSharp is shared. Running several usually means the editor starts the server twice.": "Unity LSP : un autre serveur (processus %d) tourne déjà pour cet espace de travail, son OmniSharp est donc partagé. Plusieurs serveurs signifient souvent que l'éditeur lance le serveur deux fois.",
+		"Unity LSP: %s contains several solutions. Which one should OmniSharp load?":                                                                                                  "Unity LSP : %s contient plusieurs solutions. Laquelle OmniSharp doit-il charger ?",
+		"Unity LSP: no .sln or .csproj found in %s. Open the folder containing your Unity project, or run Assets > Open C# Project in Unity to generate solution files.":              "Unity LSP : aucun .sln ni .csproj trouvé dans %s. Ouvrez le dossier de votre projet Unity, ou lancez Assets > Open C# Project dans Unity pour générer les fichiers de solution.",
+		"Unity LSP: OmniSharp %s is older than %s, the oldest release supported, so some features will fail. Update OmniSharp, or point omnisharp.path at a newer one.":               "Unity LSP : OmniSharp %s est antérieur à %s, la plus ancienne version prise en charge, certaines fonctionnalités échoueront donc. Mettez OmniSharp à jour ou faites pointer omnisharp.path vers une version plus récente.",
+		"Unity LSP: OmniSharp %s is newer than the releases supported, before %s, so some features may fail.":                                                                         "Unity LSP : OmniSharp %s est plus récent que les versions prises en charge, antérieures à %s, certaines fonctionnalités peuvent donc échouer.",
+		"Restart the server once they exist.": "Redémarrez le serveur une fois qu'ils existent.",
+		"Unity LSP: OmniSharp restarted.":     "Unity LSP : OmniSharp a redémarré.",
+		"Unity LSP: the OmniSharp settings changed, which takes restarting OmniSharp. Restart it now?":                                "Unity LSP : les paramètres d'OmniSharp ont changé, ce qui demande de redémarrer OmniSharp. Le redémarrer maintenant ?",
+		"Unity LSP: OmniSharp found no definition of %s, so this is a symbol of the same name in %s, which may not be the one meant.": "Unity LSP : OmniSharp n'a trouvé aucune définition de %s ; voici un symbole du même nom dans %s, qui n'est peut-être pas celui voulu.",
+		"Apply \"%s\" to the entire solution? This may change many files.":                                                            "Appliquer « %s » à toute la solution ? De nombreux fichiers peuvent être modifiés.",
+		"Restart": "Redémarrer",
+		"Apply":   "Appliquer",
+		"Cancel":  "Annuler",
+
+		"Force Unity to serialize a private field.":                                              "Force Unity à sérialiser un champ privé.",
+		"Hide a serialized field in the Inspector.":                                              "Masque un champ sérialisé dans l'Inspector.",
+		"Add a header above fields in the Inspector.":                                            "Ajoute un en-tête au-dessus de champs dans l'Inspector.",
+		"Show a tooltip for a field in the Inspector.":                                           "Affiche une info-bulle pour un champ dans l'Inspector.",
+		"Clamp a float or int field to a range in the Inspector.":                                "Limite un champ float ou int à une plage dans l'Inspector.",
+		"Add spacing above a field in the Inspector.":                                            "Ajoute un espace au-dessus d'un champ dans l'Inspector.",
+		"Edit a string field with a multi-line text area.":                                       "Édite un champ string dans une zone de texte multiligne.",
+		"Automatically add required components as dependencies.":                                 "Ajoute automatiquement les composants requis comme dépendances.",
+		"Prevent the MonoBehaviour from being added more than once to a GameObject.":             "Empêche d'ajouter le MonoBehaviour plus d'une fois à un GameObject.",
+		"Add a ScriptableObject to the Assets/Create menu.":                                      "Ajoute un ScriptableObject au menu Assets/Create.",
+		"Call a static method when the runtime has loaded, at the chosen load stage.":            "Appelle une méthode statique une fois le runtime chargé, à l'étape de chargement choisie.",
+		"Run the script's callbacks in Edit Mode and Play Mode.":                                 "Exécute les callbacks du script en Edit Mode et en Play Mode.",
+		"Run the script's callbacks in Edit Mode. Prefer ExecuteAlways for prefab mode support.": "Exécute les callbacks du script en Edit Mode. Préférez ExecuteAlways pour la prise en charge du Prefab Mode.",
+		"Run the class's static constructor when the editor loads or scripts recompile.":         "Exécute le constructeur statique de la classe au chargement de l'éditeur ou à la recompilation des scripts.",
+		"Call a static method when the editor loads or scripts recompile.":                       "Appelle une méthode statique au chargement de l'éditeur ou à la recompilation des scripts.",
+		"Add a static method to the editor's main menu, e.g. [MenuItem(\"Tools/My Tool\")].":     "Ajoute une méthode statique au menu principal de l'éditeur, par ex. [MenuItem(\"Tools/My Tool\")].",
+	},
+	"es": {
+		"Unity LSP: %s timed out. OmniSharp may be busy or still loading the solution; try again shortly.": "Unity LSP: se agotó el tiempo de espera de %s. Puede que OmniSharp esté ocupado o aún cargando la solución; vuelve a intentarlo en breve.",
+		"Unity LSP: %s failed because OmniSharp could not be reached.":                                     "Unity LSP: %s falló porque no se pudo contactar con OmniSharp.",
+		"Go to definition":        "Ir a la definición",
+		"Find references":         "Buscar referencias",
+		"Go to implementation":    "Ir a la implementación",
+		"Workspace symbol search": "Búsqueda de símbolos",
+		"Rename":                  "Cambiar nombre",
+		"Formatting":              "Formateo",
+		"Unity LSP: %s. C# features are unavailable until the server is restarted.": "Unity LSP: %s. Las funciones de C# no estarán disponibles hasta que se reinicie el servidor.",
+		"OmniSharp did not finish loading the solution within %s":                   "OmniSharp no terminó de cargar la solución en %s",
+		"the OmniSharp of server %d did not become ready":                           "el OmniSharp del servidor %d no llegó a estar listo",
+		"Unity LSP: another server (process %d) is already running for this workspace, so its OmniSharp is shared. Running several usually means the editor starts the server twice.": "Unity LSP: ya hay otro servidor (proceso %d) en ejecución para este espacio de trabajo, así que se comparte su OmniSharp. Varios servidores suelen indicar que el editor inicia el servidor dos veces.",
+		"Unity LSP: %s contains several solutions. Which one should OmniSharp load?":                                                                                                  "Unity LSP: %s contiene varias soluciones. ¿Cuál debe cargar OmniSharp?",
+		"Unity LSP: no .sln or .csproj found in %s. Open the folder containing your Unity project, or run Assets > Open C# Project in Unity to generate solution files.":              "Unity LSP: no se encontró ningún .sln ni .csproj en %s. Abre la carpeta de tu proyecto de Unity o ejecuta Assets > Open C# Project en Unity para generar los archivos de solución.",
+		"Unity LSP: OmniSharp %s is older than %s, the oldest release supported, so some features will fail. Update OmniSharp, or point omnisharp.path at a newer one.":               "Unity LSP: OmniSharp %s es anterior a %s, la versión más antigua compatible, por lo que algunas funciones fallarán. Actualiza OmniSharp o haz que omnisharp.path apunte a una versión más reciente.",
+		"Unity LSP: OmniSharp %s is newer than the releases supported, before %s, so some features may fail.":                                                                         "Unity LSP: OmniSharp %s es más reciente que las versiones compatibles, anteriores a %s, por lo que algunas funciones pueden fallar.",
+		"Restart the server once they exist.": "Reinicia el servidor cuando existan.",
+		"Unity LSP: OmniSharp restarted.":     "Unity LSP: OmniSharp se ha reiniciado.",
+		"Unity LSP: the OmniSharp settings changed, which takes restarting OmniSharp. Restart it now?":                                "Unity LSP: la configuración de OmniSharp cambió, lo que requiere reiniciar OmniSharp. ¿Reiniciarlo ahora?",
+		"Unity LSP: OmniSharp found no definition of %s, so this is a symbol of the same name in %s, which may not be the one meant.": "Unity LSP: OmniSharp no encontró ninguna definición de %s; este es un símbolo con el mismo nombre en %s, que puede no ser el buscado.",
+		"Apply \"%s\" to the entire solution? This may change many files.":                                                            "¿Aplicar \"%s\" a toda la solución? Esto puede modificar muchos archivos.",
+		"Restart": "Reiniciar",
+		"Apply":   "Aplicar",
+		"Cancel":  "Cancelar",
+
+		"Force Unity to serialize a private field.":                                              "Obliga a Unity a serializar un campo privado.",
+		"Hide a serialized field in the Inspector.":                                              "Oculta un campo serializado en el Inspector.",
+		"Add a header above fields in the Inspector.":                                            "Añade un encabezado sobre campos en el Inspector.",
+		"Show a tooltip for a field in the Inspector.":                                           "Muestra una descripción emergente para un campo en el Inspector.",
+		"Clamp a float or int field to a range in the Inspector.":                                "Limita un campo float o int a un rango en el Inspector.",
+		"Add spacing above a field in the Inspector.":                                            "Añade espacio sobre un campo en el Inspector.",
+		"Edit a string field with a multi-line text area.":                                       "Edita un campo string con un área de texto de varias líneas.",
+		"Automatically add required components as dependencies.":                                 "Añade automáticamente los componentes necesarios como dependencias.",
+		"Prevent the MonoBehaviour from being added more than once to a GameObject.":             "Impide añadir el MonoBehaviour más de una vez a un GameObject.",
+		"Add a ScriptableObject to the Assets/Create menu.":                                      "Añade un ScriptableObject al menú Assets/Create.",
+		"Call a static method when the runtime has loaded, at the chosen load stage.":            "Llama a un método estático cuando se ha cargado el runtime, en la fase de carga elegida.",
+		"Run the script's callbacks in Edit Mode and Play Mode.":                                 "Ejecuta los callbacks del script en Edit Mode y en Play Mode.",
+		"Run the script's callbacks in Edit Mode. Prefer ExecuteAlways for prefab mode support.": "Ejecuta los callbacks del script en Edit Mode. Usa ExecuteAlways para admitir el Prefab Mode.",
+		"Run the class's static constructor when the editor loads or scripts recompile.":         "Ejecuta el constructor estático de la clase cuando se carga el editor o se recompilan los scripts.",
+		"Call a static method when the editor loads or scripts recompile.":                       "Llama a un método estático cuando se carga el editor o se recompilan los scripts.",
+		"Add a static method to the editor's main menu, e.g. [MenuItem(\"Tools/My Tool\")].":     "Añade un método estático al menú principal del editor, p. ej. [MenuItem(\"Tools/My Tool\")].",
+	},
+}
+
+// localize translates message into locale, a BCP 47 tag such as de-CH that falls back to its
+// language, and to English for languages or messages without a translation
+func localize(locale, message string) string {
+	tag := strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	for tag != "" {
+		if translated, ok := messageCatalogs[tag][message]; ok {
+			return translated
+		}
+		cut := strings.LastIndexByte(tag, '-')
+		if cut < 0 {
+			break
+		}
+		tag = tag[:cut]
+	}
+	return message
+}
+
+// localize translates message into the locale the client shows its user interface in
+func (s *Server) localize(message string) string {
+	return localize(s.locale, message)
+}
+
+// omnisharpLocale is the environment choosing the language of OmniSharp's diagnostics, whose
+// messages .NET and Mono take from LC_MESSAGES unless LC_ALL overrides it. Windows reads the
+// language from the system, so this has no effect there
+func omnisharpLocale(locale string) []string {
+	if locale == "" {
+		return nil
+	}
+	return []string{"LC_MESSAGES=" + strings.ReplaceAll(locale, "-", "_") + ".UTF-8"}
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+// SymbolLocation is a QuickFix describing a symbol, as returned by /findsymbols
+type SymbolLocation struct {
+	QuickFix
+	Kind                 string `json:"Kind"`
+	ContainingSymbolName string `json:"ContainingSymbolName"`
+}
+
+// GoToDefinitionResponse is OmniSharp's /v2/gotodefinition response. Definitions in metadata
+// have no FileName
+type GoToDefinitionResponse struct {
+	Definitions []struct {
+		Location struct {
+			FileName string         `json:"FileName"`
+			Range    omnisharpRange `json:"Range"`
+		} `json:"Location"`
+	} `json:"Definitions"`
+}
+
+// goToDefinition asks OmniSharp for the definitions of the symbol at pos, from
+// /gotodefinition on releases before /v2/gotodefinition, which return one at most
+func (c *OmniSharpClient) goToDefinition(ctx context.Context, uri protocol.DocumentURI, pos protocol.Position) (GoToDefinitionResponse, error) {
+	var definitions GoToDefinitionResponse
+	if !c.version.before(goToDefinitionV2Version) {
+		response, err := c.SendRequest(ctx, "/v2/gotodefinition", omnisharpPosition(uri, pos))
+		if err != nil {
+			return definitions, err
+		}
+		err = json.Unmarshal(response, &definitions)
+		return definitions, err
+	}
+
+	response, err := c.SendRequest(ctx, "/gotodefinition", omnisharpPosition(uri, pos))
+	if err != nil {
+		return definitions, err
+	}
+	var definition struct {
+		FileName string `json:"FileName"`
+		Line     uint32 `json:"Line"`
+		Column   uint32 `json:"Column"`
+	}
+	if err := json.Unmarshal(response, &definition); err != nil || definition.FileName == "" {
+		return definitions, err
+	}
+	definitions.Definitions = make([]struct {
+		Location struct {
+			FileName string         `json:"FileName"`
+			Range    omnisharpRange `json:"Range"`
+		} `json:"Location"`
+	}, 1)
+	location := &definitions.Definitions[0].Location
+	location.FileName = definition.FileName
+	location.Range.Start.Line, location.Range.Start.Column = definition.Line, definition.Column
+	location.Range.End = location.Range.Start
+	return definitions, nil
+}
+
+// omnisharpPosition builds the common FileName/Line/Column part of OmniSharp requests
+func omnisharpPosition(uri protocol.DocumentURI, pos protocol.Position) map[string]interface{} {
+	return map[string]interface{}{
+		"FileName": uri.Filename(),
+		"Line":     pos.Line,
+		"Column":   pos.Character,
+	}
+}
+
+// omnisharpRange is the v2 endpoints' range format
+type omnisharpRange struct {
+	Start struct {
+		Line   uint32 `json:"Line"`
+		Column uint32 `json:"Column"`
+	} `json:"Start"`
+	End struct {
+		Line   uint32 `json:"Line"`
+		Column uint32 `json:"Column"`
+	} `json:"End"`
+}
+
+func (r omnisharpRange) toProtocol() protocol.Range {
+	return protocol.Range{
+		Start: protocol.Position{Line: r.Start.Line, Character: r.Start.Column},
+		End:   protocol.Position{Line: r.End.Line, Character: r.End.Column},
+	}
+}
+
+// handleDefinition returns every declaration OmniSharp finds, e.g. both halves of a partial
+// method, so the editor can offer a picker. Beyond identifiers it goes to the namespace of a
+// using directive and the method a string names in Invoke and the like; preprocessor symbols
+// have nowhere to go
+func (s *Server) handleDefinition(ctx context.Context, params *protocol.DefinitionParams) ([]protocol.Location, error) {
+	omnisharp := s.backend()
+	if omnisharp == nil || !s.atIdentifier(params.TextDocument.URI, params.Position) {
+		return nil, nil
+	}
+	doc, open := s.documents.Get(params.TextDocument.URI)
+	if open {
+		if onPreprocessorLine(doc.Text, params.Position) {
+			return nil, nil
+		}
+		if name, ok := stringMethodAt(doc.Text, params.Position); ok {
+			return s.methodDefinitions(ctx, omnisharp, params.TextDocument.URI, name)
+		}
+	}
+
+	omnisharpResponse, err := omnisharp.goToDefinition(ctx, params.TextDocument.URI, params.Position)
+	if err != nil {
+		return nil, err
+	}
+
+	var locations []protocol.Location
+	seen := make(map[protocol.Location]bool)
+	for _, definition := range omnisharpResponse.Definitions {
+		// Definitions in metadata have no file we can point the editor at
+		if definition.Location.FileName == "" {
+			continue
+		}
+		location := protocol.Location{
+			URI:   pathToURI(s.resolveOmniSharpPath(definition.Location.FileName)),
+			Range: definition.Location.Range.toProtocol(),
+		}
+		if !seen[location] {
+			seen[location] = true
+			locations = append(locations, location)
+		}
+	}
+
+	// OmniSharp has no single declaration to offer for the namespace of a using directive
+	if len(locations) == 0 && open {
+		if namespace, ok := usingNamespaceAt(doc.Text, params.Position); ok {
+			locations = s.namespaceDefinition(ctx, namespace)
+		} else if s.currentConfig().Navigation.SymbolSearchFallback && s.inCode(doc.URI, params.Position) {
+			locations = s.symbolSearchDefinition(ctx, omnisharp, doc, params.Position)
+		}
+	}
+
+	if len(locations) == 1 && s.currentConfig().Diagnostics.WarmDefinitionTargets {
+		// Replying cancels ctx, which the warm-up outlives
+		go s.warmDefinitionTarget(context.WithoutCancel(ctx), locations[0].URI)
+	}
+	return locations, nil
+}
+
+// warmTimeout bounds warming a definition target, which no request waits for
+const warmTimeout = 30 * time.Second
+
+// warmDefinitionTarget computes diagnostics for the file a definition jump is about to open,
+// so the Problems panel is accurate by the time the editor shows it. With several definitions
+// the user picks one, so nothing is warmed
+func (s *Server) warmDefinitionTarget(ctx context.Context, uri protocol.DocumentURI) {
+	ctx, cancel := context.WithTimeout(ctx, warmTimeout)
+	defer cancel()
+
+	if _, ok := s.documents.Get(uri); ok || (!s.currentConfig().Generated.Diagnostics && s.isGenerated(uri)) {
+		return
+	}
+
+	text, err := os.ReadFile(uri.Filename())
+	if err != nil {
+		log.Printf("failed to read definition target %s: %v", uri, err)
+		return
+	}
+
+	warmed, evicted := s.documents.Warm(uri, string(text))
+	s.forgetDocuments(ctx, evicted)
+	if warmed {
+		s.scheduleDiagnostics(uri)
+	}
+}
+
+// ReferenceParams are the params of textDocument/references, which may carry a scope
+// overriding navigation.referencesScope
+type ReferenceParams struct {
+	protocol.ReferenceParams
+	Scope string `json:"scope,omitempty"`
+}
+
+// handleReferences finds the usages of the symbol at the position. Scoped to the project, those
+// in other projects are left out; OmniSharp has no way to search a single project, so this
+// shortens the list rather than the search
+func (s *Server) handleReferences(ctx context.Context, params *ReferenceParams) ([]protocol.Location, error) {
+	omnisharp := s.backend()
+	if omnisharp == nil || !s.atIdentifier(params.TextDocument.URI, params.Position) {
+		return nil, nil
+	}
+
+	request := omnisharpPosition(params.TextDocument.URI, params.Position)
+	request["OnlyThisFile"] = false
+	request["ExcludeDefinition"] = !params.Context.IncludeDeclaration
+	locations, err := s.quickFixLocations(ctx, omnisharp, "/findusages", request)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := params.Scope
+	if scope == "" {
+		scope = s.currentConfig().Navigation.ReferencesScope
+	}
+	if scope == referencesScopeProject {
+		locations = s.inProjectOf(params.TextDocument.URI, locations)
+	}
+	return mergeLocations(locations), nil
+}
+
+// inProjectOf keeps the locations in the project compiling uri. Locations are kept whole while
+// the projects aren't indexed, or uri is in none of them
+func (s *Server) inProjectOf(uri protocol.DocumentURI, locations []protocol.Location) []protocol.Location {
+	project, ok := s.projects.project(uri.Filename())
+	if !ok {
+		return locations
+	}
+	kept := locations[:0]
+	for _, location := range locations {
+		if other, ok := s.projects.project(location.URI.Filename()); ok && other == project {
+			kept = append(kept, location)
+		}
+	}
+	return kept
+}
+
+// mergeLocations orders locations by file and position, merging those that overlap. OmniSharp
+// can report a usage twice, or both an identifier and a node containing it
+func mergeLocations(locations []protocol.Location) []protocol.Location {
+	sort.SliceStable(locations, func(i, j int) bool {
+		a, b := locations[i], locations[j]
+		if a.URI != b.URI {
+			return a.URI < b.URI
+		}
+		return positionBefore(a.Range.Start, b.Range.Start)
+	})
+
+	merged := locations[:0]
+	for _, location := range locations {
+		if n := len(merged); n > 0 && merged[n-1].URI == location.URI && !positionBefore(merged[n-1].Range.End, location.Range.Start) {
+			last := &merged[n-1]
+			if positionBefore(last.Range.End, location.Range.End) {
+				last.Range.End = location.Range.End
+			}
+			continue
+		}
+		merged = append(merged, location)
+	}
+	return merged
+}
+
+func positionBefore(a, b protocol.Position) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Character < b.Character)
+}
+
+func (s *Server) handleImplementation(ctx context.Context, params *protocol.ImplementationParams) ([]protocol.Location, error) {
+	omnisharp := s.backend()
+	if omnisharp == nil {
+		return nil, nil
+	}
+
+	return s.quickFixLocations(ctx, omnisharp, "/findimplementations", omnisharpPosition(params.TextDocument.URI, params.Position))
+}
+
+// quickFixLocations calls an endpoint answering with a QuickFixes list and converts it to locations
+func (s *Server) quickFixLocations(ctx context.Context, omnisharp *OmniSharpClient, endpoint string, request interface{}) ([]protocol.Location, error) {
+	response, err := omnisharp.SendRequest(ctx, endpoint, request)
+	if err != nil {
+		return nil, err
+	}
+
+	var omnisharpResponse struct {
+		QuickFixes []QuickFix `json:"QuickFixes"`
+	}
+	if err := json.Unmarshal(response, &omnisharpResponse); err != nil {
+		return nil, err
+	}
+
+	locations := make([]protocol.Location, 0, len(omnisharpResponse.QuickFixes))
+	for _, fix := range omnisharpResponse.QuickFixes {
+		locations = append(locations, s.quickFixLocation(fix))
+	}
+	return locations, nil
+}
+
+// latestRequest keeps only the newest of a kind of request running, such as the queries a
+// symbol search box sends on each keystroke
+type latestRequest struct {
+	mu         sync.Mutex
+	generation uint64
+	cancel     context.CancelFunc
+}
+
+// begin cancels the previous request and derives the context of a new one. end must be called
+// once the request is done
+func (l *latestRequest) begin(ctx context.Context) (context.Context, func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.cancel != nil {
+		l.cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	l.generation++
+	l.cancel = cancel
+	generation := l.generation
+
+	return ctx, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		cancel()
+		if l.generation == generation {
+			l.cancel = nil
+		}
+	}
+}
+
+// handleWorkspaceSymbol searches symbols across the solution. A newer query cancels ctx, see
+// symbolQuery, and this one then answers RequestCancelled even if OmniSharp already replied,
+// so the client only sees results for what is in the search box. Clients resolving symbols
+// lazily get WorkspaceSymbols without what they resolve, others SymbolInformation
+func (s *Server) handleWorkspaceSymbol(ctx context.Context, params *protocol.WorkspaceSymbolParams) (interface{}, error) {
+	omnisharp := s.backend()
+	if omnisharp == nil {
+		return nil, nil
+	}
+
+	found, err := findSymbols(ctx, omnisharp, params.Query)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s.lazySymbolProperties.lazySymbols() {
+		s.symbolResults.store(found)
+		symbols := make([]WorkspaceSymbol, 0, len(found))
+		for _, symbol := range found {
+			location := s.quickFixLocation(symbol.QuickFix)
+			if !s.currentConfig().Generated.WorkspaceSymbols && s.isGenerated(location.URI) {
+				continue
+			}
+			symbols = append(symbols, s.lazyWorkspaceSymbol(symbol, location))
+		}
+		return symbols, nil
+	}
+
+	symbols := make([]protocol.SymbolInformation, 0, len(found))
+	for _, symbol := range found {
+		location := s.quickFixLocation(symbol.QuickFix)
+		if !s.currentConfig().Generated.WorkspaceSymbols && s.isGenerated(location.URI) {
+			continue
+		}
+		symbols = append(symbols, protocol.SymbolInformation{
+			Name:          symbol.Text,
+			Kind:          convertSymbolKind(symbol.Kind),
+			Location:      location,
+			ContainerName: s.assemblyContainer(location.URI.Filename(), symbol.ContainingSymbolName),
+		})
+	}
+	return symbols, nil
+}
+
+// findSymbols asks OmniSharp for the symbols matching query
+func findSymbols(ctx context.Context, omnisharp *OmniSharpClient, query string) ([]SymbolLocation, error) {
+	response, err := omnisharp.SendRequest(ctx, "/findsymbols", map[string]interface{}{
+		"Filter": query,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var omnisharpResponse struct {
+		QuickFixes []SymbolLocation `json:"QuickFixes"`
+	}
+	if err := json.Unmarshal(response, &omnisharpResponse); err != nil {
+		return nil, err
+	}
+	return omnisharpResponse.QuickFixes, nil
+}
+
+func (s *Server) quickFixLocation(fix QuickFix) protocol.Location {
+	return protocol.Location{
+		URI: pathToURI(s.resolveOmniSharpPath(fix.FileName)),
+		Range: protocol.Range{
+			Start: protocol.Position{Line: fix.Line, Character: fix.Column},
+			End:   protocol.Position{Line: fix.EndLine, Character: fix.EndColumn},
+		},
+	}
+}
+
+func convertSymbolKind(omnisharpKind string) protocol.SymbolKind {
+	switch omnisharpKind {
+	case "Class":
+		return protocol.SymbolKindClass
+	case "Struct":
+		return protocol.SymbolKindStruct
+	case "Interface":
+		return protocol.SymbolKindInterface
+	case "Enum":
+		return protocol.SymbolKindEnum
+	case "EnumMember":
+		return protocol.SymbolKindEnumMember
+	case "Delegate":
+		return protocol.SymbolKindClass
+	case "Method":
+		return protocol.SymbolKindMethod
+	case "Constructor":
+		return protocol.SymbolKindConstructor
+	case "Destructor":
+		return protocol.SymbolKindMethod
+	case "Operator":
+		return protocol.SymbolKindOperator
+	case "Property", "Indexer":
+		return protocol.SymbolKindProperty
+	case "Field":
+		return protocol.SymbolKindField
+	case "Constant":
+		return protocol.SymbolKindConstant
+	case "Event":
+		return protocol.SymbolKindEvent
+	case "Namespace":
+		return protocol.SymbolKindNamespace
+	default:
+		return protocol.SymbolKindVariable
+	}
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+)
+
+// waitFor polls condition until it holds or a second passes
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDefinitionWarmsTargetAfterReplying(t *testing.T) {
+	fake := newFakeOmniSharp(t, map[string]interface{}{})
+	s, _ := newTestServer(t, fake)
+	target := filepath.Join(s.rootPath, "Player.cs")
+	if err := os.WriteFile(target, []byte("class Player { }"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fake.setResponse("/v2/gotodefinition", map[string]interface{}{
+		"Definitions": []interface{}{map[string]interface{}{
+			"Location": map[string]interface{}{"FileName": target, "Range": map[string]interface{}{}},
+		}},
+	})
+	uri := testURI(s, "Game.cs")
+	openTestDocument(s, uri, "class Game { Player player; }")
+	waitFor(t, "the opened document's diagnostics", func() bool { return fake.callCount("/codecheck") == 1 })
+
+	// The request's context ends with the reply, as jsonrpc2 cancels it
+	ctx, cancel := context.WithCancel(context.Background())
+	locations, err := s.handleDefinition(ctx, &protocol.DefinitionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Position:     protocol.Position{Line: 0, Character: 14},
+		},
+	})
+	cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locations) != 1 || locations[0].URI != pathToURI(target) {
+		t.Fatalf("locations = %v", locations)
+	}
+
+	waitFor(t, "the definition target to be warmed", func() bool {
+		_, ok := s.documents.Get(pathToURI(target))
+		return ok && fake.callCount("/codecheck") == 2
+	})
+}
+
+// definitionResponse is a /v2/gotodefinition response with a definition in each of files, on
+// the line of the same index
+func definitionResponse(files ...string) map[string]interface{} {
+	definitions := make([]interface{}, len(files))
+	for i, file := range files {
+		definitions[i] = map[string]interface{}{"Location": map[string]interface{}{
+			"FileName": file,
+			"Range": map[string]interface{}{
+				"Start": map[string]interface{}{"Line": i, "Column": 17},
+				"End":   map[string]interface{}{"Line": i, "Column": 24},
+			},
+		}}
+	}
+	return map[string]interface{}{"Definitions": definitions}
+}
+
+func definitionAt(t *testing.T, s *Server, uri protocol.DocumentURI, pos protocol.Position) []protocol.Location {
+	t.Helper()
+	locations, err := s.handleDefinition(context.Background(), &protocol.DefinitionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}, Position: pos},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return locations
+}
+
+func TestDefinitionReturnsEveryDeclaration(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []string
+		wantURIs []string
+	}{
+		{"one", []string{"/project/Assets/Player.cs"}, []string{"file:///project/Assets/Player.cs"}},
+		{
+			name:     "both halves of a partial method",
+			files:    []string{"/project/Assets/Player.cs", "/project/Assets/Player.Generated.cs"},
+			wantURIs: []string{"file:///project/Assets/Player.cs", "file:///project/Assets/Player.Generated.cs"},
+		},
+		{
+			name:     "in metadata",
+			files:    []string{"", "/project/Assets/Player.cs"},
+			wantURIs: []string{"file:///project/Assets/Player.cs"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/v2/gotodefinition": definitionResponse(test.files...)})
+			s, _ := newTestServer(t, fake)
+			configure(s, func(config *Config) { config.Diagnostics.WarmDefinitionTargets = false })
+			uri := testURI(s, "Game.cs")
+			openTestDocument(s, uri, "class Game { void Start() { Move(); } }")
+
+			locations := definitionAt(t, s, uri, protocol.Position{Character: 29})
+			var uris []string
+			for i, location := range locations {
+				uris = append(uris, string(location.URI))
+				if location.Range.Start.Character != 17 || location.Range.End.Character != 24 {
+					t.Errorf("location %d range = %v", i, location.Range)
+				}
+			}
+			if strings.Join(uris, " ") != strings.Join(test.wantURIs, " ") {
+				t.Errorf("definitions in %v, want %v", uris, test.wantURIs)
+			}
+		})
+	}
+}
+
+func TestWarmDefinitionTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		targets []string
+		// missing leaves the target files unwritten
+		missing bool
+		want    bool
+	}{
+		{name: "new file", enabled: true, targets: []string{"Player.cs"}, want: true},
+		{name: "disabled", targets: []string{"Player.cs"}},
+		{name: "several definitions", enabled: true, targets: []string{"Player.cs", "Player.Generated.cs"}},
+		{name: "missing file", enabled: true, targets: []string{"Player.cs"}, missing: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{})
+			s, _ := newTestServer(t, fake)
+			configure(s, func(config *Config) { config.Diagnostics.WarmDefinitionTargets = test.enabled })
+			var targets []string
+			for _, name := range test.targets {
+				target := filepath.Join(s.rootPath, name)
+				targets = append(targets, target)
+				if !test.missing {
+					if err := os.WriteFile(target, []byte("partial class Player { }"), 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			fake.setResponse("/v2/gotodefinition", definitionResponse(targets...))
+			uri := testURI(s, "Game.cs")
+			openTestDocument(s, uri, "class Game { Player player; }")
+			waitFor(t, "the opened document's diagnostics", func() bool { return fake.callCount("/codecheck") == 1 })
+
+			definitionAt(t, s, uri, protocol.Position{Character: 14})
+			warmed := func() bool {
+				_, ok := s.documents.Get(pathToURI(targets[0]))
+				return ok && fake.callCount("/codecheck") == 2
+			}
+			if test.want {
+				waitFor(t, "the definition target to be warmed", warmed)
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+			if warmed() || fake.callCount("/codecheck") != 1 {
+				t.Errorf("warmed %s", targets[0])
+			}
+		})
+	}
+}
+
+func TestMergeLocations(t *testing.T) {
+	const player, enemy = protocol.DocumentURI("file:///project/Assets/Player.cs"), protocol.DocumentURI("file:///project/Assets/Enemy.cs")
+	at := func(uri protocol.DocumentURI, line, start, end uint32) protocol.Location {
+		return protocol.Location{URI: uri, Range: testRange(line, start, line, end)}
+	}
+	tests := []struct {
+		name      string
+		locations []protocol.Location
+		want      []protocol.Location
+	}{
+		{
+			name:      "distinct usages sorted",
+			locations: []protocol.Location{at(player, 4, 8, 13), at(enemy, 2, 4, 9), at(player, 1, 8, 13)},
+			want:      []protocol.Location{at(enemy, 2, 4, 9), at(player, 1, 8, 13), at(player, 4, 8, 13)},
+		},
+		{
+			name:      "duplicate",
+			locations: []protocol.Location{at(player, 1, 8, 13), at(player, 1, 8, 13)},
+			want:      []protocol.Location{at(player, 1, 8, 13)},
+		},
+		{
+			name:      "identifier within its node",
+			locations: []protocol.Location{at(player, 1, 8, 13), at(player, 1, 4, 20)},
+			want:      []protocol.Location{at(player, 1, 4, 20)},
+		},
+		{
+			name:      "overlapping",
+			locations: []protocol.Location{at(player, 1, 4, 10), at(player, 1, 8, 13)},
+			want:      []protocol.Location{at(player, 1, 4, 13)},
+		},
+		{
+			name:      "adjacent",
+			locations: []protocol.Location{at(player, 1, 4, 8), at(player, 1, 8, 13)},
+			want:      []protocol.Location{at(player, 1, 4, 13)},
+		},
+		{
+			name:      "same range in different files",
+			locations: []protocol.Location{at(player, 1, 8, 13), at(enemy, 1, 8, 13)},
+			want:      []protocol.Location{at(enemy, 1, 8, 13), at(player, 1, 8, 13)},
+		},
+		{
+			name: "range across lines",
+			locations: []protocol.Location{
+				{URI: player, Range: testRange(1, 4, 3, 1)}, at(player, 2, 8, 13), at(player, 3, 4, 9),
+			},
+			want: []protocol.Location{{URI: player, Range: testRange(1, 4, 3, 1)}, at(player, 3, 4, 9)},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := mergeLocations(test.locations); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("mergeLocations = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestWorkspaceSymbolQueriesSupersede checks a query is cancelled by the next one the search
+// box sends, unless it was answered first
+func TestWorkspaceSymbolQueriesSupersede(t *testing.T) {
+	tests := []struct {
+		name string
+		// answered waits for the first query's answer before sending the second
+		answered      bool
+		wantCancelled bool
+	}{
+		{"superseded while OmniSharp searches", false, true},
+		{"answered before the next", true, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/findsymbols": map[string]interface{}{
+				"QuickFixes": []SymbolLocation{{QuickFix: QuickFix{FileName: "/project/Assets/Player.cs", Text: "Player"}, Kind: "Class"}},
+			}})
+			s, _ := newTestServer(t, fake)
+			fake.setDelay(300 * time.Millisecond)
+
+			replies := make([]chan error, 2)
+			query := func(id int32, text string) {
+				t.Helper()
+				replies[id] = make(chan error, 1)
+				request, err := jsonrpc2.NewCall(jsonrpc2.NewNumberID(id), protocol.MethodWorkspaceSymbol, protocol.WorkspaceSymbolParams{Query: text})
+				if err != nil {
+					t.Fatal(err)
+				}
+				s.handle(context.Background(), func(ctx context.Context, result interface{}, err error) error {
+					if err == nil && len(result.([]protocol.SymbolInformation)) != 1 {
+						t.Errorf("query %q found %+v", text, result)
+					}
+					replies[id] <- err
+					return nil
+				}, request)
+			}
+
+			query(0, "Pla")
+			var first error
+			if test.answered {
+				first = <-replies[0]
+			}
+			query(1, "Play")
+			if !test.answered {
+				first = <-replies[0]
+			}
+			var wireErr *jsonrpc2.Error
+			if cancelled := errors.As(first, &wireErr) && wireErr.Code == protocol.CodeRequestCancelled; cancelled != test.wantCancelled || !cancelled && first != nil {
+				t.Errorf("first query answered %v, want cancelled %v", first, test.wantCancelled)
+			}
+			if err := <-replies[1]; err != nil {
+				t.Errorf("latest query answered %v", err)
+			}
+		})
+	}
+}
+
+// TestRelativeNavigationPaths checks navigation results OmniSharp reports relative to the
+// solution point into the workspace, as absolute ones do
+func TestRelativeNavigationPaths(t *testing.T) {
+	tests := []struct {
+		name string
+		// path is the file OmniSharp reports, relative to the workspace root if not absolute
+		path     string
+		absolute bool
+	}{
+		{"relative", "Assets/Enemy.cs", false},
+		{"absolute", "Assets/Enemy.cs", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{})
+			s, _ := newTestServer(t, fake)
+			reported := test.path
+			if test.absolute {
+				reported = filepath.Join(s.rootPath, test.path)
+			}
+			quickFixes := map[string]interface{}{"QuickFixes": []QuickFix{{FileName: reported, Text: "Enemy"}}}
+			fake.setResponse("/v2/gotodefinition", definitionResponse(reported))
+			fake.setResponse("/findusages", quickFixes)
+			fake.setResponse("/findsymbols", quickFixes)
+			uri := testURI(s, "Assets/Player.cs")
+			openTestDocument(s, uri, "class Player { Enemy target; }\n")
+			want := testURI(s, test.path)
+			position := protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Position:     protocol.Position{Character: 17},
+			}
+
+			if locations := definitionAt(t, s, uri, position.Position); len(locations) != 1 || locations[0].URI != want {
+				t.Errorf("definition = %+v, want %s", locations, want)
+			}
+			references, err := s.handleReferences(context.Background(), &ReferenceParams{ReferenceParams: protocol.ReferenceParams{TextDocumentPositionParams: position}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(references) != 1 || references[0].URI != want {
+				t.Errorf("references = %+v, want %s", references, want)
+			}
+			symbols, err := s.handleWorkspaceSymbol(context.Background(), &protocol.WorkspaceSymbolParams{Query: "Enemy"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found := symbols.([]protocol.SymbolInformation); len(found) != 1 || found[0].Location.URI != want {
+				t.Errorf("workspace symbols = %+v, want %s", symbols, want)
+			}
+		})
+	}
+}
+
+// TestScopedReferences checks references are kept to the project of the document searched from
+// when navigation.referencesScope or the request says so, the request having the last word
+func TestScopedReferences(t *testing.T) {
+	usages := []string{"Assets/Player.cs", "Assets/Enemy.cs", "Assets/Editor/PlayerEditor.cs", "Packages/Tools/Spawner.cs"}
+	tests := []struct {
+		name string
+		// config and scope are navigation.referencesScope and the scope of the request
+		config, scope string
+		// from is the document searched from
+		from   string
+		loaded bool
+		want   []string
+	}{
+		{"solution", referencesScopeSolution, "", "Assets/Player.cs", true, usages},
+		{"project by config", referencesScopeProject, "", "Assets/Player.cs", true, []string{"Assets/Enemy.cs", "Assets/Player.cs"}},
+		{"project by request", referencesScopeSolution, referencesScopeProject, "Assets/Player.cs", true, []string{"Assets/Enemy.cs", "Assets/Player.cs"}},
+		{"solution by request", referencesScopeProject, referencesScopeSolution, "Assets/Player.cs", true, usages},
+		{"editor project", referencesScopeProject, "", "Assets/Editor/PlayerEditor.cs", true, []string{"Assets/Editor/PlayerEditor.cs"}},
+		{"projects not loaded", referencesScopeProject, "", "Assets/Player.cs", false, usages},
+		{"document in no project", referencesScopeProject, "", "Assets/Untracked.cs", true, usages},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var quickFixes []QuickFix
+			for _, usage := range usages {
+				quickFixes = append(quickFixes, QuickFix{FileName: usage, Text: "Enemy", Line: 1, Column: 16})
+			}
+			fake := newFakeOmniSharp(t, map[string]interface{}{
+				"/projects":   projectsResponse,
+				"/findusages": map[string]interface{}{"QuickFixes": quickFixes},
+			})
+			s, _ := newTestServer(t, fake)
+			if test.loaded {
+				s.loadProjects(context.Background(), s.omnisharp)
+			}
+			configure(s, func(config *Config) { config.Navigation.ReferencesScope = test.config })
+			uri := testURI(s, test.from)
+			openTestDocument(s, uri, "class Player { Enemy target; }\n")
+
+			result, err := call(t, s, 1, protocol.MethodTextDocumentReferences, map[string]interface{}{
+				"textDocument": map[string]interface{}{"uri": uri},
+				"position":     protocol.Position{Character: 17},
+				"context":      map[string]interface{}{"includeDeclaration": true},
+				"scope":        test.scope,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, location := range result.([]protocol.Location) {
+				rel, _ := filepath.Rel(s.rootPath, location.URI.Filename())
+				got = append(got, filepath.ToSlash(rel))
+			}
+			want := append([]string{}, test.want...)
+			sort.Strings(got)
+			sort.Strings(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("references in %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type OmniSharpClient struct {
+	// address is what the client was created with, to share it with other servers
+	address string
+	baseURL string
+	client  *http.Client
+	// timeout bounds each request; zero means no limit
+	timeout time.Duration
+	// version is the release of OmniSharp, zero if unknown, which decides the endpoints used
+	version omnisharpVersion
+	// compression asks for gzipped responses, and gzips large request bodies once OmniSharp
+	// advertises it accepts them
+	compression bool
+	// acceptsGzip is set once a response lists gzip in Accept-Encoding, the encodings the
+	// server accepts in requests, unless gzipRefused: it refused a gzipped body once
+	acceptsGzip atomic.Bool
+	gzipRefused atomic.Bool
+}
+
+// NewOmniSharpClient connects to OmniSharp at address: a URL such as http://localhost:2000, a
+// host:port, or the path of a Unix socket it listens on, optionally prefixed by unix:
+func NewOmniSharpClient(address string, timeout time.Duration, compression bool) *OmniSharpClient {
+	// The transport asks for gzip and decompresses responses itself unless disabled
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DisableCompression = !compression
+	baseURL := address
+	if socket, ok := unixSocketPath(address); ok {
+		// Requests still go over HTTP; the host of their URL is ignored
+		baseURL = "http://omnisharp"
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+	} else if !strings.Contains(address, "://") {
+		baseURL = "http://" + address
+	}
+	return &OmniSharpClient{
+		address:     address,
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		client:      &http.Client{Transport: transport},
+		timeout:     timeout,
+		compression: compression,
+	}
+}
+
+// unixSocketPath reports whether address names a Unix socket rather than a TCP endpoint,
+// returning its path
+func unixSocketPath(address string) (string, bool) {
+	if path, ok := strings.CutPrefix(address, "unix:"); ok {
+		return strings.TrimPrefix(path, "//"), true
+	}
+	return address, !strings.Contains(address, "://") && filepath.IsAbs(address)
+}
+
+// SendRequest posts request to an OmniSharp endpoint and returns the response body. Failures
+// are *OmniSharpError, classified as timeout, transport or backend errors
+func (o *OmniSharpClient) SendRequest(ctx context.Context, endpoint string, request interface{}) ([]byte, error) {
+	var body []byte
+	err := o.SendRequestStream(ctx, endpoint, request, func(r io.Reader) error {
+		var err error
+		if body, err = ioutil.ReadAll(r); err != nil {
+			return &OmniSharpError{Class: errorTransport, Endpoint: endpoint, Err: err}
+		}
+		body = bytes.TrimSpace(body)
+		return nil
+	})
+	return body, err
+}
+
+// SendRequestStream is SendRequest handing the response body to read as it arrives, so large
+// responses can be decoded without holding them whole. read may stop early; the rest is
+// discarded. Its errors are returned as they are, unless the request timed out meanwhile
+func (o *OmniSharpClient) SendRequestStream(ctx context.Context, endpoint string, request interface{}, read func(r io.Reader) error) error {
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+
+	resp, compressed, err := o.post(ctx, endpoint, jsonData, o.compression && o.acceptsGzip.Load() && len(jsonData) >= compressionThreshold)
+	if err == nil && compressed && resp.StatusCode == http.StatusUnsupportedMediaType {
+		// The server took back what it advertised, so it gets plain bodies from now on
+		resp.Body.Close()
+		o.gzipRefused.Store(true)
+		o.acceptsGzip.Store(false)
+		resp, _, err = o.post(ctx, endpoint, jsonData, false)
+	}
+	if err != nil {
+		class := errorTransport
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			class = errorTimeout
+		case context.Canceled:
+			class = errorOther
+		}
+		return &OmniSharpError{Class: class, Endpoint: endpoint, Err: err}
+	}
+	defer resp.Body.Close()
+	if o.compression && !o.gzipRefused.Load() && acceptsEncoding(resp.Header, "gzip") {
+		o.acceptsGzip.Store(true)
+	}
+
+	if resp.StatusCode >= 400 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return &OmniSharpError{
+			Class:    errorBackend,
+			Endpoint: endpoint,
+			Err:      fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body))),
+		}
+	}
+	if err := read(withoutBOM(resp.Body)); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return &OmniSharpError{Class: errorTimeout, Endpoint: endpoint, Err: err}
+		}
+		return err
+	}
+	return nil
+}
+
+// compressionThreshold is the size from which request bodies are worth gzipping, such as the
+// buffers of large scripts
+const compressionThreshold = 8 << 10
+
+// post sends body to endpoint, gzipped if compress is set, reporting whether it was
+func (o *OmniSharpClient) post(ctx context.Context, endpoint string, body []byte, compress bool) (*http.Response, bool, error) {
+	if compress {
+		var compressed bytes.Buffer
+		writer := gzip.NewWriter(&compressed)
+		if _, err := writer.Write(body); err == nil && writer.Close() == nil {
+			body = compressed.Bytes()
+		} else {
+			compress = false
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	resp, err := o.client.Do(req)
+	return resp, compress, err
+}
+
+// acceptsEncoding reports whether the Accept-Encoding of header lists encoding without ruling
+// it out with q=0
+func acceptsEncoding(header http.Header, encoding string) bool {
+	for _, value := range header.Values("Accept-Encoding") {
+		for _, entry := range strings.Split(value, ",") {
+			name, parameters, _ := strings.Cut(strings.TrimSpace(entry), ";")
+			if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+				continue
+			}
+			q, ok := strings.CutPrefix(strings.ReplaceAll(parameters, " ", ""), "q=")
+			if !ok {
+				return true
+			}
+			if weight, err := strconv.ParseFloat(q, 64); err != nil || weight > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// utf8BOM is the byte order mark some OmniSharp builds and proxies start responses with
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// withoutBOM skips a leading byte order mark, which encoding/json rejects as an invalid character
+func withoutBOM(r io.Reader) io.Reader {
+	buffered := bufio.NewReader(r)
+	if prefix, err := buffered.Peek(len(utf8BOM)); err == nil && bytes.Equal(prefix, utf8BOM) {
+		buffered.Discard(len(utf8BOM))
+	}
+	return buffered
+}
+
+// checkReadyStatus reports whether OmniSharp has finished loading the solution
+func (o *OmniSharpClient) checkReadyStatus(ctx context.Context) bool {
+	ready, err := o.readyStatus(ctx)
+	return err == nil && ready
+}
+
+// readyStatus asks whether OmniSharp has finished loading the solution, failing if OmniSharp
+// doesn't answer
+func (o *OmniSharpClient) readyStatus(ctx context.Context) (bool, error) {
+	response, err := o.SendRequest(ctx, "/checkreadystatus", map[string]interface{}{})
+	if err != nil {
+		return false, err
+	}
+
+	var status struct {
+		Ready bool `json:"Ready"`
+	}
+	if err := json.Unmarshal(response, &status); err != nil {
+		return false, err
+	}
+	return status.Ready, nil
+}
+
+// OmniSharpProcess is an OmniSharp server launched and owned by us
+type OmniSharpProcess struct {
+	cmd    *exec.Cmd
+	output *outputTail
+	exited chan struct{}
+	usage  *usageSampler
+}
+
+// omnisharpCommand builds the command line running OmniSharp with args, either the
+// self-contained executable or OmniSharp.dll under dotnet depending on omnisharp.launchMode
+func omnisharpCommand(config OmniSharpConfig, args []string) (string, []string, error) {
+	mode := config.LaunchMode
+	if mode == launchAuto {
+		mode = launchExecutable
+		if strings.EqualFold(filepath.Ext(config.Path), ".dll") {
+			mode = launchDotnet
+		}
+	}
+
+	switch mode {
+	case launchExecutable:
+		return config.Path, args, nil
+	case launchDotnet:
+		dotnet, err := exec.LookPath("dotnet")
+		if err != nil {
+			return "", nil, fmt.Errorf("running %s requires dotnet, which was not found on PATH", config.Path)
+		}
+		return dotnet, append([]string{config.Path}, args...), nil
+	default:
+		return "", nil, fmt.Errorf("unknown omnisharp.launchMode %q, expected auto, executable or dotnet", config.LaunchMode)
+	}
+}
+
+// omnisharpArgs passes the analyzer and code generation settings to OmniSharp, which only
+// reads them at startup
+func (c OmniSharpConfig) omnisharpArgs() []string {
+	properties := "PreferThrowingProperties"
+	if c.ImplementAutoProperties {
+		properties = "PreferAutoProperties"
+	}
+	return []string{
+		"RoslynExtensionsOptions:EnableAnalyzersSupport=" + strconv.FormatBool(c.EnableRoslynAnalyzers),
+		"FormattingOptions:EnableEditorConfigSupport=" + strconv.FormatBool(c.EnableEditorConfigSupport),
+		"ImplementTypeOptions:PropertyGenerationBehavior=" + properties,
+	}
+}
+
+// LaunchOmniSharp starts OmniSharp in HTTP mode on a free local port, loading solution, a
+// workspace folder or .sln file. Indices are zero-based so LSP positions can be forwarded
+// unchanged. settings are OmniSharp options as Section:Key=value arguments, and onOutput, if
+// set, sees every line OmniSharp logs. Diagnostics are in the language of locale, if OmniSharp
+// has it
+func LaunchOmniSharp(config OmniSharpConfig, solution string, settings []string, locale string, onOutput func(line string)) (*OmniSharpProcess, *OmniSharpClient, error) {
+	port, err := freePort()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	args := []string{
+		"-s", solution,
+		"-p", strconv.Itoa(port),
+		"-z",
+		"--hostPID", strconv.Itoa(os.Getpid()),
+	}
+	args = append(append(args, config.omnisharpArgs()...), settings...)
+	name, args, err := omnisharpCommand(config, args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	output := newOutputTail(50)
+	output.onLine = onOutput
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(), omnisharpLocale(locale)...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	// Don't let a child holding the output pipes open keep Wait from returning after a kill
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("failed to start OmniSharp (%s): %w", config.Path, err)
+	}
+
+	process := &OmniSharpProcess{
+		cmd:    cmd,
+		output: output,
+		exited: make(chan struct{}),
+	}
+	process.usage = sampleUsage(cmd.Process.Pid, process.exited)
+	go func() {
+		cmd.Wait()
+		close(process.exited)
+	}()
+
+	client := NewOmniSharpClient(fmt.Sprintf("http://localhost:%d", port), time.Duration(config.RequestTimeout), config.Compression)
+	return process, client, nil
+}
+
+// WaitReady polls OmniSharp until it reports ready, the process exits, or ctx is done. A nil p
+// is an OmniSharp we didn't launch, which is only waited for
+func (p *OmniSharpProcess) WaitReady(ctx context.Context, client *OmniSharpClient) error {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	var exited chan struct{}
+	if p != nil {
+		exited = p.exited
+	}
+
+	for {
+		if client.checkReadyStatus(ctx) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-exited:
+			return errors.New("OmniSharp exited before becoming ready")
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *OmniSharpProcess) Stop() {
+	if p.cmd.Process != nil {
+		p.cmd.Process.Kill()
+	}
+	<-p.exited
+}
+
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// outputTail keeps the last lines written by OmniSharp so they can be reported on failure
+type outputTail struct {
+	mu      sync.Mutex
+	lines   []string
+	max     int
+	partial string
+	onLine  func(line string)
+}
+
+func newOutputTail(max int) *outputTail {
+	return &outputTail{max: max}
+}
+
+func (t *outputTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	text := t.partial + string(p)
+	lines := strings.Split(text, "\n")
+	t.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		line = strings.TrimSuffix(line, "\r")
+		t.lines = append(t.lines, line)
+		if t.onLine != nil {
+			t.onLine(line)
+		}
+	}
+	if len(t.lines) > t.max {
+		t.lines = t.lines[len(t.lines)-t.max:]
+	}
+	return len(p), nil
+}
+
+func (t *outputTail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lines := t.lines
+	if t.partial != "" {
+		lines = append(lines[:len(lines):len(lines)], t.partial)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOmniSharpCommand(t *testing.T) {
+	binDir := t.TempDir()
+	dotnet := filepath.Join(binDir, "dotnet")
+	if err := os.WriteFile(dotnet, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"-s", "/project"}
+
+	tests := []struct {
+		name     string
+		mode     string
+		path     string
+		noDotnet bool
+		wantName string
+		wantArgs []string
+		wantErr  string
+	}{
+		{name: "auto, executable", mode: launchAuto, path: "/opt/omnisharp/OmniSharp", wantName: "/opt/omnisharp/OmniSharp", wantArgs: args},
+		{name: "auto, dll", mode: launchAuto, path: "/opt/omnisharp/OmniSharp.dll", wantName: dotnet, wantArgs: []string{"/opt/omnisharp/OmniSharp.dll", "-s", "/project"}},
+		{name: "auto, upper-case dll", mode: launchAuto, path: "/opt/omnisharp/OMNISHARP.DLL", wantName: dotnet, wantArgs: []string{"/opt/omnisharp/OMNISHARP.DLL", "-s", "/project"}},
+		{name: "executable", mode: launchExecutable, path: "/opt/omnisharp/OmniSharp.dll", wantName: "/opt/omnisharp/OmniSharp.dll", wantArgs: args},
+		{name: "dotnet", mode: launchDotnet, path: "/opt/omnisharp/OmniSharp", wantName: dotnet, wantArgs: []string{"/opt/omnisharp/OmniSharp", "-s", "/project"}},
+		{name: "dotnet not on PATH", mode: launchDotnet, path: "/opt/omnisharp/OmniSharp.dll", noDotnet: true, wantErr: "requires dotnet"},
+		{name: "unknown mode", mode: "mono", path: "/opt/omnisharp/OmniSharp.exe", wantErr: `unknown omnisharp.launchMode "mono"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.noDotnet {
+				t.Setenv("PATH", t.TempDir())
+			} else {
+				t.Setenv("PATH", binDir)
+			}
+			name, gotArgs, err := omnisharpCommand(OmniSharpConfig{Path: test.path, LaunchMode: test.mode}, args)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("error = %v, want one containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != test.wantName || !reflect.DeepEqual(gotArgs, test.wantArgs) {
+				t.Errorf("command = %s %q, want %s %q", name, gotArgs, test.wantName, test.wantArgs)
+			}
+		})
+	}
+}
+
+// TestOmniSharpAnalyzerArgs checks the analyzer settings reach OmniSharp's command line
+func TestOmniSharpAnalyzerArgs(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "OmniSharp")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nfor arg in \"$@\"; do echo \"$arg\"; done\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		analyzers    bool
+		editorConfig bool
+		want         []string
+	}{
+		{"defaults", false, true, []string{"RoslynExtensionsOptions:EnableAnalyzersSupport=false", "FormattingOptions:EnableEditorConfigSupport=true"}},
+		{"analyzers enabled", true, true, []string{"RoslynExtensionsOptions:EnableAnalyzersSupport=true", "FormattingOptions:EnableEditorConfigSupport=true"}},
+		{"editorconfig disabled", false, false, []string{"RoslynExtensionsOptions:EnableAnalyzersSupport=false", "FormattingOptions:EnableEditorConfigSupport=false"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig().OmniSharp
+			config.Path, config.LaunchMode = script, launchExecutable
+			config.EnableRoslynAnalyzers, config.EnableEditorConfigSupport = test.analyzers, test.editorConfig
+
+			var mu sync.Mutex
+			var args []string
+			process, _, err := LaunchOmniSharp(config, dir, []string{"FormattingOptions:UseTabs=true"}, "", func(line string) {
+				mu.Lock()
+				args = append(args, line)
+				mu.Unlock()
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			<-process.exited
+
+			mu.Lock()
+			defer mu.Unlock()
+			var got []string
+			for _, arg := range args {
+				if strings.Contains(arg, "Analyzers") || strings.Contains(arg, "EditorConfig") {
+					got = append(got, arg)
+				}
+			}
+			if !reflect.DeepEqual(got, test.want) || args[len(args)-1] != "FormattingOptions:UseTabs=true" {
+				t.Errorf("launched with %q, want %q before the other settings", args, test.want)
+			}
+		})
+	}
+}
+
+// TestResponseWithBOM checks responses starting with a byte order mark or padded with
+// whitespace decode, whether read whole or streamed
+func TestResponseWithBOM(t *testing.T) {
+	const response = `{"Type":"float Player.speed"}`
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"plain", response},
+		{"BOM", "\xEF\xBB\xBF" + response},
+		{"whitespace", "\r\n  " + response + "\n"},
+		{"BOM and whitespace", "\xEF\xBB\xBF\n" + response + "\r\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, test.body)
+			}))
+			defer server.Close()
+			omnisharp := NewOmniSharpClient(server.URL, 5*time.Second, false)
+
+			body, err := omnisharp.SendRequest(context.Background(), "/typelookup", map[string]interface{}{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var whole TypeLookupResponse
+			if err := json.Unmarshal(body, &whole); err != nil || whole.Type != "float Player.speed" {
+				t.Errorf("read whole = %+v, %v", whole, err)
+			}
+
+			var streamed TypeLookupResponse
+			err = omnisharp.SendRequestStream(context.Background(), "/typelookup", map[string]interface{}{}, func(r io.Reader) error {
+				return json.NewDecoder(r).Decode(&streamed)
+			})
+			if err != nil || streamed.Type != "float Player.speed" {
+				t.Errorf("streamed = %+v, %v", streamed, err)
+			}
+		})
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		accept []string
+		want   bool
+	}{
+		{[]string{"gzip"}, true},
+		{[]string{"deflate, GZIP;q=0.5"}, true},
+		{[]string{"identity", "gzip"}, true},
+		{[]string{"gzip;q=0"}, false},
+		{[]string{"gzip; q=0.0, identity"}, false},
+		{[]string{"deflate"}, false},
+		{nil, false},
+	}
+	for _, test := range tests {
+		t.Run(strings.Join(test.accept, "|"), func(t *testing.T) {
+			header := http.Header{"Accept-Encoding": test.accept}
+			if got := acceptsEncoding(header, "gzip"); got != test.want {
+				t.Errorf("acceptsEncoding = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestCompression checks large request bodies are gzipped once OmniSharp advertises it accepts
+// them, going back to plain bodies for good when it refuses one, and gzipped responses decode
+func TestCompression(t *testing.T) {
+	const response = `{"Type":"float Player.speed"}`
+	tests := []struct {
+		name        string
+		compression bool
+		// advertise is the Accept-Encoding of the responses
+		advertise string
+		// refuse answers gzipped bodies 415 Unsupported Media Type
+		refuse bool
+		// want are the Content-Encoding of the large bodies received after the first request
+		want []string
+	}{
+		{"advertised", true, "gzip", false, []string{"gzip", "gzip"}},
+		{"not advertised", true, "", false, []string{"", ""}},
+		{"refused", true, "gzip", true, []string{"gzip", "", ""}},
+		{"off", false, "gzip", false, []string{"", ""}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var encodings []string
+			buffer := strings.Repeat("class Player { }\n", compressionThreshold/16)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body io.Reader = r.Body
+				if r.Header.Get("Content-Encoding") == "gzip" {
+					reader, err := gzip.NewReader(r.Body)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					body = reader
+				}
+				var request struct{ Buffer string }
+				if err := json.NewDecoder(body).Decode(&request); err != nil {
+					t.Errorf("undecodable %s body: %v", r.Header.Get("Content-Encoding"), err)
+				}
+				mu.Lock()
+				if request.Buffer == buffer {
+					encodings = append(encodings, r.Header.Get("Content-Encoding"))
+				}
+				mu.Unlock()
+				if test.advertise != "" {
+					w.Header().Set("Accept-Encoding", test.advertise)
+				}
+				if test.refuse && r.Header.Get("Content-Encoding") == "gzip" {
+					w.WriteHeader(http.StatusUnsupportedMediaType)
+					return
+				}
+				if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+					if test.compression {
+						t.Error("gzipped responses not asked for")
+					}
+					io.WriteString(w, response)
+					return
+				}
+				if !test.compression {
+					t.Error("gzipped responses asked for with compression off")
+				}
+				w.Header().Set("Content-Encoding", "gzip")
+				writer := gzip.NewWriter(w)
+				io.WriteString(writer, response)
+				writer.Close()
+			}))
+			defer server.Close()
+			omnisharp := NewOmniSharpClient(server.URL, 5*time.Second, test.compression)
+
+			requests := []map[string]string{{}, {"Buffer": buffer}, {"Buffer": buffer}}
+			for _, request := range requests {
+				body, err := omnisharp.SendRequest(context.Background(), "/updatebuffer", request)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(body, []byte(response)) {
+					t.Errorf("response = %q, want %q", body, response)
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if !reflect.DeepEqual(encodings, test.want) {
+				t.Errorf("large bodies encoded %q, want %q", encodings, test.want)
+			}
+		})
+	}
+}
+
+func TestUnixSocketPath(t *testing.T) {
+	tests := []struct {
+		address    string
+		wantPath   string
+		wantSocket bool
+	}{
+		{"unix:/tmp/omnisharp.sock", "/tmp/omnisharp.sock", true},
+		{"unix:///tmp/omnisharp.sock", "/tmp/omnisharp.sock", true},
+		{"/tmp/omnisharp.sock", "/tmp/omnisharp.sock", true},
+		{"localhost:2000", "", false},
+		{"127.0.0.1:2000", "", false},
+		{"http://localhost:2000", "", false},
+		{"http://localhost:2000/omnisharp", "", false},
+	}
+	for _, test := range tests {
+		path, ok := unixSocketPath(test.address)
+		if ok != test.wantSocket || ok && path != test.wantPath {
+			t.Errorf("unixSocketPath(%q) = %q, %v; want %q, %v", test.address, path, ok, test.wantPath, test.wantSocket)
+		}
+	}
+}
+
+// TestUnixSocketClient checks requests reach an OmniSharp listening on a Unix socket, however
+// its address is written
+func TestUnixSocketClient(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "omnisharp.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("no Unix sockets here: %v", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/checkreadystatus" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]bool{"Ready": true})
+	}))
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+
+	for _, address := range []string{socket, "unix:" + socket} {
+		client := NewOmniSharpClient(address, 5*time.Second, false)
+		ready, err := client.readyStatus(context.Background())
+		if err != nil || !ready {
+			t.Errorf("over %s: ready %v, %v; want ready", address, ready, err)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// commandOrganizeImports removes the unused usings of a document and sorts the rest, see
+// organizeImportsArguments
+const commandOrganizeImports = "unity-lsp.organizeImports"
+
+// removeUnnecessaryUsings is the fix-all provider of Roslyn's unnecessary using analyzer
+var removeUnnecessaryUsings = FixAllItem{Id: "IDE0005", Message: "Remove unnecessary usings"}
+
+// organizeImportsArguments is the single argument of commandOrganizeImports
+type organizeImportsArguments struct {
+	URI protocol.DocumentURI `json:"uri"`
+}
+
+func organizeImportsAction(uri protocol.DocumentURI) protocol.CodeAction {
+	return protocol.CodeAction{
+		Title: "Organize usings",
+		Kind:  protocol.SourceOrganizeImports,
+		Command: &protocol.Command{
+			Title:     "Organize usings",
+			Command:   commandOrganizeImports,
+			Arguments: []interface{}{organizeImportsArguments{URI: uri}},
+		},
+	}
+}
+
+// runOrganizeImports applies the edits organizing the usings of a document, also returning them
+func (s *Server) runOrganizeImports(ctx context.Context, args organizeImportsArguments) (interface{}, error) {
+	omnisharp := s.backend()
+	if omnisharp == nil {
+		return nil, nil
+	}
+	doc, ok := s.documents.Get(args.URI)
+	if !ok {
+		return nil, fmt.Errorf("%s is not open", s.displayPath(args.URI))
+	}
+
+	edits, err := s.organizeImports(ctx, omnisharp, doc)
+	if err != nil || len(edits) == 0 {
+		return nil, err
+	}
+	edit := &protocol.WorkspaceEdit{Changes: map[protocol.DocumentURI][]protocol.TextEdit{doc.URI: edits}}
+	if s.supportsDocumentChanges() {
+		edit = versionedEdit(edit, map[protocol.DocumentURI]int32{doc.URI: doc.Version})
+	}
+	if err := s.applyEdit(ctx, "Organize usings", edit); err != nil {
+		return nil, err
+	}
+	return edit, nil
+}
+
+// handleWillSaveWaitUntil organizes the usings of a document about to be saved, if configured.
+// A failure leaves the document as it is rather than holding up the save
+func (s *Server) handleWillSaveWaitUntil(ctx context.Context, params *protocol.WillSaveTextDocumentParams) []protocol.TextEdit {
+	if !s.currentConfig().Usings.OrganizeOnSave {
+		return nil
+	}
+	omnisharp := s.backend()
+	doc, ok := s.documents.Get(params.TextDocument.URI)
+	if omnisharp == nil || !ok {
+		return nil
+	}
+
+	edits, err := s.organizeImports(ctx, omnisharp, doc)
+	if err != nil {
+		log.Printf("failed to organize usings of %s on save: %v", doc.URI, err)
+		return nil
+	}
+	return edits
+}
+
+// organizeImports has OmniSharp remove the unused usings of doc, then sorts those left in each
+// block of consecutive usings. The result is a single edit spanning the lines that changed
+func (s *Server) organizeImports(ctx context.Context, omnisharp *OmniSharpClient, doc Document) ([]protocol.TextEdit, error) {
+	removal, err := runFixAll(ctx, omnisharp, fixAllArguments{
+		FileName: doc.URI.Filename(),
+		Scope:    fixAllDocument,
+		Id:       removeUnnecessaryUsings.Id,
+		Message:  removeUnnecessaryUsings.Message,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []protocol.TextEdit
+	for uri, edits := range removal.Changes {
+		if uri.Filename() == doc.URI.Filename() {
+			removed = append(removed, edits...)
+		}
+	}
+	text := sortUsings(applyTextEdits(doc.Text, removed), s.currentConfig().Usings.SortSystemFirst)
+	if text == doc.Text {
+		return nil, nil
+	}
+	return []protocol.TextEdit{lineDiffEdit(doc.Text, text)}, nil
+}
+
+// applyTextEdits applies non-overlapping edits, all relative to text
+func applyTextEdits(text string, edits []protocol.TextEdit) string {
+	edits = append([]protocol.TextEdit(nil), edits...)
+	sort.Slice(edits, func(i, j int) bool { return positionBefore(edits[j].Range.Start, edits[i].Range.Start) })
+	for _, edit := range edits {
+		start, end := offsetAt(text, edit.Range.Start), offsetAt(text, edit.Range.End)
+		text = text[:start] + edit.NewText + text[end:]
+	}
+	return text
+}
+
+// sortUsings sorts each block of consecutive usings of the file header: global usings first,
+// then namespaces in usingLess order, then static usings, then aliases
+func sortUsings(text string, systemFirst bool) string {
+	// Lines keep their \r, so sorting preserves line endings
+	raw := strings.Split(text, "\n")
+	header := parseFileHeader(splitLines(text))
+
+	for start := 0; start < len(header.usings); {
+		end := start + 1
+		for end < len(header.usings) && header.usings[end].line == header.usings[end-1].line+1 && header.usings[end].inside == header.usings[start].inside {
+			end++
+		}
+		block := header.usings[start:end]
+		lines := make([]string, len(block))
+		for i, using := range block {
+			lines[i] = raw[using.line]
+		}
+		sort.SliceStable(lines, func(i, j int) bool {
+			ri, ni := usingSortKey(lines[i])
+			rj, nj := usingSortKey(lines[j])
+			if ri != rj {
+				return ri < rj
+			}
+			return usingLess(ni, nj, systemFirst && ri == usingRankNamespace)
+		})
+		copy(raw[block[0].line:], lines)
+		start = end
+	}
+	return strings.Join(raw, "\n")
+}
+
+const (
+	usingRankGlobal = iota
+	usingRankNamespace
+	usingRankStatic
+	usingRankAlias
+)
+
+// usingSortKey returns the group a using directive sorts in, and what it sorts by within it
+func usingSortKey(line string) (int, string) {
+	trimmed := strings.TrimSpace(line)
+	match := usingDirective.FindStringSubmatch(line)
+	switch {
+	case strings.HasPrefix(trimmed, "global"):
+		return usingRankGlobal, trimmed
+	case match[1] != "":
+		return usingRankStatic, match[2]
+	case match[3] != "":
+		return usingRankAlias, match[2]
+	default:
+		return usingRankNamespace, match[2]
+	}
+}
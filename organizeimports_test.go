@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestSortUsings(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		systemFirst bool
+		want        string
+	}{
+		{
+			name:        "System first",
+			text:        "using UnityEngine;\nusing System.Linq;\nusing Cinemachine;\nusing System;\n\nclass Player { }\n",
+			systemFirst: true,
+			want:        "using System;\nusing System.Linq;\nusing Cinemachine;\nusing UnityEngine;\n\nclass Player { }\n",
+		},
+		{
+			name: "alphabetical",
+			text: "using UnityEngine;\nusing System;\nusing Cinemachine;\n\nclass Player { }\n",
+			want: "using Cinemachine;\nusing System;\nusing UnityEngine;\n\nclass Player { }\n",
+		},
+		{
+			name:        "groups",
+			text:        "using Random = UnityEngine.Random;\nusing static UnityEngine.Mathf;\nusing UnityEngine;\nglobal using System;\n",
+			systemFirst: true,
+			want:        "global using System;\nusing UnityEngine;\nusing static UnityEngine.Mathf;\nusing Random = UnityEngine.Random;\n",
+		},
+		{
+			name:        "blocks sorted apart",
+			text:        "using UnityEngine;\nusing System;\n\nusing TMPro;\nusing Cinemachine;\n",
+			systemFirst: true,
+			want:        "using System;\nusing UnityEngine;\n\nusing Cinemachine;\nusing TMPro;\n",
+		},
+		{
+			name:        "inside a namespace",
+			text:        "namespace Game\n{\n    using UnityEngine;\n    using System;\n}\n",
+			systemFirst: true,
+			want:        "namespace Game\n{\n    using System;\n    using UnityEngine;\n}\n",
+		},
+		{
+			name:        "CRLF kept",
+			text:        "using UnityEngine;\r\nusing System;\r\n",
+			systemFirst: true,
+			want:        "using System;\r\nusing UnityEngine;\r\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := sortUsings(test.text, test.systemFirst); got != test.want {
+				t.Errorf("sortUsings = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestOrganizeImports checks the command and the on-save option remove the usings OmniSharp
+// finds unused and sort the rest
+func TestOrganizeImports(t *testing.T) {
+	const text = "using UnityEngine;\nusing System.Linq;\nusing System;\n\nclass Player { }\n"
+	const organized = "using System;\nusing UnityEngine;\n\nclass Player { }\n"
+	// System.Linq, the second line of text
+	linqUnused := []LinePositionSpanTextChange{{StartLine: 1, EndLine: 2}}
+	tests := []struct {
+		name string
+		// onSave organizes on willSaveWaitUntil rather than by the command
+		onSave  bool
+		disable bool
+		text    string
+		// unused are the lines OmniSharp removes
+		unused []LinePositionSpanTextChange
+		want   string
+	}{
+		{name: "command", text: text, unused: linqUnused, want: organized},
+		{name: "command, sorted only", text: "using UnityEngine;\nusing System;\n\nclass Player { }\n", want: organized},
+		{name: "command, already organized", text: organized},
+		{name: "on save", onSave: true, text: text, unused: linqUnused, want: organized},
+		{name: "on save, not configured", onSave: true, disable: true, text: text, unused: linqUnused},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, nil)
+			s, client := newTestServer(t, fake)
+			client.answer(protocol.MethodWorkspaceApplyEdit, protocol.ApplyWorkspaceEditResponse{Applied: true})
+			configure(s, func(config *Config) { config.Usings.OrganizeOnSave = !test.disable })
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, test.text)
+			fake.setHandler("/runfixall", func() interface{} {
+				return map[string]interface{}{"Changes": []ModifiedFileResponse{{FileName: uri.Filename(), Changes: test.unused}}}
+			})
+
+			var edits []protocol.TextEdit
+			if test.onSave {
+				edits = s.handleWillSaveWaitUntil(context.Background(), &protocol.WillSaveTextDocumentParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}})
+			} else {
+				result, err := s.handleExecuteCommand(context.Background(), &protocol.ExecuteCommandParams{
+					Command:   commandOrganizeImports,
+					Arguments: []interface{}{organizeImportsArguments{URI: uri}},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if edit, ok := result.(*protocol.WorkspaceEdit); ok {
+					edits = edit.Changes[uri]
+				}
+				if applied := len(client.received(protocol.MethodWorkspaceApplyEdit)) > 0; applied != (test.want != "") {
+					t.Errorf("applied the edit: %v, want %v", applied, test.want != "")
+				}
+			}
+
+			if test.want == "" {
+				if len(edits) != 0 {
+					t.Errorf("edits = %+v, want none", edits)
+				}
+				return
+			}
+			if got := applyTextEdits(test.text, edits); got != test.want {
+				t.Errorf("organized text = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestOrganizeImportsCodeAction checks the source action is offered to clients asking for
+// source actions, and leaves OmniSharp's fix-alls out
+func TestOrganizeImportsCodeAction(t *testing.T) {
+	tests := []struct {
+		name string
+		only []protocol.CodeActionKind
+		want []protocol.CodeActionKind
+	}{
+		{"any kind", nil, []protocol.CodeActionKind{protocol.SourceOrganizeImports, protocol.QuickFix, protocol.QuickFix, protocol.QuickFix}},
+		{"source", []protocol.CodeActionKind{protocol.Source}, []protocol.CodeActionKind{protocol.SourceOrganizeImports}},
+		{"organize imports", []protocol.CodeActionKind{protocol.SourceOrganizeImports}, []protocol.CodeActionKind{protocol.SourceOrganizeImports}},
+		{"quick fixes", []protocol.CodeActionKind{protocol.QuickFix}, []protocol.CodeActionKind{protocol.QuickFix, protocol.QuickFix, protocol.QuickFix}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{
+				"/getfixall": map[string]interface{}{"Items": []FixAllItem{unusedUsings}},
+			})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, "using System;\nclass Player { }\n")
+
+			actions, err := s.handleCodeAction(context.Background(), &protocol.CodeActionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Context: protocol.CodeActionContext{
+					Diagnostics: []protocol.Diagnostic{{Code: "IDE0005"}},
+					Only:        test.only,
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var kinds []protocol.CodeActionKind
+			for _, action := range actions {
+				kinds = append(kinds, action.Kind)
+				if action.Kind == protocol.SourceOrganizeImports && (action.Command == nil || action.Command.Command != commandOrganizeImports) {
+					t.Errorf("%q runs %+v", action.Title, action.Command)
+				}
+			}
+			if !reflect.DeepEqual(kinds, test.want) {
+				t.Errorf("action kinds = %v, want %v", kinds, test.want)
+			}
+		})
+	}
+}
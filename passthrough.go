@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.lsp.dev/jsonrpc2"
+)
+
+// methodOmniSharp forwards a request to an OmniSharp endpoint and returns its response as is,
+// for the endpoints of OmniSharp plugins the server has no feature for. Only the endpoints of
+// omnisharp.customEndpoints are forwarded
+const methodOmniSharp = "unity-lsp/omnisharp"
+
+// OmniSharpParams are the params of unity-lsp/omnisharp
+type OmniSharpParams struct {
+	// Endpoint is the path of the endpoint, such as /myplugin/analyze
+	Endpoint string `json:"endpoint"`
+	// Body is the JSON body of the request, an empty object if left out
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// handleOmniSharp posts params.Body to an allowed endpoint. An empty response is null
+func (s *Server) handleOmniSharp(ctx context.Context, params *OmniSharpParams) (json.RawMessage, error) {
+	endpoint := "/" + strings.TrimPrefix(params.Endpoint, "/")
+	if !s.currentConfig().OmniSharp.allowsEndpoint(endpoint) {
+		return nil, lspError(jsonrpc2.InvalidParams, fmt.Sprintf("%s is not one of omnisharp.customEndpoints", endpoint))
+	}
+	omnisharp := s.backend()
+	if omnisharp == nil {
+		return nil, errors.New("OmniSharp is not ready")
+	}
+
+	body := params.Body
+	if len(body) == 0 {
+		body = json.RawMessage("{}")
+	}
+	response, err := omnisharp.SendRequest(ctx, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if len(response) == 0 {
+		return json.RawMessage("null"), nil
+	}
+	if !json.Valid(response) {
+		return nil, fmt.Errorf("%s answered with invalid JSON", endpoint)
+	}
+	return response, nil
+}
+
+// allowsEndpoint reports whether unity-lsp/omnisharp may forward to endpoint, listed with or
+// without its leading slash
+func (c OmniSharpConfig) allowsEndpoint(endpoint string) bool {
+	for _, allowed := range c.CustomEndpoints {
+		if "/"+strings.TrimPrefix(allowed, "/") == endpoint {
+			return true
+		}
+	}
+	return false
+}
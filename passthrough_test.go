@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/jsonrpc2"
+)
+
+func TestOmniSharpPassthroughAllowlist(t *testing.T) {
+	tests := []struct {
+		name      string
+		allowed   []string
+		endpoint  string
+		body      string
+		wantPath  string
+		wantBody  string
+		wantError bool
+	}{
+		{name: "allowed", allowed: []string{"/myplugin/analyze"}, endpoint: "/myplugin/analyze", body: `{"FileName":"A.cs"}`, wantPath: "/myplugin/analyze", wantBody: `{"FileName":"A.cs"}`},
+		{name: "allowed without the slash", allowed: []string{"myplugin/analyze"}, endpoint: "/myplugin/analyze", wantPath: "/myplugin/analyze", wantBody: `{}`},
+		{name: "asked without the slash", allowed: []string{"/myplugin/analyze"}, endpoint: "myplugin/analyze", wantPath: "/myplugin/analyze", wantBody: `{}`},
+		{name: "not listed", allowed: []string{"/myplugin/analyze"}, endpoint: "/codecheck", wantError: true},
+		{name: "a prefix of one listed", allowed: []string{"/myplugin/analyze"}, endpoint: "/myplugin", wantError: true},
+		{name: "walking out of one listed", allowed: []string{"/myplugin"}, endpoint: "/myplugin/../codecheck", wantError: true},
+		{name: "nothing listed", endpoint: "/myplugin/analyze", wantError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{test.wantPath: map[string]int{"Count": 2}})
+			s, _ := newTestServer(t, fake)
+			configure(s, func(config *Config) { config.OmniSharp.CustomEndpoints = test.allowed })
+
+			response, err := s.handleOmniSharp(context.Background(), &OmniSharpParams{Endpoint: test.endpoint, Body: json.RawMessage(test.body)})
+			if test.wantError {
+				var wireErr *jsonrpc2.Error
+				if !errors.As(err, &wireErr) || wireErr.Code != jsonrpc2.InvalidParams {
+					t.Errorf("error = %v, want InvalidParams", err)
+				}
+				fake.mu.Lock()
+				defer fake.mu.Unlock()
+				if len(fake.calls) != 0 {
+					t.Errorf("forwarded to OmniSharp: %v", fake.calls)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.TrimSpace(string(response)) != `{"Count":2}` {
+				t.Errorf("response = %s", response)
+			}
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			if bodies := fake.bodies[test.wantPath]; len(bodies) != 1 || string(bodies[0]) != test.wantBody {
+				t.Errorf("%s got %s, want %s", test.wantPath, bodies, test.wantBody)
+			}
+		})
+	}
+}
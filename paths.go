@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// pathToURI converts a filename from an OmniSharp response into a file:// URI. OmniSharp
+// reports native paths, so Windows drive and UNC paths arrive with backslashes wherever the
+// server happens to run; URIs it already formatted are passed through
+func pathToURI(path string) protocol.DocumentURI {
+	if strings.HasPrefix(path, "file://") {
+		return protocol.DocumentURI(path)
+	}
+
+	u := url.URL{Scheme: "file"}
+	switch {
+	case isWindowsDrivePath(path):
+		u.Path = "/" + strings.ReplaceAll(path, `\`, "/")
+	case strings.HasPrefix(path, `\\`):
+		// UNC path: \\server\share\file.cs
+		parts := strings.SplitN(strings.ReplaceAll(path[2:], `\`, "/"), "/", 2)
+		u.Host = parts[0]
+		if len(parts) == 2 {
+			u.Path = "/" + parts[1]
+		}
+	default:
+		u.Path = path
+	}
+	return protocol.DocumentURI(u.String())
+}
+
+// resolveOmniSharpPath makes a filename from an OmniSharp response absolute. Some OmniSharp
+// configurations report navigation results relative to the solution, which is always in the
+// workspace root
+func (s *Server) resolveOmniSharpPath(path string) string {
+	if path == "" || filepath.IsAbs(path) || strings.HasPrefix(path, "/") || strings.HasPrefix(path, `\\`) ||
+		strings.HasPrefix(path, "file://") || isWindowsDrivePath(path) {
+		return path
+	}
+	return filepath.Join(s.rootPath, path)
+}
+
+// caseInsensitivePaths is set where filesystems usually ignore case, so the same file can
+// arrive under URIs cased differently
+const caseInsensitivePaths = runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+
+// documentKey identifies the file uri names however the client escaped and cased it, so the
+// stores keyed by it can't hold one file twice. Its path is unescaped, and case-folded where
+// paths are case-insensitive. URIs of other schemes are their own key
+func documentKey(uri protocol.DocumentURI) protocol.DocumentURI {
+	return foldedDocumentKey(uri, caseInsensitivePaths)
+}
+
+// foldedDocumentKey is documentKey, case-folding the path only when caseInsensitive is set
+func foldedDocumentKey(uri protocol.DocumentURI, caseInsensitive bool) protocol.DocumentURI {
+	u, err := url.Parse(string(uri))
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	key := "file://" + u.Host + u.Path
+	if caseInsensitive {
+		key = strings.ToLower(key)
+	}
+	return protocol.DocumentURI(key)
+}
+
+func isWindowsDrivePath(path string) bool {
+	if len(path) < 3 || path[1] != ':' || (path[2] != '\\' && path[2] != '/') {
+		return false
+	}
+	c := path[0]
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
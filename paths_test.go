@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestDocumentKey(t *testing.T) {
+	tests := []struct {
+		uri             protocol.DocumentURI
+		caseInsensitive bool
+		want            protocol.DocumentURI
+	}{
+		{"file:///project/Assets/Player.cs", false, "file:///project/Assets/Player.cs"},
+		{"file:///project/Assets/Player.cs", true, "file:///project/assets/player.cs"},
+		{"file:///c%3A/Project/Player.cs", false, "file:///c:/Project/Player.cs"},
+		{"file:///C:/Project/Player.cs", true, "file:///c:/project/player.cs"},
+		{"file:///project/My%20Game/Player.cs", false, "file:///project/My Game/Player.cs"},
+		{"file://server/share/Player.cs", true, "file://server/share/player.cs"},
+		// Only file paths are folded
+		{"untitled:Untitled-1", true, "untitled:Untitled-1"},
+	}
+	for _, test := range tests {
+		if got := foldedDocumentKey(test.uri, test.caseInsensitive); got != test.want {
+			t.Errorf("foldedDocumentKey(%s) with case-insensitive paths %v = %s, want %s", test.uri, test.caseInsensitive, got, test.want)
+		}
+	}
+}
+
+func TestPathToURI(t *testing.T) {
+	tests := []struct {
+		path string
+		want protocol.DocumentURI
+	}{
+		{"/home/dev/Game/Assets/Player.cs", "file:///home/dev/Game/Assets/Player.cs"},
+		{"/home/dev/My Game/Assets/Player.cs", "file:///home/dev/My%20Game/Assets/Player.cs"},
+		{"/home/dev/Game/Assets/C#/Player.cs", "file:///home/dev/Game/Assets/C%23/Player.cs"},
+		{`C:\Users\dev\My Game\Assets\Player.cs`, "file:///C:/Users/dev/My%20Game/Assets/Player.cs"},
+		{"c:/Users/dev/Game/Player.cs", "file:///c:/Users/dev/Game/Player.cs"},
+		{`\\server\share\My Game\Player.cs`, "file://server/share/My%20Game/Player.cs"},
+		{"file:///home/dev/Game/Player.cs", "file:///home/dev/Game/Player.cs"},
+	}
+	for _, test := range tests {
+		got := pathToURI(test.path)
+		if got != test.want {
+			t.Errorf("pathToURI(%q) = %s, want %s", test.path, got, test.want)
+		}
+		if _, err := url.Parse(string(got)); err != nil {
+			t.Errorf("pathToURI(%q) = %s, not a valid URI: %v", test.path, got, err)
+		}
+	}
+}
+
+func TestResolveOmniSharpPath(t *testing.T) {
+	s := &Server{rootPath: "/home/dev/Game"}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"Assets/Player.cs", "/home/dev/Game/Assets/Player.cs"},
+		{"./Assets/Player.cs", "/home/dev/Game/Assets/Player.cs"},
+		{"../Shared/Utils.cs", "/home/dev/Shared/Utils.cs"},
+		{"/home/dev/Game/Assets/Player.cs", "/home/dev/Game/Assets/Player.cs"},
+		{`C:\Users\dev\Game\Player.cs`, `C:\Users\dev\Game\Player.cs`},
+		{"c:/Users/dev/Game/Player.cs", "c:/Users/dev/Game/Player.cs"},
+		{`\\server\share\Player.cs`, `\\server\share\Player.cs`},
+		{"file:///home/dev/Game/Player.cs", "file:///home/dev/Game/Player.cs"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := s.resolveOmniSharpPath(test.path); got != test.want {
+			t.Errorf("resolveOmniSharpPath(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
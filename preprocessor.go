@@ -0,0 +1,292 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"go.lsp.dev/protocol"
+)
+
+var (
+	// defineConstants captures the preprocessor symbols a project defines, Unity's among them
+	defineConstants = regexp.MustCompile(`<DefineConstants>([^<]*)</DefineConstants>`)
+	// conditionalDirective captures the directives of conditional compilation and their condition
+	conditionalDirective = regexp.MustCompile(`^\s*#\s*(if|elif|else|endif|define|undef)\b(.*)$`)
+)
+
+// projectFiles caches the contents of the workspace projects projectFor reads, which it looks
+// through on every completion and diagnostics pass, until they change on disk
+type projectFiles struct {
+	mu    sync.Mutex
+	files map[string]projectFile
+}
+
+type projectFile struct {
+	modTime time.Time
+	size    int64
+	text    string
+}
+
+func newProjectFiles() *projectFiles {
+	return &projectFiles{files: make(map[string]projectFile)}
+}
+
+// read returns the contents of the project at path, read again only once it has changed
+func (p *projectFiles) read(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	p.mu.Lock()
+	cached, ok := p.files[path]
+	p.mu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.text, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	p.mu.Lock()
+	p.files[path] = projectFile{modTime: info.ModTime(), size: info.Size(), text: string(data)}
+	p.mu.Unlock()
+	return string(data), nil
+}
+
+// projectFor returns the contents of the workspace project listing path, or "" when none
+// does. Unity lists scripts with backslashes. SDK projects usually don't list them at all, and
+// another project's symbols would be no better a guess than none
+func (p *projectFiles) projectFor(path, rootPath string) string {
+	projects, _ := filepath.Glob(filepath.Join(rootPath, "*.csproj"))
+	rel, _ := filepath.Rel(rootPath, path)
+	rel = filepath.ToSlash(rel)
+
+	for _, candidate := range projects {
+		text, err := p.read(candidate)
+		if err != nil {
+			continue
+		}
+		if strings.Contains(text, rel) || strings.Contains(text, strings.ReplaceAll(rel, "/", `\`)) {
+			return text
+		}
+	}
+	return ""
+}
+
+// projectDefines returns the symbols of every DefineConstants of project. Configurations
+// defining different symbols are merged, so code is only taken for disabled when it is in
+// none of them
+func projectDefines(project string) map[string]bool {
+	defines := make(map[string]bool)
+	for _, match := range defineConstants.FindAllStringSubmatch(project, -1) {
+		for _, symbol := range strings.FieldsFunc(match[1], func(r rune) bool { return r == ';' || r == ',' || unicode.IsSpace(r) }) {
+			if !strings.HasPrefix(symbol, "$(") {
+				defines[symbol] = true
+			}
+		}
+	}
+	return defines
+}
+
+// conditionalFrame is an #if block enclosing the line being scanned
+type conditionalFrame struct {
+	// outer tells whether the code around the block is compiled, taken whether one of its
+	// branches so far was, and active whether the current branch is
+	outer, taken, active bool
+}
+
+// inDisabledRegion reports whether line of text is in a branch of #if, #elif or #else that
+// isn't compiled with defines and the symbols the file #defines before it
+func inDisabledRegion(text string, line uint32, defines map[string]bool) bool {
+	symbols := make(map[string]bool, len(defines))
+	for symbol := range defines {
+		symbols[symbol] = true
+	}
+
+	var stack []conditionalFrame
+	active := true
+	for i, current := range splitLines(text) {
+		if uint32(i) >= line {
+			break
+		}
+		match := conditionalDirective.FindStringSubmatch(current)
+		if match == nil {
+			continue
+		}
+		condition := strings.TrimSpace(match[2])
+		if comment := strings.Index(condition, "//"); comment >= 0 {
+			condition = strings.TrimSpace(condition[:comment])
+		}
+
+		switch match[1] {
+		case "define", "undef":
+			if active {
+				symbols[condition] = match[1] == "define"
+			}
+		case "if":
+			taken := active && evaluateCondition(condition, symbols)
+			stack = append(stack, conditionalFrame{outer: active, taken: taken, active: taken})
+		case "elif", "else":
+			if len(stack) == 0 {
+				continue
+			}
+			frame := &stack[len(stack)-1]
+			frame.active = frame.outer && !frame.taken && (match[1] == "else" || evaluateCondition(condition, symbols))
+			frame.taken = frame.taken || frame.active
+		case "endif":
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+		active = len(stack) == 0 || stack[len(stack)-1].active
+	}
+	return !active
+}
+
+// evaluateCondition evaluates the condition of #if or #elif. Conditions that don't parse are
+// taken for true, leaving the code enabled
+func evaluateCondition(condition string, symbols map[string]bool) bool {
+	parser := conditionParser{tokens: conditionTokens(condition), symbols: symbols}
+	value := parser.or()
+	if parser.failed || parser.pos != len(parser.tokens) {
+		return true
+	}
+	return value
+}
+
+// conditionTokens splits a condition into symbols and the operators !, &&, ||, ==, != and
+// parentheses
+func conditionTokens(condition string) []string {
+	var tokens []string
+	for i := 0; i < len(condition); {
+		c := condition[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case strings.HasPrefix(condition[i:], "&&"), strings.HasPrefix(condition[i:], "||"), strings.HasPrefix(condition[i:], "=="), strings.HasPrefix(condition[i:], "!="):
+			tokens = append(tokens, condition[i:i+2])
+			i += 2
+		case c == '!' || c == '(' || c == ')':
+			tokens = append(tokens, condition[i:i+1])
+			i++
+		default:
+			end := i
+			for end < len(condition) {
+				r, size := utf8.DecodeRuneInString(condition[end:])
+				if !isIdentifierRune(r) {
+					break
+				}
+				end += size
+			}
+			if end == i {
+				// An unexpected character, which fails the parse
+				end++
+			}
+			tokens = append(tokens, condition[i:end])
+			i = end
+		}
+	}
+	return tokens
+}
+
+// conditionParser evaluates condition tokens by recursive descent, with C#'s precedence: !,
+// then == and !=, then &&, then ||
+type conditionParser struct {
+	tokens  []string
+	pos     int
+	symbols map[string]bool
+	failed  bool
+}
+
+func (p *conditionParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *conditionParser) or() bool {
+	value := p.and()
+	for p.peek() == "||" {
+		p.pos++
+		right := p.and()
+		value = value || right
+	}
+	return value
+}
+
+func (p *conditionParser) and() bool {
+	value := p.equality()
+	for p.peek() == "&&" {
+		p.pos++
+		right := p.equality()
+		value = value && right
+	}
+	return value
+}
+
+func (p *conditionParser) equality() bool {
+	value := p.unary()
+	for p.peek() == "==" || p.peek() == "!=" {
+		equal := p.peek() == "=="
+		p.pos++
+		right := p.unary()
+		value = (value == right) == equal
+	}
+	return value
+}
+
+func (p *conditionParser) unary() bool {
+	switch token := p.peek(); {
+	case token == "!":
+		p.pos++
+		return !p.unary()
+	case token == "(":
+		p.pos++
+		value := p.or()
+		if p.peek() != ")" {
+			p.failed = true
+		}
+		p.pos++
+		return value
+	case token == "true":
+		p.pos++
+		return true
+	case token == "false":
+		p.pos++
+		return false
+	case token != "" && isIdentifierStart(token):
+		p.pos++
+		return p.symbols[token]
+	default:
+		p.failed = true
+		p.pos++
+		return false
+	}
+}
+
+func isIdentifierStart(token string) bool {
+	r := []rune(token)[0]
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// inDisabledCode reports whether pos in doc is in a region its project's symbols disable.
+// OmniSharp reads the symbols from the project itself, so they only decide here whether to
+// ask it at all. Without a project nothing is taken for disabled
+func (s *Server) inDisabledCode(doc Document, pos protocol.Position) bool {
+	if !strings.Contains(doc.Text, "#if") {
+		return false
+	}
+	project := s.projectFor(doc.URI.Filename())
+	if project == "" {
+		return false
+	}
+	return inDisabledRegion(doc.Text, pos.Line, projectDefines(project))
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestEvaluateCondition(t *testing.T) {
+	symbols := map[string]bool{"UNITY_EDITOR": true, "UNITY_2022_3": true}
+	tests := []struct {
+		condition string
+		want      bool
+	}{
+		{"UNITY_EDITOR", true},
+		{"UNITY_ANDROID", false},
+		{"!UNITY_EDITOR", false},
+		{"UNITY_EDITOR && UNITY_ANDROID", false},
+		{"UNITY_EDITOR || UNITY_ANDROID", true},
+		{"UNITY_ANDROID || UNITY_IOS && UNITY_EDITOR", false},
+		{"(UNITY_ANDROID || UNITY_IOS) && !UNITY_EDITOR", false},
+		{"!(UNITY_ANDROID || UNITY_IOS)", true},
+		{"UNITY_EDITOR == UNITY_2022_3", true},
+		{"UNITY_EDITOR != true", false},
+		{"false", false},
+		// Conditions that don't parse leave the code enabled
+		{"UNITY_EDITOR &&", true},
+		{"(UNITY_ANDROID", true},
+		{"UNITY_ANDROID UNITY_IOS", true},
+		{"", true},
+	}
+	for _, test := range tests {
+		t.Run(test.condition, func(t *testing.T) {
+			if got := evaluateCondition(test.condition, symbols); got != test.want {
+				t.Errorf("evaluateCondition = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestInDisabledRegion(t *testing.T) {
+	defines := map[string]bool{"UNITY_EDITOR": true}
+	tests := []struct {
+		name string
+		// text has | on the line asked about
+		text string
+		want bool
+	}{
+		{"taken branch", "#if UNITY_EDITOR\n|\n#endif\n", false},
+		{"untaken branch", "#if UNITY_ANDROID\n|\n#endif\n", true},
+		{"after the block", "#if UNITY_ANDROID\n#endif\n|\n", false},
+		{"else of an untaken branch", "#if UNITY_ANDROID\n#else\n|\n#endif\n", false},
+		{"else of a taken branch", "#if UNITY_EDITOR\n#else\n|\n#endif\n", true},
+		{"elif taken", "#if UNITY_ANDROID\n#elif UNITY_EDITOR\n|\n#endif\n", false},
+		{"elif after a taken branch", "#if UNITY_EDITOR\n#elif UNITY_EDITOR\n|\n#endif\n", true},
+		{"nested in an untaken branch", "#if UNITY_ANDROID\n#if UNITY_EDITOR\n|\n#endif\n#endif\n", true},
+		{"else nested in an untaken branch", "#if UNITY_ANDROID\n#if UNITY_IOS\n#else\n|\n#endif\n#endif\n", true},
+		{"defined in the file", "#define CHEATS\n#if CHEATS\n|\n#endif\n", false},
+		{"undefined in the file", "#undef UNITY_EDITOR\n#if UNITY_EDITOR\n|\n#endif\n", true},
+		{"indented, with a comment", "  #  if UNITY_ANDROID // mobile only\n|\n  #endif\n", true},
+		{"stray endif", "#endif\n|\n", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			line := uint32(strings.Count(test.text[:strings.Index(test.text, "|")], "\n"))
+			text := strings.Replace(test.text, "|", "", 1)
+			if got := inDisabledRegion(text, line, defines); got != test.want {
+				t.Errorf("inDisabledRegion = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestProjectDefines(t *testing.T) {
+	project := "<Project>\n" +
+		"  <PropertyGroup><DefineConstants>UNITY_EDITOR;UNITY_2022_3;$(DefineConstants)</DefineConstants></PropertyGroup>\n" +
+		"  <PropertyGroup Condition=\" '$(Configuration)' == 'Debug' \"><DefineConstants>DEBUG, TRACE</DefineConstants></PropertyGroup>\n" +
+		"</Project>\n"
+	want := map[string]bool{"UNITY_EDITOR": true, "UNITY_2022_3": true, "DEBUG": true, "TRACE": true}
+	if got := projectDefines(project); !reflect.DeepEqual(got, want) {
+		t.Errorf("projectDefines = %v, want %v", got, want)
+	}
+}
+
+func TestProjectFor(t *testing.T) {
+	const runtime, editor = `<Project><ItemGroup><Compile Include="Assets\Scripts\Player.cs" /></ItemGroup></Project>`, `<Project><ItemGroup><Compile Include="Assets/Editor/PlayerEditor.cs" /></ItemGroup></Project>`
+	tests := []struct {
+		name     string
+		projects map[string]string
+		path     string
+		want     string
+	}{
+		{"listed with backslashes", map[string]string{"Assembly-CSharp.csproj": runtime, "Assembly-CSharp-Editor.csproj": editor}, "Assets/Scripts/Player.cs", runtime},
+		{"listed with slashes", map[string]string{"Assembly-CSharp.csproj": runtime, "Assembly-CSharp-Editor.csproj": editor}, "Assets/Editor/PlayerEditor.cs", editor},
+		{"listed nowhere", map[string]string{"Assembly-CSharp-Editor.csproj": editor, "Assembly-CSharp.csproj": runtime}, "Assets/Enemy.cs", ""},
+		{"no project", nil, "Assets/Scripts/Player.cs", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, test.projects)
+			if got := newProjectFiles().projectFor(filepath.Join(root, test.path), root); got != test.want {
+				t.Errorf("projectFor = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestProjectFilesCached checks a project is read again only once it changes on disk
+func TestProjectFilesCached(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "Assembly-CSharp.csproj")
+	writeFiles(t, root, map[string]string{"Assembly-CSharp.csproj": "<Project>DEBUG</Project>"})
+	files := newProjectFiles()
+	if text, err := files.read(path); err != nil || text != "<Project>DEBUG</Project>" {
+		t.Fatalf("read = %q, %v", text, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rewritten in place, as far as the file system tells
+	writeFiles(t, root, map[string]string{"Assembly-CSharp.csproj": "<Project>TRACE</Project>"})
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := files.read(path); text != "<Project>DEBUG</Project>" {
+		t.Errorf("read the unchanged project again: %q", text)
+	}
+	later := info.ModTime().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := files.read(path); text != "<Project>TRACE</Project>" {
+		t.Errorf("read = %q after the project changed", text)
+	}
+}
+
+// TestCompletionInDisabledRegion checks completion in a branch the project's defines leave
+// out is answered empty without asking OmniSharp, and served as usual elsewhere
+func TestCompletionInDisabledRegion(t *testing.T) {
+	const text = "class Player {\n" +
+		"    void Update() {\n" +
+		"#if UNITY_EDITOR\n" +
+		"        s\n" +
+		"#endif\n" +
+		"#if UNITY_ANDROID\n" +
+		"        s\n" +
+		"#else\n" +
+		"        s\n" +
+		"#endif\n" +
+		"        s\n" +
+		"    }\n" +
+		"}\n"
+	tests := []struct {
+		name    string
+		project string
+		line    uint32
+		want    bool
+	}{
+		{"defined", "<DefineConstants>UNITY_EDITOR;UNITY_STANDALONE</DefineConstants>", 3, true},
+		{"not defined", "<DefineConstants>UNITY_EDITOR;UNITY_STANDALONE</DefineConstants>", 6, false},
+		{"else of not defined", "<DefineConstants>UNITY_EDITOR;UNITY_STANDALONE</DefineConstants>", 8, true},
+		{"outside the blocks", "<DefineConstants>UNITY_EDITOR;UNITY_STANDALONE</DefineConstants>", 10, true},
+		{"defined for Android", "<DefineConstants>UNITY_ANDROID</DefineConstants>", 6, true},
+		{"no project", "", 6, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": autoCompleteItems("speed")})
+			s, _ := newTestServer(t, fake)
+			if test.project != "" {
+				writeFiles(t, s.rootPath, map[string]string{"Assembly-CSharp.csproj": "<Project><PropertyGroup>" + test.project + `</PropertyGroup><ItemGroup><Compile Include="Player.cs" /></ItemGroup></Project>`})
+			}
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, text)
+
+			list := completeAt(t, s, uri, protocol.Position{Line: test.line, Character: 9}, protocol.CompletionTriggerKindInvoked)
+			completed, asked := len(list.Items) > 0, fake.callCount("/autocomplete") > 0
+			if completed != test.want || asked != test.want {
+				t.Errorf("completions = %v, asked OmniSharp %v; want served %v", labels(list.Items), asked, test.want)
+			}
+		})
+	}
+}
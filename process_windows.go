@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+const (
+	processQueryLimitedInformation = 0x1000
+	// stillActive is the exit code of processes that haven't exited
+	stillActive = 259
+)
+
+// processAlive reports whether a process with pid is running. A handle can still be opened to
+// a process that has exited while others hold handles to it, so its exit code tells
+func processAlive(pid int) bool {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		// Processes of other users can't be opened, but are running
+		return errors.Is(err, syscall.ERROR_ACCESS_DENIED)
+	}
+	defer syscall.CloseHandle(handle)
+
+	var code uint32
+	if err := syscall.GetExitCodeProcess(handle, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
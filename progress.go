@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"go.lsp.dev/protocol"
+)
+
+// progressTokens numbers the progress tokens we create ourselves
+var progressTokens atomic.Int32
+
+// workDoneProgress reports a long-running operation in the editor. Without a token, because
+// the client can't show progress, reporting does nothing
+type workDoneProgress struct {
+	client protocol.Client
+	token  *protocol.ProgressToken
+}
+
+// beginProgress starts reporting under token, the one the client sent with its request, or a
+// new one if it sent none. It must not run on the read loop, which delivers the client's answer
+func (s *Server) beginProgress(ctx context.Context, token *protocol.ProgressToken, title string) *workDoneProgress {
+	progress := &workDoneProgress{client: s.client, token: token}
+	if progress.token == nil {
+		if !s.supportsWorkDoneProgress() {
+			return progress
+		}
+		created := protocol.NewProgressToken(fmt.Sprintf("unity-lsp/%d", progressTokens.Add(1)))
+		if err := s.client.WorkDoneProgressCreate(ctx, &protocol.WorkDoneProgressCreateParams{Token: *created}); err != nil {
+			log.Printf("failed to create progress for %q: %v", title, err)
+			return progress
+		}
+		progress.token = created
+	}
+
+	progress.send(ctx, &protocol.WorkDoneProgressBegin{Kind: protocol.WorkDoneProgressKindBegin, Title: title})
+	return progress
+}
+
+func (p *workDoneProgress) report(ctx context.Context, message string) {
+	p.send(ctx, &protocol.WorkDoneProgressReport{Kind: protocol.WorkDoneProgressKindReport, Message: message})
+}
+
+func (p *workDoneProgress) end(ctx context.Context, message string) {
+	p.send(ctx, &protocol.WorkDoneProgressEnd{Kind: protocol.WorkDoneProgressKindEnd, Message: message})
+}
+
+func (p *workDoneProgress) send(ctx context.Context, value interface{}) {
+	if p.token == nil {
+		return
+	}
+	p.client.Progress(ctx, &protocol.ProgressParams{Token: *p.token, Value: value})
+}
+
+func (s *Server) supportsWorkDoneProgress() bool {
+	return s.capabilities.Window != nil && s.capabilities.Window.WorkDoneProgress
+}
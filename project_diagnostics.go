@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.lsp.dev/protocol"
+)
+
+// msbuildDiagnostic matches MSBuild's canonical error format as it appears in OmniSharp's
+// output, such as "/p/Game.csproj(12,5): error MSB4236: The SDK ... could not be found." or
+// "/p/Game.csproj : error NU1101: Unable to find package Foo."
+var msbuildDiagnostic = regexp.MustCompile(`^\s*(.+?\.(?:csproj|props|targets))\s*(?:\((\d+)(?:,\d+)?\))?\s*:\s*(error|warning)\s+([A-Z]+\d+)\s*:\s*(.+)$`)
+
+// unresolvedFixes suggests a fix for each MSBuild and NuGet code meaning a dependency
+// couldn't be resolved; other codes are left to the build
+var unresolvedFixes = map[string]string{
+	"MSB4236": "Install the .NET SDK it names, or pin an installed one in global.json.",
+	"MSB4019": "Check that the imported file exists, or regenerate the project files from Unity.",
+	"NU1101":  "Run dotnet restore, and check the package name and package sources.",
+	"NU1102":  "Run dotnet restore, or reference a version available from the package sources.",
+	"MSB3245": "Regenerate the project files from Unity (Preferences > External Tools > Regenerate project files).",
+}
+
+// unresolvedDependency is a dependency a project file refers to that OmniSharp couldn't resolve
+type unresolvedDependency struct {
+	File string
+	// Line is zero-based, 0 when OmniSharp didn't say
+	Line     uint32
+	Severity protocol.DiagnosticSeverity
+	Code     string
+	// Name is the missing SDK, package or assembly when the message quotes it
+	Name    string
+	Message string
+}
+
+// parseUnresolvedDependency recognizes an OmniSharp output line reporting an unresolved SDK,
+// package, import or reference
+func parseUnresolvedDependency(line string) (unresolvedDependency, bool) {
+	match := msbuildDiagnostic.FindStringSubmatch(line)
+	if match == nil {
+		return unresolvedDependency{}, false
+	}
+	if _, ok := unresolvedFixes[match[4]]; !ok {
+		return unresolvedDependency{}, false
+	}
+
+	dependency := unresolvedDependency{
+		File:     match[1],
+		Severity: protocol.DiagnosticSeverityError,
+		Code:     match[4],
+		Name:     missingDependencyName(match[5]),
+		Message:  strings.TrimSpace(match[5]),
+	}
+	if line, err := strconv.ParseUint(match[2], 10, 32); err == nil && line > 0 {
+		dependency.Line = uint32(line - 1)
+	}
+	if match[3] == "warning" {
+		dependency.Severity = protocol.DiagnosticSeverityWarning
+	}
+	return dependency, true
+}
+
+// missingDependencyName extracts the quoted name from messages such as The SDK
+// 'Microsoft.NET.Sdk' specified could not be found, or the package of NuGet's Unable to find
+// package Foo
+func missingDependencyName(message string) string {
+	for _, quote := range []string{"'", `"`} {
+		if start := strings.Index(message, quote); start >= 0 {
+			if end := strings.Index(message[start+1:], quote); end > 0 {
+				return message[start+1 : start+1+end]
+			}
+		}
+	}
+	if rest, ok := strings.CutPrefix(message, "Unable to find package "); ok {
+		return strings.TrimRight(strings.Fields(rest + " ")[0], ".")
+	}
+	return ""
+}
+
+// diagnostic describes d on its line of the project file, naming what is missing and how to fix it
+func (d unresolvedDependency) diagnostic() protocol.Diagnostic {
+	message := d.Message
+	if !strings.HasSuffix(message, ".") {
+		message += "."
+	}
+	if d.Name != "" {
+		message = fmt.Sprintf("%s could not be resolved: %s", d.Name, message)
+	}
+	return protocol.Diagnostic{
+		Range: protocol.Range{
+			Start: protocol.Position{Line: d.Line},
+			End:   protocol.Position{Line: d.Line + 1},
+		},
+		Severity: d.Severity,
+		Code:     d.Code,
+		Source:   "unity-lsp",
+		Message:  message + " " + unresolvedFixes[d.Code],
+	}
+}
+
+// projectDiagnostics collects the unresolved dependencies reported while OmniSharp loads the
+// solution. Project files aren't open documents, so these stay published until OmniSharp restarts
+type projectDiagnostics struct {
+	mu        sync.Mutex
+	published map[protocol.DocumentURI][]protocol.Diagnostic
+}
+
+func newProjectDiagnostics() *projectDiagnostics {
+	return &projectDiagnostics{published: make(map[protocol.DocumentURI][]protocol.Diagnostic)}
+}
+
+// observe publishes a diagnostic for line if it reports an unresolved dependency not seen yet
+func (p *projectDiagnostics) observe(ctx context.Context, client protocol.Client, line string) {
+	dependency, ok := parseUnresolvedDependency(line)
+	if !ok {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	uri := pathToURI(dependency.File)
+	diagnostic := dependency.diagnostic()
+	for _, existing := range p.published[uri] {
+		if existing.Range == diagnostic.Range && existing.Code == diagnostic.Code && existing.Message == diagnostic.Message {
+			return
+		}
+	}
+	p.published[uri] = append(p.published[uri], diagnostic)
+	client.PublishDiagnostics(ctx, &protocol.PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: p.published[uri],
+	})
+}
+
+// messages lists what has been published, as "file: message", ordered by file
+func (p *projectDiagnostics) messages() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	uris := make([]protocol.DocumentURI, 0, len(p.published))
+	for uri := range p.published {
+		uris = append(uris, uri)
+	}
+	sort.Slice(uris, func(i, j int) bool { return uris[i] < uris[j] })
+
+	messages := []string{}
+	for _, uri := range uris {
+		for _, diagnostic := range p.published[uri] {
+			messages = append(messages, filepath.Base(uri.Filename())+": "+diagnostic.Message)
+		}
+	}
+	return messages
+}
+
+// reset clears everything published, before OmniSharp loads the solution again
+func (p *projectDiagnostics) reset(ctx context.Context, client protocol.Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for uri := range p.published {
+		publishEmpty(ctx, client, uri)
+	}
+	p.published = make(map[protocol.DocumentURI][]protocol.Diagnostic)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestParseUnresolvedDependency(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want *unresolvedDependency
+		// wantMessage is found in the diagnostic's message
+		wantMessage string
+	}{
+		{
+			name: "missing SDK",
+			line: "/project/Game.csproj(12,5): error MSB4236: The SDK 'Microsoft.NET.Sdk' specified could not be found.",
+			want: &unresolvedDependency{
+				File: "/project/Game.csproj", Line: 11, Severity: protocol.DiagnosticSeverityError, Code: "MSB4236",
+				Name: "Microsoft.NET.Sdk", Message: "The SDK 'Microsoft.NET.Sdk' specified could not be found.",
+			},
+			wantMessage: "Microsoft.NET.Sdk could not be resolved: The SDK 'Microsoft.NET.Sdk' specified could not be found. Install the .NET SDK",
+		},
+		{
+			name: "missing package without a line",
+			line: "  /project/Game.csproj : error NU1101: Unable to find package Unity.Analyzers. No packages exist with this id",
+			want: &unresolvedDependency{
+				File: "/project/Game.csproj", Severity: protocol.DiagnosticSeverityError, Code: "NU1101",
+				Name: "Unity.Analyzers", Message: "Unable to find package Unity.Analyzers. No packages exist with this id",
+			},
+			wantMessage: "Unity.Analyzers could not be resolved: Unable to find package Unity.Analyzers. No packages exist with this id. Run dotnet restore",
+		},
+		{
+			name: "unresolved reference warning in props",
+			line: `/project/Directory.Build.props(3): warning MSB3245: Could not resolve this reference. Could not locate the assembly "UnityEditor".`,
+			want: &unresolvedDependency{
+				File: "/project/Directory.Build.props", Line: 2, Severity: protocol.DiagnosticSeverityWarning, Code: "MSB3245",
+				Name: "UnityEditor", Message: `Could not resolve this reference. Could not locate the assembly "UnityEditor".`,
+			},
+			wantMessage: "Regenerate the project files from Unity",
+		},
+		{name: "other MSBuild error", line: "/project/Game.csproj(4,1): error MSB4025: The project file could not be loaded."},
+		{name: "compiler error", line: "/project/Assets/Player.cs(4,1): error CS0103: The name 'x' does not exist"},
+		{name: "log line", line: "[info]: OmniSharp.MSBuild.ProjectManager Loading project: /project/Game.csproj"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dependency, ok := parseUnresolvedDependency(test.line)
+			if test.want == nil {
+				if ok {
+					t.Errorf("parsed %+v", dependency)
+				}
+				return
+			}
+			if !ok || !reflect.DeepEqual(dependency, *test.want) {
+				t.Fatalf("parsed %+v, %v, want %+v", dependency, ok, *test.want)
+			}
+			diagnostic := dependency.diagnostic()
+			if !strings.Contains(diagnostic.Message, test.wantMessage) {
+				t.Errorf("message = %q, want %q in it", diagnostic.Message, test.wantMessage)
+			}
+			if diagnostic.Range.Start.Line != test.want.Line || diagnostic.Severity != test.want.Severity || diagnostic.Code != test.want.Code {
+				t.Errorf("diagnostic = %+v", diagnostic)
+			}
+		})
+	}
+}
+
+func TestProjectDiagnosticsPublished(t *testing.T) {
+	s, client := newTestServer(t, nil)
+	p := newProjectDiagnostics()
+	sdk := "/project/Game.csproj(12,5): error MSB4236: The SDK 'Microsoft.NET.Sdk' specified could not be found."
+	for _, line := range []string{
+		sdk,
+		"[info]: Loading project",
+		sdk,
+		"/project/Game.csproj : error NU1101: Unable to find package Unity.Analyzers.",
+	} {
+		p.observe(context.Background(), s.client, line)
+	}
+	if messages := p.messages(); len(messages) != 2 || !strings.HasPrefix(messages[0], "Game.csproj: Microsoft.NET.Sdk") {
+		t.Errorf("messages = %q", messages)
+	}
+
+	p.reset(context.Background(), s.client)
+	waitFor(t, "the diagnostics to be cleared", func() bool {
+		return len(client.received(protocol.MethodTextDocumentPublishDiagnostics)) == 3
+	})
+	var counts []int
+	for _, raw := range client.received(protocol.MethodTextDocumentPublishDiagnostics) {
+		var params protocol.PublishDiagnosticsParams
+		if err := json.Unmarshal(raw, &params); err != nil {
+			t.Fatal(err)
+		}
+		if params.URI != "file:///project/Game.csproj" {
+			t.Errorf("published for %s", params.URI)
+		}
+		counts = append(counts, len(params.Diagnostics))
+	}
+	if want := []int{1, 2, 0}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("published %v diagnostics, want %v", counts, want)
+	}
+	if messages := p.messages(); len(messages) != 0 {
+		t.Errorf("messages after reset = %q", messages)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// loadedProject is a project OmniSharp loaded, with its contents as of loading
+type loadedProject struct {
+	Path         string
+	AssemblyName string
+	text         string
+}
+
+// projectIndex maps the source files of the solution to the project compiling them, as
+// OmniSharp's /projects lists them once the solution is loaded. It is emptied when OmniSharp
+// stops, and rebuilt when it is ready again
+type projectIndex struct {
+	mu       sync.Mutex
+	files    map[string]*loadedProject
+	projects []*loadedProject
+}
+
+func newProjectIndex() *projectIndex {
+	return &projectIndex{files: make(map[string]*loadedProject)}
+}
+
+// project returns the project compiling path, if the index has it
+func (i *projectIndex) project(path string) (*loadedProject, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	project, ok := i.files[filepath.Clean(path)]
+	return project, ok
+}
+
+// count is the number of projects loaded, 0 before the solution is
+func (i *projectIndex) count() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return len(i.projects)
+}
+
+func (i *projectIndex) reset() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.files = make(map[string]*loadedProject)
+	i.projects = nil
+}
+
+// loadProjects indexes the projects OmniSharp loaded and the files they compile
+func (s *Server) loadProjects(ctx context.Context, omnisharp *OmniSharpClient) {
+	response, err := omnisharp.SendRequest(ctx, "/projects", map[string]interface{}{})
+	if err != nil {
+		log.Printf("failed to list the loaded projects: %v", err)
+		return
+	}
+
+	var workspace struct {
+		MsBuild *struct {
+			Projects []struct {
+				Path         string   `json:"Path"`
+				AssemblyName string   `json:"AssemblyName"`
+				SourceFiles  []string `json:"SourceFiles"`
+			} `json:"Projects"`
+		} `json:"MsBuild"`
+	}
+	if err := json.Unmarshal(response, &workspace); err != nil || workspace.MsBuild == nil {
+		return
+	}
+
+	files := make(map[string]*loadedProject)
+	var projects []*loadedProject
+	for _, listed := range workspace.MsBuild.Projects {
+		project := &loadedProject{Path: s.resolveOmniSharpPath(listed.Path), AssemblyName: listed.AssemblyName}
+		if data, err := os.ReadFile(project.Path); err == nil {
+			project.text = string(data)
+		}
+		projects = append(projects, project)
+		for _, file := range listed.SourceFiles {
+			files[filepath.Clean(s.resolveOmniSharpPath(file))] = project
+		}
+	}
+
+	s.projects.mu.Lock()
+	s.projects.files, s.projects.projects = files, projects
+	s.projects.mu.Unlock()
+	log.Printf("indexed %d source files of %d projects", len(files), len(projects))
+}
+
+// projectFor returns the contents of the project compiling path: the one OmniSharp loaded it
+// in, or before it has loaded the solution the one listing it in the workspace
+func (s *Server) projectFor(path string) string {
+	if project, ok := s.projects.project(path); ok && project.text != "" {
+		return project.text
+	}
+	return s.projectFiles.projectFor(path, s.rootPath)
+}
+
+// assemblyContainer adds the assembly compiling path to the container of a symbol, telling the
+// symbols of several assemblies apart. With a single project there is nothing to tell apart
+func (s *Server) assemblyContainer(path, container string) string {
+	if s.projects.count() < 2 {
+		return container
+	}
+	project, ok := s.projects.project(path)
+	if !ok || project.AssemblyName == "" {
+		return container
+	}
+	if container == "" {
+		return project.AssemblyName
+	}
+	return container + " (" + project.AssemblyName + ")"
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+// projectsResponse is a /projects response of a runtime and an editor assembly, listed
+// relative to the workspace as OmniSharp may
+var projectsResponse = map[string]interface{}{"MsBuild": map[string]interface{}{"Projects": []interface{}{
+	map[string]interface{}{"Path": "Assembly-CSharp.csproj", "AssemblyName": "Assembly-CSharp", "SourceFiles": []string{"Assets/Player.cs", "Assets/Enemy.cs"}},
+	map[string]interface{}{"Path": "Assembly-CSharp-Editor.csproj", "AssemblyName": "Assembly-CSharp-Editor", "SourceFiles": []string{"Assets/Editor/PlayerEditor.cs"}},
+}}}
+
+// TestLoadProjects checks the projects OmniSharp loaded index the files they compile until
+// it stops, and their contents are preferred to searching the workspace
+func TestLoadProjects(t *testing.T) {
+	fake := newFakeOmniSharp(t, map[string]interface{}{"/projects": projectsResponse})
+	s, _ := newTestServer(t, fake)
+	// Neither project lists its files, so searching the workspace can't tell them apart
+	writeFiles(t, s.rootPath, map[string]string{
+		"Assembly-CSharp.csproj":        "<Project><!-- runtime --></Project>",
+		"Assembly-CSharp-Editor.csproj": "<Project><!-- editor --></Project>",
+	})
+	s.loadProjects(context.Background(), s.omnisharp)
+
+	tests := []struct {
+		path         string
+		wantAssembly string
+		wantProject  string
+	}{
+		{"Assets/Player.cs", "Assembly-CSharp", "<Project><!-- runtime --></Project>"},
+		{"Assets/Editor/PlayerEditor.cs", "Assembly-CSharp-Editor", "<Project><!-- editor --></Project>"},
+		{"Assets/Untracked.cs", "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			path := filepath.Join(s.rootPath, test.path)
+			project, ok := s.projects.project(path)
+			if test.wantAssembly == "" {
+				if ok {
+					t.Errorf("indexed in %+v, want no project", project)
+				}
+				return
+			}
+			if !ok || project.AssemblyName != test.wantAssembly {
+				t.Fatalf("indexed in %+v, want %s", project, test.wantAssembly)
+			}
+			if got := s.projectFor(path); got != test.wantProject {
+				t.Errorf("projectFor = %q, want %q", got, test.wantProject)
+			}
+		})
+	}
+	if got := s.projects.count(); got != 2 {
+		t.Errorf("count = %d, want 2", got)
+	}
+
+	s.stopOmniSharp()
+	if _, ok := s.projects.project(filepath.Join(s.rootPath, "Assets/Player.cs")); ok || s.projects.count() != 0 {
+		t.Error("index kept after OmniSharp stopped")
+	}
+}
+
+// TestAssemblyContainer checks workspace symbols name the assembly declaring them when the
+// solution has several
+func TestAssemblyContainer(t *testing.T) {
+	tests := []struct {
+		name     string
+		projects interface{}
+		path     string
+		// container is the one OmniSharp reports
+		container string
+		want      string
+	}{
+		{"runtime", projectsResponse, "Assets/Player.cs", "Game", "Game (Assembly-CSharp)"},
+		{"editor", projectsResponse, "Assets/Editor/PlayerEditor.cs", "Game.Editor", "Game.Editor (Assembly-CSharp-Editor)"},
+		{"global namespace", projectsResponse, "Assets/Enemy.cs", "", "Assembly-CSharp"},
+		{"not indexed", projectsResponse, "Assets/Untracked.cs", "Game", "Game"},
+		{
+			"single project",
+			map[string]interface{}{"MsBuild": map[string]interface{}{"Projects": []interface{}{
+				map[string]interface{}{"Path": "Assembly-CSharp.csproj", "AssemblyName": "Assembly-CSharp", "SourceFiles": []string{"Assets/Player.cs"}},
+			}}},
+			"Assets/Player.cs", "Game", "Game",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/projects": test.projects})
+			s, _ := newTestServer(t, fake)
+			s.loadProjects(context.Background(), s.omnisharp)
+			fake.setResponse("/findsymbols", map[string]interface{}{"QuickFixes": []SymbolLocation{{
+				QuickFix:             QuickFix{FileName: test.path, Text: "Player"},
+				Kind:                 "Class",
+				ContainingSymbolName: test.container,
+			}}})
+
+			result, err := s.handleWorkspaceSymbol(context.Background(), &protocol.WorkspaceSymbolParams{Query: "Player"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			symbols, ok := result.([]protocol.SymbolInformation)
+			if !ok || len(symbols) != 1 || symbols[0].ContainerName != test.want {
+				t.Errorf("found %+v, want Player in %q", result, test.want)
+			}
+		})
+	}
+}
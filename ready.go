@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"log"
+)
+
+// methodReady is sent to the client each time OmniSharp has loaded the solution and requests
+// are fully served, at startup and after every reload
+const methodReady = "unity-lsp/ready"
+
+// ReadyParams are the params of unity-lsp/ready. Warnings are the problems reported while
+// loading, such as unresolved dependencies, also published as diagnostics of the projects
+type ReadyParams struct {
+	Projects int      `json:"projects"`
+	Warnings []string `json:"warnings"`
+}
+
+// notifyReady tells the client that omnisharp is ready, with the number of projects it loaded,
+// once they are indexed
+func (s *Server) notifyReady(ctx context.Context, omnisharp *OmniSharpClient) {
+	s.loadProjects(ctx, omnisharp)
+	params := ReadyParams{Projects: s.projects.count(), Warnings: s.projectDiagnostics.messages()}
+	if err := s.conn.Notify(ctx, methodReady, &params); err != nil {
+		log.Printf("failed to send %s: %v", methodReady, err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestReadyNotification checks unity-lsp/ready is sent once each time OmniSharp loads the
+// solution, with the projects it loaded and the problems reported meanwhile, and not at all
+// when it never finishes
+func TestReadyNotification(t *testing.T) {
+	const sdk = "/project/Game.csproj(12,5): error MSB4236: The SDK 'Microsoft.NET.Sdk' specified could not be found."
+	tests := []struct {
+		name  string
+		ready bool
+		// warn reports an unresolved SDK while loading
+		warn bool
+		want []ReadyParams
+	}{
+		{"ready", true, false, []ReadyParams{{Projects: 2, Warnings: []string{}}, {Projects: 2, Warnings: []string{}}}},
+		{
+			"ready with warnings", true, true,
+			[]ReadyParams{
+				{Projects: 2, Warnings: []string{"Game.csproj: Microsoft.NET.Sdk"}},
+				{Projects: 2, Warnings: []string{"Game.csproj: Microsoft.NET.Sdk"}},
+			},
+		},
+		{"never ready", false, false, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{
+				"/projects": map[string]interface{}{"MsBuild": map[string]interface{}{"Projects": []interface{}{
+					map[string]interface{}{"AssemblyName": "Assembly-CSharp"},
+					map[string]interface{}{"AssemblyName": "Assembly-CSharp-Editor"},
+				}}},
+			})
+			s, client := newTestServer(t, nil)
+			fake.setHandler("/checkreadystatus", func() interface{} {
+				if test.warn {
+					s.projectDiagnostics.observe(context.Background(), s.client, sdk)
+				}
+				return map[string]bool{"Ready": test.ready}
+			})
+			if err := os.WriteFile(filepath.Join(s.rootPath, "Game.sln"), nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			configure(s, func(config *Config) {
+				config.OmniSharp.Address = fake.URL
+				config.OmniSharp.StartupTimeout = Duration(100 * time.Millisecond)
+			})
+
+			s.startOmniSharp(context.Background())
+			if test.ready {
+				waitFor(t, "the first notification", func() bool { return len(client.received(methodReady)) == 1 })
+				// Reloading loads the solution again
+				if _, err := s.handleReloadProjects(context.Background(), &ReloadProjectsParams{}); err != nil {
+					t.Fatal(err)
+				}
+				waitFor(t, "the second notification", func() bool { return len(client.received(methodReady)) == 2 })
+			}
+			// Nothing more is on its way
+			time.Sleep(50 * time.Millisecond)
+
+			var got []ReadyParams
+			for _, raw := range client.received(methodReady) {
+				var params ReadyParams
+				if err := json.Unmarshal(raw, &params); err != nil {
+					t.Fatal(err)
+				}
+				// Only the start of each message is checked, the rest explains the fix
+				for i, warning := range params.Warnings {
+					params.Warnings[i] = warning[:len("Game.csproj: Microsoft.NET.Sdk")]
+				}
+				got = append(got, params)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("notified %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
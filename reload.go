@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+// methodReloadProjects restarts OmniSharp so it loads the solution and projects afresh, e.g.
+// after Unity regenerated them in a way OmniSharp didn't notice
+const methodReloadProjects = "unity-lsp/reloadProjects"
+
+// projectReloadDelay is how long project files must stay unchanged before they trigger a
+// reload, as Unity regenerates them all at once
+const projectReloadDelay = 2 * time.Second
+
+// ReloadProjectsParams are the params of unity-lsp/reloadProjects
+type ReloadProjectsParams struct {
+	protocol.WorkDoneProgressParams
+}
+
+// ReloadProjectsResult tells whether OmniSharp came back with the solution loaded
+type ReloadProjectsResult struct {
+	Ready bool `json:"ready"`
+}
+
+// handleReloadProjects restarts OmniSharp and waits, at most omnisharp.startupTimeout, for it
+// to load the solution
+func (s *Server) handleReloadProjects(ctx context.Context, params *ReloadProjectsParams) (*ReloadProjectsResult, error) {
+	ready, err := s.reloadOmniSharp(ctx, params.WorkDoneToken, "Reloading projects")
+	if err != nil {
+		return nil, err
+	}
+	return &ReloadProjectsResult{Ready: ready}, nil
+}
+
+// reloadOmniSharp restarts OmniSharp under progress titled title, reporting whether it came
+// back. The document store keeps the open buffers, unsaved edits included, which are pushed to
+// the new process once it is ready. Requests served off the read loop meanwhile wait for that
+// rather than being answered as while starting
+func (s *Server) reloadOmniSharp(ctx context.Context, token *protocol.ProgressToken, title string) (bool, error) {
+	s.mu.Lock()
+	switch s.state {
+	case backendStarting:
+		s.mu.Unlock()
+		return false, errors.New("OmniSharp is already loading the solution")
+	case backendNoSolution:
+		s.mu.Unlock()
+		return false, errors.New("there is no solution to reload yet")
+	}
+	// Requests meanwhile are answered as while starting, rather than by the process going away
+	s.state = backendStarting
+	s.omnisharp = nil
+	// Placeholders are requeried as incomplete, which mustn't be answered from before
+	s.completionSession.reset()
+	reloaded := make(chan struct{})
+	s.reloaded = reloaded
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.reloaded = nil
+		s.mu.Unlock()
+		close(reloaded)
+	}()
+
+	progress := s.beginProgress(ctx, token, title)
+	log.Printf("%s, restarting OmniSharp", strings.ToLower(title))
+	s.stopOmniSharp()
+
+	progress.report(ctx, "Waiting for OmniSharp to load the solution")
+	// OmniSharp outlives this request, so it must not be tied to its context. It has the open
+	// buffers synced once it returns
+	s.startOmniSharp(context.Background())
+
+	s.mu.Lock()
+	ready := s.state == backendReady
+	s.mu.Unlock()
+	if ready {
+		progress.end(ctx, "Projects reloaded")
+	} else {
+		progress.end(ctx, "OmniSharp did not come back")
+	}
+	return ready, nil
+}
+
+// reloadPlaceholders answer the requests that are retried as the user goes on, without waiting
+// for a reload: completion comes back incomplete, so the editor asks again as more is typed
+var reloadPlaceholders = map[string]func() interface{}{
+	"completion": func() interface{} { return &CompletionList{IsIncomplete: true, Items: []CompletionItem{}} },
+	"hover":      func() interface{} { return nil },
+	"definition": func() interface{} { return nil },
+}
+
+// reloading reports whether a reload is in progress and hasn't synced the open buffers yet
+func (s *Server) reloading() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reloaded != nil
+}
+
+// awaitReload waits for the reload in progress, if any, to have synced the open buffers
+func (s *Server) awaitReload(ctx context.Context) {
+	s.mu.Lock()
+	reloaded := s.reloaded
+	s.mu.Unlock()
+	if reloaded == nil {
+		return
+	}
+	select {
+	case <-reloaded:
+	case <-ctx.Done():
+	}
+}
+
+// isProjectFile reports whether uri is a project or assembly definition, whose changes
+// omnisharp.reloadOnProjectChanges reloads OmniSharp for
+func isProjectFile(uri protocol.DocumentURI) bool {
+	switch strings.ToLower(filepath.Ext(uri.Filename())) {
+	case ".csproj", ".asmdef":
+		return true
+	}
+	return false
+}
+
+// scheduleProjectReload reloads OmniSharp once project files have stopped changing for
+// projectReloadDelay
+func (s *Server) scheduleProjectReload() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.projectReload != nil {
+		s.projectReload.Stop()
+	}
+	s.projectReload = time.AfterFunc(projectReloadDelay, func() {
+		if _, err := s.reloadOmniSharp(context.Background(), nil, "Reloading projects"); err != nil {
+			log.Printf("not reloading for the changed project files: %v", err)
+		}
+	})
+}
+
+// watchProjectFiles asks the client for the changes to project files, once OmniSharp is ready
+func (s *Server) watchProjectFiles(ctx context.Context) {
+	err := s.client.RegisterCapability(ctx, &protocol.RegistrationParams{
+		Registrations: []protocol.Registration{{
+			ID:     "unity-lsp.projectFiles",
+			Method: protocol.MethodWorkspaceDidChangeWatchedFiles,
+			RegisterOptions: protocol.DidChangeWatchedFilesRegistrationOptions{
+				Watchers: []protocol.FileSystemWatcher{
+					{GlobPattern: "**/*.csproj"},
+					{GlobPattern: "**/*.asmdef"},
+				},
+			},
+		}},
+	})
+	if err != nil {
+		log.Printf("failed to watch project files: %v", err)
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+// TestReloadProjects checks unity-lsp/reloadProjects brings OmniSharp back with every open
+// buffer, unsaved edits included, reporting progress under the client's token
+func TestReloadProjects(t *testing.T) {
+	tests := []struct {
+		name      string
+		state     backendState
+		ready     bool
+		wantReady bool
+		wantErr   string
+		wantEnd   string
+	}{
+		{name: "ready again", state: backendReady, ready: true, wantReady: true, wantEnd: "Projects reloaded"},
+		{name: "never ready", state: backendReady, wantEnd: "OmniSharp did not come back"},
+		{name: "already loading", state: backendStarting, wantErr: "already loading"},
+		{name: "no solution", state: backendNoSolution, wantErr: "no solution"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/checkreadystatus": map[string]bool{"Ready": test.ready}})
+			s, client := newTestServer(t, fake)
+			if err := os.WriteFile(filepath.Join(s.rootPath, "Game.sln"), nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			configure(s, func(config *Config) {
+				config.OmniSharp.Address = fake.URL
+				config.OmniSharp.StartupTimeout = Duration(100 * time.Millisecond)
+			})
+			player, enemy := testURI(s, "Player.cs"), testURI(s, "Enemy.cs")
+			openTestDocument(s, player, "class Player { }\n")
+			openTestDocument(s, enemy, "class Enemy { }\n")
+			typeAt(s, player, 2, protocol.Position{Character: 15}, "int speed; ")
+			s.mu.Lock()
+			s.state = test.state
+			s.mu.Unlock()
+			fake.mu.Lock()
+			before := len(fake.bodies["/updatebuffer"])
+			fake.mu.Unlock()
+
+			result, err := call(t, s, 1, methodReloadProjects, ReloadProjectsParams{
+				WorkDoneProgressParams: protocol.WorkDoneProgressParams{WorkDoneToken: protocol.NewProgressToken("reload")},
+			})
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("error = %v, want one containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reloaded := result.(*ReloadProjectsResult); reloaded.Ready != test.wantReady {
+				t.Errorf("ready = %v, want %v", reloaded.Ready, test.wantReady)
+			}
+
+			waitFor(t, "the end of progress", func() bool {
+				progress := client.received(protocol.MethodProgress)
+				return len(progress) > 0 && strings.Contains(string(progress[len(progress)-1]), `"end"`)
+			})
+			progress := client.received(protocol.MethodProgress)
+			if len(progress) != 3 || !strings.Contains(string(progress[0]), `"begin"`) || !strings.Contains(string(progress[2]), test.wantEnd) {
+				t.Errorf("progress = %s, want begin, report and end %q", progress, test.wantEnd)
+			}
+			for _, value := range progress {
+				if !strings.Contains(string(value), `"token":"reload"`) {
+					t.Errorf("progress %s not under the client's token", value)
+				}
+			}
+
+			fake.mu.Lock()
+			updates := fake.bodies["/updatebuffer"][before:]
+			fake.mu.Unlock()
+			buffers := make(map[string]string)
+			for _, update := range updates {
+				var body struct {
+					FileName string
+					Buffer   *string
+				}
+				if err := json.Unmarshal(update, &body); err != nil {
+					t.Fatal(err)
+				}
+				if body.Buffer != nil {
+					buffers[body.FileName] = *body.Buffer
+				}
+			}
+			want := map[string]string{}
+			if test.wantReady {
+				want = map[string]string{
+					player.Filename(): "class Player { int speed; }\n",
+					enemy.Filename():  "class Enemy { }\n",
+				}
+			}
+			if len(buffers) != len(want) {
+				t.Errorf("resynced %v, want %v", buffers, want)
+			}
+			for file, text := range want {
+				if buffers[file] != text {
+					t.Errorf("resynced %s as %q, want %q", file, buffers[file], text)
+				}
+			}
+		})
+	}
+}
+
+// TestRequestsWaitForReload checks a request arriving while projects reload is served once
+// OmniSharp is back and has the open buffers, rather than answered empty meanwhile
+func TestRequestsWaitForReload(t *testing.T) {
+	fake := newFakeOmniSharp(t, map[string]interface{}{})
+	release := make(chan struct{})
+	fake.setHandler("/checkreadystatus", func() interface{} {
+		<-release
+		return map[string]bool{"Ready": true}
+	})
+	synced := make(chan bool, 1)
+	fake.setHandler("/v2/codestructure", func() interface{} {
+		fake.mu.Lock()
+		synced <- len(fake.bodies["/updatebuffer"]) > 1
+		fake.mu.Unlock()
+		return map[string]interface{}{"Elements": []CodeElement{{Kind: "class", Name: "Player", DisplayName: "Player"}}}
+	})
+	s, _ := newTestServer(t, fake)
+	if err := os.WriteFile(filepath.Join(s.rootPath, "Game.sln"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	configure(s, func(config *Config) { config.OmniSharp.Address = fake.URL })
+	uri := testURI(s, "Player.cs")
+	openTestDocument(s, uri, "class Player { }\n")
+
+	reloaded := make(chan error, 1)
+	go func() {
+		_, err := call(t, s, 1, methodReloadProjects, ReloadProjectsParams{})
+		reloaded <- err
+	}()
+	waitFor(t, "the reload to wait for OmniSharp", func() bool { return fake.callCount("/checkreadystatus") > 0 })
+
+	answered := make(chan interface{}, 1)
+	go func() {
+		result, _ := call(t, s, 2, protocol.MethodTextDocumentDocumentSymbol, protocol.DocumentSymbolParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		})
+		answered <- result
+	}()
+	select {
+	case result := <-answered:
+		t.Fatalf("answered %+v while reloading", result)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-reloaded; err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case result := <-answered:
+		if symbols, ok := result.([]protocol.SymbolInformation); !ok || len(symbols) != 1 {
+			t.Errorf("answered %+v, want Player", result)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("not answered after the reload")
+	}
+	if !<-synced {
+		t.Error("served before the open buffer was synced")
+	}
+}
+
+func TestIsProjectFile(t *testing.T) {
+	tests := []struct {
+		uri  protocol.DocumentURI
+		want bool
+	}{
+		{"file:///project/Assembly-CSharp.csproj", true},
+		{"file:///project/Assets/Game.asmdef", true},
+		{"file:///project/Assets/Game.ASMDEF", true},
+		{"file:///project/Game.sln", false},
+		{"file:///project/Assets/Player.cs", false},
+	}
+	for _, test := range tests {
+		t.Run(string(test.uri), func(t *testing.T) {
+			if got := isProjectFile(test.uri); got != test.want {
+				t.Errorf("isProjectFile = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestProjectFileChangesReload checks a changed project file schedules a reload when
+// omnisharp.reloadOnProjectChanges is set, and nothing else does
+func TestProjectFileChangesReload(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		changed protocol.DocumentURI
+		want    bool
+	}{
+		{"project file", true, "file:///project/Assembly-CSharp.csproj", true},
+		{"assembly definition", true, "file:///project/Assets/Game.asmdef", true},
+		{"script", true, "file:///project/Assets/Player.cs", false},
+		{"not enabled", false, "file:///project/Assembly-CSharp.csproj", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, _ := newTestServer(t, newFakeOmniSharp(t, nil))
+			configure(s, func(config *Config) { config.OmniSharp.ReloadOnProjectChanges = test.enabled })
+
+			s.handleDidChangeWatchedFiles(context.Background(), &protocol.DidChangeWatchedFilesParams{
+				Changes: []*protocol.FileEvent{{URI: test.changed, Type: protocol.FileChangeTypeChanged}},
+			})
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if scheduled := s.projectReload != nil && s.projectReload.Stop(); scheduled != test.want {
+				t.Errorf("reload scheduled %v, want %v", scheduled, test.want)
+			}
+		})
+	}
+}
+
+// TestPlaceholdersWhileReloading checks completion, hover and definition are answered at once
+// while projects reload, unless omnisharp.duringReload is wait, and the status tells it
+func TestPlaceholdersWhileReloading(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		params func(position protocol.TextDocumentPositionParams) interface{}
+		// placeholder checks the answer given while reloading
+		placeholder func(result interface{}) bool
+	}{
+		{"completion", protocol.MethodTextDocumentCompletion, func(position protocol.TextDocumentPositionParams) interface{} {
+			return protocol.CompletionParams{TextDocumentPositionParams: position}
+		}, func(result interface{}) bool {
+			list, ok := result.(*CompletionList)
+			return ok && list.IsIncomplete && len(list.Items) == 0
+		}},
+		{"hover", protocol.MethodTextDocumentHover, func(position protocol.TextDocumentPositionParams) interface{} {
+			return protocol.HoverParams{TextDocumentPositionParams: position}
+		}, func(result interface{}) bool { return result == nil }},
+		{"definition", protocol.MethodTextDocumentDefinition, func(position protocol.TextDocumentPositionParams) interface{} {
+			return protocol.DefinitionParams{TextDocumentPositionParams: position}
+		}, func(result interface{}) bool { return result == nil }},
+	}
+	for _, test := range tests {
+		for _, mode := range []string{duringReloadPlaceholder, duringReloadWait} {
+			t.Run(test.name+" "+mode, func(t *testing.T) {
+				fake := newFakeOmniSharp(t, map[string]interface{}{"/autocomplete": []AutoCompleteResponse{}})
+				release := make(chan struct{})
+				fake.setHandler("/checkreadystatus", func() interface{} {
+					<-release
+					return map[string]bool{"Ready": true}
+				})
+				s, _ := newTestServer(t, fake)
+				if err := os.WriteFile(filepath.Join(s.rootPath, "Game.sln"), nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				configure(s, func(config *Config) {
+					config.OmniSharp.Address = fake.URL
+					config.OmniSharp.DuringReload = mode
+				})
+				uri := testURI(s, "Player.cs")
+				openTestDocument(s, uri, "class Player { }\n")
+
+				reloaded := make(chan error, 1)
+				go func() {
+					_, err := call(t, s, 1, methodReloadProjects, ReloadProjectsParams{})
+					reloaded <- err
+				}()
+				waitFor(t, "the reload to wait for OmniSharp", func() bool { return fake.callCount("/checkreadystatus") > 0 })
+				if state := s.handleStatus().State; state != "reloading" {
+					t.Errorf("state = %s while reloading", state)
+				}
+
+				answered := make(chan interface{}, 1)
+				go func() {
+					result, _ := call(t, s, 2, test.method, test.params(protocol.TextDocumentPositionParams{
+						TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+						Position:     protocol.Position{Character: 6},
+					}))
+					answered <- result
+				}()
+				select {
+				case result := <-answered:
+					if mode == duringReloadWait {
+						t.Errorf("answered %+v while reloading", result)
+					} else if !test.placeholder(result) {
+						t.Errorf("answered %#v while reloading, want the placeholder", result)
+					}
+				case <-time.After(50 * time.Millisecond):
+					if mode == duringReloadPlaceholder {
+						t.Error("waited for the reload")
+					}
+				}
+
+				close(release)
+				if err := <-reloaded; err != nil {
+					t.Fatal(err)
+				}
+				if mode == duringReloadWait {
+					select {
+					case <-answered:
+					case <-time.After(time.Second):
+						t.Fatal("not answered after the reload")
+					}
+				}
+				if state := s.handleStatus().State; state != "ready" {
+					t.Errorf("state = %s after the reload", state)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+// RenameResponse is OmniSharp's /rename response
+type RenameResponse struct {
+	Changes      []ModifiedFileResponse `json:"Changes"`
+	ErrorMessage string                 `json:"ErrorMessage"`
+}
+
+// handleRename computes a rename across the solution. OmniSharp computes it against the buffers
+// and files it had when asked, so if any file it touches changed meanwhile the whole rename is
+// refused: applying the other files alone would leave the code half renamed
+func (s *Server) handleRename(ctx context.Context, params *protocol.RenameParams) (*protocol.WorkspaceEdit, error) {
+	omnisharp := s.backend()
+	if omnisharp == nil {
+		return nil, nil
+	}
+
+	start := time.Now()
+	// OmniSharp names the files as they are on disk, which may be cased unlike the client's URIs
+	versions := make(map[protocol.DocumentURI]int32)
+	for _, doc := range s.documents.OpenDocuments() {
+		versions[documentKey(doc.URI)] = doc.Version
+	}
+
+	request := omnisharpPosition(params.TextDocument.URI, params.Position)
+	request["RenameTo"] = params.NewName
+	request["WantsTextChanges"] = true
+	request["ApplyTextChanges"] = false
+	response, err := omnisharp.SendRequest(ctx, "/rename", request)
+	if err != nil {
+		return nil, err
+	}
+
+	var omnisharpResponse RenameResponse
+	if err := json.Unmarshal(response, &omnisharpResponse); err != nil {
+		return nil, err
+	}
+	if omnisharpResponse.ErrorMessage != "" {
+		return nil, errors.New(omnisharpResponse.ErrorMessage)
+	}
+
+	edit := workspaceEdit(omnisharpResponse.Changes)
+	if err := s.checkRenameTargets(edit, versions, start); err != nil {
+		return nil, err
+	}
+	if s.supportsDocumentChanges() {
+		edit = versionedEdit(edit, versions)
+	}
+	return edit, nil
+}
+
+// checkRenameTargets makes sure every file of edit is as it was when the rename was requested:
+// open documents at the version they had, and other files readable and unmodified on disk
+func (s *Server) checkRenameTargets(edit *protocol.WorkspaceEdit, versions map[protocol.DocumentURI]int32, start time.Time) error {
+	for uri := range edit.Changes {
+		if doc, ok := s.documents.Get(uri); ok && doc.Open {
+			if version, ok := versions[documentKey(uri)]; !ok || version != doc.Version {
+				return fmt.Errorf("rename aborted: %s changed while the rename was computed; try again", s.displayPath(uri))
+			}
+			continue
+		}
+
+		info, err := os.Stat(uri.Filename())
+		if err != nil {
+			return fmt.Errorf("rename aborted: %s can't be read: %w", s.displayPath(uri), err)
+		}
+		if info.ModTime().After(start) {
+			return fmt.Errorf("rename aborted: %s changed on disk while the rename was computed; try again", s.displayPath(uri))
+		}
+	}
+	return nil
+}
+
+// versionedEdit turns edit into per-document changes carrying the versions of open documents
+// it applies to, so the client refuses the rename rather than misapplying it to a document
+// edited since. Other files get no version, meaning their contents on disk. versions may be
+// keyed by the URIs or their documentKey
+func versionedEdit(edit *protocol.WorkspaceEdit, versions map[protocol.DocumentURI]int32) *protocol.WorkspaceEdit {
+	keyed := make(map[protocol.DocumentURI]int32, len(versions))
+	for uri, version := range versions {
+		keyed[documentKey(uri)] = version
+	}
+	uris := make([]protocol.DocumentURI, 0, len(edit.Changes))
+	for uri := range edit.Changes {
+		uris = append(uris, uri)
+	}
+	sort.Slice(uris, func(i, j int) bool { return uris[i] < uris[j] })
+
+	changes := make([]protocol.TextDocumentEdit, len(uris))
+	for i, uri := range uris {
+		identifier := protocol.OptionalVersionedTextDocumentIdentifier{
+			TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri},
+		}
+		if version, ok := keyed[documentKey(uri)]; ok {
+			identifier.Version = &version
+		}
+		changes[i] = protocol.TextDocumentEdit{TextDocument: identifier, Edits: edit.Changes[uri]}
+	}
+	return &protocol.WorkspaceEdit{DocumentChanges: changes}
+}
+
+func (s *Server) supportsDocumentChanges() bool {
+	workspace := s.capabilities.Workspace
+	return workspace != nil && workspace.WorkspaceEdit != nil && workspace.WorkspaceEdit.DocumentChanges
+}
+
+// displayPath shows uri relative to the workspace when it is inside it
+func (s *Server) displayPath(uri protocol.DocumentURI) string {
+	if rel, err := filepath.Rel(s.rootPath, uri.Filename()); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return uri.Filename()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+// TestRenameAcrossFiles checks a rename is returned whole when its files are as OmniSharp saw
+// them, and refused when any changed while it was computed
+func TestRenameAcrossFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		// during runs while OmniSharp computes the rename
+		during          func(t *testing.T, s *Server)
+		documentChanges bool
+		wantErr         string
+	}{
+		{name: "unchanged"},
+		{name: "unchanged, versioned", documentChanges: true},
+		{
+			name:    "open document edited",
+			during:  func(t *testing.T, s *Server) { typeAt(s, testURI(s, "Player.cs"), 2, protocol.Position{}, "// ") },
+			wantErr: "Player.cs changed while the rename was computed",
+		},
+		{
+			name: "file on disk written",
+			during: func(t *testing.T, s *Server) {
+				file := testURI(s, "Enemy.cs").Filename()
+				if err := os.WriteFile(file, []byte("class Enemy { }\n"), 0o644); err != nil {
+					t.Error(err)
+				}
+				// The file system's clock may lag behind, stamping the write before it happened
+				now := time.Now()
+				if err := os.Chtimes(file, now, now); err != nil {
+					t.Error(err)
+				}
+			},
+			wantErr: "Enemy.cs changed on disk",
+		},
+		{
+			name: "file on disk deleted",
+			during: func(t *testing.T, s *Server) {
+				if err := os.Remove(testURI(s, "Enemy.cs").Filename()); err != nil {
+					t.Error(err)
+				}
+			},
+			wantErr: "Enemy.cs can't be read",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, nil)
+			s, _ := newTestServer(t, fake)
+			if test.documentChanges {
+				s.capabilities.Workspace = &protocol.WorkspaceClientCapabilities{
+					WorkspaceEdit: &protocol.WorkspaceClientCapabilitiesWorkspaceEdit{DocumentChanges: true},
+				}
+			}
+			player, enemy := testURI(s, "Player.cs"), testURI(s, "Enemy.cs")
+			openTestDocument(s, player, "class Player { float speed; }\n")
+			if err := os.WriteFile(enemy.Filename(), []byte("class Enemy { Player target; float Chase() => target.speed; }\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			fake.setHandler("/rename", func() interface{} {
+				if test.during != nil {
+					test.during(t, s)
+				}
+				return RenameResponse{Changes: []ModifiedFileResponse{
+					{FileName: player.Filename(), Changes: []LinePositionSpanTextChange{{NewText: "velocity", StartColumn: 21, EndColumn: 26}}},
+					{FileName: enemy.Filename(), Changes: []LinePositionSpanTextChange{{NewText: "velocity", StartColumn: 54, EndColumn: 59}}},
+				}}
+			})
+
+			edit, err := s.handleRename(context.Background(), &protocol.RenameParams{
+				TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+					TextDocument: protocol.TextDocumentIdentifier{URI: player},
+					Position:     protocol.Position{Character: 23},
+				},
+				NewName: "velocity",
+			})
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) || edit != nil {
+					t.Errorf("rename = %+v, %v; want it aborted with %q", edit, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !test.documentChanges {
+				if len(edit.Changes) != 2 || len(edit.Changes[player]) != 1 || len(edit.Changes[enemy]) != 1 {
+					t.Errorf("changes = %+v, want one edit to each file", edit.Changes)
+				}
+				return
+			}
+			if edit.Changes != nil || len(edit.DocumentChanges) != 2 {
+				t.Fatalf("edit = %+v, want document changes for each file", edit)
+			}
+			for _, change := range edit.DocumentChanges {
+				version := change.TextDocument.Version
+				switch change.TextDocument.URI {
+				case player:
+					if version == nil || *version != 1 {
+						t.Errorf("open document versioned %v, want 1", version)
+					}
+				case enemy:
+					if version != nil {
+						t.Errorf("file on disk versioned %d, want none", *version)
+					}
+				default:
+					t.Errorf("edit to %s", change.TextDocument.URI)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+
+	"go.lsp.dev/protocol"
+)
+
+// methodRestart tears down OmniSharp and starts it afresh with the configuration in effect, a
+// recovery for a backend in a bad state that doesn't take restarting the language server
+const methodRestart = "unity-lsp/restart"
+
+// RestartParams are the params of unity-lsp/restart
+type RestartParams struct {
+	protocol.WorkDoneProgressParams
+}
+
+// RestartResult tells whether OmniSharp came back with the solution loaded
+type RestartResult struct {
+	Ready bool `json:"ready"`
+}
+
+// handleRestart drops what was learned from the old OmniSharp and restarts it as a reload does,
+// the open buffers pushed to the new process. A degraded server is restarted too. The user is
+// told once it is back; if it isn't, entering degraded mode has told them why
+func (s *Server) handleRestart(ctx context.Context, params *RestartParams) (*RestartResult, error) {
+	s.clearCaches()
+	ready, err := s.reloadOmniSharp(ctx, params.WorkDoneToken, "Restarting OmniSharp")
+	if err != nil {
+		return nil, err
+	}
+	if ready {
+		s.client.ShowMessage(ctx, &protocol.ShowMessageParams{
+			Type:    protocol.MessageTypeInfo,
+			Message: s.localize("Unity LSP: OmniSharp restarted."),
+		})
+	}
+	return &RestartResult{Ready: ready}, nil
+}
+
+// clearCaches forgets what is kept to answer requests again, so nothing from before a restart
+// is served
+func (s *Server) clearCaches() {
+	s.cache.clear()
+	s.documentation.clear()
+	s.completionSession.reset()
+	s.symbolResults.store(nil)
+	s.resources.invalidate()
+}
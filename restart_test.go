@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+// TestRestart checks unity-lsp/restart brings OmniSharp back from any state but loading, with
+// the open buffers resynced and nothing cached from before, and tells the user once it is back
+func TestRestart(t *testing.T) {
+	tests := []struct {
+		name        string
+		state       backendState
+		ready       bool
+		wantErr     string
+		wantMessage bool
+	}{
+		{name: "ready", state: backendReady, ready: true, wantMessage: true},
+		{name: "degraded", state: backendDegraded, ready: true, wantMessage: true},
+		{name: "never ready", state: backendReady},
+		{name: "loading", state: backendStarting, wantErr: "already loading"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/checkreadystatus": map[string]bool{"Ready": test.ready}})
+			s, client := newTestServer(t, fake)
+			if err := os.WriteFile(filepath.Join(s.rootPath, "Game.sln"), nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			configure(s, func(config *Config) {
+				config.OmniSharp.Address = fake.URL
+				config.OmniSharp.StartupTimeout = Duration(100 * time.Millisecond)
+			})
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, "class Player { }\n")
+			typeAt(s, uri, 2, protocol.Position{Character: 15}, "int speed; ")
+			s.cache.put("hover", uri, s.cache.generation(uri), protocol.Position{}, "float Player.speed")
+			s.mu.Lock()
+			s.state = test.state
+			s.mu.Unlock()
+			fake.mu.Lock()
+			before := len(fake.bodies["/updatebuffer"])
+			fake.mu.Unlock()
+
+			result, err := call(t, s, 1, methodRestart, RestartParams{
+				WorkDoneProgressParams: protocol.WorkDoneProgressParams{WorkDoneToken: protocol.NewProgressToken("restart")},
+			})
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("error = %v, want one containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if restarted := result.(*RestartResult); restarted.Ready != test.ready {
+				t.Errorf("ready = %v, want %v", restarted.Ready, test.ready)
+			}
+			if fake.callCount("/checkreadystatus") == 0 {
+				t.Error("OmniSharp not started again")
+			}
+			if _, ok := s.cache.get("hover", uri, protocol.Position{}); ok {
+				t.Error("hover cached from before the restart")
+			}
+
+			waitFor(t, "the end of progress", func() bool {
+				progress := client.received(protocol.MethodProgress)
+				return len(progress) > 0 && strings.Contains(string(progress[len(progress)-1]), `"end"`)
+			})
+			if progress := client.received(protocol.MethodProgress); !strings.Contains(string(progress[0]), "Restarting OmniSharp") {
+				t.Errorf("progress began %s, want Restarting OmniSharp", progress[0])
+			}
+			var restarted bool
+			for _, value := range client.received(protocol.MethodWindowShowMessage) {
+				restarted = restarted || strings.Contains(string(value), "OmniSharp restarted")
+			}
+			if restarted != test.wantMessage {
+				t.Errorf("told restarted %v, want %v", restarted, test.wantMessage)
+			}
+
+			fake.mu.Lock()
+			updates := fake.bodies["/updatebuffer"][before:]
+			fake.mu.Unlock()
+			var resynced string
+			for _, update := range updates {
+				var body struct {
+					FileName string
+					Buffer   *string
+				}
+				if err := json.Unmarshal(update, &body); err != nil {
+					t.Fatal(err)
+				}
+				if body.FileName == uri.Filename() && body.Buffer != nil {
+					resynced = *body.Buffer
+				}
+			}
+			if want := "class Player { int speed; }\n"; test.ready && resynced != want {
+				t.Errorf("resynced %q, want %q", resynced, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.lsp.dev/protocol"
+)
+
+// SemanticTokensOptions is the semanticTokensProvider capability, whose legend and requests
+// protocol.SemanticTokensOptions lacks
+type SemanticTokensOptions struct {
+	Legend protocol.SemanticTokensLegend `json:"legend"`
+	Range  bool                          `json:"range"`
+	Full   bool                          `json:"full"`
+}
+
+// semanticTokensOptions describes the tokens we send: whole documents and ranges, without deltas
+var semanticTokensOptions = SemanticTokensOptions{
+	Legend: protocol.SemanticTokensLegend{
+		TokenTypes:     semanticTokenTypes,
+		TokenModifiers: []protocol.SemanticTokenModifiers{protocol.SemanticTokenModifierStatic},
+	},
+	Range: true,
+	Full:  true,
+}
+
+// semanticTokenTypes is the legend of token types, indexed by the type numbers of the data
+var semanticTokenTypes = []protocol.SemanticTokenTypes{
+	protocol.SemanticTokenNamespace,
+	protocol.SemanticTokenClass,
+	protocol.SemanticTokenEnum,
+	protocol.SemanticTokenInterface,
+	protocol.SemanticTokenStruct,
+	protocol.SemanticTokenTypeParameter,
+	protocol.SemanticTokenParameter,
+	protocol.SemanticTokenVariable,
+	protocol.SemanticTokenProperty,
+	protocol.SemanticTokenEnumMember,
+	protocol.SemanticTokenEvent,
+	protocol.SemanticTokenMethod,
+	protocol.SemanticTokenMacro,
+	protocol.SemanticTokenKeyword,
+	protocol.SemanticTokenComment,
+	protocol.SemanticTokenString,
+	protocol.SemanticTokenNumber,
+	protocol.SemanticTokenOperator,
+}
+
+// highlightTokenTypes maps the classifications of OmniSharp's /v2/highlight spans to token
+// types. Those left out, such as punctuation and plain identifiers, aren't sent
+var highlightTokenTypes = map[int]protocol.SemanticTokenTypes{
+	0:  protocol.SemanticTokenComment,  // Comment
+	3:  protocol.SemanticTokenKeyword,  // Keyword
+	4:  protocol.SemanticTokenKeyword,  // ControlKeyword
+	5:  protocol.SemanticTokenNumber,   // NumericLiteral
+	6:  protocol.SemanticTokenOperator, // Operator
+	7:  protocol.SemanticTokenOperator, // OperatorOverloaded
+	8:  protocol.SemanticTokenMacro,    // PreprocessorKeyword
+	9:  protocol.SemanticTokenString,   // StringLiteral
+	15: protocol.SemanticTokenString,   // VerbatimStringLiteral
+	17: protocol.SemanticTokenClass,    // ClassName
+	18: protocol.SemanticTokenClass,    // DelegateName
+	19: protocol.SemanticTokenEnum,     // EnumName
+	20: protocol.SemanticTokenInterface,
+	21: protocol.SemanticTokenNamespace, // ModuleName
+	22: protocol.SemanticTokenStruct,
+	23: protocol.SemanticTokenTypeParameter,
+	24: protocol.SemanticTokenVariable, // FieldName
+	25: protocol.SemanticTokenEnumMember,
+	26: protocol.SemanticTokenVariable, // ConstantName
+	27: protocol.SemanticTokenVariable, // LocalName
+	28: protocol.SemanticTokenParameter,
+	29: protocol.SemanticTokenMethod,
+	30: protocol.SemanticTokenMethod, // ExtensionMethodName
+	31: protocol.SemanticTokenProperty,
+	32: protocol.SemanticTokenEvent,
+	33: protocol.SemanticTokenNamespace,
+}
+
+// highlightModifierStatic is OmniSharp's modifier for static symbols
+const highlightModifierStatic = 0
+
+// HighlightSpan is a span of OmniSharp's /v2/highlight response
+type HighlightSpan struct {
+	StartLine   uint32 `json:"StartLine"`
+	StartColumn uint32 `json:"StartColumn"`
+	EndLine     uint32 `json:"EndLine"`
+	EndColumn   uint32 `json:"EndColumn"`
+	Type        int    `json:"Type"`
+	Modifiers   []int  `json:"Modifiers"`
+}
+
+// largeDocuments remembers the documents served only range tokens, to log once as each
+// crosses semanticTokens.maxLines
+type largeDocuments struct {
+	mu   sync.Mutex
+	uris map[protocol.DocumentURI]bool
+}
+
+func newLargeDocuments() *largeDocuments {
+	return &largeDocuments{uris: make(map[protocol.DocumentURI]bool)}
+}
+
+// mark records whether uri is large, reporting whether it just became so
+func (l *largeDocuments) mark(uri protocol.DocumentURI, large bool) bool {
+	uri = documentKey(uri)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entered := large && !l.uris[uri]
+	if large {
+		l.uris[uri] = true
+	} else {
+		delete(l.uris, uri)
+	}
+	return entered
+}
+
+// handleSemanticTokensFull highlights a whole document. Documents longer than
+// semanticTokens.maxLines get no tokens, which would take OmniSharp seconds, so the client keeps
+// its grammar's highlighting and asks for the visible range instead
+func (s *Server) handleSemanticTokensFull(ctx context.Context, params *protocol.SemanticTokensParams) (*protocol.SemanticTokens, error) {
+	uri := params.TextDocument.URI
+	if doc, ok := s.documents.Get(uri); ok {
+		maxLines := s.currentConfig().SemanticTokens.MaxLines
+		lines := strings.Count(doc.Text, "\n") + 1
+		large := maxLines > 0 && lines > maxLines
+		if s.largeDocuments.mark(uri, large) {
+			log.Printf("%s has %d lines, more than semanticTokens.maxLines, so only ranges are highlighted", uri.Filename(), lines)
+		}
+		if large {
+			return &protocol.SemanticTokens{Data: []uint32{}}, nil
+		}
+	}
+	return s.semanticTokens(ctx, uri, nil)
+}
+
+// handleSemanticTokensRange highlights the range of a document the client shows
+func (s *Server) handleSemanticTokensRange(ctx context.Context, params *protocol.SemanticTokensRangeParams) (*protocol.SemanticTokens, error) {
+	return s.semanticTokens(ctx, params.TextDocument.URI, &params.Range)
+}
+
+// semanticTokens asks OmniSharp for the spans of uri within span, or all of them. The request
+// is given up once an open document is closed, as nothing would show the tokens
+func (s *Server) semanticTokens(ctx context.Context, uri protocol.DocumentURI, span *protocol.Range) (*protocol.SemanticTokens, error) {
+	omnisharp := s.backend()
+	if omnisharp == nil {
+		return nil, nil
+	}
+	if doc, ok := s.documents.Get(uri); ok && doc.Open {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		stop := context.AfterFunc(doc.Context(), cancel)
+		defer stop()
+	}
+
+	request := map[string]interface{}{"FileName": uri.Filename()}
+	if span != nil {
+		request["Range"] = map[string]interface{}{
+			"Start": map[string]interface{}{"Line": span.Start.Line, "Column": span.Start.Character},
+			"End":   map[string]interface{}{"Line": span.End.Line, "Column": span.End.Character},
+		}
+	}
+	response, err := omnisharp.SendRequest(ctx, "/v2/highlight", request)
+	if err != nil {
+		return nil, err
+	}
+	var highlight struct {
+		Spans []HighlightSpan `json:"Spans"`
+	}
+	if err := json.Unmarshal(response, &highlight); err != nil {
+		return nil, err
+	}
+	text := ""
+	if doc, ok := s.documents.Get(uri); ok {
+		text = doc.Text
+	}
+	return &protocol.SemanticTokens{Data: encodeSemanticTokens(highlight.Spans, text)}, nil
+}
+
+// encodeSemanticTokens encodes spans in LSP's relative format. Spans running over several
+// lines, such as block comments, are split at the line ends found in text; without it they
+// are dropped
+func encodeSemanticTokens(spans []HighlightSpan, text string) []uint32 {
+	typeIndex := make(map[protocol.SemanticTokenTypes]uint32, len(semanticTokenTypes))
+	for i, tokenType := range semanticTokenTypes {
+		typeIndex[tokenType] = uint32(i)
+	}
+
+	type token struct {
+		line, start, length, tokenType, modifiers uint32
+	}
+	var tokens []token
+	for _, span := range spans {
+		tokenType, ok := highlightTokenTypes[span.Type]
+		if !ok {
+			continue
+		}
+		modifiers := uint32(0)
+		for _, modifier := range span.Modifiers {
+			if modifier == highlightModifierStatic {
+				modifiers |= 1
+			}
+		}
+		for line := span.StartLine; line <= span.EndLine; line++ {
+			start, end := uint32(0), span.EndColumn
+			if line == span.StartLine {
+				start = span.StartColumn
+			}
+			if line != span.EndLine {
+				if text == "" {
+					break
+				}
+				lineText := strings.TrimSuffix(lineAt(text, line), "\r")
+				end = byteToUTF16Offset(lineText, len(lineText))
+			}
+			if end > start {
+				tokens = append(tokens, token{line, start, end - start, typeIndex[tokenType], modifiers})
+			}
+		}
+	}
+	sort.SliceStable(tokens, func(i, j int) bool {
+		if tokens[i].line != tokens[j].line {
+			return tokens[i].line < tokens[j].line
+		}
+		return tokens[i].start < tokens[j].start
+	})
+
+	data := make([]uint32, 0, 5*len(tokens))
+	previousLine, previousStart := uint32(0), uint32(0)
+	for _, t := range tokens {
+		deltaStart := t.start
+		if t.line == previousLine {
+			deltaStart = t.start - previousStart
+		}
+		data = append(data, t.line-previousLine, deltaStart, t.length, t.tokenType, t.modifiers)
+		previousLine, previousStart = t.line, t.start
+	}
+	return data
+}
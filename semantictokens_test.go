@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+func tokenTypeIndex(t *testing.T, tokenType protocol.SemanticTokenTypes) uint32 {
+	t.Helper()
+	for i, legend := range semanticTokenTypes {
+		if legend == tokenType {
+			return uint32(i)
+		}
+	}
+	t.Fatalf("%s is not in the legend", tokenType)
+	return 0
+}
+
+func TestEncodeSemanticTokens(t *testing.T) {
+	class := tokenTypeIndex(t, protocol.SemanticTokenClass)
+	method := tokenTypeIndex(t, protocol.SemanticTokenMethod)
+	comment := tokenTypeIndex(t, protocol.SemanticTokenComment)
+	tests := []struct {
+		name  string
+		spans []HighlightSpan
+		text  string
+		want  []uint32
+	}{
+		{
+			"relative positions",
+			[]HighlightSpan{
+				{StartLine: 0, StartColumn: 6, EndLine: 0, EndColumn: 12, Type: 17},
+				{StartLine: 2, StartColumn: 9, EndLine: 2, EndColumn: 15, Type: 29, Modifiers: []int{highlightModifierStatic}},
+				{StartLine: 2, StartColumn: 20, EndLine: 2, EndColumn: 24, Type: 29},
+			},
+			"",
+			[]uint32{0, 6, 6, class, 0, 2, 9, 6, method, 1, 0, 11, 4, method, 0},
+		},
+		{
+			"unsorted spans",
+			[]HighlightSpan{
+				{StartLine: 1, StartColumn: 4, EndLine: 1, EndColumn: 8, Type: 29},
+				{StartLine: 0, StartColumn: 0, EndLine: 0, EndColumn: 5, Type: 17},
+			},
+			"",
+			[]uint32{0, 0, 5, class, 0, 1, 4, 4, method, 0},
+		},
+		{
+			"punctuation and identifiers dropped",
+			[]HighlightSpan{{StartLine: 0, StartColumn: 0, EndLine: 0, EndColumn: 1, Type: 14}, {StartLine: 0, StartColumn: 2, EndLine: 0, EndColumn: 3, Type: 2}},
+			"",
+			[]uint32{},
+		},
+		{
+			"multi-line span split at line ends",
+			[]HighlightSpan{{StartLine: 0, StartColumn: 4, EndLine: 2, EndColumn: 2, Type: 0}},
+			"int /* a\r\nbc\n*/",
+			[]uint32{0, 4, 4, comment, 0, 1, 0, 2, comment, 0, 1, 0, 2, comment, 0},
+		},
+		{
+			"multi-line span without text dropped",
+			[]HighlightSpan{{StartLine: 0, StartColumn: 4, EndLine: 2, EndColumn: 2, Type: 0}},
+			"",
+			[]uint32{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := encodeSemanticTokens(tt.spans, tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("encodeSemanticTokens() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSemanticTokensOfLargeDocuments(t *testing.T) {
+	fake := newFakeOmniSharp(t, map[string]interface{}{
+		"/v2/highlight": map[string]interface{}{
+			"Spans": []HighlightSpan{{StartLine: 0, StartColumn: 6, EndLine: 0, EndColumn: 7, Type: 17}},
+		},
+	})
+	s, _ := newTestServer(t, fake)
+	configure(s, func(config *Config) { config.SemanticTokens.MaxLines = 3 })
+	small := testURI(s, "Small.cs")
+	large := testURI(s, "Large.cs")
+	openTestDocument(s, small, "class A\n{\n}")
+	openTestDocument(s, large, "class B\n{\n"+strings.Repeat("int x;\n", 10)+"}")
+
+	tests := []struct {
+		name       string
+		uri        protocol.DocumentURI
+		span       *protocol.Range
+		wantTokens bool
+		wantCalls  int
+	}{
+		{"small document whole", small, nil, true, 1},
+		{"large document whole", large, nil, false, 1},
+		{"large document range", large, &protocol.Range{End: protocol.Position{Line: 2}}, true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tokens *protocol.SemanticTokens
+			var err error
+			if tt.span == nil {
+				tokens, err = s.handleSemanticTokensFull(context.Background(), &protocol.SemanticTokensParams{TextDocument: protocol.TextDocumentIdentifier{URI: tt.uri}})
+			} else {
+				tokens, err = s.handleSemanticTokensRange(context.Background(), &protocol.SemanticTokensRangeParams{TextDocument: protocol.TextDocumentIdentifier{URI: tt.uri}, Range: *tt.span})
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tokens == nil || tokens.Data == nil {
+				t.Fatalf("tokens = %v, want a result with data", tokens)
+			}
+			if got := len(tokens.Data) > 0; got != tt.wantTokens {
+				t.Errorf("tokens %v, want tokens %v", tokens.Data, tt.wantTokens)
+			}
+			if calls := fake.callCount("/v2/highlight"); calls != tt.wantCalls {
+				t.Errorf("/v2/highlight called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestSemanticTokensGivenUpOnClose(t *testing.T) {
+	fake := newFakeOmniSharp(t, map[string]interface{}{"/v2/highlight": map[string]interface{}{"Spans": []HighlightSpan{}}})
+	fake.setDelay(time.Second)
+	s, _ := newTestServer(t, fake)
+	uri := testURI(s, "Player.cs")
+	openTestDocument(s, uri, "class Player { }")
+
+	done := make(chan error)
+	go func() {
+		_, err := s.handleSemanticTokensFull(context.Background(), &protocol.SemanticTokensParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}})
+		done <- err
+	}()
+	waitFor(t, "the highlight request", func() bool { return fake.callCount("/v2/highlight") == 1 })
+	s.handleDidClose(context.Background(), &protocol.DidCloseTextDocumentParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}})
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("err = %v, want the request cancelled", err)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Errorf("highlighting went on after the document closed")
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"log"
+	"slices"
+
+	"go.lsp.dev/protocol"
+)
+
+// methodConfig returns the configuration in effect, for users to check their settings took
+const methodConfig = "unity-lsp/config"
+
+// settingsSection is the key clients nest our settings under in didChangeConfiguration
+const settingsSection = "unity-lsp"
+
+// resolveConfig computes the configuration in effect: the defaults, overlaid with the
+// initializationOptions and then with the settings last sent by didChangeConfiguration
+func resolveConfig(initializationOptions, settings interface{}) (Config, error) {
+	config := DefaultConfig()
+	if err := config.apply(initializationOptions); err != nil {
+		return config, err
+	}
+	if err := config.apply(settings); err != nil {
+		return config, err
+	}
+	if err := config.Completion.normalizeKindPriority(); err != nil {
+		log.Printf("invalid completion.kindPriority, using the default: %v", err)
+		config.Completion.KindPriority = DefaultConfig().Completion.KindPriority
+	}
+	return config, nil
+}
+
+// handleDidChangeConfiguration applies new settings. Settings read when OmniSharp is launched,
+// such as its path and the formatting style, and the advertised trigger characters take
+// effect on the next start; changed analyzer settings offer to restart it
+func (s *Server) handleDidChangeConfiguration(ctx context.Context, params *protocol.DidChangeConfigurationParams) {
+	settings := params.Settings
+	if sections, ok := settings.(map[string]interface{}); ok {
+		if section, ok := sections[settingsSection]; ok {
+			settings = section
+		}
+	}
+
+	config, err := resolveConfig(s.initializationOptions, settings)
+	if err != nil {
+		log.Printf("invalid settings, keeping the current configuration: %v", err)
+		return
+	}
+	previous := *s.currentConfig()
+	s.config.Store(&config)
+
+	// Resolved documentation depends on how it is rendered
+	s.documentation.clear()
+	s.forgetDocuments(ctx, s.documents.SetCapacity(config.Documents.MaxTracked))
+	if previous.Diagnostics.Scope == scopeFullProject && config.Diagnostics.Scope != scopeFullProject {
+		s.workspaceDiagnostics.clear(ctx, s.client)
+	}
+	s.workspaceDiagnostics.schedule(s)
+
+	if !slices.Equal(previous.OmniSharp.omnisharpArgs(), config.OmniSharp.omnisharpArgs()) {
+		// Asking waits for the user, which must not hold up the read loop
+		go s.offerRestart(context.Background())
+	}
+}
+
+// offerRestart offers to restart a running OmniSharp so changed analyzer and code generation
+// settings take effect
+func (s *Server) offerRestart(ctx context.Context) {
+	s.mu.Lock()
+	ready := s.state == backendReady
+	s.mu.Unlock()
+	if !ready {
+		return
+	}
+	if !s.confirm(ctx, s.localize("Unity LSP: the OmniSharp settings changed, which takes restarting OmniSharp. Restart it now?"), "Restart") {
+		return
+	}
+	if _, err := s.handleReloadProjects(ctx, &ReloadProjectsParams{}); err != nil {
+		log.Printf("failed to restart OmniSharp: %v", err)
+	}
+}
+
+// handleConfig returns the configuration in effect, with secrets redacted
+func (s *Server) handleConfig() Config {
+	return s.currentConfig().redacted()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// shutdownGrace bounds how long shutdown waits for requests in flight before cancelling them
+const shutdownGrace = 3 * time.Second
+
+// requestTracker follows the requests answered off the read loop, so shutdown can let them
+// finish, or cancel those that don't in time
+type requestTracker struct {
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newRequestTracker() *requestTracker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &requestTracker{ctx: ctx, cancel: cancel}
+}
+
+// goRequest serves a request in the background. Its context is cancelled if shutdown gives up
+// waiting for it, which replies RequestCancelled through withErrorCodes
+func (s *Server) goRequest(ctx context.Context, serve func(ctx context.Context)) {
+	s.requests.wg.Add(1)
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(s.requests.ctx, cancel)
+	go func() {
+		defer s.requests.wg.Done()
+		defer cancel()
+		defer stop()
+		serve(ctx)
+	}()
+}
+
+// handleShutdown stops taking requests and waits, at most shutdownGrace, for those in flight,
+// cancelling the rest. OmniSharp keeps running until exit, as the client may still send it.
+// It must not run on the read loop, since requests in flight may wait on the client
+func (s *Server) handleShutdown() {
+	done := make(chan struct{})
+	go func() {
+		s.requests.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(shutdownGrace):
+		log.Printf("requests still running %s after shutdown, cancelling them", shutdownGrace)
+		s.requests.cancel()
+		// Work that can't be cancelled, such as OmniSharp restarting, isn't waited for further
+		select {
+		case <-done:
+		case <-time.After(shutdownGrace):
+		}
+	}
+}
+
+// handleExit stops OmniSharp and exits, with status 1 if the client didn't shut down first
+func (s *Server) handleExit() {
+	s.stopOmniSharp()
+	releaseLock(s.rootPath)
+	if !s.shuttingDown {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+)
+
+// TestShutdownWaitsForRequests checks shutdown is answered after the requests in flight, which
+// finish or are cancelled once shutdownGrace is up, and leaves OmniSharp running for exit
+func TestShutdownWaitsForRequests(t *testing.T) {
+	tests := []struct {
+		name          string
+		delay         time.Duration
+		wantCancelled bool
+	}{
+		{"finishing in time", 200 * time.Millisecond, false},
+		{"outlasting the grace", shutdownGrace + time.Second, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{"/typelookup": TypeLookupResponse{Type: "float Player.speed"}})
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, "class Player { float speed; }\n")
+			fake.setDelay(test.delay)
+
+			type answer struct {
+				method string
+				err    error
+			}
+			answers := make(chan answer, 3)
+			send := func(id int32, method string, params interface{}) {
+				t.Helper()
+				request, err := jsonrpc2.NewCall(jsonrpc2.NewNumberID(id), method, params)
+				if err != nil {
+					t.Fatal(err)
+				}
+				s.handle(context.Background(), func(ctx context.Context, result interface{}, err error) error {
+					answers <- answer{method, err}
+					return nil
+				}, request)
+			}
+			hover := protocol.HoverParams{TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Position:     protocol.Position{Character: 23},
+			}}
+
+			send(1, protocol.MethodTextDocumentHover, hover)
+			send(2, protocol.MethodShutdown, nil)
+			first, second := <-answers, <-answers
+			if first.method != protocol.MethodTextDocumentHover || second.method != protocol.MethodShutdown || second.err != nil {
+				t.Fatalf("answered %s then %s (%v), want the hover before shutdown", first.method, second.method, second.err)
+			}
+			var wireErr *jsonrpc2.Error
+			if cancelled := errors.As(first.err, &wireErr) && wireErr.Code == protocol.CodeRequestCancelled; cancelled != test.wantCancelled || !cancelled && first.err != nil {
+				t.Errorf("hover answered %v, want cancelled %v", first.err, test.wantCancelled)
+			}
+
+			// Shut down, new requests are refused
+			send(3, protocol.MethodTextDocumentHover, hover)
+			if refused := <-answers; !errors.As(refused.err, &wireErr) || wireErr.Code != jsonrpc2.InvalidRequest {
+				t.Errorf("hover after shutdown answered %v, want InvalidRequest", refused.err)
+			}
+			if s.backend() == nil {
+				t.Error("OmniSharp stopped before exit")
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"go.lsp.dev/protocol"
+)
+
+var (
+	signatureHelpTriggers = []string{"(", ","}
+	// signatureHelpRetriggers update signature help that is already showing
+	signatureHelpRetriggers = []string{")"}
+)
+
+// SignatureHelpResponse is OmniSharp's /signaturehelp response
+type SignatureHelpResponse struct {
+	Signatures []struct {
+		Label         string `json:"Label"`
+		Documentation string `json:"Documentation"`
+		Parameters    []struct {
+			Name          string `json:"Name"`
+			Label         string `json:"Label"`
+			Documentation string `json:"Documentation"`
+		} `json:"Parameters"`
+	} `json:"Signatures"`
+	ActiveSignature int `json:"ActiveSignature"`
+	ActiveParameter int `json:"ActiveParameter"`
+}
+
+func (s *Server) handleSignatureHelp(ctx context.Context, params *protocol.SignatureHelpParams) (*protocol.SignatureHelp, error) {
+	omnisharp := s.backend()
+	if omnisharp == nil {
+		return nil, nil
+	}
+
+	response, err := omnisharp.SendRequest(ctx, "/signaturehelp", omnisharpPosition(params.TextDocument.URI, params.Position))
+	if err != nil {
+		return nil, err
+	}
+
+	var omnisharpResponse SignatureHelpResponse
+	if err := json.Unmarshal(response, &omnisharpResponse); err != nil {
+		return nil, err
+	}
+	if len(omnisharpResponse.Signatures) == 0 {
+		return nil, nil
+	}
+
+	help := &protocol.SignatureHelp{
+		Signatures: make([]protocol.SignatureInformation, len(omnisharpResponse.Signatures)),
+	}
+	for i, signature := range omnisharpResponse.Signatures {
+		information := protocol.SignatureInformation{
+			Label:         signature.Label,
+			Documentation: signature.Documentation,
+			Parameters:    make([]protocol.ParameterInformation, len(signature.Parameters)),
+		}
+		for j, parameter := range signature.Parameters {
+			information.Parameters[j] = protocol.ParameterInformation{
+				Label:         parameter.Label,
+				Documentation: parameter.Documentation,
+			}
+		}
+		help.Signatures[i] = information
+	}
+
+	help.ActiveSignature = activeSignature(params.Context, help, omnisharpResponse.ActiveSignature)
+
+	// OmniSharp's active parameter can lag behind the buffer, e.g. right after a comma, so
+	// count the arguments before the caret ourselves
+	activeParameter := omnisharpResponse.ActiveParameter
+	if doc, ok := s.documents.Get(params.TextDocument.URI); ok {
+		if index, ok := argumentIndex(doc.Text, offsetAt(doc.Text, params.Position)); ok {
+			activeParameter = index
+		}
+	}
+	if parameters := len(help.Signatures[help.ActiveSignature].Parameters); activeParameter >= parameters && parameters > 0 {
+		// Arguments past the last parameter belong to a params array
+		activeParameter = parameters - 1
+	}
+	if activeParameter < 0 {
+		activeParameter = 0
+	}
+	help.ActiveParameter = uint32(activeParameter)
+	return help, nil
+}
+
+// activeSignature keeps the overload the user had selected when signature help is retriggered,
+// falling back to OmniSharp's choice
+func activeSignature(helpContext *protocol.SignatureHelpContext, help *protocol.SignatureHelp, omnisharpActive int) uint32 {
+	if helpContext != nil && helpContext.IsRetrigger && helpContext.ActiveSignatureHelp != nil {
+		previous := helpContext.ActiveSignatureHelp
+		if int(previous.ActiveSignature) < len(previous.Signatures) {
+			label := previous.Signatures[previous.ActiveSignature].Label
+			for i, signature := range help.Signatures {
+				if signature.Label == label {
+					return uint32(i)
+				}
+			}
+		}
+	}
+
+	if omnisharpActive < 0 || omnisharpActive >= len(help.Signatures) {
+		return 0
+	}
+	return uint32(omnisharpActive)
+}
+
+// argumentIndex finds the call whose argument list contains offset and returns the index of
+// the argument there, skipping commas inside nested calls, brackets and string literals. It
+// reports false when offset isn't inside an argument list
+func argumentIndex(text string, offset int) (int, bool) {
+	_, index, ok := enclosingCall(text, offset)
+	return index, ok
+}
+
+// enclosingCall is argumentIndex, also returning the offset of the call's open parenthesis
+func enclosingCall(text string, offset int) (open, index int, ok bool) {
+	depth := 0
+	for i := offset - 1; i >= 0; i-- {
+		switch c := text[i]; c {
+		case ')', ']', '}':
+			depth++
+		case '(', '[', '{':
+			if depth == 0 {
+				return i, index, c == '('
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				index++
+			}
+		case ';':
+			if depth == 0 {
+				return 0, 0, false
+			}
+		case '"', '\'':
+			// Step back over the literal; its start is the previous unescaped quote on the line
+			start := i - 1
+			for start >= 0 && text[start] != '\n' && (text[start] != c || (start > 0 && text[start-1] == '\\')) {
+				start--
+			}
+			if start < 0 || text[start] == '\n' {
+				return 0, 0, false
+			}
+			i = start
+		}
+	}
+	return 0, 0, false
+}
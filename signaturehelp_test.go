@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestArgumentIndex(t *testing.T) {
+	tests := []struct {
+		name string
+		// text marks the caret with |
+		text   string
+		want   int
+		wantOK bool
+	}{
+		{"first argument", "Move(|", 0, true},
+		{"second argument", "Move(1f, |", 1, true},
+		{"third argument", "Move(1f, 2f, 3|", 2, true},
+		{"nested call", "Move(1f, Mathf.Clamp(x, 0, 1), |", 2, true},
+		{"inside a nested call", "Move(1f, Mathf.Clamp(x, |", 1, true},
+		{"brackets", "Move(values[0, 1], |", 1, true},
+		{"string with a comma", `Log("a, b", |`, 1, true},
+		{"string with an escaped quote", `Log("a \", b", |`, 1, true},
+		{"character literal comma", "Split(',', |", 1, true},
+		{"lambda body", "Where(x => { return x; }, |", 1, true},
+		{"not in a call", "var x = 1|", 0, false},
+		{"after the statement", "Move(1f);\nvar x = |", 0, false},
+		{"indexer", "values[0, |", 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			offset := strings.Index(test.text, "|")
+			text := strings.Replace(test.text, "|", "", 1)
+			index, ok := argumentIndex(text, offset)
+			if ok != test.wantOK || (ok && index != test.want) {
+				t.Errorf("argumentIndex = %d, %v, want %d, %v", index, ok, test.want, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestActiveSignature(t *testing.T) {
+	help := &protocol.SignatureHelp{Signatures: []protocol.SignatureInformation{
+		{Label: "Move(Vector3 delta)"}, {Label: "Move(float x, float y)"},
+	}}
+	previous := func(active uint32, labels ...string) *protocol.SignatureHelp {
+		previous := &protocol.SignatureHelp{ActiveSignature: active}
+		for _, label := range labels {
+			previous.Signatures = append(previous.Signatures, protocol.SignatureInformation{Label: label})
+		}
+		return previous
+	}
+	tests := []struct {
+		name            string
+		context         *protocol.SignatureHelpContext
+		omnisharpActive int
+		want            uint32
+	}{
+		{"OmniSharp's choice", nil, 1, 1},
+		{"OmniSharp's choice out of range", nil, 4, 0},
+		{
+			name:    "retrigger keeps the selected overload",
+			context: &protocol.SignatureHelpContext{IsRetrigger: true, ActiveSignatureHelp: previous(1, "Move(Vector3 delta)", "Move(float x, float y)")},
+			want:    1,
+		},
+		{
+			name:            "retrigger after the overload went away",
+			context:         &protocol.SignatureHelpContext{IsRetrigger: true, ActiveSignatureHelp: previous(0, "Jump()")},
+			omnisharpActive: 1,
+			want:            1,
+		},
+		{
+			name:    "first trigger",
+			context: &protocol.SignatureHelpContext{TriggerKind: protocol.SignatureHelpTriggerKindTriggerCharacter, ActiveSignatureHelp: previous(1, "Move(Vector3 delta)", "Move(float x, float y)")},
+			want:    0,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := activeSignature(test.context, help, test.omnisharpActive); got != test.want {
+				t.Errorf("active signature = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+// TestSignatureHelpActiveParameter moves the caret across the arguments of a call, OmniSharp
+// answering with a stale active parameter throughout
+func TestSignatureHelpActiveParameter(t *testing.T) {
+	fake := newFakeOmniSharp(t, map[string]interface{}{"/signaturehelp": map[string]interface{}{
+		"Signatures": []interface{}{map[string]interface{}{
+			"Label": "void Player.Move(float x, float y, params float[] rest)",
+			"Parameters": []interface{}{
+				map[string]interface{}{"Name": "x", "Label": "float x"},
+				map[string]interface{}{"Name": "y", "Label": "float y"},
+				map[string]interface{}{"Name": "rest", "Label": "params float[] rest"},
+			},
+		}},
+		"ActiveParameter": 0,
+	}})
+	s, _ := newTestServer(t, fake)
+	uri := testURI(s, "Player.cs")
+	line := "Move(1f, Mathf.Max(a, b), 3f, 4f);"
+	openTestDocument(s, uri, line)
+
+	tests := []struct {
+		// after is the text the caret follows
+		after string
+		want  uint32
+	}{
+		{"Move(", 0},
+		{"Move(1f, ", 1},
+		{"Mathf.Max(a, b)", 1},
+		{"Mathf.Max(a, b), ", 2},
+		// Past the last parameter, into its params array
+		{"3f, ", 2},
+	}
+	for _, test := range tests {
+		t.Run(test.after, func(t *testing.T) {
+			character := strings.Index(line, test.after) + len(test.after)
+			help, err := s.handleSignatureHelp(context.Background(), &protocol.SignatureHelpParams{
+				TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+					TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+					Position:     protocol.Position{Character: uint32(character)},
+				},
+				Context: &protocol.SignatureHelpContext{TriggerKind: protocol.SignatureHelpTriggerKindTriggerCharacter, TriggerCharacter: ",", IsRetrigger: true},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if help == nil || help.ActiveParameter != test.want {
+				t.Errorf("signature help = %+v, want active parameter %d", help, test.want)
+			}
+		})
+	}
+}
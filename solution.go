@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// solutionSkipDirs are Unity and build output folders that never hold the project's solution
+var solutionSkipDirs = map[string]bool{
+	"Library":      true,
+	"Temp":         true,
+	"Logs":         true,
+	"obj":          true,
+	"bin":          true,
+	"node_modules": true,
+}
+
+// hasSolution reports whether OmniSharp would find a .sln or .csproj to load under root
+func hasSolution(root string) bool {
+	found := false
+	filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			name := entry.Name()
+			if path != root && (solutionSkipDirs[name] || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(path); ext == ".sln" || ext == ".csproj" {
+			found = true
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return found
+}
+
+// chooseSolution picks what OmniSharp should load: the workspace root, unless it holds several
+// solutions, in which case the user is asked which one and the answer remembered for later
+// sessions. A remembered solution that no longer exists is asked about again
+func (s *Server) chooseSolution(ctx context.Context) string {
+	solutions, _ := filepath.Glob(filepath.Join(s.rootPath, "*.sln"))
+	if len(solutions) < 2 {
+		return s.rootPath
+	}
+	sort.Strings(solutions)
+
+	state := loadState(s.rootPath)
+	if state.Solution != "" {
+		remembered := filepath.Join(s.rootPath, state.Solution)
+		for _, solution := range solutions {
+			if solution == remembered {
+				return solution
+			}
+		}
+		log.Printf("remembered solution %s no longer exists", remembered)
+	}
+
+	actions := make([]protocol.MessageActionItem, len(solutions))
+	for i, solution := range solutions {
+		actions[i] = protocol.MessageActionItem{Title: filepath.Base(solution)}
+	}
+	choice, err := s.client.ShowMessageRequest(ctx, &protocol.ShowMessageRequestParams{
+		Type:    protocol.MessageTypeInfo,
+		Message: fmt.Sprintf(s.localize("Unity LSP: %s contains several solutions. Which one should OmniSharp load?"), s.rootPath),
+		Actions: actions,
+	})
+	if err != nil || choice == nil {
+		// Dismissed: go with the first for now and ask again next session
+		return solutions[0]
+	}
+
+	for _, solution := range solutions {
+		if filepath.Base(solution) == choice.Title {
+			state.Solution = filepath.Base(solution)
+			if err := saveState(s.rootPath, state); err != nil {
+				log.Printf("failed to remember the solution choice: %v", err)
+			}
+			return solution
+		}
+	}
+	return solutions[0]
+}
+
+// addSolutionCapabilities advertises the features that need OmniSharp, and so a solution
+func addSolutionCapabilities(capabilities *ServerCapabilities) {
+	capabilities.HoverProvider = true
+	capabilities.DefinitionProvider = true
+	capabilities.ReferencesProvider = true
+	capabilities.ImplementationProvider = true
+	capabilities.WorkspaceSymbolProvider = &WorkspaceSymbolOptions{ResolveProvider: true}
+	capabilities.CodeActionProvider = &protocol.CodeActionOptions{CodeActionKinds: codeActionKinds}
+	capabilities.DocumentFormattingProvider = true
+	capabilities.DocumentSymbolProvider = true
+	capabilities.RenameProvider = true
+	capabilities.SemanticTokensProvider = semanticTokensOptions
+	capabilities.InlayHintProvider = &InlayHintOptions{ResolveProvider: true}
+	capabilities.SignatureHelpProvider = &protocol.SignatureHelpOptions{
+		TriggerCharacters:   signatureHelpTriggers,
+		RetriggerCharacters: signatureHelpRetriggers,
+	}
+	capabilities.ExecuteCommandProvider = &protocol.ExecuteCommandOptions{
+		Commands: commands,
+	}
+}
+
+// solutionRegistrations registers the features of addSolutionCapabilities with a client that
+// was initialized without them
+func solutionRegistrations() []protocol.Registration {
+	csharp := protocol.TextDocumentRegistrationOptions{
+		DocumentSelector: protocol.DocumentSelector{{Language: "csharp"}},
+	}
+
+	registrations := []protocol.Registration{
+		{Method: protocol.MethodWorkspaceSymbol, RegisterOptions: WorkspaceSymbolOptions{ResolveProvider: true}},
+		{Method: protocol.MethodWorkspaceExecuteCommand, RegisterOptions: protocol.ExecuteCommandRegistrationOptions{
+			Commands: commands,
+		}},
+		{Method: protocol.MethodTextDocumentCodeAction, RegisterOptions: protocol.CodeActionRegistrationOptions{
+			TextDocumentRegistrationOptions: csharp,
+			CodeActionOptions:               protocol.CodeActionOptions{CodeActionKinds: codeActionKinds},
+		}},
+		{Method: protocol.MethodTextDocumentSignatureHelp, RegisterOptions: protocol.SignatureHelpRegistrationOptions{
+			TextDocumentRegistrationOptions: csharp,
+			TriggerCharacters:               signatureHelpTriggers,
+		}},
+		{Method: "textDocument/semanticTokens", RegisterOptions: struct {
+			protocol.TextDocumentRegistrationOptions
+			SemanticTokensOptions
+		}{csharp, semanticTokensOptions}},
+		{Method: methodInlayHint, RegisterOptions: struct {
+			protocol.TextDocumentRegistrationOptions
+			InlayHintOptions
+		}{csharp, InlayHintOptions{ResolveProvider: true}}},
+	}
+	for _, method := range []string{
+		protocol.MethodTextDocumentHover,
+		protocol.MethodTextDocumentDefinition,
+		protocol.MethodTextDocumentReferences,
+		protocol.MethodTextDocumentImplementation,
+		protocol.MethodTextDocumentFormatting,
+		protocol.MethodTextDocumentDocumentSymbol,
+		protocol.MethodTextDocumentRename,
+	} {
+		registrations = append(registrations, protocol.Registration{Method: method, RegisterOptions: csharp})
+	}
+	for i := range registrations {
+		registrations[i].ID = registrations[i].Method
+	}
+	return registrations
+}
+
+// enterNoSolution explains why C# features are missing and watches for solution files, e.g.
+// generated by Unity's Open C# Project, to start OmniSharp once one appears
+func (s *Server) enterNoSolution(ctx context.Context) {
+	log.Printf("no .sln or .csproj found under %s, waiting for one before starting OmniSharp", s.rootPath)
+
+	message := fmt.Sprintf(s.localize("Unity LSP: no .sln or .csproj found in %s. Open the folder containing your Unity project, or run Assets > Open C# Project in Unity to generate solution files."), s.rootPath)
+	if s.supportsWatchedFiles() {
+		s.watchSolutionFiles(ctx)
+	} else {
+		message += " " + s.localize("Restart the server once they exist.")
+	}
+	s.client.ShowMessage(ctx, &protocol.ShowMessageParams{
+		Type:    protocol.MessageTypeWarning,
+		Message: message,
+	})
+}
+
+func (s *Server) supportsWatchedFiles() bool {
+	workspace := s.capabilities.Workspace
+	return workspace != nil && workspace.DidChangeWatchedFiles != nil && workspace.DidChangeWatchedFiles.DynamicRegistration
+}
+
+func (s *Server) watchSolutionFiles(ctx context.Context) {
+	err := s.client.RegisterCapability(ctx, &protocol.RegistrationParams{
+		Registrations: []protocol.Registration{{
+			ID:     "unity-lsp.solutionFiles",
+			Method: protocol.MethodWorkspaceDidChangeWatchedFiles,
+			RegisterOptions: protocol.DidChangeWatchedFilesRegistrationOptions{
+				Watchers: []protocol.FileSystemWatcher{
+					{GlobPattern: "**/*.sln"},
+					{GlobPattern: "**/*.csproj"},
+				},
+			},
+		}},
+	})
+	if err != nil {
+		log.Printf("failed to watch for solution files: %v", err)
+	}
+}
+
+// handleDidChangeWatchedFiles drops the resources listed for completion when they change,
+// reloads OmniSharp for changed project files if omnisharp.reloadOnProjectChanges is set, and
+// leaves the no-solution mode once a solution file exists
+func (s *Server) handleDidChangeWatchedFiles(ctx context.Context, params *protocol.DidChangeWatchedFilesParams) {
+	if isResourceChange(params.Changes) {
+		s.resources.invalidate()
+	}
+	if s.currentConfig().OmniSharp.ReloadOnProjectChanges && s.backend() != nil {
+		for _, change := range params.Changes {
+			if isProjectFile(change.URI) {
+				s.scheduleProjectReload()
+				return
+			}
+		}
+	}
+
+	s.mu.Lock()
+	waiting := s.state == backendNoSolution
+	s.mu.Unlock()
+	if !waiting || !hasSolution(s.rootPath) {
+		return
+	}
+
+	s.mu.Lock()
+	if s.state != backendNoSolution {
+		s.mu.Unlock()
+		return
+	}
+	s.state = backendStarting
+	s.mu.Unlock()
+
+	log.Printf("solution found under %s, starting OmniSharp", s.rootPath)
+	// Registering waits on the client's response, which arrives through this read loop
+	go func() {
+		if err := s.client.RegisterCapability(ctx, &protocol.RegistrationParams{
+			Registrations: solutionRegistrations(),
+		}); err != nil {
+			log.Printf("failed to register C# features: %v", err)
+		}
+		s.startOmniSharp(ctx)
+	}()
+}
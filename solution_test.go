@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestHasSolution(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  bool
+	}{
+		{"none", []string{"Assets/Player.cs"}, false},
+		{"solution in the root", []string{"Game.sln"}, true},
+		{"project in a folder", []string{"Packages/com.game.tools/Tools.csproj"}, true},
+		{"only in Library", []string{"Library/PackageCache/Cached.csproj"}, false},
+		{"only in a hidden folder", []string{".vs/Game.sln"}, false},
+		{"only in build output", []string{"Tools/obj/Tools.csproj", "Tools/bin/Tools.sln"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, file := range test.files {
+				path := filepath.Join(root, filepath.FromSlash(file))
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := hasSolution(root); got != test.want {
+				t.Errorf("hasSolution = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestNoSolutionMode checks a workspace without a solution gets reduced capabilities and
+// guidance, and the rest once a solution appears
+func TestNoSolutionMode(t *testing.T) {
+	fake := newFakeOmniSharp(t, map[string]interface{}{"/checkreadystatus": map[string]bool{"Ready": true}})
+	s, client := newTestServer(t, nil)
+	root := s.rootPath
+	result, err := s.handleInitialize(&protocol.InitializeParams{
+		RootURI: pathToURI(root),
+		Capabilities: protocol.ClientCapabilities{Workspace: &protocol.WorkspaceClientCapabilities{
+			DidChangeWatchedFiles: &protocol.DidChangeWatchedFilesWorkspaceClientCapabilities{DynamicRegistration: true},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Capabilities.HoverProvider != nil || result.Capabilities.CompletionProvider == nil {
+		t.Errorf("advertised hover %v and completion %v without a solution", result.Capabilities.HoverProvider, result.Capabilities.CompletionProvider)
+	}
+	configure(s, func(config *Config) { config.OmniSharp.Address = fake.URL })
+
+	state := func() backendState {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.state
+	}
+	s.startOmniSharp(context.Background())
+	if state() != backendNoSolution || s.backend() != nil {
+		t.Fatalf("state = %d without a solution, want %d", state(), backendNoSolution)
+	}
+	waitFor(t, "the guidance", func() bool { return len(client.received(protocol.MethodWindowShowMessage)) > 0 })
+	var message protocol.ShowMessageParams
+	json.Unmarshal(client.received(protocol.MethodWindowShowMessage)[0], &message)
+	if message.Type != protocol.MessageTypeWarning || !strings.Contains(message.Message, "Open C# Project") {
+		t.Errorf("showed %+v, want guidance", message)
+	}
+	if registered := client.received(protocol.MethodClientRegisterCapability); len(registered) != 1 || !strings.Contains(string(registered[0]), "**/*.sln") {
+		t.Errorf("registered %s, want a watcher of solution files", registered)
+	}
+
+	// A change that doesn't add a solution leaves it waiting
+	changed := func(name string) {
+		s.handleDidChangeWatchedFiles(context.Background(), &protocol.DidChangeWatchedFilesParams{
+			Changes: []*protocol.FileEvent{{URI: pathToURI(filepath.Join(root, name)), Type: protocol.FileChangeTypeCreated}},
+		})
+	}
+	changed("Assets/Player.cs")
+	if state() != backendNoSolution {
+		t.Errorf("state = %d after an unrelated change, want %d", state(), backendNoSolution)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "Game.sln"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	changed("Game.sln")
+	waitFor(t, "OmniSharp to start", func() bool { return state() == backendReady })
+	registered := client.received(protocol.MethodClientRegisterCapability)
+	if len(registered) != 2 || !strings.Contains(string(registered[1]), protocol.MethodTextDocumentHover) {
+		t.Errorf("registered %s, want the C# features once a solution appears", registered)
+	}
+}
+
+// TestSolutionChoicePersists asks which of several solutions to load in one session, and has
+// the next sessions of the workspace reuse the answer while its solution exists
+func TestSolutionChoicePersists(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"Game.sln", "Tools.sln"} {
+		if err := os.WriteFile(filepath.Join(root, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// choose chooses a solution in a new server for root, whose user picks choice if asked
+	choose := func(choice string) (solution string, asked bool) {
+		t.Helper()
+		s, client := newTestServer(t, nil)
+		s.rootPath = root
+		if choice != "" {
+			client.answer(protocol.MethodWindowShowMessageRequest, protocol.MessageActionItem{Title: choice})
+		}
+		solution = s.chooseSolution(context.Background())
+		return solution, len(client.received(protocol.MethodWindowShowMessageRequest)) > 0
+	}
+
+	sessions := []struct {
+		name string
+		// before changes the workspace before the session
+		before    func()
+		choice    string
+		want      string
+		wantAsked bool
+	}{
+		{name: "first", choice: "Tools.sln", want: "Tools.sln", wantAsked: true},
+		{name: "next", want: "Tools.sln"},
+		{
+			name:   "after the solution was deleted",
+			before: func() { os.Remove(filepath.Join(root, "Tools.sln")) },
+			want:   root,
+		},
+		{
+			name:      "after another solution was added",
+			before:    func() { os.WriteFile(filepath.Join(root, "Editor.sln"), nil, 0o644) },
+			choice:    "Game.sln",
+			want:      "Game.sln",
+			wantAsked: true,
+		},
+		{name: "dismissed", before: func() { os.Remove(statePath(root)) }, want: "Editor.sln", wantAsked: true},
+	}
+	for _, test := range sessions {
+		if test.before != nil {
+			test.before()
+		}
+		solution, asked := choose(test.choice)
+		if test.want != root {
+			test.want = filepath.Join(root, test.want)
+		}
+		if solution != test.want || asked != test.wantAsked {
+			t.Errorf("%s session: loaded %s, asked %v; want %s, asked %v", test.name, solution, asked, test.want, test.wantAsked)
+		}
+		if test.name == "first" {
+			if state := loadState(root); state.Solution != "Tools.sln" {
+				t.Errorf("remembered %q, want Tools.sln", state.Solution)
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// workspaceState is remembered between sessions in .unity-lsp/state.json under the workspace root
+type workspaceState struct {
+	// Solution is the solution the user picked, relative to the workspace root
+	Solution string `json:"solution,omitempty"`
+}
+
+func statePath(root string) string {
+	return filepath.Join(root, ".unity-lsp", "state.json")
+}
+
+// loadState reads the workspace state, returning an empty state if there is none
+func loadState(root string) workspaceState {
+	var state workspaceState
+	data, err := os.ReadFile(statePath(root))
+	if err != nil {
+		return state
+	}
+	json.Unmarshal(data, &state)
+	return state
+}
+
+func saveState(root string, state workspaceState) error {
+	path := statePath(root)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
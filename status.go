@@ -0,0 +1,59 @@
+package main
+
+// methodStatus reports the state of OmniSharp and the resources it uses, to triage a server
+// eating memory or CPU on large projects
+const methodStatus = "unity-lsp/status"
+
+// StatusResult is the result of unity-lsp/status. The usages are those of the OmniSharp
+// processes we launched: none is reported for one shared by another server, nor on systems
+// whose process usage we can't read
+type StatusResult struct {
+	// State is starting, reloading, ready, degraded or noSolution
+	State    string `json:"state"`
+	Projects int    `json:"projects"`
+	// OmniSharp is the usage of the primary OmniSharp
+	OmniSharp *ProcessUsage `json:"omnisharp,omitempty"`
+	// Completion is the usage of the OmniSharp dedicated to completion, if any
+	Completion *ProcessUsage `json:"completion,omitempty"`
+}
+
+var backendStateNames = map[backendState]string{
+	backendStarting:   "starting",
+	backendReady:      "ready",
+	backendDegraded:   "degraded",
+	backendNoSolution: "noSolution",
+}
+
+func (s *Server) handleStatus() *StatusResult {
+	s.mu.Lock()
+	state, process, replica := backendStateNames[s.state], s.process, s.replicaProcess
+	// A reload goes through starting, until the open buffers are synced again
+	if s.reloaded != nil {
+		state = "reloading"
+	}
+	s.mu.Unlock()
+
+	return &StatusResult{
+		State:      state,
+		Projects:   s.projects.count(),
+		OmniSharp:  process.currentUsage(),
+		Completion: replica.currentUsage(),
+	}
+}
+
+// currentUsage is the last usage sampled for p, nil if p isn't running or has no usage
+func (p *OmniSharpProcess) currentUsage() *ProcessUsage {
+	if p == nil {
+		return nil
+	}
+	select {
+	case <-p.exited:
+		return nil
+	default:
+	}
+	usage, ok := p.usage.usage()
+	if !ok {
+		return nil
+	}
+	return &usage
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"testing"
+)
+
+// TestStatus checks unity-lsp/status reports the state of OmniSharp, with the usage of the
+// processes we launched while they run
+func TestStatus(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process usage isn't read on Windows")
+	}
+	running := func(t *testing.T) *OmniSharpProcess {
+		exited := make(chan struct{})
+		t.Cleanup(func() { close(exited) })
+		return &OmniSharpProcess{exited: exited, usage: sampleUsage(os.Getpid(), exited)}
+	}
+	exited := func(t *testing.T) *OmniSharpProcess {
+		process := running(t)
+		process.exited = make(chan struct{})
+		close(process.exited)
+		return process
+	}
+	tests := []struct {
+		name           string
+		state          backendState
+		process        func(t *testing.T) *OmniSharpProcess
+		replica        func(t *testing.T) *OmniSharpProcess
+		wantState      string
+		wantOmniSharp  bool
+		wantCompletion bool
+	}{
+		{"launched", backendReady, running, nil, "ready", true, false},
+		{"with a completion replica", backendReady, running, running, "ready", true, true},
+		{"exited", backendDegraded, exited, nil, "degraded", false, false},
+		{"shared", backendReady, nil, nil, "ready", false, false},
+		{"starting", backendStarting, running, nil, "starting", true, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, _ := newTestServer(t, nil)
+			s.state = test.state
+			if test.process != nil {
+				s.process = test.process(t)
+			}
+			if test.replica != nil {
+				s.replicaProcess = test.replica(t)
+			}
+
+			result, err := call(t, s, 1, methodStatus, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			status := result.(*StatusResult)
+			if status.State != test.wantState {
+				t.Errorf("state = %q, want %q", status.State, test.wantState)
+			}
+			if (status.OmniSharp != nil) != test.wantOmniSharp || (status.Completion != nil) != test.wantCompletion {
+				t.Fatalf("usages %+v and %+v, want OmniSharp's %v and completion's %v", status.OmniSharp, status.Completion, test.wantOmniSharp, test.wantCompletion)
+			}
+			if usage := status.OmniSharp; usage != nil && (usage.PID != os.Getpid() || usage.MemoryBytes == 0) {
+				t.Errorf("usage = %+v, want that of process %d", usage, os.Getpid())
+			}
+		})
+	}
+}
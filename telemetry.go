@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+// timingEvent is the telemetry/event sent after a completion, hover or definition request. It
+// deliberately carries nothing about the code: no file names, contents or identifiers
+type timingEvent struct {
+	Name        string `json:"name"`
+	DurationMs  int64  `json:"durationMs"`
+	ResultCount int    `json:"resultCount"`
+	CacheHit    bool   `json:"cacheHit"`
+}
+
+type requestStatsKey struct{}
+
+// requestStats collects what a handler learns about serving a request, for telemetry
+type requestStats struct {
+	cacheHit bool
+}
+
+func withRequestStats(ctx context.Context) (context.Context, *requestStats) {
+	stats := &requestStats{}
+	return context.WithValue(ctx, requestStatsKey{}, stats), stats
+}
+
+// markCacheHit records that the request was answered from a cache
+func markCacheHit(ctx context.Context) {
+	if stats, ok := ctx.Value(requestStatsKey{}).(*requestStats); ok {
+		stats.cacheHit = true
+	}
+}
+
+// timedRequests are the reads, by their serveRead name, that timingEvents are sent for
+var timedRequests = map[string]bool{
+	"completion": true,
+	"hover":      true,
+	"definition": true,
+}
+
+// reportTiming sends a timingEvent for the timedRequests when telemetry.enabled is set. LSP has
+// no client capability for telemetry, so the opt-in is also what says the client collects these
+// events
+func (s *Server) reportTiming(ctx context.Context, name string, start time.Time, result interface{}, stats *requestStats) {
+	if !timedRequests[name] || !s.currentConfig().Telemetry.Enabled {
+		return
+	}
+	// Served completions cancel their context, which would stop the event being written
+	s.client.Telemetry(context.WithoutCancel(ctx), &timingEvent{
+		Name:        name,
+		DurationMs:  time.Since(start).Milliseconds(),
+		ResultCount: resultCount(result),
+		CacheHit:    stats.cacheHit,
+	})
+}
+
+func resultCount(result interface{}) int {
+	switch result := result.(type) {
+	case *CompletionList:
+		if result != nil {
+			return len(result.Items)
+		}
+	case *protocol.Hover:
+		if result != nil {
+			return 1
+		}
+	case []protocol.Location:
+		return len(result)
+	}
+	return 0
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+// TestTimingTelemetry checks the timing events of completion, hover and definition carry their
+// name, duration, result count and cache hit, and nothing about the code they read, and that
+// other reads send none
+func TestTimingTelemetry(t *testing.T) {
+	const text = "class Player { float speed; void Update() { speed = 1; } }\n"
+	position := protocol.TextDocumentPositionParams{Position: protocol.Position{Line: 0, Character: 46}}
+	tests := []struct {
+		name      string
+		method    string
+		params    func(uri protocol.DocumentURI) interface{}
+		enabled   bool
+		wantCount float64
+		// twice sends the request again, which the cache answers
+		twice bool
+		// untimed is a read no event is sent for
+		untimed bool
+	}{
+		{name: "hover", method: protocol.MethodTextDocumentHover, enabled: true, wantCount: 1, params: func(uri protocol.DocumentURI) interface{} {
+			position.TextDocument.URI = uri
+			return protocol.HoverParams{TextDocumentPositionParams: position}
+		}},
+		{name: "hover", method: protocol.MethodTextDocumentHover, enabled: true, wantCount: 1, twice: true, params: func(uri protocol.DocumentURI) interface{} {
+			position.TextDocument.URI = uri
+			return protocol.HoverParams{TextDocumentPositionParams: position}
+		}},
+		{name: "definition", method: protocol.MethodTextDocumentDefinition, enabled: true, wantCount: 2, params: func(uri protocol.DocumentURI) interface{} {
+			position.TextDocument.URI = uri
+			return protocol.DefinitionParams{TextDocumentPositionParams: position}
+		}},
+		{name: "completion", method: protocol.MethodTextDocumentCompletion, enabled: true, wantCount: 2, params: func(uri protocol.DocumentURI) interface{} {
+			position.TextDocument.URI = uri
+			return protocol.CompletionParams{
+				TextDocumentPositionParams: position,
+				Context:                    &protocol.CompletionContext{TriggerKind: protocol.CompletionTriggerKindInvoked},
+			}
+		}},
+		{name: "hover", method: protocol.MethodTextDocumentHover, params: func(uri protocol.DocumentURI) interface{} {
+			position.TextDocument.URI = uri
+			return protocol.HoverParams{TextDocumentPositionParams: position}
+		}},
+		{name: "documentSymbol", method: protocol.MethodTextDocumentDocumentSymbol, enabled: true, untimed: true, params: func(uri protocol.DocumentURI) interface{} {
+			return protocol.DocumentSymbolParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}}
+		}},
+	}
+	for _, test := range tests {
+		name := test.name
+		switch {
+		case !test.enabled:
+			name += " disabled"
+		case test.twice:
+			name += " cached"
+		}
+		t.Run(name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{
+				"/typelookup":        TypeLookupResponse{Type: "float Player.speed"},
+				"/v2/gotodefinition": definitionResponse("/project/Assets/Player.cs", "/project/Assets/Player.Movement.cs"),
+				"/autocomplete":      autoCompleteItems("speed", "spawnRate"),
+			})
+			s, client := newTestServer(t, fake)
+			configure(s, func(config *Config) { config.Telemetry.Enabled = test.enabled })
+			uri := testURI(s, "Player.cs")
+			openTestDocument(s, uri, text)
+
+			want := 1
+			if test.twice {
+				want = 2
+				call(t, s, 1, test.method, test.params(uri))
+			}
+			if _, err := call(t, s, 2, test.method, test.params(uri)); err != nil {
+				t.Fatal(err)
+			}
+			if !test.enabled || test.untimed {
+				// Messages reach the client in order, so this one arrives after any event
+				s.client.LogMessage(context.Background(), &protocol.LogMessageParams{Type: protocol.MessageTypeLog, Message: "done"})
+				waitFor(t, "the log message", func() bool { return len(client.received(protocol.MethodWindowLogMessage)) > 0 })
+				if events := client.received(protocol.MethodTelemetryEvent); len(events) != 0 {
+					t.Errorf("sent %d telemetry events, want none", len(events))
+				}
+				return
+			}
+
+			waitFor(t, "telemetry", func() bool { return len(client.received(protocol.MethodTelemetryEvent)) == want })
+			events := client.received(protocol.MethodTelemetryEvent)
+			raw := events[len(events)-1]
+			var event map[string]interface{}
+			if err := json.Unmarshal(raw, &event); err != nil {
+				t.Fatal(err)
+			}
+			var fields []string
+			for field := range event {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+			if wantFields := []string{"cacheHit", "durationMs", "name", "resultCount"}; !reflect.DeepEqual(fields, wantFields) {
+				t.Errorf("event fields = %v, want only %v", fields, wantFields)
+			}
+			if event["name"] != test.name || event["resultCount"] != test.wantCount || event["cacheHit"] != test.twice {
+				t.Errorf("event = %s, want %s with %v results, cache hit %v", raw, test.name, test.wantCount, test.twice)
+			}
+			for _, secret := range []string{"Player", "speed", s.rootPath} {
+				if strings.Contains(string(raw), secret) {
+					t.Errorf("event %s gives away %q", raw, secret)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go.lsp.dev/protocol"
+)
+
+// lineAt returns the given zero-based line of text without its line terminator
+func lineAt(text string, line uint32) string {
+	for i := uint32(0); i < line; i++ {
+		idx := strings.IndexByte(text, '\n')
+		if idx < 0 {
+			return ""
+		}
+		text = text[idx+1:]
+	}
+	if idx := strings.IndexByte(text, '\n'); idx >= 0 {
+		text = text[:idx]
+	}
+	return strings.TrimSuffix(text, "\r")
+}
+
+// splitLines splits text into lines without their line terminators
+func splitLines(text string) []string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
+}
+
+// clampPosition moves pos to the nearest position that exists in lines, so positions past the
+// end of a line or of the document land on that end
+func clampPosition(lines []string, pos protocol.Position) protocol.Position {
+	last := uint32(len(lines) - 1)
+	if pos.Line > last {
+		return protocol.Position{Line: last, Character: byteToUTF16Offset(lines[last], len(lines[last]))}
+	}
+	if length := byteToUTF16Offset(lines[pos.Line], len(lines[pos.Line])); pos.Character > length {
+		pos.Character = length
+	}
+	return pos
+}
+
+// offsetAt converts pos into a byte offset within text, clamped to the end of its line
+func offsetAt(text string, pos protocol.Position) int {
+	offset := 0
+	for i := uint32(0); i < pos.Line; i++ {
+		idx := strings.IndexByte(text[offset:], '\n')
+		if idx < 0 {
+			return len(text)
+		}
+		offset += idx + 1
+	}
+	return offset + utf16ToByteOffset(lineAt(text[offset:], 0), pos.Character)
+}
+
+// positionAt converts a byte offset within text into a position, the inverse of offsetAt
+func positionAt(text string, offset int) protocol.Position {
+	lineStart := strings.LastIndexByte(text[:offset], '\n') + 1
+	return protocol.Position{
+		Line:      uint32(strings.Count(text[:lineStart], "\n")),
+		Character: byteToUTF16Offset(text[lineStart:], offset-lineStart),
+	}
+}
+
+// utf16ToByteOffset converts an LSP character offset (UTF-16 code units) into a byte offset within line
+func utf16ToByteOffset(line string, character uint32) int {
+	units := uint32(0)
+	for i, r := range line {
+		if units >= character {
+			return i
+		}
+		units += uint32(utf16Len(r))
+	}
+	return len(line)
+}
+
+// byteToUTF16Offset converts a byte offset within line into an LSP character offset
+func byteToUTF16Offset(line string, offset int) uint32 {
+	units := uint32(0)
+	for i, r := range line {
+		if i >= offset {
+			break
+		}
+		units += uint32(utf16Len(r))
+	}
+	return units
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+func isIdentifierRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isIdentifier reports whether name can name a type as it is, which keywords can't
+func isIdentifier(name string) bool {
+	if name == "" || !isIdentifierStart(name) || containsString(csharpKeywords, name) {
+		return false
+	}
+	for _, r := range name {
+		if !isIdentifierRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// wordRanges returns the ranges of the identifier around pos: insert spans from the start of
+// the identifier to the caret, replace spans the whole identifier including the part after the caret
+func wordRanges(text string, pos protocol.Position) (insert, replace protocol.Range) {
+	line := lineAt(text, pos.Line)
+	caret := utf16ToByteOffset(line, pos.Character)
+
+	start := caret
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(line[:start])
+		if !isIdentifierRune(r) {
+			break
+		}
+		start -= size
+	}
+
+	end := caret
+	for end < len(line) {
+		r, size := utf8.DecodeRuneInString(line[end:])
+		if !isIdentifierRune(r) {
+			break
+		}
+		end += size
+	}
+
+	startPos := protocol.Position{Line: pos.Line, Character: byteToUTF16Offset(line, start)}
+	caretPos := protocol.Position{Line: pos.Line, Character: byteToUTF16Offset(line, caret)}
+	endPos := protocol.Position{Line: pos.Line, Character: byteToUTF16Offset(line, end)}
+
+	insert = protocol.Range{Start: startPos, End: caretPos}
+	replace = protocol.Range{Start: startPos, End: endPos}
+	return insert, replace
+}
+
+// lineDiffEdit is a single edit turning before into after, spanning only the lines between
+// their common leading and trailing lines
+func lineDiffEdit(before, after string) protocol.TextEdit {
+	a, b := strings.Split(before, "\n"), strings.Split(after, "\n")
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	if suffix == 0 {
+		// The last lines differ, so the edit runs to the end of the document
+		prefix = min(prefix, len(a)-1, len(b)-1)
+		return protocol.TextEdit{
+			Range: protocol.Range{
+				Start: protocol.Position{Line: uint32(prefix)},
+				End:   clampPosition(splitLines(before), protocol.Position{Line: uint32(len(a))}),
+			},
+			NewText: strings.Join(b[prefix:], "\n"),
+		}
+	}
+
+	newText := ""
+	if changed := b[prefix : len(b)-suffix]; len(changed) > 0 {
+		newText = strings.Join(changed, "\n") + "\n"
+	}
+	return protocol.TextEdit{
+		Range: protocol.Range{
+			Start: protocol.Position{Line: uint32(prefix)},
+			End:   protocol.Position{Line: uint32(len(a) - suffix)},
+		},
+		NewText: newText,
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestWordRanges(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		pos         protocol.Position
+		wantInsert  [2]uint32
+		wantReplace [2]uint32
+	}{
+		{"mid-identifier", "transform.position", protocol.Position{Character: 14}, [2]uint32{10, 14}, [2]uint32{10, 18}},
+		{"end of identifier", "transform.position", protocol.Position{Character: 18}, [2]uint32{10, 18}, [2]uint32{10, 18}},
+		{"start of identifier", "transform.position", protocol.Position{Character: 10}, [2]uint32{10, 10}, [2]uint32{10, 18}},
+		{"between tokens", "a = ;", protocol.Position{Character: 4}, [2]uint32{4, 4}, [2]uint32{4, 4}},
+		{"underscores and digits", "x(_speed2)", protocol.Position{Character: 5}, [2]uint32{2, 5}, [2]uint32{2, 9}},
+		{"non-ASCII letters", "var größe", protocol.Position{Character: 7}, [2]uint32{4, 7}, [2]uint32{4, 9}},
+		// An emoji is two UTF-16 code units, and no identifier
+		{"after a surrogate pair", "\"😀\" + na", protocol.Position{Character: 8}, [2]uint32{7, 8}, [2]uint32{7, 9}},
+		{"second line", "class A\n{ Upd }", protocol.Position{Line: 1, Character: 4}, [2]uint32{2, 4}, [2]uint32{2, 5}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			insert, replace := wordRanges(test.text, test.pos)
+			if insert.Start.Line != test.pos.Line || replace.End.Line != test.pos.Line {
+				t.Errorf("ranges %v and %v left line %d", insert, replace, test.pos.Line)
+			}
+			if got := [2]uint32{insert.Start.Character, insert.End.Character}; got != test.wantInsert {
+				t.Errorf("insert = %v, want %v", got, test.wantInsert)
+			}
+			if got := [2]uint32{replace.Start.Character, replace.End.Character}; got != test.wantReplace {
+				t.Errorf("replace = %v, want %v", got, test.wantReplace)
+			}
+		})
+	}
+}
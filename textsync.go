@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"go.lsp.dev/protocol"
+)
+
+func (s *Server) handleDidOpen(ctx context.Context, params *protocol.DidOpenTextDocumentParams) {
+	s.bufferSync.Lock()
+	evicted := s.documents.Open(params.TextDocument.URI, params.TextDocument.Version, params.TextDocument.Text)
+	s.forgetDocuments(ctx, evicted)
+	s.syncBuffer(ctx, params.TextDocument.URI)
+	s.bufferSync.Unlock()
+	s.diagnosticsQueue.focus(params.TextDocument.URI)
+	s.scheduleDiagnostics(params.TextDocument.URI)
+}
+
+// DidChangeTextDocumentParams mirrors protocol.DidChangeTextDocumentParams with an optional
+// change range, which go.lsp.dev/protocol v0.12.0 can't tell apart from an edit at the start
+// of the file
+type DidChangeTextDocumentParams struct {
+	TextDocument   protocol.VersionedTextDocumentIdentifier `json:"textDocument"`
+	ContentChanges []TextDocumentContentChangeEvent         `json:"contentChanges"`
+}
+
+// TextDocumentContentChangeEvent replaces Range with Text, or the whole document without a range
+type TextDocumentContentChangeEvent struct {
+	Range *protocol.Range `json:"range,omitempty"`
+	Text  string          `json:"text"`
+}
+
+func (s *Server) handleDidChange(ctx context.Context, params *DidChangeTextDocumentParams) {
+	uri := params.TextDocument.URI
+	// A backend joining meanwhile gets either the text before the changes, and then the
+	// changes, or the text after them
+	s.bufferSync.Lock()
+	doc, ok := s.documents.Get(uri)
+	if !ok || !doc.Open {
+		s.openUnannounced(ctx, uri, params.TextDocument.Version)
+		doc, _ = s.documents.Get(uri)
+	}
+
+	// Changes apply in order, each to the text the previous one left
+	text := doc.Text
+	for _, change := range params.ContentChanges {
+		text = applyContentChange(text, change)
+	}
+	s.documents.Update(uri, params.TextDocument.Version, text)
+	s.resolvedHints.forget(uri)
+	if s.currentConfig().Completion.RecentlyUsed {
+		s.recentCompletions.observe(uri, text, params.ContentChanges)
+	}
+
+	switch {
+	case isDisabledText(text):
+		s.diagnostics.forget(ctx, s.client, uri)
+	case isDisabledText(doc.Text):
+		// OmniSharp's copy stopped following the document when it was disabled
+		s.syncBuffer(ctx, uri)
+	default:
+		s.syncChanges(ctx, uri, params.ContentChanges)
+	}
+	s.bufferSync.Unlock()
+	s.diagnosticsQueue.focus(uri)
+	s.scheduleDiagnostics(uri)
+}
+
+func applyContentChange(text string, change TextDocumentContentChangeEvent) string {
+	if change.Range == nil {
+		return change.Text
+	}
+	start, end := offsetAt(text, change.Range.Start), offsetAt(text, change.Range.End)
+	if end < start {
+		start, end = end, start
+	}
+	return text[:start] + change.Text + text[end:]
+}
+
+// openUnannounced tracks a document changed without a didOpen, as buggy clients or restored
+// sessions send. Its base, which incremental changes apply to, is our cached copy or else the
+// file on disk
+func (s *Server) openUnannounced(ctx context.Context, uri protocol.DocumentURI, version int32) {
+	log.Printf("didChange for %s, which was never opened; tracking it from now on", uri)
+
+	var text string
+	if doc, ok := s.documents.Get(uri); ok {
+		text = doc.Text
+	} else if content, err := os.ReadFile(uri.Filename()); err == nil {
+		text = string(content)
+	} else {
+		log.Printf("failed to read %s: %v", uri, err)
+	}
+	s.forgetDocuments(ctx, s.documents.Open(uri, version, text))
+}
+
+func (s *Server) handleDidClose(ctx context.Context, params *protocol.DidCloseTextDocumentParams) {
+	// Closing cancels the document's context, which stops any diagnostics pass still running
+	evicted := s.documents.Close(params.TextDocument.URI)
+	s.diagnostics.clear(ctx, s.client, params.TextDocument.URI)
+	s.cache.forget(params.TextDocument.URI)
+	s.resolvedHints.forget(params.TextDocument.URI)
+	s.forgetDocuments(ctx, evicted)
+}
+
+// forgetDocuments clears what we published and cached for documents evicted from the store
+func (s *Server) forgetDocuments(ctx context.Context, uris []protocol.DocumentURI) {
+	for _, uri := range uris {
+		s.diagnostics.forget(ctx, s.client, uri)
+		s.cache.forget(uri)
+		s.resolvedHints.forget(uri)
+	}
+}
+
+// syncBuffer pushes our copy of the document to every OmniSharp so they don't read stale
+// contents from disk. Disabled documents aren't sent at all. Callers hold bufferSync
+func (s *Server) syncBuffer(ctx context.Context, uri protocol.DocumentURI) {
+	backends := s.bufferBackends()
+	if len(backends) == 0 {
+		return
+	}
+
+	doc, ok := s.documents.Get(uri)
+	if !ok || isDisabledText(doc.Text) {
+		return
+	}
+
+	s.sendBuffer(ctx, backends, doc)
+}
+
+// sendBuffer pushes doc whole to backends. Buffers are only ever sent through it, but for
+// syncChanges falling back on a failed patch, so it also invalidates results cached for doc
+func (s *Server) sendBuffer(ctx context.Context, backends []*OmniSharpClient, doc Document) {
+	// Even a failed update may have reached OmniSharp
+	s.bumpGeneration(doc)
+	for _, omnisharp := range backends {
+		pushBuffer(ctx, omnisharp, doc)
+	}
+}
+
+// bumpGeneration records that doc is about to reach OmniSharp, invalidating what was computed
+// against its previous copy
+func (s *Server) bumpGeneration(doc Document) {
+	s.cache.bump(doc.URI)
+	s.completionSession.advance(doc.URI, s.cache.generation(doc.URI), doc.Text)
+}
+
+// maxIncrementalChanges bounds the changes forwarded to OmniSharp one by one. Larger batches,
+// such as multi-cursor edits, are sent as the whole buffer instead
+const maxIncrementalChanges = 16
+
+// syncChanges forwards the changes just applied to uri to OmniSharp, which patches its copy
+// rather than receiving the whole buffer. Whole-document changes and large batches fall back
+// to syncBuffer, as does a backend that fails to take the changes
+func (s *Server) syncChanges(ctx context.Context, uri protocol.DocumentURI, changes []TextDocumentContentChangeEvent) {
+	if len(changes) == 0 || len(changes) > maxIncrementalChanges {
+		s.syncBuffer(ctx, uri)
+		return
+	}
+	spans := make([]LinePositionSpanTextChange, len(changes))
+	for i, change := range changes {
+		if change.Range == nil {
+			s.syncBuffer(ctx, uri)
+			return
+		}
+		spans[i] = LinePositionSpanTextChange{
+			NewText:     change.Text,
+			StartLine:   change.Range.Start.Line,
+			StartColumn: change.Range.Start.Character,
+			EndLine:     change.Range.End.Line,
+			EndColumn:   change.Range.End.Character,
+		}
+	}
+
+	backends := s.bufferBackends()
+	if len(backends) == 0 {
+		return
+	}
+	doc, ok := s.documents.Get(uri)
+	if !ok {
+		return
+	}
+
+	s.bumpGeneration(doc)
+	for _, omnisharp := range backends {
+		_, err := omnisharp.SendRequest(ctx, "/updatebuffer", map[string]interface{}{
+			"FileName": doc.URI.Filename(),
+			"Changes":  spans,
+			// Each change applies to the result of the previous one, as in LSP
+			"ApplyChangesTogether": false,
+		})
+		if err != nil {
+			log.Printf("failed to send changes to OmniSharp, sending all of %s: %v", uri, err)
+			pushBuffer(ctx, omnisharp, doc)
+		}
+	}
+}
+
+func pushBuffer(ctx context.Context, omnisharp *OmniSharpClient, doc Document) {
+	_, err := omnisharp.SendRequest(ctx, "/updatebuffer", map[string]interface{}{
+		"FileName": doc.URI.Filename(),
+		"Buffer":   doc.Text,
+	})
+	if err != nil {
+		log.Printf("failed to sync %s with OmniSharp: %v", doc.URI, err)
+	}
+}
+
+// resyncDocuments pushes every open document to OmniSharp, e.g. once it has finished starting
+func (s *Server) resyncDocuments(ctx context.Context) {
+	for _, doc := range s.documents.OpenDocuments() {
+		s.bufferSync.Lock()
+		s.syncBuffer(ctx, doc.URI)
+		s.bufferSync.Unlock()
+		s.scheduleDiagnostics(doc.URI)
+	}
+}
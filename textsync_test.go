@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func changeAt(startLine, startCharacter, endLine, endCharacter uint32, text string) TextDocumentContentChangeEvent {
+	return TextDocumentContentChangeEvent{
+		Range: &protocol.Range{
+			Start: protocol.Position{Line: startLine, Character: startCharacter},
+			End:   protocol.Position{Line: endLine, Character: endCharacter},
+		},
+		Text: text,
+	}
+}
+
+func TestDidChangeAppliesChangesInOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		changes []TextDocumentContentChangeEvent
+		want    string
+	}{
+		{
+			"second range within the text the first inserted",
+			"int a;\n",
+			[]TextDocumentContentChangeEvent{changeAt(0, 4, 0, 5, "count"), changeAt(0, 9, 0, 9, " = 1")},
+			"int count = 1;\n",
+		},
+		{
+			"first change adds a line the second edits",
+			"class A\n{\n}",
+			[]TextDocumentContentChangeEvent{changeAt(1, 1, 1, 1, "\n    void M() { }"), changeAt(2, 9, 2, 10, "Start")},
+			"class A\n{\n    void Start() { }\n}",
+		},
+		{
+			"multi-cursor edits from the end",
+			"a\nb\nc",
+			[]TextDocumentContentChangeEvent{changeAt(2, 0, 2, 0, "// "), changeAt(1, 0, 1, 0, "// "), changeAt(0, 0, 0, 0, "// ")},
+			"// a\n// b\n// c",
+		},
+		{
+			"deletion shifts the next change",
+			"var x = 1; var y = 2;",
+			[]TextDocumentContentChangeEvent{changeAt(0, 0, 0, 11, ""), changeAt(0, 4, 0, 5, "z")},
+			"var z = 2;",
+		},
+		{
+			"whole document then a range in it",
+			"old",
+			[]TextDocumentContentChangeEvent{{Text: "new text"}, changeAt(0, 0, 0, 3, "old")},
+			"old text",
+		},
+		{
+			"UTF-16 columns after an inserted emoji",
+			"s = \"\";",
+			[]TextDocumentContentChangeEvent{changeAt(0, 5, 0, 5, "😀"), changeAt(0, 7, 0, 7, "!")},
+			"s = \"😀!\";",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, nil)
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "A.cs")
+			openTestDocument(s, uri, tt.text)
+
+			s.handleDidChange(context.Background(), &DidChangeTextDocumentParams{
+				TextDocument:   protocol.VersionedTextDocumentIdentifier{TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri}, Version: 2},
+				ContentChanges: tt.changes,
+			})
+
+			doc, _ := s.documents.Get(uri)
+			if doc.Text != tt.want {
+				t.Errorf("buffer = %q, want %q", doc.Text, tt.want)
+			}
+			if doc.Version != 2 {
+				t.Errorf("version = %d, want 2", doc.Version)
+			}
+			// OmniSharp applies the changes one after another too
+			fake.mu.Lock()
+			updates := fake.bodies["/updatebuffer"]
+			fake.mu.Unlock()
+			if len(updates) == 0 {
+				t.Fatal("no /updatebuffer sent")
+			}
+			var update struct {
+				Changes              []LinePositionSpanTextChange `json:"Changes"`
+				ApplyChangesTogether bool                         `json:"ApplyChangesTogether"`
+			}
+			if err := json.Unmarshal(updates[len(updates)-1], &update); err != nil {
+				t.Fatal(err)
+			}
+			if update.Changes != nil && (update.ApplyChangesTogether || len(update.Changes) != len(tt.changes)) {
+				t.Errorf("/updatebuffer sent %d changes together=%v, want %d in order", len(update.Changes), update.ApplyChangesTogether, len(tt.changes))
+			}
+		})
+	}
+}
+
+// TestDidChangeWithoutDidOpen checks a document changed before it was opened is tracked from
+// the text the client's changes were made against
+func TestDidChangeWithoutDidOpen(t *testing.T) {
+	tests := []struct {
+		name string
+		// disk is the file on disk, none if empty
+		disk string
+		// setup puts a copy of the document in the store beforehand
+		setup   func(s *Server, uri protocol.DocumentURI)
+		changes []TextDocumentContentChangeEvent
+		want    string
+	}{
+		{
+			name:    "change to the file on disk",
+			disk:    "class Player { }\n",
+			changes: []TextDocumentContentChangeEvent{changeAt(0, 15, 0, 15, "int speed; ")},
+			want:    "class Player { int speed; }\n",
+		},
+		{
+			name:    "whole document",
+			disk:    "class Player { }\n",
+			changes: []TextDocumentContentChangeEvent{{Text: "class Enemy { }\n"}},
+			want:    "class Enemy { }\n",
+		},
+		{
+			name:    "no file on disk",
+			changes: []TextDocumentContentChangeEvent{{Text: "class Player { }\n"}},
+			want:    "class Player { }\n",
+		},
+		{
+			name: "warmed copy over the disk",
+			disk: "class Player { }\n",
+			setup: func(s *Server, uri protocol.DocumentURI) {
+				s.documents.Warm(uri, "class Player { void Start() { } }\n")
+			},
+			changes: []TextDocumentContentChangeEvent{changeAt(0, 20, 0, 25, "Awake")},
+			want:    "class Player { void Awake() { } }\n",
+		},
+		{
+			name: "closed copy over the disk",
+			disk: "class Player { }\n",
+			setup: func(s *Server, uri protocol.DocumentURI) {
+				openTestDocument(s, uri, "class Player { int health; }\n")
+				s.documents.Close(uri)
+			},
+			changes: []TextDocumentContentChangeEvent{changeAt(0, 19, 0, 25, "lives")},
+			want:    "class Player { int lives; }\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, nil)
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "Player.cs")
+			if tt.disk != "" {
+				if err := os.WriteFile(uri.Filename(), []byte(tt.disk), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.setup != nil {
+				tt.setup(s, uri)
+			}
+
+			s.handleDidChange(context.Background(), &DidChangeTextDocumentParams{
+				TextDocument:   protocol.VersionedTextDocumentIdentifier{TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri}, Version: 3},
+				ContentChanges: tt.changes,
+			})
+
+			doc, ok := s.documents.Get(uri)
+			if !ok || !doc.Open {
+				t.Fatalf("document tracked = %v, open = %v, want it open", ok, doc.Open)
+			}
+			if doc.Text != tt.want {
+				t.Errorf("buffer = %q, want %q", doc.Text, tt.want)
+			}
+			if doc.Version != 3 {
+				t.Errorf("version = %d, want 3", doc.Version)
+			}
+		})
+	}
+}
+
+// TestDidChangeForwardsIncrementalChanges checks OmniSharp gets the changes of a didChange as
+// they were made, and the whole buffer when they are too many or replace the document
+func TestDidChangeForwardsIncrementalChanges(t *testing.T) {
+	many := make([]TextDocumentContentChangeEvent, maxIncrementalChanges+1)
+	for i := range many {
+		many[i] = changeAt(0, 0, 0, 0, " ")
+	}
+	tests := []struct {
+		name        string
+		changes     []TextDocumentContentChangeEvent
+		wantChanges []LinePositionSpanTextChange
+	}{
+		{
+			name:    "one range",
+			changes: []TextDocumentContentChangeEvent{changeAt(0, 4, 0, 5, "count")},
+			wantChanges: []LinePositionSpanTextChange{
+				{NewText: "count", StartLine: 0, StartColumn: 4, EndLine: 0, EndColumn: 5},
+			},
+		},
+		{
+			name:    "ranges across lines",
+			changes: []TextDocumentContentChangeEvent{changeAt(0, 6, 1, 0, ""), changeAt(0, 0, 0, 0, "// ")},
+			wantChanges: []LinePositionSpanTextChange{
+				{NewText: "", StartLine: 0, StartColumn: 6, EndLine: 1, EndColumn: 0},
+				{NewText: "// ", StartLine: 0, StartColumn: 0, EndLine: 0, EndColumn: 0},
+			},
+		},
+		{name: "whole document", changes: []TextDocumentContentChangeEvent{{Text: "int b;\n"}}},
+		{name: "whole document among ranges", changes: []TextDocumentContentChangeEvent{changeAt(0, 4, 0, 5, "b"), {Text: "int c;\n"}}},
+		{name: "too many changes", changes: many},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, nil)
+			s, _ := newTestServer(t, fake)
+			uri := testURI(s, "A.cs")
+			openTestDocument(s, uri, "int a;\nint b;\n")
+
+			s.handleDidChange(context.Background(), &DidChangeTextDocumentParams{
+				TextDocument:   protocol.VersionedTextDocumentIdentifier{TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri}, Version: 2},
+				ContentChanges: tt.changes,
+			})
+
+			fake.mu.Lock()
+			updates := fake.bodies["/updatebuffer"]
+			fake.mu.Unlock()
+			// The first update is didOpen's
+			if len(updates) != 2 {
+				t.Fatalf("sent %d buffer updates, want one for the change", len(updates)-1)
+			}
+			var update struct {
+				Buffer  *string
+				Changes []LinePositionSpanTextChange
+			}
+			if err := json.Unmarshal(updates[1], &update); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantChanges == nil {
+				doc, _ := s.documents.Get(uri)
+				if update.Changes != nil || update.Buffer == nil || *update.Buffer != doc.Text {
+					t.Errorf("/updatebuffer = %s, want the whole buffer %q", updates[1], doc.Text)
+				}
+				return
+			}
+			if update.Buffer != nil || !reflect.DeepEqual(update.Changes, tt.wantChanges) {
+				t.Errorf("/updatebuffer = %s, want changes %+v", updates[1], tt.wantChanges)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// tokenClass is the kind of code a position of a buffer falls in
+type tokenClass int
+
+const (
+	tokenCode tokenClass = iota
+	tokenComment
+	// tokenString covers string and character literals, but not the holes of interpolated strings
+	tokenString
+	tokenNumber
+)
+
+// tokenClassAt classifies the byte at offset of text, scanning from the start of the file since
+// comments and strings can span lines
+func tokenClassAt(text string, offset int) tokenClass {
+	scanner := tokenScanner{text: text, offset: offset}
+	scanner.scanCode(0, false)
+	return scanner.class
+}
+
+// interpolationHoleAt returns where the innermost interpolation hole holding the byte at
+// offset starts, just past its opening brace, if there is one
+func interpolationHoleAt(text string, offset int) (int, bool) {
+	scanner := tokenScanner{text: text, offset: offset}
+	scanner.scanCode(0, false)
+	return scanner.hole, scanner.inHole && scanner.class == tokenCode
+}
+
+// maskCommentsAndStrings returns text with its comments and string and character literals
+// blanked out, line breaks kept, so patterns run over it match code only, at the offsets of text
+func maskCommentsAndStrings(text string) string {
+	// An offset past the end is never found, so the whole text is scanned
+	scanner := tokenScanner{text: text, offset: len(text), masked: []byte(text)}
+	scanner.scanCode(0, false)
+	return string(scanner.masked)
+}
+
+// tokenScanner walks text until it finds the token holding offset
+type tokenScanner struct {
+	text   string
+	offset int
+	class  tokenClass
+	found  bool
+	// hole is where the innermost interpolation hole holding offset starts, if inHole
+	hole   int
+	inHole bool
+	// masked, if set, is a copy of text which comments and strings are blanked out of
+	masked []byte
+}
+
+// mark records class if offset lies within [start, end)
+func (s *tokenScanner) mark(start, end int, class tokenClass) {
+	if s.masked != nil && (class == tokenComment || class == tokenString) {
+		for i := start; i < end; i++ {
+			if s.masked[i] != '\n' {
+				s.masked[i] = ' '
+			}
+		}
+	}
+	if !s.found && s.offset >= start && s.offset < end {
+		s.class, s.found = class, true
+	}
+}
+
+// scanCode scans code from i. Inside an interpolation hole it stops at the brace closing the
+// hole, returning its offset; otherwise it returns the end of text
+func (s *tokenScanner) scanCode(i int, hole bool) int {
+	text := s.text
+	depth := 0
+	for i < len(text) && !s.found {
+		if i > s.offset {
+			// The offset fell between tokens, or in one that is plain code
+			s.found = true
+			return i
+		}
+		rest := text[i:]
+		switch c := text[i]; {
+		case c == '{':
+			depth++
+			i++
+		case c == '}':
+			if hole && depth == 0 {
+				return i
+			}
+			depth--
+			i++
+		case strings.HasPrefix(rest, "//"):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			s.mark(i, i+end, tokenComment)
+			i += end
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += 4
+			}
+			s.mark(i, i+end, tokenComment)
+			i += end
+		case stringPrefix(rest) >= 0:
+			i = s.scanString(i)
+		case c == '\'':
+			end := i + 1
+			for end < len(text) && text[end] != '\'' && text[end] != '\n' {
+				if text[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(text))
+			s.mark(i, end, tokenString)
+			i = end
+		case c >= '0' && c <= '9':
+			end := numberEnd(text, i)
+			s.mark(i, end, tokenNumber)
+			i = end
+		case c == '_' || c >= utf8.RuneSelf || 'a' <= c|0x20 && c|0x20 <= 'z':
+			// Whole identifiers, so their digits aren't taken for numbers
+			for i < len(text) {
+				r, size := utf8.DecodeRuneInString(text[i:])
+				if !isIdentifierRune(r) {
+					break
+				}
+				i += size
+			}
+		default:
+			i++
+		}
+	}
+	return i
+}
+
+// stringPrefix returns the length of the $ and @ prefix of a string literal starting rest, or
+// -1 if rest doesn't start a string literal
+func stringPrefix(rest string) int {
+	prefix := len(rest) - len(strings.TrimLeft(rest, "$@"))
+	if !strings.HasPrefix(rest[prefix:], `"`) {
+		return -1
+	}
+	return prefix
+}
+
+// scanString scans the string literal starting at i, descending into its interpolation holes,
+// and returns the offset past it
+func (s *tokenScanner) scanString(i int) int {
+	text := s.text
+	prefix := stringPrefix(text[i:])
+	dollars := strings.Count(text[i:i+prefix], "$")
+	verbatim := strings.Contains(text[i:i+prefix], "@")
+
+	j := i + prefix
+	quotes := len(text[j:]) - len(strings.TrimLeft(text[j:], `"`))
+	raw := quotes >= 3
+	if !raw {
+		quotes = 1
+	}
+	j += quotes
+
+	// braces is how many braces open or close a hole: one, except in raw strings with $$ or more
+	braces := max(dollars, 1)
+	segment := i
+	for j < len(text) && !s.found {
+		rest := text[j:]
+		switch {
+		case raw && strings.HasPrefix(rest, strings.Repeat(`"`, quotes)):
+			s.mark(segment, j+quotes, tokenString)
+			return j + quotes
+		case !raw && rest[0] == '"':
+			if verbatim && strings.HasPrefix(rest, `""`) {
+				j += 2
+				continue
+			}
+			s.mark(segment, j+1, tokenString)
+			return j + 1
+		case !raw && !verbatim && rest[0] == '\n':
+			// Regular strings end on their line, even unterminated
+			s.mark(segment, j, tokenString)
+			return j
+		case !raw && !verbatim && rest[0] == '\\':
+			j += 2
+		case dollars > 0 && rest[0] == '{':
+			run := len(rest) - len(strings.TrimLeft(rest, "{"))
+			if !raw && run >= 2 {
+				// {{ is an escaped brace
+				j += 2
+				continue
+			}
+			if run < braces {
+				j += run
+				continue
+			}
+			// Extra braces before the hole are content
+			open := j + run
+			s.mark(segment, open, tokenString)
+			j = s.scanCode(open, true)
+			if s.found && !s.inHole {
+				s.hole, s.inHole = open, true
+			}
+			segment = j
+			j += braces
+		default:
+			j++
+		}
+	}
+	s.mark(segment, len(text), tokenString)
+	return min(j, len(text))
+}
+
+// numberEnd returns the end of the numeric literal starting at i, including its suffix and
+// exponent, e.g. 0x1F, 1_000, 2.5e-3f
+func numberEnd(text string, i int) int {
+	end := i
+	hex := i+1 < len(text) && text[i] == '0' && text[i+1]|0x20 == 'x'
+	for end < len(text) {
+		c := text[end]
+		switch {
+		case c == '_' || c >= '0' && c <= '9' || 'a' <= c|0x20 && c|0x20 <= 'z':
+			end++
+		case c == '.' && end+1 < len(text) && text[end+1] >= '0' && text[end+1] <= '9':
+			end++
+		case (c == '+' || c == '-') && !hex && end > i && text[end-1]|0x20 == 'e':
+			end++
+		default:
+			return end
+		}
+	}
+	return end
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenClassAt(t *testing.T) {
+	tests := []struct {
+		name string
+		// text has | before the byte classified
+		text string
+		want tokenClass
+	}{
+		{"identifier", "float |speed;", tokenCode},
+		{"line comment", "// the |speed\nfloat speed;", tokenComment},
+		{"after a line comment", "// speed\n|float speed;", tokenCode},
+		{"block comment across lines", "/* the\n |speed */ float speed;", tokenComment},
+		{"after a block comment", "/* speed */ |float speed;", tokenCode},
+		{"string", `tag = "|Enemy";`, tokenString},
+		{"escaped quote", `tag = "a\" |Enemy";`, tokenString},
+		{"after a string", `tag = "Enemy"; |speed`, tokenCode},
+		{"character", `c = '|x';`, tokenString},
+		{"verbatim string across lines", "path = @\"C:\\\n|Assets\";", tokenString},
+		{"verbatim doubled quote", `s = @"say ""|hi""";`, tokenString},
+		{"raw string", `s = """a "quoted" |word""";`, tokenString},
+		{"interpolation hole", `s = $"speed {|speed}";`, tokenCode},
+		{"interpolation text", `s = $"|speed {speed}";`, tokenString},
+		{"after an interpolation hole", `s = $"{speed} |units";`, tokenString},
+		{"string in an interpolation hole", `s = $"{Name("|x")}";`, tokenString},
+		{"escaped brace", `s = $"{{|speed}}";`, tokenString},
+		{"raw interpolation hole", `s = $$"""{{|speed}}""";`, tokenCode},
+		{"raw single brace", `s = $$"""{|speed}""";`, tokenString},
+		{"number", "x = 1|00;", tokenNumber},
+		{"number suffix", "x = 2.5|f;", tokenNumber},
+		{"hex number", "x = 0x|1F;", tokenNumber},
+		{"digits of an identifier", "vector|3 = v;", tokenCode},
+		{"unterminated string ends at the line", "s = \"open\n|speed", tokenCode},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			offset := strings.Index(test.text, "|")
+			text := strings.Replace(test.text, "|", "", 1)
+			if got := tokenClassAt(text, offset); got != test.want {
+				t.Errorf("tokenClassAt = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMaskCommentsAndStrings(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"line comment", "a // b\nc", "a     \nc"},
+		{"block comment", "a /* b\nc */ d", "a     \n     d"},
+		{"string", `a = "b // c";`, `a =         ;`},
+		{"verbatim string", "a = @\"b\nc\";", "a =    \n  ;"},
+		{"character", `a = '"';`, `a =    ;`},
+		{"interpolation hole", `a = $"b {c} d";`, `a =      c    ;`},
+		{"non-ASCII", `a = "é"; b`, `a =     ; b`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := maskCommentsAndStrings(test.text); got != test.want {
+				t.Errorf("maskCommentsAndStrings(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+)
+
+// tracer reports handled messages to the client through $/logTrace, at the level set by
+// InitializeParams.Trace and later $/setTrace notifications
+type tracer struct {
+	conn jsonrpc2.Conn
+
+	mu    sync.Mutex
+	level protocol.TraceValue
+}
+
+func newTracer(conn jsonrpc2.Conn) *tracer {
+	return &tracer{conn: conn, level: protocol.TraceOff}
+}
+
+func (t *tracer) setLevel(level protocol.TraceValue) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch level {
+	case protocol.TraceMessage, protocol.TraceVerbose:
+		t.level = level
+	default:
+		t.level = protocol.TraceOff
+	}
+}
+
+func (t *tracer) getLevel() protocol.TraceValue {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.level
+}
+
+// wrap traces the receipt of req and returns a replier that traces the response
+func (t *tracer) wrap(ctx context.Context, req jsonrpc2.Request, reply jsonrpc2.Replier) jsonrpc2.Replier {
+	_, isCall := req.(*jsonrpc2.Call)
+
+	if level := t.getLevel(); level != protocol.TraceOff {
+		kind := "notification"
+		if isCall {
+			kind = "request"
+		}
+		verbose := ""
+		if level == protocol.TraceVerbose {
+			verbose = "Params: " + string(req.Params())
+		}
+		t.log(ctx, fmt.Sprintf("Received %s '%s'", kind, describe(req)), verbose)
+	}
+
+	if !isCall {
+		return reply
+	}
+
+	start := time.Now()
+	return func(ctx context.Context, result interface{}, err error) error {
+		// Checked again at reply time since initialize itself sets the level
+		if level := t.getLevel(); level != protocol.TraceOff {
+			message := fmt.Sprintf("Sending response '%s'. Processing request took %dms", describe(req), time.Since(start).Milliseconds())
+			verbose := ""
+			if level == protocol.TraceVerbose {
+				verbose = traceResult(result, err)
+			}
+			t.log(ctx, message, verbose)
+		}
+		return reply(ctx, result, err)
+	}
+}
+
+func (t *tracer) log(ctx context.Context, message, verbose string) {
+	t.conn.Notify(ctx, protocol.MethodLogTrace, &protocol.LogTraceParams{
+		Message: message,
+		Verbose: protocol.TraceValue(verbose),
+	})
+}
+
+func traceResult(result interface{}, err error) string {
+	if err != nil {
+		return "Error: " + err.Error()
+	}
+	data, marshalErr := json.Marshal(result)
+	if marshalErr != nil {
+		return "Result: <unserializable>"
+	}
+	return "Result: " + string(data)
+}
+
+func describe(req jsonrpc2.Request) string {
+	if call, ok := req.(*jsonrpc2.Call); ok {
+		return fmt.Sprintf("%s - (%v)", req.Method(), call.ID())
+	}
+	return req.Method()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestInitializeTraceLevel(t *testing.T) {
+	tests := []struct {
+		trace       protocol.TraceValue
+		wantTraced  bool
+		wantVerbose bool
+	}{
+		{protocol.TraceOff, false, false},
+		{"", false, false},
+		{protocol.TraceMessage, true, false},
+		{protocol.TraceVerbose, true, true},
+	}
+	for _, test := range tests {
+		t.Run(string(test.trace), func(t *testing.T) {
+			s, client := newTestServer(t, nil)
+			s.initialized = false
+			if _, err := call(t, s, 1, protocol.MethodInitialize, protocol.InitializeParams{
+				RootURI: pathToURI(s.rootPath),
+				Trace:   test.trace,
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := call(t, s, 2, methodStatus, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			var received *protocol.LogTraceParams
+			traced := func() bool {
+				for _, raw := range client.received(protocol.MethodLogTrace) {
+					var params protocol.LogTraceParams
+					json.Unmarshal(raw, &params)
+					if strings.HasPrefix(params.Message, "Sending response '"+methodStatus) {
+						received = &params
+					}
+				}
+				return received != nil
+			}
+			if test.wantTraced {
+				waitFor(t, "the trace of the request after initialize", traced)
+			} else if traced() {
+				t.Fatalf("traced %q with trace %q", received.Message, test.trace)
+			}
+			if test.wantTraced && (received.Verbose != "") != test.wantVerbose {
+				t.Errorf("verbose = %q with trace %q", received.Verbose, test.trace)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// unityAttribute is an attribute offered when completing inside [...]
+type unityAttribute struct {
+	Name          string
+	Documentation string
+	// EditorOnly attributes live in UnityEditor and only compile in editor assemblies
+	EditorOnly bool
+	// ArgumentType and Arguments describe the enum accepted as the attribute's first argument
+	ArgumentType string
+	Arguments    []string
+	// Properties can be set by name in the argument list, as in [CreateAssetMenu(menuName = "...")]
+	Properties []string
+}
+
+// propertyAttributeProperties are settable on every PropertyAttribute, such as Header and Range
+var propertyAttributeProperties = []string{"order"}
+
+var unityAttributes = []unityAttribute{
+	{Name: "SerializeField", Documentation: "Force Unity to serialize a private field."},
+	{Name: "HideInInspector", Documentation: "Hide a serialized field in the Inspector."},
+	{Name: "Header", Documentation: "Add a header above fields in the Inspector.", Properties: propertyAttributeProperties},
+	{Name: "Tooltip", Documentation: "Show a tooltip for a field in the Inspector.", Properties: propertyAttributeProperties},
+	{Name: "Range", Documentation: "Clamp a float or int field to a range in the Inspector.", Properties: propertyAttributeProperties},
+	{Name: "Space", Documentation: "Add spacing above a field in the Inspector.", Properties: propertyAttributeProperties},
+	{Name: "TextArea", Documentation: "Edit a string field with a multi-line text area.", Properties: propertyAttributeProperties},
+	{Name: "RequireComponent", Documentation: "Automatically add required components as dependencies."},
+	{Name: "DisallowMultipleComponent", Documentation: "Prevent the MonoBehaviour from being added more than once to a GameObject."},
+	{Name: "CreateAssetMenu", Documentation: "Add a ScriptableObject to the Assets/Create menu.", Properties: []string{"fileName", "menuName", "order"}},
+	{
+		Name:          "RuntimeInitializeOnLoadMethod",
+		Documentation: "Call a static method when the runtime has loaded, at the chosen load stage.",
+		ArgumentType:  "RuntimeInitializeLoadType",
+		Arguments: []string{
+			"AfterSceneLoad",
+			"BeforeSceneLoad",
+			"AfterAssembliesLoaded",
+			"BeforeSplashScreen",
+			"SubsystemRegistration",
+		},
+	},
+	{Name: "ExecuteAlways", Documentation: "Run the script's callbacks in Edit Mode and Play Mode."},
+	{Name: "ExecuteInEditMode", Documentation: "Run the script's callbacks in Edit Mode. Prefer ExecuteAlways for prefab mode support."},
+	{Name: "InitializeOnLoad", Documentation: "Run the class's static constructor when the editor loads or scripts recompile.", EditorOnly: true},
+	{Name: "InitializeOnLoadMethod", Documentation: "Call a static method when the editor loads or scripts recompile.", EditorOnly: true},
+	{Name: "MenuItem", Documentation: "Add a static method to the editor's main menu, e.g. [MenuItem(\"Tools/My Tool\")].", EditorOnly: true},
+}
+
+// Attribute lists start a line, possibly after other complete lists, which keeps indexers such
+// as items[i out of attribute completion
+const attributeListPrefix = `^\s*(?:\[[^\]]*\]\s*)*\[\s*(?:\w+\s*:\s*)?(?:[\w.]+(?:\([^)]*\))?\s*,\s*)*`
+
+var (
+	// attributeNamePattern matches a caret where an attribute name starts
+	attributeNamePattern = regexp.MustCompile(attributeListPrefix + `\w*$`)
+	// attributeArgumentPattern matches a caret at the first argument of an attribute
+	attributeArgumentPattern = regexp.MustCompile(attributeListPrefix + `(\w+)\s*\(\s*(?:(\w+)\.)?\w*$`)
+	// attributeArgumentsPattern matches a caret where any argument of an attribute starts,
+	// capturing the attribute and the arguments before the caret
+	attributeArgumentsPattern = regexp.MustCompile(attributeListPrefix + `(\w+)\s*\(((?:[^)]*,)?)\s*\w*$`)
+)
+
+// unityAttributeCompletions returns Unity attribute items for a caret inside an attribute list,
+// or enum values when the caret is at an attribute's first argument
+func unityAttributeCompletions(doc Document, pos protocol.Position, rootPath string) []CompletionItem {
+	line := lineAt(doc.Text, pos.Line)
+	prefix := line[:utf16ToByteOffset(line, pos.Character)]
+
+	if m := attributeArgumentsPattern.FindStringSubmatch(prefix); m != nil {
+		// Only a caret in an attribute list pays for looking up the assembly definition
+		editor := isEditorScoped(doc.URI.Filename(), rootPath)
+		items := attributePropertyCompletions(m[1], m[2], editor)
+		if m := attributeArgumentPattern.FindStringSubmatch(prefix); m != nil {
+			items = append(items, attributeEnumCompletions(m[1], m[2], editor)...)
+		}
+		return items
+	}
+
+	if !attributeNamePattern.MatchString(prefix) {
+		return nil
+	}
+	editor := isEditorScoped(doc.URI.Filename(), rootPath)
+
+	var items []CompletionItem
+	for _, attr := range unityAttributes {
+		if attr.EditorOnly && !editor {
+			continue
+		}
+		items = append(items, CompletionItem{
+			CompletionItem: protocol.CompletionItem{
+				Label:      attr.Name,
+				Kind:       protocol.CompletionItemKindClass,
+				Detail:     "Unity attribute",
+				InsertText: attr.Name,
+				// Documentation is attached on resolve
+				Data: &completionData{Source: completionSourceUnity, Name: attr.Name},
+			},
+			TextEditText: attr.Name,
+		})
+	}
+	return items
+}
+
+// attributeEnumCompletions offers the enum values accepted as the first argument of attribute,
+// given the enum type qualifier the user may have typed
+func attributeEnumCompletions(attribute, qualifier string, editor bool) []CompletionItem {
+	for _, attr := range unityAttributes {
+		if attr.Name != attribute || attr.ArgumentType == "" || (attr.EditorOnly && !editor) {
+			continue
+		}
+		if qualifier != "" && qualifier != attr.ArgumentType {
+			return nil
+		}
+		return enumArgumentCompletions(attr, qualifier != "")
+	}
+	return nil
+}
+
+// attributePropertyCompletions offers Name = for the properties of attribute not already set
+// in arguments, the argument list up to the caret. Constructor parameters are offered as
+// named arguments from OmniSharp's signatures
+func attributePropertyCompletions(attribute, arguments string, editor bool) []CompletionItem {
+	var items []CompletionItem
+	for _, attr := range unityAttributes {
+		if attr.Name != attribute || (attr.EditorOnly && !editor) {
+			continue
+		}
+		for _, property := range attr.Properties {
+			if isPropertyAssigned(arguments, property) {
+				continue
+			}
+			insert := property + " = "
+			items = append(items, CompletionItem{
+				CompletionItem: protocol.CompletionItem{
+					Label:      property + " =",
+					Kind:       protocol.CompletionItemKindProperty,
+					Detail:     "(named property) " + attr.Name + "." + property,
+					FilterText: property,
+					InsertText: insert,
+				},
+				TextEditText: insert,
+			})
+		}
+	}
+	return items
+}
+
+// isPropertyAssigned reports whether arguments already set property by name
+func isPropertyAssigned(arguments, property string) bool {
+	for _, argument := range strings.Split(arguments, ",") {
+		name, _, ok := strings.Cut(argument, "=")
+		if ok && strings.TrimSpace(name) == property {
+			return true
+		}
+	}
+	return false
+}
+
+// unityAttributeDocumentation returns the local documentation for a Unity attribute
+func unityAttributeDocumentation(name string) (string, bool) {
+	for _, attr := range unityAttributes {
+		if attr.Name == name {
+			return attr.Documentation, true
+		}
+	}
+	return "", false
+}
+
+// enumArgumentCompletions offers the enum values for an attribute argument, qualified with
+// the enum type unless the user already typed it
+func enumArgumentCompletions(attr unityAttribute, qualified bool) []CompletionItem {
+	items := make([]CompletionItem, 0, len(attr.Arguments))
+	for _, value := range attr.Arguments {
+		text := value
+		if !qualified {
+			text = attr.ArgumentType + "." + value
+		}
+		items = append(items, CompletionItem{
+			CompletionItem: protocol.CompletionItem{
+				Label:      text,
+				Kind:       protocol.CompletionItemKindEnumMember,
+				Detail:     attr.ArgumentType,
+				FilterText: text,
+				InsertText: text,
+			},
+			TextEditText: text,
+		})
+	}
+	return items
+}
+
+// isEditorScoped reports whether a script only compiles into the editor: it sits under an
+// Editor/ folder of the project or its nearest assembly definition only includes the Editor
+// platform. Folders above rootPath don't count, so a project checked out under an Editor folder
+// isn't all editor scripts
+func isEditorScoped(path, rootPath string) bool {
+	dir := filepath.Dir(path)
+	relative := dir
+	if rootPath != "" {
+		if rel, err := filepath.Rel(rootPath, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			relative = rel
+		}
+	}
+	for _, part := range strings.Split(filepath.ToSlash(relative), "/") {
+		if part == "Editor" {
+			return true
+		}
+	}
+
+	for {
+		if asmdef, ok := findAsmdef(dir); ok {
+			return isEditorAsmdef(asmdef)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir || dir == rootPath {
+			return false
+		}
+		dir = parent
+	}
+}
+
+func findAsmdef(dir string) (string, bool) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.asmdef"))
+	if err != nil || len(matches) == 0 {
+		return "", false
+	}
+	return matches[0], true
+}
+
+func isEditorAsmdef(path string) bool {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	var asmdef struct {
+		IncludePlatforms []string `json:"includePlatforms"`
+	}
+	if err := json.Unmarshal(data, &asmdef); err != nil {
+		return false
+	}
+	return len(asmdef.IncludePlatforms) == 1 && asmdef.IncludePlatforms[0] == "Editor"
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestIsEditorScoped(t *testing.T) {
+	base := t.TempDir()
+	// A project checked out under a folder named Editor
+	root := filepath.Join(base, "Editor", "Game")
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(root, "Assets", "Tools", "Tools.asmdef"), `{"name": "Tools", "includePlatforms": ["Editor"]}`)
+	write(filepath.Join(root, "Assets", "Runtime", "Runtime.asmdef"), `{"name": "Runtime", "includePlatforms": []}`)
+
+	tests := []struct {
+		name string
+		path string
+		want bool
+	}{
+		{"runtime script under an Editor checkout", filepath.Join(root, "Assets", "Scripts", "Player.cs"), false},
+		{"Editor folder in the project", filepath.Join(root, "Assets", "Editor", "PlayerEditor.cs"), true},
+		{"nested Editor folder", filepath.Join(root, "Assets", "Plugins", "Editor", "Inspector", "A.cs"), true},
+		{"editor-only assembly definition", filepath.Join(root, "Assets", "Tools", "Window.cs"), true},
+		{"runtime assembly definition", filepath.Join(root, "Assets", "Runtime", "Game.cs"), false},
+		{"folder merely named like Editor", filepath.Join(root, "Assets", "LevelEditor", "Level.cs"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isEditorScoped(tt.path, root); got != tt.want {
+				t.Errorf("isEditorScoped(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnityAttributeCompletionsEditorOnly(t *testing.T) {
+	root := t.TempDir()
+	tests := []struct {
+		name       string
+		path       string
+		text       string
+		wantEditor bool
+	}{
+		{"runtime script", filepath.Join(root, "Assets", "Player.cs"), "[", false},
+		{"editor script", filepath.Join(root, "Assets", "Editor", "Menu.cs"), "[", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := Document{URI: pathToURI(tt.path), Text: tt.text}
+			items := unityAttributeCompletions(doc, positionAt(tt.text, len(tt.text)), root)
+			found := false
+			for _, item := range items {
+				found = found || item.Label == "MenuItem"
+			}
+			if found != tt.wantEditor {
+				t.Errorf("MenuItem offered = %v, want %v", found, tt.wantEditor)
+			}
+		})
+	}
+}
+
+func TestUnityAttributePropertyCompletions(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"first argument", "[CreateAssetMenu(", []string{"fileName =", "menuName =", "order ="}},
+		{"property begun", "[CreateAssetMenu(men", []string{"fileName =", "menuName =", "order ="}},
+		{"one set", `[CreateAssetMenu(fileName = "Enemy", `, []string{"menuName =", "order ="}},
+		{"two set", `[CreateAssetMenu(menuName = "Game/Enemy", fileName = "Enemy", `, []string{"order ="}},
+		{"after a positional argument", `[Header("Stats", `, []string{"order ="}},
+		{"after two positional arguments", "[Range(0, 10, ", []string{"order ="}},
+		{"among other attributes", "[SerializeField, Tooltip(", []string{"order ="}},
+		{"attribute without properties", "[RequireComponent(", nil},
+		{"after the argument list", "[CreateAssetMenu()] ", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := Document{URI: "file:///project/Assets/EnemyData.cs", Text: tt.text}
+			var got []string
+			for _, item := range unityAttributeCompletions(doc, positionAt(tt.text, len(tt.text)), "/project") {
+				if item.Kind == protocol.CompletionItemKindProperty {
+					got = append(got, item.Label)
+					if want := strings.TrimSuffix(item.Label, " =") + " = "; item.InsertText != want || item.TextEditText != want {
+						t.Errorf("%s inserts %q, want %q", item.Label, item.InsertText, want)
+					}
+				}
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("properties = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
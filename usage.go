@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// usageSampleInterval is how often the usage of a managed OmniSharp is read from the OS. CPU
+// usage is averaged over it
+const usageSampleInterval = 10 * time.Second
+
+// ProcessUsage is the resource usage of an OmniSharp process as last sampled
+type ProcessUsage struct {
+	PID           int     `json:"pid"`
+	UptimeSeconds float64 `json:"uptimeSeconds"`
+	// MemoryBytes is the resident memory of the process
+	MemoryBytes uint64 `json:"memoryBytes"`
+	// CPUPercent is the CPU time used over the last sample interval, above 100 when several
+	// cores were busy
+	CPUPercent float64 `json:"cpuPercent"`
+}
+
+// usageSampler samples the usage of a process until it exits, so reporting it costs nothing
+type usageSampler struct {
+	pid     int
+	started time.Time
+
+	mu      sync.Mutex
+	sampled bool
+	memory  uint64
+	cpu     float64
+	// cpuTime and sampledAt are those of the last sample, which the next one's CPU is relative to
+	cpuTime   time.Duration
+	sampledAt time.Time
+}
+
+// sampleUsage samples the usage of pid every usageSampleInterval until exited is closed
+func sampleUsage(pid int, exited <-chan struct{}) *usageSampler {
+	now := time.Now()
+	sampler := &usageSampler{pid: pid, started: now, sampledAt: now}
+	go func() {
+		ticker := time.NewTicker(usageSampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-exited:
+				return
+			case <-ticker.C:
+				sampler.sample()
+			}
+		}
+	}()
+	return sampler
+}
+
+func (u *usageSampler) sample() {
+	memory, cpuTime, err := readProcessUsage(u.pid)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if elapsed := now.Sub(u.sampledAt); elapsed > 0 {
+		u.cpu = float64(cpuTime-u.cpuTime) / float64(elapsed) * 100
+	}
+	u.memory, u.cpuTime, u.sampledAt, u.sampled = memory, cpuTime, now, true
+}
+
+// usage returns the last sample, taking the first one now if the process is younger than an
+// interval. It reports false where the OS usage can't be read
+func (u *usageSampler) usage() (ProcessUsage, bool) {
+	u.mu.Lock()
+	sampled := u.sampled
+	u.mu.Unlock()
+	if !sampled {
+		u.sample()
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.sampled {
+		return ProcessUsage{}, false
+	}
+	return ProcessUsage{
+		PID:           u.pid,
+		UptimeSeconds: time.Since(u.started).Seconds(),
+		MemoryBytes:   u.memory,
+		CPUPercent:    u.cpu,
+	}, true
+}
+
+// readProcessUsage reads the resident memory and total CPU time of pid: from /proc on Linux,
+// from ps on other Unix systems
+func readProcessUsage(pid int) (uint64, time.Duration, error) {
+	switch runtime.GOOS {
+	case "linux":
+		return readProcUsage(pid)
+	case "windows":
+		return 0, 0, fmt.Errorf("process usage isn't supported on %s", runtime.GOOS)
+	default:
+		return readPSUsage(pid)
+	}
+}
+
+// clockTicks is the unit of the CPU times in /proc/<pid>/stat, 100 Hz on every Linux
+// architecture Go supports
+const clockTicks = 100
+
+func readProcUsage(pid int) (uint64, time.Duration, error) {
+	statm, err := os.ReadFile(fmt.Sprintf("/proc/%d/statm", pid))
+	if err != nil {
+		return 0, 0, err
+	}
+	fields := strings.Fields(string(statm))
+	if len(fields) < 2 {
+		return 0, 0, fmt.Errorf("unexpected /proc/%d/statm: %q", pid, statm)
+	}
+	pages, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, 0, err
+	}
+	// The command name in parentheses may hold spaces, so fields are counted after it: utime
+	// and stime are the 14th and 15th
+	end := bytes.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0, 0, fmt.Errorf("unexpected /proc/%d/stat", pid)
+	}
+	fields = strings.Fields(string(stat[end+1:]))
+	if len(fields) < 13 {
+		return 0, 0, fmt.Errorf("unexpected /proc/%d/stat", pid)
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return pages * uint64(os.Getpagesize()), time.Duration(utime+stime) * time.Second / clockTicks, nil
+}
+
+func readPSUsage(pid int) (uint64, time.Duration, error) {
+	output, err := exec.Command("ps", "-o", "rss=,time=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return 0, 0, err
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("unexpected ps output %q", output)
+	}
+	kilobytes, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	cpuTime, err := parsePSTime(fields[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return kilobytes * 1024, cpuTime, nil
+}
+
+// parsePSTime parses the CPU time column of ps, [dd-][hh:]mm:ss[.cc]
+func parsePSTime(value string) (time.Duration, error) {
+	var days int
+	if day, rest, ok := strings.Cut(value, "-"); ok {
+		var err error
+		if days, err = strconv.Atoi(day); err != nil {
+			return 0, err
+		}
+		value = rest
+	}
+
+	parts := strings.Split(value, ":")
+	seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil {
+		return 0, err
+	}
+	total := time.Duration(days)*24*time.Hour + time.Duration(seconds*float64(time.Second))
+	unit := time.Minute
+	for i := len(parts) - 2; i >= 0; i-- {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return 0, err
+		}
+		total += time.Duration(n) * unit
+		unit *= 60
+	}
+	return total, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestParsePSTime(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"00:05", 5 * time.Second, false},
+		{"01:02.50", time.Minute + 2500*time.Millisecond, false},
+		{"1:02:03", time.Hour + 2*time.Minute + 3*time.Second, false},
+		{"2-01:00:00", 49 * time.Hour, false},
+		{"x:05", 0, true},
+		{"", 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := parsePSTime(test.value)
+			if (err != nil) != test.wantErr || got != test.want {
+				t.Errorf("parsePSTime = %v, %v; want %v, error %v", got, err, test.want, test.wantErr)
+			}
+		})
+	}
+}
+
+// TestUsageSampler checks the usage of a running process is sampled on first asking, before
+// the ticker has sampled it
+func TestUsageSampler(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process usage isn't read on Windows")
+	}
+	exited := make(chan struct{})
+	defer close(exited)
+	sampler := sampleUsage(os.Getpid(), exited)
+	time.Sleep(10 * time.Millisecond)
+
+	usage, ok := sampler.usage()
+	if !ok {
+		t.Fatal("no usage sampled")
+	}
+	if usage.PID != os.Getpid() || usage.MemoryBytes == 0 || usage.UptimeSeconds <= 0 || usage.CPUPercent < 0 {
+		t.Errorf("usage = %+v, want that of this process", usage)
+	}
+}
+
+func TestUsageSamplerWithoutProcess(t *testing.T) {
+	exited := make(chan struct{})
+	defer close(exited)
+	// No process has a negative pid
+	if usage, ok := sampleUsage(-1, exited).usage(); ok {
+		t.Errorf("usage = %+v, want none", usage)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+const (
+	// placementAuto follows the file: inside the namespace if its usings are, else outside
+	placementAuto    = "auto"
+	placementOutside = "outsideNamespace"
+	placementInside  = "insideNamespace"
+)
+
+var (
+	usingDirective = regexp.MustCompile(`^\s*(?:global\s+)?using\s+(static\s+)?([A-Za-z_][\w.]*)\s*(=)?`)
+	namespaceLine  = regexp.MustCompile(`^\s*namespace\s+[\w.]+\s*(;)?`)
+)
+
+// usingLine is a using directive of the file header
+type usingLine struct {
+	line int
+	// namespace is the imported namespace, empty for static and alias directives, which sort apart
+	namespace string
+	inside    bool
+}
+
+// fileHeader is what precedes the first declaration of a file: its usings and namespace
+type fileHeader struct {
+	usings []usingLine
+	// namespace is the line declaring the namespace, or -1 without one
+	namespace  int
+	fileScoped bool
+	// body is the first line of code after the comments leading the file
+	body int
+}
+
+func parseFileHeader(lines []string) fileHeader {
+	header := fileHeader{namespace: -1, body: -1}
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if header.body < 0 && trimmed != "" && !isCommentLine(trimmed) {
+			header.body = i
+		}
+		switch {
+		case trimmed == "", isCommentLine(trimmed), strings.HasPrefix(trimmed, "#"), trimmed == "{":
+		case usingDirective.MatchString(line) && strings.HasSuffix(trimmed, ";"):
+			match := usingDirective.FindStringSubmatch(line)
+			using := usingLine{line: i, inside: header.namespace >= 0}
+			if match[1] == "" && match[3] == "" {
+				using.namespace = match[2]
+			}
+			header.usings = append(header.usings, using)
+		case namespaceLine.MatchString(line) && header.namespace < 0:
+			header.namespace = i
+			header.fileScoped = namespaceLine.FindStringSubmatch(line)[1] != ""
+		default:
+			// The first declaration ends the header
+			return header
+		}
+	}
+	return header
+}
+
+func isCommentLine(trimmed string) bool {
+	return strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "/*") || strings.HasPrefix(trimmed, "*")
+}
+
+// usingEdit inserts a using directive for namespace where the file's convention puts it,
+// reporting false if the file already imports it
+func usingEdit(text, namespace string, config UsingsConfig) (protocol.TextEdit, bool) {
+	lines := splitLines(text)
+	header := parseFileHeader(lines)
+	for _, using := range header.usings {
+		if using.namespace == namespace {
+			return protocol.TextEdit{}, false
+		}
+	}
+
+	inside := config.Placement == placementInside
+	if config.Placement == placementAuto && len(header.usings) > 0 {
+		inside = header.usings[0].inside
+	}
+	inside = inside && header.namespace >= 0
+
+	var group []usingLine
+	for _, using := range header.usings {
+		if using.inside == inside {
+			group = append(group, using)
+		}
+	}
+	directive := "using " + namespace + ";"
+	if len(group) > 0 {
+		line := insertionLine(group, namespace, config.SortSystemFirst)
+		anchor := lines[group[0].line]
+		indent := anchor[:len(anchor)-len(strings.TrimLeft(anchor, " \t"))]
+		return insertAtLine(line, indent+directive+"\n"), true
+	}
+
+	if !inside {
+		line := max(header.body, 0)
+		return insertAtLine(line, directive+"\n\n"), true
+	}
+	if header.fileScoped {
+		return insertAtLine(header.namespace+1, "\n"+directive+"\n"), true
+	}
+	// Usings go right after the brace opening the namespace, indented like its body
+	open := header.namespace
+	if !strings.Contains(lines[open], "{") && open+1 < len(lines) {
+		open++
+	}
+	indent := "    "
+	for _, line := range lines[open+1:] {
+		if strings.TrimSpace(line) != "" {
+			indent = line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			break
+		}
+	}
+	return insertAtLine(open+1, indent+directive+"\n\n"), true
+}
+
+// insertionLine is where namespace goes among group: in order if the group is sorted, else after it
+func insertionLine(group []usingLine, namespace string, systemFirst bool) int {
+	var plain []usingLine
+	for _, using := range group {
+		if using.namespace != "" {
+			plain = append(plain, using)
+		}
+	}
+	if len(plain) == 0 {
+		return group[0].line
+	}
+
+	for i := 1; i < len(plain); i++ {
+		if usingLess(plain[i].namespace, plain[i-1].namespace, systemFirst) {
+			return group[len(group)-1].line + 1
+		}
+	}
+	for _, using := range plain {
+		if usingLess(namespace, using.namespace, systemFirst) {
+			return using.line
+		}
+	}
+	return plain[len(plain)-1].line + 1
+}
+
+// usingLess orders namespaces alphabetically, optionally putting System and its children first
+func usingLess(a, b string, systemFirst bool) bool {
+	if systemFirst {
+		if sa, sb := isSystemNamespace(a), isSystemNamespace(b); sa != sb {
+			return sa
+		}
+	}
+	if la, lb := strings.ToLower(a), strings.ToLower(b); la != lb {
+		return la < lb
+	}
+	return a < b
+}
+
+func isSystemNamespace(namespace string) bool {
+	return namespace == "System" || strings.HasPrefix(namespace, "System.")
+}
+
+func insertAtLine(line int, text string) protocol.TextEdit {
+	position := protocol.Position{Line: uint32(line)}
+	return protocol.TextEdit{Range: protocol.Range{Start: position, End: position}, NewText: text}
+}
@@ -0,0 +1,119 @@
+package main
+
+import "testing"
+
+func TestUsingEdit(t *testing.T) {
+	systemFirst := UsingsConfig{Placement: placementAuto, SortSystemFirst: true}
+	alphabetical := UsingsConfig{Placement: placementAuto}
+	tests := []struct {
+		name      string
+		text      string
+		namespace string
+		config    UsingsConfig
+		// want is the text with the using inserted, empty if none should be
+		want string
+	}{
+		{
+			name:      "System namespace among System ones",
+			text:      "using System;\nusing System.Collections;\nusing UnityEngine;\n\nclass Player { }\n",
+			namespace: "System.Linq",
+			config:    systemFirst,
+			want:      "using System;\nusing System.Collections;\nusing System.Linq;\nusing UnityEngine;\n\nclass Player { }\n",
+		},
+		{
+			name:      "after the System ones",
+			text:      "using System;\nusing System.Collections;\nusing UnityEngine;\n\nclass Player { }\n",
+			namespace: "Cinemachine",
+			config:    systemFirst,
+			want:      "using System;\nusing System.Collections;\nusing Cinemachine;\nusing UnityEngine;\n\nclass Player { }\n",
+		},
+		{
+			name:      "alphabetical without System first",
+			text:      "using Cinemachine;\nusing System;\nusing UnityEngine;\n\nclass Player { }\n",
+			namespace: "TMPro",
+			config:    alphabetical,
+			want:      "using Cinemachine;\nusing System;\nusing TMPro;\nusing UnityEngine;\n\nclass Player { }\n",
+		},
+		{
+			name:      "unsorted block appended to",
+			text:      "using UnityEngine;\nusing System;\n\nclass Player { }\n",
+			namespace: "System.Linq",
+			config:    systemFirst,
+			want:      "using UnityEngine;\nusing System;\nusing System.Linq;\n\nclass Player { }\n",
+		},
+		{
+			name:      "already imported",
+			text:      "using UnityEngine;\n\nclass Player { }\n",
+			namespace: "UnityEngine",
+			config:    systemFirst,
+		},
+		{
+			name:      "inside the namespace like the file",
+			text:      "namespace Game\n{\n    using UnityEngine;\n\n    class Player { }\n}\n",
+			namespace: "System",
+			config:    systemFirst,
+			want:      "namespace Game\n{\n    using System;\n    using UnityEngine;\n\n    class Player { }\n}\n",
+		},
+		{
+			name:      "inside by configuration",
+			text:      "namespace Game\n{\n  class Player { }\n}\n",
+			namespace: "UnityEngine",
+			config:    UsingsConfig{Placement: placementInside},
+			want:      "namespace Game\n{\n  using UnityEngine;\n\n  class Player { }\n}\n",
+		},
+		{
+			name:      "inside a file-scoped namespace",
+			text:      "namespace Game;\n\nclass Player { }\n",
+			namespace: "UnityEngine",
+			config:    UsingsConfig{Placement: placementInside},
+			want:      "namespace Game;\n\nusing UnityEngine;\n\nclass Player { }\n",
+		},
+		{
+			name:      "inside without a namespace",
+			text:      "class Player { }\n",
+			namespace: "UnityEngine",
+			config:    UsingsConfig{Placement: placementInside},
+			want:      "using UnityEngine;\n\nclass Player { }\n",
+		},
+		{
+			name:      "outside by configuration",
+			text:      "namespace Game\n{\n    using UnityEngine;\n\n    class Player { }\n}\n",
+			namespace: "System",
+			config:    UsingsConfig{Placement: placementOutside},
+			want:      "using System;\n\nnamespace Game\n{\n    using UnityEngine;\n\n    class Player { }\n}\n",
+		},
+		{
+			name:      "after the leading comment",
+			text:      "// Copyright Game Studio\n\nclass Player { }\n",
+			namespace: "UnityEngine",
+			config:    systemFirst,
+			want:      "// Copyright Game Studio\n\nusing UnityEngine;\n\nclass Player { }\n",
+		},
+		{
+			name:      "static and alias usings kept apart",
+			text:      "using static UnityEngine.Mathf;\nusing Random = UnityEngine.Random;\n\nclass Player { }\n",
+			namespace: "System",
+			config:    systemFirst,
+			want:      "using System;\nusing static UnityEngine.Mathf;\nusing Random = UnityEngine.Random;\n\nclass Player { }\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			edit, ok := usingEdit(test.text, test.namespace, test.config)
+			if test.want == "" {
+				if ok {
+					t.Errorf("inserted %q, want nothing", edit.NewText)
+				}
+				return
+			}
+			if !ok {
+				t.Fatal("inserted nothing")
+			}
+			at := edit.Range
+			got := applyContentChange(test.text, TextDocumentContentChangeEvent{Range: &at, Text: edit.NewText})
+			if got != test.want {
+				t.Errorf("text = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// omnisharpVersion is a release of OmniSharp, such as 1.39.11. The zero value is a release we
+// couldn't tell, taken to have every endpoint
+type omnisharpVersion struct {
+	major, minor, patch int
+}
+
+var (
+	// minOmniSharpVersion is the oldest release serving every endpoint the server uses, such
+	// as /getfixall, save for those with a fallback
+	minOmniSharpVersion = omnisharpVersion{1, 35, 0}
+	// untestedOmniSharpVersion is the first release the server wasn't written against
+	untestedOmniSharpVersion = omnisharpVersion{2, 0, 0}
+	// goToDefinitionV2Version introduced /v2/gotodefinition; older releases are asked
+	// /gotodefinition, which finds a single definition
+	goToDefinitionV2Version = omnisharpVersion{1, 37, 10}
+)
+
+// versionNumber matches the version OmniSharp prints for --version
+var versionNumber = regexp.MustCompile(`(\d+)\.(\d+)\.(\d+)`)
+
+func parseOmniSharpVersion(output string) (omnisharpVersion, bool) {
+	match := versionNumber.FindStringSubmatch(output)
+	if match == nil {
+		return omnisharpVersion{}, false
+	}
+	var parts [3]int
+	for i := range parts {
+		parts[i], _ = strconv.Atoi(match[i+1])
+	}
+	return omnisharpVersion{parts[0], parts[1], parts[2]}, true
+}
+
+func (v omnisharpVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+}
+
+// before reports whether v is a known release older than other
+func (v omnisharpVersion) before(other omnisharpVersion) bool {
+	if v == (omnisharpVersion{}) {
+		return false
+	}
+	if v.major != other.major {
+		return v.major < other.major
+	}
+	if v.minor != other.minor {
+		return v.minor < other.minor
+	}
+	return v.patch < other.patch
+}
+
+// versionTimeout bounds running OmniSharp with --version
+const versionTimeout = 10 * time.Second
+
+// readOmniSharpVersion runs the configured OmniSharp with --version
+func readOmniSharpVersion(config OmniSharpConfig) (omnisharpVersion, error) {
+	name, args, err := omnisharpCommand(config, []string{"--version"})
+	if err != nil {
+		return omnisharpVersion{}, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), versionTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return omnisharpVersion{}, fmt.Errorf("failed to run %s --version: %w", config.Path, err)
+	}
+	version, ok := parseOmniSharpVersion(string(output))
+	if !ok {
+		return omnisharpVersion{}, fmt.Errorf("%s --version printed no version: %s", config.Path, strings.TrimSpace(string(output)))
+	}
+	return version, nil
+}
+
+// versionWarning explains a release outside the range the server supports, as a message to
+// localize and format with the release and the end of the range it falls out of
+func versionWarning(version omnisharpVersion) (string, omnisharpVersion, bool) {
+	switch {
+	case version.before(minOmniSharpVersion):
+		return "Unity LSP: OmniSharp %s is older than %s, the oldest release supported, so some features will fail. Update OmniSharp, or point omnisharp.path at a newer one.", minOmniSharpVersion, true
+	case version != (omnisharpVersion{}) && !version.before(untestedOmniSharpVersion):
+		return "Unity LSP: OmniSharp %s is newer than the releases supported, before %s, so some features may fail.", untestedOmniSharpVersion, true
+	}
+	return "", omnisharpVersion{}, false
+}
+
+// runCheck implements --check: it validates the OmniSharp at path, or the default one, and
+// returns the exit status, non-zero when it can't run or its release isn't supported
+func runCheck(args []string) int {
+	config := DefaultConfig().OmniSharp
+	if len(args) > 0 {
+		config.Path = args[0]
+	}
+	version, err := readOmniSharpVersion(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if warning, bound, ok := versionWarning(version); ok {
+		fmt.Fprintln(os.Stderr, strings.TrimPrefix(fmt.Sprintf(warning, version, bound), "Unity LSP: "))
+		return 1
+	}
+	fmt.Printf("OmniSharp %s at %s is supported\n", version, config.Path)
+	return 0
+}
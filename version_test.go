@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+// fakeOmniSharpBinary writes a script standing in for OmniSharp, which runs script for
+// --version, and returns its path
+func fakeOmniSharpBinary(t *testing.T, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake OmniSharp is a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "OmniSharp")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestVersionWarning(t *testing.T) {
+	tests := []struct {
+		version   omnisharpVersion
+		wantWarn  bool
+		wantBound omnisharpVersion
+	}{
+		{omnisharpVersion{1, 34, 9}, true, minOmniSharpVersion},
+		{omnisharpVersion{1, 35, 0}, false, omnisharpVersion{}},
+		{omnisharpVersion{1, 39, 11}, false, omnisharpVersion{}},
+		{omnisharpVersion{2, 0, 0}, true, untestedOmniSharpVersion},
+		// A release OmniSharp couldn't tell isn't warned about
+		{omnisharpVersion{}, false, omnisharpVersion{}},
+	}
+	for _, test := range tests {
+		warning, bound, ok := versionWarning(test.version)
+		if ok != test.wantWarn || bound != test.wantBound || ok == (warning == "") {
+			t.Errorf("versionWarning(%s) = %q, %s, %v; want a warning %v bounded by %s", test.version, warning, bound, ok, test.wantWarn, test.wantBound)
+		}
+	}
+}
+
+// TestOutdatedOmniSharpWarns checks the user is shown the warning for an OmniSharp too old
+func TestOutdatedOmniSharpWarns(t *testing.T) {
+	s, client := newTestServer(t, nil)
+	path := fakeOmniSharpBinary(t, "echo OmniSharp 1.30.2")
+	configure(s, func(config *Config) { config.OmniSharp.Path = path })
+
+	if version := s.checkOmniSharpVersion(context.Background()); version != (omnisharpVersion{1, 30, 2}) {
+		t.Errorf("read version %s, want 1.30.2", version)
+	}
+	waitFor(t, "the warning", func() bool { return len(client.received(protocol.MethodWindowShowMessage)) > 0 })
+	var params protocol.ShowMessageParams
+	if err := json.Unmarshal(client.received(protocol.MethodWindowShowMessage)[0], &params); err != nil {
+		t.Fatal(err)
+	}
+	if params.Type != protocol.MessageTypeWarning || !strings.Contains(params.Message, "1.30.2 is older than 1.35.0") {
+		t.Errorf("showed %v %q, want a warning naming both releases", params.Type, params.Message)
+	}
+}
+
+func TestGoToDefinitionVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version omnisharpVersion
+		want    string
+	}{
+		{"before v2", omnisharpVersion{1, 37, 9}, "/gotodefinition"},
+		{"v2", goToDefinitionV2Version, "/v2/gotodefinition"},
+		{"unknown", omnisharpVersion{}, "/v2/gotodefinition"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{
+				"/gotodefinition":    map[string]interface{}{"FileName": "/project/Assets/Enemy.cs", "Line": 3, "Column": 17},
+				"/v2/gotodefinition": definitionResponse("/project/Assets/Enemy.cs"),
+			})
+			s, _ := newTestServer(t, fake)
+			s.omnisharp.version = test.version
+
+			definitions, err := s.omnisharp.goToDefinition(context.Background(), testURI(s, "Player.cs"), protocol.Position{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if calls := fake.callCount(test.want); calls != 1 {
+				t.Errorf("asked %s %d times, want once", test.want, calls)
+			}
+			if len(definitions.Definitions) != 1 || definitions.Definitions[0].Location.FileName != "/project/Assets/Enemy.cs" {
+				t.Errorf("definitions = %+v, want Enemy.cs", definitions)
+			}
+		})
+	}
+}
+
+func TestRunCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   int
+	}{
+		{"supported", "echo 1.39.11", 0},
+		{"too old", "echo 1.30.2", 1},
+		{"too new", "echo 2.1.0", 1},
+		{"no version", "echo OmniSharp", 1},
+		{"fails", "exit 3", 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := runCheck([]string{fakeOmniSharpBinary(t, test.script)}); got != test.want {
+				t.Errorf("runCheck exited %d, want %d", got, test.want)
+			}
+		})
+	}
+	t.Run("missing", func(t *testing.T) {
+		if got := runCheck([]string{filepath.Join(t.TempDir(), "OmniSharp")}); got == 0 {
+			t.Errorf("runCheck of a missing OmniSharp exited 0")
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+// workspaceDiagnosticsDelay is how long edits must pause before a solution-wide codecheck
+const workspaceDiagnosticsDelay = 2 * time.Second
+
+// workspaceDiagnostics publishes diagnostics for the files of the solution the client hasn't
+// opened, with diagnostics.scope set to fullProject. Documents in the store get their own passes
+type workspaceDiagnostics struct {
+	mu        sync.Mutex
+	timer     *time.Timer
+	published map[protocol.DocumentURI]bool
+}
+
+func newWorkspaceDiagnostics() *workspaceDiagnostics {
+	return &workspaceDiagnostics{published: make(map[protocol.DocumentURI]bool)}
+}
+
+// schedule runs a solution-wide check once edits pause, if the scope asks for one
+func (w *workspaceDiagnostics) schedule(s *Server) {
+	if s.currentConfig().Diagnostics.Scope != scopeFullProject {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	// Nothing is published until the quiet period after loading is over anyway
+	delay := max(workspaceDiagnosticsDelay, s.diagnostics.quietRemaining())
+	w.timer = time.AfterFunc(delay, func() {
+		w.run(context.Background(), s)
+	})
+}
+
+func (w *workspaceDiagnostics) run(ctx context.Context, s *Server) {
+	omnisharp := s.backend()
+	if omnisharp == nil {
+		return
+	}
+
+	// Without a file name OmniSharp checks the whole solution
+	response, err := omnisharp.SendRequest(ctx, "/codecheck", map[string]interface{}{})
+	if err != nil {
+		log.Printf("solution-wide codecheck failed: %v", err)
+		return
+	}
+	var omnisharpResponse struct {
+		QuickFixes []QuickFix `json:"QuickFixes"`
+	}
+	if err := json.Unmarshal(response, &omnisharpResponse); err != nil {
+		log.Printf("solution-wide codecheck failed: %v", err)
+		return
+	}
+
+	byFile := make(map[protocol.DocumentURI][]QuickFix)
+	for _, fix := range omnisharpResponse.QuickFixes {
+		uri := pathToURI(fix.FileName)
+		if fix.LogLevel == "Hidden" || (!s.currentConfig().Generated.Diagnostics && s.isGenerated(uri)) {
+			continue
+		}
+		byFile[uri] = append(byFile[uri], fix)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for uri, fixes := range byFile {
+		if _, ok := s.documents.Get(uri); ok {
+			continue
+		}
+		// Clamp to the file on disk, which is what OmniSharp checked for files nobody edits
+		text, err := os.ReadFile(uri.Filename())
+		if err != nil {
+			continue
+		}
+		lines := splitLines(string(text))
+		diagnostics := make([]protocol.Diagnostic, len(fixes))
+		for i, fix := range fixes {
+			diagnostics[i] = quickFixDiagnostic(fix, lines)
+		}
+		s.client.PublishDiagnostics(ctx, &protocol.PublishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+		w.published[uri] = true
+	}
+
+	// Files whose problems were fixed, or that the client has opened since
+	for uri := range w.published {
+		_, open := s.documents.Get(uri)
+		if _, ok := byFile[uri]; !ok || open {
+			if !open {
+				publishEmpty(ctx, s.client, uri)
+			}
+			delete(w.published, uri)
+		}
+	}
+}
+
+// clear stops any pending check and removes what was published, e.g. once the scope no longer
+// asks for solution-wide diagnostics
+func (w *workspaceDiagnostics) clear(ctx context.Context, client protocol.Client) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	for uri := range w.published {
+		publishEmpty(ctx, client, uri)
+	}
+	w.published = make(map[protocol.DocumentURI]bool)
+}
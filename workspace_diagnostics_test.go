@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+// publishedFor are the diagnostics counts of each publishDiagnostics the client got, by URI
+func publishedFor(t *testing.T, client *testClient) map[protocol.DocumentURI][]int {
+	t.Helper()
+	counts := make(map[protocol.DocumentURI][]int)
+	for _, raw := range client.received(protocol.MethodTextDocumentPublishDiagnostics) {
+		var params protocol.PublishDiagnosticsParams
+		if err := json.Unmarshal(raw, &params); err != nil {
+			t.Fatal(err)
+		}
+		counts[params.URI] = append(counts[params.URI], len(params.Diagnostics))
+	}
+	return counts
+}
+
+func TestDiagnosticsScope(t *testing.T) {
+	tests := []struct {
+		scope string
+		want  bool
+	}{
+		{scopeOpenFiles, false},
+		{scopeFullProject, true},
+	}
+	for _, test := range tests {
+		t.Run(test.scope, func(t *testing.T) {
+			s, _ := newTestServer(t, newFakeOmniSharp(t, map[string]interface{}{}))
+			configure(s, func(config *Config) { config.Diagnostics.Scope = test.scope })
+			s.workspaceDiagnostics.schedule(s)
+			s.workspaceDiagnostics.mu.Lock()
+			scheduled := s.workspaceDiagnostics.timer != nil
+			s.workspaceDiagnostics.mu.Unlock()
+			s.workspaceDiagnostics.clear(context.Background(), s.client)
+			if scheduled != test.want {
+				t.Errorf("scheduled a solution-wide check: %v, want %v", scheduled, test.want)
+			}
+		})
+	}
+}
+
+// TestWorkspaceDiagnosticsUnopenedFiles runs solution-wide checks, which publish for the files
+// nobody opened and clear them once fixed
+func TestWorkspaceDiagnosticsUnopenedFiles(t *testing.T) {
+	fake := newFakeOmniSharp(t, map[string]interface{}{})
+	s, client := newTestServer(t, fake)
+	configure(s, func(config *Config) { config.Diagnostics.Scope = scopeFullProject })
+	enemy := filepath.Join(s.rootPath, "Enemy.cs")
+	if err := os.WriteFile(enemy, []byte("class Enemy { int x = y; }"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	player := testURI(s, "Player.cs")
+	openTestDocument(s, player, "class Player { int x = y; }")
+	fix := func(file string) QuickFix {
+		return QuickFix{Id: "CS0103", LogLevel: "Error", FileName: file, Line: 0, Column: 22, EndLine: 0, EndColumn: 23, Text: "The name 'y' does not exist"}
+	}
+	generated := filepath.Join(s.rootPath, "obj", "Debug", "Game.AssemblyInfo.cs")
+
+	runs := []struct {
+		name  string
+		fixes []QuickFix
+		want  []int
+	}{
+		{"problems", []QuickFix{fix(enemy), fix(player.Filename()), fix(generated)}, []int{1}},
+		{"fixed", nil, []int{1, 0}},
+	}
+	for _, run := range runs {
+		fake.setResponse("/codecheck", map[string]interface{}{"QuickFixes": run.fixes})
+		s.workspaceDiagnostics.run(context.Background(), s)
+		waitFor(t, run.name+" published", func() bool { return len(publishedFor(t, client)[pathToURI(enemy)]) == len(run.want) })
+		published := publishedFor(t, client)
+		if got := published[pathToURI(enemy)]; len(got) != len(run.want) || got[len(got)-1] != run.want[len(run.want)-1] {
+			t.Errorf("%s: published %v for Enemy.cs, want %v", run.name, got, run.want)
+		}
+		if got := published[pathToURI(generated)]; len(got) != 0 {
+			t.Errorf("%s: published %v for a generated file", run.name, got)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"go.lsp.dev/protocol"
+)
+
+// methodWorkspaceSymbolResolve fills in what a lazily resolved workspace symbol left out. It is
+// from LSP 3.17, which go.lsp.dev/protocol v0.12.0 predates
+const methodWorkspaceSymbolResolve = "workspaceSymbol/resolve"
+
+// WorkspaceSymbolOptions mirrors the 3.17 options of workspace/symbol
+type WorkspaceSymbolOptions struct {
+	ResolveProvider bool `json:"resolveProvider,omitempty"`
+}
+
+// WorkspaceSymbol is the 3.17 result of workspace/symbol, whose location may be a bare URI
+// until resolved
+type WorkspaceSymbol struct {
+	Name          string              `json:"name"`
+	Kind          protocol.SymbolKind `json:"kind"`
+	ContainerName string              `json:"containerName,omitempty"`
+	// Location is a protocol.Location, or a workspaceSymbolURI until resolved
+	Location interface{}          `json:"location"`
+	Data     *workspaceSymbolData `json:"data,omitempty"`
+}
+
+type workspaceSymbolURI struct {
+	URI protocol.DocumentURI `json:"uri"`
+}
+
+// workspaceSymbolData identifies a symbol for resolve: the file OmniSharp reported it in and
+// where its name starts, which together tell symbols of the same name apart
+type workspaceSymbolData struct {
+	FileName string `json:"fileName"`
+	Line     uint32 `json:"line"`
+	Column   uint32 `json:"column"`
+}
+
+func (d workspaceSymbolData) key() string {
+	return fmt.Sprintf("%s:%d:%d", d.FileName, d.Line, d.Column)
+}
+
+// lazySymbolProperties are the properties of a WorkspaceSymbol the client resolves lazily,
+// from its workspace.symbol.resolveSupport capability
+type lazySymbolProperties struct {
+	Range     bool
+	Container bool
+}
+
+// parseLazySymbolProperties reads the resolveSupport capability from the raw initialize
+// params, since protocol.ClientCapabilities doesn't have it
+func parseLazySymbolProperties(raw json.RawMessage) lazySymbolProperties {
+	var params struct {
+		Capabilities struct {
+			Workspace struct {
+				Symbol struct {
+					ResolveSupport struct {
+						Properties []string `json:"properties"`
+					} `json:"resolveSupport"`
+				} `json:"symbol"`
+			} `json:"workspace"`
+		} `json:"capabilities"`
+	}
+	if json.Unmarshal(raw, &params) != nil {
+		return lazySymbolProperties{}
+	}
+	properties := params.Capabilities.Workspace.Symbol.ResolveSupport.Properties
+	return lazySymbolProperties{
+		Range:     containsString(properties, "location.range"),
+		Container: containsString(properties, "containerName"),
+	}
+}
+
+// lazySymbols reports whether workspace/symbol leaves anything for the client to resolve
+func (p lazySymbolProperties) lazySymbols() bool {
+	return p.Range || p.Container
+}
+
+// symbolResults remembers the symbols of the last workspace/symbol query, so resolving one of
+// them doesn't ask OmniSharp again
+type symbolResults struct {
+	mu      sync.Mutex
+	symbols map[string]SymbolLocation
+}
+
+func (r *symbolResults) store(symbols []SymbolLocation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.symbols = make(map[string]SymbolLocation, len(symbols))
+	for _, symbol := range symbols {
+		r.symbols[symbolData(symbol).key()] = symbol
+	}
+}
+
+func (r *symbolResults) lookup(data workspaceSymbolData) (SymbolLocation, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	symbol, ok := r.symbols[data.key()]
+	return symbol, ok
+}
+
+func symbolData(symbol SymbolLocation) workspaceSymbolData {
+	return workspaceSymbolData{FileName: symbol.FileName, Line: symbol.Line, Column: symbol.Column}
+}
+
+// lazyWorkspaceSymbol converts a symbol leaving out what the client resolves lazily
+func (s *Server) lazyWorkspaceSymbol(symbol SymbolLocation, location protocol.Location) WorkspaceSymbol {
+	lazy := s.lazySymbolProperties
+	converted := WorkspaceSymbol{
+		Name:     symbol.Text,
+		Kind:     convertSymbolKind(symbol.Kind),
+		Location: location,
+	}
+	if lazy.Range {
+		converted.Location = workspaceSymbolURI{URI: location.URI}
+	}
+	if !lazy.Container {
+		converted.ContainerName = s.assemblyContainer(location.URI.Filename(), symbol.ContainingSymbolName)
+	}
+	data := symbolData(symbol)
+	converted.Data = &data
+	return converted
+}
+
+// handleWorkspaceSymbolResolve fills in the location and container of a symbol from the last
+// query, or, once a newer query replaced it, by searching for the symbol's name again
+func (s *Server) handleWorkspaceSymbolResolve(ctx context.Context, symbol *WorkspaceSymbol) (*WorkspaceSymbol, error) {
+	if symbol.Data == nil {
+		return symbol, nil
+	}
+
+	found, ok := s.symbolResults.lookup(*symbol.Data)
+	if !ok {
+		omnisharp := s.backend()
+		if omnisharp == nil {
+			return symbol, nil
+		}
+		symbols, err := findSymbols(ctx, omnisharp, symbol.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, candidate := range symbols {
+			if symbolData(candidate) == *symbol.Data {
+				found, ok = candidate, true
+				break
+			}
+		}
+		if !ok {
+			return symbol, nil
+		}
+	}
+
+	location := s.quickFixLocation(found.QuickFix)
+	symbol.Location = location
+	symbol.ContainerName = s.assemblyContainer(location.URI.Filename(), found.ContainingSymbolName)
+	return symbol, nil
+}
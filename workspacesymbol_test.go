@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestParseLazySymbolProperties(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want lazySymbolProperties
+	}{
+		{"range and container", `{"capabilities": {"workspace": {"symbol": {"resolveSupport": {"properties": ["location.range", "containerName"]}}}}}`, lazySymbolProperties{Range: true, Container: true}},
+		{"range", `{"capabilities": {"workspace": {"symbol": {"resolveSupport": {"properties": ["location.range"]}}}}}`, lazySymbolProperties{Range: true}},
+		{"other properties", `{"capabilities": {"workspace": {"symbol": {"resolveSupport": {"properties": ["tags"]}}}}}`, lazySymbolProperties{}},
+		{"no resolve support", `{"capabilities": {"workspace": {"symbol": {}}}}`, lazySymbolProperties{}},
+		{"malformed", `{"capabilities": []}`, lazySymbolProperties{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := parseLazySymbolProperties(json.RawMessage(test.raw)); got != test.want {
+				t.Errorf("parseLazySymbolProperties = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestWorkspaceSymbolResolve checks lazily resolving clients get symbols with a bare URI and
+// no container, which resolve fills in, from the last query or by searching again
+func TestWorkspaceSymbolResolve(t *testing.T) {
+	symbol := func(name string, line uint32) SymbolLocation {
+		return SymbolLocation{
+			QuickFix:             QuickFix{FileName: "/project/Assets/" + name + ".cs", Line: line, Column: 6, EndLine: line, EndColumn: 6 + uint32(len(name)), Text: name},
+			Kind:                 "Class",
+			ContainingSymbolName: "Game",
+		}
+	}
+	player, enemy := symbol("Player", 3), symbol("Enemy", 5)
+	tests := []struct {
+		name string
+		// newer is searched for after the symbol resolved was found, replacing its results
+		newer string
+		// wantSearches is how many times OmniSharp was searched, resolve included
+		wantSearches int
+	}{
+		{name: "from the last query", wantSearches: 1},
+		{name: "after a newer query", newer: "Enemy", wantSearches: 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOmniSharp(t, map[string]interface{}{})
+			s, _ := newTestServer(t, fake)
+			s.lazySymbolProperties = lazySymbolProperties{Range: true, Container: true}
+			searches := 0
+			fake.setHandler("/findsymbols", func() interface{} {
+				searches++
+				if searches == 2 {
+					return map[string]interface{}{"QuickFixes": []SymbolLocation{enemy}}
+				}
+				return map[string]interface{}{"QuickFixes": []SymbolLocation{player}}
+			})
+
+			result, err := call(t, s, 1, protocol.MethodWorkspaceSymbol, protocol.WorkspaceSymbolParams{Query: "Player"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoded, _ := json.Marshal(result)
+			var found []json.RawMessage
+			if err := json.Unmarshal(encoded, &found); err != nil || len(found) != 1 {
+				t.Fatalf("found %s", encoded)
+			}
+			if lazy := string(found[0]); strings.Contains(lazy, "range") || strings.Contains(lazy, "containerName") || !strings.Contains(lazy, `"uri":"file:///project/Assets/Player.cs"`) {
+				t.Errorf("found %s, want the URI alone", lazy)
+			}
+			if test.newer != "" {
+				if _, err := call(t, s, 2, protocol.MethodWorkspaceSymbol, protocol.WorkspaceSymbolParams{Query: test.newer}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var unresolved WorkspaceSymbol
+			if err := json.Unmarshal(found[0], &unresolved); err != nil {
+				t.Fatal(err)
+			}
+			resolved, err := call(t, s, 3, methodWorkspaceSymbolResolve, unresolved)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := resolved.(*WorkspaceSymbol)
+			want := protocol.Location{
+				URI:   "file:///project/Assets/Player.cs",
+				Range: protocol.Range{Start: protocol.Position{Line: 3, Character: 6}, End: protocol.Position{Line: 3, Character: 12}},
+			}
+			if got.Location != want || got.ContainerName != "Game" || got.Name != "Player" {
+				t.Errorf("resolved to %+v, want %+v in Game", got, want)
+			}
+			if searches := fake.callCount("/findsymbols"); searches != test.wantSearches {
+				t.Errorf("searched %d times, want %d", searches, test.wantSearches)
+			}
+		})
+	}
+}
+
+// TestEagerWorkspaceSymbols checks clients without resolve support get whole symbols
+func TestEagerWorkspaceSymbols(t *testing.T) {
+	fake := newFakeOmniSharp(t, map[string]interface{}{"/findsymbols": map[string]interface{}{"QuickFixes": []SymbolLocation{{
+		QuickFix:             QuickFix{FileName: "/project/Assets/Player.cs", Line: 3, Column: 6, EndLine: 3, EndColumn: 12, Text: "Player"},
+		Kind:                 "Class",
+		ContainingSymbolName: "Game",
+	}}}})
+	s, _ := newTestServer(t, fake)
+
+	result, err := s.handleWorkspaceSymbol(context.Background(), &protocol.WorkspaceSymbolParams{Query: "Player"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	symbols, ok := result.([]protocol.SymbolInformation)
+	if !ok || len(symbols) != 1 || symbols[0].ContainerName != "Game" || symbols[0].Location.Range.End.Character != 12 {
+		t.Errorf("found %+v, want Player located in Game", result)
+	}
+}
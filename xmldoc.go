@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// xmlDocToMarkdown renders a C# XML documentation comment as markdown: the summary, then
+// what a method returns and any remarks. Documentation that isn't XML, such as text
+// OmniSharp already converted, is returned unchanged
+func xmlDocToMarkdown(doc string) string {
+	if !strings.Contains(doc, "</") && !strings.Contains(doc, "/>") {
+		return doc
+	}
+
+	sections, err := xmlDocSections(doc)
+	if err != nil {
+		return doc
+	}
+
+	var parts []string
+	if summary := sections["summary"]; summary != "" {
+		parts = append(parts, summary)
+	}
+	if returns := sections["returns"]; returns != "" {
+		parts = append(parts, "Returns: "+returns)
+	}
+	if remarks := sections["remarks"]; remarks != "" {
+		parts = append(parts, remarks)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// xmlDocSections collects the markdown text of each top-level element of doc, keyed by
+// element name. A <member> wrapper, as in documentation files, is looked through
+func xmlDocSections(doc string) (map[string]string, error) {
+	decoder := xml.NewDecoder(strings.NewReader("<doc>" + doc + "</doc>"))
+	decoder.Strict = false
+	// Only <br> is written unclosed; HTML's list would also close <param>
+	decoder.AutoClose = []string{"br"}
+	decoder.Entity = xml.HTMLEntity
+
+	sections := make(map[string]string)
+	var section string
+	var text strings.Builder
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return sections, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			name := t.Name.Local
+			if depth == 2 && name == "member" {
+				depth--
+				continue
+			}
+			if depth == 2 {
+				section = name
+				text.Reset()
+				continue
+			}
+			switch name {
+			case "see", "seealso":
+				text.WriteString(" `" + seeTarget(t) + "` ")
+			case "paramref", "typeparamref":
+				text.WriteString(" `" + xmlAttr(t, "name") + "` ")
+			case "c":
+				text.WriteString(" `")
+			case "para", "br":
+				text.WriteString("\n\n")
+			}
+		case xml.EndElement:
+			name := t.Name.Local
+			if name == "member" && depth == 1 {
+				continue
+			}
+			if depth == 2 && section != "" {
+				sections[section] = normalizeDocText(text.String())
+				section = ""
+			}
+			if name == "c" {
+				text.WriteString("` ")
+			}
+			depth--
+		case xml.CharData:
+			if section != "" {
+				text.Write(t)
+			}
+		}
+	}
+}
+
+// seeTarget is the short name a <see> refers to, e.g. Vector3 for cref="T:UnityEngine.Vector3"
+func seeTarget(element xml.StartElement) string {
+	if word := xmlAttr(element, "langword"); word != "" {
+		return word
+	}
+	if href := xmlAttr(element, "href"); href != "" {
+		return href
+	}
+
+	cref := xmlAttr(element, "cref")
+	if i := strings.Index(cref, ":"); i == 1 {
+		cref = cref[2:]
+	}
+	if i := strings.Index(cref, "("); i >= 0 {
+		cref = cref[:i]
+	}
+	return cref[strings.LastIndex(cref, ".")+1:]
+}
+
+func xmlAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// normalizeDocText collapses the indentation and line breaks of a doc comment into single
+// spaces, keeping the paragraph breaks of <para>
+func normalizeDocText(text string) string {
+	paragraphs := strings.Split(text, "\n\n")
+	kept := paragraphs[:0]
+	for _, paragraph := range paragraphs {
+		words := strings.Join(strings.Fields(paragraph), " ")
+		// Inline code was padded with spaces to separate it from the text around it
+		words = strings.NewReplacer("` .", "`.", "` ,", "`,", "` )", "`)", "( `", "(`").Replace(words)
+		if words != "" {
+			kept = append(kept, words)
+		}
+	}
+	return strings.Join(kept, "\n\n")
+}
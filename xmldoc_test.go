@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestXMLDocToMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"plain text", "Moves the transform.", "Moves the transform."},
+		{"summary", "<summary>Moves the transform.</summary>", "Moves the transform."},
+		{
+			name: "returns",
+			doc:  "<summary>Finds a component.</summary><returns>The component, or null if there is none.</returns>",
+			want: "Finds a component.\n\nReturns: The component, or null if there is none.",
+		},
+		{
+			name: "returns before summary, and remarks",
+			doc:  "<returns>A <see cref=\"T:UnityEngine.Vector3\"/>.</returns><remarks>Allocates.</remarks><summary>Gets the velocity.</summary>",
+			want: "Gets the velocity.\n\nReturns: A `Vector3`.\n\nAllocates.",
+		},
+		{
+			name: "member wrapper and indentation",
+			doc:  "<member name=\"M:Player.Jump\">\n  <summary>\n    Jumps once.\n  </summary>\n  <param name=\"height\">How high.</param>\n</member>",
+			want: "Jumps once.",
+		},
+		{
+			name: "inline references",
+			doc:  "<summary>Clamps <paramref name=\"value\"/> between <c>min</c> and <c>max</c>, see <see langword=\"null\"/>.</summary>",
+			want: "Clamps `value` between `min` and `max`, see `null`.",
+		},
+		{
+			name: "paragraphs",
+			doc:  "<summary>First.<para>Second.</para></summary>",
+			want: "First.\n\nSecond.",
+		},
+		{
+			name: "unclosed line break",
+			doc:  "<summary>First.<br>Second.</summary>",
+			want: "First.\n\nSecond.",
+		},
+		{"entities", "<summary>Less &lt; more &amp; less.</summary>", "Less < more & less."},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := xmlDocToMarkdown(test.doc); got != test.want {
+				t.Errorf("xmlDocToMarkdown = %q, want %q", got, test.want)
+			}
+		})
+	}
+}